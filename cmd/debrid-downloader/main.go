@@ -1,30 +1,94 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/auth"
+	"debrid-downloader/internal/cli"
 	"debrid-downloader/internal/config"
 	"debrid-downloader/internal/database"
 	"debrid-downloader/internal/downloader"
+	"debrid-downloader/internal/metrics"
+	"debrid-downloader/internal/retention"
+	"debrid-downloader/internal/settings"
 	"debrid-downloader/internal/web"
 	"debrid-downloader/pkg/models"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		if err := hashPassword(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		if err := runCommand(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	if err := run(); err != nil {
 		slog.Error("Application failed", "error", err)
 		os.Exit(1)
 	}
 }
 
+// hashPassword prints a bcrypt hash for AUTH_PASSWORD_HASH, reading the password from stdin if not given
+func hashPassword(args []string) error {
+	var password string
+	if len(args) > 0 {
+		password = args[0]
+	} else {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read password: %w", err)
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if password == "" {
+		return fmt.Errorf("password must not be empty")
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(hash)
+	return nil
+}
+
+// runCommand runs a command-line subcommand, cancelling it on interrupt
+func runCommand(args []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := cli.New().Run(ctx, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
 func run() error {
 	// Load configuration
 	cfg, err := config.Load()
@@ -35,6 +99,9 @@ func run() error {
 	// Setup structured logging
 	setupLogging(cfg.LogLevel)
 
+	// Reload settings on SIGHUP or when the config file changes
+	reloader := config.NewReloader(cfg)
+
 	slog.Info("Starting Debrid Downloader", "version", "1.0.0")
 
 	// Initialize database
@@ -48,8 +115,16 @@ func run() error {
 		}
 	}()
 
+	// Make sure the configured admin account exists and owns records created before accounts did
+	if (cfg.AuthPasswordHash != "" || cfg.AuthProxyHeader != "") && cfg.AuthUsername != "" {
+		if _, err := db.EnsureAdminUser(cfg.AuthUsername, cfg.AuthPasswordHash); err != nil {
+			return fmt.Errorf("failed to set up admin user: %w", err)
+		}
+	}
+
 	// Initialize AllDebrid client
 	allDebridClient := alldebrid.New(cfg.AllDebridAPIKey)
+	allDebridClient.SetTimeout(cfg.AllDebridTimeout)
 
 	// Validate API key (warn but don't exit if validation fails during development)
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -63,13 +138,59 @@ func run() error {
 
 	// Initialize download worker
 	downloadWorker := downloader.NewWorker(db, cfg.BaseDownloadsPath)
+	downloadWorker.SetLinkUnlocker(metrics.InstrumentClient(allDebridClient))
+
+	// Runtime settings start from the configuration, with the ones changed from the
+	// settings page stored in the database
+	settingsService, err := settings.NewService(db, settings.FromConfig(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	// Old downloads are removed from the history once a day
+	history := retention.NewService(db, cfg.HistoryArchivePath)
+
+	// Apply the runtime settings now and whenever they change
+	settingsService.Subscribe(func(s settings.Settings) {
+		history.SetPolicy(s.HistoryPolicy())
+		downloadWorker.SetOptions(workerOptions(reloader.Current(), s))
+	})
+
+	// Apply the reloadable configuration now and after every reload
+	reloader.Subscribe(func(cfg *config.Config) {
+		logLevel.Set(parseLogLevel(cfg.LogLevel))
+		settingsService.SetDefaults(settings.FromConfig(cfg))
+	})
 
 	// Initialize web server with download worker
 	server := web.NewServer(db, allDebridClient, cfg, downloadWorker)
+	server.SetSettings(settingsService)
+	server.SetRetention(history)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go reloader.Start(ctx)
 
 	return runServer(server, downloadWorker, db)
 }
 
+// workerOptions returns the download worker's options from the runtime settings and the
+// configuration
+func workerOptions(cfg *config.Config, s settings.Settings) downloader.Options {
+	return downloader.Options{
+		Concurrency:      s.Concurrency,
+		SpeedLimit:       s.SpeedLimit,
+		MaxRetries:       s.MaxRetries,
+		RetryBackoff:     s.RetryBackoff,
+		RetryMaxDelay:    cfg.RetryMaxDelay,
+		RetryJitter:      cfg.RetryJitter,
+		RateLimitWait:    cfg.RateLimitWait,
+		ProgressInterval: cfg.ProgressInterval,
+		Timeout:          cfg.DownloadTimeout,
+		Cleanup:          s.Extensions(),
+	}
+}
+
 func runServer(server *web.Server, downloadWorker *downloader.Worker, db *database.DB) error {
 	// Create main context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -88,8 +209,8 @@ func runServer(server *web.Server, downloadWorker *downloader.Worker, db *databa
 		slog.Error("Failed to queue pending downloads", "error", err)
 	}
 
-	// Start history cleanup routine (runs daily)
-	go startHistoryCleanup(ctx, db)
+	// Start the server's background services: the torrent monitor, webhook dispatcher, notifications, media server refreshes, hooks, watch folder, scheduled backups and history cleanup
+	server.StartBackground(ctx)
 
 	// Start server in goroutine
 	serverErr := make(chan error, 1)
@@ -123,21 +244,12 @@ func runServer(server *web.Server, downloadWorker *downloader.Worker, db *databa
 	return nil
 }
 
+// logLevel is the level the logger writes at, changed when the configuration reloads
+var logLevel = new(slog.LevelVar)
+
 // setupLogging configures structured logging based on the log level
 func setupLogging(level string) {
-	var logLevel slog.Level
-	switch level {
-	case "debug":
-		logLevel = slog.LevelDebug
-	case "info":
-		logLevel = slog.LevelInfo
-	case "warn":
-		logLevel = slog.LevelWarn
-	case "error":
-		logLevel = slog.LevelError
-	default:
-		logLevel = slog.LevelInfo
-	}
+	logLevel.Set(parseLogLevel(level))
 
 	opts := &slog.HandlerOptions{
 		Level: logLevel,
@@ -148,37 +260,18 @@ func setupLogging(level string) {
 	slog.SetDefault(logger)
 }
 
-// startHistoryCleanup runs a goroutine that cleans up old downloads periodically
-func startHistoryCleanup(ctx context.Context, db *database.DB) {
-	ticker := time.NewTicker(24 * time.Hour) // Run daily
-	defer ticker.Stop()
-
-	// Run cleanup immediately on startup
-	cleanupOldDownloads(db)
-
-	for {
-		select {
-		case <-ctx.Done():
-			slog.Info("History cleanup routine shutting down")
-			return
-		case <-ticker.C:
-			cleanupOldDownloads(db)
-		}
-	}
-}
-
-// cleanupOldDownloads removes downloads older than 60 days
-func cleanupOldDownloads(db *database.DB) {
-	retention := 60 * 24 * time.Hour // 60 days
-
-	slog.Info("Running history cleanup", "retention_days", 60)
-
-	if err := db.DeleteOldDownloads(retention); err != nil {
-		slog.Error("Failed to cleanup old downloads", "error", err)
-		return
+// parseLogLevel returns the slog level for a configured log level, defaulting to info
+func parseLogLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
 	}
-
-	slog.Info("History cleanup completed")
 }
 
 // resetOrphanedDownloads finds downloads stuck in downloading state and resets them to pending
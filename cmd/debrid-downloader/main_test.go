@@ -13,6 +13,7 @@ import (
 	"debrid-downloader/internal/config"
 	"debrid-downloader/internal/database"
 	"debrid-downloader/internal/downloader"
+	"debrid-downloader/internal/retention"
 	"debrid-downloader/internal/web"
 
 	"github.com/stretchr/testify/require"
@@ -175,54 +176,6 @@ func TestMainFunction(t *testing.T) {
 	require.Contains(t, err.Error(), "failed to load configuration")
 }
 
-func TestCleanupOldDownloads(t *testing.T) {
-	// Test cleanup function with in-memory database
-	db, err := database.New(":memory:")
-	require.NoError(t, err)
-	defer db.Close()
-
-	// Test cleanup function doesn't panic
-	require.NotPanics(t, func() {
-		cleanupOldDownloads(db)
-	})
-}
-
-func TestCleanupOldDownloadsWithError(t *testing.T) {
-	// Test cleanup function with closed database to trigger error
-	db, err := database.New(":memory:")
-	require.NoError(t, err)
-	db.Close() // Close to trigger error
-
-	// Test cleanup function doesn't panic even with error
-	require.NotPanics(t, func() {
-		cleanupOldDownloads(db)
-	})
-}
-
-func TestStartHistoryCleanup(t *testing.T) {
-	// Test history cleanup routine startup and shutdown
-	db, err := database.New(":memory:")
-	require.NoError(t, err)
-	defer db.Close()
-
-	ctx, cancel := context.WithCancel(context.Background())
-
-	// Start cleanup routine
-	go startHistoryCleanup(ctx, db)
-
-	// Let it run briefly
-	time.Sleep(100 * time.Millisecond)
-
-	// Cancel context to stop cleanup
-	cancel()
-
-	// Give it time to shutdown
-	time.Sleep(100 * time.Millisecond)
-
-	// Test completes successfully
-	require.True(t, true)
-}
-
 func TestRunWithAPIKeyValidation(t *testing.T) {
 	// Set up environment for testing API key validation path
 	os.Setenv("ALLDEBRID_API_KEY", "invalid-test-key")
@@ -274,7 +227,7 @@ func TestRunServerComponents(t *testing.T) {
 	go worker.Start(ctx)
 
 	// Test cleanup function
-	go startHistoryCleanup(ctx, db)
+	go retention.NewService(db, "").Start(ctx)
 
 	// Let components run briefly
 	time.Sleep(50 * time.Millisecond)
@@ -410,47 +363,6 @@ func TestRunServerErrorScenarios(t *testing.T) {
 	}
 }
 
-func TestStartHistoryCleanupTicker(t *testing.T) {
-	// Test the ticker-based cleanup functionality
-	db, err := database.New(":memory:")
-	require.NoError(t, err)
-	defer db.Close()
-
-	ctx, cancel := context.WithCancel(context.Background())
-
-	// Use a shorter interval for testing
-	originalStartHistoryCleanup := func(ctx context.Context, db *database.DB) {
-		ticker := time.NewTicker(10 * time.Millisecond) // Very short for testing
-		defer ticker.Stop()
-
-		// Run cleanup immediately on startup
-		cleanupOldDownloads(db)
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				cleanupOldDownloads(db)
-			}
-		}
-	}
-
-	// Start cleanup routine
-	go originalStartHistoryCleanup(ctx, db)
-
-	// Let it run for a few ticks
-	time.Sleep(50 * time.Millisecond)
-
-	// Cancel context to stop cleanup
-	cancel()
-
-	// Give it time to shutdown
-	time.Sleep(10 * time.Millisecond)
-
-	require.True(t, true)
-}
-
 func TestRunFullFlow(t *testing.T) {
 	// Test a more complete flow of the run function
 	// Set up valid environment
@@ -871,7 +783,7 @@ func runServerWithMockServer(server *mockServer, downloadWorker *downloader.Work
 	go downloadWorker.Start(ctx)
 
 	// Start history cleanup routine (runs daily)
-	go startHistoryCleanup(ctx, db)
+	go retention.NewService(db, "").Start(ctx)
 
 	// Start server in goroutine
 	serverErr := make(chan error, 1)
@@ -911,7 +823,7 @@ func runServerWithSignalChan(server *web.Server, downloadWorker *downloader.Work
 	go downloadWorker.Start(ctx)
 
 	// Start history cleanup routine (runs daily)
-	go startHistoryCleanup(ctx, db)
+	go retention.NewService(db, "").Start(ctx)
 
 	// Start server in goroutine
 	serverErr := make(chan error, 1)
@@ -54,6 +54,10 @@ func (e *APIError) Error() string {
 type AllDebridClient interface {
 	UnrestrictLink(ctx context.Context, link string) (*UnrestrictResult, error)
 	CheckAPIKey(ctx context.Context) error
+	UploadMagnet(ctx context.Context, magnet string) (*Magnet, error)
+	UploadTorrentFile(ctx context.Context, filename string, data []byte) (*Magnet, error)
+	MagnetStatus(ctx context.Context, id int64) (*MagnetStatus, error)
+	DeleteMagnet(ctx context.Context, id int64) error
 }
 
 // New creates a new AllDebrid client
@@ -67,6 +71,11 @@ func New(apiKey string) *Client {
 	}
 }
 
+// SetTimeout sets how long a request to the API may take. Call it before the client is used.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.httpClient.Timeout = timeout
+}
+
 // UnrestrictLink unrestricts a link using the AllDebrid API
 func (c *Client) UnrestrictLink(ctx context.Context, link string) (*UnrestrictResult, error) {
 	params := url.Values{}
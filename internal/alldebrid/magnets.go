@@ -0,0 +1,195 @@
+package alldebrid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// MagnetStatusReady is the status code AllDebrid reports once a magnet's files can be unlocked.
+// Codes below it mean the magnet is still being processed, codes above it are errors.
+const MagnetStatusReady = 4
+
+// Magnet is a magnet or torrent file accepted by AllDebrid
+type Magnet struct {
+	ID    int64  `json:"id"`
+	Hash  string `json:"hash"`
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	Ready bool   `json:"ready"`
+}
+
+// MagnetLink is a hoster link to one of a magnet's files, to be unlocked with UnrestrictLink
+type MagnetLink struct {
+	Link     string `json:"link"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+}
+
+// MagnetStatus reports how far AllDebrid has got with a magnet
+type MagnetStatus struct {
+	ID         int64        `json:"id"`
+	Filename   string       `json:"filename"`
+	Size       int64        `json:"size"`
+	Hash       string       `json:"hash"`
+	Status     string       `json:"status"`
+	StatusCode int          `json:"statusCode"`
+	Downloaded int64        `json:"downloaded"`
+	Links      []MagnetLink `json:"links"`
+}
+
+// Ready reports whether the magnet's files can be downloaded
+func (s *MagnetStatus) Ready() bool {
+	return s.StatusCode == MagnetStatusReady
+}
+
+// Failed reports whether AllDebrid gave up on the magnet
+func (s *MagnetStatus) Failed() bool {
+	return s.StatusCode > MagnetStatusReady
+}
+
+// magnetUploadResult is one entry of an upload response, which reports errors per magnet
+type magnetUploadResult struct {
+	Magnet
+	Error *APIError `json:"error,omitempty"`
+}
+
+// UploadMagnet sends a magnet link to AllDebrid
+func (c *Client) UploadMagnet(ctx context.Context, magnet string) (*Magnet, error) {
+	params := c.params()
+	params.Add("magnets[]", magnet)
+
+	var data struct {
+		Magnets []magnetUploadResult `json:"magnets"`
+	}
+	if err := c.get(ctx, "/magnet/upload", params, &data); err != nil {
+		return nil, err
+	}
+
+	return firstUploadResult(data.Magnets)
+}
+
+// UploadTorrentFile sends the contents of a .torrent file to AllDebrid
+func (c *Client) UploadTorrentFile(ctx context.Context, filename string, data []byte) (*Magnet, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("files[]", filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write torrent file: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish form: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/magnet/upload/file?%s", c.baseURL, c.params().Encode())
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	var result struct {
+		Files []magnetUploadResult `json:"files"`
+	}
+	if err := c.do(req, &result); err != nil {
+		return nil, err
+	}
+
+	return firstUploadResult(result.Files)
+}
+
+// MagnetStatus returns the processing state and, once ready, the links of a magnet
+func (c *Client) MagnetStatus(ctx context.Context, id int64) (*MagnetStatus, error) {
+	params := c.params()
+	params.Set("id", strconv.FormatInt(id, 10))
+
+	var data struct {
+		Magnets MagnetStatus `json:"magnets"`
+	}
+	if err := c.get(ctx, "/magnet/status", params, &data); err != nil {
+		return nil, err
+	}
+
+	return &data.Magnets, nil
+}
+
+// DeleteMagnet removes a magnet from the AllDebrid account
+func (c *Client) DeleteMagnet(ctx context.Context, id int64) error {
+	params := c.params()
+	params.Set("id", strconv.FormatInt(id, 10))
+
+	return c.get(ctx, "/magnet/delete", params, nil)
+}
+
+// params returns the query parameters sent with every request
+func (c *Client) params() url.Values {
+	params := url.Values{}
+	params.Set("agent", "debrid-downloader")
+	params.Set("apikey", c.apiKey)
+	return params
+}
+
+// get makes a GET request to an API path and decodes the response data into out
+func (c *Client) get(ctx context.Context, path string, params url.Values, out any) error {
+	endpoint := fmt.Sprintf("%s%s?%s", c.baseURL, path, params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	return c.do(req, out)
+}
+
+// do sends a request and decodes the response data into out, which may be nil
+func (c *Client) do(req *http.Request, out any) error {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("API request failed with status %d", resp.StatusCode)
+	}
+
+	var apiResp APIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if apiResp.Status != "success" {
+		if apiResp.Error != nil {
+			return apiResp.Error
+		}
+		return fmt.Errorf("API returned status: %s", apiResp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(apiResp.Data, out); err != nil {
+		return fmt.Errorf("failed to parse response data: %w", err)
+	}
+
+	return nil
+}
+
+// firstUploadResult returns the single magnet from an upload response
+func firstUploadResult(results []magnetUploadResult) (*Magnet, error) {
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no magnet in upload response")
+	}
+	if results[0].Error != nil {
+		return nil, results[0].Error
+	}
+	return &results[0].Magnet, nil
+}
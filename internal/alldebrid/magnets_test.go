@@ -0,0 +1,68 @@
+package alldebrid
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Magnets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "test-api-key", r.URL.Query().Get("apikey"))
+
+		switch r.URL.Path {
+		case "/magnet/upload":
+			if r.URL.Query().Get("magnets[]") == "magnet:?xt=urn:btih:bad" {
+				_, _ = w.Write([]byte(`{"status": "success", "data": {"magnets": [{"magnet": "bad", "error": {"code": "MAGNET_INVALID_URI", "message": "Invalid magnet"}}]}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"status": "success", "data": {"magnets": [{"id": 42, "hash": "abc123", "name": "Show.S01E01", "size": 1000, "ready": false}]}}`))
+		case "/magnet/upload/file":
+			file, header, err := r.FormFile("files[]")
+			require.NoError(t, err)
+			data, err := io.ReadAll(file)
+			require.NoError(t, err)
+			require.Equal(t, "show.torrent", header.Filename)
+			require.Equal(t, "d4:infoe", string(data))
+			_, _ = w.Write([]byte(`{"status": "success", "data": {"files": [{"id": 43, "hash": "def456", "name": "Movie", "size": 2000, "ready": true}]}}`))
+		case "/magnet/status":
+			require.Equal(t, "42", r.URL.Query().Get("id"))
+			_, _ = w.Write([]byte(`{"status": "success", "data": {"magnets": {"id": 42, "filename": "Show.S01E01", "hash": "abc123", "status": "Ready", "statusCode": 4, "links": [{"link": "https://alldebrid.com/f/1", "filename": "Show.S01E01.mkv", "size": 1000}]}}}`))
+		case "/magnet/delete":
+			_, _ = w.Write([]byte(`{"status": "success", "data": {"message": "Magnet was successfully deleted"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := New("test-api-key")
+	client.baseURL = server.URL
+	ctx := context.Background()
+
+	magnet, err := client.UploadMagnet(ctx, "magnet:?xt=urn:btih:abc123")
+	require.NoError(t, err)
+	require.Equal(t, int64(42), magnet.ID)
+	require.Equal(t, "abc123", magnet.Hash)
+
+	_, err = client.UploadMagnet(ctx, "magnet:?xt=urn:btih:bad")
+	require.ErrorContains(t, err, "Invalid magnet")
+
+	magnet, err = client.UploadTorrentFile(ctx, "show.torrent", []byte("d4:infoe"))
+	require.NoError(t, err)
+	require.Equal(t, int64(43), magnet.ID)
+	require.True(t, magnet.Ready)
+
+	status, err := client.MagnetStatus(ctx, 42)
+	require.NoError(t, err)
+	require.True(t, status.Ready())
+	require.False(t, status.Failed())
+	require.Len(t, status.Links, 1)
+	require.Equal(t, "Show.S01E01.mkv", status.Links[0].Filename)
+
+	require.NoError(t, client.DeleteMagnet(ctx, 42))
+}
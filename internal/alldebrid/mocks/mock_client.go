@@ -55,6 +55,35 @@ func (mr *MockAllDebridClientMockRecorder) CheckAPIKey(ctx any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckAPIKey", reflect.TypeOf((*MockAllDebridClient)(nil).CheckAPIKey), ctx)
 }
 
+// DeleteMagnet mocks base method.
+func (m *MockAllDebridClient) DeleteMagnet(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteMagnet", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteMagnet indicates an expected call of DeleteMagnet.
+func (mr *MockAllDebridClientMockRecorder) DeleteMagnet(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteMagnet", reflect.TypeOf((*MockAllDebridClient)(nil).DeleteMagnet), ctx, id)
+}
+
+// MagnetStatus mocks base method.
+func (m *MockAllDebridClient) MagnetStatus(ctx context.Context, id int64) (*alldebrid.MagnetStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MagnetStatus", ctx, id)
+	ret0, _ := ret[0].(*alldebrid.MagnetStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// MagnetStatus indicates an expected call of MagnetStatus.
+func (mr *MockAllDebridClientMockRecorder) MagnetStatus(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MagnetStatus", reflect.TypeOf((*MockAllDebridClient)(nil).MagnetStatus), ctx, id)
+}
+
 // UnrestrictLink mocks base method.
 func (m *MockAllDebridClient) UnrestrictLink(ctx context.Context, link string) (*alldebrid.UnrestrictResult, error) {
 	m.ctrl.T.Helper()
@@ -69,3 +98,33 @@ func (mr *MockAllDebridClientMockRecorder) UnrestrictLink(ctx, link any) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnrestrictLink", reflect.TypeOf((*MockAllDebridClient)(nil).UnrestrictLink), ctx, link)
 }
+
+// UploadMagnet mocks base method.
+func (m *MockAllDebridClient) UploadMagnet(ctx context.Context, magnet string) (*alldebrid.Magnet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadMagnet", ctx, magnet)
+	ret0, _ := ret[0].(*alldebrid.Magnet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadMagnet indicates an expected call of UploadMagnet.
+func (mr *MockAllDebridClientMockRecorder) UploadMagnet(ctx, magnet any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadMagnet", reflect.TypeOf((*MockAllDebridClient)(nil).UploadMagnet), ctx, magnet)
+}
+
+// UploadTorrentFile mocks base method.
+func (m *MockAllDebridClient) UploadTorrentFile(ctx context.Context, filename string, data []byte) (*alldebrid.Magnet, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UploadTorrentFile", ctx, filename, data)
+	ret0, _ := ret[0].(*alldebrid.Magnet)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UploadTorrentFile indicates an expected call of UploadTorrentFile.
+func (mr *MockAllDebridClientMockRecorder) UploadTorrentFile(ctx, filename, data any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UploadTorrentFile", reflect.TypeOf((*MockAllDebridClient)(nil).UploadTorrentFile), ctx, filename, data)
+}
@@ -0,0 +1,372 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bcrypt hash of "secret"
+const testHash = "$2a$10$8qRMFrDLMPTbxRR1MfVWOeUflFtgOa8IzYPB2AlcLV1acDtgCXgWO"
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if session := SessionFromContext(r.Context()); session != nil {
+			w.Header().Set("X-User", session.Username)
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestHashPasswordAndCheck(t *testing.T) {
+	hash, err := HashPassword("hunter2")
+	require.NoError(t, err)
+
+	service := NewService(Options{Username: "admin", PasswordHash: hash})
+	require.True(t, service.Enabled())
+	user, ok := service.Authenticate("admin", "hunter2")
+	require.True(t, ok)
+	require.Equal(t, "admin", user.Username)
+	require.True(t, user.IsAdmin())
+
+	_, ok = service.Authenticate("admin", "wrong")
+	require.False(t, ok)
+	_, ok = service.Authenticate("other", "hunter2")
+	require.False(t, ok)
+}
+
+func TestService_Disabled(t *testing.T) {
+	service := NewService(Options{})
+	require.False(t, service.Enabled())
+	_, ok := service.Authenticate("admin", "")
+	require.False(t, ok)
+
+	req := httptest.NewRequest("DELETE", "/downloads/1", nil)
+	w := httptest.NewRecorder()
+	service.Middleware(okHandler()).ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestService_Sessions(t *testing.T) {
+	service := NewService(Options{Username: "admin", PasswordHash: testHash, SessionTTL: time.Hour})
+
+	session, err := service.CreateSession(&models.User{Username: "admin", Role: models.RoleAdmin})
+	require.NoError(t, err)
+	require.NotEmpty(t, session.ID)
+	require.NotEmpty(t, session.CSRFToken)
+	require.Equal(t, session, service.GetSession(session.ID))
+
+	service.DeleteSession(session.ID)
+	require.Nil(t, service.GetSession(session.ID))
+
+	expired, err := service.CreateSession(&models.User{Username: "admin", Role: models.RoleAdmin})
+	require.NoError(t, err)
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	require.Nil(t, service.GetSession(expired.ID))
+}
+
+func TestMiddleware_Unauthenticated(t *testing.T) {
+	service := NewService(Options{Username: "admin", PasswordHash: testHash})
+	handler := service.Middleware(okHandler())
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		headers      map[string]string
+		wantStatus   int
+		wantLocation string
+		wantHXTarget string
+	}{
+		{
+			name:         "page redirects to login",
+			method:       "GET",
+			path:         "/settings",
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "/login?next=" + url.QueryEscape("/settings"),
+		},
+		{
+			name:         "root redirects without next",
+			method:       "GET",
+			path:         "/",
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "/login",
+		},
+		{
+			name:         "htmx request gets HX-Redirect",
+			method:       "POST",
+			path:         "/downloads/search",
+			headers:      map[string]string{"HX-Request": "true"},
+			wantStatus:   http.StatusUnauthorized,
+			wantHXTarget: "/login",
+		},
+		{
+			name:       "api request gets 401",
+			method:     "GET",
+			path:       "/api/stats",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "delete gets 401",
+			method:     "DELETE",
+			path:       "/downloads/1",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "login page is public",
+			method:     "GET",
+			path:       "/login",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantLocation != "" {
+				require.Equal(t, tt.wantLocation, w.Header().Get("Location"))
+			}
+			if tt.wantHXTarget != "" {
+				require.Equal(t, tt.wantHXTarget, w.Header().Get("HX-Redirect"))
+			}
+		})
+	}
+}
+
+func TestMiddleware_CSRF(t *testing.T) {
+	service := NewService(Options{Username: "admin", PasswordHash: testHash})
+	handler := service.Middleware(okHandler())
+
+	session, err := service.CreateSession(&models.User{Username: "admin", Role: models.RoleAdmin})
+	require.NoError(t, err)
+	cookie := &http.Cookie{Name: SessionCookieName, Value: session.ID}
+
+	t.Run("GET does not need a token", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "admin", w.Header().Get("X-User"))
+	})
+
+	t.Run("POST without token is rejected", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/download", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("DELETE with wrong token is rejected", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "/downloads/1", nil)
+		req.AddCookie(cookie)
+		req.Header.Set(CSRFHeader, "wrong")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("DELETE with header token is accepted", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "/downloads/1", nil)
+		req.AddCookie(cookie)
+		req.Header.Set(CSRFHeader, session.CSRFToken)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("POST with form token is accepted", func(t *testing.T) {
+		form := url.Values{CSRFFormField: {session.CSRFToken}}
+		req := httptest.NewRequest("POST", "/logout", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestMiddleware_ProxyHeader(t *testing.T) {
+	service := NewService(Options{
+		ProxyHeader:    "Remote-User",
+		TrustedProxies: []string{"10.0.0.0/8", "not-an-ip"},
+	})
+	handler := service.Middleware(okHandler())
+
+	t.Run("trusted proxy creates session", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.1.2.3:5000"
+		req.Header.Set("Remote-User", "alice")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "alice", w.Header().Get("X-User"))
+		require.Contains(t, w.Header().Get("Set-Cookie"), SessionCookieName)
+	})
+
+	t.Run("untrusted address is ignored", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.10:5000"
+		req.Header.Set("Remote-User", "alice")
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusSeeOther, w.Code)
+	})
+}
+
+func TestParseTrustedProxy(t *testing.T) {
+	network, err := ParseTrustedProxy("192.168.1.5")
+	require.NoError(t, err)
+	require.Equal(t, "192.168.1.5/32", network.String())
+
+	network, err = ParseTrustedProxy(" 10.0.0.0/8 ")
+	require.NoError(t, err)
+	require.Equal(t, "10.0.0.0/8", network.String())
+
+	network, err = ParseTrustedProxy("::1")
+	require.NoError(t, err)
+	require.Equal(t, "::1/128", network.String())
+
+	_, err = ParseTrustedProxy("bogus")
+	require.Error(t, err)
+}
+
+func TestSafeRedirectTarget(t *testing.T) {
+	require.Equal(t, "/", SafeRedirectTarget(""))
+	require.Equal(t, "/settings", SafeRedirectTarget("/settings"))
+	require.Equal(t, "/", SafeRedirectTarget("https://evil.example"))
+	require.Equal(t, "/", SafeRedirectTarget("//evil.example"))
+	require.Equal(t, "/", SafeRedirectTarget("/\\evil.example"))
+}
+
+// memoryUsers is an in-memory UserStore
+type memoryUsers struct {
+	users []*models.User
+}
+
+func (m *memoryUsers) GetUser(id int64) (*models.User, error) {
+	for _, user := range m.users {
+		if user.ID == id {
+			return user, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (m *memoryUsers) GetUserByUsername(username string) (*models.User, error) {
+	for _, user := range m.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (m *memoryUsers) CreateUser(user *models.User) error {
+	user.ID = int64(len(m.users) + 1)
+	m.users = append(m.users, user)
+	return nil
+}
+
+func TestService_UserStore(t *testing.T) {
+	users := &memoryUsers{}
+	require.NoError(t, users.CreateUser(&models.User{Username: "admin", PasswordHash: testHash, Role: models.RoleAdmin}))
+	require.NoError(t, users.CreateUser(&models.User{Username: "bob", PasswordHash: testHash, Role: models.RoleUser}))
+
+	service := NewService(Options{
+		Username:       "admin",
+		PasswordHash:   testHash,
+		ProxyHeader:    "Remote-User",
+		TrustedProxies: []string{"10.0.0.0/8"},
+		Users:          users,
+	})
+
+	var seen *models.User
+	handler := service.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = UserFromContext(r.Context())
+	}))
+
+	t.Run("any stored user can sign in", func(t *testing.T) {
+		user, ok := service.Authenticate("bob", "secret")
+		require.True(t, ok)
+		require.False(t, user.IsAdmin())
+
+		_, ok = service.Authenticate("nobody", "secret")
+		require.False(t, ok)
+	})
+
+	t.Run("session resolves to the stored user", func(t *testing.T) {
+		bob, err := users.GetUserByUsername("bob")
+		require.NoError(t, err)
+		session, err := service.CreateSession(bob)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: session.ID})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		require.NotNil(t, seen)
+		require.Equal(t, bob.ID, seen.ID)
+	})
+
+	t.Run("session for a deleted user is ended", func(t *testing.T) {
+		session, err := service.CreateSession(&models.User{ID: 99, Username: "gone"})
+		require.NoError(t, err)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: session.ID})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusSeeOther, w.Code)
+		require.Nil(t, service.GetSession(session.ID))
+	})
+
+	t.Run("proxy users get a regular account", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.1.2.3:5000"
+		req.Header.Set("Remote-User", "carol")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		carol, err := users.GetUserByUsername("carol")
+		require.NoError(t, err)
+		require.Equal(t, models.RoleUser, carol.Role)
+		require.Equal(t, carol.ID, seen.ID)
+	})
+}
+
+func TestRequireAdmin(t *testing.T) {
+	handler := RequireAdmin(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for role, want := range map[models.UserRole]int{models.RoleAdmin: http.StatusOK, models.RoleUser: http.StatusForbidden} {
+		req := httptest.NewRequest("POST", "/settings/users", nil)
+		req = req.WithContext(WithUser(req.Context(), &models.User{Role: role}))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		require.Equal(t, want, w.Code, role)
+	}
+}
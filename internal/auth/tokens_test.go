@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memoryTokenStore struct {
+	tokens  map[string]*models.APIToken
+	touched int
+}
+
+func (s *memoryTokenStore) GetAPITokenByHash(tokenHash string) (*models.APIToken, error) {
+	token, ok := s.tokens[tokenHash]
+	if !ok {
+		return nil, errors.New("API token not found")
+	}
+	return token, nil
+}
+
+func (s *memoryTokenStore) TouchAPIToken(id int64, usedAt time.Time) error {
+	s.touched++
+	for _, token := range s.tokens {
+		if token.ID == id {
+			token.LastUsedAt = &usedAt
+		}
+	}
+	return nil
+}
+
+func newTokenStore(t *testing.T, scope models.APITokenScope) (*memoryTokenStore, string) {
+	value, prefix, hash, err := GenerateAPIToken()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(value, prefix))
+
+	store := &memoryTokenStore{tokens: map[string]*models.APIToken{
+		hash: {ID: 1, Name: "test", Prefix: prefix, TokenHash: hash, Scope: scope},
+	}}
+	return store, value
+}
+
+func TestGenerateAPIToken(t *testing.T) {
+	value, prefix, hash, err := GenerateAPIToken()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(value, "dd_"))
+	require.Len(t, prefix, 11)
+	require.Equal(t, HashAPIToken(value), hash)
+	require.NotContains(t, hash, value)
+}
+
+func TestMiddleware_APIToken(t *testing.T) {
+	store, value := newTokenStore(t, models.ScopeSubmit)
+	service := NewService(Options{Username: "admin", PasswordHash: testHash, Tokens: store})
+
+	protected := http.NewServeMux()
+	protected.HandleFunc("POST /api/v1/downloads", RequireScope(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}, models.ScopeSubmit))
+	protected.HandleFunc("DELETE /downloads/{id}", RequireScope(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	handler := service.Middleware(protected)
+
+	t.Run("bearer token skips CSRF", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/downloads", nil)
+		req.Header.Set("Authorization", "Bearer "+value)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.Equal(t, 1, store.touched)
+	})
+
+	t.Run("X-API-Key header is accepted", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/downloads", nil)
+		req.Header.Set(APITokenHeader, value)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+		// Last-used timestamp is throttled
+		require.Equal(t, 1, store.touched)
+	})
+
+	t.Run("scope is enforced", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "/downloads/1", nil)
+		req.Header.Set("Authorization", "Bearer "+value)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("unknown token is rejected", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/api/v1/downloads", nil)
+		req.Header.Set("Authorization", "Bearer dd_unknown")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("revoked token is rejected", func(t *testing.T) {
+		revokedAt := time.Now()
+		for _, token := range store.tokens {
+			token.RevokedAt = &revokedAt
+		}
+		req := httptest.NewRequest("POST", "/api/v1/downloads", nil)
+		req.Header.Set("Authorization", "Bearer "+value)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestRequireScope(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+	tests := []struct {
+		name       string
+		token      *models.APIToken
+		scopes     []models.APITokenScope
+		wantStatus int
+	}{
+		{name: "session request", token: nil, wantStatus: http.StatusOK},
+		{name: "admin token on admin route", token: &models.APIToken{Scope: models.ScopeAdmin}, wantStatus: http.StatusOK},
+		{name: "read token on admin route", token: &models.APIToken{Scope: models.ScopeRead}, wantStatus: http.StatusForbidden},
+		{name: "read token on read route", token: &models.APIToken{Scope: models.ScopeRead}, scopes: []models.APITokenScope{models.ScopeRead}, wantStatus: http.StatusOK},
+		{name: "submit token on read route", token: &models.APIToken{Scope: models.ScopeSubmit}, scopes: []models.APITokenScope{models.ScopeRead}, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			if tt.token != nil {
+				req = req.WithContext(WithAPIToken(req.Context(), tt.token))
+			}
+			w := httptest.NewRecorder()
+			RequireScope(ok, tt.scopes...)(w, req)
+			require.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Package backup takes scheduled snapshots of the database and prunes old ones
+package backup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/database"
+)
+
+const (
+	// filePrefix and fileSuffix surround the timestamp in backup file names. Only files
+	// named like this are pruned.
+	filePrefix = "debrid-"
+	fileSuffix = ".db"
+
+	// timestampFormat sorts in time order
+	timestampFormat = "20060102-150405"
+)
+
+// Options configures a scheduler
+type Options struct {
+	Path     string
+	Interval time.Duration
+	Keep     int
+}
+
+// Scheduler snapshots the database into a folder on an interval, keeping the most
+// recent backups
+type Scheduler struct {
+	db       *database.DB
+	path     string
+	interval time.Duration
+	keep     int
+	logger   *slog.Logger
+}
+
+// NewScheduler creates a new backup scheduler
+func NewScheduler(db *database.DB, opts Options) *Scheduler {
+	return &Scheduler{
+		db:       db,
+		path:     filepath.Clean(opts.Path),
+		interval: opts.Interval,
+		keep:     opts.Keep,
+		logger:   slog.Default(),
+	}
+}
+
+// Start takes a backup every interval until the context is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	s.logger.Info("Starting scheduled backups", "path", s.path, "interval", s.interval, "keep", s.keep)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("Scheduled backups shutting down")
+			return
+		case <-ticker.C:
+			if _, err := s.Run(time.Now()); err != nil {
+				s.logger.Error("Scheduled backup failed", "error", err)
+			}
+		}
+	}
+}
+
+// Run takes a backup named after the given time, then removes the oldest backups beyond
+// the number kept. It returns the new backup's path.
+func (s *Scheduler) Run(now time.Time) (string, error) {
+	if err := os.MkdirAll(s.path, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create backup folder: %w", err)
+	}
+
+	path := filepath.Join(s.path, FileName(now))
+	if err := s.db.Backup(path); err != nil {
+		return "", err
+	}
+	s.logger.Info("Database backed up", "path", path)
+
+	backups, err := List(s.path)
+	if err != nil {
+		return path, err
+	}
+	for len(backups) > s.keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return path, fmt.Errorf("failed to remove old backup: %w", err)
+		}
+		s.logger.Info("Removed old backup", "path", backups[0])
+		backups = backups[1:]
+	}
+
+	return path, nil
+}
+
+// FileName returns the name of a backup taken at the given time
+func FileName(t time.Time) string {
+	return filePrefix + t.UTC().Format(timestampFormat) + fileSuffix
+}
+
+// List returns the paths of the backups in a folder, oldest first
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list backups: %w", err)
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, filePrefix) && strings.HasSuffix(name, fileSuffix) {
+			backups = append(backups, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
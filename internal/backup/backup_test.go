@@ -0,0 +1,56 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_Run(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.New(filepath.Join(dir, "debrid.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	backupDir := filepath.Join(dir, "backups")
+	scheduler := NewScheduler(db, Options{Path: backupDir, Interval: time.Hour, Keep: 2})
+
+	// Unrelated files in the folder are left alone
+	require.NoError(t, os.MkdirAll(backupDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(backupDir, "notes.txt"), []byte("keep"), 0o644))
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	var paths []string
+	for i := range 3 {
+		path, err := scheduler.Run(start.Add(time.Duration(i) * time.Hour))
+		require.NoError(t, err)
+		paths = append(paths, path)
+	}
+	require.Equal(t, filepath.Join(backupDir, "debrid-20260102-030405.db"), paths[0])
+
+	// Only the newest backups are kept
+	backups, err := List(backupDir)
+	require.NoError(t, err)
+	require.Equal(t, paths[1:], backups)
+	require.FileExists(t, filepath.Join(backupDir, "notes.txt"))
+
+	// A backup can be opened as a database
+	restored, err := database.New(backups[1])
+	require.NoError(t, err)
+	require.NoError(t, restored.Close())
+
+	// Taking two backups in the same second fails rather than overwriting
+	_, err = scheduler.Run(start.Add(2 * time.Hour))
+	require.Error(t, err)
+}
+
+func TestList_MissingFolder(t *testing.T) {
+	backups, err := List(filepath.Join(t.TempDir(), "missing"))
+	require.NoError(t, err)
+	require.Empty(t, backups)
+}
@@ -6,10 +6,13 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/metrics"
 	"debrid-downloader/pkg/models"
 )
 
@@ -25,11 +28,31 @@ var CleanupExtensions = []string{
 	".ass", ".ssa", ".smi", ".rt", ".sbv", ".dfxp", ".ttml", ".xml", ".log", ".diz", ".sfv",
 }
 
+// SubtitleExtensions defines the cleanup extensions kept by the subtitles profile
+var SubtitleExtensions = []string{
+	".srt", ".sub", ".idx", ".vtt", ".ass", ".ssa", ".smi", ".rt", ".sbv", ".dfxp", ".ttml",
+}
+
+// Extensions are the file extensions the cleanup profiles keep and remove
+type Extensions struct {
+	Video    []string
+	Cleanup  []string
+	Subtitle []string
+}
+
+// DefaultExtensions returns the built-in extension lists
+func DefaultExtensions() Extensions {
+	return Extensions{Video: VideoExtensions, Cleanup: CleanupExtensions, Subtitle: SubtitleExtensions}
+}
+
 // Service provides file cleanup services
 type Service struct {
 	db               *database.DB
 	logger           *slog.Logger
 	baseDownloadPath string
+
+	mu         sync.RWMutex
+	extensions Extensions
 }
 
 // NewService creates a new cleanup service
@@ -38,12 +61,53 @@ func NewService(db *database.DB, baseDownloadPath string) *Service {
 		db:               db,
 		logger:           slog.Default(),
 		baseDownloadPath: baseDownloadPath,
+		extensions:       DefaultExtensions(),
 	}
 }
 
+// SetExtensions replaces the extension lists. An empty list keeps the built-in one.
+func (s *Service) SetExtensions(extensions Extensions) {
+	defaults := DefaultExtensions()
+	if len(extensions.Video) == 0 {
+		extensions.Video = defaults.Video
+	}
+	if len(extensions.Cleanup) == 0 {
+		extensions.Cleanup = defaults.Cleanup
+	}
+	if len(extensions.Subtitle) == 0 {
+		extensions.Subtitle = defaults.Subtitle
+	}
+
+	s.mu.Lock()
+	s.extensions = extensions
+	s.mu.Unlock()
+}
+
+// Extensions returns the extension lists in use
+func (s *Service) Extensions() Extensions {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.extensions
+}
+
+// IsVideo reports whether a file has a video extension
+func (s *Service) IsVideo(filePath string) bool {
+	return slices.Contains(s.Extensions().Video, strings.ToLower(filepath.Ext(filePath)))
+}
+
 // CleanupExtractedFiles safely removes non-video files from extracted archives
 func (s *Service) CleanupExtractedFiles(downloadID int64) error {
-	s.logger.Info("Starting cleanup for extracted files", "download_id", downloadID)
+	return s.CleanupExtractedFilesFor(downloadID, models.CleanupVideo)
+}
+
+// CleanupExtractedFilesFor safely removes the extracted files a cleanup profile doesn't keep
+func (s *Service) CleanupExtractedFilesFor(downloadID int64, profile models.CleanupProfile) error {
+	if profile == models.CleanupNone {
+		s.logger.Info("Keeping all extracted files", "download_id", downloadID)
+		return nil
+	}
+
+	s.logger.Info("Starting cleanup for extracted files", "download_id", downloadID, "profile", profile)
 
 	// Get list of extracted files for this download
 	extractedFiles, err := s.db.GetExtractedFilesByDownloadID(downloadID)
@@ -69,7 +133,7 @@ func (s *Service) CleanupExtractedFiles(downloadID int64) error {
 		}
 
 		// Check if file should be cleaned up
-		if s.shouldCleanupFile(extractedFile.FilePath) {
+		if s.shouldCleanupFileFor(extractedFile.FilePath, profile) {
 			if err := s.deleteFile(extractedFile, downloadID); err != nil {
 				s.logger.Warn("Failed to delete file", "file", extractedFile.FilePath, "error", err)
 				errors = append(errors, fmt.Sprintf("%s: %s", extractedFile.FilePath, err.Error()))
@@ -117,20 +181,31 @@ func (s *Service) isPathSafe(filePath string) bool {
 
 // shouldCleanupFile determines if a file should be deleted based on its extension
 func (s *Service) shouldCleanupFile(filePath string) bool {
+	return s.shouldCleanupFileFor(filePath, models.CleanupVideo)
+}
+
+// shouldCleanupFileFor determines if a file should be deleted under a cleanup profile
+func (s *Service) shouldCleanupFileFor(filePath string, profile models.CleanupProfile) bool {
 	ext := strings.ToLower(filepath.Ext(filePath))
+	extensions := s.Extensions()
 
-	// First check if it's a video file (keep these)
-	for _, videoExt := range VideoExtensions {
-		if ext == videoExt {
-			return false // Keep video files
+	switch profile {
+	case models.CleanupNone:
+		return false
+	case models.CleanupSubtitles:
+		if slices.Contains(extensions.Subtitle, ext) {
+			return false // Keep subtitles
 		}
 	}
 
+	// First check if it's a video file (keep these)
+	if slices.Contains(extensions.Video, ext) {
+		return false // Keep video files
+	}
+
 	// Then check if it's in the cleanup list (delete these)
-	for _, cleanupExt := range CleanupExtensions {
-		if ext == cleanupExt {
-			return true // Delete these files
-		}
+	if slices.Contains(extensions.Cleanup, ext) {
+		return true // Delete these files
 	}
 
 	// For unknown extensions, be conservative and keep them
@@ -148,16 +223,18 @@ func (s *Service) deleteFile(extractedFile *models.ExtractedFile, downloadID int
 	}
 
 	// Log the deletion for audit purposes
+	size := s.getFileSize(extractedFile.FilePath)
 	s.logger.Info("Deleting non-video file",
 		"download_id", downloadID,
 		"file", extractedFile.FilePath,
-		"size", s.getFileSize(extractedFile.FilePath),
+		"size", size,
 		"created_at", extractedFile.CreatedAt)
 
 	// Delete the file
 	if err := os.Remove(extractedFile.FilePath); err != nil {
 		return fmt.Errorf("failed to delete file: %w", err)
 	}
+	metrics.CleanupFreedBytes.WithLabelValues(metrics.CleanupExtracted).Add(float64(size))
 
 	// Mark as deleted in database
 	return s.markFileDeleted(extractedFile.ID)
@@ -253,19 +330,10 @@ func (s *Service) GetCleanupStats(downloadID int64) (*CleanupStats, error) {
 		if s.shouldCleanupFile(extractedFile.FilePath) {
 			stats.CleanupFiles++
 			stats.CleanupSize += fileSize
+		} else if s.IsVideo(extractedFile.FilePath) {
+			stats.VideoFiles++
 		} else {
-			ext := strings.ToLower(filepath.Ext(extractedFile.FilePath))
-			isVideo := false
-			for _, videoExt := range VideoExtensions {
-				if ext == videoExt {
-					stats.VideoFiles++
-					isVideo = true
-					break
-				}
-			}
-			if !isVideo {
-				stats.UnknownFiles++
-			}
+			stats.UnknownFiles++
 		}
 	}
 
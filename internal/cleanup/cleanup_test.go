@@ -242,6 +242,29 @@ func TestService_ShouldCleanupFile(t *testing.T) {
 	}
 }
 
+func TestService_ShouldCleanupFileFor(t *testing.T) {
+	service := NewService(nil, "/downloads")
+
+	require.True(t, service.shouldCleanupFileFor("/path/to/movie.srt", models.CleanupVideo))
+	require.False(t, service.shouldCleanupFileFor("/path/to/movie.srt", models.CleanupSubtitles))
+	require.True(t, service.shouldCleanupFileFor("/path/to/movie.nfo", models.CleanupSubtitles))
+	require.False(t, service.shouldCleanupFileFor("/path/to/movie.nfo", models.CleanupNone))
+	require.False(t, service.shouldCleanupFileFor("/path/to/movie.mkv", models.CleanupSubtitles))
+}
+
+func TestService_SetExtensions(t *testing.T) {
+	service := NewService(nil, "/downloads")
+
+	// Lists that are set replace the built-in ones; empty lists keep them
+	service.SetExtensions(Extensions{Video: []string{".iso"}, Cleanup: []string{".url"}})
+	require.True(t, service.IsVideo("/path/to/disc.ISO"))
+	require.False(t, service.IsVideo("/path/to/movie.mkv"))
+	require.True(t, service.shouldCleanupFileFor("/path/to/link.url", models.CleanupVideo))
+	require.False(t, service.shouldCleanupFileFor("/path/to/movie.nfo", models.CleanupVideo))
+	require.False(t, service.shouldCleanupFileFor("/path/to/movie.srt", models.CleanupSubtitles))
+	require.Equal(t, SubtitleExtensions, service.Extensions().Subtitle)
+}
+
 func TestService_DeleteFile(t *testing.T) {
 	db, err := database.New(":memory:")
 	require.NoError(t, err)
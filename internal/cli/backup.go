@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"debrid-downloader/internal/backup"
+	"debrid-downloader/internal/database"
+)
+
+func (c *CLI) backup(ctx context.Context, args []string) error {
+	fs := c.flags("backup")
+	positional, release, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if len(positional) > 1 {
+		return fmt.Errorf("backup takes at most one file")
+	}
+	path := backup.FileName(time.Now())
+	if len(positional) == 1 {
+		path = positional[0]
+	}
+
+	if err := c.backend.Backup(ctx, path); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.Stdout, "Backed up to %s\n", path)
+	return nil
+}
+
+func (c *CLI) export(ctx context.Context, args []string) error {
+	fs := c.flags("export")
+	positional, release, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if len(positional) > 1 {
+		return fmt.Errorf("export takes at most one file")
+	}
+
+	export, err := c.backend.Export(ctx)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode export: %w", err)
+	}
+	encoded = append(encoded, '\n')
+
+	if len(positional) == 0 || positional[0] == "-" {
+		_, err := c.Stdout.Write(encoded)
+		return err
+	}
+	if err := os.WriteFile(positional[0], encoded, 0o644); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	fmt.Fprintf(c.Stderr, "Exported to %s\n", positional[0])
+	return nil
+}
+
+func (c *CLI) importData(ctx context.Context, args []string) error {
+	fs := c.flags("import")
+	conflict := fs.String("conflict", string(database.ConflictSkip), "what to do with records that already exist: skip, replace or fail")
+	positional, release, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if len(positional) != 1 {
+		return fmt.Errorf("import takes one file, or - for stdin")
+	}
+	mode := database.ConflictMode(*conflict)
+	if !mode.Valid() {
+		return fmt.Errorf("--conflict must be skip, replace or fail")
+	}
+
+	var input io.Reader = c.Stdin
+	if positional[0] != "-" {
+		file, err := os.Open(positional[0])
+		if err != nil {
+			return fmt.Errorf("failed to open export: %w", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	var export database.Export
+	if err := json.NewDecoder(input).Decode(&export); err != nil {
+		return fmt.Errorf("failed to read export: %w", err)
+	}
+
+	result, err := c.backend.Import(ctx, &export, mode)
+	if err != nil {
+		return err
+	}
+	if c.opts.JSON {
+		return c.printJSON(result)
+	}
+
+	counted := maps.Clone(result.Imported)
+	maps.Copy(counted, result.Skipped)
+	tables := slices.Sorted(maps.Keys(counted))
+
+	w := tabwriter.NewWriter(c.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tIMPORTED\tSKIPPED")
+	for _, table := range tables {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", table, result.Imported[table], result.Skipped[table])
+	}
+	return w.Flush()
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/internal/web/handlers"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCLI_BackupExportImport(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.New(filepath.Join(dir, "debrid.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	source, stdout, _ := newTestCLI(NewOffline(db, alldebrid.New("test-key"), dir))
+	ctx := context.Background()
+
+	createDownload(t, db, "movie.mkv", models.StatusCompleted)
+	require.NoError(t, db.CreateCategory(models.NewCategory("movies")))
+
+	backupPath := filepath.Join(dir, "snapshot.db")
+	require.NoError(t, source.Run(ctx, []string{"backup", backupPath}))
+	require.Contains(t, stdout.String(), "Backed up to "+backupPath)
+	require.Error(t, source.Run(ctx, []string{"backup", backupPath}), "an existing file is not overwritten")
+
+	exportPath := filepath.Join(dir, "export.json")
+	require.NoError(t, source.Run(ctx, []string{"export", exportPath}))
+	export, err := os.ReadFile(exportPath)
+	require.NoError(t, err)
+	require.Contains(t, string(export), "movie.mkv")
+
+	// Import into another instance through its API, reading the export from stdin
+	target, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer target.Close()
+
+	h := handlers.NewHandlers(target, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(target, "/downloads"))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/backup", h.APIBackup)
+	mux.HandleFunc("GET /api/v1/export", h.APIExport)
+	mux.HandleFunc("POST /api/v1/import", h.APIImport)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	remote, stdout, _ := newTestCLI(NewClient(server.URL, ""))
+	remote.Stdin = bytes.NewReader(export)
+	require.NoError(t, remote.Run(ctx, []string{"import", "-"}))
+	require.Regexp(t, `categories\s+1\s+0`, stdout.String())
+	require.Regexp(t, `downloads\s+1\s+0`, stdout.String())
+
+	downloads, err := target.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 1)
+
+	// Conflicts are skipped by default and can be made to fail
+	stdout.Reset()
+	require.NoError(t, remote.Run(ctx, []string{"import", exportPath}))
+	require.Regexp(t, `downloads\s+0\s+1`, stdout.String())
+	require.ErrorContains(t, remote.Run(ctx, []string{"import", "--conflict", "fail", exportPath}), "import conflicts")
+	require.EqualError(t, remote.Run(ctx, []string{"import", "--conflict", "merge", exportPath}), "--conflict must be skip, replace or fail")
+
+	stdout.Reset()
+	require.NoError(t, remote.Run(ctx, []string{"export"}))
+	require.Contains(t, stdout.String(), `"name": "movies"`)
+
+	remoteBackup := filepath.Join(dir, "remote.db")
+	require.NoError(t, remote.Run(ctx, []string{"backup", remoteBackup}))
+	content, err := os.ReadFile(remoteBackup)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(content), "SQLite format 3"))
+}
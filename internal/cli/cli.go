@@ -0,0 +1,457 @@
+// Package cli implements the debrid-downloader subcommands for managing downloads from a
+// shell, either through a running server's API or directly on its database
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/config"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/pkg/models"
+)
+
+// Usage describes the subcommands
+const Usage = `Usage: debrid-downloader <command> [flags] [arguments]
+
+Commands:
+  add <url...>         Queue links (reads them from stdin when none are given)
+  list                 List downloads
+  status <id>          Show a download
+  pause <id...>        Pause downloads
+  resume <id...>       Resume paused downloads
+  retry <id...>        Retry failed downloads
+  rm <id...>           Remove downloads from the history, keeping finished files
+  groups               List download groups
+  watch [id]           Show live progress until interrupted, or until the download finishes
+  backup [file]        Save a snapshot of the database (default debrid-<time>.db here)
+  export [file]        Write downloads, groups, directory mappings and settings as JSON
+                       (default stdout)
+  import <file|->      Add records from an export; --conflict skip|replace|fail chooses
+                       what happens to records that already exist (default skip)
+  hash-password        Print a bcrypt hash for AUTH_PASSWORD_HASH
+
+Running without a command starts the server.
+
+Flags for every command:
+  --server URL   Server to talk to (DEBRID_URL, default http://localhost:$SERVER_PORT)
+  --token TOKEN  API token (DEBRID_TOKEN)
+  --offline      Open the database directly instead of calling the server
+  --json         Print JSON instead of tables
+`
+
+// commands maps each subcommand to its implementation
+var commands = map[string]func(*CLI, context.Context, []string) error{
+	"add":    (*CLI).add,
+	"list":   (*CLI).list,
+	"status": (*CLI).status,
+	"pause":  (*CLI).pause,
+	"resume": (*CLI).resume,
+	"retry":  (*CLI).retry,
+	"rm":     (*CLI).remove,
+	"groups": (*CLI).groups,
+	"watch":  (*CLI).watch,
+	"backup": (*CLI).backup,
+	"export": (*CLI).export,
+	"import": (*CLI).importData,
+}
+
+// IsCommand reports whether name is a subcommand handled by Run
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok || name == "help" || name == "-h" || name == "--help"
+}
+
+// Options are the flags shared by every command
+type Options struct {
+	Server  string
+	Token   string
+	Offline bool
+	JSON    bool
+}
+
+// CLI runs subcommands, writing their output to Stdout
+type CLI struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	Stdin  io.Reader
+
+	// Connect opens the backend the options select. It returns a function that releases it.
+	Connect func(opts Options) (Backend, func(), error)
+
+	opts    Options
+	backend Backend
+}
+
+// New creates a CLI using the process's standard streams
+func New() *CLI {
+	return &CLI{
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+		Stdin:   os.Stdin,
+		Connect: Connect,
+	}
+}
+
+// Connect opens a client for the server, or the database when the options ask for
+// offline access. Offline access reads the server's configuration from the environment.
+func Connect(opts Options) (Backend, func(), error) {
+	if !opts.Offline {
+		return NewClient(opts.Server, opts.Token), func() {}, nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	db, err := database.New(cfg.DatabasePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	offline := NewOffline(db, alldebrid.New(cfg.AllDebridAPIKey), cfg.BaseDownloadsPath)
+	return offline, func() { db.Close() }, nil
+}
+
+// Run runs the subcommand named by the first argument
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(c.Stdout, Usage)
+		return nil
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprint(c.Stderr, Usage)
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+
+	return command(c, ctx, args[1:])
+}
+
+// flags creates a flag set for a command with the shared flags registered
+func (c *CLI) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	fs.StringVar(&c.opts.Server, "server", defaultServer(), "server URL")
+	fs.StringVar(&c.opts.Token, "token", os.Getenv("DEBRID_TOKEN"), "API token")
+	fs.BoolVar(&c.opts.Offline, "offline", false, "open the database directly")
+	fs.BoolVar(&c.opts.JSON, "json", false, "print JSON")
+	return fs
+}
+
+// defaultServer returns the server URL from DEBRID_URL, or the local server on SERVER_PORT
+func defaultServer() string {
+	if server := os.Getenv("DEBRID_URL"); server != "" {
+		return server
+	}
+	port := os.Getenv("SERVER_PORT")
+	if port == "" {
+		port = "8080"
+	}
+	return "http://localhost:" + port
+}
+
+// parse parses a command's flags, which may appear before, between or after its
+// arguments, and connects to the backend. The returned function releases it.
+func (c *CLI) parse(fs *flag.FlagSet, args []string) ([]string, func(), error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	backend, release, err := c.Connect(c.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	c.backend = backend
+	return positional, release, nil
+}
+
+func (c *CLI) add(ctx context.Context, args []string) error {
+	fs := c.flags("add")
+	var req AddRequest
+	fs.StringVar(&req.Directory, "dir", "", "directory, relative to the downloads folder")
+	fs.StringVar(&req.Category, "category", "", "category name")
+	urls, release, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if len(urls) == 0 {
+		scanner := bufio.NewScanner(c.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" {
+				urls = append(urls, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read links: %w", err)
+		}
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("no links given")
+	}
+	req.URLs = urls
+
+	result, err := c.backend.Add(ctx, req)
+	if err != nil {
+		return err
+	}
+	if c.opts.JSON {
+		return c.printJSON(result)
+	}
+
+	for _, download := range result.Downloads {
+		fmt.Fprintf(c.Stdout, "Queued %d %s -> %s\n", download.ID, download.Filename, download.Directory)
+	}
+	for _, failure := range result.Failed {
+		fmt.Fprintf(c.Stderr, "Failed %s: %s\n", failure.URL, failure.Error)
+	}
+	if len(result.Downloads) == 0 {
+		return fmt.Errorf("no links were queued")
+	}
+	return nil
+}
+
+func (c *CLI) list(ctx context.Context, args []string) error {
+	fs := c.flags("list")
+	var opts ListOptions
+	var statuses string
+	fs.StringVar(&opts.Search, "search", "", "only downloads whose filename or link contains this")
+	fs.StringVar(&statuses, "status", "", "comma-separated statuses to show")
+	fs.IntVar(&opts.Limit, "limit", 50, "most downloads to show")
+	_, release, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Downloads are only listed for the statuses asked for, so default to all of them
+	opts.Statuses = []string{
+		string(models.StatusDownloading), string(models.StatusPending), string(models.StatusPaused),
+		string(models.StatusFailed), string(models.StatusCompleted),
+	}
+	if statuses != "" {
+		opts.Statuses = strings.Split(statuses, ",")
+	}
+
+	downloads, err := c.backend.List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if c.opts.JSON {
+		if downloads == nil {
+			downloads = []*models.Download{}
+		}
+		return c.printJSON(downloads)
+	}
+
+	c.printDownloads(downloads)
+	return nil
+}
+
+func (c *CLI) status(ctx context.Context, args []string) error {
+	fs := c.flags("status")
+	ids, release, err := c.parseIDs(fs, args)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if len(ids) != 1 {
+		return fmt.Errorf("status takes one download ID")
+	}
+
+	download, err := c.backend.Get(ctx, ids[0])
+	if err != nil {
+		return err
+	}
+	if c.opts.JSON {
+		return c.printJSON(download)
+	}
+
+	w := tabwriter.NewWriter(c.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ID:\t%d\n", download.ID)
+	fmt.Fprintf(w, "File:\t%s\n", download.Filename)
+	fmt.Fprintf(w, "Directory:\t%s\n", download.Directory)
+	fmt.Fprintf(w, "Status:\t%s\n", download.Status)
+	fmt.Fprintf(w, "Progress:\t%s\n", progressLine(download))
+	if download.Category != "" {
+		fmt.Fprintf(w, "Category:\t%s\n", download.Category)
+	}
+	if download.GroupID != "" {
+		fmt.Fprintf(w, "Group:\t%s\n", download.GroupID)
+	}
+	if download.ErrorMessage != "" {
+		fmt.Fprintf(w, "Error:\t%s\n", download.ErrorMessage)
+	}
+	fmt.Fprintf(w, "Link:\t%s\n", download.OriginalURL)
+	fmt.Fprintf(w, "Added:\t%s\n", download.CreatedAt.Local().Format(time.DateTime))
+	if download.CompletedAt != nil {
+		fmt.Fprintf(w, "Completed:\t%s\n", download.CompletedAt.Local().Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+func (c *CLI) pause(ctx context.Context, args []string) error {
+	return c.eachDownload("pause", "Paused", args, func(id int64) (*models.Download, error) {
+		return c.backend.Pause(ctx, id)
+	})
+}
+
+func (c *CLI) resume(ctx context.Context, args []string) error {
+	return c.eachDownload("resume", "Resumed", args, func(id int64) (*models.Download, error) {
+		return c.backend.Resume(ctx, id)
+	})
+}
+
+func (c *CLI) retry(ctx context.Context, args []string) error {
+	return c.eachDownload("retry", "Retrying", args, func(id int64) (*models.Download, error) {
+		return c.backend.Retry(ctx, id)
+	})
+}
+
+func (c *CLI) remove(ctx context.Context, args []string) error {
+	return c.eachDownload("rm", "Removed", args, func(id int64) (*models.Download, error) {
+		return nil, c.backend.Remove(ctx, id)
+	})
+}
+
+// eachDownload runs an action on every download ID given, reporting each result. It
+// carries on past failures and returns an error if any failed.
+func (c *CLI) eachDownload(name, done string, args []string, action func(id int64) (*models.Download, error)) error {
+	fs := c.flags(name)
+	ids, release, err := c.parseIDs(fs, args)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if len(ids) == 0 {
+		return fmt.Errorf("%s takes one or more download IDs", name)
+	}
+
+	failed := 0
+	for _, id := range ids {
+		download, err := action(id)
+		if err != nil {
+			fmt.Fprintf(c.Stderr, "Download %d: %v\n", id, err)
+			failed++
+			continue
+		}
+		if c.opts.JSON && download != nil {
+			if err := c.printJSON(download); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(c.Stdout, "%s %d\n", done, id)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d downloads failed", failed, len(ids))
+	}
+	return nil
+}
+
+func (c *CLI) groups(ctx context.Context, args []string) error {
+	fs := c.flags("groups")
+	limit := fs.Int("limit", 20, "most groups to show")
+	_, release, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	groups, err := c.backend.Groups(ctx, *limit)
+	if err != nil {
+		return err
+	}
+	if c.opts.JSON {
+		if groups == nil {
+			groups = []*models.DownloadGroup{}
+		}
+		return c.printJSON(groups)
+	}
+
+	w := tabwriter.NewWriter(c.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tSTATUS\tDONE\tCREATED\tERROR")
+	for _, group := range groups {
+		fmt.Fprintf(w, "%s\t%s\t%d/%d\t%s\t%s\n",
+			group.ID, group.Status, group.CompletedDownloads, group.TotalDownloads,
+			group.CreatedAt.Local().Format(time.DateTime), group.ProcessingError)
+	}
+	return w.Flush()
+}
+
+// parseIDs parses a command's flags and its download ID arguments
+func (c *CLI) parseIDs(fs *flag.FlagSet, args []string) ([]int64, func(), error) {
+	positional, release, err := c.parse(fs, args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ids := make([]int64, 0, len(positional))
+	for _, arg := range positional {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			release()
+			return nil, nil, fmt.Errorf("invalid download ID: %s", arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids, release, nil
+}
+
+// printDownloads prints downloads as a table
+func (c *CLI) printDownloads(downloads []*models.Download) {
+	w := tabwriter.NewWriter(c.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tPROGRESS\tSIZE\tFILE")
+	for _, download := range downloads {
+		fmt.Fprintf(w, "%d\t%s\t%.1f%%\t%s\t%s\n",
+			download.ID, download.Status, download.Progress, formatSize(download.FileSize), download.Filename)
+	}
+	w.Flush()
+}
+
+// printJSON prints a value as indented JSON
+func (c *CLI) printJSON(value any) error {
+	encoder := json.NewEncoder(c.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// formatSize returns a byte count in binary units
+func formatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
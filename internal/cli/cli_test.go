@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/alldebrid/mocks"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/internal/web/handlers"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// newTestCLI returns a CLI using the backend, and its captured output
+func newTestCLI(backend Backend) (*CLI, *bytes.Buffer, *bytes.Buffer) {
+	var stdout, stderr bytes.Buffer
+	return &CLI{
+		Stdout: &stdout,
+		Stderr: &stderr,
+		Stdin:  strings.NewReader(""),
+		Connect: func(Options) (Backend, func(), error) {
+			return backend, func() {}, nil
+		},
+	}, &stdout, &stderr
+}
+
+func createDownload(t *testing.T, db *database.DB, filename string, status models.DownloadStatus) *models.Download {
+	t.Helper()
+	download := &models.Download{
+		OriginalURL: "https://example.com/" + filename,
+		Filename:    filename,
+		Directory:   "/downloads",
+		Status:      status,
+		FileSize:    2048,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(download))
+	return download
+}
+
+func TestCLI_Offline(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	basePath := t.TempDir()
+	mockClient := mocks.NewMockAllDebridClient(ctrl)
+	cli, stdout, stderr := newTestCLI(NewOffline(db, mockClient, basePath))
+	ctx := context.Background()
+
+	pending := createDownload(t, db, "pending.mkv", models.StatusPending)
+	failed := createDownload(t, db, "failed.zip", models.StatusFailed)
+
+	require.NoError(t, cli.Run(ctx, []string{"list"}))
+	require.Contains(t, stdout.String(), "pending.mkv")
+	require.Contains(t, stdout.String(), "2.0 KB")
+
+	stdout.Reset()
+	require.NoError(t, cli.Run(ctx, []string{"list", "--status", "failed", "--json"}))
+	var listed []*models.Download
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &listed))
+	require.Len(t, listed, 1)
+	require.Equal(t, failed.ID, listed[0].ID)
+
+	// Actions report each download and carry on past failures
+	stdout.Reset()
+	err = cli.Run(ctx, []string{"pause", fmt.Sprint(pending.ID), fmt.Sprint(failed.ID)})
+	require.EqualError(t, err, "1 of 2 downloads failed")
+	require.Contains(t, stdout.String(), fmt.Sprintf("Paused %d", pending.ID))
+	require.Contains(t, stderr.String(), "download is not pending or in progress")
+
+	download, err := db.GetDownload(pending.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusPaused, download.Status)
+
+	// Retrying starts over, however often the download was retried
+	failed.RetryCount = 6
+	require.NoError(t, db.UpdateDownload(failed))
+	require.NoError(t, cli.Run(ctx, []string{"resume", fmt.Sprint(pending.ID)}))
+	require.NoError(t, cli.Run(ctx, []string{"retry", fmt.Sprint(failed.ID)}))
+	download, err = db.GetDownload(failed.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusPending, download.Status)
+	require.Zero(t, download.RetryCount)
+
+	stdout.Reset()
+	require.NoError(t, cli.Run(ctx, []string{"status", fmt.Sprint(pending.ID)}))
+	require.Contains(t, stdout.String(), "pending.mkv")
+	require.Contains(t, stdout.String(), "Status:")
+
+	require.NoError(t, cli.Run(ctx, []string{"rm", fmt.Sprint(pending.ID)}))
+	_, err = db.GetDownload(pending.ID)
+	require.Error(t, err)
+
+	require.EqualError(t, cli.Run(ctx, []string{"status", "abc"}), "invalid download ID: abc")
+
+	// Flags may come after the links; offline additions stay pending for the server
+	mockClient.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/a.rar").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl.example.com/a.rar", Filename: "a.rar"}, nil)
+	mockClient.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/b.rar").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl.example.com/b.rar", Filename: "b.rar"}, nil)
+
+	stdout.Reset()
+	require.NoError(t, cli.Run(ctx, []string{"add", "https://example.com/a.rar", "--dir", "movies", "https://example.com/b.rar"}))
+	require.Contains(t, stdout.String(), "a.rar -> "+filepath.Join(basePath, "movies"))
+	require.Contains(t, stdout.String(), "b.rar")
+
+	stdout.Reset()
+	require.NoError(t, cli.Run(ctx, []string{"groups"}))
+	require.Contains(t, stdout.String(), "0/2")
+
+	require.EqualError(t, cli.Run(ctx, []string{"add", "https://example.com/c.rar"}), "--dir or --category is required when offline")
+	require.EqualError(t, cli.Run(ctx, []string{"add"}), "no links given")
+	require.EqualError(t, cli.Run(ctx, []string{"frobnicate"}), "unknown command: frobnicate")
+}
+
+func TestCLI_Client(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	h := handlers.NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/downloads", h.APIListDownloads)
+	mux.HandleFunc("GET /api/v1/downloads/{id}", h.APIGetDownload)
+	mux.HandleFunc("POST /api/v1/downloads/{id}/retry", h.APIRetryDownload)
+	mux.HandleFunc("POST /api/v1/downloads/{id}/pause", h.APIPauseDownload)
+	mux.HandleFunc("DELETE /api/v1/downloads/{id}", h.APIDeleteDownload)
+	mux.HandleFunc("GET /api/v1/groups", h.APIListGroups)
+
+	var token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("Authorization")
+		mux.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	cli, stdout, stderr := newTestCLI(NewClient(server.URL+"/", "secret"))
+	ctx := context.Background()
+
+	failed := createDownload(t, db, "failed.zip", models.StatusFailed)
+
+	require.NoError(t, cli.Run(ctx, []string{"list", "--search", "failed"}))
+	require.Contains(t, stdout.String(), "failed.zip")
+	require.Equal(t, "Bearer secret", token)
+
+	stdout.Reset()
+	require.NoError(t, cli.Run(ctx, []string{"retry", "--json", fmt.Sprint(failed.ID)}))
+	require.Contains(t, stdout.String(), `"status": "pending"`)
+
+	// The server's error messages are passed on
+	require.Error(t, cli.Run(ctx, []string{"pause", fmt.Sprint(failed.ID)}))
+	require.Contains(t, stderr.String(), "Download is not in progress")
+	require.EqualError(t, cli.Run(ctx, []string{"status", "999"}), "Download not found")
+
+	stdout.Reset()
+	require.NoError(t, cli.Run(ctx, []string{"groups", "--json"}))
+	require.JSONEq(t, `[]`, stdout.String())
+
+	require.NoError(t, cli.Run(ctx, []string{"rm", fmt.Sprint(failed.ID)}))
+	_, err = db.GetDownload(failed.ID)
+	require.Error(t, err)
+}
+
+func TestCLI_Watch(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	cli, stdout, _ := newTestCLI(NewOffline(db, alldebrid.New("test-key"), t.TempDir()))
+
+	download := createDownload(t, db, "done.mkv", models.StatusCompleted)
+	download.Progress = 100
+	download.DownloadedBytes = download.FileSize
+	require.NoError(t, db.UpdateDownload(download))
+
+	// Following a finished download returns straight away
+	require.NoError(t, cli.Run(context.Background(), []string{"watch", fmt.Sprint(download.ID)}))
+	require.Contains(t, stdout.String(), "[####################]")
+	require.Contains(t, stdout.String(), "done.mkv")
+	require.NotContains(t, stdout.String(), clearScreen)
+
+	// Watching everything runs until cancelled
+	createDownload(t, db, "active.mkv", models.StatusDownloading)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stdout.Reset()
+	require.NoError(t, cli.Run(ctx, []string{"watch", "--interval", "10ms"}))
+	require.Contains(t, stdout.String(), "1 active")
+	require.Contains(t, stdout.String(), "active.mkv")
+}
@@ -0,0 +1,254 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/submit"
+	"debrid-downloader/pkg/models"
+)
+
+// Backend is what the commands need from a debrid-downloader instance. Client talks to
+// a running server over its JSON API; Offline opens its database directly.
+type Backend interface {
+	Add(ctx context.Context, req AddRequest) (*AddResult, error)
+	List(ctx context.Context, opts ListOptions) ([]*models.Download, error)
+	Get(ctx context.Context, id int64) (*models.Download, error)
+	Pause(ctx context.Context, id int64) (*models.Download, error)
+	Resume(ctx context.Context, id int64) (*models.Download, error)
+	Retry(ctx context.Context, id int64) (*models.Download, error)
+	Remove(ctx context.Context, id int64) error
+	Groups(ctx context.Context, limit int) ([]*models.DownloadGroup, error)
+	Backup(ctx context.Context, path string) error
+	Export(ctx context.Context) (*database.Export, error)
+	Import(ctx context.Context, export *database.Export, mode database.ConflictMode) (*database.ImportResult, error)
+}
+
+// AddRequest describes links to queue
+type AddRequest struct {
+	URLs      []string `json:"urls"`
+	Directory string   `json:"directory,omitempty"`
+	Category  string   `json:"category,omitempty"`
+}
+
+// AddResult lists the downloads created by Add
+type AddResult struct {
+	GroupID   string             `json:"group_id,omitempty"`
+	Downloads []*models.Download `json:"downloads"`
+	Failed    []submit.Failure   `json:"failed,omitempty"`
+}
+
+// ListOptions filters the downloads returned by List
+type ListOptions struct {
+	Search   string
+	Statuses []string
+	Limit    int
+}
+
+// Client calls a running server's JSON API, authenticating with an API token
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the server at baseURL. The token may be empty when the
+// server has authentication disabled.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Add queues links on the server
+func (c *Client) Add(ctx context.Context, req AddRequest) (*AddResult, error) {
+	var result AddResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/downloads", req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// List returns downloads, newest first
+func (c *Client) List(ctx context.Context, opts ListOptions) ([]*models.Download, error) {
+	query := url.Values{}
+	if opts.Search != "" {
+		query.Set("search", opts.Search)
+	}
+	for _, status := range opts.Statuses {
+		query.Add("status", status)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	var response struct {
+		Downloads []*models.Download `json:"downloads"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/v1/downloads?"+query.Encode(), nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Downloads, nil
+}
+
+// Get returns a download
+func (c *Client) Get(ctx context.Context, id int64) (*models.Download, error) {
+	return c.downloadAction(ctx, http.MethodGet, id, "")
+}
+
+// Pause pauses a download in progress
+func (c *Client) Pause(ctx context.Context, id int64) (*models.Download, error) {
+	return c.downloadAction(ctx, http.MethodPost, id, "/pause")
+}
+
+// Resume queues a paused download again
+func (c *Client) Resume(ctx context.Context, id int64) (*models.Download, error) {
+	return c.downloadAction(ctx, http.MethodPost, id, "/resume")
+}
+
+// Retry queues a failed download again
+func (c *Client) Retry(ctx context.Context, id int64) (*models.Download, error) {
+	return c.downloadAction(ctx, http.MethodPost, id, "/retry")
+}
+
+// Remove deletes a download from the history, keeping any finished file
+func (c *Client) Remove(ctx context.Context, id int64) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/api/v1/downloads/%d", id), nil, nil)
+}
+
+// Groups returns the most recent download groups
+func (c *Client) Groups(ctx context.Context, limit int) ([]*models.DownloadGroup, error) {
+	var response struct {
+		Groups []*models.DownloadGroup `json:"groups"`
+	}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/groups?limit=%d", limit), nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Groups, nil
+}
+
+// Backup saves a snapshot of the server's database to a new file
+func (c *Client) Backup(ctx context.Context, path string) error {
+	resp, err := c.send(ctx, http.MethodGet, "/api/v1/backup", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return writeNewFile(path, resp.Body)
+}
+
+// Export returns the server's downloads, groups, directory mappings and settings
+func (c *Client) Export(ctx context.Context) (*database.Export, error) {
+	var export database.Export
+	if err := c.do(ctx, http.MethodGet, "/api/v1/export", nil, &export); err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// Import adds exported records on the server
+func (c *Client) Import(ctx context.Context, export *database.Export, mode database.ConflictMode) (*database.ImportResult, error) {
+	var result database.ImportResult
+	if err := c.do(ctx, http.MethodPost, "/api/v1/import?conflict="+url.QueryEscape(string(mode)), export, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// downloadAction calls an endpoint under a download and returns the download it responds with
+func (c *Client) downloadAction(ctx context.Context, method string, id int64, action string) (*models.Download, error) {
+	var download models.Download
+	if err := c.do(ctx, method, fmt.Sprintf("/api/v1/downloads/%d%s", id, action), nil, &download); err != nil {
+		return nil, err
+	}
+	return &download, nil
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// send sends a request with an optional JSON body. Error responses are returned as
+// errors carrying the server's message; otherwise the caller closes the response body.
+func (c *Client) send(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach server: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return nil, fmt.Errorf("%s", apiErr.Error)
+		}
+		return nil, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	return resp, nil
+}
+
+// writeNewFile copies r into a file that must not already exist, removing it on failure
+func writeNewFile(path string, r io.Reader) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		os.Remove(path)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return file.Close()
+}
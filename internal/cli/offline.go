@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/folder"
+	"debrid-downloader/internal/submit"
+	"debrid-downloader/pkg/models"
+)
+
+// Offline works on the database directly, for when the server isn't running. Changes
+// are picked up when the server next starts: pending downloads are queued then.
+type Offline struct {
+	db        *database.DB
+	submitter *submit.Service
+	folders   *folder.Service
+}
+
+// NewOffline creates an offline backend. The client is used to unrestrict added links.
+func NewOffline(db *database.DB, client alldebrid.AllDebridClient, basePath string) *Offline {
+	return &Offline{
+		db:        db,
+		submitter: submit.NewService(db, client, nopQueue{}),
+		folders:   folder.NewService(basePath),
+	}
+}
+
+// nopQueue leaves submitted downloads pending for the server to queue when it starts
+type nopQueue struct{}
+
+func (nopQueue) QueueDownload(int64) {}
+
+// Add unrestricts links and records them as pending downloads
+func (o *Offline) Add(ctx context.Context, req AddRequest) (*AddResult, error) {
+	directory := req.Directory
+	if directory == "" && req.Category != "" {
+		category, err := o.db.GetCategoryByName(req.Category)
+		if err != nil {
+			return nil, fmt.Errorf("unknown category: %s", req.Category)
+		}
+		directory = category.DirectoryName()
+	}
+	if directory == "" {
+		return nil, fmt.Errorf("--dir or --category is required when offline")
+	}
+
+	if o.folders.Contains(directory) {
+		directory = strings.TrimPrefix(filepath.Clean(directory), o.folders.BasePath)
+	}
+	fullPath, err := o.folders.ValidatePath(directory)
+	if err != nil {
+		return nil, fmt.Errorf("invalid directory: %s", directory)
+	}
+
+	result, err := o.submitter.Submit(ctx, submit.Request{URLs: req.URLs, Directory: fullPath, Category: req.Category})
+	if err != nil {
+		var submitErr *submit.Error
+		if errors.As(err, &submitErr) {
+			return nil, fmt.Errorf("%s", submitErr.Message)
+		}
+		return nil, err
+	}
+
+	added := &AddResult{GroupID: result.GroupID, Failed: result.Failed}
+	for _, item := range result.Items {
+		added.Downloads = append(added.Downloads, item.Download)
+	}
+	return added, nil
+}
+
+// List returns downloads, newest first
+func (o *Offline) List(_ context.Context, opts ListOptions) ([]*models.Download, error) {
+	return o.db.SearchDownloadsByOwner(database.AllOwners, opts.Search, opts.Statuses, "desc", opts.Limit, 0)
+}
+
+// Get returns a download
+func (o *Offline) Get(_ context.Context, id int64) (*models.Download, error) {
+	return o.db.GetDownload(id)
+}
+
+// Pause marks a pending or interrupted download as paused so it isn't started
+func (o *Offline) Pause(_ context.Context, id int64) (*models.Download, error) {
+	download, err := o.db.GetDownload(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if download.Status != models.StatusPending && download.Status != models.StatusDownloading {
+		return nil, fmt.Errorf("download is not pending or in progress")
+	}
+
+	now := time.Now()
+	download.Status = models.StatusPaused
+	download.PausedAt = &now
+	download.UpdatedAt = now
+	return download, o.db.UpdateDownload(download)
+}
+
+// Resume marks a paused download as pending again
+func (o *Offline) Resume(_ context.Context, id int64) (*models.Download, error) {
+	download, err := o.db.GetDownload(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if download.Status != models.StatusPaused {
+		return nil, fmt.Errorf("download is not paused")
+	}
+
+	if download.PausedAt != nil {
+		download.TotalPausedTime += int64(time.Since(*download.PausedAt).Seconds())
+		download.PausedAt = nil
+	}
+	download.Status = models.StatusPending
+	download.UpdatedAt = time.Now()
+	return download, o.db.UpdateDownload(download)
+}
+
+// Retry marks a failed download as pending again with its retry count reset
+func (o *Offline) Retry(_ context.Context, id int64) (*models.Download, error) {
+	download, err := o.db.GetDownload(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if download.Status != models.StatusFailed {
+		return nil, fmt.Errorf("download is not in failed state")
+	}
+
+	download.Status = models.StatusPending
+	download.ErrorMessage = ""
+	download.RetryCount = 0
+	download.UpdatedAt = time.Now()
+	return download, o.db.UpdateDownload(download)
+}
+
+// Remove deletes a download from the history and its temporary file, keeping any finished file
+func (o *Offline) Remove(_ context.Context, id int64) error {
+	download, err := o.db.GetDownload(id)
+	if err != nil {
+		return err
+	}
+
+	if err := o.db.DeleteDownload(id); err != nil {
+		return err
+	}
+
+	tempPath := filepath.Join(download.Directory, fmt.Sprintf("%s.%d.tmp", download.Filename, download.ID))
+	if err := os.Remove(tempPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove temporary file: %w", err)
+	}
+	return nil
+}
+
+// Groups returns the most recent download groups
+func (o *Offline) Groups(_ context.Context, limit int) ([]*models.DownloadGroup, error) {
+	return o.db.ListDownloadGroups(database.AllOwners, limit)
+}
+
+// Backup saves a snapshot of the database to a new file
+func (o *Offline) Backup(_ context.Context, path string) error {
+	return o.db.Backup(path)
+}
+
+// Export returns the downloads, groups, directory mappings and settings
+func (o *Offline) Export(_ context.Context) (*database.Export, error) {
+	return o.db.Export()
+}
+
+// Import adds exported records to the database. Records whose owner has no user with the
+// same name here are left unowned.
+func (o *Offline) Import(_ context.Context, export *database.Export, mode database.ConflictMode) (*database.ImportResult, error) {
+	return o.db.Import(export, mode, 0)
+}
@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"debrid-downloader/pkg/models"
+)
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// progressBarWidth is how many characters the progress bar takes
+const progressBarWidth = 20
+
+// activeStatuses are the downloads watch shows when not following a single download
+var activeStatuses = []string{
+	string(models.StatusDownloading),
+	string(models.StatusPending),
+	string(models.StatusPaused),
+}
+
+// watch redraws progress every interval. With a download ID it follows that download
+// and returns once it completes or fails; otherwise it shows the active downloads until
+// interrupted.
+func (c *CLI) watch(ctx context.Context, args []string) error {
+	fs := c.flags("watch")
+	interval := fs.Duration("interval", time.Second, "how often to refresh")
+	ids, release, err := c.parseIDs(fs, args)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if len(ids) > 1 {
+		return fmt.Errorf("watch takes at most one download ID")
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+
+	terminal := isTerminal(c.Stdout)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		var frame strings.Builder
+		if len(ids) == 1 {
+			download, err := c.backend.Get(ctx, ids[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&frame, "%s\n", watchLine(download))
+			if download.Status == models.StatusCompleted || download.Status == models.StatusFailed {
+				c.drawFrame(frame.String(), terminal)
+				if download.Status == models.StatusFailed {
+					return fmt.Errorf("download failed: %s", download.ErrorMessage)
+				}
+				return nil
+			}
+		} else {
+			downloads, err := c.backend.List(ctx, ListOptions{Statuses: activeStatuses, Limit: 50})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&frame, "%s  %d active\n", time.Now().Format(time.TimeOnly), len(downloads))
+			for _, download := range downloads {
+				fmt.Fprintf(&frame, "%s\n", watchLine(download))
+			}
+		}
+		c.drawFrame(frame.String(), terminal)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// drawFrame replaces the previous frame on a terminal, or appends it otherwise
+func (c *CLI) drawFrame(frame string, terminal bool) {
+	if terminal {
+		frame = clearScreen + frame
+	}
+	fmt.Fprint(c.Stdout, frame)
+}
+
+// watchLine describes a download on one line with a progress bar
+func watchLine(download *models.Download) string {
+	filled := int(download.Progress / 100 * progressBarWidth)
+	filled = max(0, min(filled, progressBarWidth))
+	bar := strings.Repeat("#", filled) + strings.Repeat(".", progressBarWidth-filled)
+	return fmt.Sprintf("%6d [%s] %-11s %s  %s", download.ID, bar, download.Status, progressLine(download), download.Filename)
+}
+
+// progressLine describes how far a download has got, with its speed while downloading
+func progressLine(download *models.Download) string {
+	line := fmt.Sprintf("%5.1f%%  %s / %s", download.Progress,
+		formatSize(download.DownloadedBytes), formatSize(download.FileSize))
+	if download.Status == models.StatusDownloading {
+		line += fmt.Sprintf("  %s/s", formatSize(int64(download.DownloadSpeed)))
+	}
+	return line
+}
+
+// isTerminal reports whether w is a terminal rather than a file or pipe
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -1,14 +1,18 @@
-// Package config handles application configuration from environment variables
+// Package config handles application configuration from environment variables and an
+// optional YAML config file
 package config
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v10"
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // Config represents the application configuration
@@ -18,17 +22,92 @@ type Config struct {
 	LogLevel          string `env:"LOG_LEVEL" envDefault:"info"`
 	DatabasePath      string `env:"DATABASE_PATH" envDefault:"debrid.db"`
 	BaseDownloadsPath string `env:"BASE_DOWNLOADS_PATH" envDefault:"/downloads"`
+
+	// ConfigFile is the YAML file settings are read from, under the environment
+	ConfigFile string `env:"CONFIG_FILE"`
+
+	// Downloads (the retry, progress, timeout and retention settings reload without a restart)
+	MaxRetries       int           `env:"MAX_RETRIES" envDefault:"5"`
+	RetryBackoff     time.Duration `env:"RETRY_BACKOFF" envDefault:"1s"`
+	RetryMaxDelay    time.Duration `env:"RETRY_MAX_DELAY" envDefault:"5m"`
+	RetryJitter      float64       `env:"RETRY_JITTER" envDefault:"0.2"`
+	RateLimitWait    time.Duration `env:"RATE_LIMIT_WAIT" envDefault:"6h"`
+	ProgressInterval time.Duration `env:"PROGRESS_INTERVAL" envDefault:"500ms"`
+	DownloadTimeout  time.Duration `env:"DOWNLOAD_TIMEOUT" envDefault:"1h"`
+	AllDebridTimeout time.Duration `env:"ALLDEBRID_TIMEOUT" envDefault:"30s"`
+	HistoryRetention time.Duration `env:"HISTORY_RETENTION" envDefault:"1440h"`
+
+	// History cleanup (failed downloads follow HISTORY_RETENTION unless set; no archive
+	// is written unless a path is set)
+	FailedHistoryRetention time.Duration `env:"FAILED_HISTORY_RETENTION"`
+	HistoryMaxRows         int           `env:"HISTORY_MAX_ROWS" envDefault:"0"`
+	HistoryArchivePath     string        `env:"HISTORY_ARCHIVE_PATH"`
+
+	// Extensions the cleanup profiles keep and remove (empty keeps the built-in lists)
+	VideoExtensions    []string `env:"VIDEO_EXTENSIONS" envSeparator:","`
+	CleanupExtensions  []string `env:"CLEANUP_EXTENSIONS" envSeparator:","`
+	SubtitleExtensions []string `env:"SUBTITLE_EXTENSIONS" envSeparator:","`
+
+	// Authentication (disabled when neither a password hash nor a proxy header is set)
+	AuthUsername       string        `env:"AUTH_USERNAME" envDefault:"admin"`
+	AuthPasswordHash   string        `env:"AUTH_PASSWORD_HASH"`
+	AuthProxyHeader    string        `env:"AUTH_PROXY_HEADER"`
+	AuthTrustedProxies []string      `env:"AUTH_TRUSTED_PROXIES" envSeparator:","`
+	SessionTTL         time.Duration `env:"SESSION_TTL" envDefault:"168h"`
+	SecureCookies      bool          `env:"SECURE_COOKIES" envDefault:"false"`
+
+	// Watch folder (disabled when no path is set)
+	WatchFolderPath  string        `env:"WATCH_FOLDER_PATH"`
+	WatchInterval    time.Duration `env:"WATCH_INTERVAL" envDefault:"10s"`
+	WatchFolderOwner string        `env:"WATCH_FOLDER_OWNER"`
+	DLCDecryptURL    string        `env:"DLC_DECRYPT_URL" envDefault:"https://dcrypt.it/decrypt/upload"`
+
+	// Post-processing hooks run scripts from this directory (disabled when no path is set)
+	HooksPath string `env:"HOOKS_PATH"`
+
+	// Automatic database backups (disabled when no path is set)
+	BackupPath     string        `env:"BACKUP_PATH"`
+	BackupInterval time.Duration `env:"BACKUP_INTERVAL" envDefault:"24h"`
+	BackupKeep     int           `env:"BACKUP_KEEP" envDefault:"7"`
+
+	// Notifications (each provider is disabled until its endpoint is set)
+	Ntfy     NtfyConfig     `envPrefix:"NTFY_"`
+	Gotify   GotifyConfig   `envPrefix:"GOTIFY_"`
+	Discord  DiscordConfig  `envPrefix:"DISCORD_"`
+	Telegram TelegramConfig `envPrefix:"TELEGRAM_"`
+	SMTP     SMTPConfig     `envPrefix:"SMTP_"`
 }
 
-// Load loads configuration from environment variables and .env file
+// Load loads configuration from environment variables, the .env file and the config file
+// named by CONFIG_FILE. Environment variables take precedence over the config file.
 func Load() (*Config, error) {
 	// Load .env file if it exists (ignore error if file doesn't exist)
 	_ = godotenv.Load()
 
+	return LoadFile(os.Getenv("CONFIG_FILE"))
+}
+
+// LoadFile loads configuration from environment variables layered over a config file. An
+// empty path reads the environment only.
+func LoadFile(path string) (*Config, error) {
+	environment := env.ToMap(os.Environ())
+	if path != "" {
+		settings, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range settings {
+			if _, set := environment[key]; !set {
+				environment[key] = value
+			}
+		}
+	}
+
 	var cfg Config
-	if err := env.Parse(&cfg); err != nil {
+	if err := env.ParseWithOptions(&cfg, env.Options{Environment: environment}); err != nil {
 		return nil, fmt.Errorf("failed to parse environment variables: %w", err)
 	}
+	cfg.ConfigFile = path
 
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
@@ -78,5 +157,174 @@ func (c *Config) Validate() error {
 	// Update the config with cleaned path
 	c.BaseDownloadsPath = cleanPath
 
+	if err := c.validateAuth(); err != nil {
+		return err
+	}
+
+	if err := c.validateWatchFolder(); err != nil {
+		return err
+	}
+
+	if err := c.validateNotifications(); err != nil {
+		return err
+	}
+
+	if c.HooksPath != "" {
+		cleanPath := filepath.Clean(c.HooksPath)
+		if !filepath.IsAbs(cleanPath) {
+			return fmt.Errorf("HOOKS_PATH must be an absolute path, got: %s", c.HooksPath)
+		}
+		c.HooksPath = cleanPath
+	}
+
+	if err := c.validateBackups(); err != nil {
+		return err
+	}
+
+	if err := c.validateDownloads(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateDownloads validates the download tuning and cleanup settings
+func (c *Config) validateDownloads() error {
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("MAX_RETRIES cannot be negative")
+	}
+	if c.RetryJitter < 0 || c.RetryJitter > 1 {
+		return fmt.Errorf("RETRY_JITTER must be between 0 and 1")
+	}
+	if c.RateLimitWait < 0 {
+		return fmt.Errorf("RATE_LIMIT_WAIT cannot be negative")
+	}
+	if c.HistoryMaxRows < 0 {
+		return fmt.Errorf("HISTORY_MAX_ROWS cannot be negative")
+	}
+	if c.FailedHistoryRetention < 0 {
+		return fmt.Errorf("FAILED_HISTORY_RETENTION cannot be negative")
+	}
+
+	// Unset durations, as in a Config built in code, take their defaults
+	durations := []struct {
+		name     string
+		value    *time.Duration
+		fallback time.Duration
+	}{
+		{"RETRY_BACKOFF", &c.RetryBackoff, time.Second},
+		{"RETRY_MAX_DELAY", &c.RetryMaxDelay, 5 * time.Minute},
+		{"PROGRESS_INTERVAL", &c.ProgressInterval, 500 * time.Millisecond},
+		{"DOWNLOAD_TIMEOUT", &c.DownloadTimeout, time.Hour},
+		{"ALLDEBRID_TIMEOUT", &c.AllDebridTimeout, 30 * time.Second},
+		{"HISTORY_RETENTION", &c.HistoryRetention, 60 * 24 * time.Hour},
+	}
+	for _, d := range durations {
+		if *d.value < 0 {
+			return fmt.Errorf("%s cannot be negative", d.name)
+		}
+		if *d.value == 0 {
+			*d.value = d.fallback
+		}
+	}
+
+	if c.FailedHistoryRetention == 0 {
+		c.FailedHistoryRetention = c.HistoryRetention
+	}
+
+	c.VideoExtensions = NormalizeExtensions(c.VideoExtensions)
+	c.CleanupExtensions = NormalizeExtensions(c.CleanupExtensions)
+	c.SubtitleExtensions = NormalizeExtensions(c.SubtitleExtensions)
+	return nil
+}
+
+// NormalizeExtensions lowercases extensions, gives each a leading dot and drops empty ones
+func NormalizeExtensions(extensions []string) []string {
+	var normalized []string
+	for _, ext := range extensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		normalized = append(normalized, ext)
+	}
+	return normalized
+}
+
+// validateBackups validates the automatic backup settings
+func (c *Config) validateBackups() error {
+	if c.BackupPath == "" {
+		return nil
+	}
+
+	cleanPath := filepath.Clean(c.BackupPath)
+	if !filepath.IsAbs(cleanPath) {
+		return fmt.Errorf("BACKUP_PATH must be an absolute path, got: %s", c.BackupPath)
+	}
+	c.BackupPath = cleanPath
+
+	if c.BackupInterval <= 0 {
+		return fmt.Errorf("BACKUP_INTERVAL must be positive")
+	}
+
+	if c.BackupKeep < 1 {
+		return fmt.Errorf("BACKUP_KEEP must be at least 1")
+	}
+
+	return nil
+}
+
+// validateWatchFolder validates the watch folder settings
+func (c *Config) validateWatchFolder() error {
+	if c.WatchFolderPath == "" {
+		return nil
+	}
+
+	cleanPath := filepath.Clean(c.WatchFolderPath)
+	if !filepath.IsAbs(cleanPath) {
+		return fmt.Errorf("WATCH_FOLDER_PATH must be an absolute path, got: %s", c.WatchFolderPath)
+	}
+	c.WatchFolderPath = cleanPath
+
+	if c.WatchInterval <= 0 {
+		return fmt.Errorf("WATCH_INTERVAL must be positive")
+	}
+
+	return nil
+}
+
+// validateAuth validates the authentication settings
+func (c *Config) validateAuth() error {
+	if c.AuthPasswordHash != "" {
+		if c.AuthUsername == "" {
+			return fmt.Errorf("AUTH_USERNAME cannot be empty when AUTH_PASSWORD_HASH is set")
+		}
+		if _, err := bcrypt.Cost([]byte(c.AuthPasswordHash)); err != nil {
+			return fmt.Errorf("AUTH_PASSWORD_HASH must be a bcrypt hash: %w", err)
+		}
+	}
+
+	if c.AuthProxyHeader != "" && len(c.AuthTrustedProxies) == 0 {
+		return fmt.Errorf("AUTH_TRUSTED_PROXIES is required when AUTH_PROXY_HEADER is set")
+	}
+
+	for _, proxy := range c.AuthTrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if strings.Contains(proxy, "/") {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("invalid AUTH_TRUSTED_PROXIES entry %q: %w", proxy, err)
+			}
+		} else if net.ParseIP(proxy) == nil {
+			return fmt.Errorf("invalid AUTH_TRUSTED_PROXIES entry %q", proxy)
+		}
+	}
+
+	if c.SessionTTL < 0 {
+		return fmt.Errorf("SESSION_TTL cannot be negative")
+	}
+
 	return nil
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -137,6 +138,17 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "relative hooks path",
+			config: Config{
+				AllDebridAPIKey:   "test-key",
+				ServerPort:        "8080",
+				LogLevel:          "info",
+				BaseDownloadsPath: "/tmp",
+				HooksPath:         "hooks",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -150,3 +162,345 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateAuth(t *testing.T) {
+	// bcrypt hash of "secret"
+	validHash := "$2a$10$8qRMFrDLMPTbxRR1MfVWOeUflFtgOa8IzYPB2AlcLV1acDtgCXgWO"
+
+	base := func() Config {
+		return Config{
+			AllDebridAPIKey:   "test-key",
+			ServerPort:        "8080",
+			LogLevel:          "info",
+			BaseDownloadsPath: "/tmp",
+			AuthUsername:      "admin",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{
+			name:    "auth disabled",
+			modify:  func(c *Config) {},
+			wantErr: false,
+		},
+		{
+			name: "valid password hash",
+			modify: func(c *Config) {
+				c.AuthPasswordHash = validHash
+			},
+			wantErr: false,
+		},
+		{
+			name: "password hash is not bcrypt",
+			modify: func(c *Config) {
+				c.AuthPasswordHash = "plaintext"
+			},
+			wantErr: true,
+		},
+		{
+			name: "password hash without username",
+			modify: func(c *Config) {
+				c.AuthPasswordHash = validHash
+				c.AuthUsername = ""
+			},
+			wantErr: true,
+		},
+		{
+			name: "proxy header without trusted proxies",
+			modify: func(c *Config) {
+				c.AuthProxyHeader = "Remote-User"
+			},
+			wantErr: true,
+		},
+		{
+			name: "proxy header with trusted proxies",
+			modify: func(c *Config) {
+				c.AuthProxyHeader = "Remote-User"
+				c.AuthTrustedProxies = []string{"10.0.0.0/8", "192.168.1.5"}
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid trusted proxy",
+			modify: func(c *Config) {
+				c.AuthProxyHeader = "Remote-User"
+				c.AuthTrustedProxies = []string{"not-an-ip"}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateWatchFolder(t *testing.T) {
+	base := func() Config {
+		return Config{
+			AllDebridAPIKey:   "test-key",
+			ServerPort:        "8080",
+			LogLevel:          "info",
+			BaseDownloadsPath: "/tmp",
+			WatchInterval:     10 * time.Second,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{
+			name:    "watch folder disabled",
+			modify:  func(c *Config) {},
+			wantErr: false,
+		},
+		{
+			name: "absolute watch folder",
+			modify: func(c *Config) {
+				c.WatchFolderPath = "/watch/"
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative watch folder",
+			modify: func(c *Config) {
+				c.WatchFolderPath = "watch"
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero interval",
+			modify: func(c *Config) {
+				c.WatchFolderPath = "/watch"
+				c.WatchInterval = 0
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if cfg.WatchFolderPath != "" {
+				require.Equal(t, "/watch", cfg.WatchFolderPath)
+			}
+		})
+	}
+}
+
+func TestValidateBackups(t *testing.T) {
+	base := func() Config {
+		return Config{
+			AllDebridAPIKey:   "test-key",
+			ServerPort:        "8080",
+			LogLevel:          "info",
+			BaseDownloadsPath: "/tmp",
+			BackupInterval:    24 * time.Hour,
+			BackupKeep:        7,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{
+			name:    "backups disabled",
+			modify:  func(c *Config) {},
+			wantErr: false,
+		},
+		{
+			name: "absolute backup path",
+			modify: func(c *Config) {
+				c.BackupPath = "/backups/"
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative backup path",
+			modify: func(c *Config) {
+				c.BackupPath = "backups"
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero interval",
+			modify: func(c *Config) {
+				c.BackupPath = "/backups"
+				c.BackupInterval = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "keeping no backups",
+			modify: func(c *Config) {
+				c.BackupPath = "/backups"
+				c.BackupKeep = 0
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if cfg.BackupPath != "" {
+				require.Equal(t, "/backups", cfg.BackupPath)
+			}
+		})
+	}
+}
+
+func TestValidateNotifications(t *testing.T) {
+	base := func() Config {
+		return Config{
+			AllDebridAPIKey:   "test-key",
+			ServerPort:        "8080",
+			LogLevel:          "info",
+			BaseDownloadsPath: "/tmp",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr bool
+	}{
+		{
+			name:    "notifications disabled",
+			modify:  func(c *Config) {},
+			wantErr: false,
+		},
+		{
+			name: "ntfy with filter and template",
+			modify: func(c *Config) {
+				c.Ntfy.URL = "https://ntfy.sh/downloads"
+				c.Ntfy.Events = []string{"download.failed"}
+				c.Ntfy.Template = "{{.Name}} failed"
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown event",
+			modify: func(c *Config) {
+				c.Discord.WebhookURL = "https://discord.com/api/webhooks/1/abc"
+				c.Discord.Events = []string{"download.exploded"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "broken template",
+			modify: func(c *Config) {
+				c.Ntfy.URL = "https://ntfy.sh/downloads"
+				c.Ntfy.Template = "{{.Name"
+			},
+			wantErr: true,
+		},
+		{
+			name: "gotify without token",
+			modify: func(c *Config) {
+				c.Gotify.URL = "https://gotify.local"
+			},
+			wantErr: true,
+		},
+		{
+			name: "telegram without chat",
+			modify: func(c *Config) {
+				c.Telegram.BotToken = "123:abc"
+			},
+			wantErr: true,
+		},
+		{
+			name: "smtp without recipients",
+			modify: func(c *Config) {
+				c.SMTP.Host = "mail.local"
+				c.SMTP.Port = 587
+				c.SMTP.From = "downloads@example.com"
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateDownloads(t *testing.T) {
+	cfg := Config{
+		AllDebridAPIKey:   "test-key",
+		LogLevel:          "info",
+		BaseDownloadsPath: "/tmp",
+		VideoExtensions:   []string{"MKV", " .iso", ""},
+	}
+
+	// Unset durations take their defaults and extensions are normalized
+	require.NoError(t, cfg.Validate())
+	require.Equal(t, time.Second, cfg.RetryBackoff)
+	require.Equal(t, 5*time.Minute, cfg.RetryMaxDelay)
+	require.Equal(t, 500*time.Millisecond, cfg.ProgressInterval)
+	require.Equal(t, 60*24*time.Hour, cfg.HistoryRetention)
+	require.Equal(t, 60*24*time.Hour, cfg.FailedHistoryRetention)
+	require.Equal(t, []string{".mkv", ".iso"}, cfg.VideoExtensions)
+
+	cfg.MaxRetries = -1
+	require.EqualError(t, cfg.Validate(), "MAX_RETRIES cannot be negative")
+
+	cfg.MaxRetries = 0
+	cfg.RetryJitter = 1.5
+	require.EqualError(t, cfg.Validate(), "RETRY_JITTER must be between 0 and 1")
+
+	cfg.RetryJitter = 0.2
+	cfg.RateLimitWait = -time.Hour
+	require.EqualError(t, cfg.Validate(), "RATE_LIMIT_WAIT cannot be negative")
+
+	cfg.RateLimitWait = 0
+	cfg.HistoryMaxRows = -1
+	require.EqualError(t, cfg.Validate(), "HISTORY_MAX_ROWS cannot be negative")
+
+	cfg.HistoryMaxRows = 0
+	cfg.DownloadTimeout = -time.Second
+	require.EqualError(t, cfg.Validate(), "DOWNLOAD_TIMEOUT cannot be negative")
+}
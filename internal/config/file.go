@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/caarlos0/env/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// readFile reads a YAML config file into environment variable names and values. Keys are
+// the environment variable names in any case, and nested sections join their keys with
+// an underscore, so notifications can be written as ntfy: {url: ...}. Lists become
+// comma-separated values.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var document map[string]any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	settings := map[string]string{}
+	if err := flatten("", document, settings); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	known, err := settingNames()
+	if err != nil {
+		return nil, err
+	}
+	var unknown []string
+	for key := range settings {
+		if !known[key] {
+			unknown = append(unknown, strings.ToLower(key))
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("invalid config file %s: unknown settings: %s", path, strings.Join(unknown, ", "))
+	}
+
+	return settings, nil
+}
+
+// flatten adds a YAML section's values to settings under upper-cased, prefixed keys
+func flatten(prefix string, section map[string]any, settings map[string]string) error {
+	for key, value := range section {
+		name := prefix + strings.ToUpper(key)
+		switch value := value.(type) {
+		case map[string]any:
+			if err := flatten(name+"_", value, settings); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				if _, nested := item.(map[string]any); nested {
+					return fmt.Errorf("%s must be a list of values", strings.ToLower(name))
+				}
+				items = append(items, fmt.Sprint(item))
+			}
+			settings[name] = strings.Join(items, ",")
+		case nil:
+			settings[name] = ""
+		default:
+			settings[name] = fmt.Sprint(value)
+		}
+	}
+	return nil
+}
+
+// settingNames returns the environment variable name of every setting
+func settingNames() (map[string]bool, error) {
+	params, err := env.GetFieldParams(&Config{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list settings: %w", err)
+	}
+
+	names := make(map[string]bool, len(params))
+	for _, param := range params {
+		names[param.Key] = true
+	}
+	return names, nil
+}
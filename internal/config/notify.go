@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"debrid-downloader/internal/events"
+)
+
+// NotifierConfig holds the event filter and message template every notification provider has
+type NotifierConfig struct {
+	Events   []string `env:"EVENTS" envSeparator:"," envDefault:"download.failed,group.completed,group.failed"`
+	Template string   `env:"TEMPLATE"` // Go text/template for the message body, empty for the default
+}
+
+// NtfyConfig configures ntfy notifications
+type NtfyConfig struct {
+	URL   string `env:"URL"` // Full topic URL, e.g. https://ntfy.sh/downloads
+	Token string `env:"TOKEN"`
+	NotifierConfig
+}
+
+// GotifyConfig configures Gotify notifications
+type GotifyConfig struct {
+	URL   string `env:"URL"`
+	Token string `env:"TOKEN"` // Application token
+	NotifierConfig
+}
+
+// DiscordConfig configures Discord webhook notifications
+type DiscordConfig struct {
+	WebhookURL string `env:"WEBHOOK_URL"`
+	NotifierConfig
+}
+
+// TelegramConfig configures Telegram Bot API notifications
+type TelegramConfig struct {
+	APIURL   string `env:"API_URL" envDefault:"https://api.telegram.org"`
+	BotToken string `env:"BOT_TOKEN"`
+	ChatID   string `env:"CHAT_ID"`
+	NotifierConfig
+}
+
+// SMTPConfig configures email notifications
+type SMTPConfig struct {
+	Host     string   `env:"HOST"`
+	Port     int      `env:"PORT" envDefault:"587"`
+	Username string   `env:"USERNAME"`
+	Password string   `env:"PASSWORD"`
+	From     string   `env:"FROM"`
+	To       []string `env:"TO" envSeparator:","`
+	NotifierConfig
+}
+
+// Enabled reports whether ntfy notifications are configured
+func (c NtfyConfig) Enabled() bool { return c.URL != "" }
+
+// Enabled reports whether Gotify notifications are configured
+func (c GotifyConfig) Enabled() bool { return c.URL != "" }
+
+// Enabled reports whether Discord notifications are configured
+func (c DiscordConfig) Enabled() bool { return c.WebhookURL != "" }
+
+// Enabled reports whether Telegram notifications are configured
+func (c TelegramConfig) Enabled() bool { return c.BotToken != "" }
+
+// Enabled reports whether email notifications are configured
+func (c SMTPConfig) Enabled() bool { return c.Host != "" }
+
+// validateNotifications validates the settings of every enabled notification provider
+func (c *Config) validateNotifications() error {
+	if c.Gotify.Enabled() && c.Gotify.Token == "" {
+		return fmt.Errorf("GOTIFY_TOKEN is required when GOTIFY_URL is set")
+	}
+	if c.Telegram.Enabled() && c.Telegram.ChatID == "" {
+		return fmt.Errorf("TELEGRAM_CHAT_ID is required when TELEGRAM_BOT_TOKEN is set")
+	}
+	if c.SMTP.Enabled() {
+		if c.SMTP.From == "" || len(c.SMTP.To) == 0 {
+			return fmt.Errorf("SMTP_FROM and SMTP_TO are required when SMTP_HOST is set")
+		}
+		if c.SMTP.Port <= 0 || c.SMTP.Port > 65535 {
+			return fmt.Errorf("SMTP_PORT must be between 1 and 65535")
+		}
+	}
+
+	notifiers := []struct {
+		prefix  string
+		enabled bool
+		config  NotifierConfig
+	}{
+		{"NTFY_", c.Ntfy.Enabled(), c.Ntfy.NotifierConfig},
+		{"GOTIFY_", c.Gotify.Enabled(), c.Gotify.NotifierConfig},
+		{"DISCORD_", c.Discord.Enabled(), c.Discord.NotifierConfig},
+		{"TELEGRAM_", c.Telegram.Enabled(), c.Telegram.NotifierConfig},
+		{"SMTP_", c.SMTP.Enabled(), c.SMTP.NotifierConfig},
+	}
+	for _, notifier := range notifiers {
+		if !notifier.enabled {
+			continue
+		}
+		for _, event := range notifier.config.Events {
+			if !events.Type(strings.TrimSpace(event)).Valid() {
+				return fmt.Errorf("invalid %sEVENTS entry %q", notifier.prefix, event)
+			}
+		}
+		if notifier.config.Template != "" {
+			if _, err := template.New("").Parse(notifier.config.Template); err != nil {
+				return fmt.Errorf("invalid %sTEMPLATE: %w", notifier.prefix, err)
+			}
+		}
+	}
+
+	return nil
+}
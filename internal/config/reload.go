@@ -0,0 +1,185 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// reloadable names the fields that take effect without a restart
+var reloadable = map[string]bool{
+	"LogLevel":               true,
+	"MaxRetries":             true,
+	"RetryBackoff":           true,
+	"RetryMaxDelay":          true,
+	"RetryJitter":            true,
+	"RateLimitWait":          true,
+	"ProgressInterval":       true,
+	"DownloadTimeout":        true,
+	"HistoryRetention":       true,
+	"FailedHistoryRetention": true,
+	"HistoryMaxRows":         true,
+	"VideoExtensions":        true,
+	"CleanupExtensions":      true,
+	"SubtitleExtensions":     true,
+}
+
+// Merge returns a copy of the configuration with the reloadable settings taken from next,
+// and the names of the other settings that differ, which only take effect after a restart
+func (c *Config) Merge(next *Config) (*Config, []string) {
+	merged := *c
+	current, updated, target := reflect.ValueOf(c).Elem(), reflect.ValueOf(next).Elem(), reflect.ValueOf(&merged).Elem()
+
+	var restart []string
+	for i := range current.NumField() {
+		field := current.Type().Field(i)
+		if reflect.DeepEqual(current.Field(i).Interface(), updated.Field(i).Interface()) {
+			continue
+		}
+		if reloadable[field.Name] {
+			target.Field(i).Set(updated.Field(i))
+			continue
+		}
+		restart = append(restart, settingName(field))
+	}
+
+	return &merged, restart
+}
+
+// settingName returns the environment variable a field is read from, or its prefix for a
+// section such as NTFY_*
+func settingName(field reflect.StructField) string {
+	if prefix := field.Tag.Get("envPrefix"); prefix != "" {
+		return prefix + "*"
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("env"), ",")
+	return name
+}
+
+// Reloader reloads the configuration on SIGHUP, or when the config file changes, and
+// passes it to its subscribers. Settings that need a restart keep their startup values.
+type Reloader struct {
+	logger   *slog.Logger
+	interval time.Duration
+	reloadMu sync.Mutex // Serialises reloads
+
+	mu          sync.Mutex
+	current     *Config
+	modTime     time.Time
+	subscribers []func(*Config)
+}
+
+// NewReloader creates a reloader starting from the loaded configuration
+func NewReloader(cfg *Config) *Reloader {
+	r := &Reloader{
+		logger:   slog.Default(),
+		interval: 5 * time.Second,
+		current:  cfg,
+	}
+	r.modTime = r.fileModTime()
+	return r
+}
+
+// Current returns the configuration in effect
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Subscribe calls fn with the configuration now and after every reload
+func (r *Reloader) Subscribe(fn func(*Config)) {
+	r.mu.Lock()
+	r.subscribers = append(r.subscribers, fn)
+	current := r.current
+	r.mu.Unlock()
+
+	fn(current)
+}
+
+// Reload reads the configuration again and applies its reloadable settings. An invalid
+// configuration is rejected and the current one stays in effect. Subscribers are called
+// after the new configuration is current, so they can read it with Current.
+func (r *Reloader) Reload() error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	r.mu.Lock()
+	r.modTime = r.fileModTime()
+	next, err := LoadFile(r.current.ConfigFile)
+	if err != nil {
+		r.mu.Unlock()
+		return err
+	}
+
+	merged, restart := r.current.Merge(next)
+	r.current = merged
+	subscribers := slices.Clone(r.subscribers)
+	r.mu.Unlock()
+
+	if len(restart) > 0 {
+		r.logger.Warn("Configuration changes need a restart to take effect", "settings", strings.Join(restart, ","))
+	}
+	for _, fn := range subscribers {
+		fn(merged)
+	}
+	r.logger.Info("Configuration reloaded", "file", merged.ConfigFile)
+	return nil
+}
+
+// Start reloads on SIGHUP and, with a config file, whenever the file changes, until the
+// context is cancelled
+func (r *Reloader) Start(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			r.reload()
+		case <-ticker.C:
+			if r.changed() {
+				r.reload()
+			}
+		}
+	}
+}
+
+// reload reloads, logging failures
+func (r *Reloader) reload() {
+	if err := r.Reload(); err != nil {
+		r.logger.Error("Failed to reload configuration, keeping the current one", "error", err)
+	}
+}
+
+// changed reports whether the config file was modified since it was last read
+func (r *Reloader) changed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current.ConfigFile != "" && !r.fileModTime().Equal(r.modTime)
+}
+
+// fileModTime returns the config file's modification time, or zero without one
+func (r *Reloader) fileModTime() time.Time {
+	if r.current.ConfigFile == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(r.current.ConfigFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
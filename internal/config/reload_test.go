@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestLoadFile(t *testing.T) {
+	os.Clearenv()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, path, `
+alldebrid_api_key: file-key
+log_level: debug
+max_retries: 2
+retry_backoff: 10s
+video_extensions: [mkv, .mp4]
+ntfy:
+  url: https://ntfy.sh/downloads
+  events: [download.failed]
+`)
+
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "file-key", cfg.AllDebridAPIKey)
+	require.Equal(t, 2, cfg.MaxRetries)
+	require.Equal(t, 10*time.Second, cfg.RetryBackoff)
+	require.Equal(t, []string{".mkv", ".mp4"}, cfg.VideoExtensions)
+	require.Equal(t, "https://ntfy.sh/downloads", cfg.Ntfy.URL)
+	require.Equal(t, []string{"download.failed"}, cfg.Ntfy.Events)
+	require.Equal(t, "8080", cfg.ServerPort, "unset settings keep their defaults")
+	require.Equal(t, path, cfg.ConfigFile)
+
+	// Environment variables take precedence over the file
+	t.Setenv("LOG_LEVEL", "warn")
+	cfg, err = LoadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "warn", cfg.LogLevel)
+
+	// The file is validated like the environment
+	writeConfigFile(t, path, "alldebrid_api_key: file-key\nmax_retries: -3\n")
+	_, err = LoadFile(path)
+	require.ErrorContains(t, err, "MAX_RETRIES cannot be negative")
+
+	writeConfigFile(t, path, "alldebrid_api_key: file-key\nmax_retrys: 3\nntfy:\n  colour: red\n")
+	_, err = LoadFile(path)
+	require.ErrorContains(t, err, "unknown settings: max_retrys, ntfy_colour")
+
+	writeConfigFile(t, path, "alldebrid_api_key: [unclosed\n")
+	_, err = LoadFile(path)
+	require.ErrorContains(t, err, "failed to parse config file")
+
+	_, err = LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	require.ErrorContains(t, err, "failed to read config file")
+}
+
+func TestConfig_Merge(t *testing.T) {
+	current := &Config{LogLevel: "info", MaxRetries: 5, ServerPort: "8080"}
+	next := &Config{LogLevel: "debug", MaxRetries: 1, ServerPort: "9090"}
+	next.Ntfy.URL = "https://ntfy.sh/downloads"
+
+	merged, restart := current.Merge(next)
+	require.Equal(t, "debug", merged.LogLevel)
+	require.Equal(t, 1, merged.MaxRetries)
+	require.Equal(t, "8080", merged.ServerPort)
+	require.Empty(t, merged.Ntfy.URL)
+	require.Equal(t, []string{"SERVER_PORT", "NTFY_*"}, restart)
+	require.Equal(t, "info", current.LogLevel, "the current configuration is not changed")
+}
+
+func TestReloader(t *testing.T) {
+	os.Clearenv()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigFile(t, path, "alldebrid_api_key: file-key\nmax_retries: 2\n")
+
+	cfg, err := LoadFile(path)
+	require.NoError(t, err)
+	reloader := NewReloader(cfg)
+
+	var applied []int
+	reloader.Subscribe(func(cfg *Config) {
+		applied = append(applied, cfg.MaxRetries)
+	})
+	require.Equal(t, []int{2}, applied, "subscribers get the current configuration straight away")
+	require.False(t, reloader.changed())
+
+	writeConfigFile(t, path, "alldebrid_api_key: file-key\nmax_retries: 4\nserver_port: 9090\n")
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	require.True(t, reloader.changed())
+
+	require.NoError(t, reloader.Reload())
+	require.Equal(t, []int{2, 4}, applied)
+	require.Equal(t, 4, reloader.Current().MaxRetries)
+	require.Equal(t, "8080", reloader.Current().ServerPort, "structural settings need a restart")
+	require.False(t, reloader.changed())
+
+	// An invalid file is rejected and the current configuration stays
+	writeConfigFile(t, path, "alldebrid_api_key: file-key\nmax_retries: -1\n")
+	require.Error(t, reloader.Reload())
+	require.Equal(t, []int{2, 4}, applied)
+	require.Equal(t, 4, reloader.Current().MaxRetries)
+}
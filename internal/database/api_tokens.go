@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"debrid-downloader/pkg/models"
+)
+
+// CreateAPIToken stores a new API token
+func (db *DB) CreateAPIToken(token *models.APIToken) error {
+	query := `
+	INSERT INTO api_tokens (
+		name, prefix, token_hash, scope, created_at, last_used_at, revoked_at, owner_id
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := db.conn.Exec(query,
+		token.Name, token.Prefix, token.TokenHash, token.Scope,
+		token.CreatedAt, token.LastUsedAt, token.RevokedAt, token.OwnerID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create API token: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	token.ID = id
+	return nil
+}
+
+// GetAPITokenByHash retrieves an API token by the hash of its value
+func (db *DB) GetAPITokenByHash(tokenHash string) (*models.APIToken, error) {
+	query := `
+	SELECT id, name, prefix, token_hash, scope, created_at, last_used_at, revoked_at, owner_id
+	FROM api_tokens WHERE token_hash = ?
+	`
+
+	var token models.APIToken
+	err := db.conn.QueryRow(query, tokenHash).Scan(
+		&token.ID, &token.Name, &token.Prefix, &token.TokenHash,
+		&token.Scope, &token.CreatedAt, &token.LastUsedAt, &token.RevokedAt, &token.OwnerID,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("API token not found")
+		}
+		return nil, fmt.Errorf("failed to get API token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// ListAPITokens retrieves all API tokens, newest first
+func (db *DB) ListAPITokens() ([]*models.APIToken, error) {
+	return db.ListAPITokensByOwner(AllOwners)
+}
+
+// ListAPITokensByOwner retrieves one user's API tokens, newest first
+func (db *DB) ListAPITokensByOwner(ownerID int64) ([]*models.APIToken, error) {
+	query := `
+	SELECT id, name, prefix, token_hash, scope, created_at, last_used_at, revoked_at, owner_id
+	FROM api_tokens
+	WHERE (? = 0 OR owner_id = ?)
+	ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := db.conn.Query(query, ownerID, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*models.APIToken
+	for rows.Next() {
+		var token models.APIToken
+		err := rows.Scan(
+			&token.ID, &token.Name, &token.Prefix, &token.TokenHash,
+			&token.Scope, &token.CreatedAt, &token.LastUsedAt, &token.RevokedAt, &token.OwnerID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		tokens = append(tokens, &token)
+	}
+
+	return tokens, nil
+}
+
+// TouchAPIToken records when an API token was last used
+func (db *DB) TouchAPIToken(id int64, usedAt time.Time) error {
+	query := `
+	UPDATE api_tokens SET last_used_at = ? WHERE id = ?
+	`
+
+	_, err := db.conn.Exec(query, usedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to update API token last used time: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAPIToken marks an API token as revoked so it can no longer be used
+func (db *DB) RevokeAPIToken(id int64, revokedAt time.Time) error {
+	query := `
+	UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL
+	`
+
+	result, err := db.conn.Exec(query, revokedAt, id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("API token not found")
+	}
+
+	return nil
+}
+
+// GetAPIToken retrieves an API token by ID
+func (db *DB) GetAPIToken(id int64) (*models.APIToken, error) {
+	query := `
+	SELECT id, name, prefix, token_hash, scope, created_at, last_used_at, revoked_at, owner_id
+	FROM api_tokens WHERE id = ?
+	`
+
+	var token models.APIToken
+	err := db.conn.QueryRow(query, id).Scan(
+		&token.ID, &token.Name, &token.Prefix, &token.TokenHash,
+		&token.Scope, &token.CreatedAt, &token.LastUsedAt, &token.RevokedAt, &token.OwnerID,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("API token not found")
+		}
+		return nil, fmt.Errorf("failed to get API token: %w", err)
+	}
+
+	return &token, nil
+}
@@ -0,0 +1,59 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_APITokens(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	token := &models.APIToken{
+		Name:      "browser extension",
+		Prefix:    "dd_abcd1234",
+		TokenHash: "hash-1",
+		Scope:     models.ScopeSubmit,
+		CreatedAt: time.Now(),
+	}
+	require.NoError(t, db.CreateAPIToken(token))
+	require.NotZero(t, token.ID)
+
+	// Hashes are unique
+	duplicate := *token
+	require.Error(t, db.CreateAPIToken(&duplicate))
+
+	found, err := db.GetAPITokenByHash("hash-1")
+	require.NoError(t, err)
+	require.Equal(t, token.ID, found.ID)
+	require.Equal(t, models.ScopeSubmit, found.Scope)
+	require.Nil(t, found.LastUsedAt)
+	require.False(t, found.Revoked())
+
+	_, err = db.GetAPITokenByHash("missing")
+	require.Error(t, err)
+
+	usedAt := time.Now()
+	require.NoError(t, db.TouchAPIToken(token.ID, usedAt))
+	found, err = db.GetAPITokenByHash("hash-1")
+	require.NoError(t, err)
+	require.NotNil(t, found.LastUsedAt)
+	require.WithinDuration(t, usedAt, *found.LastUsedAt, time.Second)
+
+	require.NoError(t, db.RevokeAPIToken(token.ID, time.Now()))
+	found, err = db.GetAPITokenByHash("hash-1")
+	require.NoError(t, err)
+	require.True(t, found.Revoked())
+
+	// Revoking twice reports not found
+	require.Error(t, db.RevokeAPIToken(token.ID, time.Now()))
+
+	tokens, err := db.ListAPITokens()
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+}
@@ -0,0 +1,252 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"debrid-downloader/pkg/models"
+)
+
+// idCondition returns the condition limiting a bulk change to the given downloads of an
+// owner, with its arguments
+func idCondition(ownerID int64, ids []int64) (string, []any) {
+	args := make([]any, 0, len(ids)+2)
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	args = append(args, ownerID, ownerID)
+	return `id IN (` + placeholders(len(ids)) + `) AND (? = 0 OR owner_id = ?)`, args
+}
+
+// updateIDs runs an UPDATE ... RETURNING id and returns the IDs of the rows it changed
+func updateIDs(q interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}, query string, args ...any) ([]int64, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetDownloadsByIDs retrieves the given downloads of an owner, or of everyone with
+// AllOwners, skipping any that don't exist
+func (db *DB) GetDownloadsByIDs(ownerID int64, ids []int64) ([]*models.Download, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	condition, args := idCondition(ownerID, ids)
+	downloads, err := db.queryDownloads(`SELECT `+downloadColumns+` FROM downloads WHERE `+condition+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get downloads: %w", err)
+	}
+	return downloads, nil
+}
+
+// RetryAllFailed sets every failed download of an owner, or everyone's with AllOwners,
+// back to pending with its retry count reset in one statement, returning their IDs
+func (db *DB) RetryAllFailed(ownerID int64, now time.Time) ([]int64, error) {
+	query := `
+	UPDATE downloads SET status = 'pending', error_message = '', retry_count = 0, updated_at = ?
+	WHERE status = 'failed' AND (? = 0 OR owner_id = ?)
+	RETURNING id
+	`
+
+	changed, err := updateIDs(db.conn, query, now, ownerID, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry failed downloads: %w", err)
+	}
+	slices.Sort(changed)
+	return changed, nil
+}
+
+// RetryDownloads sets the given failed downloads back to pending with their retry count
+// reset, since a retry by hand starts over, returning the IDs of those it changed
+func (db *DB) RetryDownloads(ownerID int64, ids []int64, now time.Time) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	condition, args := idCondition(ownerID, ids)
+	query := `
+	UPDATE downloads SET status = 'pending', error_message = '', retry_count = 0, updated_at = ?
+	WHERE ` + condition + ` AND status = 'failed'
+	RETURNING id
+	`
+
+	changed, err := updateIDs(db.conn, query, append([]any{now}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry downloads: %w", err)
+	}
+	return changed, nil
+}
+
+// PauseDownloads marks the given pending or downloading downloads as paused, returning
+// the IDs of those it changed. Downloads in progress have to be stopped by the worker.
+func (db *DB) PauseDownloads(ownerID int64, ids []int64, now time.Time) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	condition, args := idCondition(ownerID, ids)
+	query := `
+	UPDATE downloads SET status = 'paused', paused_at = ?, updated_at = ?
+	WHERE ` + condition + ` AND status IN ('pending', 'downloading')
+	RETURNING id
+	`
+
+	changed, err := updateIDs(db.conn, query, append([]any{now, now}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pause downloads: %w", err)
+	}
+	return changed, nil
+}
+
+// ResumeDownloads sets the given paused downloads back to pending, adding the time they
+// were paused to their total, and returns the IDs of those it changed
+func (db *DB) ResumeDownloads(ownerID int64, ids []int64, now time.Time) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	condition, args := idCondition(ownerID, ids)
+	rows, err := tx.Query(`SELECT id, paused_at FROM downloads WHERE `+condition+` AND status = 'paused'`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paused downloads: %w", err)
+	}
+
+	pausedFor := make(map[int64]int64)
+	for rows.Next() {
+		var id int64
+		var pausedAt *time.Time
+		if err := rows.Scan(&id, &pausedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan paused download: %w", err)
+		}
+		pausedFor[id] = 0
+		if pausedAt != nil {
+			pausedFor[id] = int64(now.Sub(*pausedAt).Seconds())
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read paused downloads: %w", err)
+	}
+
+	changed := make([]int64, 0, len(pausedFor))
+	for id, seconds := range pausedFor {
+		_, err := tx.Exec(`
+		UPDATE downloads
+		SET status = 'pending', paused_at = NULL, total_paused_time = total_paused_time + ?, updated_at = ?
+		WHERE id = ?`, seconds, now, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resume download %d: %w", id, err)
+		}
+		changed = append(changed, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit resumed downloads: %w", err)
+	}
+	return changed, nil
+}
+
+// SetDownloadsPriority changes the queue priority of the given downloads, returning the
+// IDs of those it changed
+func (db *DB) SetDownloadsPriority(ownerID int64, ids []int64, priority int, now time.Time) ([]int64, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	condition, args := idCondition(ownerID, ids)
+	query := `UPDATE downloads SET priority = ?, updated_at = ? WHERE ` + condition + ` RETURNING id`
+
+	changed, err := updateIDs(db.conn, query, append([]any{priority, now}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to change download priority: %w", err)
+	}
+	return changed, nil
+}
+
+// MoveDownloads records that the given downloads now live in directory, moving the
+// paths of the files extracted inside their old directory along with them
+func (db *DB) MoveDownloads(downloads []*models.Download, directory string, now time.Time) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, download := range downloads {
+		extracted, err := movedExtractedFiles(download.ExtractedFiles, download.Directory, directory)
+		if err != nil {
+			return fmt.Errorf("failed to read extracted files of download %d: %w", download.ID, err)
+		}
+
+		_, err = tx.Exec(`UPDATE downloads SET directory = ?, extracted_files = ?, updated_at = ? WHERE id = ?`,
+			directory, extracted, now, download.ID)
+		if err != nil {
+			return fmt.Errorf("failed to move download %d: %w", download.ID, err)
+		}
+
+		old := strings.TrimSuffix(download.Directory, string(filepath.Separator))
+		_, err = tx.Exec(`
+		UPDATE extracted_files SET file_path = ? || substr(file_path, length(?) + 1)
+		WHERE download_id = ? AND file_path LIKE ? ESCAPE '\'`,
+			directory, old, download.ID, escapeLike(old)+"/%")
+		if err != nil {
+			return fmt.Errorf("failed to move extracted files of download %d: %w", download.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit moved downloads: %w", err)
+	}
+	return nil
+}
+
+// movedExtractedFiles rewrites a download's JSON list of extracted files for a move from
+// one directory to another. Files outside the old directory keep their paths.
+func movedExtractedFiles(list, from, to string) (string, error) {
+	if list == "" {
+		return list, nil
+	}
+
+	var paths []string
+	if err := json.Unmarshal([]byte(list), &paths); err != nil {
+		return "", err
+	}
+	for i, path := range paths {
+		if relative, err := filepath.Rel(from, path); err == nil && !strings.HasPrefix(relative, "..") {
+			paths[i] = filepath.Join(to, relative)
+		}
+	}
+
+	moved, err := json.Marshal(paths)
+	if err != nil {
+		return "", err
+	}
+	return string(moved), nil
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_BulkStatusChanges(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	create := func(name string, status models.DownloadStatus, ownerID int64, retries int) *models.Download {
+		download := &models.Download{
+			OriginalURL: "https://example.com/" + name,
+			Filename:    name,
+			Directory:   "/downloads",
+			Status:      status,
+			RetryCount:  retries,
+			OwnerID:     ownerID,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		require.NoError(t, db.CreateDownload(download))
+		return download
+	}
+
+	failed := create("failed.mkv", models.StatusFailed, 1, 1)
+	exhausted := create("exhausted.mkv", models.StatusFailed, 1, 5)
+	otherFailed := create("other-failed.mkv", models.StatusFailed, 2, 0)
+	pending := create("pending.mkv", models.StatusPending, 1, 0)
+	completed := create("completed.mkv", models.StatusCompleted, 1, 0)
+	ids := []int64{failed.ID, exhausted.ID, otherFailed.ID, pending.ID, completed.ID}
+
+	// Retries skip other owners' downloads, and start over for those out of retries
+	retried, err := db.RetryDownloads(1, ids, now)
+	require.NoError(t, err)
+	require.Equal(t, []int64{failed.ID, exhausted.ID}, retried)
+
+	download, err := db.GetDownload(exhausted.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusPending, download.Status)
+	require.Zero(t, download.RetryCount)
+
+	// Pausing only applies to pending and downloading downloads
+	paused, err := db.PauseDownloads(AllOwners, ids, now.Add(-time.Minute))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int64{failed.ID, exhausted.ID, pending.ID}, paused)
+
+	resumed, err := db.ResumeDownloads(1, ids, now)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int64{failed.ID, exhausted.ID, pending.ID}, resumed)
+
+	download, err = db.GetDownload(pending.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusPending, download.Status)
+	require.Nil(t, download.PausedAt)
+	require.InDelta(t, 60, download.TotalPausedTime, 1)
+
+	prioritised, err := db.SetDownloadsPriority(1, ids, 7, now)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int64{failed.ID, exhausted.ID, pending.ID, completed.ID}, prioritised)
+
+	download, err = db.GetDownload(completed.ID)
+	require.NoError(t, err)
+	require.Equal(t, 7, download.Priority)
+
+	owned, err := db.GetDownloadsByIDs(2, ids)
+	require.NoError(t, err)
+	require.Len(t, owned, 1)
+	require.Equal(t, otherFailed.ID, owned[0].ID)
+
+	// Retrying all failed downloads only takes those still failed
+	retried, err = db.RetryAllFailed(1, now)
+	require.NoError(t, err)
+	require.Empty(t, retried)
+
+	retried, err = db.RetryAllFailed(AllOwners, now)
+	require.NoError(t, err)
+	require.Equal(t, []int64{otherFailed.ID}, retried)
+}
+
+func TestDB_MoveDownloads(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	download := &models.Download{
+		OriginalURL:    "https://example.com/film.rar",
+		Filename:       "film.rar",
+		Directory:      "/downloads/incoming",
+		Status:         models.StatusCompleted,
+		IsArchive:      true,
+		ExtractedFiles: `["/downloads/incoming/Film/film.mkv","/elsewhere/notes.txt"]`,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	require.NoError(t, db.CreateDownload(download))
+	for _, path := range []string{"/downloads/incoming/Film/film.mkv", "/elsewhere/notes.txt"} {
+		require.NoError(t, db.CreateExtractedFile(&models.ExtractedFile{DownloadID: download.ID, FilePath: path, CreatedAt: now}))
+	}
+
+	require.NoError(t, db.MoveDownloads([]*models.Download{download}, "/downloads/movies", now))
+
+	moved, err := db.GetDownload(download.ID)
+	require.NoError(t, err)
+	require.Equal(t, "/downloads/movies", moved.Directory)
+	require.JSONEq(t, `["/downloads/movies/Film/film.mkv","/elsewhere/notes.txt"]`, moved.ExtractedFiles)
+
+	files, err := db.GetExtractedFilesByDownloadID(download.ID)
+	require.NoError(t, err)
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.FilePath)
+	}
+	require.ElementsMatch(t, []string{"/downloads/movies/Film/film.mkv", "/elsewhere/notes.txt"}, paths)
+
+	// The search index follows the new folder
+	found, err := db.FilterDownloads(AllOwners, DownloadFilter{Search: "dir:movies", Statuses: []string{"completed"}}, "desc", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+}
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"debrid-downloader/pkg/models"
+)
+
+const categoryColumns = `id, name, directory, keywords, extract, cleanup_profile, priority, hook_id, rename_template, created_at`
+
+// CreateCategory stores a new category
+func (db *DB) CreateCategory(category *models.Category) error {
+	query := `
+	INSERT INTO categories (name, directory, keywords, extract, cleanup_profile, priority, hook_id, rename_template, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := db.conn.Exec(query,
+		category.Name, category.Directory, category.Keywords, category.Extract,
+		cleanupProfile(category), category.Priority, category.HookID, category.RenameTemplate, category.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create category: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	category.ID = id
+	return nil
+}
+
+// GetCategory retrieves a category by ID
+func (db *DB) GetCategory(id int64) (*models.Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM categories WHERE id = ?`
+
+	category, err := scanCategory(db.conn.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("category not found")
+		}
+		return nil, fmt.Errorf("failed to get category: %w", err)
+	}
+
+	return category, nil
+}
+
+// GetCategoryByName retrieves a category by name
+func (db *DB) GetCategoryByName(name string) (*models.Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM categories WHERE name = ?`
+
+	category, err := scanCategory(db.conn.QueryRow(query, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("category not found")
+		}
+		return nil, fmt.Errorf("failed to get category: %w", err)
+	}
+
+	return category, nil
+}
+
+// ListCategories retrieves all categories ordered by name
+func (db *DB) ListCategories() ([]*models.Category, error) {
+	query := `SELECT ` + categoryColumns + ` FROM categories ORDER BY name ASC`
+
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []*models.Category
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, nil
+}
+
+// UpdateCategory updates a category's name, directory and post-processing settings
+func (db *DB) UpdateCategory(category *models.Category) error {
+	query := `
+	UPDATE categories SET
+		name = ?, directory = ?, keywords = ?, extract = ?,
+		cleanup_profile = ?, priority = ?, hook_id = ?, rename_template = ?
+	WHERE id = ?
+	`
+
+	_, err := db.conn.Exec(query,
+		category.Name, category.Directory, category.Keywords, category.Extract,
+		cleanupProfile(category), category.Priority, category.HookID, category.RenameTemplate, category.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update category: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteCategory removes a category by name
+func (db *DB) DeleteCategory(name string) error {
+	if _, err := db.conn.Exec("DELETE FROM categories WHERE name = ?", name); err != nil {
+		return fmt.Errorf("failed to delete category: %w", err)
+	}
+
+	return nil
+}
+
+// cleanupProfile returns the category's cleanup profile, defaulting to video cleanup
+func cleanupProfile(category *models.Category) models.CleanupProfile {
+	if category.CleanupProfile == "" {
+		return models.CleanupVideo
+	}
+	return category.CleanupProfile
+}
+
+// scanCategory reads a category selected with categoryColumns
+func scanCategory(row rowScanner) (*models.Category, error) {
+	var category models.Category
+	err := row.Scan(
+		&category.ID, &category.Name, &category.Directory, &category.Keywords,
+		&category.Extract, &category.CleanupProfile, &category.Priority,
+		&category.HookID, &category.RenameTemplate, &category.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
@@ -15,6 +15,9 @@ import (
 	_ "modernc.org/sqlite"
 )
 
+// AllOwners can be passed to the ByOwner queries to include every user's records
+const AllOwners int64 = 0
+
 // DB wraps the SQLite database connection
 type DB struct {
 	conn *sql.DB
@@ -44,6 +47,11 @@ func New(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
+	if err := db.migrate(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
 	return db, nil
 }
 
@@ -96,6 +104,28 @@ func (db *DB) initSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_directory_mappings_pattern ON directory_mappings(filename_pattern);
 	CREATE INDEX IF NOT EXISTS idx_directory_mappings_use_count ON directory_mappings(use_count DESC);
 
+	CREATE TABLE IF NOT EXISTS directory_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		token TEXT NOT NULL,
+		directory TEXT NOT NULL,
+		weight REAL NOT NULL DEFAULT 0,
+		last_used DATETIME NOT NULL,
+		owner_id INTEGER NOT NULL DEFAULT 0,
+		UNIQUE (owner_id, token, directory)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_directory_tokens_token ON directory_tokens(token);
+
+	CREATE TABLE IF NOT EXISTS suggestion_feedback (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		filename TEXT NOT NULL,
+		suggested TEXT NOT NULL,
+		chosen TEXT NOT NULL,
+		accepted BOOLEAN NOT NULL,
+		owner_id INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	);
+
 	CREATE TABLE IF NOT EXISTS download_groups (
 		id TEXT PRIMARY KEY,
 		created_at DATETIME NOT NULL,
@@ -116,12 +146,162 @@ func (db *DB) initSchema() error {
 
 	CREATE INDEX IF NOT EXISTS idx_extracted_files_download_id ON extracted_files(download_id);
 	CREATE INDEX IF NOT EXISTS idx_extracted_files_deleted_at ON extracted_files(deleted_at);
+
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		scope TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		last_used_at DATETIME,
+		revoked_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL DEFAULT '',
+		role TEXT NOT NULL,
+		folder TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS categories (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		directory TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		condition TEXT NOT NULL,
+		value TEXT NOT NULL,
+		directory TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS torrents (
+		hash TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		magnet_id INTEGER NOT NULL,
+		category TEXT NOT NULL DEFAULT '',
+		directory TEXT NOT NULL,
+		size INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL,
+		error_message TEXT NOT NULL DEFAULT '',
+		group_id TEXT NOT NULL DEFAULT '',
+		owner_id INTEGER NOT NULL DEFAULT 0,
+		added_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_torrents_status ON torrents(status);
+
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL DEFAULT '',
+		enabled BOOLEAN NOT NULL DEFAULT 1,
+		owner_id INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_code INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		next_attempt_at DATETIME NOT NULL,
+		created_at DATETIME NOT NULL,
+		delivered_at DATETIME,
+		FOREIGN KEY (webhook_id) REFERENCES webhooks(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id);
+
+	CREATE TABLE IF NOT EXISTS media_servers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		url TEXT NOT NULL,
+		token TEXT NOT NULL DEFAULT '',
+		path_prefix TEXT NOT NULL,
+		remote_prefix TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS hooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		script TEXT NOT NULL,
+		directory TEXT NOT NULL DEFAULT '',
+		timeout_seconds INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS filter_presets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		query TEXT NOT NULL,
+		owner_id INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		UNIQUE (owner_id, name)
+	);
 	`
 
 	_, err := db.conn.Exec(schema)
 	return err
 }
 
+// downloadColumns lists the downloads columns in the order scanDownload reads them
+const downloadColumns = `id, original_url, unrestricted_url, filename, directory, status,
+		   progress, file_size, downloaded_bytes, download_speed,
+		   error_message, retry_count, created_at, updated_at,
+		   started_at, completed_at, paused_at, total_paused_time,
+		   group_id, is_archive, extracted_files, owner_id,
+		   hook_exit_code, hook_output, category, priority, pinned, next_attempt_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanDownload reads a download selected with downloadColumns
+func scanDownload(row rowScanner) (*models.Download, error) {
+	var download models.Download
+	err := row.Scan(
+		&download.ID, &download.OriginalURL, &download.UnrestrictedURL,
+		&download.Filename, &download.Directory, &download.Status,
+		&download.Progress, &download.FileSize, &download.DownloadedBytes,
+		&download.DownloadSpeed, &download.ErrorMessage, &download.RetryCount,
+		&download.CreatedAt, &download.UpdatedAt, &download.StartedAt,
+		&download.CompletedAt, &download.PausedAt, &download.TotalPausedTime,
+		&download.GroupID, &download.IsArchive, &download.ExtractedFiles,
+		&download.OwnerID, &download.HookExitCode, &download.HookOutput,
+		&download.Category, &download.Priority, &download.Pinned,
+		&download.NextAttemptAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &download, nil
+}
+
 // CreateDownload creates a new download record
 func (db *DB) CreateDownload(download *models.Download) error {
 	query := `
@@ -130,8 +310,8 @@ func (db *DB) CreateDownload(download *models.Download) error {
 		progress, file_size, downloaded_bytes, download_speed,
 		error_message, retry_count, created_at, updated_at,
 		started_at, completed_at, paused_at, total_paused_time,
-		group_id, is_archive, extracted_files
-	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		group_id, is_archive, extracted_files, owner_id, category, priority, pinned
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	result, err := db.conn.Exec(query,
@@ -142,6 +322,7 @@ func (db *DB) CreateDownload(download *models.Download) error {
 		download.UpdatedAt, download.StartedAt, download.CompletedAt,
 		download.PausedAt, download.TotalPausedTime,
 		download.GroupID, download.IsArchive, download.ExtractedFiles,
+		download.OwnerID, download.Category, download.Priority, download.Pinned,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create download: %w", err)
@@ -159,24 +340,11 @@ func (db *DB) CreateDownload(download *models.Download) error {
 // GetDownload retrieves a download by ID
 func (db *DB) GetDownload(id int64) (*models.Download, error) {
 	query := `
-	SELECT id, original_url, unrestricted_url, filename, directory, status,
-		   progress, file_size, downloaded_bytes, download_speed,
-		   error_message, retry_count, created_at, updated_at,
-		   started_at, completed_at, paused_at, total_paused_time,
-		   group_id, is_archive, extracted_files
+	SELECT ` + downloadColumns + `
 	FROM downloads WHERE id = ?
 	`
 
-	var download models.Download
-	err := db.conn.QueryRow(query, id).Scan(
-		&download.ID, &download.OriginalURL, &download.UnrestrictedURL,
-		&download.Filename, &download.Directory, &download.Status,
-		&download.Progress, &download.FileSize, &download.DownloadedBytes,
-		&download.DownloadSpeed, &download.ErrorMessage, &download.RetryCount,
-		&download.CreatedAt, &download.UpdatedAt, &download.StartedAt,
-		&download.CompletedAt, &download.PausedAt, &download.TotalPausedTime,
-		&download.GroupID, &download.IsArchive, &download.ExtractedFiles,
-	)
+	download, err := scanDownload(db.conn.QueryRow(query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("download not found")
@@ -184,7 +352,7 @@ func (db *DB) GetDownload(id int64) (*models.Download, error) {
 		return nil, fmt.Errorf("failed to get download: %w", err)
 	}
 
-	return &download, nil
+	return download, nil
 }
 
 // UpdateDownload updates an existing download record
@@ -195,7 +363,7 @@ func (db *DB) UpdateDownload(download *models.Download) error {
 		downloaded_bytes = ?, download_speed = ?, error_message = ?,
 		retry_count = ?, updated_at = ?, started_at = ?, completed_at = ?,
 		paused_at = ?, total_paused_time = ?, group_id = ?, is_archive = ?,
-		extracted_files = ?
+		extracted_files = ?, next_attempt_at = ?
 	WHERE id = ?
 	`
 
@@ -205,7 +373,7 @@ func (db *DB) UpdateDownload(download *models.Download) error {
 		download.ErrorMessage, download.RetryCount, download.UpdatedAt,
 		download.StartedAt, download.CompletedAt, download.PausedAt,
 		download.TotalPausedTime, download.GroupID, download.IsArchive,
-		download.ExtractedFiles, download.ID,
+		download.ExtractedFiles, download.NextAttemptAt, download.ID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update download: %w", err)
@@ -214,15 +382,28 @@ func (db *DB) UpdateDownload(download *models.Download) error {
 	return nil
 }
 
+// UpdateDownloadLocation records where a download's file was moved to
+func (db *DB) UpdateDownloadLocation(id int64, directory, filename string) error {
+	query := `UPDATE downloads SET directory = ?, filename = ?, updated_at = ? WHERE id = ?`
+
+	if _, err := db.conn.Exec(query, directory, filename, time.Now(), id); err != nil {
+		return fmt.Errorf("failed to update download location: %w", err)
+	}
+
+	return nil
+}
+
 // ListDownloads retrieves downloads with pagination
 func (db *DB) ListDownloads(limit, offset int) ([]*models.Download, error) {
+	return db.ListDownloadsByOwner(AllOwners, limit, offset)
+}
+
+// ListDownloadsByOwner retrieves one user's downloads with pagination
+func (db *DB) ListDownloadsByOwner(ownerID int64, limit, offset int) ([]*models.Download, error) {
 	query := `
-	SELECT id, original_url, unrestricted_url, filename, directory, status,
-		   progress, file_size, downloaded_bytes, download_speed,
-		   error_message, retry_count, created_at, updated_at,
-		   started_at, completed_at, paused_at, total_paused_time,
-		   group_id, is_archive, extracted_files
+	SELECT ` + downloadColumns + `
 	FROM downloads 
+	WHERE (? = 0 OR owner_id = ?)
 	ORDER BY 
 		CASE 
 			WHEN status = 'downloading' THEN 1
@@ -234,7 +415,7 @@ func (db *DB) ListDownloads(limit, offset int) ([]*models.Download, error) {
 	LIMIT ? OFFSET ?
 	`
 
-	rows, err := db.conn.Query(query, limit, offset)
+	rows, err := db.conn.Query(query, ownerID, ownerID, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list downloads: %w", err)
 	}
@@ -242,20 +423,11 @@ func (db *DB) ListDownloads(limit, offset int) ([]*models.Download, error) {
 
 	var downloads []*models.Download
 	for rows.Next() {
-		var download models.Download
-		err := rows.Scan(
-			&download.ID, &download.OriginalURL, &download.UnrestrictedURL,
-			&download.Filename, &download.Directory, &download.Status,
-			&download.Progress, &download.FileSize, &download.DownloadedBytes,
-			&download.DownloadSpeed, &download.ErrorMessage, &download.RetryCount,
-			&download.CreatedAt, &download.UpdatedAt, &download.StartedAt,
-			&download.CompletedAt, &download.PausedAt, &download.TotalPausedTime,
-			&download.GroupID, &download.IsArchive, &download.ExtractedFiles,
-		)
+		download, err := scanDownload(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan download: %w", err)
 		}
-		downloads = append(downloads, &download)
+		downloads = append(downloads, download)
 	}
 
 	return downloads, nil
@@ -264,11 +436,7 @@ func (db *DB) ListDownloads(limit, offset int) ([]*models.Download, error) {
 // GetPendingDownloadsOldestFirst retrieves all pending downloads ordered by creation time (oldest first)
 func (db *DB) GetPendingDownloadsOldestFirst() ([]*models.Download, error) {
 	query := `
-	SELECT id, original_url, unrestricted_url, filename, directory, status,
-		   progress, file_size, downloaded_bytes, download_speed,
-		   error_message, retry_count, created_at, updated_at,
-		   started_at, completed_at, paused_at, total_paused_time,
-		   group_id, is_archive, extracted_files
+	SELECT ` + downloadColumns + `
 	FROM downloads 
 	WHERE status = ?
 	ORDER BY created_at ASC, id ASC
@@ -282,20 +450,11 @@ func (db *DB) GetPendingDownloadsOldestFirst() ([]*models.Download, error) {
 
 	var downloads []*models.Download
 	for rows.Next() {
-		var download models.Download
-		err := rows.Scan(
-			&download.ID, &download.OriginalURL, &download.UnrestrictedURL,
-			&download.Filename, &download.Directory, &download.Status,
-			&download.Progress, &download.FileSize, &download.DownloadedBytes,
-			&download.DownloadSpeed, &download.ErrorMessage, &download.RetryCount,
-			&download.CreatedAt, &download.UpdatedAt, &download.StartedAt,
-			&download.CompletedAt, &download.PausedAt, &download.TotalPausedTime,
-			&download.GroupID, &download.IsArchive, &download.ExtractedFiles,
-		)
+		download, err := scanDownload(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan download: %w", err)
 		}
-		downloads = append(downloads, &download)
+		downloads = append(downloads, download)
 	}
 
 	return downloads, nil
@@ -304,11 +463,7 @@ func (db *DB) GetPendingDownloadsOldestFirst() ([]*models.Download, error) {
 // GetOrphanedDownloads retrieves downloads stuck in downloading state (orphaned by server restart)
 func (db *DB) GetOrphanedDownloads() ([]*models.Download, error) {
 	query := `
-	SELECT id, original_url, unrestricted_url, filename, directory, status,
-		   progress, file_size, downloaded_bytes, download_speed,
-		   error_message, retry_count, created_at, updated_at,
-		   started_at, completed_at, paused_at, total_paused_time,
-		   group_id, is_archive, extracted_files
+	SELECT ` + downloadColumns + `
 	FROM downloads 
 	WHERE status = ?
 	ORDER BY created_at ASC, id ASC
@@ -322,97 +477,85 @@ func (db *DB) GetOrphanedDownloads() ([]*models.Download, error) {
 
 	var downloads []*models.Download
 	for rows.Next() {
-		var download models.Download
-		err := rows.Scan(
-			&download.ID, &download.OriginalURL, &download.UnrestrictedURL,
-			&download.Filename, &download.Directory, &download.Status,
-			&download.Progress, &download.FileSize, &download.DownloadedBytes,
-			&download.DownloadSpeed, &download.ErrorMessage, &download.RetryCount,
-			&download.CreatedAt, &download.UpdatedAt, &download.StartedAt,
-			&download.CompletedAt, &download.PausedAt, &download.TotalPausedTime,
-			&download.GroupID, &download.IsArchive, &download.ExtractedFiles,
-		)
+		download, err := scanDownload(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan download: %w", err)
 		}
-		downloads = append(downloads, &download)
+		downloads = append(downloads, download)
 	}
 
 	return downloads, nil
 }
 
-// SearchDownloads performs a fuzzy search on downloads with support for multiple status filters and custom sort order
+// SearchDownloads performs a full-text search on downloads with support for multiple status filters and custom sort order.
+// See parseSearch for the search syntax; matches are ranked by relevance after the status priority.
 func (db *DB) SearchDownloads(searchTerm string, statusFilters []string, sortOrder string, limit, offset int) ([]*models.Download, error) {
+	return db.SearchDownloadsByOwner(AllOwners, searchTerm, statusFilters, sortOrder, limit, offset)
+}
+
+// SearchDownloadsByOwner performs SearchDownloads limited to one user's downloads
+func (db *DB) SearchDownloadsByOwner(ownerID int64, searchTerm string, statusFilters []string, sortOrder string, limit, offset int) ([]*models.Download, error) {
+	return db.FilterDownloads(ownerID, DownloadFilter{Search: searchTerm, Statuses: statusFilters}, sortOrder, limit, offset)
+}
+
+// FilterDownloads performs SearchDownloadsByOwner with the other history filters as well
+func (db *DB) FilterDownloads(ownerID int64, filter DownloadFilter, sortOrder string, limit, offset int) ([]*models.Download, error) {
+	search := parseSearch(filter.Search)
+
 	query := `
-	SELECT id, original_url, unrestricted_url, filename, directory, status,
-		   progress, file_size, downloaded_bytes, download_speed,
-		   error_message, retry_count, created_at, updated_at,
-		   started_at, completed_at, paused_at, total_paused_time,
-		   group_id, is_archive, extracted_files
-	FROM downloads 
-	WHERE 1=1`
+	SELECT ` + downloadColumns + `
+	FROM downloads`
 
 	args := []interface{}{}
+	rank := ""
+
+	// Join the matches from the search index, which carry their relevance
+	if search.match != "" {
+		query = `
+	WITH matches AS (
+		SELECT rowid AS download_id, ` + searchRank + ` AS score
+		FROM downloads_fts WHERE downloads_fts MATCH ?
+	)` + query + `
+	JOIN matches ON matches.download_id = downloads.id`
+		args = append(args, search.match)
+		rank = "matches.score, "
+	}
+
+	query += `
+	WHERE 1=1`
+
+	if ownerID != AllOwners {
+		query += ` AND owner_id = ?`
+		args = append(args, ownerID)
+	}
 
-	// Add search term filter with fuzzy matching
-	if searchTerm != "" {
-		// Split search term into words for fuzzy matching
-		words := strings.Fields(strings.ToLower(searchTerm))
-		if len(words) > 0 {
-			// Create conditions for each word - any word can match any field
-			var conditions []string
-			for _, word := range words {
-				// Add multiple pattern variations for better fuzzy matching
-				wordPattern := "%" + word + "%"
-				var wordConditions []string
-
-				// Exact partial match
-				wordConditions = append(wordConditions, "(LOWER(filename) LIKE ? OR LOWER(original_url) LIKE ? OR LOWER(directory) LIKE ?)")
-				args = append(args, wordPattern, wordPattern, wordPattern)
-
-				// Match with word boundaries for more precise results
-				if len(word) >= 3 {
-					// Pattern that allows character separation (e.g., "example" matches "example_file")
-					partialPattern := "%"
-					for i, char := range word {
-						if i > 0 {
-							partialPattern += "_?"
-						}
-						partialPattern += string(char)
-					}
-					partialPattern += "%"
-					wordConditions = append(wordConditions, "(LOWER(filename) LIKE ? OR LOWER(original_url) LIKE ? OR LOWER(directory) LIKE ?)")
-					args = append(args, partialPattern, partialPattern, partialPattern)
-				}
-
-				// Add pattern for character substitution fuzzy matching (if word is 4+ chars)
-				if len(word) >= 4 {
-					// Create pattern with wildcard in middle for typo tolerance
-					midPattern := "%" + word[:2] + "%" + word[len(word)-2:] + "%"
-					wordConditions = append(wordConditions, "(LOWER(filename) LIKE ? OR LOWER(original_url) LIKE ? OR LOWER(directory) LIKE ?)")
-					args = append(args, midPattern, midPattern, midPattern)
-				}
-
-				conditions = append(conditions, "("+strings.Join(wordConditions, " OR ")+")")
-			}
-			// Any word matching is enough (use OR instead of AND)
-			query += ` AND (` + strings.Join(conditions, " OR ") + `)`
+	// status: filters in the search narrow the selected statuses further
+	if len(search.statuses) > 0 {
+		query += ` AND status IN (` + placeholders(len(search.statuses)) + `)`
+		for _, status := range search.statuses {
+			args = append(args, status)
 		}
 	}
 
 	// Add status filter - support multiple statuses
 	// If no statuses provided, return no results
-	if len(statusFilters) == 0 {
+	if len(filter.Statuses) == 0 {
 		query += ` AND 1=0`
 	} else {
-		placeholders := make([]string, len(statusFilters))
-		for i, status := range statusFilters {
+		placeholders := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
 			placeholders[i] = "?"
 			args = append(args, status)
 		}
 		query += ` AND status IN (` + strings.Join(placeholders, ",") + `)`
 	}
 
+	conditions, conditionArgs := filter.conditions()
+	for _, condition := range conditions {
+		query += ` AND ` + condition
+	}
+	args = append(args, conditionArgs...)
+
 	// Add sort order with status priority (downloading items always first)
 	if sortOrder == "asc" {
 		query += ` ORDER BY 
@@ -422,7 +565,7 @@ func (db *DB) SearchDownloads(searchTerm string, statusFilters []string, sortOrd
 				WHEN status = 'paused' THEN 3
 				ELSE 4
 			END,
-			created_at ASC, id DESC`
+			` + rank + `created_at ASC, id DESC`
 	} else {
 		query += ` ORDER BY 
 			CASE 
@@ -431,7 +574,7 @@ func (db *DB) SearchDownloads(searchTerm string, statusFilters []string, sortOrd
 				WHEN status = 'paused' THEN 3  
 				ELSE 4
 			END,
-			created_at DESC, id ASC`
+			` + rank + `created_at DESC, id ASC`
 	}
 
 	query += ` LIMIT ? OFFSET ?`
@@ -445,33 +588,25 @@ func (db *DB) SearchDownloads(searchTerm string, statusFilters []string, sortOrd
 
 	var downloads []*models.Download
 	for rows.Next() {
-		var download models.Download
-		err := rows.Scan(
-			&download.ID, &download.OriginalURL, &download.UnrestrictedURL,
-			&download.Filename, &download.Directory, &download.Status,
-			&download.Progress, &download.FileSize, &download.DownloadedBytes,
-			&download.DownloadSpeed, &download.ErrorMessage, &download.RetryCount,
-			&download.CreatedAt, &download.UpdatedAt, &download.StartedAt,
-			&download.CompletedAt, &download.PausedAt, &download.TotalPausedTime,
-			&download.GroupID, &download.IsArchive, &download.ExtractedFiles,
-		)
+		download, err := scanDownload(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan download: %w", err)
 		}
-		downloads = append(downloads, &download)
+		downloads = append(downloads, download)
 	}
 
 	return downloads, nil
 }
 
-// DeleteOldDownloads removes downloads older than the specified duration
+// DeleteOldDownloads removes finished downloads older than the specified duration, except
+// pinned ones
 func (db *DB) DeleteOldDownloads(olderThan time.Duration) error {
 	cutoff := time.Now().Add(-olderThan)
 
 	// First get the downloads that will be deleted to clean up temp files
 	selectQuery := `
 		SELECT id, filename, directory FROM downloads 
-		WHERE created_at < ? AND status IN ('failed', 'completed')
+		WHERE created_at < ? AND status IN ('failed', 'completed') AND pinned = 0
 	`
 
 	rows, err := db.conn.Query(selectQuery, cutoff)
@@ -497,7 +632,7 @@ func (db *DB) DeleteOldDownloads(olderThan time.Duration) error {
 	}
 
 	// Delete from database
-	deleteQuery := "DELETE FROM downloads WHERE created_at < ? AND status IN ('failed', 'completed')"
+	deleteQuery := "DELETE FROM downloads WHERE created_at < ? AND status IN ('failed', 'completed') AND pinned = 0"
 	result, err := db.conn.Exec(deleteQuery, cutoff)
 	if err != nil {
 		return fmt.Errorf("failed to delete old downloads: %w", err)
@@ -535,13 +670,13 @@ func (db *DB) DeleteDownload(id int64) error {
 func (db *DB) CreateDirectoryMapping(mapping *models.DirectoryMapping) error {
 	query := `
 	INSERT INTO directory_mappings (
-		filename_pattern, original_url, directory, use_count, last_used, created_at
-	) VALUES (?, ?, ?, ?, ?, ?)
+		filename_pattern, original_url, directory, use_count, last_used, created_at, owner_id
+	) VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 
 	result, err := db.conn.Exec(query,
 		mapping.FilenamePattern, mapping.OriginalURL, mapping.Directory,
-		mapping.UseCount, mapping.LastUsed, mapping.CreatedAt,
+		mapping.UseCount, mapping.LastUsed, mapping.CreatedAt, mapping.OwnerID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create directory mapping: %w", err)
@@ -558,13 +693,19 @@ func (db *DB) CreateDirectoryMapping(mapping *models.DirectoryMapping) error {
 
 // GetDirectoryMappings retrieves all directory mappings ordered by use count
 func (db *DB) GetDirectoryMappings() ([]*models.DirectoryMapping, error) {
+	return db.GetDirectoryMappingsByOwner(AllOwners)
+}
+
+// GetDirectoryMappingsByOwner retrieves one user's directory mappings ordered by use count
+func (db *DB) GetDirectoryMappingsByOwner(ownerID int64) ([]*models.DirectoryMapping, error) {
 	query := `
-	SELECT id, filename_pattern, original_url, directory, use_count, last_used, created_at
+	SELECT id, filename_pattern, original_url, directory, use_count, last_used, created_at, owner_id
 	FROM directory_mappings 
+	WHERE (? = 0 OR owner_id = ?)
 	ORDER BY use_count DESC, last_used DESC
 	`
 
-	rows, err := db.conn.Query(query)
+	rows, err := db.conn.Query(query, ownerID, ownerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get directory mappings: %w", err)
 	}
@@ -576,6 +717,7 @@ func (db *DB) GetDirectoryMappings() ([]*models.DirectoryMapping, error) {
 		err := rows.Scan(
 			&mapping.ID, &mapping.FilenamePattern, &mapping.OriginalURL,
 			&mapping.Directory, &mapping.UseCount, &mapping.LastUsed, &mapping.CreatedAt,
+			&mapping.OwnerID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan directory mapping: %w", err)
@@ -605,7 +747,7 @@ func (db *DB) UpdateDirectoryMappingUsage(id int64) error {
 // GetDirectorySuggestionsForURL retrieves directory mappings that might match the given URL
 func (db *DB) GetDirectorySuggestionsForURL(url string) ([]*models.DirectoryMapping, error) {
 	query := `
-	SELECT id, filename_pattern, original_url, directory, use_count, last_used, created_at
+	SELECT id, filename_pattern, original_url, directory, use_count, last_used, created_at, owner_id
 	FROM directory_mappings 
 	ORDER BY use_count DESC, last_used DESC
 	`
@@ -622,6 +764,7 @@ func (db *DB) GetDirectorySuggestionsForURL(url string) ([]*models.DirectoryMapp
 		err := rows.Scan(
 			&mapping.ID, &mapping.FilenamePattern, &mapping.OriginalURL,
 			&mapping.Directory, &mapping.UseCount, &mapping.LastUsed, &mapping.CreatedAt,
+			&mapping.OwnerID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan directory mapping: %w", err)
@@ -636,13 +779,13 @@ func (db *DB) GetDirectorySuggestionsForURL(url string) ([]*models.DirectoryMapp
 func (db *DB) CreateDownloadGroup(group *models.DownloadGroup) error {
 	query := `
 	INSERT INTO download_groups (
-		id, created_at, total_downloads, completed_downloads, status, processing_error
-	) VALUES (?, ?, ?, ?, ?, ?)
+		id, created_at, total_downloads, completed_downloads, status, processing_error, owner_id
+	) VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err := db.conn.Exec(query,
 		group.ID, group.CreatedAt, group.TotalDownloads,
-		group.CompletedDownloads, group.Status, group.ProcessingError,
+		group.CompletedDownloads, group.Status, group.ProcessingError, group.OwnerID,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create download group: %w", err)
@@ -654,14 +797,16 @@ func (db *DB) CreateDownloadGroup(group *models.DownloadGroup) error {
 // GetDownloadGroup retrieves a download group by ID
 func (db *DB) GetDownloadGroup(id string) (*models.DownloadGroup, error) {
 	query := `
-	SELECT id, created_at, total_downloads, completed_downloads, status, processing_error
+	SELECT id, created_at, total_downloads, completed_downloads, status, processing_error, owner_id,
+		hook_exit_code, hook_output
 	FROM download_groups WHERE id = ?
 	`
 
 	var group models.DownloadGroup
 	err := db.conn.QueryRow(query, id).Scan(
 		&group.ID, &group.CreatedAt, &group.TotalDownloads,
-		&group.CompletedDownloads, &group.Status, &group.ProcessingError,
+		&group.CompletedDownloads, &group.Status, &group.ProcessingError, &group.OwnerID,
+		&group.HookExitCode, &group.HookOutput,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -673,6 +818,39 @@ func (db *DB) GetDownloadGroup(id string) (*models.DownloadGroup, error) {
 	return &group, nil
 }
 
+// ListDownloadGroups retrieves the most recent download groups, for one owner or everyone
+func (db *DB) ListDownloadGroups(ownerID int64, limit int) ([]*models.DownloadGroup, error) {
+	query := `
+	SELECT id, created_at, total_downloads, completed_downloads, status, processing_error, owner_id,
+		hook_exit_code, hook_output
+	FROM download_groups
+	WHERE (? = 0 OR owner_id = ?)
+	ORDER BY created_at DESC, id ASC
+	LIMIT ?
+	`
+
+	rows, err := db.conn.Query(query, ownerID, ownerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list download groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []*models.DownloadGroup
+	for rows.Next() {
+		var group models.DownloadGroup
+		if err := rows.Scan(
+			&group.ID, &group.CreatedAt, &group.TotalDownloads,
+			&group.CompletedDownloads, &group.Status, &group.ProcessingError, &group.OwnerID,
+			&group.HookExitCode, &group.HookOutput,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan download group: %w", err)
+		}
+		groups = append(groups, &group)
+	}
+
+	return groups, nil
+}
+
 // UpdateDownloadGroup updates an existing download group record
 func (db *DB) UpdateDownloadGroup(group *models.DownloadGroup) error {
 	query := `
@@ -691,14 +869,71 @@ func (db *DB) UpdateDownloadGroup(group *models.DownloadGroup) error {
 	return nil
 }
 
+// RetryDownloadGroup sets a group's failed downloads back to pending with their retry
+// counts reset, and the group back to downloading, in one transaction. It returns the
+// IDs of the downloads it changed.
+func (db *DB) RetryDownloadGroup(groupID string, now time.Time) ([]int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	changed, err := updateIDs(tx, `
+	UPDATE downloads SET status = 'pending', error_message = '', retry_count = 0, updated_at = ?
+	WHERE group_id = ? AND status = 'failed'
+	RETURNING id`, now, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry group downloads: %w", err)
+	}
+
+	if len(changed) > 0 {
+		_, err := tx.Exec(`UPDATE download_groups SET status = ?, processing_error = '' WHERE id = ?`,
+			models.GroupStatusDownloading, groupID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update download group: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return changed, nil
+}
+
+// DeleteDownloadGroup removes a group and its downloads in one transaction, returning how
+// many downloads were removed
+func (db *DB) DeleteDownloadGroup(id string) (int, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM download_groups WHERE id = ?`, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete download group: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return 0, fmt.Errorf("download group not found")
+	}
+
+	result, err = tx.Exec(`DELETE FROM downloads WHERE group_id = ?`, id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete group downloads: %w", err)
+	}
+	removed, _ := result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return int(removed), nil
+}
+
 // GetDownloadsByGroupID retrieves all downloads for a specific group
 func (db *DB) GetDownloadsByGroupID(groupID string) ([]*models.Download, error) {
 	query := `
-	SELECT id, original_url, unrestricted_url, filename, directory, status,
-		   progress, file_size, downloaded_bytes, download_speed,
-		   error_message, retry_count, created_at, updated_at,
-		   started_at, completed_at, paused_at, total_paused_time,
-		   group_id, is_archive, extracted_files
+	SELECT ` + downloadColumns + `
 	FROM downloads 
 	WHERE group_id = ?
 	ORDER BY 
@@ -719,20 +954,11 @@ func (db *DB) GetDownloadsByGroupID(groupID string) ([]*models.Download, error)
 
 	var downloads []*models.Download
 	for rows.Next() {
-		var download models.Download
-		err := rows.Scan(
-			&download.ID, &download.OriginalURL, &download.UnrestrictedURL,
-			&download.Filename, &download.Directory, &download.Status,
-			&download.Progress, &download.FileSize, &download.DownloadedBytes,
-			&download.DownloadSpeed, &download.ErrorMessage, &download.RetryCount,
-			&download.CreatedAt, &download.UpdatedAt, &download.StartedAt,
-			&download.CompletedAt, &download.PausedAt, &download.TotalPausedTime,
-			&download.GroupID, &download.IsArchive, &download.ExtractedFiles,
-		)
+		download, err := scanDownload(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan download: %w", err)
 		}
-		downloads = append(downloads, &download)
+		downloads = append(downloads, download)
 	}
 
 	return downloads, nil
@@ -807,15 +1033,58 @@ func (db *DB) MarkExtractedFileDeleted(id int64, deletedAt time.Time) error {
 	return nil
 }
 
+// UpdateExtractedFilePath records where an extracted file was moved to
+func (db *DB) UpdateExtractedFilePath(id int64, filePath string) error {
+	query := `
+	UPDATE extracted_files SET file_path = ? WHERE id = ?
+	`
+
+	_, err := db.conn.Exec(query, filePath, id)
+	if err != nil {
+		return fmt.Errorf("failed to update extracted file path: %w", err)
+	}
+
+	return nil
+}
+
 // GetDownloadStats retrieves download statistics by status
 func (db *DB) GetDownloadStats() (map[string]int, error) {
+	return db.GetDownloadStatsByOwner(AllOwners)
+}
+
+// DownloadTotals holds totals across every download in the history
+type DownloadTotals struct {
+	DownloadedBytes int64
+	Retries         int64
+	Speed           float64 // Combined speed of downloads in progress, in bytes per second
+}
+
+// GetDownloadTotals sums the bytes downloaded, retries made and current speed across all downloads
+func (db *DB) GetDownloadTotals() (*DownloadTotals, error) {
+	query := `
+	SELECT COALESCE(SUM(downloaded_bytes), 0), COALESCE(SUM(retry_count), 0),
+		   COALESCE(SUM(CASE WHEN status = 'downloading' THEN download_speed ELSE 0 END), 0)
+	FROM downloads
+	`
+
+	var totals DownloadTotals
+	if err := db.conn.QueryRow(query).Scan(&totals.DownloadedBytes, &totals.Retries, &totals.Speed); err != nil {
+		return nil, fmt.Errorf("failed to get download totals: %w", err)
+	}
+
+	return &totals, nil
+}
+
+// GetDownloadStatsByOwner retrieves one user's download statistics by status
+func (db *DB) GetDownloadStatsByOwner(ownerID int64) (map[string]int, error) {
 	query := `
 	SELECT status, COUNT(*) as count
 	FROM downloads
+	WHERE (? = 0 OR owner_id = ?)
 	GROUP BY status
 	`
 
-	rows, err := db.conn.Query(query)
+	rows, err := db.conn.Query(query, ownerID, ownerID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get download stats: %w", err)
 	}
@@ -500,6 +500,37 @@ func TestDB_GetDownloadGroup(t *testing.T) {
 	require.Contains(t, err.Error(), "download group not found")
 }
 
+func TestDB_ListDownloadGroups(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	for i, ownerID := range []int64{1, 2, 1} {
+		require.NoError(t, db.CreateDownloadGroup(&models.DownloadGroup{
+			ID:             fmt.Sprintf("group-%d", i),
+			CreatedAt:      now.Add(time.Duration(i) * time.Minute),
+			TotalDownloads: 2,
+			Status:         models.GroupStatusDownloading,
+			OwnerID:        ownerID,
+		}))
+	}
+
+	// Newest first
+	groups, err := db.ListDownloadGroups(AllOwners, 10)
+	require.NoError(t, err)
+	require.Len(t, groups, 3)
+	require.Equal(t, "group-2", groups[0].ID)
+
+	groups, err = db.ListDownloadGroups(1, 10)
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+
+	groups, err = db.ListDownloadGroups(AllOwners, 1)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+}
+
 func TestDB_UpdateDownloadGroup(t *testing.T) {
 	db, err := New(":memory:")
 	require.NoError(t, err)
@@ -585,6 +616,75 @@ func TestDB_GetDownloadsByGroupID(t *testing.T) {
 	}
 }
 
+func TestDB_RetryAndDeleteDownloadGroup(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	group := &models.DownloadGroup{
+		ID:              "test-group-id",
+		CreatedAt:       time.Now(),
+		TotalDownloads:  3,
+		Status:          models.GroupStatusFailed,
+		ProcessingError: "Failed to process any archive files",
+	}
+	require.NoError(t, db.CreateDownloadGroup(group))
+
+	var failed []int64
+	for i, status := range []models.DownloadStatus{models.StatusCompleted, models.StatusFailed, models.StatusFailed} {
+		download := &models.Download{
+			OriginalURL: fmt.Sprintf("https://example.com/file.part%d.rar", i+1),
+			Filename:    fmt.Sprintf("file.part%d.rar", i+1),
+			Directory:   "/downloads",
+			Status:      status,
+			RetryCount:  i * 3, // The third part is out of retries
+			GroupID:     group.ID,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		require.NoError(t, db.CreateDownload(download))
+		if status == models.StatusFailed {
+			failed = append(failed, download.ID)
+		}
+	}
+	other := &models.Download{
+		OriginalURL: "https://example.com/other.rar",
+		Filename:    "other.rar",
+		Directory:   "/downloads",
+		Status:      models.StatusFailed,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(other))
+
+	// Every failed download of the group is retried, starting its retries over
+	retried, err := db.RetryDownloadGroup(group.ID, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, failed, retried)
+
+	exhausted, err := db.GetDownload(failed[1])
+	require.NoError(t, err)
+	require.Equal(t, models.StatusPending, exhausted.Status)
+	require.Zero(t, exhausted.RetryCount)
+
+	retrieved, err := db.GetDownloadGroup(group.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.GroupStatusDownloading, retrieved.Status)
+	require.Empty(t, retrieved.ProcessingError)
+
+	removed, err := db.DeleteDownloadGroup(group.ID)
+	require.NoError(t, err)
+	require.Equal(t, 3, removed)
+
+	_, err = db.GetDownloadGroup(group.ID)
+	require.Error(t, err)
+	_, err = db.GetDownload(other.ID)
+	require.NoError(t, err)
+
+	_, err = db.DeleteDownloadGroup(group.ID)
+	require.EqualError(t, err, "download group not found")
+}
+
 func TestDB_CreateExtractedFile(t *testing.T) {
 	db, err := New(":memory:")
 	require.NoError(t, err)
@@ -705,6 +805,41 @@ func TestDB_MarkExtractedFileDeleted(t *testing.T) {
 	require.Len(t, files, 0) // Deleted files are filtered out
 }
 
+func TestDB_UpdateLocations(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	download := &models.Download{
+		OriginalURL: "https://example.com/show.s01e01.mkv",
+		Filename:    "show.s01e01.mkv",
+		Directory:   "/downloads",
+		Status:      models.StatusCompleted,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(download))
+
+	require.NoError(t, db.UpdateDownloadLocation(download.ID, "/downloads/Show/Season 01", "Show - S01E01.mkv"))
+	retrieved, err := db.GetDownload(download.ID)
+	require.NoError(t, err)
+	require.Equal(t, "/downloads/Show/Season 01", retrieved.Directory)
+	require.Equal(t, "Show - S01E01.mkv", retrieved.Filename)
+
+	extractedFile := &models.ExtractedFile{
+		DownloadID: download.ID,
+		FilePath:   "/downloads/extracted/show.mkv",
+		CreatedAt:  time.Now(),
+	}
+	require.NoError(t, db.CreateExtractedFile(extractedFile))
+	require.NoError(t, db.UpdateExtractedFilePath(extractedFile.ID, "/downloads/Show/show.mkv"))
+
+	files, err := db.GetExtractedFilesByDownloadID(download.ID)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "/downloads/Show/show.mkv", files[0].FilePath)
+}
+
 func TestDB_ErrorCases(t *testing.T) {
 	db, err := New(":memory:")
 	require.NoError(t, err)
@@ -1251,3 +1386,32 @@ func TestDB_StatusBasedSortingInGetDownloadsByGroupID(t *testing.T) {
 		require.Equal(t, groupID, download.GroupID)
 	}
 }
+
+func TestDB_GetDownloadTotals(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	totals, err := db.GetDownloadTotals()
+	require.NoError(t, err)
+	require.Equal(t, DownloadTotals{}, *totals)
+
+	for _, download := range []*models.Download{
+		{Status: models.StatusCompleted, DownloadedBytes: 1000, RetryCount: 1, DownloadSpeed: 500},
+		{Status: models.StatusDownloading, DownloadedBytes: 200, RetryCount: 2, DownloadSpeed: 300},
+	} {
+		download.OriginalURL = "https://example.com/file.zip"
+		download.Filename = "file.zip"
+		download.Directory = "/downloads"
+		download.CreatedAt = time.Now()
+		download.UpdatedAt = time.Now()
+		require.NoError(t, db.CreateDownload(download))
+	}
+
+	totals, err = db.GetDownloadTotals()
+	require.NoError(t, err)
+	require.Equal(t, int64(1200), totals.DownloadedBytes)
+	require.Equal(t, int64(3), totals.Retries)
+	// Only downloads in progress count towards the current speed
+	require.Equal(t, 300.0, totals.Speed)
+}
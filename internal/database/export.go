@@ -0,0 +1,328 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"debrid-downloader/pkg/models"
+)
+
+// ExportVersion is the format version written by Export
+const ExportVersion = 1
+
+// Export holds the records moved between instances. Records keep their IDs so
+// references between them, such as a category's hook, still hold after an import. Owners
+// are matched by username instead, since user IDs differ between instances.
+type Export struct {
+	Version           int                        `json:"version"`
+	ExportedAt        time.Time                  `json:"exported_at"`
+	Users             []*ExportedUser            `json:"users"`
+	Downloads         []*models.Download         `json:"downloads"`
+	ExtractedFiles    []*models.ExtractedFile    `json:"extracted_files"`
+	Groups            []*models.DownloadGroup    `json:"groups"`
+	DirectoryMappings []*models.DirectoryMapping `json:"directory_mappings"`
+	Categories        []*models.Category         `json:"categories"`
+	Rules             []*models.Rule             `json:"rules"`
+	Hooks             []*models.Hook             `json:"hooks"`
+	MediaServers      []*ExportedMediaServer     `json:"media_servers"`
+	// Settings are the runtime settings changed on the settings page, by key
+	Settings map[string]string `json:"settings"`
+}
+
+// ExportedUser names the owner of exported records. Passwords aren't exported.
+type ExportedUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// ExportedUsers returns every user by name, for an export's owners
+func (db *DB) ExportedUsers() ([]*ExportedUser, error) {
+	users, err := db.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	exported := make([]*ExportedUser, len(users))
+	for i, user := range users {
+		exported[i] = &ExportedUser{ID: user.ID, Username: user.Username}
+	}
+	return exported, nil
+}
+
+// ExportedMediaServer is a media server with its token, which isn't otherwise serialised
+type ExportedMediaServer struct {
+	*models.MediaServer
+	Token string `json:"token"`
+}
+
+// ConflictMode says what an import does with records that already exist
+type ConflictMode string
+
+const (
+	ConflictSkip    ConflictMode = "skip"    // Keep the existing record
+	ConflictReplace ConflictMode = "replace" // Overwrite it with the imported one
+	ConflictFail    ConflictMode = "fail"    // Abort the import without changing anything
+)
+
+// Valid reports whether the mode is one of the known modes
+func (m ConflictMode) Valid() bool {
+	return m == ConflictSkip || m == ConflictReplace || m == ConflictFail
+}
+
+// ImportResult counts the records imported and skipped, by table
+type ImportResult struct {
+	Imported map[string]int `json:"imported"`
+	Skipped  map[string]int `json:"skipped"`
+}
+
+// Backup writes a consistent snapshot of the database to a new file while it stays in use
+func (db *DB) Backup(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file already exists: %s", path)
+	}
+
+	if _, err := db.conn.Exec(`VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("failed to back up database: %w", err)
+	}
+
+	return nil
+}
+
+// Export reads every download, group, directory mapping and setting, and the names of
+// their owners
+func (db *DB) Export() (*Export, error) {
+	export := &Export{Version: ExportVersion, ExportedAt: time.Now()}
+
+	var err error
+	if export.Users, err = db.ExportedUsers(); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn.Query(`SELECT ` + downloadColumns + ` FROM downloads ORDER BY id ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export downloads: %w", err)
+	}
+	for rows.Next() {
+		download, err := scanDownload(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan download: %w", err)
+		}
+		export.Downloads = append(export.Downloads, download)
+	}
+	rows.Close()
+
+	rows, err = db.conn.Query(`SELECT id, download_id, file_path, created_at, deleted_at FROM extracted_files ORDER BY id ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export extracted files: %w", err)
+	}
+	for rows.Next() {
+		var file models.ExtractedFile
+		if err := rows.Scan(&file.ID, &file.DownloadID, &file.FilePath, &file.CreatedAt, &file.DeletedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan extracted file: %w", err)
+		}
+		export.ExtractedFiles = append(export.ExtractedFiles, &file)
+	}
+	rows.Close()
+
+	if export.Groups, err = db.ListDownloadGroups(AllOwners, -1); err != nil {
+		return nil, err
+	}
+	if export.DirectoryMappings, err = db.GetDirectoryMappings(); err != nil {
+		return nil, err
+	}
+	if export.Categories, err = db.ListCategories(); err != nil {
+		return nil, err
+	}
+	if export.Rules, err = db.ListRules(); err != nil {
+		return nil, err
+	}
+	if export.Hooks, err = db.ListHooks(); err != nil {
+		return nil, err
+	}
+
+	servers, err := db.ListMediaServers()
+	if err != nil {
+		return nil, err
+	}
+	for _, server := range servers {
+		export.MediaServers = append(export.MediaServers, &ExportedMediaServer{MediaServer: server, Token: server.Token})
+	}
+
+	if export.Settings, err = db.GetSettings(); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// Import adds exported records in one transaction. A record conflicts when its ID, or a
+// category's name, is already taken; mode decides what happens then. Records go to the
+// user with their owner's username, or to ownerID when there is none.
+func (db *DB) Import(export *Export, mode ConflictMode, ownerID int64) (*ImportResult, error) {
+	if export.Version != ExportVersion {
+		return nil, fmt.Errorf("unsupported export version: %d", export.Version)
+	}
+	if !mode.Valid() {
+		return nil, fmt.Errorf("unknown conflict mode: %s", mode)
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	owner, err := importOwners(tx, export.Users, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	verb := map[ConflictMode]string{
+		ConflictSkip:    "INSERT OR IGNORE",
+		ConflictReplace: "INSERT OR REPLACE",
+		ConflictFail:    "INSERT",
+	}[mode]
+
+	result := &ImportResult{Imported: map[string]int{}, Skipped: map[string]int{}}
+	insert := func(table, columns string, values ...any) error {
+		query := verb + ` INTO ` + table + ` (` + columns + `) VALUES (` + placeholders(len(values)) + `)`
+
+		res, err := tx.Exec(query, values...)
+		if err != nil {
+			if mode == ConflictFail && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				return fmt.Errorf("import conflicts with an existing record in %s", table)
+			}
+			return fmt.Errorf("failed to import into %s: %w", table, err)
+		}
+		if affected, _ := res.RowsAffected(); affected > 0 {
+			result.Imported[table]++
+		} else {
+			result.Skipped[table]++
+		}
+		return nil
+	}
+
+	for _, d := range export.Downloads {
+		if err := insert("downloads", downloadColumns,
+			d.ID, d.OriginalURL, d.UnrestrictedURL, d.Filename, d.Directory, d.Status,
+			d.Progress, d.FileSize, d.DownloadedBytes, d.DownloadSpeed,
+			d.ErrorMessage, d.RetryCount, d.CreatedAt, d.UpdatedAt,
+			d.StartedAt, d.CompletedAt, d.PausedAt, d.TotalPausedTime,
+			d.GroupID, d.IsArchive, d.ExtractedFiles, owner(d.OwnerID),
+			d.HookExitCode, d.HookOutput, d.Category, d.Priority, d.Pinned,
+			d.NextAttemptAt,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range export.ExtractedFiles {
+		if err := insert("extracted_files", `id, download_id, file_path, created_at, deleted_at`,
+			f.ID, f.DownloadID, f.FilePath, f.CreatedAt, f.DeletedAt,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, g := range export.Groups {
+		if err := insert("download_groups",
+			`id, created_at, total_downloads, completed_downloads, status, processing_error, owner_id, hook_exit_code, hook_output`,
+			g.ID, g.CreatedAt, g.TotalDownloads, g.CompletedDownloads, g.Status, g.ProcessingError, owner(g.OwnerID),
+			g.HookExitCode, g.HookOutput,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, m := range export.DirectoryMappings {
+		if err := insert("directory_mappings",
+			`id, filename_pattern, original_url, directory, use_count, last_used, created_at, owner_id`,
+			m.ID, m.FilenamePattern, m.OriginalURL, m.Directory, m.UseCount, m.LastUsed, m.CreatedAt, owner(m.OwnerID),
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, h := range export.Hooks {
+		if err := insert("hooks", hookColumns,
+			h.ID, h.Name, h.Script, h.Directory, h.TimeoutSeconds, h.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range export.Categories {
+		if err := insert("categories", categoryColumns,
+			c.ID, c.Name, c.Directory, c.Keywords, c.Extract, cleanupProfile(c),
+			c.Priority, c.HookID, c.RenameTemplate, c.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, r := range export.Rules {
+		if err := insert("rules", ruleColumns,
+			r.ID, r.Condition, r.Value, r.Directory, r.Position, r.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range export.MediaServers {
+		if s.MediaServer == nil {
+			continue
+		}
+		if err := insert("media_servers", mediaServerColumns,
+			s.ID, s.Name, s.Kind, s.URL, s.Token, s.PathPrefix, s.RemotePrefix, s.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	for key, value := range export.Settings {
+		if err := insert("settings", `key, value, updated_at`, key, value, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return result, nil
+}
+
+// importOwners returns how an import maps the owner IDs of the exporting instance to
+// users here: an exported user goes to the user with the same username, and any other
+// owner to ownerID. Unowned records stay unowned.
+func importOwners(tx *sql.Tx, users []*ExportedUser, ownerID int64) (func(int64) int64, error) {
+	local := make(map[int64]int64, len(users))
+	for _, user := range users {
+		var id int64
+		err := tx.QueryRow(`SELECT id FROM users WHERE username = ?`, user.Username).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up user %s: %w", user.Username, err)
+		}
+		local[user.ID] = id
+	}
+
+	return func(id int64) int64 {
+		if id == 0 {
+			return 0
+		}
+		if mapped, ok := local[id]; ok {
+			return mapped
+		}
+		return ownerID
+	}, nil
+}
@@ -0,0 +1,176 @@
+package database
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Backup(t *testing.T) {
+	dir := t.TempDir()
+	db, err := New(filepath.Join(dir, "debrid.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.CreateDownload(&models.Download{
+		OriginalURL: "https://example.com/a.zip",
+		Filename:    "a.zip",
+		Directory:   "/downloads",
+		Status:      models.StatusCompleted,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}))
+
+	path := filepath.Join(dir, "backup.db")
+	require.NoError(t, db.Backup(path))
+
+	// The snapshot is a working database
+	backup, err := New(path)
+	require.NoError(t, err)
+	defer backup.Close()
+
+	download, err := backup.GetDownload(1)
+	require.NoError(t, err)
+	require.Equal(t, "a.zip", download.Filename)
+
+	// Existing files are never overwritten
+	err = db.Backup(path)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "backup file already exists")
+}
+
+func TestDB_ExportImport(t *testing.T) {
+	source, err := New(":memory:")
+	require.NoError(t, err)
+	defer source.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	createUsers := func(db *DB, names ...string) []*models.User {
+		var users []*models.User
+		for _, name := range names {
+			user := &models.User{Username: name, Role: models.RoleUser, CreatedAt: now}
+			require.NoError(t, db.CreateUser(user))
+			users = append(users, user)
+		}
+		return users
+	}
+	sourceUsers := createUsers(source, "alice", "bob")
+
+	download := &models.Download{
+		OriginalURL: "https://example.com/a.rar",
+		Filename:    "a.rar",
+		Directory:   "/downloads/movies",
+		Status:      models.StatusCompleted,
+		GroupID:     "group-1",
+		OwnerID:     sourceUsers[1].ID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	require.NoError(t, source.CreateDownload(download))
+	require.NoError(t, source.CreateExtractedFile(&models.ExtractedFile{DownloadID: download.ID, FilePath: "/downloads/movies/a.mkv", CreatedAt: now}))
+	require.NoError(t, source.CreateDownloadGroup(&models.DownloadGroup{ID: "group-1", CreatedAt: now, TotalDownloads: 1, Status: models.GroupStatusCompleted, OwnerID: sourceUsers[1].ID}))
+	require.NoError(t, source.CreateDirectoryMapping(&models.DirectoryMapping{FilenamePattern: "movie", Directory: "/downloads/movies", UseCount: 3, LastUsed: now, CreatedAt: now, OwnerID: sourceUsers[0].ID}))
+
+	hook := &models.Hook{Name: "scan", Script: "scan.sh", CreatedAt: now}
+	require.NoError(t, source.CreateHook(hook))
+	category := models.NewCategory("movies")
+	category.HookID = hook.ID
+	require.NoError(t, source.CreateCategory(category))
+	require.NoError(t, source.CreateRule(&models.Rule{Condition: models.RuleHost, Value: "example.com", Directory: "example", CreatedAt: now}))
+	require.NoError(t, source.CreateMediaServer(&models.MediaServer{Name: "Jellyfin", Kind: models.MediaServerJellyfin, URL: "http://jellyfin:8096", Token: "secret", PathPrefix: "/downloads", CreatedAt: now}))
+	require.NoError(t, source.ReplaceSettings(map[string]string{"concurrency": "4", "max_retries": "7"}))
+
+	export, err := source.Export()
+	require.NoError(t, err)
+
+	// Exports survive a round trip through JSON, media server tokens included
+	encoded, err := json.Marshal(export)
+	require.NoError(t, err)
+	require.Contains(t, string(encoded), `"token":"secret"`)
+	var decoded Export
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+
+	target, err := New(":memory:")
+	require.NoError(t, err)
+	defer target.Close()
+
+	// Owners are matched by username, and the importing user takes the rest
+	targetUsers := createUsers(target, "carol", "dave", "bob")
+	result, err := target.Import(&decoded, ConflictSkip, targetUsers[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Imported["downloads"])
+	require.Equal(t, 1, result.Imported["media_servers"])
+
+	imported, err := target.GetDownload(download.ID)
+	require.NoError(t, err)
+	require.Equal(t, "a.rar", imported.Filename)
+	require.Equal(t, "group-1", imported.GroupID)
+	require.Equal(t, targetUsers[2].ID, imported.OwnerID)
+
+	group, err := target.GetDownloadGroup("group-1")
+	require.NoError(t, err)
+	require.Equal(t, targetUsers[2].ID, group.OwnerID)
+
+	mappings, err := target.GetDirectoryMappings()
+	require.NoError(t, err)
+	require.Len(t, mappings, 1)
+	require.Equal(t, targetUsers[0].ID, mappings[0].OwnerID)
+
+	files, err := target.GetExtractedFilesByDownloadID(download.ID)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	require.Equal(t, "/downloads/movies/a.mkv", files[0].FilePath)
+
+	importedCategory, err := target.GetCategoryByName("movies")
+	require.NoError(t, err)
+	require.Equal(t, hook.ID, importedCategory.HookID)
+
+	servers, err := target.ListMediaServers()
+	require.NoError(t, err)
+	require.Len(t, servers, 1)
+	require.Equal(t, "secret", servers[0].Token)
+
+	settings, err := target.GetSettings()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"concurrency": "4", "max_retries": "7"}, settings)
+
+	// Importing again skips what already exists
+	result, err = target.Import(&decoded, ConflictSkip, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Skipped["downloads"])
+	require.Empty(t, result.Imported)
+
+	// Replacing overwrites existing records
+	decoded.Downloads[0].Filename = "renamed.rar"
+	_, err = target.Import(&decoded, ConflictReplace, 0)
+	require.NoError(t, err)
+	imported, err = target.GetDownload(download.ID)
+	require.NoError(t, err)
+	require.Equal(t, "renamed.rar", imported.Filename)
+
+	decoded.Settings["max_retries"] = "2"
+	_, err = target.Import(&decoded, ConflictReplace, 0)
+	require.NoError(t, err)
+	settings, err = target.GetSettings()
+	require.NoError(t, err)
+	require.Equal(t, "2", settings["max_retries"])
+
+	// Failing on conflicts changes nothing
+	decoded.Downloads[0].Filename = "again.rar"
+	_, err = target.Import(&decoded, ConflictFail, 0)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "import conflicts with an existing record in downloads")
+	imported, err = target.GetDownload(download.ID)
+	require.NoError(t, err)
+	require.Equal(t, "renamed.rar", imported.Filename)
+
+	_, err = target.Import(&Export{Version: 99}, ConflictSkip, 0)
+	require.Error(t, err)
+	_, err = target.Import(&decoded, ConflictMode("merge"), 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"database/sql/driver"
+	"net/url"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+func init() {
+	// url_host(url) returns a link's lowercased host without a port, for the host filter
+	sqlite.MustRegisterDeterministicScalarFunction("url_host", 1, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		link, _ := args[0].(string)
+		return urlHost(link), nil
+	})
+}
+
+// DownloadFilter selects downloads from the history. Zero fields don't filter.
+type DownloadFilter struct {
+	Search   string   // Full-text search, see parseSearch
+	Statuses []string // Statuses to include; none matches nothing
+
+	CreatedFrom   time.Time // Created at or after
+	CreatedTo     time.Time // Created before
+	CompletedFrom time.Time // Completed at or after
+	CompletedTo   time.Time // Completed before
+	MinSize       int64     // Smallest file size in bytes
+	MaxSize       int64     // Largest file size in bytes
+	Host          string    // Host of the original link, subdomains included
+	Directory     string    // Full path of a folder, subfolders included
+	GroupID       string
+	ArchivesOnly  bool
+}
+
+// conditions returns the SQL conditions for the filter's fields other than the search
+// and statuses, with their arguments
+func (f DownloadFilter) conditions() ([]string, []any) {
+	var conditions []string
+	var args []any
+
+	add := func(condition string, values ...any) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+
+	if !f.CreatedFrom.IsZero() {
+		add(`created_at >= ?`, f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		add(`created_at < ?`, f.CreatedTo)
+	}
+	if !f.CompletedFrom.IsZero() {
+		add(`completed_at >= ?`, f.CompletedFrom)
+	}
+	if !f.CompletedTo.IsZero() {
+		add(`completed_at < ?`, f.CompletedTo)
+	}
+	if f.MinSize > 0 {
+		add(`file_size >= ?`, f.MinSize)
+	}
+	if f.MaxSize > 0 {
+		add(`file_size <= ?`, f.MaxSize)
+	}
+	if host := strings.ToLower(strings.TrimSpace(f.Host)); host != "" {
+		add(`(url_host(original_url) = ? OR url_host(original_url) LIKE ? ESCAPE '\')`, host, "%."+escapeLike(host))
+	}
+	if dir := strings.TrimSpace(f.Directory); dir != "" {
+		dir = strings.TrimSuffix(dir, "/")
+		add(`(directory = ? OR directory LIKE ? ESCAPE '\')`, dir, escapeLike(dir)+"/%")
+	}
+	if f.GroupID != "" {
+		add(`group_id = ?`, f.GroupID)
+	}
+	if f.ArchivesOnly {
+		add(`is_archive = 1`)
+	}
+
+	return conditions, args
+}
+
+// urlHost returns a link's lowercased host without a port, or an empty string if it
+// can't be parsed
+func urlHost(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// escapeLike escapes the LIKE wildcards in s, for patterns using ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
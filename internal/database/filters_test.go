@@ -0,0 +1,83 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_FilterDownloads(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	day := 24 * time.Hour
+	create := func(filename, url, directory string, size int64, age time.Duration, group string, archive bool) {
+		created := now.Add(-age)
+		completed := created.Add(time.Hour)
+		require.NoError(t, db.CreateDownload(&models.Download{
+			OriginalURL: url,
+			Filename:    filename,
+			Directory:   directory,
+			Status:      models.StatusCompleted,
+			FileSize:    size,
+			CreatedAt:   created,
+			UpdatedAt:   completed,
+			CompletedAt: &completed,
+			GroupID:     group,
+			IsArchive:   archive,
+		}))
+	}
+
+	create("film.mkv", "https://cdn.host.example:8443/f", "/downloads/movies", 4<<30, 2*day, "g1", false)
+	create("sequel.mkv", "https://host.example/s", "/downloads/movies/sequels", 2<<30, 10*day, "g1", false)
+	create("show.rar", "https://other.example/r", "/downloads/tv", 500<<20, 30*day, "", true)
+	create("clip.mp4", "https://nothost.example/c", "/downloads/movies_old", 10<<20, 40*day, "", false)
+
+	filter := func(f DownloadFilter) []string {
+		f.Statuses = []string{"completed"}
+		results, err := db.FilterDownloads(AllOwners, f, "desc", 10, 0)
+		require.NoError(t, err)
+		names := []string{}
+		for _, d := range results {
+			names = append(names, d.Filename)
+		}
+		return names
+	}
+
+	require.Len(t, filter(DownloadFilter{}), 4)
+
+	// Date ranges
+	require.Equal(t, []string{"film.mkv", "sequel.mkv"}, filter(DownloadFilter{CreatedFrom: now.Add(-15 * day)}))
+	require.Equal(t, []string{"show.rar", "clip.mp4"}, filter(DownloadFilter{CreatedTo: now.Add(-15 * day)}))
+	require.Equal(t, []string{"sequel.mkv"}, filter(DownloadFilter{CompletedFrom: now.Add(-15 * day), CompletedTo: now.Add(-5 * day)}))
+
+	// Size range
+	require.Equal(t, []string{"sequel.mkv", "show.rar"}, filter(DownloadFilter{MinSize: 100 << 20, MaxSize: 3 << 30}))
+
+	// Host includes subdomains but not other hosts ending the same way
+	require.Equal(t, []string{"film.mkv", "sequel.mkv"}, filter(DownloadFilter{Host: "Host.Example"}))
+	require.Equal(t, []string{"film.mkv"}, filter(DownloadFilter{Host: "cdn.host.example"}))
+
+	// Folder includes subfolders but not folders sharing a prefix
+	require.Equal(t, []string{"film.mkv", "sequel.mkv"}, filter(DownloadFilter{Directory: "/downloads/movies/"}))
+	require.Equal(t, []string{"clip.mp4"}, filter(DownloadFilter{Directory: "/downloads/movies_old"}))
+
+	// Group and archives
+	require.Equal(t, []string{"film.mkv", "sequel.mkv"}, filter(DownloadFilter{GroupID: "g1"}))
+	require.Equal(t, []string{"show.rar"}, filter(DownloadFilter{ArchivesOnly: true}))
+
+	// Filters combine with the search
+	require.Equal(t, []string{"sequel.mkv"}, filter(DownloadFilter{Search: "sequel", Host: "host.example"}))
+	require.Empty(t, filter(DownloadFilter{Search: "show", Host: "host.example"}))
+}
+
+func TestURLHost(t *testing.T) {
+	require.Equal(t, "host.example", urlHost("https://user:pw@HOST.example:8080/path"))
+	require.Equal(t, "", urlHost("not a url"))
+	require.Equal(t, "", urlHost("://bad"))
+}
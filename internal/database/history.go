@@ -0,0 +1,121 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"debrid-downloader/pkg/models"
+)
+
+// expiredClause matches finished downloads past their retention, by when they finished
+const expiredClause = `((status = 'completed' AND COALESCE(completed_at, created_at) < ?)
+	OR (status = 'failed' AND COALESCE(completed_at, created_at) < ?))`
+
+// ExpiredDownloads returns the finished downloads a history cleanup removes, oldest first:
+// completed ones that finished before completedBefore, failed ones that finished before
+// failedBefore, then the oldest of the rest beyond maxRows finished downloads (0 for no
+// limit). Pinned downloads are never returned but count towards maxRows.
+func (db *DB) ExpiredDownloads(completedBefore, failedBefore time.Time, maxRows int) ([]*models.Download, error) {
+	query := `SELECT ` + downloadColumns + ` FROM downloads
+		WHERE pinned = 0 AND ` + expiredClause + `
+		ORDER BY COALESCE(completed_at, created_at) ASC, id ASC`
+	expired, err := db.queryDownloads(query, completedBefore, failedBefore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expired downloads: %w", err)
+	}
+
+	if maxRows <= 0 {
+		return expired, nil
+	}
+
+	var finished int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM downloads WHERE status IN ('completed', 'failed')`).Scan(&finished); err != nil {
+		return nil, fmt.Errorf("failed to count finished downloads: %w", err)
+	}
+
+	excess := finished - len(expired) - maxRows
+	if excess <= 0 {
+		return expired, nil
+	}
+
+	query = `SELECT ` + downloadColumns + ` FROM downloads
+		WHERE pinned = 0 AND status IN ('completed', 'failed') AND NOT ` + expiredClause + `
+		ORDER BY COALESCE(completed_at, created_at) ASC, id ASC
+		LIMIT ?`
+	oldest, err := db.queryDownloads(query, completedBefore, failedBefore, excess)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query oldest downloads: %w", err)
+	}
+
+	return append(expired, oldest...), nil
+}
+
+// queryDownloads runs a query selecting downloadColumns
+func (db *DB) queryDownloads(query string, args ...any) ([]*models.Download, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var downloads []*models.Download
+	for rows.Next() {
+		download, err := scanDownload(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan download: %w", err)
+		}
+		downloads = append(downloads, download)
+	}
+
+	return downloads, rows.Err()
+}
+
+// DeleteDownloads removes download records by ID in one transaction, returning how many
+// were removed
+func (db *DB) DeleteDownloads(ids []int64) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Delete in batches to stay under SQLite's limit on query parameters
+	var removed int64
+	for start := 0; start < len(ids); start += 500 {
+		batch := ids[start:min(start+500, len(ids))]
+		args := make([]any, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+
+		result, err := tx.Exec(`DELETE FROM downloads WHERE id IN (`+placeholders(len(batch))+`)`, args...)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete downloads: %w", err)
+		}
+		affected, _ := result.RowsAffected()
+		removed += affected
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return int(removed), nil
+}
+
+// SetDownloadPinned pins a download so history cleanup keeps it, or unpins it
+func (db *DB) SetDownloadPinned(id int64, pinned bool) error {
+	result, err := db.conn.Exec(`UPDATE downloads SET pinned = ? WHERE id = ?`, pinned, id)
+	if err != nil {
+		return fmt.Errorf("failed to update download: %w", err)
+	}
+
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("download not found")
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_ExpiredDownloads(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	create := func(name string, status models.DownloadStatus, age time.Duration) *models.Download {
+		finished := now.Add(-age)
+		download := &models.Download{
+			OriginalURL: "https://example.com/" + name,
+			Filename:    name,
+			Directory:   "/downloads",
+			Status:      status,
+			CreatedAt:   finished.Add(-time.Hour),
+			UpdatedAt:   finished,
+			CompletedAt: &finished,
+		}
+		require.NoError(t, db.CreateDownload(download))
+		return download
+	}
+	day := 24 * time.Hour
+
+	oldCompleted := create("old-completed.mkv", models.StatusCompleted, 40*day)
+	create("recent-completed.mkv", models.StatusCompleted, 10*day)
+	oldFailed := create("old-failed.mkv", models.StatusFailed, 10*day)
+	create("recent-failed.mkv", models.StatusFailed, 2*day)
+	pinned := create("pinned.mkv", models.StatusCompleted, 90*day)
+	require.NoError(t, db.SetDownloadPinned(pinned.ID, true))
+	create("pending.mkv", models.StatusPending, 90*day)
+
+	filenames := func(downloads []*models.Download) []string {
+		var names []string
+		for _, d := range downloads {
+			names = append(names, d.Filename)
+		}
+		return names
+	}
+
+	// Completed and failed downloads have their own retention; pinned and unfinished
+	// downloads are kept
+	expired, err := db.ExpiredDownloads(now.Add(-30*day), now.Add(-7*day), 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{oldCompleted.Filename, oldFailed.Filename}, filenames(expired))
+
+	// A row limit removes the oldest of the rest too, counting the pinned download
+	expired, err = db.ExpiredDownloads(now.Add(-30*day), now.Add(-7*day), 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"old-completed.mkv", "old-failed.mkv", "recent-completed.mkv"}, filenames(expired))
+
+	ids := make([]int64, len(expired))
+	for i, d := range expired {
+		ids[i] = d.ID
+	}
+	removed, err := db.DeleteDownloads(ids)
+	require.NoError(t, err)
+	require.Equal(t, 3, removed)
+
+	downloads, err := db.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"recent-failed.mkv", "pinned.mkv", "pending.mkv"}, filenames(downloads))
+
+	// The pin is kept with the download
+	pinned, err = db.GetDownload(pinned.ID)
+	require.NoError(t, err)
+	require.True(t, pinned.Pinned)
+
+	require.NoError(t, db.SetDownloadPinned(pinned.ID, false))
+	pinned, err = db.GetDownload(pinned.ID)
+	require.NoError(t, err)
+	require.False(t, pinned.Pinned)
+
+	require.EqualError(t, db.SetDownloadPinned(999, true), "download not found")
+}
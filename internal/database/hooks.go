@@ -0,0 +1,151 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"debrid-downloader/pkg/models"
+)
+
+const hookColumns = `id, name, script, directory, timeout_seconds, created_at`
+
+// CreateHook stores a new post-processing hook
+func (db *DB) CreateHook(hook *models.Hook) error {
+	query := `
+	INSERT INTO hooks (name, script, directory, timeout_seconds, created_at)
+	VALUES (?, ?, ?, ?, ?)
+	`
+
+	result, err := db.conn.Exec(query,
+		hook.Name, hook.Script, hook.Directory, hook.TimeoutSeconds, hook.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create hook: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	hook.ID = id
+	return nil
+}
+
+// GetHook retrieves a hook by ID
+func (db *DB) GetHook(id int64) (*models.Hook, error) {
+	query := `SELECT ` + hookColumns + ` FROM hooks WHERE id = ?`
+
+	hook, err := scanHook(db.conn.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("hook not found")
+		}
+		return nil, fmt.Errorf("failed to get hook: %w", err)
+	}
+
+	return hook, nil
+}
+
+// ListHooks retrieves all hooks ordered by name
+func (db *DB) ListHooks() ([]*models.Hook, error) {
+	query := `SELECT ` + hookColumns + ` FROM hooks ORDER BY name ASC, id ASC`
+
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hooks: %w", err)
+	}
+	defer rows.Close()
+
+	var hooks []*models.Hook
+	for rows.Next() {
+		hook, err := scanHook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan hook: %w", err)
+		}
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, nil
+}
+
+// DeleteHook removes a hook and unsets it on the categories using it
+func (db *DB) DeleteHook(id int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM hooks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete hook: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("hook not found")
+	}
+
+	if _, err := tx.Exec(`UPDATE categories SET hook_id = 0 WHERE hook_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to unset hook on categories: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// SetDownloadHookResult records the exit code and output of the hook run for a download
+func (db *DB) SetDownloadHookResult(id int64, exitCode int, output string) error {
+	_, err := db.conn.Exec(`UPDATE downloads SET hook_exit_code = ?, hook_output = ? WHERE id = ?`, exitCode, output, id)
+	if err != nil {
+		return fmt.Errorf("failed to set download hook result: %w", err)
+	}
+	return nil
+}
+
+// SetGroupHookResult records the exit code and output of the hook run for a group. The
+// result is copied to the group's downloads so the download list can show it.
+func (db *DB) SetGroupHookResult(groupID string, exitCode int, output string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE download_groups SET hook_exit_code = ?, hook_output = ? WHERE id = ?`, exitCode, output, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to set group hook result: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("download group not found")
+	}
+
+	if _, err := tx.Exec(`UPDATE downloads SET hook_exit_code = ?, hook_output = ? WHERE group_id = ?`, exitCode, output, groupID); err != nil {
+		return fmt.Errorf("failed to set group downloads hook result: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// scanHook reads a hook selected with hookColumns
+func scanHook(row rowScanner) (*models.Hook, error) {
+	var hook models.Hook
+	err := row.Scan(
+		&hook.ID, &hook.Name, &hook.Script, &hook.Directory,
+		&hook.TimeoutSeconds, &hook.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Hooks(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	hook := &models.Hook{
+		Name:           "Notify Sonarr",
+		Script:         "sonarr-import.sh",
+		Directory:      "tv",
+		TimeoutSeconds: 60,
+		CreatedAt:      time.Now(),
+	}
+	require.NoError(t, db.CreateHook(hook))
+	require.NotZero(t, hook.ID)
+
+	found, err := db.GetHook(hook.ID)
+	require.NoError(t, err)
+	require.Equal(t, "sonarr-import.sh", found.Script)
+	require.Equal(t, time.Minute, found.Timeout())
+
+	hooks, err := db.ListHooks()
+	require.NoError(t, err)
+	require.Len(t, hooks, 1)
+
+	require.NoError(t, db.DeleteHook(hook.ID))
+	require.Error(t, db.DeleteHook(hook.ID))
+	_, err = db.GetHook(hook.ID)
+	require.Error(t, err)
+}
+
+func TestDB_HookResults(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	group := &models.DownloadGroup{ID: "group-1", CreatedAt: time.Now(), Status: models.GroupStatusCompleted}
+	require.NoError(t, db.CreateDownloadGroup(group))
+
+	grouped := &models.Download{
+		OriginalURL: "https://example.com/a.rar",
+		Filename:    "a.rar",
+		Directory:   "/downloads",
+		Status:      models.StatusCompleted,
+		GroupID:     group.ID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(grouped))
+	single := &models.Download{
+		OriginalURL: "https://example.com/b.mkv",
+		Filename:    "b.mkv",
+		Directory:   "/downloads",
+		Status:      models.StatusCompleted,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(single))
+
+	// No hook has run yet
+	found, err := db.GetDownload(single.ID)
+	require.NoError(t, err)
+	require.Nil(t, found.HookExitCode)
+
+	require.NoError(t, db.SetDownloadHookResult(single.ID, 0, "done"))
+	found, err = db.GetDownload(single.ID)
+	require.NoError(t, err)
+	require.Equal(t, 0, *found.HookExitCode)
+	require.Equal(t, "done", found.HookOutput)
+
+	require.NoError(t, db.SetGroupHookResult(group.ID, 2, "import failed"))
+	foundGroup, err := db.GetDownloadGroup(group.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, *foundGroup.HookExitCode)
+	require.Equal(t, "import failed", foundGroup.HookOutput)
+
+	found, err = db.GetDownload(grouped.ID)
+	require.NoError(t, err)
+	require.Equal(t, 2, *found.HookExitCode)
+
+	require.Error(t, db.SetGroupHookResult("missing", 0, ""))
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"debrid-downloader/pkg/models"
+)
+
+const mediaServerColumns = `id, name, kind, url, token, path_prefix, remote_prefix, created_at`
+
+// CreateMediaServer stores a new media server
+func (db *DB) CreateMediaServer(server *models.MediaServer) error {
+	query := `
+	INSERT INTO media_servers (name, kind, url, token, path_prefix, remote_prefix, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := db.conn.Exec(query,
+		server.Name, server.Kind, server.URL, server.Token,
+		server.PathPrefix, server.RemotePrefix, server.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create media server: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	server.ID = id
+	return nil
+}
+
+// GetMediaServer retrieves a media server by ID
+func (db *DB) GetMediaServer(id int64) (*models.MediaServer, error) {
+	query := `SELECT ` + mediaServerColumns + ` FROM media_servers WHERE id = ?`
+
+	server, err := scanMediaServer(db.conn.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("media server not found")
+		}
+		return nil, fmt.Errorf("failed to get media server: %w", err)
+	}
+
+	return server, nil
+}
+
+// ListMediaServers retrieves all media servers ordered by name
+func (db *DB) ListMediaServers() ([]*models.MediaServer, error) {
+	query := `SELECT ` + mediaServerColumns + ` FROM media_servers ORDER BY name ASC, id ASC`
+
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list media servers: %w", err)
+	}
+	defer rows.Close()
+
+	var servers []*models.MediaServer
+	for rows.Next() {
+		server, err := scanMediaServer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan media server: %w", err)
+		}
+		servers = append(servers, server)
+	}
+
+	return servers, nil
+}
+
+// DeleteMediaServer removes a media server
+func (db *DB) DeleteMediaServer(id int64) error {
+	result, err := db.conn.Exec(`DELETE FROM media_servers WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete media server: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("media server not found")
+	}
+
+	return nil
+}
+
+// scanMediaServer reads a media server selected with mediaServerColumns
+func scanMediaServer(row rowScanner) (*models.MediaServer, error) {
+	var server models.MediaServer
+	err := row.Scan(
+		&server.ID, &server.Name, &server.Kind, &server.URL, &server.Token,
+		&server.PathPrefix, &server.RemotePrefix, &server.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_MediaServers(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	server := &models.MediaServer{
+		Name:         "Living room",
+		Kind:         models.MediaServerJellyfin,
+		URL:          "http://jellyfin:8096",
+		Token:        "api-key",
+		PathPrefix:   "/downloads/movies",
+		RemotePrefix: "/media/movies",
+		CreatedAt:    time.Now(),
+	}
+	require.NoError(t, db.CreateMediaServer(server))
+	require.NotZero(t, server.ID)
+
+	found, err := db.GetMediaServer(server.ID)
+	require.NoError(t, err)
+	require.Equal(t, "api-key", found.Token)
+	require.Equal(t, "/media/movies", found.RemotePrefix)
+
+	servers, err := db.ListMediaServers()
+	require.NoError(t, err)
+	require.Len(t, servers, 1)
+
+	require.NoError(t, db.DeleteMediaServer(server.ID))
+	require.Error(t, db.DeleteMediaServer(server.ID))
+	_, err = db.GetMediaServer(server.ID)
+	require.Error(t, err)
+}
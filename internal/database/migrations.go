@@ -0,0 +1,96 @@
+package database
+
+import (
+	"fmt"
+)
+
+// columnMigration adds a column to a table created by an earlier version of the schema
+type columnMigration struct {
+	table      string
+	column     string
+	definition string
+}
+
+// columnMigrations lists columns added after their table was first released, oldest first
+var columnMigrations = []columnMigration{
+	{"downloads", "owner_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"download_groups", "owner_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"directory_mappings", "owner_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"api_tokens", "owner_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"downloads", "hook_exit_code", "INTEGER"},
+	{"downloads", "hook_output", "TEXT NOT NULL DEFAULT ''"},
+	{"download_groups", "hook_exit_code", "INTEGER"},
+	{"download_groups", "hook_output", "TEXT NOT NULL DEFAULT ''"},
+	{"categories", "keywords", "TEXT NOT NULL DEFAULT ''"},
+	{"categories", "extract", "BOOLEAN NOT NULL DEFAULT 1"},
+	{"categories", "cleanup_profile", "TEXT NOT NULL DEFAULT 'video'"},
+	{"categories", "priority", "INTEGER NOT NULL DEFAULT 0"},
+	{"categories", "hook_id", "INTEGER NOT NULL DEFAULT 0"},
+	{"categories", "rename_template", "TEXT NOT NULL DEFAULT ''"},
+	{"downloads", "category", "TEXT NOT NULL DEFAULT ''"},
+	{"downloads", "priority", "INTEGER NOT NULL DEFAULT 0"},
+	{"downloads", "pinned", "BOOLEAN NOT NULL DEFAULT 0"},
+	{"downloads", "next_attempt_at", "DATETIME"},
+}
+
+// postMigrationSchema holds statements that depend on migrated columns
+const postMigrationSchema = `
+	CREATE INDEX IF NOT EXISTS idx_downloads_owner_id ON downloads(owner_id);
+	CREATE INDEX IF NOT EXISTS idx_directory_mappings_owner_id ON directory_mappings(owner_id);
+`
+
+// migrate brings tables created by older versions up to date
+func (db *DB) migrate() error {
+	for _, m := range columnMigrations {
+		exists, err := db.columnExists(m.table, m.column)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", m.table, m.column, m.definition)
+		if _, err := db.conn.Exec(query); err != nil {
+			return fmt.Errorf("failed to add column %s.%s: %w", m.table, m.column, err)
+		}
+	}
+
+	if _, err := db.conn.Exec(postMigrationSchema); err != nil {
+		return fmt.Errorf("failed to create indexes: %w", err)
+	}
+
+	if err := db.migrateDirectoryMappings(); err != nil {
+		return err
+	}
+
+	return db.initSearch()
+}
+
+// columnExists reports whether a table already has the given column
+func (db *DB) columnExists(table, column string) (bool, error) {
+	rows, err := db.conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    int
+			defaultVal any
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return false, fmt.Errorf("failed to scan column of %s: %w", table, err)
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
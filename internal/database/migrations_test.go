@@ -0,0 +1,64 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate_AddsOwnerColumns(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+
+	// A downloads table as created before owners existed
+	conn, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	_, err = conn.Exec(`
+	CREATE TABLE downloads (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		original_url TEXT NOT NULL,
+		unrestricted_url TEXT,
+		filename TEXT NOT NULL,
+		directory TEXT NOT NULL,
+		status TEXT NOT NULL,
+		progress REAL DEFAULT 0.0,
+		file_size INTEGER DEFAULT 0,
+		downloaded_bytes INTEGER DEFAULT 0,
+		download_speed REAL DEFAULT 0.0,
+		error_message TEXT,
+		retry_count INTEGER DEFAULT 0,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		started_at DATETIME,
+		completed_at DATETIME,
+		paused_at DATETIME,
+		total_paused_time INTEGER DEFAULT 0,
+		group_id TEXT,
+		is_archive BOOLEAN DEFAULT FALSE,
+		extracted_files TEXT
+	);
+	INSERT INTO downloads (original_url, unrestricted_url, filename, directory, status, error_message, created_at, updated_at, group_id, extracted_files)
+	VALUES ('https://example.com/old.zip', '', 'old.zip', '/downloads', 'completed', '', datetime('now'), datetime('now'), '', '');
+	`)
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	db, err := New(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+
+	exists, err := db.columnExists("downloads", "owner_id")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	download, err := db.GetDownload(1)
+	require.NoError(t, err)
+	require.Equal(t, "old.zip", download.Filename)
+	require.Zero(t, download.OwnerID)
+
+	// Downloads from before the search index are indexed when it is created
+	results, err := db.SearchDownloads("old", []string{"completed"}, "desc", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+}
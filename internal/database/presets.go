@@ -0,0 +1,90 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"debrid-downloader/pkg/models"
+)
+
+// SaveFilterPreset stores a filter preset, replacing the owner's preset with the same name
+func (db *DB) SaveFilterPreset(preset *models.FilterPreset) error {
+	query := `
+	INSERT INTO filter_presets (name, query, owner_id, created_at)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT (owner_id, name) DO UPDATE SET query = excluded.query
+	RETURNING id, created_at
+	`
+
+	err := db.conn.QueryRow(query, preset.Name, preset.Query, preset.OwnerID, preset.CreatedAt).
+		Scan(&preset.ID, &preset.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save filter preset: %w", err)
+	}
+
+	return nil
+}
+
+// GetFilterPreset retrieves a filter preset by ID
+func (db *DB) GetFilterPreset(id int64) (*models.FilterPreset, error) {
+	query := `
+	SELECT id, name, query, owner_id, created_at FROM filter_presets WHERE id = ?
+	`
+
+	var preset models.FilterPreset
+	err := db.conn.QueryRow(query, id).Scan(&preset.ID, &preset.Name, &preset.Query, &preset.OwnerID, &preset.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("filter preset not found")
+		}
+		return nil, fmt.Errorf("failed to get filter preset: %w", err)
+	}
+
+	return &preset, nil
+}
+
+// ListFilterPresets retrieves one user's filter presets by name. Presets are personal,
+// so admins only see their own.
+func (db *DB) ListFilterPresets(ownerID int64) ([]*models.FilterPreset, error) {
+	query := `
+	SELECT id, name, query, owner_id, created_at
+	FROM filter_presets
+	WHERE owner_id = ?
+	ORDER BY name COLLATE NOCASE, id
+	`
+
+	rows, err := db.conn.Query(query, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list filter presets: %w", err)
+	}
+	defer rows.Close()
+
+	var presets []*models.FilterPreset
+	for rows.Next() {
+		var preset models.FilterPreset
+		if err := rows.Scan(&preset.ID, &preset.Name, &preset.Query, &preset.OwnerID, &preset.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan filter preset: %w", err)
+		}
+		presets = append(presets, &preset)
+	}
+
+	return presets, nil
+}
+
+// DeleteFilterPreset removes a filter preset
+func (db *DB) DeleteFilterPreset(id int64) error {
+	result, err := db.conn.Exec(`DELETE FROM filter_presets WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete filter preset: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("filter preset not found")
+	}
+
+	return nil
+}
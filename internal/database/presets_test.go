@@ -0,0 +1,65 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_FilterPresets(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	user := &models.User{Username: "alice", Role: models.RoleUser, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(user))
+
+	failed := &models.FilterPreset{Name: "Failed", Query: "status=failed", OwnerID: user.ID, CreatedAt: time.Now()}
+	require.NoError(t, db.SaveFilterPreset(failed))
+	require.NotZero(t, failed.ID)
+	require.NoError(t, db.SaveFilterPreset(&models.FilterPreset{Name: "archives", Query: "archives=true", OwnerID: user.ID, CreatedAt: time.Now()}))
+	require.NoError(t, db.SaveFilterPreset(&models.FilterPreset{Name: "Failed", Query: "status=paused", OwnerID: 0, CreatedAt: time.Now()}))
+
+	// Saving under the same name replaces the preset
+	replaced := &models.FilterPreset{Name: "Failed", Query: "status=failed&host=example.com", OwnerID: user.ID, CreatedAt: time.Now()}
+	require.NoError(t, db.SaveFilterPreset(replaced))
+	require.Equal(t, failed.ID, replaced.ID)
+
+	presets, err := db.ListFilterPresets(user.ID)
+	require.NoError(t, err)
+	require.Len(t, presets, 2)
+	require.Equal(t, "archives", presets[0].Name)
+	require.Equal(t, "status=failed&host=example.com", presets[1].Query)
+
+	preset, err := db.GetFilterPreset(failed.ID)
+	require.NoError(t, err)
+	require.Equal(t, user.ID, preset.OwnerID)
+
+	require.NoError(t, db.DeleteFilterPreset(failed.ID))
+	require.EqualError(t, db.DeleteFilterPreset(failed.ID), "filter preset not found")
+	_, err = db.GetFilterPreset(failed.ID)
+	require.EqualError(t, err, "filter preset not found")
+
+	// Deleting a user deletes their presets
+	require.NoError(t, db.DeleteUser(user.ID))
+	presets, err = db.ListFilterPresets(user.ID)
+	require.NoError(t, err)
+	require.Empty(t, presets)
+
+	// The admin account takes over unowned presets unless it has one with the same name
+	admin, err := db.EnsureAdminUser("admin", "hash")
+	require.NoError(t, err)
+	presets, err = db.ListFilterPresets(admin.ID)
+	require.NoError(t, err)
+	require.Len(t, presets, 1)
+
+	require.NoError(t, db.SaveFilterPreset(&models.FilterPreset{Name: "Failed", Query: "status=failed", OwnerID: 0, CreatedAt: time.Now()}))
+	_, err = db.EnsureAdminUser("admin", "hash")
+	require.NoError(t, err)
+	presets, err = db.ListFilterPresets(0)
+	require.NoError(t, err)
+	require.Len(t, presets, 1)
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"debrid-downloader/pkg/models"
+)
+
+const ruleColumns = `id, condition, value, directory, position, created_at`
+
+// CreateRule stores a new routing rule after the existing ones
+func (db *DB) CreateRule(rule *models.Rule) error {
+	query := `
+	INSERT INTO rules (condition, value, directory, position, created_at)
+	VALUES (?, ?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM rules), ?)
+	`
+
+	result, err := db.conn.Exec(query, rule.Condition, rule.Value, rule.Directory, rule.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create rule: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	rule.ID = id
+	if err := db.conn.QueryRow("SELECT position FROM rules WHERE id = ?", id).Scan(&rule.Position); err != nil {
+		return fmt.Errorf("failed to get rule position: %w", err)
+	}
+	return nil
+}
+
+// GetRule retrieves a routing rule by ID
+func (db *DB) GetRule(id int64) (*models.Rule, error) {
+	query := `SELECT ` + ruleColumns + ` FROM rules WHERE id = ?`
+
+	rule, err := scanRule(db.conn.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("rule not found")
+		}
+		return nil, fmt.Errorf("failed to get rule: %w", err)
+	}
+
+	return rule, nil
+}
+
+// ListRules retrieves all routing rules in the order they are checked
+func (db *DB) ListRules() ([]*models.Rule, error) {
+	query := `SELECT ` + ruleColumns + ` FROM rules ORDER BY position ASC, id ASC`
+
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []*models.Rule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rule: %w", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// UpdateRule updates a routing rule's condition, value and directory
+func (db *DB) UpdateRule(rule *models.Rule) error {
+	query := `UPDATE rules SET condition = ?, value = ?, directory = ? WHERE id = ?`
+
+	if _, err := db.conn.Exec(query, rule.Condition, rule.Value, rule.Directory, rule.ID); err != nil {
+		return fmt.Errorf("failed to update rule: %w", err)
+	}
+
+	return nil
+}
+
+// MoveRule swaps a routing rule with the one before it, or after it when down is true.
+// Rules already first or last stay where they are.
+func (db *DB) MoveRule(id int64, down bool) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var position int
+	if err := tx.QueryRow("SELECT position FROM rules WHERE id = ?", id).Scan(&position); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("rule not found")
+		}
+		return fmt.Errorf("failed to get rule: %w", err)
+	}
+
+	query := "SELECT id, position FROM rules WHERE position < ? ORDER BY position DESC LIMIT 1"
+	if down {
+		query = "SELECT id, position FROM rules WHERE position > ? ORDER BY position ASC LIMIT 1"
+	}
+
+	var neighbourID int64
+	var neighbourPosition int
+	if err := tx.QueryRow(query, position).Scan(&neighbourID, &neighbourPosition); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("failed to get neighbouring rule: %w", err)
+	}
+
+	if _, err := tx.Exec("UPDATE rules SET position = ? WHERE id = ?", neighbourPosition, id); err != nil {
+		return fmt.Errorf("failed to move rule: %w", err)
+	}
+	if _, err := tx.Exec("UPDATE rules SET position = ? WHERE id = ?", position, neighbourID); err != nil {
+		return fmt.Errorf("failed to move rule: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRule removes a routing rule
+func (db *DB) DeleteRule(id int64) error {
+	if _, err := db.conn.Exec("DELETE FROM rules WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
+	}
+
+	return nil
+}
+
+// scanRule reads a rule selected with ruleColumns
+func scanRule(row rowScanner) (*models.Rule, error) {
+	var rule models.Rule
+	err := row.Scan(&rule.ID, &rule.Condition, &rule.Value, &rule.Directory, &rule.Position, &rule.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
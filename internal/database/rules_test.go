@@ -0,0 +1,60 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Rules(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var created []*models.Rule
+	for _, value := range []string{"one.com", "two.com", "three.com"} {
+		rule := &models.Rule{Condition: models.RuleHost, Value: value, Directory: value, CreatedAt: time.Now()}
+		require.NoError(t, db.CreateRule(rule))
+		require.NotZero(t, rule.ID)
+		created = append(created, rule)
+	}
+	require.Equal(t, 3, created[2].Position)
+
+	order := func() []string {
+		rules, err := db.ListRules()
+		require.NoError(t, err)
+		var values []string
+		for _, rule := range rules {
+			values = append(values, rule.Value)
+		}
+		return values
+	}
+
+	// Moving swaps with the neighbour, and the ends stay put
+	require.NoError(t, db.MoveRule(created[2].ID, false))
+	require.Equal(t, []string{"one.com", "three.com", "two.com"}, order())
+	require.NoError(t, db.MoveRule(created[0].ID, false))
+	require.NoError(t, db.MoveRule(created[1].ID, true))
+	require.Equal(t, []string{"one.com", "three.com", "two.com"}, order())
+	require.NoError(t, db.MoveRule(created[0].ID, true))
+	require.Equal(t, []string{"three.com", "one.com", "two.com"}, order())
+	require.Error(t, db.MoveRule(99, true))
+
+	created[1].Condition = models.RuleLargerThan
+	created[1].Value = "4GB"
+	created[1].Directory = "big"
+	require.NoError(t, db.UpdateRule(created[1]))
+	found, err := db.GetRule(created[1].ID)
+	require.NoError(t, err)
+	require.Equal(t, models.RuleLargerThan, found.Condition)
+	require.Equal(t, "4GB", found.Value)
+	require.Equal(t, "big", found.Directory)
+
+	require.NoError(t, db.DeleteRule(created[1].ID))
+	_, err = db.GetRule(created[1].ID)
+	require.Error(t, err)
+	require.Equal(t, []string{"three.com", "one.com"}, order())
+}
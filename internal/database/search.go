@@ -0,0 +1,165 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// searchSchema creates the full-text index over the download history. Its rowid is the
+// download's ID and triggers keep it in step with the downloads table.
+const searchSchema = `
+	CREATE VIRTUAL TABLE IF NOT EXISTS downloads_fts USING fts5(
+		name, url, dir, files,
+		tokenize = 'unicode61 remove_diacritics 2'
+	);
+
+	CREATE TRIGGER IF NOT EXISTS downloads_fts_insert AFTER INSERT ON downloads BEGIN
+		INSERT OR REPLACE INTO downloads_fts(rowid, name, url, dir, files)
+		VALUES (new.id, new.filename, new.original_url, new.directory, COALESCE(new.extracted_files, ''));
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS downloads_fts_update
+	AFTER UPDATE OF filename, original_url, directory, extracted_files ON downloads BEGIN
+		UPDATE downloads_fts
+		SET name = new.filename, url = new.original_url, dir = new.directory,
+			files = COALESCE(new.extracted_files, '')
+		WHERE rowid = new.id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS downloads_fts_delete AFTER DELETE ON downloads BEGIN
+		DELETE FROM downloads_fts WHERE rowid = old.id;
+	END;
+`
+
+// searchBackfill indexes the downloads missing from the full-text index, which is all of
+// them the first time
+const searchBackfill = `
+	INSERT INTO downloads_fts(rowid, name, url, dir, files)
+	SELECT id, filename, original_url, directory, COALESCE(extracted_files, '') FROM downloads
+	WHERE id NOT IN (SELECT rowid FROM downloads_fts);
+`
+
+// searchRank orders matches by relevance, weighing the filename above the directory,
+// extracted files and URL
+const searchRank = `bm25(downloads_fts, 10.0, 1.0, 4.0, 2.0)`
+
+// searchFields maps the field filters of a search to the index columns they match
+var searchFields = map[string]string{
+	"name":  "name",
+	"url":   "url",
+	"dir":   "dir",
+	"files": "files",
+}
+
+// initSearch creates whatever is missing of the full-text index and its triggers, and
+// indexes the downloads it doesn't have yet. It runs in one transaction on every start.
+func (db *DB) initSearch() error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(searchSchema); err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+	if _, err := tx.Exec(searchBackfill); err != nil {
+		return fmt.Errorf("failed to fill search index: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// searchQuery is a search box entry split into the full-text match and status filters
+type searchQuery struct {
+	match    string   // FTS5 query, empty to match every download
+	statuses []string // Statuses from status: filters, empty for any
+}
+
+// parseSearch turns a search box entry into a full-text query. Words match as prefixes
+// and "quoted phrases" as exact phrases, all of which must match. A word or phrase can
+// be limited to one field with name:, url:, dir: or files:, and status:failed (or
+// status:failed,paused) keeps only downloads with those statuses.
+func parseSearch(input string) searchQuery {
+	var query searchQuery
+	var terms []string
+
+	rest := strings.TrimSpace(input)
+	for rest != "" {
+		column := ""
+		if i := strings.IndexAny(rest, ": \t\""); i > 0 && rest[i] == ':' {
+			field := strings.ToLower(rest[:i])
+			if field == "status" {
+				var value string
+				value, rest = nextWord(rest[i+1:])
+				for _, status := range strings.Split(strings.ToLower(value), ",") {
+					if status != "" {
+						query.statuses = append(query.statuses, status)
+					}
+				}
+				continue
+			}
+			if name, ok := searchFields[field]; ok {
+				column = name
+				rest = rest[i+1:]
+			}
+		}
+
+		var term string
+		if strings.HasPrefix(rest, `"`) {
+			var phrase string
+			phrase, rest = nextPhrase(rest[1:])
+			if hasTokens(phrase) {
+				term = quoteTerm(phrase)
+			}
+		} else {
+			var word string
+			word, rest = nextWord(rest)
+			if word = strings.TrimRight(word, "*"); hasTokens(word) {
+				term = quoteTerm(word) + "*"
+			}
+		}
+
+		if term != "" {
+			if column != "" {
+				term = column + " : " + term
+			}
+			terms = append(terms, term)
+		}
+	}
+
+	query.match = strings.Join(terms, " ")
+	return query
+}
+
+// nextWord splits off the text up to the next space
+func nextWord(s string) (word, rest string) {
+	if i := strings.IndexFunc(s, unicode.IsSpace); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i:])
+	}
+	return s, ""
+}
+
+// nextPhrase splits off the text up to the closing quote, or the end if there is none
+func nextPhrase(s string) (phrase, rest string) {
+	if i := strings.Index(s, `"`); i >= 0 {
+		return s[:i], strings.TrimSpace(s[i+1:])
+	}
+	return s, ""
+}
+
+// hasTokens reports whether text has anything the index tokenizer keeps
+func hasTokens(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	}) >= 0
+}
+
+// quoteTerm quotes text as an FTS5 string so its punctuation isn't read as query syntax
+func quoteTerm(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSearch(t *testing.T) {
+	tests := []struct {
+		input    string
+		match    string
+		statuses []string
+	}{
+		{"", "", nil},
+		{"movie", `"movie"*`, nil},
+		{"  action  movie ", `"action"* "movie"*`, nil},
+		{`"action movie" 2024`, `"action movie" "2024"*`, nil},
+		{"mov*", `"mov"*`, nil},
+		{"dir:movies status:failed", `dir : "movies"*`, []string{"failed"}},
+		{"Status:Failed,paused", "", []string{"failed", "paused"}},
+		{`name:"the film" url:example`, `name : "the film" url : "example"*`, nil},
+		{"https://example.com/file", `"https://example.com/file"*`, nil},
+		{"foo:bar", `"foo:bar"*`, nil},
+		{`quote"d - ( ) NOT`, `"quote""d"* "NOT"*`, nil},
+		{`"unterminated phrase`, `"unterminated phrase"`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			query := parseSearch(tt.input)
+			require.Equal(t, tt.match, query.match)
+			require.Equal(t, tt.statuses, query.statuses)
+		})
+	}
+}
+
+func TestDB_SearchDownloads_FullText(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	create := func(filename, directory, url string, status models.DownloadStatus) *models.Download {
+		download := &models.Download{
+			OriginalURL: url,
+			Filename:    filename,
+			Directory:   directory,
+			Status:      status,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		require.NoError(t, db.CreateDownload(download))
+		return download
+	}
+
+	film := create("The.Great.Film.2024.1080p.mkv", "/downloads/movies", "https://host.example/abc", models.StatusCompleted)
+	create("Film.Soundtrack.flac", "/downloads/music", "https://host.example/ost", models.StatusFailed)
+	create("notes.txt", "/downloads/misc", "https://host.example/film-notes", models.StatusCompleted)
+	create("Great.Show.S01E01.mkv", "/downloads/tv", "https://host.example/xyz", models.StatusFailed)
+	archive := create("backup.rar", "/downloads/misc", "https://host.example/rar", models.StatusCompleted)
+
+	all := []string{"completed", "failed"}
+	search := func(term string) []string {
+		results, err := db.SearchDownloads(term, all, "desc", 10, 0)
+		require.NoError(t, err)
+		names := []string{}
+		for _, d := range results {
+			names = append(names, d.Filename)
+		}
+		return names
+	}
+
+	// Words match as prefixes and must all match
+	require.ElementsMatch(t, []string{film.Filename, "Great.Show.S01E01.mkv"}, search("gre"))
+	require.Equal(t, []string{film.Filename}, search("great film"))
+
+	// Phrases match consecutive words only
+	require.Equal(t, []string{film.Filename}, search(`"great film"`))
+	require.Empty(t, search(`"film great"`))
+
+	// Field filters limit a word to one field; status: limits the statuses
+	require.Equal(t, []string{film.Filename}, search("dir:movies"))
+	require.Equal(t, []string{"notes.txt"}, search("url:film"))
+	require.Equal(t, []string{"Great.Show.S01E01.mkv"}, search("great status:failed"))
+	require.ElementsMatch(t, []string{"Film.Soundtrack.flac", "Great.Show.S01E01.mkv"}, search("status:failed"))
+
+	// A status: filter can't widen the selected statuses
+	results, err := db.SearchDownloads("status:failed", []string{"completed"}, "desc", 10, 0)
+	require.NoError(t, err)
+	require.Empty(t, results)
+
+	// Filename matches rank above URL matches
+	ranked := search("film")
+	require.Len(t, ranked, 3)
+	require.Equal(t, "notes.txt", ranked[2])
+
+	// Query syntax typed into the box is searched for, not run
+	require.Empty(t, search(`NOT OR "`))
+	require.Len(t, search("(film*"), 3)
+
+	// The index follows renames, extracted files and deletes
+	require.NoError(t, db.UpdateDownloadLocation(film.ID, "/downloads/archive", "Renamed.mkv"))
+	require.Equal(t, []string{"Renamed.mkv"}, search("dir:archive"))
+	require.Empty(t, search("dir:movies"))
+
+	archive.ExtractedFiles = `["/downloads/misc/holiday/photo.jpg"]`
+	require.NoError(t, db.UpdateDownload(archive))
+	require.Equal(t, []string{"backup.rar"}, search("files:holiday"))
+
+	require.NoError(t, db.DeleteDownload(archive.ID))
+	require.Empty(t, search("holiday"))
+}
+
+func TestDB_InitSearch_Repeatable(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	download := &models.Download{
+		OriginalURL: "https://host.example/abc",
+		Filename:    "Indexed.Film.mkv",
+		Directory:   "/downloads/movies",
+		Status:      models.StatusCompleted,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(download))
+
+	// A start left with a missing trigger and row is repaired without duplicating the rest
+	_, err = db.conn.Exec(`DROP TRIGGER downloads_fts_delete; DELETE FROM downloads_fts`)
+	require.NoError(t, err)
+	require.NoError(t, db.initSearch())
+	require.NoError(t, db.initSearch())
+
+	var rows int
+	require.NoError(t, db.conn.QueryRow(`SELECT COUNT(*) FROM downloads_fts`).Scan(&rows))
+	require.Equal(t, 1, rows)
+
+	results, err := db.SearchDownloads("indexed", []string{"completed"}, "desc", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	require.NoError(t, db.DeleteDownload(download.ID))
+	require.NoError(t, db.conn.QueryRow(`SELECT COUNT(*) FROM downloads_fts`).Scan(&rows))
+	require.Equal(t, 0, rows)
+}
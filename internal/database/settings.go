@@ -0,0 +1,52 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// GetSettings retrieves the stored runtime settings by key
+func (db *DB) GetSettings() (map[string]string, error) {
+	rows, err := db.conn.Query(`SELECT key, value FROM settings`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get settings: %w", err)
+	}
+	defer rows.Close()
+
+	settings := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan setting: %w", err)
+		}
+		settings[key] = value
+	}
+
+	return settings, rows.Err()
+}
+
+// ReplaceSettings stores the runtime settings in one transaction, removing any not given
+func (db *DB) ReplaceSettings(settings map[string]string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM settings`); err != nil {
+		return fmt.Errorf("failed to clear settings: %w", err)
+	}
+
+	now := time.Now()
+	for key, value := range settings {
+		if _, err := tx.Exec(`INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)`, key, value, now); err != nil {
+			return fmt.Errorf("failed to store setting %s: %w", key, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Settings(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	settings, err := db.GetSettings()
+	require.NoError(t, err)
+	require.Empty(t, settings)
+
+	require.NoError(t, db.ReplaceSettings(map[string]string{"concurrency": "3", "speed_limit": "1048576"}))
+	settings, err = db.GetSettings()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"concurrency": "3", "speed_limit": "1048576"}, settings)
+
+	// Settings left out are removed
+	require.NoError(t, db.ReplaceSettings(map[string]string{"concurrency": "2"}))
+	settings, err = db.GetSettings()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"concurrency": "2"}, settings)
+}
@@ -0,0 +1,216 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"debrid-downloader/pkg/fuzzy"
+	"debrid-downloader/pkg/models"
+)
+
+// LearnDirectoryTokens strengthens the link between each token and a directory
+func (db *DB) LearnDirectoryTokens(ownerID int64, tokens []string, directory string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO directory_tokens (token, directory, weight, last_used, owner_id)
+	VALUES (?, ?, 1, ?, ?)
+	ON CONFLICT (owner_id, token, directory) DO UPDATE SET
+		weight = weight + 1, last_used = excluded.last_used
+	`
+
+	now := time.Now()
+	for _, token := range tokens {
+		if _, err := tx.Exec(query, token, directory, now, ownerID); err != nil {
+			return fmt.Errorf("failed to learn directory token: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// WeakenDirectoryTokens reduces the link between each token and a directory, without
+// going below zero
+func (db *DB) WeakenDirectoryTokens(ownerID int64, tokens []string, directory string, amount float64) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	query := `
+	UPDATE directory_tokens SET weight = MAX(weight - ?, 0)
+	WHERE owner_id = ? AND directory = ? AND token IN (` + placeholders(len(tokens)) + `)
+	`
+
+	args := []any{amount, ownerID, directory}
+	for _, token := range tokens {
+		args = append(args, token)
+	}
+
+	if _, err := db.conn.Exec(query, args...); err != nil {
+		return fmt.Errorf("failed to weaken directory tokens: %w", err)
+	}
+
+	return nil
+}
+
+// GetDirectoryTokens retrieves what was learned about the given tokens, for one owner
+// or everyone
+func (db *DB) GetDirectoryTokens(ownerID int64, tokens []string) ([]*models.DirectoryToken, error) {
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	query := `
+	SELECT id, token, directory, weight, last_used, owner_id
+	FROM directory_tokens
+	WHERE (? = 0 OR owner_id = ?) AND weight > 0 AND token IN (` + placeholders(len(tokens)) + `)
+	`
+
+	args := []any{ownerID, ownerID}
+	for _, token := range tokens {
+		args = append(args, token)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get directory tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var learned []*models.DirectoryToken
+	for rows.Next() {
+		var token models.DirectoryToken
+		if err := rows.Scan(&token.ID, &token.Token, &token.Directory, &token.Weight, &token.LastUsed, &token.OwnerID); err != nil {
+			return nil, fmt.Errorf("failed to scan directory token: %w", err)
+		}
+		learned = append(learned, &token)
+	}
+
+	return learned, nil
+}
+
+// CreateSuggestionFeedback records whether a suggested directory was kept
+func (db *DB) CreateSuggestionFeedback(feedback *models.SuggestionFeedback) error {
+	query := `
+	INSERT INTO suggestion_feedback (filename, suggested, chosen, accepted, owner_id, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := db.conn.Exec(query,
+		feedback.Filename, feedback.Suggested, feedback.Chosen,
+		feedback.Accepted, feedback.OwnerID, feedback.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create suggestion feedback: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	feedback.ID = id
+	return nil
+}
+
+// GetSuggestionAccuracy counts the suggestions that were kept and overridden, for one
+// owner or everyone
+func (db *DB) GetSuggestionAccuracy(ownerID int64) (accepted, overridden int, err error) {
+	query := `
+	SELECT COALESCE(SUM(accepted), 0), COALESCE(SUM(NOT accepted), 0)
+	FROM suggestion_feedback
+	WHERE (? = 0 OR owner_id = ?)
+	`
+
+	if err := db.conn.QueryRow(query, ownerID, ownerID).Scan(&accepted, &overridden); err != nil {
+		return 0, 0, fmt.Errorf("failed to get suggestion accuracy: %w", err)
+	}
+
+	return accepted, overridden, nil
+}
+
+// placeholders returns n comma-separated query placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// migrateDirectoryMappings learns the tokens of the links behind the pattern mappings
+// kept by older versions, weighted by how often each was used, and removes the mappings.
+// Mappings restored from an old backup are picked up on the next start.
+func (db *DB) migrateDirectoryMappings() error {
+	type mapping struct {
+		url       sql.NullString
+		directory string
+		useCount  int
+		lastUsed  time.Time
+		ownerID   int64
+	}
+
+	rows, err := db.conn.Query(`SELECT original_url, directory, use_count, last_used, owner_id FROM directory_mappings`)
+	if err != nil {
+		return fmt.Errorf("failed to read directory mappings: %w", err)
+	}
+	defer rows.Close()
+
+	var mappings []mapping
+	for rows.Next() {
+		var m mapping
+		if err := rows.Scan(&m.url, &m.directory, &m.useCount, &m.lastUsed, &m.ownerID); err != nil {
+			return fmt.Errorf("failed to scan directory mapping: %w", err)
+		}
+		mappings = append(mappings, m)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read directory mappings: %w", err)
+	}
+	if len(mappings) == 0 {
+		return nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO directory_tokens (token, directory, weight, last_used, owner_id)
+	VALUES (?, ?, ?, ?, ?)
+	ON CONFLICT (owner_id, token, directory) DO UPDATE SET
+		weight = weight + excluded.weight, last_used = MAX(last_used, excluded.last_used)
+	`
+
+	for _, m := range mappings {
+		filename := ""
+		if parsed, err := url.Parse(m.url.String); err == nil {
+			filename = path.Base(parsed.Path)
+		}
+		for _, token := range fuzzy.Tokens(filename, m.url.String) {
+			if _, err := tx.Exec(query, token, m.directory, max(m.useCount, 1), m.lastUsed, m.ownerID); err != nil {
+				return fmt.Errorf("failed to learn directory mapping: %w", err)
+			}
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM directory_mappings`); err != nil {
+		return fmt.Errorf("failed to remove directory mappings: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_DirectoryTokens(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	tokens := []string{"word:office", "ext:mkv"}
+	require.NoError(t, db.LearnDirectoryTokens(1, tokens, "/downloads/tv"))
+	require.NoError(t, db.LearnDirectoryTokens(1, tokens, "/downloads/tv"))
+	require.NoError(t, db.LearnDirectoryTokens(2, []string{"ext:mkv"}, "/downloads/movies"))
+
+	learned, err := db.GetDirectoryTokens(1, []string{"word:office", "ext:mkv", "word:other"})
+	require.NoError(t, err)
+	require.Len(t, learned, 2)
+	for _, token := range learned {
+		require.Equal(t, "/downloads/tv", token.Directory)
+		require.Equal(t, 2.0, token.Weight)
+	}
+
+	// Everyone's tokens are returned for all owners
+	learned, err = db.GetDirectoryTokens(AllOwners, []string{"ext:mkv"})
+	require.NoError(t, err)
+	require.Len(t, learned, 2)
+
+	// Weakening stops at zero, and tokens with no weight left are ignored
+	require.NoError(t, db.WeakenDirectoryTokens(1, []string{"word:office"}, "/downloads/tv", 0.5))
+	learned, err = db.GetDirectoryTokens(1, []string{"word:office"})
+	require.NoError(t, err)
+	require.Equal(t, 1.5, learned[0].Weight)
+
+	require.NoError(t, db.WeakenDirectoryTokens(1, []string{"word:office"}, "/downloads/tv", 5))
+	learned, err = db.GetDirectoryTokens(1, []string{"word:office"})
+	require.NoError(t, err)
+	require.Empty(t, learned)
+}
+
+func TestDB_SuggestionFeedback(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	for i, chosen := range []string{"/downloads/tv", "/downloads/tv", "/downloads/anime"} {
+		feedback := &models.SuggestionFeedback{
+			Filename:  "show.mkv",
+			Suggested: "/downloads/tv",
+			Chosen:    chosen,
+			Accepted:  chosen == "/downloads/tv",
+			OwnerID:   int64(i % 2),
+			CreatedAt: time.Now(),
+		}
+		require.NoError(t, db.CreateSuggestionFeedback(feedback))
+		require.NotZero(t, feedback.ID)
+	}
+
+	accepted, overridden, err := db.GetSuggestionAccuracy(AllOwners)
+	require.NoError(t, err)
+	require.Equal(t, 2, accepted)
+	require.Equal(t, 1, overridden)
+
+	accepted, overridden, err = db.GetSuggestionAccuracy(1)
+	require.NoError(t, err)
+	require.Equal(t, 1, accepted)
+	require.Equal(t, 0, overridden)
+}
+
+func TestDB_MigrateDirectoryMappings(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	lastUsed := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	require.NoError(t, db.CreateDirectoryMapping(&models.DirectoryMapping{
+		FilenamePattern: ".mkv",
+		OriginalURL:     "https://host.example/Heat.1995.1080p.mkv",
+		Directory:       "/downloads/movies",
+		UseCount:        3,
+		LastUsed:        lastUsed,
+		CreatedAt:       lastUsed,
+		OwnerID:         1,
+	}))
+	require.NoError(t, db.CreateDirectoryMapping(&models.DirectoryMapping{
+		FilenamePattern: "movie",
+		Directory:       "/downloads/movies",
+		UseCount:        1,
+		LastUsed:        lastUsed,
+		CreatedAt:       lastUsed,
+	}))
+
+	require.NoError(t, db.migrateDirectoryMappings())
+
+	learned, err := db.GetDirectoryTokens(1, []string{"word:heat", "ext:mkv", "host:host.example"})
+	require.NoError(t, err)
+	require.Len(t, learned, 3)
+	for _, token := range learned {
+		require.Equal(t, "/downloads/movies", token.Directory)
+		require.Equal(t, 3.0, token.Weight)
+		require.True(t, lastUsed.Equal(token.LastUsed))
+	}
+
+	// The mappings are gone, so the next start has nothing left to migrate
+	mappings, err := db.GetDirectoryMappings()
+	require.NoError(t, err)
+	require.Empty(t, mappings)
+	require.NoError(t, db.migrateDirectoryMappings())
+}
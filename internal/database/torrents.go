@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"debrid-downloader/pkg/models"
+)
+
+// torrentColumns lists the torrent columns in the order scanTorrent reads them
+const torrentColumns = `hash, name, magnet_id, category, directory, size, status,
+	error_message, group_id, owner_id, added_at, updated_at`
+
+// scanTorrent reads a torrent row selected with torrentColumns
+func scanTorrent(row rowScanner) (*models.Torrent, error) {
+	var torrent models.Torrent
+	err := row.Scan(
+		&torrent.Hash, &torrent.Name, &torrent.MagnetID, &torrent.Category,
+		&torrent.Directory, &torrent.Size, &torrent.Status, &torrent.ErrorMessage,
+		&torrent.GroupID, &torrent.OwnerID, &torrent.AddedAt, &torrent.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &torrent, nil
+}
+
+// CreateTorrent stores a torrent added through the debrid provider
+func (db *DB) CreateTorrent(torrent *models.Torrent) error {
+	query := `INSERT INTO torrents (` + torrentColumns + `) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	_, err := db.conn.Exec(query,
+		torrent.Hash, torrent.Name, torrent.MagnetID, torrent.Category,
+		torrent.Directory, torrent.Size, torrent.Status, torrent.ErrorMessage,
+		torrent.GroupID, torrent.OwnerID, torrent.AddedAt, torrent.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create torrent: %w", err)
+	}
+
+	return nil
+}
+
+// GetTorrent retrieves a torrent by its info hash
+func (db *DB) GetTorrent(hash string) (*models.Torrent, error) {
+	query := `SELECT ` + torrentColumns + ` FROM torrents WHERE hash = ?`
+
+	torrent, err := scanTorrent(db.conn.QueryRow(query, hash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("torrent not found")
+		}
+		return nil, fmt.Errorf("failed to get torrent: %w", err)
+	}
+
+	return torrent, nil
+}
+
+// ListTorrents retrieves torrents, oldest first, optionally limited to one owner and category
+func (db *DB) ListTorrents(ownerID int64, category string) ([]*models.Torrent, error) {
+	query := `SELECT ` + torrentColumns + ` FROM torrents
+	WHERE (? = 0 OR owner_id = ?) AND (? = '' OR category = ?)
+	ORDER BY added_at ASC`
+
+	return db.queryTorrents(query, ownerID, ownerID, category, category)
+}
+
+// ListTorrentsByStatus retrieves torrents in the given status, oldest first
+func (db *DB) ListTorrentsByStatus(status models.TorrentStatus) ([]*models.Torrent, error) {
+	query := `SELECT ` + torrentColumns + ` FROM torrents WHERE status = ? ORDER BY added_at ASC`
+
+	return db.queryTorrents(query, status)
+}
+
+// queryTorrents runs a query returning torrent rows
+func (db *DB) queryTorrents(query string, args ...any) ([]*models.Torrent, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list torrents: %w", err)
+	}
+	defer rows.Close()
+
+	var torrents []*models.Torrent
+	for rows.Next() {
+		torrent, err := scanTorrent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan torrent: %w", err)
+		}
+		torrents = append(torrents, torrent)
+	}
+
+	return torrents, nil
+}
+
+// UpdateTorrent updates a torrent's state
+func (db *DB) UpdateTorrent(torrent *models.Torrent) error {
+	query := `
+	UPDATE torrents SET
+		name = ?, category = ?, directory = ?, size = ?, status = ?,
+		error_message = ?, group_id = ?, updated_at = ?
+	WHERE hash = ?
+	`
+
+	_, err := db.conn.Exec(query,
+		torrent.Name, torrent.Category, torrent.Directory, torrent.Size, torrent.Status,
+		torrent.ErrorMessage, torrent.GroupID, torrent.UpdatedAt, torrent.Hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update torrent: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteTorrent removes a torrent record. Its downloads are left alone.
+func (db *DB) DeleteTorrent(hash string) error {
+	if _, err := db.conn.Exec("DELETE FROM torrents WHERE hash = ?", hash); err != nil {
+		return fmt.Errorf("failed to delete torrent: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Torrents(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	for i, hash := range []string{"aaa", "bbb", "ccc"} {
+		require.NoError(t, db.CreateTorrent(&models.Torrent{
+			Hash:      hash,
+			Name:      "Torrent " + hash,
+			MagnetID:  int64(i + 1),
+			Category:  []string{"tv", "tv", "movies"}[i],
+			Directory: "/downloads",
+			Status:    models.TorrentStatusQueued,
+			OwnerID:   []int64{1, 2, 1}[i],
+			AddedAt:   now.Add(time.Duration(i) * time.Second),
+			UpdatedAt: now,
+		}))
+	}
+
+	// Hashes are unique
+	require.Error(t, db.CreateTorrent(&models.Torrent{Hash: "aaa", AddedAt: now, UpdatedAt: now}))
+
+	torrents, err := db.ListTorrents(AllOwners, "tv")
+	require.NoError(t, err)
+	require.Len(t, torrents, 2)
+	require.Equal(t, "aaa", torrents[0].Hash)
+
+	torrents, err = db.ListTorrents(1, "")
+	require.NoError(t, err)
+	require.Len(t, torrents, 2)
+
+	torrent, err := db.GetTorrent("bbb")
+	require.NoError(t, err)
+	torrent.Status = models.TorrentStatusDownloading
+	torrent.GroupID = "group-1"
+	require.NoError(t, db.UpdateTorrent(torrent))
+
+	queued, err := db.ListTorrentsByStatus(models.TorrentStatusQueued)
+	require.NoError(t, err)
+	require.Len(t, queued, 2)
+
+	torrent, err = db.GetTorrent("bbb")
+	require.NoError(t, err)
+	require.Equal(t, "group-1", torrent.GroupID)
+
+	require.NoError(t, db.DeleteTorrent("bbb"))
+	_, err = db.GetTorrent("bbb")
+	require.Error(t, err)
+}
+
+func TestDB_Categories(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	tv := &models.Category{Name: "tv", Directory: "TV", CreatedAt: time.Now()}
+	require.NoError(t, db.CreateCategory(tv))
+	require.NotZero(t, tv.ID)
+	require.NoError(t, db.CreateCategory(&models.Category{Name: "movies", CreatedAt: time.Now()}))
+
+	// Names are unique
+	require.Error(t, db.CreateCategory(&models.Category{Name: "tv", CreatedAt: time.Now()}))
+
+	categories, err := db.ListCategories()
+	require.NoError(t, err)
+	require.Len(t, categories, 2)
+	require.Equal(t, "movies", categories[0].Name)
+
+	tv.Directory = "Shows"
+	tv.RenameTemplate = "{show}/{show} - {episode}.{ext}"
+	require.NoError(t, db.UpdateCategory(tv))
+	found, err := db.GetCategoryByName("tv")
+	require.NoError(t, err)
+	require.Equal(t, "Shows", found.Directory)
+	require.Equal(t, tv.RenameTemplate, found.RenameTemplate)
+
+	require.NoError(t, db.DeleteCategory("tv"))
+	_, err = db.GetCategoryByName("tv")
+	require.Error(t, err)
+}
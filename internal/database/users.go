@@ -0,0 +1,172 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"debrid-downloader/pkg/models"
+)
+
+// ownedTables lists the tables whose rows carry an owner_id
+var ownedTables = []string{"downloads", "download_groups", "directory_mappings", "api_tokens", "torrents", "webhooks", "filter_presets"}
+
+// CreateUser creates a new user account
+func (db *DB) CreateUser(user *models.User) error {
+	query := `
+	INSERT INTO users (username, password_hash, role, folder, created_at)
+	VALUES (?, ?, ?, ?, ?)
+	`
+
+	result, err := db.conn.Exec(query,
+		user.Username, user.PasswordHash, user.Role, user.Folder, user.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	user.ID = id
+	return nil
+}
+
+// GetUser retrieves a user by ID
+func (db *DB) GetUser(id int64) (*models.User, error) {
+	query := `
+	SELECT id, username, password_hash, role, folder, created_at
+	FROM users WHERE id = ?
+	`
+
+	return db.getUser(query, id)
+}
+
+// GetUserByUsername retrieves a user by username
+func (db *DB) GetUserByUsername(username string) (*models.User, error) {
+	query := `
+	SELECT id, username, password_hash, role, folder, created_at
+	FROM users WHERE username = ?
+	`
+
+	return db.getUser(query, username)
+}
+
+// getUser runs a query returning a single user
+func (db *DB) getUser(query string, arg any) (*models.User, error) {
+	var user models.User
+	err := db.conn.QueryRow(query, arg).Scan(
+		&user.ID, &user.Username, &user.PasswordHash,
+		&user.Role, &user.Folder, &user.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return &user, nil
+}
+
+// ListUsers retrieves all users ordered by username
+func (db *DB) ListUsers() ([]*models.User, error) {
+	query := `
+	SELECT id, username, password_hash, role, folder, created_at
+	FROM users
+	ORDER BY username ASC
+	`
+
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		var user models.User
+		err := rows.Scan(
+			&user.ID, &user.Username, &user.PasswordHash,
+			&user.Role, &user.Folder, &user.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, &user)
+	}
+
+	return users, nil
+}
+
+// UpdateUser updates a user's password hash, role and folder
+func (db *DB) UpdateUser(user *models.User) error {
+	query := `
+	UPDATE users SET password_hash = ?, role = ?, folder = ? WHERE id = ?
+	`
+
+	_, err := db.conn.Exec(query, user.PasswordHash, user.Role, user.Folder, user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUser removes a user account. Their downloads are kept and remain visible to admins.
+func (db *DB) DeleteUser(id int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE api_tokens SET revoked_at = ? WHERE owner_id = ? AND revoked_at IS NULL", time.Now(), id); err != nil {
+		return fmt.Errorf("failed to revoke user API tokens: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM filter_presets WHERE owner_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete user filter presets: %w", err)
+	}
+
+	if _, err := tx.Exec("DELETE FROM users WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// EnsureAdminUser creates or updates the admin account configured through the environment,
+// then assigns any records created before accounts existed to it
+func (db *DB) EnsureAdminUser(username, passwordHash string) (*models.User, error) {
+	user, err := db.GetUserByUsername(username)
+	if err != nil {
+		user = &models.User{
+			Username:     username,
+			PasswordHash: passwordHash,
+			Role:         models.RoleAdmin,
+			CreatedAt:    time.Now(),
+		}
+		if err := db.CreateUser(user); err != nil {
+			return nil, err
+		}
+	} else if user.PasswordHash != passwordHash || user.Role != models.RoleAdmin {
+		user.PasswordHash = passwordHash
+		user.Role = models.RoleAdmin
+		if err := db.UpdateUser(user); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, table := range ownedTables {
+		// OR IGNORE leaves a preset unowned when the admin has one with the same name
+		query := fmt.Sprintf("UPDATE OR IGNORE %s SET owner_id = ? WHERE owner_id = 0", table)
+		if _, err := db.conn.Exec(query, user.ID); err != nil {
+			return nil, fmt.Errorf("failed to assign unowned %s: %w", table, err)
+		}
+	}
+
+	return user, nil
+}
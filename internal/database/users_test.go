@@ -0,0 +1,129 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Users(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	alice := &models.User{
+		Username:     "alice",
+		PasswordHash: "hash",
+		Role:         models.RoleUser,
+		Folder:       "alice",
+		CreatedAt:    time.Now(),
+	}
+	require.NoError(t, db.CreateUser(alice))
+	require.NotZero(t, alice.ID)
+
+	// Usernames are unique
+	duplicate := *alice
+	require.Error(t, db.CreateUser(&duplicate))
+
+	found, err := db.GetUserByUsername("alice")
+	require.NoError(t, err)
+	require.Equal(t, alice.ID, found.ID)
+	require.Equal(t, "alice", found.Folder)
+	require.False(t, found.IsAdmin())
+
+	found.Role = models.RoleAdmin
+	found.Folder = ""
+	require.NoError(t, db.UpdateUser(found))
+	found, err = db.GetUser(alice.ID)
+	require.NoError(t, err)
+	require.True(t, found.IsAdmin())
+	require.Empty(t, found.Folder)
+
+	token := &models.APIToken{Name: "script", Prefix: "dd_1", TokenHash: "hash-1", Scope: models.ScopeRead, CreatedAt: time.Now(), OwnerID: alice.ID}
+	require.NoError(t, db.CreateAPIToken(token))
+
+	require.NoError(t, db.DeleteUser(alice.ID))
+	_, err = db.GetUser(alice.ID)
+	require.Error(t, err)
+
+	// Deleting a user revokes their tokens
+	token, err = db.GetAPIToken(token.ID)
+	require.NoError(t, err)
+	require.True(t, token.Revoked())
+
+	users, err := db.ListUsers()
+	require.NoError(t, err)
+	require.Empty(t, users)
+}
+
+func TestDB_EnsureAdminUser(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// A download from before accounts existed
+	download := &models.Download{
+		OriginalURL: "https://example.com/old.zip",
+		Filename:    "old.zip",
+		Directory:   "/downloads",
+		Status:      models.StatusCompleted,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(download))
+
+	admin, err := db.EnsureAdminUser("admin", "hash-1")
+	require.NoError(t, err)
+	require.True(t, admin.IsAdmin())
+
+	download, err = db.GetDownload(download.ID)
+	require.NoError(t, err)
+	require.Equal(t, admin.ID, download.OwnerID)
+
+	// Running again updates the password without creating another account
+	again, err := db.EnsureAdminUser("admin", "hash-2")
+	require.NoError(t, err)
+	require.Equal(t, admin.ID, again.ID)
+
+	found, err := db.GetUser(admin.ID)
+	require.NoError(t, err)
+	require.Equal(t, "hash-2", found.PasswordHash)
+}
+
+func TestDB_DownloadsByOwner(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, ownerID := range []int64{1, 1, 2} {
+		require.NoError(t, db.CreateDownload(&models.Download{
+			OriginalURL: "https://example.com/file.zip",
+			Filename:    "file.zip",
+			Directory:   "/downloads",
+			Status:      models.StatusPending,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+			OwnerID:     ownerID,
+		}))
+	}
+
+	downloads, err := db.ListDownloadsByOwner(1, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 2)
+
+	downloads, err = db.SearchDownloadsByOwner(2, "", []string{"pending"}, "desc", 10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 1)
+	require.Equal(t, int64(2), downloads[0].OwnerID)
+
+	downloads, err = db.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 3)
+
+	stats, err := db.GetDownloadStatsByOwner(1)
+	require.NoError(t, err)
+	require.Equal(t, 2, stats[string(models.StatusPending)])
+}
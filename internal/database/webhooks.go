@@ -0,0 +1,222 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"debrid-downloader/pkg/models"
+)
+
+const webhookColumns = `id, url, secret, events, enabled, owner_id, created_at`
+
+const webhookDeliveryColumns = `id, webhook_id, event, payload, status, attempts, response_code,
+		error, next_attempt_at, created_at, delivered_at`
+
+// CreateWebhook stores a new webhook
+func (db *DB) CreateWebhook(webhook *models.Webhook) error {
+	query := `
+	INSERT INTO webhooks (url, secret, events, enabled, owner_id, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := db.conn.Exec(query,
+		webhook.URL, webhook.Secret, webhook.Events, webhook.Enabled, webhook.OwnerID, webhook.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	webhook.ID = id
+	return nil
+}
+
+// GetWebhook retrieves a webhook by ID
+func (db *DB) GetWebhook(id int64) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = ?`
+
+	webhook, err := scanWebhook(db.conn.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("webhook not found")
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// ListWebhooks retrieves all webhooks, oldest first
+func (db *DB) ListWebhooks() ([]*models.Webhook, error) {
+	return db.ListWebhooksByOwner(AllOwners)
+}
+
+// ListWebhooksByOwner retrieves one user's webhooks, oldest first
+func (db *DB) ListWebhooksByOwner(ownerID int64) ([]*models.Webhook, error) {
+	query := `
+	SELECT ` + webhookColumns + `
+	FROM webhooks
+	WHERE (? = 0 OR owner_id = ?)
+	ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := db.conn.Query(query, ownerID, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var webhooks []*models.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func (db *DB) DeleteWebhook(id int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+	}
+
+	result, err := tx.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+
+	return tx.Commit()
+}
+
+// CreateWebhookDelivery queues an event for delivery to a webhook
+func (db *DB) CreateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+	INSERT INTO webhook_deliveries (
+		webhook_id, event, payload, status, attempts, response_code,
+		error, next_attempt_at, created_at, delivered_at
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	result, err := db.conn.Exec(query,
+		delivery.WebhookID, delivery.Event, delivery.Payload, delivery.Status, delivery.Attempts,
+		delivery.ResponseCode, delivery.Error, delivery.NextAttemptAt, delivery.CreatedAt, delivery.DeliveredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get last insert id: %w", err)
+	}
+
+	delivery.ID = id
+	return nil
+}
+
+// UpdateWebhookDelivery records the outcome of a delivery attempt
+func (db *DB) UpdateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	query := `
+	UPDATE webhook_deliveries SET
+		status = ?, attempts = ?, response_code = ?, error = ?,
+		next_attempt_at = ?, delivered_at = ?
+	WHERE id = ?
+	`
+
+	_, err := db.conn.Exec(query,
+		delivery.Status, delivery.Attempts, delivery.ResponseCode, delivery.Error,
+		delivery.NextAttemptAt, delivery.DeliveredAt, delivery.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// ListDueWebhookDeliveries retrieves pending deliveries whose next attempt is due
+func (db *DB) ListDueWebhookDeliveries(now time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+	SELECT ` + webhookDeliveryColumns + `
+	FROM webhook_deliveries
+	WHERE status = ? AND next_attempt_at <= ?
+	ORDER BY next_attempt_at ASC, id ASC
+	LIMIT ?
+	`
+
+	return db.queryWebhookDeliveries(query, models.DeliveryPending, now, limit)
+}
+
+// ListWebhookDeliveries retrieves the most recent deliveries for a webhook
+func (db *DB) ListWebhookDeliveries(webhookID int64, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+	SELECT ` + webhookDeliveryColumns + `
+	FROM webhook_deliveries
+	WHERE webhook_id = ?
+	ORDER BY created_at DESC, id DESC
+	LIMIT ?
+	`
+
+	return db.queryWebhookDeliveries(query, webhookID, limit)
+}
+
+func (db *DB) queryWebhookDeliveries(query string, args ...any) ([]*models.WebhookDelivery, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		err := rows.Scan(
+			&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.Payload,
+			&delivery.Status, &delivery.Attempts, &delivery.ResponseCode, &delivery.Error,
+			&delivery.NextAttemptAt, &delivery.CreatedAt, &delivery.DeliveredAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return deliveries, nil
+}
+
+// scanWebhook reads a webhook selected with webhookColumns
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	var webhook models.Webhook
+	err := row.Scan(
+		&webhook.ID, &webhook.URL, &webhook.Secret, &webhook.Events,
+		&webhook.Enabled, &webhook.OwnerID, &webhook.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDB_Webhooks(t *testing.T) {
+	db, err := New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	webhook := &models.Webhook{
+		URL:       "https://hooks.example.com/debrid",
+		Secret:    "secret",
+		Events:    "download.completed,group.completed",
+		Enabled:   true,
+		OwnerID:   2,
+		CreatedAt: time.Now(),
+	}
+	require.NoError(t, db.CreateWebhook(webhook))
+	require.NotZero(t, webhook.ID)
+
+	found, err := db.GetWebhook(webhook.ID)
+	require.NoError(t, err)
+	require.Equal(t, "secret", found.Secret)
+	require.True(t, found.Wants("group.completed"))
+	require.False(t, found.Wants("download.queued"))
+
+	_, err = db.GetWebhook(999)
+	require.Error(t, err)
+
+	owned, err := db.ListWebhooksByOwner(2)
+	require.NoError(t, err)
+	require.Len(t, owned, 1)
+
+	others, err := db.ListWebhooksByOwner(3)
+	require.NoError(t, err)
+	require.Empty(t, others)
+
+	now := time.Now().UTC()
+	due := &models.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		Event:         "download.completed",
+		Payload:       `{"event":"download.completed"}`,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: now.Add(-time.Second),
+		CreatedAt:     now,
+	}
+	require.NoError(t, db.CreateWebhookDelivery(due))
+
+	later := *due
+	later.NextAttemptAt = now.Add(time.Hour)
+	require.NoError(t, db.CreateWebhookDelivery(&later))
+
+	pending, err := db.ListDueWebhookDeliveries(now, 10)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, due.ID, pending[0].ID)
+
+	deliveredAt := now
+	due.Status = models.DeliveryDelivered
+	due.Attempts = 1
+	due.ResponseCode = 204
+	due.DeliveredAt = &deliveredAt
+	require.NoError(t, db.UpdateWebhookDelivery(due))
+
+	pending, err = db.ListDueWebhookDeliveries(now, 10)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	log, err := db.ListWebhookDeliveries(webhook.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+
+	require.NoError(t, db.DeleteWebhook(webhook.ID))
+	require.Error(t, db.DeleteWebhook(webhook.ID))
+
+	log, err = db.ListWebhookDeliveries(webhook.ID, 10)
+	require.NoError(t, err)
+	require.Empty(t, log)
+}
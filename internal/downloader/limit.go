@@ -0,0 +1,59 @@
+package downloader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter shares a download speed limit between all downloads in progress. It is a
+// token bucket holding up to one second of transfer; reads take their bytes from it,
+// and a read the bucket can't cover waits until the deficit has been refilled.
+type rateLimiter struct {
+	mu     sync.Mutex
+	limit  int64 // Bytes per second, 0 for unlimited
+	tokens float64
+	last   time.Time
+}
+
+// setLimit changes the limit, taking effect for the next read
+func (l *rateLimiter) setLimit(limit int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = max(limit, 0)
+	l.tokens = 0
+	l.last = time.Now()
+}
+
+// wait takes n bytes from the bucket and blocks until the limit allows them, or the
+// context is done
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	if l.limit == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	rate := float64(l.limit)
+	l.tokens = min(l.tokens+now.Sub(l.last).Seconds()*rate, rate)
+	l.last = now
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(deficit / rate * float64(time.Second)))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
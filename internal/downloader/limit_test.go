@@ -0,0 +1,33 @@
+package downloader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := &rateLimiter{}
+
+	// Unlimited reads never wait
+	start := time.Now()
+	for range 100 {
+		require.NoError(t, limiter.wait(context.Background(), 1<<20))
+	}
+	require.Less(t, time.Since(start), 50*time.Millisecond)
+
+	// The bucket starts empty, so 30 KB at 100 KB/s takes about 300ms
+	limiter.setLimit(100 * 1024)
+	start = time.Now()
+	for range 3 {
+		require.NoError(t, limiter.wait(context.Background(), 10*1024))
+	}
+	require.GreaterOrEqual(t, time.Since(start), 250*time.Millisecond)
+
+	// Waiting stops when the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, limiter.wait(ctx, 1<<20), context.Canceled)
+}
@@ -0,0 +1,225 @@
+package downloader
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+)
+
+// ErrorClass is the kind of failure a download attempt ended with, which decides how it
+// is retried
+type ErrorClass string
+
+// Error classes
+const (
+	ClassNetwork   ErrorClass = "network"    // Connection problems and timeouts
+	ClassServer    ErrorClass = "server"     // HTTP 5xx responses
+	ClassClient    ErrorClass = "client"     // HTTP 4xx responses other than the ones below
+	ClassExpired   ErrorClass = "expired"    // The link expired or is gone (HTTP 403, 404 or 410, or the provider's dead link)
+	ClassRateLimit ErrorClass = "rate_limit" // The rate limit of the provider or host (HTTP 429, or 503 with Retry-After)
+	ClassQuota     ErrorClass = "quota"      // The provider's or host's download quota ran out (HTTP 509)
+	ClassDisk      ErrorClass = "disk"       // Writing the file failed
+)
+
+// AllDebrid error codes for a link that can't be unlocked, by what they mean for a retry
+var (
+	quotaCodes     = []string{"LINK_HOST_LIMIT_REACHED", "LINK_TOO_MANY_DOWNLOADS", "LINK_HOST_FULL", "FREE_TRIAL_LIMIT_REACHED"}
+	deadLinkCodes  = []string{"LINK_DOWN"}
+	temporaryCodes = []string{"LINK_HOST_UNAVAILABLE", "LINK_TEMPORARY_UNAVAILABLE", "LINK_ERROR"}
+)
+
+// RetryPolicy says how often and how long to wait before retrying a class of errors
+type RetryPolicy struct {
+	MaxRetries int           // Retries before the download fails, negative for no limit
+	Backoff    time.Duration // Wait before the first retry, doubled for each one after
+	MaxDelay   time.Duration // Longest wait between attempts, 0 for no cap
+	Jitter     float64       // Fraction of each wait picked at random, from 0 to 1
+	MaxWait    time.Duration // Longest total wait before the download fails, 0 for no limit
+	Requeue    bool          // Wait in the backlog instead of holding a place among the active downloads
+}
+
+// delay returns the wait before the given retry, counting from 0. A Retry-After from the
+// server is waited out in full when it is longer.
+func (p RetryPolicy) delay(retry int, retryAfter time.Duration) time.Duration {
+	delay := p.Backoff << min(retry, 30)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		jitter := min(p.Jitter, 1)
+		delay = time.Duration(float64(delay) * (1 - jitter + 2*jitter*rand.Float64()))
+		if p.MaxDelay > 0 {
+			delay = min(delay, p.MaxDelay)
+		}
+	}
+
+	return max(delay, retryAfter)
+}
+
+// retryPolicy returns the policy for a class of errors. Network and server errors use the
+// configured retries; an expired link is unlocked again once; a rate limit or quota is
+// waited out in the backlog for up to RateLimitWait.
+func (o Options) retryPolicy(class ErrorClass) RetryPolicy {
+	policy := RetryPolicy{
+		MaxRetries: o.MaxRetries,
+		Backoff:    o.RetryBackoff,
+		MaxDelay:   o.RetryMaxDelay,
+		Jitter:     o.RetryJitter,
+	}
+
+	switch class {
+	case ClassClient:
+		// Asking again gets the same answer
+		policy.MaxRetries = 0
+	case ClassExpired:
+		// Retried straight away with a fresh link from the provider
+		policy.MaxRetries = min(o.MaxRetries, 1)
+		policy.Backoff = 0
+		policy.MaxDelay = 0
+		policy.Jitter = 0
+	case ClassDisk:
+		// A full disk may be cleared, but other disk errors need someone to step in
+		policy.MaxRetries = min(o.MaxRetries, 2)
+		policy.Backoff = 10 * o.RetryBackoff
+	case ClassRateLimit, ClassQuota:
+		if o.RateLimitWait > 0 {
+			// Waiting out a rate limit can take hours, so other downloads go first
+			policy.MaxRetries = -1
+			policy.MaxWait = o.RateLimitWait
+			policy.Requeue = true
+		}
+	}
+	return policy
+}
+
+// retryState counts a download's attempts, and its retries and waits by class of error.
+// It is kept by the worker while the download waits in the backlog.
+type retryState struct {
+	attempts int
+	retries  map[ErrorClass]int
+	waited   map[ErrorClass]time.Duration
+	unlock   bool // The next attempt unlocks the original link with the provider first
+}
+
+func newRetryState() *retryState {
+	return &retryState{
+		retries: make(map[ErrorClass]int),
+		waited:  make(map[ErrorClass]time.Duration),
+	}
+}
+
+// downloadError is a failed download attempt whose class is known
+type downloadError struct {
+	class      ErrorClass
+	retryAfter time.Duration // How long the server asked us to wait, 0 if it didn't
+	err        error
+}
+
+func (e *downloadError) Error() string {
+	return e.err.Error()
+}
+
+func (e *downloadError) Unwrap() error {
+	return e.err
+}
+
+// statusError returns the error for a response with an unexpected status
+func statusError(resp *http.Response) error {
+	err := &downloadError{err: fmt.Errorf("server returned status %d", resp.StatusCode)}
+	retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+
+	switch code := resp.StatusCode; {
+	case code == http.StatusForbidden || code == http.StatusNotFound || code == http.StatusGone:
+		err.class = ClassExpired
+		err.err = fmt.Errorf("link expired or removed: %w", err.err)
+	case code == http.StatusTooManyRequests:
+		err.class = ClassRateLimit
+		err.retryAfter = retryAfter
+	case code == 509:
+		// The bandwidth limit file hosts return when a quota runs out
+		err.class = ClassQuota
+		err.retryAfter = retryAfter
+	case code == http.StatusServiceUnavailable && retryAfter > 0:
+		err.class = ClassRateLimit
+		err.retryAfter = retryAfter
+	case code >= 400 && code < 500:
+		err.class = ClassClient
+	default:
+		err.class = ClassServer
+	}
+	return err
+}
+
+// providerError classifies an error from unlocking a link with the provider by its
+// AllDebrid error code. Unknown codes are not retried; errors without one are network
+// errors.
+func providerError(err error) error {
+	var apiErr *alldebrid.APIError
+	if !errors.As(err, &apiErr) {
+		return fmt.Errorf("failed to unlock link: %w", err)
+	}
+
+	class := ClassClient
+	switch code := fmt.Sprint(apiErr.Code); {
+	case slices.Contains(quotaCodes, code):
+		class = ClassQuota
+	case slices.Contains(deadLinkCodes, code):
+		class = ClassExpired
+	case slices.Contains(temporaryCodes, code):
+		class = ClassServer
+	}
+	return &downloadError{class: class, err: fmt.Errorf("failed to unlock link: %w", err)}
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as a date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// ClassifyError returns the class of a failed download attempt. Anything that isn't an
+// HTTP status or a file error is treated as a network error.
+func ClassifyError(err error) ErrorClass {
+	var downloadErr *downloadError
+	if errors.As(err, &downloadErr) {
+		return downloadErr.class
+	}
+
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) || errors.Is(err, syscall.EROFS) {
+		return ClassDisk
+	}
+
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return ClassDisk
+	}
+
+	// Connection errors, timeouts and responses cut short
+	return ClassNetwork
+}
+
+// retryAfter returns how long the server asked to wait before retrying, if it did
+func retryAfter(err error) time.Duration {
+	var downloadErr *downloadError
+	if errors.As(err, &downloadErr) {
+		return downloadErr.retryAfter
+	}
+	return 0
+}
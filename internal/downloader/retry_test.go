@@ -0,0 +1,334 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		status     int
+		retryAfter string
+		class      ErrorClass
+		wait       time.Duration
+	}{
+		{http.StatusNotFound, "", ClassExpired, 0},
+		{http.StatusGone, "", ClassExpired, 0},
+		{http.StatusForbidden, "", ClassExpired, 0},
+		{http.StatusBadRequest, "", ClassClient, 0},
+		{http.StatusRequestedRangeNotSatisfiable, "", ClassClient, 0},
+		{http.StatusTooManyRequests, "120", ClassRateLimit, 2 * time.Minute},
+		{509, "", ClassQuota, 0},
+		{http.StatusServiceUnavailable, "30", ClassRateLimit, 30 * time.Second},
+		{http.StatusServiceUnavailable, "", ClassServer, 0},
+		{http.StatusInternalServerError, "", ClassServer, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d", tt.status), func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+
+			err := statusError(resp)
+			require.Contains(t, err.Error(), fmt.Sprintf("server returned status %d", tt.status))
+			require.Equal(t, tt.class, ClassifyError(fmt.Errorf("attempt failed: %w", err)))
+			require.Equal(t, tt.wait, retryAfter(err))
+		})
+	}
+}
+
+func TestProviderError(t *testing.T) {
+	tests := []struct {
+		code  string
+		class ErrorClass
+	}{
+		{"LINK_HOST_LIMIT_REACHED", ClassQuota},
+		{"LINK_TOO_MANY_DOWNLOADS", ClassQuota},
+		{"LINK_DOWN", ClassExpired},
+		{"LINK_HOST_UNAVAILABLE", ClassServer},
+		{"AUTH_BAD_APIKEY", ClassClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			err := providerError(&alldebrid.APIError{Message: "unlock failed", Code: tt.code})
+			require.Contains(t, err.Error(), "failed to unlock link")
+			require.Equal(t, tt.class, ClassifyError(err))
+		})
+	}
+
+	require.Equal(t, ClassNetwork, ClassifyError(providerError(errors.New("connection refused"))))
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	require.Equal(t, time.Duration(0), parseRetryAfter(""))
+	require.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+	require.Equal(t, time.Duration(0), parseRetryAfter("-5"))
+	require.Equal(t, 90*time.Second, parseRetryAfter("90"))
+
+	wait := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	require.InDelta(t, time.Hour.Seconds(), wait.Seconds(), 2)
+	require.Equal(t, time.Duration(0), parseRetryAfter(time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)))
+}
+
+func TestClassifyError(t *testing.T) {
+	dnsErr := &url.Error{Op: "Get", URL: "http://invalid.invalid", Err: &net.DNSError{Err: "no such host", Name: "invalid.invalid"}}
+	require.Equal(t, ClassNetwork, ClassifyError(dnsErr))
+	require.Equal(t, ClassNetwork, ClassifyError(fmt.Errorf("failed to read: %w", io.ErrUnexpectedEOF)))
+	require.Equal(t, ClassNetwork, ClassifyError(context.DeadlineExceeded))
+	require.Equal(t, ClassNetwork, ClassifyError(errors.New("something else")))
+
+	_, pathErr := os.Open("/nonexistent/file.bin")
+	require.Equal(t, ClassDisk, ClassifyError(fmt.Errorf("failed to create file: %w", pathErr)))
+	full := &os.PathError{Op: "write", Path: "file.bin", Err: syscall.ENOSPC}
+	require.Equal(t, ClassDisk, ClassifyError(fmt.Errorf("failed to write to file: %w", full)))
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := RetryPolicy{Backoff: time.Second, MaxDelay: 10 * time.Second}
+	require.Equal(t, time.Second, policy.delay(0, 0))
+	require.Equal(t, 4*time.Second, policy.delay(2, 0))
+	require.Equal(t, 10*time.Second, policy.delay(5, 0))
+	require.Equal(t, 10*time.Second, policy.delay(100, 0))
+
+	// A Retry-After is waited out even past the cap
+	require.Equal(t, time.Minute, policy.delay(0, time.Minute))
+
+	// Jitter stays within its fraction of the wait and under the cap
+	policy.Jitter = 0.5
+	for range 100 {
+		delay := policy.delay(1, 0)
+		require.GreaterOrEqual(t, delay, time.Second)
+		require.LessOrEqual(t, delay, 3*time.Second)
+		require.LessOrEqual(t, policy.delay(4, 0), 10*time.Second)
+	}
+}
+
+func TestOptions_RetryPolicy(t *testing.T) {
+	opts := DefaultOptions()
+
+	require.Equal(t, opts.MaxRetries, opts.retryPolicy(ClassNetwork).MaxRetries)
+	require.Equal(t, opts.MaxRetries, opts.retryPolicy(ClassServer).MaxRetries)
+	require.Equal(t, opts.RetryMaxDelay, opts.retryPolicy(ClassServer).MaxDelay)
+	require.Equal(t, 0, opts.retryPolicy(ClassClient).MaxRetries)
+	require.Equal(t, 1, opts.retryPolicy(ClassExpired).MaxRetries)
+	require.Zero(t, opts.retryPolicy(ClassExpired).delay(0, 0))
+	require.Equal(t, 2, opts.retryPolicy(ClassDisk).MaxRetries)
+
+	rateLimit := opts.retryPolicy(ClassRateLimit)
+	require.Negative(t, rateLimit.MaxRetries)
+	require.Equal(t, opts.RateLimitWait, rateLimit.MaxWait)
+	require.True(t, rateLimit.Requeue)
+	require.Equal(t, rateLimit, opts.retryPolicy(ClassQuota))
+
+	// Without a rate limit wait, rate limits are retried like server errors
+	opts.RateLimitWait = 0
+	require.Equal(t, opts.retryPolicy(ClassServer), opts.retryPolicy(ClassRateLimit))
+	require.Equal(t, opts.retryPolicy(ClassServer), opts.retryPolicy(ClassQuota))
+}
+
+func TestWorker_RetryByErrorClass(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := requests.Add(1)
+		switch r.URL.Path {
+		case "/expired.bin":
+			w.WriteHeader(http.StatusNotFound)
+		case "/fresh.bin":
+			_, _ = w.Write([]byte("content"))
+		case "/limited.bin":
+			// Rate limited for more attempts than the configured retries, then served
+			if count <= 4 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			_, _ = w.Write([]byte("content"))
+		}
+	}))
+	defer server.Close()
+
+	worker := NewWorker(db, t.TempDir())
+	worker.SetOptions(Options{
+		MaxRetries:       1,
+		RetryBackoff:     time.Millisecond,
+		RetryMaxDelay:    5 * time.Millisecond,
+		RateLimitWait:    time.Minute,
+		ProgressInterval: time.Second,
+		Timeout:          time.Minute,
+	})
+
+	newDownload := func(name string) *models.Download {
+		download := &models.Download{
+			OriginalURL:     "https://hoster.example/" + name,
+			UnrestrictedURL: server.URL + "/" + name,
+			Filename:        name,
+			Directory:       t.TempDir(),
+			Status:          models.StatusPending,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		}
+		require.NoError(t, db.CreateDownload(download))
+		return download
+	}
+
+	// An expired link fails without retrying
+	expired := newDownload("expired.bin")
+	worker.processDownload(context.Background(), expired.ID)
+	require.Equal(t, int32(1), requests.Load())
+
+	updated, err := db.GetDownload(expired.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusFailed, updated.Status)
+	require.Contains(t, updated.ErrorMessage, "link expired or removed")
+
+	// With the provider at hand, an expired link is unlocked again from the original link
+	var unlocked []string
+	worker.SetLinkUnlocker(unlockerFunc(func(ctx context.Context, link string) (*alldebrid.UnrestrictResult, error) {
+		unlocked = append(unlocked, link)
+		if link == "https://hoster.example/quota.bin" {
+			return nil, &alldebrid.APIError{Message: "Host limit reached", Code: "LINK_HOST_LIMIT_REACHED"}
+		}
+		return &alldebrid.UnrestrictResult{UnrestrictedURL: server.URL + "/fresh.bin"}, nil
+	}))
+
+	relinked := newDownload("expired.bin")
+	worker.processDownload(context.Background(), relinked.ID)
+	require.Equal(t, []string{"https://hoster.example/expired.bin"}, unlocked)
+
+	updated, err = db.GetDownload(relinked.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusCompleted, updated.Status)
+	require.Equal(t, server.URL+"/fresh.bin", updated.UnrestrictedURL)
+
+	// The provider's quota is waited out like a rate limit
+	quota := newDownload("quota.bin")
+	quota.UnrestrictedURL = server.URL + "/expired.bin"
+	require.NoError(t, db.UpdateDownload(quota))
+	worker.processDownload(context.Background(), quota.ID)
+
+	updated, err = db.GetDownload(quota.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusPending, updated.Status)
+	require.NotNil(t, updated.NextAttemptAt)
+	require.Contains(t, updated.ErrorMessage, "Host limit reached")
+	require.NoError(t, db.DeleteDownload(quota.ID))
+
+	// A rate limit is waited out past the retries other errors get, from the backlog
+	requests.Store(0)
+	limited := newDownload("limited.bin")
+	worker.processDownload(context.Background(), limited.ID)
+	require.Equal(t, int32(1), requests.Load())
+
+	updated, err = db.GetDownload(limited.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusPending, updated.Status)
+	require.NotNil(t, updated.NextAttemptAt)
+	require.Empty(t, worker.ActiveDownloads())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go worker.Start(ctx)
+
+	require.Eventually(t, func() bool {
+		updated, err := db.GetDownload(limited.ID)
+		return err == nil && updated.Status == models.StatusCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, int32(5), requests.Load())
+}
+
+// unlockerFunc unlocks links with a function
+type unlockerFunc func(ctx context.Context, link string) (*alldebrid.UnrestrictResult, error)
+
+func (f unlockerFunc) UnrestrictLink(ctx context.Context, link string) (*alldebrid.UnrestrictResult, error) {
+	return f(ctx, link)
+}
+
+func TestWorker_RateLimitFreesPlace(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var limitedRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/limited.bin" && limitedRequests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	worker := NewWorker(db, t.TempDir())
+	worker.SetOptions(Options{
+		Concurrency:      1,
+		MaxRetries:       1,
+		RetryBackoff:     time.Millisecond,
+		RateLimitWait:    time.Minute,
+		ProgressInterval: time.Second,
+		Timeout:          time.Minute,
+	})
+
+	var downloads []*models.Download
+	for _, name := range []string{"limited.bin", "other.bin"} {
+		download := &models.Download{
+			OriginalURL:     server.URL + "/" + name,
+			UnrestrictedURL: server.URL + "/" + name,
+			Filename:        name,
+			Directory:       t.TempDir(),
+			Status:          models.StatusPending,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		}
+		require.NoError(t, db.CreateDownload(download))
+		downloads = append(downloads, download)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go worker.Start(ctx)
+
+	worker.QueueDownload(downloads[0].ID)
+	require.Eventually(t, func() bool {
+		return limitedRequests.Load() == 1
+	}, time.Second, 10*time.Millisecond)
+	worker.QueueDownload(downloads[1].ID)
+
+	// The other download runs while the rate limited one waits
+	require.Eventually(t, func() bool {
+		other, err := db.GetDownload(downloads[1].ID)
+		return err == nil && other.Status == models.StatusCompleted
+	}, 500*time.Millisecond, 10*time.Millisecond)
+
+	limited, err := db.GetDownload(downloads[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusPending, limited.Status)
+
+	require.Eventually(t, func() bool {
+		limited, err := db.GetDownload(downloads[0].ID)
+		return err == nil && limited.Status == models.StatusCompleted && limited.NextAttemptAt == nil
+	}, 3*time.Second, 10*time.Millisecond)
+}
@@ -10,13 +10,19 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"debrid-downloader/internal/alldebrid"
 	"debrid-downloader/internal/cleanup"
 	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/events"
 	"debrid-downloader/internal/extractor"
+	"debrid-downloader/internal/metrics"
+	"debrid-downloader/internal/release"
 	"debrid-downloader/pkg/models"
 )
 
@@ -92,19 +98,72 @@ func (sh *SpeedHistory) CalculateSpeed(recentBytes int64, recentTime float64) fl
 	return float64(totalBytes) / totalTime
 }
 
-// Worker manages the download queue and processes downloads sequentially
+// Options tune how the worker downloads. They can be changed while it runs.
+type Options struct {
+	Concurrency      int           // Downloads processed at the same time
+	SpeedLimit       int64         // Bytes per second shared by all downloads, 0 for unlimited
+	MaxRetries       int           // Attempts after the first before a download fails
+	RetryBackoff     time.Duration // Wait before the first retry, doubled for each one after
+	RetryMaxDelay    time.Duration // Longest wait between retries, 0 for no cap
+	RetryJitter      float64       // Fraction of each retry wait picked at random
+	RateLimitWait    time.Duration // How long a rate limited download waits before failing, 0 to retry it like a server error
+	ProgressInterval time.Duration // How often progress is saved while downloading
+	Timeout          time.Duration // Longest a single download attempt may take
+	Cleanup          cleanup.Extensions
+}
+
+// DefaultOptions returns the options a new worker starts with
+func DefaultOptions() Options {
+	return Options{
+		Concurrency:      1,
+		MaxRetries:       5,
+		RetryBackoff:     time.Second,
+		RetryMaxDelay:    5 * time.Minute,
+		RetryJitter:      0.2,
+		RateLimitWait:    6 * time.Hour,
+		ProgressInterval: 500 * time.Millisecond,
+		Timeout:          time.Hour,
+		Cleanup:          cleanup.DefaultExtensions(),
+	}
+}
+
+// Worker manages the download queue and processes up to Options.Concurrency downloads
+// at a time
 type Worker struct {
 	db        *database.DB
 	logger    *slog.Logger
-	queue     chan int64 // Channel for download IDs
+	queue     chan int64       // Channel for download IDs
+	backlog   []queuedDownload // Downloads taken off the queue, waiting their turn by priority
+	wake      chan struct{}    // Signalled when a download finishes or the options change
 	extractor *extractor.Service
 	cleanup   *cleanup.Service
+	events    *events.Bus
+	limiter   *rateLimiter
+	unlocker  LinkUnlocker // Unlocks expired links again, nil if they just fail
+	options   Options
 	mu        sync.RWMutex
+	groupMu   sync.Mutex // Serialises group completion checks
 
-	// Current download state
-	currentDownload *models.Download
-	cancel          context.CancelFunc
-	paused          bool
+	// Downloads in progress by ID
+	active map[int64]*activeDownload
+
+	// Retry state of downloads waiting out a rate limit in the backlog, by ID
+	retries map[int64]*retryState
+}
+
+// activeDownload is the state of a download in progress
+type activeDownload struct {
+	download *models.Download // Nil until the download has been loaded
+	cancel   context.CancelFunc
+	paused   bool
+	started  time.Time
+}
+
+// queuedDownload is a download waiting in the worker's backlog
+type queuedDownload struct {
+	id        int64
+	priority  int
+	notBefore time.Time // Zero unless the download waits out a rate limit
 }
 
 // NewWorker creates a new download worker
@@ -113,69 +172,264 @@ func NewWorker(db *database.DB, baseDownloadPath string) *Worker {
 		db:        db,
 		logger:    slog.Default(),
 		queue:     make(chan int64, 100), // Buffer for up to 100 queued downloads
+		wake:      make(chan struct{}, 1),
 		extractor: extractor.NewService(),
 		cleanup:   cleanup.NewService(db, baseDownloadPath),
+		events:    events.NewBus(),
+		limiter:   &rateLimiter{},
+		options:   DefaultOptions(),
+		active:    make(map[int64]*activeDownload),
+		retries:   make(map[int64]*retryState),
+	}
+}
+
+// LinkUnlocker unlocks a hoster link with the provider
+type LinkUnlocker interface {
+	UnrestrictLink(ctx context.Context, link string) (*alldebrid.UnrestrictResult, error)
+}
+
+// SetLinkUnlocker sets what unlocks a download's original link again when the link it
+// was given has expired. Call it before the worker is started.
+func (w *Worker) SetLinkUnlocker(unlocker LinkUnlocker) {
+	w.unlocker = unlocker
+}
+
+// SetOptions changes the worker's options. Downloads in progress pick them up on their
+// next attempt or progress update, and a higher concurrency starts waiting downloads.
+func (w *Worker) SetOptions(opts Options) {
+	w.cleanup.SetExtensions(opts.Cleanup)
+	w.limiter.setLimit(opts.SpeedLimit)
+
+	w.mu.Lock()
+	w.options = opts
+	w.mu.Unlock()
+
+	w.signal()
+}
+
+// signal wakes the queue loop to start any downloads that now fit
+func (w *Worker) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
 	}
 }
 
-// Start begins processing the download queue
+// Options returns the worker's options
+func (w *Worker) Options() Options {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.options
+}
+
+// Events returns the bus download and group lifecycle events are published on
+func (w *Worker) Events() *events.Bus {
+	return w.events
+}
+
+// publish sends a download event
+func (w *Worker) publish(eventType events.Type, download *models.Download) {
+	w.events.Publish(events.Event{Type: eventType, Download: download})
+}
+
+// Start begins processing the download queue. Waiting downloads are processed
+// highest priority first, and in the order they were queued within a priority, once any
+// rate limit they wait out has passed. On shutdown it waits for the downloads in
+// progress to stop.
 func (w *Worker) Start(ctx context.Context) {
 	w.logger.Info("Starting download worker")
 
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
 	for {
+		if ctx.Err() == nil {
+			if downloadID, ok := w.nextDownload(); ok {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					w.processDownload(ctx, downloadID)
+				}()
+				continue
+			}
+		}
+
 		select {
 		case <-ctx.Done():
 			w.logger.Info("Download worker shutting down")
 			return
 		case downloadID := <-w.queue:
-			w.processDownload(ctx, downloadID)
+			w.addToBacklog(downloadID)
+		case <-w.wake:
+		case <-w.nextDue():
 		}
 	}
 }
 
+// nextDue returns a channel that fires when the next download waiting out a rate limit
+// may be attempted, or nil when none is waiting
+func (w *Worker) nextDue() <-chan time.Time {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	now := time.Now()
+	var due time.Time
+	for _, queued := range w.backlog {
+		if queued.notBefore.After(now) && (due.IsZero() || queued.notBefore.Before(due)) {
+			due = queued.notBefore
+		}
+	}
+	if due.IsZero() {
+		return nil
+	}
+	return time.After(due.Sub(now))
+}
+
+// nextDownload moves everything queued into the backlog and, when fewer downloads than
+// the concurrency are in progress, removes the download to process next from it and
+// reserves its place among the active downloads. Downloads already in progress stay in
+// the backlog until they finish, and those waiting out a rate limit until it has passed.
+func (w *Worker) nextDownload() (int64, bool) {
+	for drained := false; !drained; {
+		select {
+		case downloadID := <-w.queue:
+			w.addToBacklog(downloadID)
+		default:
+			drained = true
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.active) >= max(w.options.Concurrency, 1) {
+		return 0, false
+	}
+
+	now := time.Now()
+	next := -1
+	for i, queued := range w.backlog {
+		if _, running := w.active[queued.id]; running || queued.notBefore.After(now) {
+			continue
+		}
+		if next < 0 || queued.priority > w.backlog[next].priority {
+			next = i
+		}
+	}
+	if next < 0 {
+		return 0, false
+	}
+
+	downloadID := w.backlog[next].id
+	w.backlog = append(w.backlog[:next], w.backlog[next+1:]...)
+	w.active[downloadID] = &activeDownload{started: time.Now()}
+	return downloadID, true
+}
+
+// addToBacklog adds a download taken off the queue to the backlog with its priority,
+// and the time of its next attempt if it was waiting out a rate limit
+func (w *Worker) addToBacklog(downloadID int64) {
+	queued := queuedDownload{id: downloadID}
+	if download, err := w.db.GetDownload(downloadID); err == nil {
+		queued.priority = download.Priority
+		if download.NextAttemptAt != nil {
+			queued.notBefore = *download.NextAttemptAt
+		}
+	}
+
+	w.mu.Lock()
+	w.backlog = append(w.backlog, queued)
+	w.mu.Unlock()
+}
+
 // QueueDownload adds a download to the processing queue
 func (w *Worker) QueueDownload(downloadID int64) {
 	select {
 	case w.queue <- downloadID:
 		w.logger.Info("Download queued", "download_id", downloadID)
+		if download, err := w.db.GetDownload(downloadID); err == nil {
+			w.publish(events.DownloadQueued, download)
+		}
 	default:
 		w.logger.Error("Download queue is full", "download_id", downloadID)
 	}
 }
 
-// GetCurrentDownload returns information about the currently processing download
-func (w *Worker) GetCurrentDownload() *models.Download {
+// QueueDepth returns how many downloads are waiting in the queue
+func (w *Worker) QueueDepth() int {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
-	return w.currentDownload
+	return len(w.queue) + len(w.backlog)
+}
+
+// ActiveDownloads returns the downloads in progress, oldest first
+func (w *Worker) ActiveDownloads() []*models.Download {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	entries := make([]*activeDownload, 0, len(w.active))
+	for _, entry := range w.active {
+		if entry.download != nil {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].started.Before(entries[j].started)
+	})
+
+	downloads := make([]*models.Download, len(entries))
+	for i, entry := range entries {
+		downloads[i] = entry.download
+	}
+	return downloads
 }
 
-// PauseCurrentDownload pauses the current download
+// GetCurrentDownload returns the oldest download in progress
+func (w *Worker) GetCurrentDownload() *models.Download {
+	if downloads := w.ActiveDownloads(); len(downloads) > 0 {
+		return downloads[0]
+	}
+	return nil
+}
+
+// PauseCurrentDownload pauses the oldest download in progress
 func (w *Worker) PauseCurrentDownload() error {
+	current := w.GetCurrentDownload()
+	if current == nil {
+		return fmt.Errorf("no download currently in progress")
+	}
+	return w.PauseDownload(current.ID)
+}
+
+// PauseDownload pauses a download in progress
+func (w *Worker) PauseDownload(downloadID int64) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	if w.currentDownload == nil {
-		return fmt.Errorf("no download currently in progress")
+	entry, ok := w.active[downloadID]
+	if !ok || entry.download == nil || entry.paused {
+		return fmt.Errorf("download is not in progress")
 	}
 
-	w.paused = true
-	if w.cancel != nil {
-		w.cancel()
+	entry.paused = true
+	if entry.cancel != nil {
+		entry.cancel()
 	}
 
 	// Update database status to paused and record pause time
-	w.currentDownload.Status = models.StatusPaused
+	download := entry.download
+	download.Status = models.StatusPaused
 	now := time.Now()
-	w.currentDownload.UpdatedAt = now
-	w.currentDownload.PausedAt = &now
+	download.UpdatedAt = now
+	download.PausedAt = &now
 
-	if err := w.db.UpdateDownload(w.currentDownload); err != nil {
+	if err := w.db.UpdateDownload(download); err != nil {
 		w.logger.Error("Failed to update paused download status", "error", err)
 		return err
 	}
 
-	w.logger.Info("Download paused", "download_id", w.currentDownload.ID)
+	w.logger.Info("Download paused", "download_id", download.ID)
+	w.publish(events.DownloadPaused, download)
 	return nil
 }
 
@@ -210,15 +464,74 @@ func (w *Worker) ResumeDownload(downloadID int64) error {
 	return nil
 }
 
-// CancelCurrentDownloadIfMatches cancels the current download if it matches the given ID
+// PauseDownloads pauses the given pending and in-progress downloads of an owner, or of
+// everyone with database.AllOwners, returning the IDs it paused. The database changes
+// happen in one statement; downloads in progress are stopped.
+func (w *Worker) PauseDownloads(ownerID int64, ids []int64) ([]int64, error) {
+	now := time.Now()
+
+	w.mu.Lock()
+	paused, err := w.db.PauseDownloads(ownerID, ids, now)
+	if err != nil {
+		w.mu.Unlock()
+		return nil, err
+	}
+
+	downloads := make([]*models.Download, 0, len(paused))
+	for _, id := range paused {
+		entry, ok := w.active[id]
+		if !ok || entry.download == nil {
+			continue
+		}
+		entry.paused = true
+		if entry.cancel != nil {
+			entry.cancel()
+		}
+		entry.download.Status = models.StatusPaused
+		entry.download.UpdatedAt = now
+		entry.download.PausedAt = &now
+		downloads = append(downloads, entry.download)
+	}
+	w.mu.Unlock()
+
+	for _, id := range paused {
+		if slices.ContainsFunc(downloads, func(d *models.Download) bool { return d.ID == id }) {
+			continue
+		}
+		if download, err := w.db.GetDownload(id); err == nil {
+			downloads = append(downloads, download)
+		}
+	}
+	for _, download := range downloads {
+		w.publish(events.DownloadPaused, download)
+	}
+
+	w.logger.Info("Downloads paused", "count", len(paused))
+	return paused, nil
+}
+
+// SetPriority changes the priority of the given downloads waiting in the backlog, after
+// it has been changed in the database
+func (w *Worker) SetPriority(ids []int64, priority int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := range w.backlog {
+		if slices.Contains(ids, w.backlog[i].id) {
+			w.backlog[i].priority = priority
+		}
+	}
+}
+
+// CancelCurrentDownloadIfMatches cancels the download with the given ID if it is in progress
 func (w *Worker) CancelCurrentDownloadIfMatches(downloadID int64) bool {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	if w.currentDownload != nil && w.currentDownload.ID == downloadID {
+	if entry, ok := w.active[downloadID]; ok && entry.download != nil {
 		w.logger.Info("Canceling current download", "download_id", downloadID)
-		if w.cancel != nil {
-			w.cancel()
+		if entry.cancel != nil {
+			entry.cancel()
 		}
 		return true
 	}
@@ -227,64 +540,100 @@ func (w *Worker) CancelCurrentDownloadIfMatches(downloadID int64) bool {
 
 // processDownload handles the actual downloading of a file
 func (w *Worker) processDownload(ctx context.Context, downloadID int64) {
-	// Get download details from database
+	// Free the download's place among the active ones when done
+	defer func() {
+		w.mu.Lock()
+		delete(w.active, downloadID)
+		w.mu.Unlock()
+		w.signal()
+	}()
+
+	// Pick up the retries counted before the download waited out a rate limit
+	w.mu.Lock()
+	state, ok := w.retries[downloadID]
+	delete(w.retries, downloadID)
+	w.mu.Unlock()
+	if !ok {
+		state = newRetryState()
+	}
+
 	download, err := w.db.GetDownload(downloadID)
 	if err != nil {
 		w.logger.Error("Failed to get download", "download_id", downloadID, "error", err)
 		return
 	}
 
-	// Set as current download
+	// Skip downloads paused, completed or failed while they waited in the queue
+	if download.Status != models.StatusPending {
+		w.logger.Info("Skipping download that is no longer pending", "download_id", downloadID, "status", download.Status)
+		return
+	}
+
+	// Mark as in progress, using the place reserved when it was taken off the backlog
 	w.mu.Lock()
-	w.currentDownload = download
-	w.paused = false
+	entry, ok := w.active[downloadID]
+	if !ok {
+		entry = &activeDownload{started: time.Now()}
+		w.active[downloadID] = entry
+	}
+	entry.download = download
 	w.mu.Unlock()
 
-	// Defer cleanup
-	defer func() {
-		w.mu.Lock()
-		w.currentDownload = nil
-		w.cancel = nil
-		w.mu.Unlock()
-	}()
-
-	// Start download with retry logic
-	maxRetries := 5
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			// Exponential backoff: 2^attempt seconds
-			backoffDuration := time.Duration(1<<uint(attempt)) * time.Second
+	// Start download with retry logic, reading the options for each attempt so reloaded
+	// settings apply to downloads already retrying. Each class of error counts its own
+	// retries and waits.
+	var delay time.Duration
+	first := state.attempts
+	for attempt := first; ; attempt++ {
+		if attempt > first {
 			w.logger.Info("Retrying download after backoff",
 				"download_id", downloadID,
 				"attempt", attempt,
-				"backoff", backoffDuration)
+				"backoff", delay)
 
 			select {
 			case <-ctx.Done():
 				return
-			case <-time.After(backoffDuration):
+			case <-time.After(delay):
 			}
 
 			// Check if the download was deleted during the backoff period
-			if _, err := w.db.GetDownload(downloadID); err != nil {
+			current, err := w.db.GetDownload(downloadID)
+			if err != nil {
 				w.logger.Info("Download was deleted during retry backoff, stopping processing",
 					"download_id", downloadID)
 				return
 			}
+			if current.Status == models.StatusPaused {
+				w.logger.Info("Download was paused during retry backoff", "download_id", downloadID)
+				return
+			}
 		}
 
 		// Create cancellable context for this download attempt
 		downloadCtx, cancel := context.WithCancel(ctx)
 		w.mu.Lock()
-		w.cancel = cancel
+		entry.cancel = cancel
 		w.mu.Unlock()
 
-		err := w.downloadFile(downloadCtx, download)
+		// An expired link is unlocked again before the attempt, until that works
+		var err error
+		if state.unlock {
+			err = w.unlockLink(downloadCtx, download)
+		}
+		unlockFailed := err != nil
+		if err == nil {
+			err = w.downloadFile(downloadCtx, download)
+		}
 		cancel()
 
 		if err == nil {
 			// Success!
 			w.logger.Info("Download completed successfully", "download_id", downloadID)
+			if download.GroupID == "" {
+				w.organise(download, w.category(download.Category).RenameTemplate)
+			}
+			w.publish(events.DownloadCompleted, download)
 
 			// Check if this download is part of a group and handle group completion
 			if download.GroupID != "" {
@@ -296,7 +645,7 @@ func (w *Worker) processDownload(ctx context.Context, downloadID int64) {
 
 		// Check if we were paused
 		w.mu.RLock()
-		isPaused := w.paused
+		isPaused := entry.paused
 		w.mu.RUnlock()
 
 		if isPaused {
@@ -304,23 +653,46 @@ func (w *Worker) processDownload(ctx context.Context, downloadID int64) {
 			return
 		}
 
+		// Work out whether and when this class of error is retried
+		class := ClassifyError(err)
+		policy := w.Options().retryPolicy(class)
+		if class == ClassExpired && (w.unlocker == nil || download.OriginalURL == "") {
+			policy.MaxRetries = 0
+		}
+		state.unlock = class == ClassExpired || unlockFailed
+		delay = policy.delay(state.retries[class], retryAfter(err))
+		retry := (policy.MaxRetries < 0 || state.retries[class] < policy.MaxRetries) &&
+			(policy.MaxWait == 0 || state.waited[class]+delay <= policy.MaxWait)
+		state.retries[class]++
+		state.waited[class] += delay
+		state.attempts = attempt + 1
+
 		// Update retry count
 		download.RetryCount = attempt + 1
 		download.ErrorMessage = err.Error()
 		download.UpdatedAt = time.Now()
 
-		if attempt < maxRetries {
+		if retry {
 			download.Status = models.StatusPending
+			if policy.Requeue {
+				nextAttempt := download.UpdatedAt.Add(delay)
+				download.NextAttemptAt = &nextAttempt
+			}
+			metrics.DownloadRetries.Inc()
 			w.logger.Warn("Download attempt failed, will retry",
 				"download_id", downloadID,
 				"attempt", attempt+1,
+				"class", class,
+				"retry_in", delay,
 				"error", err)
 		} else {
 			download.Status = models.StatusFailed
 			completedAt := time.Now()
 			download.CompletedAt = &completedAt
-			w.logger.Error("Download failed after all retries",
+			w.logger.Error("Download failed",
 				"download_id", downloadID,
+				"class", class,
+				"attempts", attempt+1,
 				"error", err)
 		}
 
@@ -330,8 +702,26 @@ func (w *Worker) processDownload(ctx context.Context, downloadID int64) {
 				"error", updateErr)
 		}
 
+		if download.Status == models.StatusFailed {
+			w.publish(events.DownloadFailed, download)
+		}
+
+		// Give up the download's place while it waits, and take it up again from the
+		// backlog once the wait is over
+		if retry && policy.Requeue {
+			w.mu.Lock()
+			w.retries[downloadID] = state
+			w.backlog = append(w.backlog, queuedDownload{
+				id:        downloadID,
+				priority:  download.Priority,
+				notBefore: *download.NextAttemptAt,
+			})
+			w.mu.Unlock()
+			return
+		}
+
 		// If we've exhausted retries, clean up temporary file and stop
-		if attempt >= maxRetries {
+		if !retry {
 			// Clean up temporary file for this download
 			tempFilename := fmt.Sprintf("%s.%d.tmp", download.Filename, download.ID)
 			tempPath := filepath.Join(download.Directory, tempFilename)
@@ -347,16 +737,32 @@ func (w *Worker) processDownload(ctx context.Context, downloadID int64) {
 	}
 }
 
+// unlockLink replaces a download's expired link with a fresh one from the provider
+func (w *Worker) unlockLink(ctx context.Context, download *models.Download) error {
+	result, err := w.unlocker.UnrestrictLink(ctx, download.OriginalURL)
+	if err != nil {
+		return providerError(err)
+	}
+
+	download.UnrestrictedURL = result.UnrestrictedURL
+	w.logger.Info("Unlocked expired link again", "download_id", download.ID)
+	return nil
+}
+
 // downloadFile performs the actual file download with progress tracking
 func (w *Worker) downloadFile(ctx context.Context, download *models.Download) error {
 	// Update status to downloading
 	download.Status = models.StatusDownloading
 	download.UpdatedAt = time.Now()
+	download.NextAttemptAt = nil
 
 	if err := w.db.UpdateDownload(download); err != nil {
 		return fmt.Errorf("failed to update download status: %w", err)
 	}
 
+	// Published for every attempt, including after a pause or a failed attempt
+	w.publish(events.DownloadStarted, download)
+
 	// Create HTTP request
 	req, err := http.NewRequestWithContext(ctx, "GET", download.UnrestrictedURL, nil)
 	if err != nil {
@@ -380,7 +786,7 @@ func (w *Worker) downloadFile(ctx context.Context, download *models.Download) er
 
 	// Make the request with longer timeout for large file downloads
 	client := &http.Client{
-		Timeout: 1 * time.Hour, // Allow up to 1 hour for downloads
+		Timeout: w.Options().Timeout,
 	}
 
 	resp, err := client.Do(req)
@@ -391,7 +797,7 @@ func (w *Worker) downloadFile(ctx context.Context, download *models.Download) er
 
 	// Check response status
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
-		return fmt.Errorf("server returned status %d", resp.StatusCode)
+		return statusError(resp)
 	}
 
 	// Get content length for progress tracking
@@ -457,6 +863,7 @@ func (w *Worker) copyWithProgress(ctx context.Context, dst io.Writer, src io.Rea
 	lastUpdate := time.Now()
 	lastSampleTime := lastUpdate
 	lastSampleBytes := resumeFrom
+	progressInterval := w.Options().ProgressInterval
 
 	for {
 		select {
@@ -473,10 +880,16 @@ func (w *Worker) copyWithProgress(ctx context.Context, dst io.Writer, src io.Rea
 			}
 
 			totalRead += int64(n)
+			metrics.DownloadedBytes.Add(float64(n))
+
+			// Hold back to stay under the speed limit shared by all downloads
+			if err := w.limiter.wait(ctx, n); err != nil {
+				return err
+			}
 
-			// Update progress every 500ms for smooth progress viewing
+			// Update progress regularly for smooth progress viewing
 			now := time.Now()
-			if now.Sub(lastUpdate) >= 500*time.Millisecond {
+			if now.Sub(lastUpdate) >= progressInterval {
 				// Add speed sample to history if enough time has passed
 				timeSinceSample := now.Sub(lastSampleTime).Seconds()
 				if timeSinceSample >= SAMPLE_MIN_DURATION {
@@ -551,6 +964,10 @@ func (w *Worker) copyWithProgress(ctx context.Context, dst io.Writer, src io.Rea
 
 // checkGroupCompletion checks if all downloads in a group are complete and triggers post-processing
 func (w *Worker) checkGroupCompletion(groupID string) {
+	// Downloads of a group can finish at the same time, so only one check runs at once
+	w.groupMu.Lock()
+	defer w.groupMu.Unlock()
+
 	// Get the group from database
 	group, err := w.db.GetDownloadGroup(groupID)
 	if err != nil {
@@ -582,8 +999,8 @@ func (w *Worker) checkGroupCompletion(groupID string) {
 
 	w.logger.Info("Group progress updated", "group_id", groupID, "completed", completedCount, "total", group.TotalDownloads)
 
-	// If all downloads are complete, start post-processing
-	if completedCount >= group.TotalDownloads {
+	// If all downloads are complete, start post-processing unless another check already has
+	if completedCount >= group.TotalDownloads && group.Status != models.GroupStatusProcessing {
 		w.logger.Info("All downloads in group completed, starting post-processing", "group_id", groupID)
 
 		// Update group status to processing
@@ -598,6 +1015,31 @@ func (w *Worker) checkGroupCompletion(groupID string) {
 	}
 }
 
+// ReprocessGroup runs a group's post-processing again in the background, unless it is
+// already running. Archives that were already extracted are skipped.
+func (w *Worker) ReprocessGroup(groupID string) error {
+	w.groupMu.Lock()
+	defer w.groupMu.Unlock()
+
+	group, err := w.db.GetDownloadGroup(groupID)
+	if err != nil {
+		return err
+	}
+	if group.Status == models.GroupStatusProcessing {
+		return fmt.Errorf("group is already being processed")
+	}
+
+	group.Status = models.GroupStatusProcessing
+	group.ProcessingError = ""
+	if err := w.db.UpdateDownloadGroup(group); err != nil {
+		return err
+	}
+
+	w.logger.Info("Re-running group post-processing", "group_id", groupID)
+	go w.processGroup(groupID)
+	return nil
+}
+
 // processGroup handles post-download processing for a completed group
 func (w *Worker) processGroup(groupID string) {
 	w.logger.Info("Starting group post-processing", "group_id", groupID)
@@ -675,8 +1117,27 @@ func (w *Worker) processGroup(groupID string) {
 		}
 	}
 
+	// A group processed again keeps only its extracted files, so there is nothing to
+	// extract and only the renaming, hooks and notifications run again
+	archiveDownloads = slices.DeleteFunc(archiveDownloads, func(download *models.Download) bool {
+		if !alreadyExtracted(download) {
+			return false
+		}
+		w.logger.Info("Skipping archive that was already extracted", "filename", download.Filename)
+		return true
+	})
+
 	if len(archiveDownloads) == 0 {
 		w.logger.Info("No archive files to process in group", "group_id", groupID)
+		w.organiseGroup(completedDownloads)
+		w.markGroupCompleted(groupID)
+		return
+	}
+
+	category := w.category(completedDownloads[0].Category)
+	if !category.Extract {
+		w.logger.Info("Extraction is disabled for the group's category", "group_id", groupID, "category", category.Name)
+		w.organiseGroup(completedDownloads)
 		w.markGroupCompleted(groupID)
 		return
 	}
@@ -686,7 +1147,7 @@ func (w *Worker) processGroup(groupID string) {
 	// Process each archive download
 	successCount := 0
 	for _, download := range archiveDownloads {
-		if err := w.processArchive(download); err != nil {
+		if err := w.processArchive(download, category.CleanupProfile); err != nil {
 			w.logger.Error("Failed to process archive", "download_id", download.ID, "filename", download.Filename, "error", err)
 			// Continue with other archives even if one fails
 		} else {
@@ -696,6 +1157,7 @@ func (w *Worker) processGroup(groupID string) {
 
 	if successCount > 0 {
 		w.logger.Info("Archive processing completed", "group_id", groupID, "successful", successCount, "total", len(archiveDownloads))
+		w.organiseGroup(completedDownloads)
 		w.markGroupCompleted(groupID)
 	} else {
 		w.logger.Error("No archives could be processed", "group_id", groupID)
@@ -703,6 +1165,16 @@ func (w *Worker) processGroup(groupID string) {
 	}
 }
 
+// alreadyExtracted reports whether an archive was extracted before and has since been
+// deleted
+func alreadyExtracted(download *models.Download) bool {
+	if download.ExtractedFiles == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(download.Directory, download.Filename))
+	return os.IsNotExist(err)
+}
+
 // markGroupCompleted marks a group as successfully completed
 func (w *Worker) markGroupCompleted(groupID string) {
 	group, err := w.db.GetDownloadGroup(groupID)
@@ -718,6 +1190,7 @@ func (w *Worker) markGroupCompleted(groupID string) {
 	}
 
 	w.logger.Info("Group processing completed successfully", "group_id", groupID)
+	w.events.Publish(events.Event{Type: events.GroupCompleted, Group: group})
 }
 
 // markGroupFailed marks a group as failed with an error message
@@ -736,10 +1209,22 @@ func (w *Worker) markGroupFailed(groupID string, errorMessage string) {
 	}
 
 	w.logger.Error("Group processing failed", "group_id", groupID, "error", errorMessage)
+	w.events.Publish(events.Event{Type: events.GroupFailed, Group: group})
+}
+
+// category returns a download's category, or the default settings when it has none
+func (w *Worker) category(name string) *models.Category {
+	if name != "" {
+		if category, err := w.db.GetCategoryByName(name); err == nil {
+			return category
+		}
+	}
+	return models.NewCategory(name)
 }
 
-// processArchive extracts an archive file and tracks the extracted files
-func (w *Worker) processArchive(download *models.Download) error {
+// processArchive extracts an archive file, tracks the extracted files and cleans them
+// up with the given profile
+func (w *Worker) processArchive(download *models.Download, profile models.CleanupProfile) error {
 	archivePath := filepath.Join(download.Directory, download.Filename)
 
 	// Check if archive file exists
@@ -750,10 +1235,13 @@ func (w *Worker) processArchive(download *models.Download) error {
 	w.logger.Info("Processing archive", "download_id", download.ID, "archive", archivePath)
 
 	// Extract archive to the same directory
+	extractStart := time.Now()
 	extractedFiles, err := w.extractor.Extract(archivePath, download.Directory)
 	if err != nil {
+		metrics.ExtractionDuration.WithLabelValues(metrics.ResultFailure).Observe(time.Since(extractStart).Seconds())
 		return fmt.Errorf("failed to extract archive: %w", err)
 	}
+	metrics.ExtractionDuration.WithLabelValues(metrics.ResultSuccess).Observe(time.Since(extractStart).Seconds())
 
 	if len(extractedFiles) == 0 {
 		return fmt.Errorf("no files were extracted from archive")
@@ -785,8 +1273,8 @@ func (w *Worker) processArchive(download *models.Download) error {
 		// Don't return error here as extraction was successful
 	}
 
-	// Clean up non-video files from the extracted files
-	if err := w.cleanup.CleanupExtractedFiles(download.ID); err != nil {
+	// Clean up the extracted files the category doesn't keep
+	if err := w.cleanup.CleanupExtractedFilesFor(download.ID, profile); err != nil {
 		w.logger.Warn("File cleanup completed with errors", "download_id", download.ID, "error", err)
 		// Don't return error here as extraction was successful
 	} else {
@@ -807,11 +1295,7 @@ func (w *Worker) deleteArchiveFiles(download *models.Download) error {
 	archivePath := filepath.Join(download.Directory, download.Filename)
 
 	// Delete the main archive file
-	if err := os.Remove(archivePath); err != nil && !os.IsNotExist(err) {
-		w.logger.Warn("Failed to delete archive file", "archive", archivePath, "error", err)
-	} else {
-		w.logger.Info("Archive file deleted", "archive", archivePath)
-	}
+	w.removeArchive(archivePath, "Archive file deleted", "Failed to delete archive file")
 
 	// If this is part of a group, delete all other archive parts in the group
 	if download.GroupID != "" {
@@ -830,11 +1314,7 @@ func (w *Worker) deleteArchiveFiles(download *models.Download) error {
 			filename := strings.ToLower(groupDownload.Filename)
 			if strings.HasSuffix(filename, ".rar") {
 				partPath := filepath.Join(groupDownload.Directory, groupDownload.Filename)
-				if err := os.Remove(partPath); err != nil && !os.IsNotExist(err) {
-					w.logger.Warn("Failed to delete archive part", "archive", partPath, "error", err)
-				} else {
-					w.logger.Info("Archive part deleted", "archive", partPath)
-				}
+				w.removeArchive(partPath, "Archive part deleted", "Failed to delete archive part")
 			}
 		}
 	}
@@ -842,6 +1322,22 @@ func (w *Worker) deleteArchiveFiles(download *models.Download) error {
 	return nil
 }
 
+// removeArchive deletes an archive file and records the space freed
+func (w *Worker) removeArchive(path, deletedMessage, failedMessage string) {
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		size = info.Size()
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		w.logger.Warn(failedMessage, "archive", path, "error", err)
+		return
+	}
+
+	w.logger.Info(deletedMessage, "archive", path)
+	metrics.CleanupFreedBytes.WithLabelValues(metrics.CleanupArchive).Add(float64(size))
+}
+
 // storeExtractedFiles stores a list of extracted files in the database for cleanup tracking
 func (w *Worker) storeExtractedFiles(downloadID int64, filePaths []string) error {
 	now := time.Now()
@@ -861,3 +1357,133 @@ func (w *Worker) storeExtractedFiles(downloadID int64, filePaths []string) error
 
 	return nil
 }
+
+// organiseGroup renames the video files of a group's completed downloads
+func (w *Worker) organiseGroup(downloads []*models.Download) {
+	if len(downloads) == 0 {
+		return
+	}
+
+	template := w.category(downloads[0].Category).RenameTemplate
+	for _, download := range downloads {
+		w.organise(download, template)
+	}
+}
+
+// organise renames a completed download's video files with a rename template, moving
+// the files it extracted or, when nothing was extracted, the downloaded file itself
+func (w *Worker) organise(download *models.Download, template string) {
+	if template == "" {
+		return
+	}
+
+	if download.ExtractedFiles == "" {
+		path := filepath.Join(download.Directory, download.Filename)
+		target, ok := w.renameTarget(download, path, template)
+		if !ok || !w.moveFile(download, path, target) {
+			return
+		}
+
+		download.Directory, download.Filename = filepath.Dir(target), filepath.Base(target)
+		if err := w.db.UpdateDownloadLocation(download.ID, download.Directory, download.Filename); err != nil {
+			w.logger.Warn("Failed to update renamed download", "download_id", download.ID, "error", err)
+		}
+		return
+	}
+
+	files, err := w.db.GetExtractedFilesByDownloadID(download.ID)
+	if err != nil {
+		w.logger.Warn("Failed to get extracted files for renaming", "download_id", download.ID, "error", err)
+		return
+	}
+
+	renamed := make(map[string]string)
+	for _, file := range files {
+		target, ok := w.renameTarget(download, file.FilePath, template)
+		if !ok || !w.moveFile(download, file.FilePath, target) {
+			continue
+		}
+
+		renamed[file.FilePath] = target
+		if err := w.db.UpdateExtractedFilePath(file.ID, target); err != nil {
+			w.logger.Warn("Failed to update renamed file", "download_id", download.ID, "file", target, "error", err)
+		}
+	}
+	if len(renamed) == 0 {
+		return
+	}
+
+	var paths []string
+	if err := json.Unmarshal([]byte(download.ExtractedFiles), &paths); err != nil {
+		w.logger.Warn("Failed to read extracted files list", "download_id", download.ID, "error", err)
+		return
+	}
+	for i, path := range paths {
+		if target, ok := renamed[path]; ok {
+			paths[i] = target
+		}
+	}
+
+	extractedFilesJSON, err := json.Marshal(paths)
+	if err != nil {
+		w.logger.Warn("Failed to marshal extracted files list", "download_id", download.ID, "error", err)
+		return
+	}
+	download.ExtractedFiles = string(extractedFilesJSON)
+	download.UpdatedAt = time.Now()
+	if err := w.db.UpdateDownload(download); err != nil {
+		w.logger.Warn("Failed to update download with renamed files", "download_id", download.ID, "error", err)
+	}
+}
+
+// renameTarget returns where a video file is renamed to inside the download's directory.
+// Files whose own names don't fit the template, such as obfuscated ones, are named after
+// the download instead.
+func (w *Worker) renameTarget(download *models.Download, path, template string) (string, bool) {
+	if !w.cleanup.IsVideo(path) {
+		return "", false
+	}
+
+	ext := filepath.Ext(path)
+	var renderErr error
+	for _, name := range []string{filepath.Base(path), download.Filename} {
+		relative, err := release.Render(template, release.Parse(name), ext)
+		if err == nil {
+			return filepath.Join(download.Directory, filepath.FromSlash(relative)), true
+		}
+		renderErr = err
+	}
+
+	w.logger.Info("File doesn't fit the rename template", "download_id", download.ID, "file", path, "error", renderErr)
+	return "", false
+}
+
+// moveFile moves a file to its renamed path without overwriting anything, then removes
+// the folders it leaves empty
+func (w *Worker) moveFile(download *models.Download, path, target string) bool {
+	if path == target {
+		return false
+	}
+	if _, err := os.Stat(target); err == nil {
+		w.logger.Warn("Not renaming file over an existing one", "download_id", download.ID, "file", path, "target", target)
+		return false
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		w.logger.Warn("Failed to create folder for renamed file", "download_id", download.ID, "folder", filepath.Dir(target), "error", err)
+		return false
+	}
+	if err := os.Rename(path, target); err != nil {
+		w.logger.Warn("Failed to rename file", "download_id", download.ID, "file", path, "target", target, "error", err)
+		return false
+	}
+
+	w.logger.Info("File renamed", "download_id", download.ID, "file", path, "target", target)
+
+	for dir := filepath.Dir(path); dir != download.Directory && strings.HasPrefix(dir, download.Directory+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return true
+}
@@ -10,10 +10,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/events"
 	"debrid-downloader/pkg/models"
 
 	"github.com/stretchr/testify/require"
@@ -116,6 +118,45 @@ func TestWorker_QueueDownload(t *testing.T) {
 	}
 }
 
+func TestWorker_NextDownloadByPriority(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	worker := NewWorker(db, "/tmp/test")
+
+	var ids []int64
+	for _, priority := range []int{0, 5, 0, 5} {
+		download := &models.Download{
+			OriginalURL: "https://example.com/file",
+			Filename:    "file",
+			Directory:   "/tmp/test",
+			Status:      models.StatusPending,
+			Priority:    priority,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		require.NoError(t, db.CreateDownload(download))
+		worker.queue <- download.ID
+		ids = append(ids, download.ID)
+	}
+	require.Equal(t, 4, worker.QueueDepth())
+
+	// Higher priorities first, oldest first within a priority. Each download finishes,
+	// freeing its place, before the next is taken.
+	var order []int64
+	for {
+		id, ok := worker.nextDownload()
+		if !ok {
+			break
+		}
+		order = append(order, id)
+		delete(worker.active, id)
+	}
+	require.Equal(t, []int64{ids[1], ids[3], ids[0], ids[2]}, order)
+	require.Zero(t, worker.QueueDepth())
+}
+
 func TestWorker_GetCurrentDownload(t *testing.T) {
 	db, err := database.New(":memory:")
 	require.NoError(t, err)
@@ -133,7 +174,7 @@ func TestWorker_GetCurrentDownload(t *testing.T) {
 		Filename: "test.txt",
 		Status:   models.StatusDownloading,
 	}
-	worker.currentDownload = download
+	worker.active[download.ID] = &activeDownload{download: download, started: time.Now()}
 
 	current = worker.GetCurrentDownload()
 	require.Equal(t, download, current)
@@ -164,7 +205,7 @@ func TestWorker_PauseCurrentDownload(t *testing.T) {
 	require.NoError(t, err)
 
 	// Test with current download
-	worker.currentDownload = download
+	worker.active[download.ID] = &activeDownload{download: download, started: time.Now()}
 
 	err = worker.PauseCurrentDownload()
 	require.NoError(t, err)
@@ -175,6 +216,84 @@ func TestWorker_PauseCurrentDownload(t *testing.T) {
 	require.Equal(t, models.StatusPaused, updatedDownload.Status)
 }
 
+func TestWorker_PauseDownloads(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	worker := NewWorker(db, "/tmp/test")
+	var published []events.Type
+	worker.Events().Subscribe(func(event events.Event) {
+		published = append(published, event.Type)
+	})
+
+	create := func(status models.DownloadStatus) *models.Download {
+		download := &models.Download{
+			OriginalURL: "https://example.com/file",
+			Filename:    "file",
+			Directory:   "/tmp/test",
+			Status:      status,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		require.NoError(t, db.CreateDownload(download))
+		return download
+	}
+	downloading := create(models.StatusDownloading)
+	pending := create(models.StatusPending)
+	completed := create(models.StatusCompleted)
+
+	canceled := false
+	worker.active[downloading.ID] = &activeDownload{
+		download: downloading,
+		cancel:   func() { canceled = true },
+		started:  time.Now(),
+	}
+
+	ids, err := worker.PauseDownloads(database.AllOwners, []int64{downloading.ID, pending.ID, completed.ID})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int64{downloading.ID, pending.ID}, ids)
+
+	// The download in progress is stopped and marked paused so it isn't retried
+	require.True(t, canceled)
+	require.True(t, worker.active[downloading.ID].paused)
+	require.Equal(t, models.StatusPaused, downloading.Status)
+
+	require.Equal(t, []events.Type{events.DownloadPaused, events.DownloadPaused}, published)
+
+	// A paused download taken off the queue isn't processed
+	delete(worker.active, downloading.ID)
+	worker.processDownload(context.Background(), pending.ID)
+	download, err := db.GetDownload(pending.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusPaused, download.Status)
+
+	// Nor is one that completed or failed since it was queued
+	for _, status := range []models.DownloadStatus{models.StatusCompleted, models.StatusFailed} {
+		queued := create(status)
+		worker.processDownload(context.Background(), queued.ID)
+		download, err := db.GetDownload(queued.ID)
+		require.NoError(t, err)
+		require.Equal(t, status, download.Status)
+		require.Equal(t, 0, download.RetryCount)
+	}
+}
+
+func TestWorker_SetPriority(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	worker := NewWorker(db, "/tmp/test")
+	worker.backlog = []queuedDownload{{id: 1}, {id: 2}, {id: 3}}
+
+	worker.SetPriority([]int64{3}, 10)
+
+	id, ok := worker.nextDownload()
+	require.True(t, ok)
+	require.Equal(t, int64(3), id)
+}
+
 func TestWorker_ResumeDownload(t *testing.T) {
 	db, err := database.New(":memory:")
 	require.NoError(t, err)
@@ -362,6 +481,123 @@ func TestWorker_ProcessDownloadError(t *testing.T) {
 	require.Equal(t, models.StatusFailed, updatedDownload.Status)
 }
 
+func TestWorker_SetOptions(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	worker := NewWorker(db, t.TempDir())
+	require.Equal(t, DefaultOptions().MaxRetries, worker.Options().MaxRetries)
+	worker.SetOptions(Options{MaxRetries: 2, RetryBackoff: time.Millisecond, ProgressInterval: time.Second, Timeout: time.Minute})
+
+	download := &models.Download{
+		OriginalURL:     server.URL + "/file.bin",
+		UnrestrictedURL: server.URL + "/file.bin",
+		Filename:        "file.bin",
+		Directory:       t.TempDir(),
+		Status:          models.StatusPending,
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(download))
+
+	// The download fails after the configured retries, with the configured backoff
+	worker.processDownload(context.Background(), download.ID)
+	require.Equal(t, int32(3), requests.Load())
+
+	updated, err := db.GetDownload(download.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusFailed, updated.Status)
+	require.Equal(t, 3, updated.RetryCount)
+
+	// Empty cleanup lists keep the built-in ones
+	require.True(t, worker.cleanup.IsVideo("movie.mkv"))
+}
+
+func TestWorker_Concurrency(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Each request holds its download open until released
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "4")
+		_, _ = w.Write([]byte("da"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+			_, _ = w.Write([]byte("ta"))
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	worker := NewWorker(db, t.TempDir())
+	opts := DefaultOptions()
+	opts.Concurrency = 2
+	worker.SetOptions(opts)
+
+	directory := t.TempDir()
+	var ids []int64
+	for _, name := range []string{"one.bin", "two.bin", "three.bin"} {
+		download := &models.Download{
+			OriginalURL:     server.URL + "/" + name,
+			UnrestrictedURL: server.URL + "/" + name,
+			Filename:        name,
+			Directory:       directory,
+			Status:          models.StatusPending,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+		}
+		require.NoError(t, db.CreateDownload(download))
+		worker.QueueDownload(download.ID)
+		ids = append(ids, download.ID)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		worker.Start(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// Two downloads run at once while the third waits
+	require.Eventually(t, func() bool { return len(worker.ActiveDownloads()) == 2 }, time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, worker.QueueDepth())
+
+	// Pausing one of them by ID frees its place for the waiting download
+	active := worker.ActiveDownloads()
+	require.NoError(t, worker.PauseDownload(active[1].ID))
+	require.Error(t, worker.PauseDownload(active[1].ID))
+
+	paused, err := db.GetDownload(active[1].ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusPaused, paused.Status)
+
+	require.Eventually(t, func() bool {
+		for _, download := range worker.ActiveDownloads() {
+			if download.ID == ids[2] {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+	require.Len(t, worker.ActiveDownloads(), 2)
+}
+
 func TestWorker_ProcessDownloadNonExistent(t *testing.T) {
 	db, err := database.New(":memory:")
 	require.NoError(t, err)
@@ -685,7 +921,7 @@ func TestWorker_ProcessArchiveNonExistentFile(t *testing.T) {
 	}
 
 	// Process archive (should handle non-existent file gracefully)
-	err = worker.processArchive(download)
+	err = worker.processArchive(download, models.CleanupVideo)
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "archive file not found")
 }
@@ -716,6 +952,43 @@ func TestWorker_MarkGroupCompleted(t *testing.T) {
 	require.Equal(t, models.GroupStatusCompleted, updatedGroup.Status)
 }
 
+func TestWorker_PublishesEvents(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	worker := NewWorker(db, "/tmp/test")
+
+	var received []events.Event
+	worker.Events().Subscribe(func(event events.Event) {
+		received = append(received, event)
+	})
+
+	download := &models.Download{
+		OriginalURL: "https://example.com/file.zip",
+		Filename:    "file.zip",
+		Directory:   "/tmp/test",
+		Status:      models.StatusPending,
+		OwnerID:     3,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(download))
+
+	group := &models.DownloadGroup{ID: "events-group", CreatedAt: time.Now(), Status: models.GroupStatusProcessing}
+	require.NoError(t, db.CreateDownloadGroup(group))
+
+	worker.QueueDownload(download.ID)
+	worker.markGroupFailed("events-group", "extraction failed")
+
+	require.Len(t, received, 2)
+	require.Equal(t, events.DownloadQueued, received[0].Type)
+	require.Equal(t, download.ID, received[0].Download.ID)
+	require.Equal(t, int64(3), received[0].OwnerID())
+	require.Equal(t, events.GroupFailed, received[1].Type)
+	require.Equal(t, models.GroupStatusFailed, received[1].Group.Status)
+}
+
 func TestWorker_MarkGroupFailed(t *testing.T) {
 	db, err := database.New(":memory:")
 	require.NoError(t, err)
@@ -917,6 +1190,167 @@ func TestWorker_ProcessGroupWithArchives(t *testing.T) {
 	time.Sleep(100 * time.Millisecond)
 }
 
+func TestWorker_ReprocessGroup(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	tempDir := t.TempDir()
+	worker := NewWorker(db, tempDir)
+
+	group := &models.DownloadGroup{
+		ID:              "reprocess",
+		CreatedAt:       time.Now(),
+		TotalDownloads:  2,
+		Status:          models.GroupStatusFailed,
+		ProcessingError: "Failed to process any archive files",
+	}
+	require.NoError(t, db.CreateDownloadGroup(group))
+	require.NoError(t, db.CreateDownload(&models.Download{
+		Filename:  "notes.txt",
+		Directory: tempDir,
+		Status:    models.StatusCompleted,
+		GroupID:   group.ID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}))
+
+	// The archive was extracted and deleted the first time, so only its files are left
+	require.NoError(t, db.CreateDownload(&models.Download{
+		Filename:       "film.part1.rar",
+		Directory:      tempDir,
+		Status:         models.StatusCompleted,
+		IsArchive:      true,
+		ExtractedFiles: fmt.Sprintf("[%q]", filepath.Join(tempDir, "film.mkv")),
+		GroupID:        group.ID,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}))
+
+	require.NoError(t, worker.ReprocessGroup(group.ID))
+	require.Eventually(t, func() bool {
+		found, err := db.GetDownloadGroup(group.ID)
+		return err == nil && found.Status == models.GroupStatusCompleted && found.ProcessingError == ""
+	}, time.Second, 10*time.Millisecond)
+
+	// A group already being processed isn't started twice
+	group.Status = models.GroupStatusProcessing
+	require.NoError(t, db.UpdateDownloadGroup(group))
+	require.Error(t, worker.ReprocessGroup(group.ID))
+}
+
+func TestWorker_ProcessGroupWithoutExtraction(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	tempDir := t.TempDir()
+	worker := NewWorker(db, tempDir)
+
+	category := models.NewCategory("software")
+	category.Extract = false
+	require.NoError(t, db.CreateCategory(category))
+
+	group := &models.DownloadGroup{ID: "no-extract", CreatedAt: time.Now(), TotalDownloads: 1, Status: models.GroupStatusProcessing}
+	require.NoError(t, db.CreateDownloadGroup(group))
+
+	archive := filepath.Join(tempDir, "setup.zip")
+	require.NoError(t, os.WriteFile(archive, []byte("not extracted"), 0o644))
+	require.NoError(t, db.CreateDownload(&models.Download{
+		Filename:  "setup.zip",
+		Directory: tempDir,
+		Status:    models.StatusCompleted,
+		GroupID:   group.ID,
+		IsArchive: true,
+		Category:  "software",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}))
+
+	worker.processGroup(group.ID)
+
+	found, err := db.GetDownloadGroup(group.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.GroupStatusCompleted, found.Status)
+	require.FileExists(t, archive)
+}
+
+func TestWorker_OrganiseDownload(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	tempDir := t.TempDir()
+	worker := NewWorker(db, tempDir)
+	template := "{show}/Season {season:02}/{show} - S{season:02}E{episode:02}.{ext}"
+
+	download := &models.Download{
+		Filename:  "The.Office.S03E07.720p.HDTV.x264.mkv",
+		Directory: tempDir,
+		Status:    models.StatusCompleted,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(download))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, download.Filename), []byte("video"), 0o644))
+
+	worker.organise(download, template)
+
+	renamed := filepath.Join(tempDir, "The Office", "Season 03", "The Office - S03E07.mkv")
+	require.FileExists(t, renamed)
+	found, err := db.GetDownload(download.ID)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Dir(renamed), found.Directory)
+	require.Equal(t, filepath.Base(renamed), found.Filename)
+
+	// A movie doesn't fit an episode template and keeps its name
+	movie := &models.Download{Filename: "Heat.1995.1080p.mkv", Directory: tempDir, Status: models.StatusCompleted, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	require.NoError(t, db.CreateDownload(movie))
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, movie.Filename), []byte("video"), 0o644))
+
+	worker.organise(movie, template)
+	require.FileExists(t, filepath.Join(tempDir, movie.Filename))
+}
+
+func TestWorker_OrganiseExtractedFiles(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	tempDir := t.TempDir()
+	worker := NewWorker(db, tempDir)
+
+	// An obfuscated file is named after the archive it came from
+	extracted := filepath.Join(tempDir, "Heat.1995.1080p.BluRay", "a1b2c3.mkv")
+	require.NoError(t, os.MkdirAll(filepath.Dir(extracted), 0o755))
+	require.NoError(t, os.WriteFile(extracted, []byte("video"), 0o644))
+
+	download := &models.Download{
+		Filename:       "Heat.1995.1080p.BluRay.rar",
+		Directory:      tempDir,
+		Status:         models.StatusCompleted,
+		ExtractedFiles: fmt.Sprintf("[%q]", extracted),
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(download))
+	require.NoError(t, db.CreateExtractedFile(&models.ExtractedFile{DownloadID: download.ID, FilePath: extracted, CreatedAt: time.Now()}))
+
+	worker.organise(download, "{title} ({year})/{title} ({year}).{ext}")
+
+	renamed := filepath.Join(tempDir, "Heat (1995)", "Heat (1995).mkv")
+	require.FileExists(t, renamed)
+	require.NoDirExists(t, filepath.Dir(extracted))
+
+	files, err := db.GetExtractedFilesByDownloadID(download.ID)
+	require.NoError(t, err)
+	require.Equal(t, renamed, files[0].FilePath)
+	found, err := db.GetDownload(download.ID)
+	require.NoError(t, err)
+	require.Contains(t, found.ExtractedFiles, "Heat (1995).mkv")
+	require.Equal(t, "Heat.1995.1080p.BluRay.rar", found.Filename)
+}
+
 func TestWorker_ProcessArchiveNonExistent(t *testing.T) {
 	db, err := database.New(":memory:")
 	require.NoError(t, err)
@@ -932,7 +1366,7 @@ func TestWorker_ProcessArchiveNonExistent(t *testing.T) {
 	}
 
 	// Process archive (should fail)
-	err = worker.processArchive(download)
+	err = worker.processArchive(download, models.CleanupVideo)
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "archive file not found")
 }
@@ -1026,7 +1460,7 @@ func TestWorker_ProcessArchiveRealExtraction(t *testing.T) {
 	require.NoError(t, err)
 
 	// Process should fail due to corrupt archive
-	err = worker.processArchive(download)
+	err = worker.processArchive(download, models.CleanupVideo)
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "failed to extract archive")
 }
@@ -1725,7 +2159,7 @@ func TestWorker_ProcessArchiveComprehensive(t *testing.T) {
 		require.NoError(t, err)
 
 		// Test the actual processArchive method
-		err = worker.processArchive(download)
+		err = worker.processArchive(download, models.CleanupVideo)
 		// This will likely fail due to extraction issues, but exercises the code paths
 		_ = err // Don't require success, just exercise the code
 	})
@@ -1737,7 +2171,7 @@ func TestWorker_ProcessArchiveComprehensive(t *testing.T) {
 			Directory: tempDir,
 		}
 
-		err = worker.processArchive(download)
+		err = worker.processArchive(download, models.CleanupVideo)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "archive file not found")
 	})
@@ -1755,7 +2189,7 @@ func TestWorker_ProcessArchiveComprehensive(t *testing.T) {
 		}
 
 		// This will exercise the cleanup service paths even if extraction fails
-		err = worker.processArchive(download)
+		err = worker.processArchive(download, models.CleanupVideo)
 		_ = err // Don't require success, focus on code coverage
 	})
 }
@@ -0,0 +1,107 @@
+// Package events lets other parts of the application react to download lifecycle changes
+package events
+
+import (
+	"sync"
+	"time"
+
+	"debrid-downloader/pkg/models"
+)
+
+// Type identifies what happened
+type Type string
+
+const (
+	DownloadQueued    Type = "download.queued"
+	DownloadStarted   Type = "download.started"
+	DownloadCompleted Type = "download.completed"
+	DownloadFailed    Type = "download.failed"
+	DownloadPaused    Type = "download.paused"
+	GroupCompleted    Type = "group.completed"
+	GroupFailed       Type = "group.failed"
+)
+
+// Types lists every event type in lifecycle order
+var Types = []Type{
+	DownloadQueued, DownloadStarted, DownloadCompleted, DownloadFailed, DownloadPaused,
+	GroupCompleted, GroupFailed,
+}
+
+// Valid reports whether t is a known event type
+func (t Type) Valid() bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Event describes a change to a download or, for group events, a download group
+type Event struct {
+	Type     Type
+	Time     time.Time
+	Download *models.Download      // Set for download events
+	Group    *models.DownloadGroup // Set for group events
+}
+
+// OwnerID returns the user the download or group belongs to
+func (e Event) OwnerID() int64 {
+	if e.Download != nil {
+		return e.Download.OwnerID
+	}
+	if e.Group != nil {
+		return e.Group.OwnerID
+	}
+	return 0
+}
+
+// Handler receives published events. Handlers run on the publisher's goroutine,
+// so anything slow should be handed off.
+type Handler func(Event)
+
+// Bus delivers events to subscribed handlers
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+// NewBus creates a new event bus
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers a handler for every published event
+func (b *Bus) Subscribe(handler Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+// Publish sends an event to every handler. Downloads and groups are copied so
+// handlers can keep them while the publisher carries on changing the originals.
+func (b *Bus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.Download != nil {
+		download := *event.Download
+		event.Download = &download
+	}
+	if event.Group != nil {
+		group := *event.Group
+		event.Group = &group
+	}
+
+	b.mu.RLock()
+	handlers := append([]Handler(nil), b.handlers...)
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
@@ -0,0 +1,39 @@
+package events
+
+import (
+	"testing"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus_Publish(t *testing.T) {
+	bus := NewBus()
+
+	var received []Event
+	bus.Subscribe(func(event Event) {
+		received = append(received, event)
+	})
+
+	download := &models.Download{ID: 1, Status: models.StatusCompleted, OwnerID: 2}
+	bus.Publish(Event{Type: DownloadCompleted, Download: download})
+
+	// Handlers get a copy, unaffected by later changes
+	download.Status = models.StatusFailed
+
+	require.Len(t, received, 1)
+	require.Equal(t, DownloadCompleted, received[0].Type)
+	require.Equal(t, models.StatusCompleted, received[0].Download.Status)
+	require.Equal(t, int64(2), received[0].OwnerID())
+	require.False(t, received[0].Time.IsZero())
+
+	// A nil bus drops events
+	var nilBus *Bus
+	nilBus.Publish(Event{Type: DownloadQueued})
+}
+
+func TestType_Valid(t *testing.T) {
+	require.True(t, GroupFailed.Valid())
+	require.False(t, Type("download.exploded").Valid())
+}
@@ -171,3 +171,18 @@ func (fs *Service) CreateDirectory(relativePath string) error {
 
 	return nil
 }
+
+// Subtree returns a service restricted to a folder below the base path
+func (fs *Service) Subtree(relativePath string) (*Service, error) {
+	fullPath, err := fs.ValidatePath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewService(fullPath), nil
+}
+
+// Contains reports whether an absolute path lies within the base path
+func (fs *Service) Contains(absolutePath string) bool {
+	cleaned := filepath.Clean(absolutePath)
+	return cleaned == fs.BasePath || strings.HasPrefix(cleaned, fs.BasePath+string(filepath.Separator))
+}
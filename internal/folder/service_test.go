@@ -465,3 +465,20 @@ func TestService_ListDirectoriesParentPathHandling(t *testing.T) {
 		require.Equal(t, "/level1", dirs[0].Path)
 	})
 }
+
+func TestService_Subtree(t *testing.T) {
+	service := NewService("/downloads")
+
+	sub, err := service.Subtree("alice")
+	require.NoError(t, err)
+	require.Equal(t, "/downloads/alice", sub.BasePath)
+
+	require.True(t, sub.Contains("/downloads/alice"))
+	require.True(t, sub.Contains("/downloads/alice/movies"))
+	require.False(t, sub.Contains("/downloads/alicex"))
+	require.False(t, sub.Contains("/downloads/bob"))
+	require.False(t, sub.Contains("/downloads/alice/../bob"))
+
+	_, err = service.Subtree("../etc")
+	require.Error(t, err)
+}
@@ -0,0 +1,365 @@
+// Package hooks runs user post-processing scripts when downloads and groups finish
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/events"
+	"debrid-downloader/internal/folder"
+	"debrid-downloader/pkg/models"
+)
+
+const (
+	queueSize = 100
+	// maxOutput is how much of a script's combined stdout and stderr is kept
+	maxOutput = 16 * 1024
+	// waitDelay is how long to wait for a killed script's children to release its output
+	waitDelay = 5 * time.Second
+)
+
+// Payload is the JSON written to a hook's stdin
+type Payload struct {
+	Event     events.Type           `json:"event"`
+	Timestamp time.Time             `json:"timestamp"`
+	Status    string                `json:"status"`
+	Directory string                `json:"directory"`
+	Files     []string              `json:"files"`
+	Error     string                `json:"error,omitempty"`
+	Group     *models.DownloadGroup `json:"group,omitempty"`
+	Downloads []*models.Download    `json:"downloads"`
+}
+
+// Runner runs the hook matching each finished download or group
+type Runner struct {
+	db         *database.DB
+	scriptsDir string
+	root       *folder.Service
+	queue      chan events.Event
+	logger     *slog.Logger
+}
+
+// NewRunner creates a runner for scripts in scriptsDir. Hook directories are
+// relative to basePath, the downloads directory.
+func NewRunner(db *database.DB, scriptsDir, basePath string) *Runner {
+	return &Runner{
+		db:         db,
+		scriptsDir: scriptsDir,
+		root:       folder.NewService(basePath),
+		queue:      make(chan events.Event, queueSize),
+		logger:     slog.Default(),
+	}
+}
+
+// Handle queues a hook run when a download outside a group or a whole group
+// completes or fails. It is meant to be subscribed to the worker's event bus and
+// never blocks it. Nothing runs while hooks are disabled.
+func (r *Runner) Handle(event events.Event) {
+	if r.scriptsDir == "" {
+		return
+	}
+
+	switch event.Type {
+	case events.DownloadCompleted, events.DownloadFailed:
+		if event.Download == nil || event.Download.GroupID != "" {
+			return
+		}
+	case events.GroupCompleted, events.GroupFailed:
+		if event.Group == nil {
+			return
+		}
+	default:
+		return
+	}
+
+	select {
+	case r.queue <- event:
+	default:
+		r.logger.Warn("Hook queue is full, skipping", "event", event.Type)
+	}
+}
+
+// Start runs queued hooks one at a time until the context is cancelled
+func (r *Runner) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-r.queue:
+			if err := r.Run(ctx, event); err != nil {
+				r.logger.Warn("Post-processing hook failed", "event", event.Type, "error", err)
+			}
+		}
+	}
+}
+
+// Run runs the hook matching an event's directory, if any, and records its exit
+// code and output on the download or group
+func (r *Runner) Run(ctx context.Context, event events.Event) error {
+	downloads := []*models.Download{event.Download}
+	if event.Group != nil {
+		var err error
+		downloads, err = r.db.GetDownloadsByGroupID(event.Group.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get group downloads: %w", err)
+		}
+		if len(downloads) == 0 {
+			return nil
+		}
+	}
+	directory := downloads[0].Directory
+
+	hook, err := r.match(downloads[0].Category, directory)
+	if err != nil || hook == nil {
+		return err
+	}
+
+	payload := newPayload(event, directory, downloads)
+	exitCode, output := r.execute(ctx, hook, payload)
+	r.logger.Info("Post-processing hook finished", "hook", hook.Name, "script", hook.Script, "directory", directory, "exit_code", exitCode)
+
+	if event.Group != nil {
+		return r.db.SetGroupHookResult(event.Group.ID, exitCode, output)
+	}
+	return r.db.SetDownloadHookResult(event.Download.ID, exitCode, output)
+}
+
+// match returns the hook for a download. A category's own hook wins; otherwise the
+// most specific hook for the directory is used: the one whose folder is the longest
+// prefix of it. Hooks without a folder match everything.
+func (r *Runner) match(categoryName, directory string) (*models.Hook, error) {
+	if categoryName != "" {
+		category, err := r.db.GetCategoryByName(categoryName)
+		if err == nil && category.HookID != 0 {
+			hook, err := r.db.GetHook(category.HookID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get category hook: %w", err)
+			}
+			return hook, nil
+		}
+	}
+
+	hooks, err := r.db.ListHooks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hooks: %w", err)
+	}
+
+	var best *models.Hook
+	longest := -1
+	for _, hook := range hooks {
+		var prefix string
+		if hook.Directory != "" {
+			if prefix, err = r.root.ValidatePath(hook.Directory); err != nil {
+				r.logger.Warn("Skipping hook with invalid path", "hook", hook.Name, "error", err)
+				continue
+			}
+			if !within(prefix, directory) {
+				continue
+			}
+		}
+		if len(prefix) > longest {
+			best, longest = hook, len(prefix)
+		}
+	}
+
+	return best, nil
+}
+
+// execute runs a hook's script with the payload on stdin, returning its exit code and
+// output. Scripts that can't be started or time out get exit code -1.
+func (r *Runner) execute(ctx context.Context, hook *models.Hook, payload Payload) (int, string) {
+	script, err := ScriptPath(r.scriptsDir, hook.Script)
+	if err != nil {
+		return -1, err.Error()
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return -1, fmt.Sprintf("failed to encode payload: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hook.Timeout())
+	defer cancel()
+
+	output := &limitedBuffer{limit: maxOutput}
+	cmd := exec.CommandContext(ctx, script)
+	cmd.Dir = payload.Directory
+	cmd.Env = append(baseEnvironment(), environment(payload)...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = waitDelay
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return -1, output.String() + fmt.Sprintf("\nhook timed out after %s", hook.Timeout())
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return -1, output.String() + fmt.Sprintf("\nfailed to run hook: %v", err)
+	}
+
+	return cmd.ProcessState.ExitCode(), output.String()
+}
+
+// newPayload describes a finished download or group for a hook
+func newPayload(event events.Event, directory string, downloads []*models.Download) Payload {
+	payload := Payload{
+		Event:     event.Type,
+		Timestamp: event.Time,
+		Status:    string(models.StatusCompleted),
+		Directory: directory,
+		Files:     files(downloads),
+		Group:     event.Group,
+		Downloads: downloads,
+	}
+
+	switch {
+	case event.Group != nil:
+		payload.Status = string(event.Group.Status)
+		payload.Error = event.Group.ProcessingError
+	case event.Download != nil:
+		payload.Status = string(event.Download.Status)
+		payload.Error = event.Download.ErrorMessage
+	}
+
+	return payload
+}
+
+// inheritedVariables are the only variables scripts get from the process environment,
+// which otherwise holds secrets such as the AllDebrid API key
+var inheritedVariables = []string{"PATH", "HOME", "TZ"}
+
+// baseEnvironment returns the inherited variables that are set
+func baseEnvironment() []string {
+	var env []string
+	for _, name := range inheritedVariables {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// environment returns the variables describing the payload to a script
+func environment(payload Payload) []string {
+	env := []string{
+		"DEBRID_EVENT=" + string(payload.Event),
+		"DEBRID_STATUS=" + payload.Status,
+		"DEBRID_DIRECTORY=" + payload.Directory,
+		"DEBRID_FILES=" + strings.Join(payload.Files, "\n"),
+		"DEBRID_ERROR=" + payload.Error,
+	}
+	if len(payload.Downloads) > 0 {
+		env = append(env, "DEBRID_FILENAME="+payload.Downloads[0].Filename)
+	}
+	if payload.Group != nil {
+		env = append(env, "DEBRID_GROUP_ID="+payload.Group.ID)
+	} else if len(payload.Downloads) > 0 {
+		env = append(env, "DEBRID_DOWNLOAD_ID="+strconv.FormatInt(payload.Downloads[0].ID, 10))
+	}
+	return env
+}
+
+// files lists the files left on disk by downloads: what was extracted from
+// archives, or the downloaded file itself
+func files(downloads []*models.Download) []string {
+	var paths []string
+	for _, download := range downloads {
+		candidates := []string{filepath.Join(download.Directory, download.Filename)}
+		if download.ExtractedFiles != "" {
+			var extracted []string
+			if err := json.Unmarshal([]byte(download.ExtractedFiles), &extracted); err == nil {
+				candidates = extracted
+			}
+		}
+		for _, path := range candidates {
+			if _, err := os.Stat(path); err == nil {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// within reports whether path is root or inside it
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// ScriptPath returns the full path of a script in the hooks directory, refusing
+// names that would leave it
+func ScriptPath(scriptsDir, name string) (string, error) {
+	if scriptsDir == "" {
+		return "", fmt.Errorf("hooks are disabled: HOOKS_PATH is not set")
+	}
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("invalid script name: %q", name)
+	}
+	return filepath.Join(scriptsDir, name), nil
+}
+
+// ListScripts returns the names of the executable files in the hooks directory
+func ListScripts(scriptsDir string) ([]string, error) {
+	if scriptsDir == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(scriptsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hooks directory: %w", err)
+	}
+
+	var scripts []string
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		scripts = append(scripts, entry.Name())
+	}
+	sort.Strings(scripts)
+
+	return scripts, nil
+}
+
+// limitedBuffer keeps the first limit bytes written to it and drops the rest
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write implements io.Writer, always reporting the whole write as successful so
+// the script isn't killed by a broken pipe
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining < len(p) {
+		b.truncated = true
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// String returns the kept output, noting when some was dropped
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
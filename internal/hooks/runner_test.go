@@ -0,0 +1,255 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/events"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testEnv struct {
+	runner     *Runner
+	db         *database.DB
+	scriptsDir string
+	basePath   string
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	scriptsDir := t.TempDir()
+	basePath := t.TempDir()
+	return &testEnv{
+		runner:     NewRunner(db, scriptsDir, basePath),
+		db:         db,
+		scriptsDir: scriptsDir,
+		basePath:   basePath,
+	}
+}
+
+func (e *testEnv) writeScript(t *testing.T, name, body string) {
+	require.NoError(t, os.WriteFile(filepath.Join(e.scriptsDir, name), []byte("#!/bin/sh\n"+body), 0o755))
+}
+
+func (e *testEnv) createHook(t *testing.T, hook *models.Hook) {
+	hook.CreatedAt = time.Now()
+	require.NoError(t, e.db.CreateHook(hook))
+}
+
+// createDownload creates a completed download with its file on disk
+func (e *testEnv) createDownload(t *testing.T, directory, filename, groupID string) *models.Download {
+	require.NoError(t, os.MkdirAll(directory, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(directory, filename), []byte("data"), 0o644))
+
+	download := &models.Download{
+		OriginalURL: "https://example.com/" + filename,
+		Filename:    filename,
+		Directory:   directory,
+		Status:      models.StatusCompleted,
+		GroupID:     groupID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	require.NoError(t, e.db.CreateDownload(download))
+	return download
+}
+
+func TestRunner_RunsGroupHook(t *testing.T) {
+	env := newTestEnv(t)
+	env.writeScript(t, "import.sh", `echo "$DEBRID_EVENT $DEBRID_STATUS $DEBRID_GROUP_ID $DEBRID_FILENAME"
+echo "$DEBRID_FILES"
+cat > "$DEBRID_DIRECTORY/payload.json"
+echo "warning" >&2
+exit 3
+`)
+	env.createHook(t, &models.Hook{Name: "Import", Script: "import.sh"})
+
+	group := &models.DownloadGroup{ID: "group-1", CreatedAt: time.Now(), Status: models.GroupStatusCompleted}
+	require.NoError(t, env.db.CreateDownloadGroup(group))
+	directory := filepath.Join(env.basePath, "movies")
+	first := env.createDownload(t, directory, "film.mkv", group.ID)
+	env.createDownload(t, directory, "film.nfo", group.ID)
+
+	require.NoError(t, env.runner.Run(context.Background(), events.Event{Type: events.GroupCompleted, Group: group}))
+
+	found, err := env.db.GetDownloadGroup(group.ID)
+	require.NoError(t, err)
+	require.NotNil(t, found.HookExitCode)
+	require.Equal(t, 3, *found.HookExitCode)
+	require.Contains(t, found.HookOutput, "group.completed completed group-1 film.mkv")
+	require.Contains(t, found.HookOutput, filepath.Join(directory, "film.nfo"))
+	require.Contains(t, found.HookOutput, "warning")
+
+	download, err := env.db.GetDownload(first.ID)
+	require.NoError(t, err)
+	require.Equal(t, 3, *download.HookExitCode)
+
+	body, err := os.ReadFile(filepath.Join(directory, "payload.json"))
+	require.NoError(t, err)
+	var payload Payload
+	require.NoError(t, json.Unmarshal(body, &payload))
+	require.Equal(t, events.GroupCompleted, payload.Event)
+	require.Equal(t, directory, payload.Directory)
+	require.Len(t, payload.Downloads, 2)
+	require.Len(t, payload.Files, 2)
+}
+
+func TestRunner_HidesSecretsFromScripts(t *testing.T) {
+	t.Setenv("ALLDEBRID_API_KEY", "secret-key")
+	t.Setenv("TZ", "Europe/Paris")
+
+	env := newTestEnv(t)
+	env.writeScript(t, "env.sh", `env`)
+	env.createHook(t, &models.Hook{Name: "Env", Script: "env.sh"})
+
+	directory := filepath.Join(env.basePath, "movies")
+	download := env.createDownload(t, directory, "film.mkv", "")
+	require.NoError(t, env.runner.Run(context.Background(), events.Event{Type: events.DownloadCompleted, Download: download}))
+
+	found, err := env.db.GetDownload(download.ID)
+	require.NoError(t, err)
+	require.Contains(t, found.HookOutput, "TZ=Europe/Paris")
+	require.Contains(t, found.HookOutput, "DEBRID_FILENAME=film.mkv")
+	require.NotContains(t, found.HookOutput, "secret-key")
+}
+
+func TestRunner_PicksMostSpecificHook(t *testing.T) {
+	env := newTestEnv(t)
+	env.writeScript(t, "name.sh", `echo "$0"`)
+	env.createHook(t, &models.Hook{Name: "Everything", Script: "name.sh"})
+	env.createHook(t, &models.Hook{Name: "TV", Script: "name.sh", Directory: "tv"})
+	env.createHook(t, &models.Hook{Name: "Anime", Script: "name.sh", Directory: "tv/anime"})
+
+	cases := map[string]string{
+		filepath.Join(env.basePath, "tv", "anime", "Show"): "Anime",
+		filepath.Join(env.basePath, "tv", "Show"):          "TV",
+		filepath.Join(env.basePath, "tvshows"):             "Everything",
+		filepath.Join(env.basePath, "movies"):              "Everything",
+	}
+	for directory, expected := range cases {
+		hook, err := env.runner.match("", directory)
+		require.NoError(t, err)
+		require.Equal(t, expected, hook.Name, directory)
+	}
+}
+
+func TestRunner_PrefersCategoryHook(t *testing.T) {
+	env := newTestEnv(t)
+	env.createHook(t, &models.Hook{Name: "TV", Script: "name.sh", Directory: "tv"})
+	kids := &models.Hook{Name: "Kids", Script: "name.sh"}
+	env.createHook(t, kids)
+
+	category := models.NewCategory("kids")
+	category.Directory = "tv"
+	category.HookID = kids.ID
+	require.NoError(t, env.db.CreateCategory(category))
+	require.NoError(t, env.db.CreateCategory(models.NewCategory("tv")))
+
+	directory := filepath.Join(env.basePath, "tv", "Show")
+	hook, err := env.runner.match("kids", directory)
+	require.NoError(t, err)
+	require.Equal(t, "Kids", hook.Name)
+
+	// Categories without a hook fall back to folder matching
+	hook, err = env.runner.match("tv", directory)
+	require.NoError(t, err)
+	require.Equal(t, "TV", hook.Name)
+
+	// Deleting the hook unsets it on the category
+	require.NoError(t, env.db.DeleteHook(kids.ID))
+	found, err := env.db.GetCategoryByName("kids")
+	require.NoError(t, err)
+	require.Zero(t, found.HookID)
+}
+
+func TestRunner_WithoutMatchingHook(t *testing.T) {
+	env := newTestEnv(t)
+	env.createHook(t, &models.Hook{Name: "TV", Script: "missing.sh", Directory: "tv"})
+
+	download := env.createDownload(t, filepath.Join(env.basePath, "movies"), "film.mkv", "")
+	require.NoError(t, env.runner.Run(context.Background(), events.Event{Type: events.DownloadCompleted, Download: download}))
+
+	found, err := env.db.GetDownload(download.ID)
+	require.NoError(t, err)
+	require.Nil(t, found.HookExitCode)
+}
+
+func TestRunner_RecordsFailuresToRun(t *testing.T) {
+	env := newTestEnv(t)
+	env.writeScript(t, "slow.sh", "exec sleep 10\n")
+	env.createHook(t, &models.Hook{Name: "Slow", Script: "slow.sh", Directory: "slow", TimeoutSeconds: 1})
+	env.createHook(t, &models.Hook{Name: "Missing", Script: "missing.sh", Directory: "missing"})
+
+	slow := env.createDownload(t, filepath.Join(env.basePath, "slow"), "a.mkv", "")
+	require.NoError(t, env.runner.Run(context.Background(), events.Event{Type: events.DownloadCompleted, Download: slow}))
+	found, err := env.db.GetDownload(slow.ID)
+	require.NoError(t, err)
+	require.Equal(t, -1, *found.HookExitCode)
+	require.Contains(t, found.HookOutput, "hook timed out after 1s")
+
+	missing := env.createDownload(t, filepath.Join(env.basePath, "missing"), "b.mkv", "")
+	require.NoError(t, env.runner.Run(context.Background(), events.Event{Type: events.DownloadFailed, Download: missing}))
+	found, err = env.db.GetDownload(missing.ID)
+	require.NoError(t, err)
+	require.Equal(t, -1, *found.HookExitCode)
+	require.Contains(t, found.HookOutput, "failed to run hook")
+}
+
+func TestRunner_Handle(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.runner.Handle(events.Event{Type: events.DownloadCompleted, Download: &models.Download{GroupID: "group-1"}})
+	env.runner.Handle(events.Event{Type: events.DownloadStarted, Download: &models.Download{}})
+	require.Empty(t, env.runner.queue)
+
+	env.runner.Handle(events.Event{Type: events.DownloadFailed, Download: &models.Download{}})
+	env.runner.Handle(events.Event{Type: events.GroupCompleted, Group: &models.DownloadGroup{ID: "group-1"}})
+	require.Len(t, env.runner.queue, 2)
+}
+
+func TestScriptPath(t *testing.T) {
+	path, err := ScriptPath("/hooks", "import.sh")
+	require.NoError(t, err)
+	require.Equal(t, "/hooks/import.sh", path)
+
+	for _, name := range []string{"", "..", "../bin/sh", "sub/import.sh"} {
+		_, err := ScriptPath("/hooks", name)
+		require.Error(t, err, name)
+	}
+
+	_, err = ScriptPath("", "import.sh")
+	require.ErrorContains(t, err, "HOOKS_PATH")
+}
+
+func TestListScripts(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.sh"), []byte("#!/bin/sh\n"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.py"), []byte("#!/usr/bin/env python3\n"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("notes"), 0o644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "lib"), 0o755))
+
+	scripts, err := ListScripts(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a.py", "b.sh"}, scripts)
+}
+
+func TestLimitedBuffer(t *testing.T) {
+	buf := &limitedBuffer{limit: 5}
+	n, err := buf.Write([]byte("abc"))
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	n, err = buf.Write([]byte("defgh"))
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+	require.Equal(t, "abcde\n[output truncated]", buf.String())
+}
@@ -0,0 +1,120 @@
+package mediaserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"debrid-downloader/pkg/models"
+)
+
+// refreshJellyfin reports a changed path to Jellyfin or Emby, which scan just that folder
+func refreshJellyfin(ctx context.Context, client *http.Client, server *models.MediaServer, path string) error {
+	payload := map[string]any{
+		"Updates": []map[string]string{{"Path": path, "UpdateType": "Created"}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	endpoint := strings.TrimRight(server.URL, "/") + "/Library/Media/Updated"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Emby-Token", server.Token)
+
+	_, err = do(client, req)
+	return err
+}
+
+// plexSections is the response of Plex's /library/sections
+type plexSections struct {
+	Directories []struct {
+		Key       string `xml:"key,attr"`
+		Title     string `xml:"title,attr"`
+		Locations []struct {
+			Path string `xml:"path,attr"`
+		} `xml:"Location"`
+	} `xml:"Directory"`
+}
+
+// refreshPlex finds the Plex library containing the path and scans that folder of it
+func refreshPlex(ctx context.Context, client *http.Client, server *models.MediaServer, path string) error {
+	base := strings.TrimRight(server.URL, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/library/sections", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/xml")
+	req.Header.Set("X-Plex-Token", server.Token)
+
+	body, err := do(client, req)
+	if err != nil {
+		return fmt.Errorf("failed to list libraries: %w", err)
+	}
+
+	var sections plexSections
+	if err := xml.Unmarshal(body, &sections); err != nil {
+		return fmt.Errorf("failed to decode libraries: %w", err)
+	}
+
+	// Use the library whose folder most closely contains the path
+	sectionKey, longest := "", -1
+	for _, section := range sections.Directories {
+		for _, location := range section.Locations {
+			if containsPath(location.Path, path) && len(location.Path) > longest {
+				sectionKey, longest = section.Key, len(location.Path)
+			}
+		}
+	}
+	if sectionKey == "" {
+		return fmt.Errorf("no Plex library contains %s", path)
+	}
+
+	endpoint := fmt.Sprintf("%s/library/sections/%s/refresh?path=%s", base, url.PathEscape(sectionKey), url.QueryEscape(path))
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Plex-Token", server.Token)
+
+	_, err = do(client, req)
+	return err
+}
+
+// containsPath reports whether path is root or inside it, for either slash style
+func containsPath(root, path string) bool {
+	root = strings.TrimRight(root, `/\`)
+	if path == root {
+		return true
+	}
+	return strings.HasPrefix(path, root+"/") || strings.HasPrefix(path, root+`\`)
+}
+
+// do sends a request and returns the body, or an error for non-2xx responses
+func do(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return body, nil
+}
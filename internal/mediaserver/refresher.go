@@ -0,0 +1,131 @@
+// Package mediaserver asks Jellyfin, Emby and Plex to scan the folders finished
+// downloads were saved to, so new files show up without a manual library scan
+package mediaserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/events"
+	"debrid-downloader/pkg/models"
+)
+
+const (
+	requestTimeout = 30 * time.Second
+	queueSize      = 100
+)
+
+// Refresher triggers library scans for directories downloads finish in
+type Refresher struct {
+	db     *database.DB
+	client *http.Client
+	queue  chan string
+	logger *slog.Logger
+}
+
+// NewRefresher creates a new media server refresher
+func NewRefresher(db *database.DB) *Refresher {
+	return &Refresher{
+		db:     db,
+		client: &http.Client{Timeout: requestTimeout},
+		queue:  make(chan string, queueSize),
+		logger: slog.Default(),
+	}
+}
+
+// Handle queues a refresh when a download outside a group completes, or when a
+// group completes after post-processing. It is meant to be subscribed to the worker's
+// event bus and never blocks it.
+func (r *Refresher) Handle(event events.Event) {
+	var directories []string
+
+	switch event.Type {
+	case events.DownloadCompleted:
+		// Grouped downloads are refreshed once the whole group has been extracted
+		if event.Download != nil && event.Download.GroupID == "" {
+			directories = append(directories, event.Download.Directory)
+		}
+	case events.GroupCompleted:
+		if event.Group == nil {
+			return
+		}
+		downloads, err := r.db.GetDownloadsByGroupID(event.Group.ID)
+		if err != nil {
+			r.logger.Warn("Failed to get group downloads for library refresh", "group_id", event.Group.ID, "error", err)
+			return
+		}
+		seen := make(map[string]bool)
+		for _, download := range downloads {
+			if !seen[download.Directory] {
+				seen[download.Directory] = true
+				directories = append(directories, download.Directory)
+			}
+		}
+	}
+
+	for _, directory := range directories {
+		select {
+		case r.queue <- directory:
+		default:
+			r.logger.Warn("Library refresh queue is full, skipping", "directory", directory)
+		}
+	}
+}
+
+// Start runs queued refreshes until the context is cancelled
+func (r *Refresher) Start(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case directory := <-r.queue:
+			if err := r.Refresh(ctx, directory); err != nil {
+				r.logger.Warn("Library refresh failed", "directory", directory, "error", err)
+			}
+		}
+	}
+}
+
+// Refresh asks every media server whose path contains the directory to scan it
+func (r *Refresher) Refresh(ctx context.Context, directory string) error {
+	servers, err := r.db.ListMediaServers()
+	if err != nil {
+		return fmt.Errorf("failed to list media servers: %w", err)
+	}
+
+	var errs []error
+	for _, server := range servers {
+		remotePath, ok := server.TranslatePath(directory)
+		if !ok {
+			continue
+		}
+
+		if err := r.refreshServer(ctx, server, remotePath); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", server.Name, err))
+			continue
+		}
+		r.logger.Info("Library refresh requested", "server", server.Name, "kind", server.Kind, "path", remotePath)
+	}
+
+	return errors.Join(errs...)
+}
+
+// refreshServer asks one media server to scan a path in its own view of the filesystem
+func (r *Refresher) refreshServer(ctx context.Context, server *models.MediaServer, remotePath string) error {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	switch server.Kind {
+	case models.MediaServerJellyfin, models.MediaServerEmby:
+		return refreshJellyfin(ctx, r.client, server, remotePath)
+	case models.MediaServerPlex:
+		return refreshPlex(ctx, r.client, server, remotePath)
+	default:
+		return fmt.Errorf("unsupported media server kind %q", server.Kind)
+	}
+}
@@ -0,0 +1,182 @@
+package mediaserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/events"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	sections string
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.requests = append(rec.requests, r.Clone(context.Background()))
+	rec.bodies = append(rec.bodies, string(body))
+
+	if r.URL.Path == "/library/sections" {
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = io.WriteString(w, rec.sections)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newTestRefresher(t *testing.T) (*Refresher, *database.DB) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return NewRefresher(db), db
+}
+
+func createServer(t *testing.T, db *database.DB, kind models.MediaServerKind, url, pathPrefix, remotePrefix string) {
+	require.NoError(t, db.CreateMediaServer(&models.MediaServer{
+		Name:         string(kind),
+		Kind:         kind,
+		URL:          url,
+		Token:        "secret-token",
+		PathPrefix:   pathPrefix,
+		RemotePrefix: remotePrefix,
+		CreatedAt:    time.Now(),
+	}))
+}
+
+func TestRefresher_Jellyfin(t *testing.T) {
+	refresher, db := newTestRefresher(t)
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	createServer(t, db, models.MediaServerJellyfin, server.URL+"/", "/downloads/movies", "/media/movies")
+
+	require.NoError(t, refresher.Refresh(context.Background(), "/downloads/movies/Film (2024)"))
+
+	require.Len(t, rec.requests, 1)
+	require.Equal(t, http.MethodPost, rec.requests[0].Method)
+	require.Equal(t, "/Library/Media/Updated", rec.requests[0].URL.Path)
+	require.Equal(t, "secret-token", rec.requests[0].Header.Get("X-Emby-Token"))
+
+	var payload struct {
+		Updates []struct {
+			Path       string
+			UpdateType string
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(rec.bodies[0]), &payload))
+	require.Len(t, payload.Updates, 1)
+	require.Equal(t, "/media/movies/Film (2024)", payload.Updates[0].Path)
+	require.Equal(t, "Created", payload.Updates[0].UpdateType)
+}
+
+func TestRefresher_Plex(t *testing.T) {
+	refresher, db := newTestRefresher(t)
+	rec := &recorder{sections: `<MediaContainer>
+		<Directory key="1" title="Media"><Location path="/data"/></Directory>
+		<Directory key="2" title="Movies"><Location path="/data/movies"/></Directory>
+		<Directory key="3" title="TV"><Location path="/data/tv"/></Directory>
+	</MediaContainer>`}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	createServer(t, db, models.MediaServerPlex, server.URL, "/downloads", "/data")
+
+	require.NoError(t, refresher.Refresh(context.Background(), "/downloads/movies/Film"))
+
+	require.Len(t, rec.requests, 2)
+	require.Equal(t, "secret-token", rec.requests[0].Header.Get("X-Plex-Token"))
+	// The most specific library wins
+	require.Equal(t, "/library/sections/2/refresh", rec.requests[1].URL.Path)
+	require.Equal(t, "/data/movies/Film", rec.requests[1].URL.Query().Get("path"))
+	require.Equal(t, "secret-token", rec.requests[1].Header.Get("X-Plex-Token"))
+}
+
+func TestRefresher_PlexWithoutMatchingLibrary(t *testing.T) {
+	refresher, db := newTestRefresher(t)
+	rec := &recorder{sections: `<MediaContainer><Directory key="3" title="TV"><Location path="/data/tv"/></Directory></MediaContainer>`}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	createServer(t, db, models.MediaServerPlex, server.URL, "/downloads", "/data")
+
+	err := refresher.Refresh(context.Background(), "/downloads/movies")
+	require.ErrorContains(t, err, "no Plex library contains /data/movies")
+	require.Len(t, rec.requests, 1)
+}
+
+func TestRefresher_SkipsServersOutsidePath(t *testing.T) {
+	refresher, db := newTestRefresher(t)
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	createServer(t, db, models.MediaServerEmby, server.URL, "/downloads/tv", "")
+
+	require.NoError(t, refresher.Refresh(context.Background(), "/downloads/movies"))
+	require.NoError(t, refresher.Refresh(context.Background(), "/downloads/tv-extras"))
+	require.Empty(t, rec.requests)
+
+	require.NoError(t, refresher.Refresh(context.Background(), "/downloads/tv/Show"))
+	require.Len(t, rec.requests, 1)
+}
+
+func TestRefresher_ReportsServerErrors(t *testing.T) {
+	refresher, db := newTestRefresher(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	createServer(t, db, models.MediaServerJellyfin, server.URL, "/downloads", "")
+
+	err := refresher.Refresh(context.Background(), "/downloads")
+	require.ErrorContains(t, err, "unexpected status 401")
+}
+
+func TestRefresher_Handle(t *testing.T) {
+	refresher, db := newTestRefresher(t)
+
+	group := &models.DownloadGroup{ID: "group-1", CreatedAt: time.Now(), Status: models.GroupStatusCompleted}
+	require.NoError(t, db.CreateDownloadGroup(group))
+	for _, filename := range []string{"a.part1.rar", "a.part2.rar"} {
+		require.NoError(t, db.CreateDownload(&models.Download{
+			OriginalURL: "https://example.com/" + filename,
+			Filename:    filename,
+			Directory:   "/downloads/movies",
+			Status:      models.StatusCompleted,
+			GroupID:     group.ID,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}))
+	}
+
+	// Grouped downloads wait for the group to complete
+	refresher.Handle(events.Event{Type: events.DownloadCompleted, Download: &models.Download{Directory: "/downloads/movies", GroupID: group.ID}})
+	refresher.Handle(events.Event{Type: events.DownloadFailed, Download: &models.Download{Directory: "/downloads/other"}})
+	require.Empty(t, refresher.queue)
+
+	refresher.Handle(events.Event{Type: events.DownloadCompleted, Download: &models.Download{Directory: "/downloads/single"}})
+	refresher.Handle(events.Event{Type: events.GroupCompleted, Group: group})
+
+	require.Len(t, refresher.queue, 2)
+	require.Equal(t, "/downloads/single", <-refresher.queue)
+	require.Equal(t, "/downloads/movies", <-refresher.queue)
+}
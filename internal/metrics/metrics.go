@@ -0,0 +1,209 @@
+// Package metrics exposes download, provider and post-processing metrics in the
+// Prometheus format
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/pkg/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "debrid"
+
+// Counters and histograms updated as work happens. They live for the life of the process.
+var (
+	DownloadedBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "downloaded_bytes_total",
+		Help:      "Bytes written to disk by the download worker.",
+	})
+
+	DownloadRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "download_retries_total",
+		Help:      "Download attempts that failed and were retried.",
+	})
+
+	UnrestrictDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "unrestrict_duration_seconds",
+		Help:      "Time taken by the debrid provider to unrestrict a link.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+	})
+
+	UnrestrictErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "unrestrict_errors_total",
+		Help:      "Failed unrestrict requests by provider error code.",
+	}, []string{"code"})
+
+	ExtractionDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "extraction_duration_seconds",
+		Help:      "Time taken to extract archives.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"result"})
+
+	CleanupFreedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cleanup_freed_bytes_total",
+		Help:      "Bytes freed by deleting archives after extraction and unwanted extracted files.",
+	}, []string{"kind"})
+)
+
+// Labels used with CleanupFreedBytes
+const (
+	CleanupArchive   = "archive"
+	CleanupExtracted = "extracted"
+)
+
+// Labels used with ExtractionDuration
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// transportErrorCode labels unrestrict errors that didn't come with a provider error code
+const transportErrorCode = "request_failed"
+
+// Worker is the part of the download worker the metrics read from
+type Worker interface {
+	QueueDepth() int
+}
+
+// Handler returns an http.Handler serving every metric, with gauges read from the
+// database and worker on each scrape
+func Handler(db *database.DB, worker Worker) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		DownloadedBytes,
+		DownloadRetries,
+		UnrestrictDuration,
+		UnrestrictErrors,
+		ExtractionDuration,
+		CleanupFreedBytes,
+		newStateCollector(db, worker),
+	)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// stateCollector reports gauges from the database and worker at scrape time
+type stateCollector struct {
+	db     *database.DB
+	worker Worker
+	logger *slog.Logger
+
+	downloads        *prometheus.Desc
+	historyBytes     *prometheus.Desc
+	historyRetries   *prometheus.Desc
+	speed            *prometheus.Desc
+	queueDepth       *prometheus.Desc
+	scrapeErrors     *prometheus.Desc
+	downloadStatuses []models.DownloadStatus
+}
+
+func newStateCollector(db *database.DB, worker Worker) *stateCollector {
+	return &stateCollector{
+		db:     db,
+		worker: worker,
+		logger: slog.Default(),
+		downloads: prometheus.NewDesc(namespace+"_downloads",
+			"Downloads in the history by status.", []string{"status"}, nil),
+		historyBytes: prometheus.NewDesc(namespace+"_downloads_downloaded_bytes",
+			"Bytes downloaded across every download in the history.", nil, nil),
+		historyRetries: prometheus.NewDesc(namespace+"_downloads_retries",
+			"Retries made across every download in the history.", nil, nil),
+		speed: prometheus.NewDesc(namespace+"_download_speed_bytes",
+			"Current aggregate download speed in bytes per second.", nil, nil),
+		queueDepth: prometheus.NewDesc(namespace+"_queue_depth",
+			"Downloads waiting in the worker queue.", nil, nil),
+		scrapeErrors: prometheus.NewDesc(namespace+"_metrics_scrape_error",
+			"1 if reading metrics from the database failed during this scrape.", nil, nil),
+		downloadStatuses: []models.DownloadStatus{
+			models.StatusPending, models.StatusDownloading, models.StatusCompleted,
+			models.StatusFailed, models.StatusPaused,
+		},
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.downloads
+	ch <- c.historyBytes
+	ch <- c.historyRetries
+	ch <- c.speed
+	ch <- c.queueDepth
+	ch <- c.scrapeErrors
+}
+
+// Collect implements prometheus.Collector
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	scrapeError := 0.0
+
+	stats, err := c.db.GetDownloadStats()
+	if err != nil {
+		c.logger.Error("Failed to read download stats for metrics", "error", err)
+		scrapeError = 1
+	} else {
+		for _, status := range c.downloadStatuses {
+			ch <- prometheus.MustNewConstMetric(c.downloads, prometheus.GaugeValue, float64(stats[string(status)]), string(status))
+		}
+	}
+
+	totals, err := c.db.GetDownloadTotals()
+	if err != nil {
+		c.logger.Error("Failed to read download totals for metrics", "error", err)
+		scrapeError = 1
+	} else {
+		ch <- prometheus.MustNewConstMetric(c.historyBytes, prometheus.GaugeValue, float64(totals.DownloadedBytes))
+		ch <- prometheus.MustNewConstMetric(c.historyRetries, prometheus.GaugeValue, float64(totals.Retries))
+		ch <- prometheus.MustNewConstMetric(c.speed, prometheus.GaugeValue, totals.Speed)
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(c.worker.QueueDepth()))
+	ch <- prometheus.MustNewConstMetric(c.scrapeErrors, prometheus.GaugeValue, scrapeError)
+}
+
+// instrumentedClient records unrestrict latency and errors for an AllDebrid client
+type instrumentedClient struct {
+	alldebrid.AllDebridClient
+}
+
+// InstrumentClient wraps a client so its unrestrict calls are recorded
+func InstrumentClient(client alldebrid.AllDebridClient) alldebrid.AllDebridClient {
+	return &instrumentedClient{AllDebridClient: client}
+}
+
+// UnrestrictLink unrestricts a link, recording how long it took and any error code
+func (c *instrumentedClient) UnrestrictLink(ctx context.Context, link string) (*alldebrid.UnrestrictResult, error) {
+	start := time.Now()
+	result, err := c.AllDebridClient.UnrestrictLink(ctx, link)
+	UnrestrictDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		UnrestrictErrors.WithLabelValues(ErrorCode(err)).Inc()
+	}
+	return result, err
+}
+
+// ErrorCode returns the provider's error code for an unrestrict error, or
+// "request_failed" when the request failed before the provider answered
+func ErrorCode(err error) string {
+	var apiErr *alldebrid.APIError
+	if errors.As(err, &apiErr) && apiErr.Code != nil {
+		return fmt.Sprint(apiErr.Code)
+	}
+	return transportErrorCode
+}
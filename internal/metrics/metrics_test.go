@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/alldebrid/mocks"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/pkg/models"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type fakeWorker struct {
+	depth int
+}
+
+func (w fakeWorker) QueueDepth() int { return w.depth }
+
+func TestHandler_ReportsDatabaseAndWorkerState(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, download := range []*models.Download{
+		{Status: models.StatusCompleted, DownloadedBytes: 1000, RetryCount: 2},
+		{Status: models.StatusCompleted, DownloadedBytes: 500},
+		{Status: models.StatusDownloading, DownloadedBytes: 250, DownloadSpeed: 2048},
+	} {
+		download.OriginalURL = "https://example.com/file"
+		download.Filename = "file"
+		download.Directory = "/downloads"
+		download.CreatedAt = time.Now()
+		download.UpdatedAt = time.Now()
+		require.NoError(t, db.CreateDownload(download))
+	}
+
+	DownloadedBytes.Add(42)
+
+	w := httptest.NewRecorder()
+	Handler(db, fakeWorker{depth: 3}).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	body := w.Body.String()
+
+	require.Contains(t, body, `debrid_downloads{status="completed"} 2`)
+	require.Contains(t, body, `debrid_downloads{status="failed"} 0`)
+	require.Contains(t, body, "debrid_downloads_downloaded_bytes 1750")
+	require.Contains(t, body, "debrid_downloads_retries 2")
+	require.Contains(t, body, "debrid_download_speed_bytes 2048")
+	require.Contains(t, body, "debrid_queue_depth 3")
+	require.Contains(t, body, "debrid_metrics_scrape_error 0")
+	require.Contains(t, body, "debrid_downloaded_bytes_total")
+	require.Contains(t, body, "go_goroutines")
+}
+
+func TestInstrumentClient(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	client := mocks.NewMockAllDebridClient(ctrl)
+
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/ok").
+		Return(&alldebrid.UnrestrictResult{Filename: "ok"}, nil)
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/dead").
+		Return(nil, &alldebrid.APIError{Message: "Link is dead", Code: "LINK_DOWN"})
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/timeout").
+		Return(nil, errors.New("failed to make request: timeout"))
+
+	linkDown := testutil.ToFloat64(UnrestrictErrors.WithLabelValues("LINK_DOWN"))
+	requestFailed := testutil.ToFloat64(UnrestrictErrors.WithLabelValues("request_failed"))
+
+	instrumented := InstrumentClient(client)
+	_, err := instrumented.UnrestrictLink(context.Background(), "https://example.com/ok")
+	require.NoError(t, err)
+	_, err = instrumented.UnrestrictLink(context.Background(), "https://example.com/dead")
+	require.Error(t, err)
+	_, err = instrumented.UnrestrictLink(context.Background(), "https://example.com/timeout")
+	require.Error(t, err)
+
+	require.Equal(t, linkDown+1, testutil.ToFloat64(UnrestrictErrors.WithLabelValues("LINK_DOWN")))
+	require.Equal(t, requestFailed+1, testutil.ToFloat64(UnrestrictErrors.WithLabelValues("request_failed")))
+
+	// Other calls pass straight through
+	client.EXPECT().CheckAPIKey(gomock.Any()).Return(nil)
+	require.NoError(t, instrumented.CheckAPIKey(context.Background()))
+}
+
+func TestErrorCode(t *testing.T) {
+	require.Equal(t, "42", ErrorCode(&alldebrid.APIError{Message: "numeric", Code: 42}))
+	require.Equal(t, "request_failed", ErrorCode(&alldebrid.APIError{Message: "no code"}))
+	require.Equal(t, "request_failed", ErrorCode(errors.New("boom")))
+}
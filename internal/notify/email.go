@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EmailOptions configures the SMTP server notifications are sent through
+type EmailOptions struct {
+	Host     string
+	Port     int
+	Username string // Leave empty for servers that don't require authentication
+	Password string
+	From     string
+	To       []string
+}
+
+// Email sends notifications over SMTP
+type Email struct {
+	opts EmailOptions
+}
+
+// NewEmail creates an email notifier
+func NewEmail(opts EmailOptions) *Email {
+	return &Email{opts: opts}
+}
+
+// Name returns the provider name
+func (e *Email) Name() string { return "email" }
+
+// Send emails the message to every recipient. STARTTLS is used when the server offers it.
+func (e *Email) Send(ctx context.Context, msg Message) error {
+	addr := net.JoinHostPort(e.opts.Host, strconv.Itoa(e.opts.Port))
+
+	var auth smtp.Auth
+	if e.opts.Username != "" {
+		auth = smtp.PlainAuth("", e.opts.Username, e.opts.Password, e.opts.Host)
+	}
+
+	// net/smtp has no context support, so run it alongside the context and give up on cancellation
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, auth, e.opts.From, e.opts.To, e.message(msg))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("failed to send email: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to send email: %w", ctx.Err())
+	}
+}
+
+// message builds the RFC 5322 message
+func (e *Email) message(msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", e.opts.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(e.opts.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "[debrid-downloader] "+msg.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,290 @@
+// Package notify sends human-readable notifications of download events to
+// services such as ntfy, Gotify, Discord, Telegram and email
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"text/template"
+	"time"
+
+	"debrid-downloader/internal/config"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/events"
+	"debrid-downloader/pkg/models"
+)
+
+// DefaultTemplate is the message body used when a provider has no template configured
+const DefaultTemplate = `{{.Name}}{{if .Directory}} in {{.Directory}}{{end}}{{if .Error}}
+{{.Error}}{{end}}`
+
+const (
+	sendTimeout = 15 * time.Second
+	queueSize   = 100
+)
+
+// Message is a rendered notification
+type Message struct {
+	Event events.Type
+	Title string
+	Body  string
+}
+
+// Failure reports whether the message is about something going wrong, which
+// providers with priorities send at a higher priority
+func (m Message) Failure() bool {
+	return m.Event == events.DownloadFailed || m.Event == events.GroupFailed
+}
+
+// Notifier delivers a message to one provider
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// TemplateData is what message templates are executed with
+type TemplateData struct {
+	Event     events.Type
+	Title     string
+	Name      string // The download's filename, or the group's first file and how many others
+	Directory string
+	Error     string
+	Download  *models.Download      // Set for download events
+	Group     *models.DownloadGroup // Set for group events
+	Files     []*models.Download    // A group's downloads, for group events
+}
+
+// Channel is a notifier with the events it wants and the template its messages use
+type Channel struct {
+	Notifier Notifier
+	Events   []events.Type
+	Template *template.Template
+}
+
+// NewChannel creates a channel, parsing the template or using DefaultTemplate when it is empty
+func NewChannel(notifier Notifier, eventTypes []string, body string) (*Channel, error) {
+	if body == "" {
+		body = DefaultTemplate
+	}
+	tmpl, err := template.New(notifier.Name()).Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s template: %w", notifier.Name(), err)
+	}
+
+	channel := &Channel{Notifier: notifier, Template: tmpl}
+	for _, eventType := range eventTypes {
+		channel.Events = append(channel.Events, events.Type(strings.TrimSpace(eventType)))
+	}
+	return channel, nil
+}
+
+// Wants reports whether the channel is interested in the event type
+func (c *Channel) Wants(eventType events.Type) bool {
+	for _, wanted := range c.Events {
+		if wanted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// ChannelsFromConfig creates a channel for every notification provider that is configured
+func ChannelsFromConfig(cfg *config.Config) ([]*Channel, error) {
+	type provider struct {
+		notifier Notifier
+		settings config.NotifierConfig
+	}
+
+	var providers []provider
+	if cfg.Ntfy.Enabled() {
+		providers = append(providers, provider{NewNtfy(cfg.Ntfy.URL, cfg.Ntfy.Token), cfg.Ntfy.NotifierConfig})
+	}
+	if cfg.Gotify.Enabled() {
+		providers = append(providers, provider{NewGotify(cfg.Gotify.URL, cfg.Gotify.Token), cfg.Gotify.NotifierConfig})
+	}
+	if cfg.Discord.Enabled() {
+		providers = append(providers, provider{NewDiscord(cfg.Discord.WebhookURL), cfg.Discord.NotifierConfig})
+	}
+	if cfg.Telegram.Enabled() {
+		telegram := NewTelegram(cfg.Telegram.APIURL, cfg.Telegram.BotToken, cfg.Telegram.ChatID)
+		providers = append(providers, provider{telegram, cfg.Telegram.NotifierConfig})
+	}
+	if cfg.SMTP.Enabled() {
+		email := NewEmail(EmailOptions{
+			Host:     cfg.SMTP.Host,
+			Port:     cfg.SMTP.Port,
+			Username: cfg.SMTP.Username,
+			Password: cfg.SMTP.Password,
+			From:     cfg.SMTP.From,
+			To:       cfg.SMTP.To,
+		})
+		providers = append(providers, provider{email, cfg.SMTP.NotifierConfig})
+	}
+
+	channels := make([]*Channel, 0, len(providers))
+	for _, p := range providers {
+		channel, err := NewChannel(p.notifier, p.settings.Events, p.settings.Template)
+		if err != nil {
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, nil
+}
+
+// Service sends events to every channel that wants them
+type Service struct {
+	db       *database.DB
+	channels []*Channel
+	queue    chan events.Event
+	logger   *slog.Logger
+}
+
+// NewService creates a new notification service
+func NewService(db *database.DB, channels []*Channel) *Service {
+	return &Service{
+		db:       db,
+		channels: channels,
+		queue:    make(chan events.Event, queueSize),
+		logger:   slog.Default(),
+	}
+}
+
+// Enabled reports whether any notification provider is configured
+func (s *Service) Enabled() bool {
+	return len(s.channels) > 0
+}
+
+// Handle queues an event for sending. It is meant to be subscribed to the worker's
+// event bus and never blocks it; events are dropped if the queue is full.
+func (s *Service) Handle(event events.Event) {
+	if !s.wanted(event.Type) {
+		return
+	}
+
+	select {
+	case s.queue <- event:
+	default:
+		s.logger.Warn("Notification queue is full, dropping event", "event", event.Type)
+	}
+}
+
+// wanted reports whether any channel wants the event type
+func (s *Service) wanted(eventType events.Type) bool {
+	for _, channel := range s.channels {
+		if channel.Wants(eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Start sends queued events until the context is cancelled
+func (s *Service) Start(ctx context.Context) {
+	if !s.Enabled() {
+		return
+	}
+
+	names := make([]string, 0, len(s.channels))
+	for _, channel := range s.channels {
+		names = append(names, channel.Notifier.Name())
+	}
+	s.logger.Info("Starting notifications", "providers", names)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-s.queue:
+			s.Notify(ctx, event)
+		}
+	}
+}
+
+// Notify renders the event for each channel that wants it and sends it
+func (s *Service) Notify(ctx context.Context, event events.Event) {
+	data := s.templateData(event)
+
+	for _, channel := range s.channels {
+		if !channel.Wants(event.Type) {
+			continue
+		}
+
+		var body bytes.Buffer
+		if err := channel.Template.Execute(&body, data); err != nil {
+			s.logger.Error("Failed to render notification", "provider", channel.Notifier.Name(), "event", event.Type, "error", err)
+			continue
+		}
+
+		msg := Message{Event: event.Type, Title: data.Title, Body: strings.TrimSpace(body.String())}
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := channel.Notifier.Send(sendCtx, msg)
+		cancel()
+		if err != nil {
+			s.logger.Error("Failed to send notification", "provider", channel.Notifier.Name(), "event", event.Type, "error", err)
+			continue
+		}
+		s.logger.Debug("Notification sent", "provider", channel.Notifier.Name(), "event", event.Type)
+	}
+}
+
+// templateData describes an event for message templates
+func (s *Service) templateData(event events.Event) TemplateData {
+	data := TemplateData{
+		Event:    event.Type,
+		Title:    Title(event.Type),
+		Download: event.Download,
+		Group:    event.Group,
+	}
+
+	if event.Download != nil {
+		data.Name = event.Download.Filename
+		data.Directory = event.Download.Directory
+		data.Error = event.Download.ErrorMessage
+	}
+
+	if event.Group != nil {
+		data.Name = event.Group.ID
+		data.Error = event.Group.ProcessingError
+
+		files, err := s.db.GetDownloadsByGroupID(event.Group.ID)
+		if err != nil {
+			s.logger.Warn("Failed to get group downloads for notification", "group_id", event.Group.ID, "error", err)
+		}
+		if len(files) > 0 {
+			data.Files = files
+			data.Name = files[0].Filename
+			if len(files) > 1 {
+				data.Name = fmt.Sprintf("%s and %d more", files[0].Filename, len(files)-1)
+			}
+			data.Directory = files[0].Directory
+		}
+	}
+
+	return data
+}
+
+// Title returns the notification title for an event type
+func Title(eventType events.Type) string {
+	switch eventType {
+	case events.DownloadQueued:
+		return "Download queued"
+	case events.DownloadStarted:
+		return "Download started"
+	case events.DownloadCompleted:
+		return "Download completed"
+	case events.DownloadFailed:
+		return "Download failed"
+	case events.DownloadPaused:
+		return "Download paused"
+	case events.GroupCompleted:
+		return "Downloads finished"
+	case events.GroupFailed:
+		return "Post-processing failed"
+	default:
+		return string(eventType)
+	}
+}
@@ -0,0 +1,127 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/config"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/events"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingNotifier struct {
+	messages []Message
+}
+
+func (r *recordingNotifier) Name() string { return "recording" }
+
+func (r *recordingNotifier) Send(ctx context.Context, msg Message) error {
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func newTestDB(t *testing.T) *database.DB {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestService_FiltersAndRendersEvents(t *testing.T) {
+	db := newTestDB(t)
+
+	failures := &recordingNotifier{}
+	failuresChannel, err := NewChannel(failures, []string{"download.failed"}, "{{.Name}} broke: {{.Error}}")
+	require.NoError(t, err)
+
+	everything := &recordingNotifier{}
+	everythingChannel, err := NewChannel(everything, []string{"download.failed", "download.completed"}, "")
+	require.NoError(t, err)
+
+	service := NewService(db, []*Channel{failuresChannel, everythingChannel})
+
+	service.Notify(context.Background(), events.Event{
+		Type:     events.DownloadFailed,
+		Download: &models.Download{Filename: "movie.mkv", Directory: "/downloads/movies", ErrorMessage: "link expired"},
+	})
+	service.Notify(context.Background(), events.Event{
+		Type:     events.DownloadCompleted,
+		Download: &models.Download{Filename: "show.mkv", Directory: "/downloads/tv"},
+	})
+
+	require.Len(t, failures.messages, 1)
+	require.Equal(t, "Download failed", failures.messages[0].Title)
+	require.Equal(t, "movie.mkv broke: link expired", failures.messages[0].Body)
+	require.True(t, failures.messages[0].Failure())
+
+	require.Len(t, everything.messages, 2)
+	require.Equal(t, "movie.mkv in /downloads/movies\nlink expired", everything.messages[0].Body)
+	require.Equal(t, "show.mkv in /downloads/tv", everything.messages[1].Body)
+	require.False(t, everything.messages[1].Failure())
+}
+
+func TestService_GroupEventsDescribeFiles(t *testing.T) {
+	db := newTestDB(t)
+
+	group := &models.DownloadGroup{ID: "group-1", CreatedAt: time.Now(), TotalDownloads: 2, Status: models.GroupStatusCompleted}
+	require.NoError(t, db.CreateDownloadGroup(group))
+	for _, name := range []string{"show.part1.rar", "show.part2.rar"} {
+		require.NoError(t, db.CreateDownload(&models.Download{
+			OriginalURL: "https://example.com/" + name,
+			Filename:    name,
+			Directory:   "/downloads/tv",
+			Status:      models.StatusCompleted,
+			GroupID:     group.ID,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}))
+	}
+
+	notifier := &recordingNotifier{}
+	channel, err := NewChannel(notifier, []string{"group.completed"}, "{{.Name}} ({{len .Files}} files)")
+	require.NoError(t, err)
+
+	service := NewService(db, []*Channel{channel})
+	service.Notify(context.Background(), events.Event{Type: events.GroupCompleted, Group: group})
+
+	require.Len(t, notifier.messages, 1)
+	require.Equal(t, "Downloads finished", notifier.messages[0].Title)
+	require.Equal(t, "show.part1.rar and 1 more (2 files)", notifier.messages[0].Body)
+}
+
+func TestService_HandleSkipsUnwantedEvents(t *testing.T) {
+	channel, err := NewChannel(&recordingNotifier{}, []string{"group.failed"}, "")
+	require.NoError(t, err)
+
+	service := NewService(newTestDB(t), []*Channel{channel})
+	service.Handle(events.Event{Type: events.DownloadQueued})
+	require.Empty(t, service.queue)
+
+	service.Handle(events.Event{Type: events.GroupFailed, Group: &models.DownloadGroup{ID: "g"}})
+	require.Len(t, service.queue, 1)
+}
+
+func TestChannelsFromConfig(t *testing.T) {
+	channels, err := ChannelsFromConfig(&config.Config{})
+	require.NoError(t, err)
+	require.Empty(t, channels)
+
+	cfg := &config.Config{
+		Ntfy:    config.NtfyConfig{URL: "http://127.0.0.1/topic", NotifierConfig: config.NotifierConfig{Events: []string{"download.failed"}}},
+		Discord: config.DiscordConfig{WebhookURL: "http://127.0.0.1/discord"},
+	}
+	channels, err = ChannelsFromConfig(cfg)
+	require.NoError(t, err)
+	require.Len(t, channels, 2)
+	require.Equal(t, "ntfy", channels[0].Notifier.Name())
+	require.True(t, channels[0].Wants(events.DownloadFailed))
+	require.Equal(t, "discord", channels[1].Notifier.Name())
+
+	cfg.Ntfy.Template = "{{.Name"
+	_, err = ChannelsFromConfig(cfg)
+	require.Error(t, err)
+}
@@ -0,0 +1,185 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const maxResponseBody = 64 * 1024
+
+// httpClient is shared by the HTTP-based providers
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// post sends a request body and returns an error for non-2xx responses
+func post(ctx context.Context, url, contentType string, body []byte, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return respBody, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	return respBody, nil
+}
+
+// postJSON encodes a value as JSON and posts it
+func postJSON(ctx context.Context, url string, value any, headers map[string]string) ([]byte, error) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+	return post(ctx, url, "application/json", body, headers)
+}
+
+// Ntfy publishes to an ntfy topic
+type Ntfy struct {
+	topicURL string
+	token    string
+}
+
+// NewNtfy creates an ntfy notifier for a full topic URL such as https://ntfy.sh/downloads
+func NewNtfy(topicURL, token string) *Ntfy {
+	return &Ntfy{topicURL: topicURL, token: token}
+}
+
+// Name returns the provider name
+func (n *Ntfy) Name() string { return "ntfy" }
+
+// Send publishes the message to the topic
+func (n *Ntfy) Send(ctx context.Context, msg Message) error {
+	headers := map[string]string{
+		"Title":    msg.Title,
+		"Priority": "default",
+		"Tags":     "white_check_mark",
+	}
+	if msg.Failure() {
+		headers["Priority"] = "high"
+		headers["Tags"] = "warning"
+	}
+	if n.token != "" {
+		headers["Authorization"] = "Bearer " + n.token
+	}
+
+	_, err := post(ctx, n.topicURL, "text/plain; charset=utf-8", []byte(msg.Body), headers)
+	return err
+}
+
+// Gotify sends messages to a Gotify server
+type Gotify struct {
+	serverURL string
+	token     string
+}
+
+// NewGotify creates a Gotify notifier using an application token
+func NewGotify(serverURL, token string) *Gotify {
+	return &Gotify{serverURL: strings.TrimRight(serverURL, "/"), token: token}
+}
+
+// Name returns the provider name
+func (g *Gotify) Name() string { return "gotify" }
+
+// Send creates a Gotify message
+func (g *Gotify) Send(ctx context.Context, msg Message) error {
+	priority := 5
+	if msg.Failure() {
+		priority = 8
+	}
+
+	payload := map[string]any{
+		"title":    msg.Title,
+		"message":  msg.Body,
+		"priority": priority,
+	}
+	_, err := postJSON(ctx, g.serverURL+"/message", payload, map[string]string{"X-Gotify-Key": g.token})
+	return err
+}
+
+// Discord posts to a Discord channel webhook
+type Discord struct {
+	webhookURL string
+}
+
+// NewDiscord creates a Discord notifier for a channel webhook URL
+func NewDiscord(webhookURL string) *Discord {
+	return &Discord{webhookURL: webhookURL}
+}
+
+// Name returns the provider name
+func (d *Discord) Name() string { return "discord" }
+
+// Send posts the message as an embed, red for failures and green otherwise
+func (d *Discord) Send(ctx context.Context, msg Message) error {
+	color := 0x16a34a
+	if msg.Failure() {
+		color = 0xdc2626
+	}
+
+	payload := map[string]any{
+		"embeds": []map[string]any{{
+			"title":       msg.Title,
+			"description": msg.Body,
+			"color":       color,
+		}},
+	}
+	_, err := postJSON(ctx, d.webhookURL, payload, nil)
+	return err
+}
+
+// Telegram sends messages through the Telegram Bot API
+type Telegram struct {
+	apiURL   string
+	botToken string
+	chatID   string
+}
+
+// NewTelegram creates a Telegram notifier. apiURL is normally https://api.telegram.org.
+func NewTelegram(apiURL, botToken, chatID string) *Telegram {
+	return &Telegram{apiURL: strings.TrimRight(apiURL, "/"), botToken: botToken, chatID: chatID}
+}
+
+// Name returns the provider name
+func (t *Telegram) Name() string { return "telegram" }
+
+// Send sends the title and body as a plain text message
+func (t *Telegram) Send(ctx context.Context, msg Message) error {
+	payload := map[string]any{
+		"chat_id": t.chatID,
+		"text":    msg.Title + "\n" + msg.Body,
+	}
+
+	body, err := postJSON(ctx, fmt.Sprintf("%s/bot%s/sendMessage", t.apiURL, t.botToken), payload, nil)
+	if err != nil {
+		// Don't leak the bot token, which is part of the URL, into logs
+		return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), t.botToken, "<token>"))
+	}
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram error: %s", result.Description)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"debrid-downloader/internal/events"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	completed = Message{Event: events.DownloadCompleted, Title: "Download completed", Body: "movie.mkv"}
+	failed    = Message{Event: events.DownloadFailed, Title: "Download failed", Body: "movie.mkv\nlink expired"}
+)
+
+// stubServer records the last request it received and replies with the given status and body
+func stubServer(t *testing.T, status int, reply string) (*httptest.Server, *http.Request, *[]byte) {
+	var last http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = *r.Clone(context.Background())
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(reply))
+	}))
+	t.Cleanup(server.Close)
+	return server, &last, &body
+}
+
+func TestNtfy_Send(t *testing.T) {
+	server, req, body := stubServer(t, http.StatusOK, "{}")
+
+	notifier := NewNtfy(server.URL+"/downloads", "tk_secret")
+	require.NoError(t, notifier.Send(context.Background(), failed))
+
+	require.Equal(t, "/downloads", req.URL.Path)
+	require.Equal(t, "Download failed", req.Header.Get("Title"))
+	require.Equal(t, "high", req.Header.Get("Priority"))
+	require.Equal(t, "Bearer tk_secret", req.Header.Get("Authorization"))
+	require.Equal(t, "movie.mkv\nlink expired", string(*body))
+}
+
+func TestGotify_Send(t *testing.T) {
+	server, req, body := stubServer(t, http.StatusOK, "{}")
+
+	notifier := NewGotify(server.URL+"/", "app-token")
+	require.NoError(t, notifier.Send(context.Background(), completed))
+
+	require.Equal(t, "/message", req.URL.Path)
+	require.Equal(t, "app-token", req.Header.Get("X-Gotify-Key"))
+
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(*body, &payload))
+	require.Equal(t, "Download completed", payload["title"])
+	require.Equal(t, "movie.mkv", payload["message"])
+	require.Equal(t, float64(5), payload["priority"])
+}
+
+func TestDiscord_Send(t *testing.T) {
+	server, _, body := stubServer(t, http.StatusNoContent, "")
+
+	notifier := NewDiscord(server.URL + "/api/webhooks/1/abc")
+	require.NoError(t, notifier.Send(context.Background(), failed))
+
+	var payload struct {
+		Embeds []struct {
+			Title       string `json:"title"`
+			Description string `json:"description"`
+			Color       int    `json:"color"`
+		} `json:"embeds"`
+	}
+	require.NoError(t, json.Unmarshal(*body, &payload))
+	require.Len(t, payload.Embeds, 1)
+	require.Equal(t, "Download failed", payload.Embeds[0].Title)
+	require.Equal(t, 0xdc2626, payload.Embeds[0].Color)
+}
+
+func TestTelegram_Send(t *testing.T) {
+	server, req, body := stubServer(t, http.StatusOK, `{"ok":true}`)
+
+	notifier := NewTelegram(server.URL, "123:abc", "-10042")
+	require.NoError(t, notifier.Send(context.Background(), completed))
+
+	require.Equal(t, "/bot123:abc/sendMessage", req.URL.Path)
+	var payload map[string]any
+	require.NoError(t, json.Unmarshal(*body, &payload))
+	require.Equal(t, "-10042", payload["chat_id"])
+	require.Equal(t, "Download completed\nmovie.mkv", payload["text"])
+}
+
+func TestTelegram_SendErrors(t *testing.T) {
+	server, _, _ := stubServer(t, http.StatusOK, `{"ok":false,"description":"chat not found"}`)
+	err := NewTelegram(server.URL, "123:abc", "1").Send(context.Background(), completed)
+	require.ErrorContains(t, err, "chat not found")
+
+	// The bot token is part of the URL and must not end up in error messages
+	err = NewTelegram("http://127.0.0.1:1", "123:abc", "1").Send(context.Background(), completed)
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "123:abc")
+}
+
+func TestHTTPProviders_ReportErrorStatus(t *testing.T) {
+	server, _, _ := stubServer(t, http.StatusUnauthorized, "invalid token")
+
+	for _, notifier := range []Notifier{
+		NewNtfy(server.URL, ""),
+		NewGotify(server.URL, "bad"),
+		NewDiscord(server.URL),
+	} {
+		err := notifier.Send(context.Background(), completed)
+		require.ErrorContains(t, err, "401", notifier.Name())
+	}
+}
+
+// smtpStub accepts one message and returns its envelope and data
+func smtpStub(t *testing.T) (string, int, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP stub")
+
+		var transcript strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+				transcript.WriteString(line)
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				for {
+					dataLine, err := reader.ReadString('\n')
+					if err != nil || dataLine == ".\r\n" {
+						break
+					}
+					transcript.WriteString(dataLine)
+				}
+				reply("250 OK")
+			case command == "QUIT":
+				reply("221 Bye")
+				received <- transcript.String()
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	host, portString, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portString)
+	require.NoError(t, err)
+	return host, port, received
+}
+
+func TestEmail_Send(t *testing.T) {
+	host, port, received := smtpStub(t)
+
+	notifier := NewEmail(EmailOptions{
+		Host: host,
+		Port: port,
+		From: "downloads@example.com",
+		To:   []string{"me@example.com", "you@example.com"},
+	})
+	require.NoError(t, notifier.Send(context.Background(), failed))
+
+	transcript := <-received
+	require.Contains(t, transcript, "MAIL FROM:<downloads@example.com>")
+	require.Contains(t, transcript, "RCPT TO:<you@example.com>")
+	require.Contains(t, transcript, "Subject: [debrid-downloader] Download failed")
+	require.Contains(t, transcript, "movie.mkv\r\nlink expired")
+}
@@ -0,0 +1,150 @@
+// Package release parses scene-style release names into the show, episode, movie and
+// quality details used to rename downloaded files
+package release
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Info describes a release parsed from its name
+type Info struct {
+	Title      string // Show or movie title
+	Year       int
+	Season     int
+	Episode    int
+	Resolution string // e.g. 1080p
+	Source     string // e.g. BluRay, WEB-DL
+	Codec      string // e.g. x264
+}
+
+// IsEpisode reports whether the release is a TV episode
+func (i Info) IsEpisode() bool {
+	return i.Episode > 0
+}
+
+var (
+	episodePattern    = regexp.MustCompile(`(?i)\bS(\d{1,2}) ?E(\d{1,3})\b|\b(\d{1,2})x(\d{2,3})\b`)
+	yearPattern       = regexp.MustCompile(`\b(19\d{2}|20\d{2})\b`)
+	resolutionPattern = regexp.MustCompile(`(?i)\b(2160p|1080p|720p|576p|480p|4k|uhd)\b`)
+	groupPattern      = regexp.MustCompile(`^\s*\[[^\]]*\]\s*`)
+	spacePattern      = regexp.MustCompile(`\s+`)
+	extPattern        = regexp.MustCompile(`^\.[A-Za-z0-9]{2,4}$`)
+)
+
+// tag maps the ways a quality tag is written to its usual spelling
+type tag struct {
+	pattern *regexp.Regexp
+	name    string
+}
+
+var sources = []tag{
+	{regexp.MustCompile(`(?i)\b(blu-?ray|bdrip|brrip|bdremux)\b`), "BluRay"},
+	{regexp.MustCompile(`(?i)\bweb-?dl\b`), "WEB-DL"},
+	{regexp.MustCompile(`(?i)\bweb-?rip\b`), "WEBRip"},
+	{regexp.MustCompile(`(?i)\bhdtv\b`), "HDTV"},
+	{regexp.MustCompile(`(?i)\b(dvdrip|dvd)\b`), "DVD"},
+	{regexp.MustCompile(`(?i)\bweb\b`), "WEB"},
+}
+
+var codecs = []tag{
+	{regexp.MustCompile(`(?i)\b(x265|h ?265|hevc)\b`), "x265"},
+	{regexp.MustCompile(`(?i)\b(x264|h ?264|avc)\b`), "x264"},
+	{regexp.MustCompile(`(?i)\bav1\b`), "AV1"},
+	{regexp.MustCompile(`(?i)\bxvid\b`), "XviD"},
+}
+
+// Parse extracts the title, episode or year and quality tags from a release or file name
+func Parse(name string) Info {
+	name = filepath.Base(name)
+	if ext := filepath.Ext(name); extPattern.MatchString(ext) {
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	// Dots and underscores separate words in release names
+	name = strings.NewReplacer(".", " ", "_", " ").Replace(name)
+	name = groupPattern.ReplaceAllString(name, "")
+
+	var info Info
+	titleEnd := len(name)
+
+	if match := episodePattern.FindStringSubmatchIndex(name); match != nil {
+		groups := episodePattern.FindStringSubmatch(name)
+		if groups[1] != "" {
+			info.Season, _ = strconv.Atoi(groups[1])
+			info.Episode, _ = strconv.Atoi(groups[2])
+		} else {
+			info.Season, _ = strconv.Atoi(groups[3])
+			info.Episode, _ = strconv.Atoi(groups[4])
+		}
+		titleEnd = match[0]
+	}
+
+	// The last year in the title part is the release year, so titles that start with
+	// a year keep it
+	for _, match := range yearPattern.FindAllStringIndex(name[:titleEnd], -1) {
+		if match[0] == 0 {
+			continue
+		}
+		info.Year, _ = strconv.Atoi(name[match[0]:match[1]])
+		if !info.IsEpisode() {
+			titleEnd = match[0]
+		}
+	}
+
+	info.Resolution = resolution(name)
+	info.Source = firstTag(sources, name)
+	info.Codec = firstTag(codecs, name)
+
+	// Without an episode or year the title ends at the first quality tag
+	if !info.IsEpisode() && info.Year == 0 {
+		for _, pattern := range qualityPatterns() {
+			if match := pattern.FindStringIndex(name); match != nil && match[0] > 0 && match[0] < titleEnd {
+				titleEnd = match[0]
+			}
+		}
+	}
+
+	title := name[:titleEnd]
+	if info.IsEpisode() && info.Year != 0 {
+		title = strings.Replace(title, strconv.Itoa(info.Year), "", 1)
+	}
+	info.Title = cleanTitle(title)
+	return info
+}
+
+// resolution returns the release's resolution, treating 4K as 2160p
+func resolution(name string) string {
+	match := strings.ToLower(resolutionPattern.FindString(name))
+	if match == "4k" || match == "uhd" {
+		return "2160p"
+	}
+	return match
+}
+
+// firstTag returns the name of the first tag found in the release name
+func firstTag(tags []tag, name string) string {
+	for _, t := range tags {
+		if t.pattern.MatchString(name) {
+			return t.name
+		}
+	}
+	return ""
+}
+
+// qualityPatterns returns every pattern that marks the end of a title
+func qualityPatterns() []*regexp.Regexp {
+	patterns := []*regexp.Regexp{resolutionPattern}
+	for _, t := range append(append([]tag{}, sources...), codecs...) {
+		patterns = append(patterns, t.pattern)
+	}
+	return patterns
+}
+
+// cleanTitle trims the separators and brackets left around a title
+func cleanTitle(title string) string {
+	title = spacePattern.ReplaceAllString(title, " ")
+	return strings.Trim(title, " -([")
+}
@@ -0,0 +1,85 @@
+package release
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected Info
+	}{
+		{
+			name:     "The.Office.US.S03E07.720p.HDTV.x264-GROUP.mkv",
+			expected: Info{Title: "The Office US", Season: 3, Episode: 7, Resolution: "720p", Source: "HDTV", Codec: "x264"},
+		},
+		{
+			name:     "Doctor.Who.2005.S10E01.1080p.WEB-DL.H.265.mkv",
+			expected: Info{Title: "Doctor Who", Year: 2005, Season: 10, Episode: 1, Resolution: "1080p", Source: "WEB-DL", Codec: "x265"},
+		},
+		{
+			name:     "[SubsPlease] Frieren - 1x12 (1080p).mkv",
+			expected: Info{Title: "Frieren", Season: 1, Episode: 12, Resolution: "1080p"},
+		},
+		{
+			name:     "Blade.Runner.2049.2017.2160p.BluRay.HEVC.mkv",
+			expected: Info{Title: "Blade Runner 2049", Year: 2017, Resolution: "2160p", Source: "BluRay", Codec: "x265"},
+		},
+		{
+			name:     "2001.A.Space.Odyssey.1968.1080p.BDRip.mp4",
+			expected: Info{Title: "2001 A Space Odyssey", Year: 1968, Resolution: "1080p", Source: "BluRay"},
+		},
+		{
+			name:     "Some Movie (1999) [720p].avi",
+			expected: Info{Title: "Some Movie", Year: 1999, Resolution: "720p"},
+		},
+		{
+			name:     "Home_Video_WEBRip.mkv",
+			expected: Info{Title: "Home Video", Source: "WEBRip"},
+		},
+		{
+			name:     "holiday.mp4",
+			expected: Info{Title: "holiday"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, Parse(tt.name))
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	episode := Parse("The.Office.US.S03E07.720p.HDTV.x264.mkv")
+	movie := Parse("Blade.Runner.2049.2017.2160p.BluRay.mkv")
+
+	path, err := Render("{show}/Season {season:02}/{show} - S{season:02}E{episode:02}.{ext}", episode, ".mkv")
+	require.NoError(t, err)
+	require.Equal(t, "The Office US/Season 03/The Office US - S03E07.mkv", path)
+
+	path, err = Render("{title} ({year})/{title} ({year}) {resolution} {codec}.{ext}", movie, "mkv")
+	require.NoError(t, err)
+	require.Equal(t, "Blade Runner 2049 (2017)/Blade Runner 2049 (2017) 2160p .mkv", path)
+
+	// Movies have no episode, and episodes here have no year
+	_, err = Render("{show} - {episode}.{ext}", movie, "mkv")
+	require.ErrorContains(t, err, "no show")
+	_, err = Render("{title} ({year}).{ext}", episode, "mkv")
+	require.ErrorContains(t, err, "no year")
+
+	// Path separators in values don't create folders
+	path, err = Render("{title}.{ext}", Info{Title: "AC/DC: Live"}, "mkv")
+	require.NoError(t, err)
+	require.Equal(t, "AC DC Live.mkv", path)
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, Validate("{title} ({year})/{title}.{ext}"))
+	require.ErrorContains(t, Validate("{name}.{ext}"), "unknown placeholder")
+	require.ErrorContains(t, Validate("/{title}.{ext}"), "relative")
+	require.ErrorContains(t, Validate("../{title}.{ext}"), "category folder")
+	require.Error(t, Validate(" "))
+}
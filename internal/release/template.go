@@ -0,0 +1,125 @@
+package release
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Fields lists the placeholders a rename template can use
+var Fields = []string{"show", "title", "year", "season", "episode", "resolution", "source", "codec", "ext"}
+
+// optionalFields may be missing from a release, leaving their placeholders empty
+var optionalFields = map[string]bool{"resolution": true, "source": true, "codec": true}
+
+var (
+	placeholderPattern = regexp.MustCompile(`\{(\w+)(?::(\d+))?\}`)
+	unsafeChars        = strings.NewReplacer("/", " ", `\`, " ", ":", "", "*", "", "?", "", `"`, "", "<", "", ">", "", "|", "")
+)
+
+// Validate checks that a template only uses known placeholders and builds a relative path
+func Validate(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("template is empty")
+	}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		if !knownField(match[1]) {
+			return fmt.Errorf("unknown placeholder {%s}", match[1])
+		}
+	}
+	if strings.HasPrefix(template, "/") || strings.HasPrefix(template, `\`) {
+		return fmt.Errorf("template must be a relative path")
+	}
+	for _, segment := range strings.Split(template, "/") {
+		if strings.TrimSpace(segment) == ".." {
+			return fmt.Errorf("template can't leave the category folder")
+		}
+	}
+	return nil
+}
+
+// Render fills in a template for a release, returning a slash-separated relative path.
+// {field:02} pads numbers with zeros. It fails when the release is missing a required
+// field, such as the episode number for a movie.
+func Render(template string, info Info, ext string) (string, error) {
+	if err := Validate(template); err != nil {
+		return "", err
+	}
+
+	var missing string
+	rendered := placeholderPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		match := placeholderPattern.FindStringSubmatch(placeholder)
+		value, ok := fieldValue(match[1], info, ext)
+		if !ok {
+			if missing == "" {
+				missing = match[1]
+			}
+			return ""
+		}
+		if width, err := strconv.Atoi(match[2]); err == nil {
+			if number, err := strconv.Atoi(value); err == nil {
+				value = fmt.Sprintf("%0*d", width, number)
+			}
+		}
+		return unsafeChars.Replace(value)
+	})
+	if missing != "" {
+		return "", fmt.Errorf("release has no %s", missing)
+	}
+
+	segments := strings.Split(rendered, "/")
+	for i, segment := range segments {
+		segment = strings.Trim(spacePattern.ReplaceAllString(segment, " "), " ")
+		if segment == "" || segment == "." || segment == ".." {
+			return "", fmt.Errorf("template produced an invalid path: %s", rendered)
+		}
+		segments[i] = segment
+	}
+	return path.Join(segments...), nil
+}
+
+// fieldValue returns a placeholder's value, reporting false when a required one is missing
+func fieldValue(field string, info Info, ext string) (string, bool) {
+	var value string
+	switch field {
+	case "show":
+		if info.IsEpisode() {
+			value = info.Title
+		}
+	case "title":
+		value = info.Title
+	case "year":
+		if info.Year != 0 {
+			value = strconv.Itoa(info.Year)
+		}
+	case "season":
+		if info.IsEpisode() {
+			value = strconv.Itoa(info.Season)
+		}
+	case "episode":
+		if info.IsEpisode() {
+			value = strconv.Itoa(info.Episode)
+		}
+	case "resolution":
+		value = info.Resolution
+	case "source":
+		value = info.Source
+	case "codec":
+		value = info.Codec
+	case "ext":
+		value = strings.TrimPrefix(ext, ".")
+	}
+	return value, value != "" || optionalFields[field]
+}
+
+// knownField reports whether a placeholder is one of Fields
+func knownField(field string) bool {
+	for _, known := range Fields {
+		if field == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+// Package retention removes old finished downloads from the history once a day, archiving
+// them to JSON first when an archive folder is configured
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"debrid-downloader/internal/database"
+	"debrid-downloader/pkg/models"
+)
+
+// Interval is how often the history is cleaned up
+const Interval = 24 * time.Hour
+
+// Policy says which finished downloads the history keeps. Pinned downloads are always kept.
+type Policy struct {
+	Completed time.Duration // How long completed downloads are kept
+	Failed    time.Duration // How long failed downloads are kept
+	MaxRows   int           // Most finished downloads kept, 0 for no limit
+}
+
+// DefaultPolicy keeps finished downloads for 60 days
+func DefaultPolicy() Policy {
+	return Policy{Completed: 60 * 24 * time.Hour, Failed: 60 * 24 * time.Hour}
+}
+
+// Result is what a cleanup removed
+type Result struct {
+	Removed int
+	Archive string // Path of the archive written, empty if none was
+}
+
+// Service cleans up the history on a schedule or on demand
+type Service struct {
+	db          *database.DB
+	archivePath string
+	logger      *slog.Logger
+
+	runMu sync.Mutex // Serialises cleanups
+
+	mu      sync.Mutex
+	policy  Policy
+	lastRun time.Time
+}
+
+// NewService creates a history cleanup service. Removed downloads are archived to
+// archivePath first unless it is empty.
+func NewService(db *database.DB, archivePath string) *Service {
+	if archivePath != "" {
+		archivePath = filepath.Clean(archivePath)
+	}
+	return &Service{
+		db:          db,
+		archivePath: archivePath,
+		logger:      slog.Default(),
+		policy:      DefaultPolicy(),
+	}
+}
+
+// SetPolicy changes the policy, taking effect from the next cleanup
+func (s *Service) SetPolicy(policy Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = policy
+}
+
+// Policy returns the policy in effect
+func (s *Service) Policy() Policy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.policy
+}
+
+// ArchivePath returns the folder removed downloads are archived to, empty if they aren't
+func (s *Service) ArchivePath() string {
+	return s.archivePath
+}
+
+// NextRun returns when the next scheduled cleanup is due
+func (s *Service) NextRun() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastRun.IsZero() {
+		return time.Now()
+	}
+	return s.lastRun.Add(Interval)
+}
+
+// Start cleans up the history now and then every Interval until the context is cancelled
+func (s *Service) Start(ctx context.Context) {
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+
+	s.runScheduled()
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("History cleanup routine shutting down")
+			return
+		case <-ticker.C:
+			s.runScheduled()
+		}
+	}
+}
+
+// runScheduled runs a scheduled cleanup, logging any failure
+func (s *Service) runScheduled() {
+	if _, err := s.Run(time.Now()); err != nil {
+		s.logger.Error("Failed to cleanup old downloads", "error", err)
+	}
+}
+
+// Preview returns the downloads a cleanup at the given time would remove
+func (s *Service) Preview(at time.Time) ([]*models.Download, error) {
+	policy := s.Policy()
+	return s.db.ExpiredDownloads(at.Add(-policy.Completed), at.Add(-policy.Failed), policy.MaxRows)
+}
+
+// Run removes the downloads the policy no longer keeps, archiving them first. Nothing is
+// removed when the archive can't be written.
+func (s *Service) Run(now time.Time) (Result, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	s.mu.Lock()
+	s.lastRun = now
+	policy := s.policy
+	s.mu.Unlock()
+
+	s.logger.Info("Running history cleanup",
+		"completed_days", int(policy.Completed.Hours()/24),
+		"failed_days", int(policy.Failed.Hours()/24),
+		"max_rows", policy.MaxRows)
+
+	downloads, err := s.Preview(now)
+	if err != nil {
+		return Result{}, err
+	}
+	if len(downloads) == 0 {
+		s.logger.Info("History cleanup completed", "removed", 0)
+		return Result{}, nil
+	}
+
+	var result Result
+	if s.archivePath != "" {
+		if result.Archive, err = s.archive(downloads, now); err != nil {
+			return Result{}, err
+		}
+	}
+
+	ids := make([]int64, len(downloads))
+	for i, d := range downloads {
+		ids[i] = d.ID
+	}
+	if result.Removed, err = s.db.DeleteDownloads(ids); err != nil {
+		return result, err
+	}
+
+	// Clean up any temporary files (best effort)
+	for _, d := range downloads {
+		os.Remove(filepath.Join(d.Directory, fmt.Sprintf("%s.%d.tmp", d.Filename, d.ID)))
+	}
+
+	s.logger.Info("History cleanup completed", "removed", result.Removed, "archive", result.Archive)
+	return result, nil
+}
+
+// archive writes downloads to a JSON file in the archive folder, in the export format so
+// they can be imported again. It returns the file's path.
+func (s *Service) archive(downloads []*models.Download, now time.Time) (string, error) {
+	if err := os.MkdirAll(s.archivePath, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create archive folder: %w", err)
+	}
+
+	// The owners go along so an import gives the downloads back to them
+	users, err := s.db.ExportedUsers()
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(s.archivePath, fmt.Sprintf("history-%s.json", now.UTC().Format("20060102-150405")))
+	data, err := json.MarshalIndent(&database.Export{
+		Version:    database.ExportVersion,
+		ExportedAt: now,
+		Users:      users,
+		Downloads:  downloads,
+	}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode archive: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	return path, nil
+}
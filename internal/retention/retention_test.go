@@ -0,0 +1,131 @@
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/database"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func createFinished(t *testing.T, db *database.DB, name string, status models.DownloadStatus, finished time.Time) *models.Download {
+	t.Helper()
+	download := &models.Download{
+		OriginalURL: "https://example.com/" + name,
+		Filename:    name,
+		Directory:   t.TempDir(),
+		Status:      status,
+		CreatedAt:   finished,
+		UpdatedAt:   finished,
+		CompletedAt: &finished,
+	}
+	require.NoError(t, db.CreateDownload(download))
+	return download
+}
+
+func TestService_Run(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	now := time.Now()
+	day := 24 * time.Hour
+	old := createFinished(t, db, "old.mkv", models.StatusCompleted, now.Add(-20*day))
+	failed := createFinished(t, db, "failed.mkv", models.StatusFailed, now.Add(-5*day))
+	createFinished(t, db, "recent.mkv", models.StatusCompleted, now.Add(-day))
+	pinned := createFinished(t, db, "pinned.mkv", models.StatusCompleted, now.Add(-100*day))
+	require.NoError(t, db.SetDownloadPinned(pinned.ID, true))
+
+	// A leftover partial file is removed with its download
+	tempPath := filepath.Join(old.Directory, "old.mkv.1.tmp")
+	require.NoError(t, os.WriteFile(tempPath, []byte("partial"), 0o644))
+
+	archivePath := filepath.Join(t.TempDir(), "archive")
+	service := NewService(db, archivePath)
+	service.SetPolicy(Policy{Completed: 10 * day, Failed: 3 * day})
+
+	preview, err := service.Preview(now)
+	require.NoError(t, err)
+	require.Len(t, preview, 2)
+
+	result, err := service.Run(now)
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Removed)
+	require.NoFileExists(t, tempPath)
+	require.Equal(t, now.Add(Interval), service.NextRun())
+
+	downloads, err := db.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 2)
+
+	// The archive can be imported to bring the downloads back
+	data, err := os.ReadFile(result.Archive)
+	require.NoError(t, err)
+	var archive database.Export
+	require.NoError(t, json.Unmarshal(data, &archive))
+	require.Len(t, archive.Downloads, 2)
+
+	_, err = db.Import(&archive, database.ConflictSkip, 0)
+	require.NoError(t, err)
+	restored, err := db.GetDownload(failed.ID)
+	require.NoError(t, err)
+	require.Equal(t, "failed.mkv", restored.Filename)
+
+	// Nothing left to remove writes no archive
+	result, err = NewService(db, "").Run(now.Add(-30 * day))
+	require.NoError(t, err)
+	require.Equal(t, Result{}, result)
+}
+
+func TestService_RunErrors(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	createFinished(t, db, "old.mkv", models.StatusCompleted, time.Now().Add(-100*24*time.Hour))
+
+	// Nothing is removed when the archive can't be written
+	blocked := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(blocked, nil, 0o644))
+	_, err = NewService(db, blocked).Run(time.Now())
+	require.ErrorContains(t, err, "failed to create archive folder")
+
+	downloads, err := db.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 1)
+
+	db.Close()
+	_, err = NewService(db, "").Run(time.Now())
+	require.Error(t, err)
+}
+
+func TestService_Start(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	createFinished(t, db, "old.mkv", models.StatusCompleted, time.Now().Add(-100*24*time.Hour))
+	service := NewService(db, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.Start(ctx)
+		close(done)
+	}()
+
+	// The first cleanup runs straight away
+	require.Eventually(t, func() bool {
+		downloads, err := db.ListDownloads(10, 0)
+		return err == nil && len(downloads) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
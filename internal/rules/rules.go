@@ -0,0 +1,138 @@
+// Package rules matches links against the ordered routing rules that pick their
+// download directory
+package rules
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"debrid-downloader/pkg/models"
+)
+
+// Link is what rules are checked against. Size is 0 when it isn't known, in which case
+// size rules don't match.
+type Link struct {
+	URL      string
+	Filename string
+	Size     int64
+}
+
+// Host returns the link's lowercased host without a port
+func (l Link) Host() string {
+	parsed, err := url.Parse(l.URL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+var sizeUnits = map[string]float64{
+	"":   1,
+	"b":  1,
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+	"tb": 1 << 40,
+}
+
+var sizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([kmgt]?b)?$`)
+
+// ParseSize reads a size such as 700MB or 1.5 GB, in binary units, as bytes
+func ParseSize(value string) (int64, error) {
+	match := sizePattern.FindStringSubmatch(strings.ToLower(strings.TrimSpace(value)))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size: %s", value)
+	}
+	number, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", value)
+	}
+	return int64(number * sizeUnits[match[2]]), nil
+}
+
+// Validate checks that a rule's value suits its condition
+func Validate(condition models.RuleCondition, value string) error {
+	if !condition.Valid() {
+		return fmt.Errorf("unknown condition: %s", condition)
+	}
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("value is empty")
+	}
+
+	switch condition {
+	case models.RuleHost:
+		if _, err := path.Match(strings.ToLower(value), ""); err != nil {
+			return fmt.Errorf("invalid host pattern: %w", err)
+		}
+	case models.RuleFilename, models.RuleURL:
+		if _, err := regexp.Compile(value); err != nil {
+			return fmt.Errorf("invalid regular expression: %w", err)
+		}
+	case models.RuleLargerThan, models.RuleSmallerThan:
+		if _, err := ParseSize(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a link meets a rule's condition. Regular expressions are
+// case-insensitive.
+func Matches(rule *models.Rule, link Link) (bool, error) {
+	switch rule.Condition {
+	case models.RuleHost:
+		return matchesHost(strings.ToLower(strings.TrimSpace(rule.Value)), link.Host())
+	case models.RuleFilename:
+		return matchesPattern(rule.Value, link.Filename)
+	case models.RuleURL:
+		return matchesPattern(rule.Value, link.URL)
+	case models.RuleLargerThan, models.RuleSmallerThan:
+		size, err := ParseSize(rule.Value)
+		if err != nil || link.Size <= 0 {
+			return false, err
+		}
+		if rule.Condition == models.RuleLargerThan {
+			return link.Size > size, nil
+		}
+		return link.Size < size, nil
+	}
+	return false, fmt.Errorf("unknown condition: %s", rule.Condition)
+}
+
+// Match returns the first rule the link meets, or nil. Rules that can't be checked
+// are skipped.
+func Match(rules []*models.Rule, link Link) *models.Rule {
+	for _, rule := range rules {
+		if ok, err := Matches(rule, link); err == nil && ok {
+			return rule
+		}
+	}
+	return nil
+}
+
+// matchesHost matches a host exactly, as a subdomain or against a * pattern
+func matchesHost(pattern, host string) (bool, error) {
+	if host == "" {
+		return false, nil
+	}
+	if host == pattern || strings.HasSuffix(host, "."+pattern) {
+		return true, nil
+	}
+	return path.Match(pattern, host)
+}
+
+// matchesPattern matches text against a case-insensitive regular expression
+func matchesPattern(pattern, text string) (bool, error) {
+	if text == "" {
+		return false, nil
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return false, fmt.Errorf("invalid regular expression: %w", err)
+	}
+	return re.MatchString(text), nil
+}
@@ -0,0 +1,72 @@
+package rules
+
+import (
+	"testing"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSize(t *testing.T) {
+	for value, expected := range map[string]int64{
+		"100":    100,
+		"700MB":  700 << 20,
+		"1.5 GB": 3 << 29,
+		"2tb":    2 << 40,
+	} {
+		size, err := ParseSize(value)
+		require.NoError(t, err, value)
+		require.Equal(t, expected, size, value)
+	}
+
+	_, err := ParseSize("big")
+	require.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, Validate(models.RuleHost, "*.example.com"))
+	require.NoError(t, Validate(models.RuleFilename, `S\d+E\d+`))
+	require.NoError(t, Validate(models.RuleLargerThan, "4GB"))
+
+	require.ErrorContains(t, Validate("colour", "red"), "unknown condition")
+	require.ErrorContains(t, Validate(models.RuleHost, " "), "empty")
+	require.ErrorContains(t, Validate(models.RuleFilename, "(unclosed"), "regular expression")
+	require.ErrorContains(t, Validate(models.RuleSmallerThan, "small"), "invalid size")
+}
+
+func TestMatch(t *testing.T) {
+	rules := []*models.Rule{
+		{ID: 1, Condition: models.RuleHost, Value: "example.com", Directory: "example"},
+		{ID: 2, Condition: models.RuleFilename, Value: `s\d{2}e\d{2}`, Directory: "tv"},
+		{ID: 3, Condition: models.RuleLargerThan, Value: "4GB", Directory: "big"},
+		{ID: 4, Condition: models.RuleURL, Value: "(broken", Directory: "never"},
+		{ID: 5, Condition: models.RuleHost, Value: "*.cdn.net", Directory: "cdn"},
+	}
+
+	tests := []struct {
+		name     string
+		link     Link
+		expected int64
+	}{
+		{"host", Link{URL: "https://example.com/a.zip"}, 1},
+		{"subdomain", Link{URL: "https://files.example.com/Show.S01E02.mkv", Filename: "Show.S01E02.mkv"}, 1},
+		{"filename before size", Link{URL: "https://host.org/x", Filename: "Show.S01E02.mkv", Size: 5 << 30}, 2},
+		{"size", Link{URL: "https://host.org/x", Filename: "movie.mkv", Size: 5 << 30}, 3},
+		{"unknown size", Link{URL: "https://host.org/x", Filename: "movie.mkv"}, 0},
+		{"wildcard", Link{URL: "https://eu.cdn.net/file"}, 5},
+		{"no match", Link{URL: "https://other.org/file.zip", Filename: "file.zip", Size: 100}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := Match(rules, tt.link)
+			if tt.expected == 0 {
+				require.Nil(t, rule)
+				return
+			}
+			require.NotNil(t, rule)
+			require.Equal(t, tt.expected, rule.ID)
+		})
+	}
+}
@@ -0,0 +1,351 @@
+// Package settings holds the runtime settings operators change from the browser. They
+// start from the configuration and the changed ones are stored in the database.
+package settings
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"debrid-downloader/internal/cleanup"
+	"debrid-downloader/internal/config"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/retention"
+)
+
+// Keys the settings are stored under
+const (
+	KeyConcurrency         = "concurrency"
+	KeySpeedLimit          = "speed_limit"
+	KeyMaxRetries          = "max_retries"
+	KeyRetryBackoff        = "retry_backoff"
+	KeyRetentionDays       = "retention_days"
+	KeyFailedRetentionDays = "failed_retention_days"
+	KeyMaxHistory          = "max_history"
+	KeyVideoExtensions     = "video_extensions"
+	KeyCleanupExtensions   = "cleanup_extensions"
+	KeySubtitleExtensions  = "subtitle_extensions"
+	KeyDefaultDirectory    = "default_directory"
+)
+
+// Limits on the settings
+const (
+	MaxConcurrency = 10
+	MaxRetries     = 20
+)
+
+// Settings are the runtime settings
+type Settings struct {
+	Concurrency         int           // Downloads processed at the same time
+	SpeedLimit          int64         // Bytes per second shared by all downloads, 0 for unlimited
+	MaxRetries          int           // Attempts after the first before a download fails
+	RetryBackoff        time.Duration // Wait before the first retry, doubled for each one after
+	RetentionDays       int           // Days completed downloads stay in the history
+	FailedRetentionDays int           // Days failed downloads stay in the history
+	MaxHistory          int           // Most finished downloads kept in the history, 0 for no limit
+	VideoExtensions     []string
+	CleanupExtensions   []string
+	SubtitleExtensions  []string
+	DefaultDirectory    string // Folder under the downloads path suggested when nothing else matches
+}
+
+// FromConfig returns the settings the configuration starts with
+func FromConfig(cfg *config.Config) Settings {
+	settings := Settings{
+		Concurrency:         1,
+		MaxRetries:          cfg.MaxRetries,
+		RetryBackoff:        cfg.RetryBackoff,
+		RetentionDays:       days(cfg.HistoryRetention),
+		FailedRetentionDays: days(cfg.FailedHistoryRetention),
+		MaxHistory:          cfg.HistoryMaxRows,
+		VideoExtensions:     cfg.VideoExtensions,
+		CleanupExtensions:   cfg.CleanupExtensions,
+		SubtitleExtensions:  cfg.SubtitleExtensions,
+	}
+	if cfg.FailedHistoryRetention == 0 {
+		settings.FailedRetentionDays = settings.RetentionDays
+	}
+	settings.fillExtensions()
+	return settings
+}
+
+// days returns a retention in whole days, at least 1
+func days(d time.Duration) int {
+	return max(int(d/(24*time.Hour)), 1)
+}
+
+// Retention returns how long completed downloads stay in the history
+func (s Settings) Retention() time.Duration {
+	return time.Duration(s.RetentionDays) * 24 * time.Hour
+}
+
+// HistoryPolicy returns what the history cleanup keeps
+func (s Settings) HistoryPolicy() retention.Policy {
+	return retention.Policy{
+		Completed: s.Retention(),
+		Failed:    time.Duration(s.FailedRetentionDays) * 24 * time.Hour,
+		MaxRows:   s.MaxHistory,
+	}
+}
+
+// Extensions returns the cleanup extension lists
+func (s Settings) Extensions() cleanup.Extensions {
+	return cleanup.Extensions{Video: s.VideoExtensions, Cleanup: s.CleanupExtensions, Subtitle: s.SubtitleExtensions}
+}
+
+// Validate checks the settings, normalising the extensions and default directory
+func (s *Settings) Validate() error {
+	if s.Concurrency < 1 || s.Concurrency > MaxConcurrency {
+		return fmt.Errorf("concurrent downloads must be between 1 and %d", MaxConcurrency)
+	}
+	if s.SpeedLimit < 0 {
+		return fmt.Errorf("speed limit cannot be negative")
+	}
+	if s.MaxRetries < 0 || s.MaxRetries > MaxRetries {
+		return fmt.Errorf("retries must be between 0 and %d", MaxRetries)
+	}
+	if s.RetryBackoff <= 0 {
+		return fmt.Errorf("retry backoff must be positive")
+	}
+	if s.RetentionDays < 1 {
+		return fmt.Errorf("history must be kept for at least 1 day")
+	}
+	if s.FailedRetentionDays < 1 {
+		return fmt.Errorf("failed downloads must be kept for at least 1 day")
+	}
+	if s.MaxHistory < 0 {
+		return fmt.Errorf("history size cannot be negative")
+	}
+
+	directory := filepath.Clean(strings.TrimSpace(s.DefaultDirectory))
+	if filepath.IsAbs(directory) {
+		return fmt.Errorf("default directory must be relative to the downloads folder")
+	}
+	if directory == ".." || strings.HasPrefix(directory, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("default directory must be inside the downloads folder")
+	}
+	if directory == "." {
+		directory = ""
+	}
+	s.DefaultDirectory = directory
+
+	s.VideoExtensions = config.NormalizeExtensions(s.VideoExtensions)
+	s.CleanupExtensions = config.NormalizeExtensions(s.CleanupExtensions)
+	s.SubtitleExtensions = config.NormalizeExtensions(s.SubtitleExtensions)
+	s.fillExtensions()
+	return nil
+}
+
+// fillExtensions gives empty extension lists the built-in ones
+func (s *Settings) fillExtensions() {
+	defaults := cleanup.DefaultExtensions()
+	if len(s.VideoExtensions) == 0 {
+		s.VideoExtensions = defaults.Video
+	}
+	if len(s.CleanupExtensions) == 0 {
+		s.CleanupExtensions = defaults.Cleanup
+	}
+	if len(s.SubtitleExtensions) == 0 {
+		s.SubtitleExtensions = defaults.Subtitle
+	}
+}
+
+// values returns the settings as stored values by key
+func (s Settings) values() map[string]string {
+	return map[string]string{
+		KeyConcurrency:         strconv.Itoa(s.Concurrency),
+		KeySpeedLimit:          strconv.FormatInt(s.SpeedLimit, 10),
+		KeyMaxRetries:          strconv.Itoa(s.MaxRetries),
+		KeyRetryBackoff:        s.RetryBackoff.String(),
+		KeyRetentionDays:       strconv.Itoa(s.RetentionDays),
+		KeyFailedRetentionDays: strconv.Itoa(s.FailedRetentionDays),
+		KeyMaxHistory:          strconv.Itoa(s.MaxHistory),
+		KeyVideoExtensions:     strings.Join(s.VideoExtensions, ","),
+		KeyCleanupExtensions:   strings.Join(s.CleanupExtensions, ","),
+		KeySubtitleExtensions:  strings.Join(s.SubtitleExtensions, ","),
+		KeyDefaultDirectory:    s.DefaultDirectory,
+	}
+}
+
+// apply sets the settings from stored values by key
+func (s *Settings) apply(values map[string]string) error {
+	for key, value := range values {
+		var err error
+		switch key {
+		case KeyConcurrency:
+			s.Concurrency, err = strconv.Atoi(value)
+		case KeySpeedLimit:
+			s.SpeedLimit, err = strconv.ParseInt(value, 10, 64)
+		case KeyMaxRetries:
+			s.MaxRetries, err = strconv.Atoi(value)
+		case KeyRetryBackoff:
+			s.RetryBackoff, err = time.ParseDuration(value)
+		case KeyRetentionDays:
+			s.RetentionDays, err = strconv.Atoi(value)
+		case KeyFailedRetentionDays:
+			s.FailedRetentionDays, err = strconv.Atoi(value)
+		case KeyMaxHistory:
+			s.MaxHistory, err = strconv.Atoi(value)
+		case KeyVideoExtensions:
+			s.VideoExtensions = strings.Split(value, ",")
+		case KeyCleanupExtensions:
+			s.CleanupExtensions = strings.Split(value, ",")
+		case KeySubtitleExtensions:
+			s.SubtitleExtensions = strings.Split(value, ",")
+		case KeyDefaultDirectory:
+			s.DefaultDirectory = value
+		default:
+			err = fmt.Errorf("unknown setting")
+		}
+		if err != nil {
+			return fmt.Errorf("invalid setting %s: %w", key, err)
+		}
+	}
+	return s.Validate()
+}
+
+// Service keeps the settings in effect. Settings that differ from the defaults are
+// stored, so the others follow the configuration when it changes.
+type Service struct {
+	db     *database.DB
+	logger *slog.Logger
+
+	mu          sync.Mutex
+	defaults    Settings
+	stored      map[string]string
+	current     Settings
+	subscribers []func(Settings)
+}
+
+// NewService creates a settings service, applying the stored settings over the defaults
+func NewService(db *database.DB, defaults Settings) (*Service, error) {
+	stored, err := db.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Service{
+		db:       db,
+		logger:   slog.Default(),
+		defaults: defaults,
+		stored:   stored,
+	}
+	s.current = s.resolve()
+	return s, nil
+}
+
+// resolve returns the stored settings over the defaults, or the defaults alone when the
+// stored ones are no longer valid
+func (s *Service) resolve() Settings {
+	if len(s.stored) == 0 {
+		return s.defaults
+	}
+
+	settings := s.defaults
+	if err := settings.apply(s.stored); err != nil {
+		s.logger.Warn("Ignoring invalid stored settings", "error", err)
+		return s.defaults
+	}
+	return settings
+}
+
+// Current returns the settings in effect
+func (s *Service) Current() Settings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// Defaults returns the settings from the configuration
+func (s *Service) Defaults() Settings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.defaults
+}
+
+// Subscribe calls fn with the settings now and after every change. fn must not call
+// back into the service.
+func (s *Service) Subscribe(fn func(Settings)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscribers = append(s.subscribers, fn)
+	fn(s.current)
+}
+
+// Update validates and stores new settings and applies them
+func (s *Service) Update(next Settings) error {
+	if err := next.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defaults := s.defaults.values()
+	overrides := make(map[string]string)
+	for key, value := range next.values() {
+		if value != defaults[key] {
+			overrides[key] = value
+		}
+	}
+
+	if err := s.db.ReplaceSettings(overrides); err != nil {
+		return fmt.Errorf("failed to save settings: %w", err)
+	}
+
+	s.stored = overrides
+	s.setCurrent(next)
+	return nil
+}
+
+// Reset removes the stored settings so the defaults apply again
+func (s *Service) Reset() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.db.ReplaceSettings(nil); err != nil {
+		return fmt.Errorf("failed to reset settings: %w", err)
+	}
+
+	s.stored = map[string]string{}
+	s.setCurrent(s.defaults)
+	return nil
+}
+
+// Reload reads the stored settings again and applies them, as after an import
+func (s *Service) Reload() error {
+	stored, err := s.db.GetSettings()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stored = stored
+	s.setCurrent(s.resolve())
+	return nil
+}
+
+// SetDefaults changes the defaults, as when the configuration reloads. Stored settings
+// keep their values.
+func (s *Service) SetDefaults(defaults Settings) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defaults = defaults
+	s.setCurrent(s.resolve())
+}
+
+// setCurrent makes settings current and passes them to the subscribers
+func (s *Service) setCurrent(settings Settings) {
+	s.current = settings
+	for _, fn := range s.subscribers {
+		fn(settings)
+	}
+}
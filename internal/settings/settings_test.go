@@ -0,0 +1,148 @@
+package settings
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/config"
+	"debrid-downloader/internal/database"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testDefaults() Settings {
+	return FromConfig(&config.Config{
+		MaxRetries:       5,
+		RetryBackoff:     time.Second,
+		HistoryRetention: 60 * 24 * time.Hour,
+	})
+}
+
+func TestFromConfig(t *testing.T) {
+	settings := testDefaults()
+	require.Equal(t, 1, settings.Concurrency)
+	require.Equal(t, 60, settings.RetentionDays)
+	require.Equal(t, 60*24*time.Hour, settings.Retention())
+	require.Equal(t, 60, settings.FailedRetentionDays)
+	require.Equal(t, 60*24*time.Hour, settings.HistoryPolicy().Failed)
+	require.Contains(t, settings.VideoExtensions, ".mkv")
+	require.NoError(t, settings.Validate())
+}
+
+func TestSettings_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(*Settings)
+		wantErr string
+	}{
+		{"too few downloads", func(s *Settings) { s.Concurrency = 0 }, "concurrent downloads must be between 1 and 10"},
+		{"too many downloads", func(s *Settings) { s.Concurrency = 11 }, "concurrent downloads must be between 1 and 10"},
+		{"negative speed limit", func(s *Settings) { s.SpeedLimit = -1 }, "speed limit cannot be negative"},
+		{"too many retries", func(s *Settings) { s.MaxRetries = 21 }, "retries must be between 0 and 20"},
+		{"no backoff", func(s *Settings) { s.RetryBackoff = 0 }, "retry backoff must be positive"},
+		{"no retention", func(s *Settings) { s.RetentionDays = 0 }, "history must be kept for at least 1 day"},
+		{"no failed retention", func(s *Settings) { s.FailedRetentionDays = 0 }, "failed downloads must be kept for at least 1 day"},
+		{"negative history size", func(s *Settings) { s.MaxHistory = -1 }, "history size cannot be negative"},
+		{"absolute directory", func(s *Settings) { s.DefaultDirectory = "/etc" }, "default directory must be relative"},
+		{"escaping directory", func(s *Settings) { s.DefaultDirectory = "../outside" }, "default directory must be inside"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := testDefaults()
+			tt.change(&settings)
+			err := settings.Validate()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+
+	t.Run("normalises", func(t *testing.T) {
+		settings := testDefaults()
+		settings.DefaultDirectory = " movies/../tv/ "
+		settings.VideoExtensions = []string{"MKV", " mp4", ""}
+		settings.CleanupExtensions = nil
+
+		require.NoError(t, settings.Validate())
+		require.Equal(t, "tv", settings.DefaultDirectory)
+		require.Equal(t, []string{".mkv", ".mp4"}, settings.VideoExtensions)
+		require.Contains(t, settings.CleanupExtensions, ".nfo")
+	})
+}
+
+func TestService(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	service, err := NewService(db, testDefaults())
+	require.NoError(t, err)
+	require.Equal(t, testDefaults(), service.Current())
+
+	var applied []Settings
+	service.Subscribe(func(s Settings) { applied = append(applied, s) })
+	require.Len(t, applied, 1)
+
+	// Invalid settings are rejected without changing anything
+	next := service.Current()
+	next.Concurrency = 50
+	require.Error(t, service.Update(next))
+	require.Equal(t, 1, service.Current().Concurrency)
+	require.Len(t, applied, 1)
+
+	// Only the settings that differ from the defaults are stored
+	next.Concurrency = 3
+	next.SpeedLimit = 2 << 20
+	next.DefaultDirectory = "incoming"
+	require.NoError(t, service.Update(next))
+	require.Equal(t, 3, service.Current().Concurrency)
+	require.Len(t, applied, 2)
+	require.Equal(t, "incoming", applied[1].DefaultDirectory)
+
+	stored, err := db.GetSettings()
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		KeyConcurrency:      "3",
+		KeySpeedLimit:       "2097152",
+		KeyDefaultDirectory: "incoming",
+	}, stored)
+
+	// A new service starts from the stored settings
+	restarted, err := NewService(db, testDefaults())
+	require.NoError(t, err)
+	require.Equal(t, service.Current(), restarted.Current())
+
+	// New defaults apply to the settings that weren't changed
+	defaults := testDefaults()
+	defaults.MaxRetries = 8
+	defaults.Concurrency = 2
+	service.SetDefaults(defaults)
+	require.Equal(t, 8, service.Current().MaxRetries)
+	require.Equal(t, 3, service.Current().Concurrency)
+	require.Len(t, applied, 3)
+
+	// Resetting goes back to the defaults
+	require.NoError(t, service.Reset())
+	require.Equal(t, defaults, service.Current())
+	stored, err = db.GetSettings()
+	require.NoError(t, err)
+	require.Empty(t, stored)
+
+	// Reloading picks up settings written behind the service's back, as by an import
+	require.NoError(t, db.ReplaceSettings(map[string]string{KeyMaxRetries: "6"}))
+	require.NoError(t, service.Reload())
+	require.Equal(t, 6, service.Current().MaxRetries)
+	require.Equal(t, 6, applied[len(applied)-1].MaxRetries)
+}
+
+func TestService_InvalidStoredSettings(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.ReplaceSettings(map[string]string{KeyConcurrency: "many"}))
+
+	service, err := NewService(db, testDefaults())
+	require.NoError(t, err)
+	require.Equal(t, testDefaults(), service.Current())
+}
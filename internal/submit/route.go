@@ -0,0 +1,100 @@
+package submit
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/folder"
+	"debrid-downloader/internal/rules"
+	"debrid-downloader/pkg/fuzzy"
+	"debrid-downloader/pkg/models"
+)
+
+// LinkDetails looks up a link's file name and size, falling back to the name in the
+// link when it can't be unrestricted. The result stays cached for the submission.
+func (s *Service) LinkDetails(ctx context.Context, link string) rules.Link {
+	details := rules.Link{URL: link, Filename: filenameFromURL(link)}
+	if result, err := s.Unrestrict(ctx, link); err == nil {
+		if result.Filename != "" {
+			details.Filename = result.Filename
+		}
+		details.Size = result.FileSize
+	} else {
+		s.logger.Debug("Failed to unrestrict link for routing", "error", err, "url", link)
+	}
+	return details
+}
+
+// RuleDirectory returns the folder of the first routing rule a link matches, or a nil
+// rule. Rules pointing outside the given downloads folder are ignored.
+func (s *Service) RuleDirectory(link rules.Link, folders *folder.Service) (string, *models.Rule) {
+	list, err := s.db.ListRules()
+	if err != nil {
+		s.logger.Error("Failed to list rules", "error", err)
+		return "", nil
+	}
+
+	rule := rules.Match(list, link)
+	if rule == nil {
+		return "", nil
+	}
+
+	directory, err := folders.ValidatePath(rule.Directory)
+	if err != nil {
+		s.logger.Warn("Rule folder is outside the downloads folder", "rule_id", rule.ID, "directory", rule.Directory)
+		return "", nil
+	}
+	return directory, rule
+}
+
+// RankDirectories returns up to limit directories learned for similar files of an owner,
+// or everyone's, best first, leaving out any outside the given downloads folder
+func (s *Service) RankDirectories(ownerID int64, filename, link string, limit int, folders *folder.Service) []fuzzy.Suggestion {
+	tokens := fuzzy.Tokens(filename, link)
+	learned, err := s.db.GetDirectoryTokens(ownerID, tokens)
+	if err != nil {
+		s.logger.Error("Failed to get learned directory tokens", "error", err)
+		return nil
+	}
+
+	ranked := fuzzy.NewMatcher().Rank(tokens, learned, time.Now(), 0)
+	suggestions := make([]fuzzy.Suggestion, 0, limit)
+	for _, suggestion := range ranked {
+		if len(suggestions) == limit {
+			break
+		}
+		if folders.Contains(suggestion.Directory) {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions
+}
+
+// Route returns the folder a link goes to when none was chosen: the folder of the first
+// routing rule it matches, otherwise the directory learned for similar files. It
+// returns an empty string when neither applies.
+func (s *Service) Route(link rules.Link, ownerID int64, folders *folder.Service) string {
+	if directory, rule := s.RuleDirectory(link, folders); rule != nil {
+		return directory
+	}
+	if ranked := s.RankDirectories(ownerID, link.Filename, link.URL, 1, folders); len(ranked) > 0 {
+		return ranked[0].Directory
+	}
+	return ""
+}
+
+// filenameFromURL returns the last segment of a link's path, lowercased
+func filenameFromURL(link string) string {
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Path == "" {
+		return ""
+	}
+	name := path.Base(parsed.Path)
+	if name == "/" || name == "." {
+		return ""
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,325 @@
+// Package submit turns debrid links into queued downloads
+package submit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/pkg/models"
+	"github.com/google/uuid"
+)
+
+// Queue accepts download IDs for processing
+type Queue interface {
+	QueueDownload(downloadID int64)
+}
+
+// Request describes a submission of one or more links into a directory
+type Request struct {
+	URLs      []string
+	Directory string
+	// OwnerID is the user the downloads belong to, 0 if unowned
+	OwnerID int64
+	// Category is the name of the downloads' category, empty for none. Callers
+	// resolve it to Directory; the downloads take their priority from it.
+	Category string
+	// Group forces the downloads into a group even for a single link, so callers
+	// tracking the submission as a whole can follow its post-processing
+	Group bool
+}
+
+// Item is a download created by a submission
+type Item struct {
+	Download *models.Download
+	// SourceFilename is the filename reported by AllDebrid, before any de-duplication
+	SourceFilename string
+}
+
+// Failure records a link from a multi-link submission that could not be queued
+type Failure struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+// Result summarises a submission
+type Result struct {
+	GroupID string
+	Items   []*Item
+	Failed  []Failure
+}
+
+// Error is returned when a submission fails as a whole.
+// Message is safe to show to the user.
+type Error struct {
+	Message  string
+	Internal bool // true when the failure was on our side rather than the request's
+	Err      error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Service unrestricts links, creates download records and queues them
+type Service struct {
+	db     *database.DB
+	client alldebrid.AllDebridClient
+	queue  Queue
+	logger *slog.Logger
+
+	cacheMutex sync.RWMutex
+	urlCache   map[string]*alldebrid.UnrestrictResult // Unrestricted links looked up before submission
+}
+
+// NewService creates a new submit service
+func NewService(db *database.DB, client alldebrid.AllDebridClient, queue Queue) *Service {
+	return &Service{
+		db:       db,
+		client:   client,
+		queue:    queue,
+		logger:   slog.Default(),
+		urlCache: make(map[string]*alldebrid.UnrestrictResult),
+	}
+}
+
+// Unrestrict resolves a link through AllDebrid, reusing a cached result if one exists.
+// Results stay cached until the link is submitted so suggestions don't cost a second API call.
+func (s *Service) Unrestrict(ctx context.Context, url string) (*alldebrid.UnrestrictResult, error) {
+	s.cacheMutex.RLock()
+	cached, exists := s.urlCache[url]
+	s.cacheMutex.RUnlock()
+	if exists {
+		s.logger.Debug("Using cached unrestrict result", "url", url, "filename", cached.Filename)
+		return cached, nil
+	}
+
+	result, err := s.client.UnrestrictLink(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cacheMutex.Lock()
+	s.urlCache[url] = result
+	s.cacheMutex.Unlock()
+
+	return result, nil
+}
+
+// Submit creates and queues a download for each link. Several links are grouped so
+// their archives are extracted together once all parts have finished.
+func (s *Service) Submit(ctx context.Context, req Request) (*Result, error) {
+	if req.Directory == "" {
+		return nil, &Error{Message: "Directory is required"}
+	}
+	if len(req.URLs) == 0 {
+		return nil, &Error{Message: "URL is required"}
+	}
+
+	priority := 0
+	if req.Category != "" {
+		category, err := s.db.GetCategoryByName(req.Category)
+		if err != nil {
+			return nil, &Error{Message: fmt.Sprintf("Unknown category: %s", req.Category), Err: err}
+		}
+		priority = category.Priority
+	}
+
+	// Clean up cache for processed URLs to prevent memory growth
+	defer func() {
+		s.cacheMutex.Lock()
+		for _, url := range req.URLs {
+			delete(s.urlCache, url)
+		}
+		s.cacheMutex.Unlock()
+	}()
+
+	result := &Result{}
+
+	// If multiple URLs, create a group
+	if len(req.URLs) > 1 || req.Group {
+		result.GroupID = uuid.New().String()
+
+		group := &models.DownloadGroup{
+			ID:                 result.GroupID,
+			CreatedAt:          time.Now(),
+			TotalDownloads:     len(req.URLs),
+			CompletedDownloads: 0,
+			Status:             models.GroupStatusDownloading,
+			OwnerID:            req.OwnerID,
+		}
+
+		if err := s.db.CreateDownloadGroup(group); err != nil {
+			s.logger.Error("Failed to create download group", "error", err)
+			return nil, &Error{Message: "Failed to create download group", Internal: true, Err: err}
+		}
+	}
+
+	for i, url := range req.URLs {
+		unrestricted, err := s.Unrestrict(ctx, url)
+		if err != nil {
+			s.logger.Error("Failed to unrestrict URL", "error", err, "url", url, "group_id", result.GroupID)
+			// For multi-URL, continue with other URLs; for single URL, return error
+			if len(req.URLs) == 1 {
+				return nil, &Error{Message: fmt.Sprintf("Failed to unrestrict URL: %s", err.Error()), Err: err}
+			}
+			result.Failed = append(result.Failed, Failure{URL: url, Error: err.Error()})
+			continue
+		}
+
+		isArchive := IsArchiveFile(unrestricted.Filename)
+
+		download := &models.Download{
+			OriginalURL:     url,
+			UnrestrictedURL: unrestricted.UnrestrictedURL,
+			Filename:        UniqueFilename(unrestricted.Filename, req.Directory),
+			Directory:       req.Directory,
+			Status:          models.StatusPending,
+			Progress:        0.0,
+			FileSize:        unrestricted.FileSize,
+			DownloadedBytes: 0,
+			DownloadSpeed:   0.0,
+			RetryCount:      0,
+			CreatedAt:       time.Now(),
+			UpdatedAt:       time.Now(),
+			GroupID:         result.GroupID,
+			IsArchive:       isArchive,
+			ExtractedFiles:  "",
+			OwnerID:         req.OwnerID,
+			Category:        req.Category,
+			Priority:        priority,
+		}
+
+		if err := s.db.CreateDownload(download); err != nil {
+			s.logger.Error("Failed to create download record", "error", err, "url", url, "group_id", result.GroupID)
+			if len(req.URLs) == 1 {
+				return nil, &Error{Message: "Failed to create download record", Internal: true, Err: err}
+			}
+			result.Failed = append(result.Failed, Failure{URL: url, Error: "failed to create download record"})
+			continue
+		}
+
+		result.Items = append(result.Items, &Item{Download: download, SourceFilename: unrestricted.Filename})
+
+		s.queue.QueueDownload(download.ID)
+
+		s.logger.Info("Download submitted", "url", url, "directory", req.Directory, "filename", unrestricted.Filename, "download_id", download.ID, "group_id", result.GroupID, "category", req.Category, "is_archive", isArchive, "position", i+1, "total", len(req.URLs))
+	}
+
+	if len(result.Items) == 0 {
+		return nil, &Error{Message: "No downloads could be created"}
+	}
+
+	// Links that failed will never complete, so the group only waits for the queued ones
+	if result.GroupID != "" && len(result.Failed) > 0 {
+		if group, err := s.db.GetDownloadGroup(result.GroupID); err == nil {
+			group.TotalDownloads = len(result.Items)
+			if err := s.db.UpdateDownloadGroup(group); err != nil {
+				s.logger.Warn("Failed to update download group size", "error", err, "group_id", result.GroupID)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// ParseURLs parses a string containing multiple URLs separated by whitespace or newlines
+func ParseURLs(input string) []string {
+	var urls []string
+
+	// Split on both newlines and spaces, then filter for HTTP URLs
+	lines := strings.Fields(strings.ReplaceAll(input, "\n", " "))
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" && (strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://")) {
+			urls = append(urls, line)
+		}
+	}
+
+	return urls
+}
+
+// UniqueFilename checks if a file exists and generates a unique filename if needed
+func UniqueFilename(filename, directory string) string {
+	originalName := filename
+	counter := 1
+
+	for {
+		// Check if file exists at the target location
+		fullPath := filepath.Join(directory, filename)
+		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+			// File doesn't exist, we can use this name
+			break
+		}
+
+		// File exists, generate a new name
+		ext := filepath.Ext(originalName)
+		nameWithoutExt := strings.TrimSuffix(originalName, ext)
+		filename = fmt.Sprintf("%s(%d)%s", nameWithoutExt, counter, ext)
+		counter++
+
+		// Safety check to prevent infinite loop
+		if counter > 1000 {
+			slog.Warn("Too many filename conflicts, using timestamp", "original", originalName, "directory", directory)
+			timestamp := time.Now().Unix()
+			filename = fmt.Sprintf("%s_%d%s", nameWithoutExt, timestamp, ext)
+			break
+		}
+	}
+
+	if filename != originalName {
+		slog.Info("Generated unique filename", "original", originalName, "unique", filename, "directory", directory)
+	}
+
+	return filename
+}
+
+// IsArchiveFile checks if a filename is an archive that should be extracted
+func IsArchiveFile(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	lowerFilename := strings.ToLower(filename)
+
+	// For RAR files, be more selective about multi-part archives
+	if ext == ".rar" {
+		// If it's a multi-part RAR, only mark the first part as an archive
+		if strings.Contains(lowerFilename, ".part") {
+			return strings.Contains(lowerFilename, ".part1.rar") ||
+				strings.Contains(lowerFilename, ".part01.rar") ||
+				strings.Contains(lowerFilename, ".part001.rar")
+		}
+		// Single RAR files are archives
+		return true
+	}
+
+	// Other archive formats
+	archiveExts := []string{".zip", ".7z", ".tar", ".gz", ".bz2", ".xz"}
+	for _, archiveExt := range archiveExts {
+		if ext == archiveExt {
+			return true
+		}
+	}
+
+	// Check for compound extensions like .tar.gz
+	if strings.HasSuffix(lowerFilename, ".tar.gz") ||
+		strings.HasSuffix(lowerFilename, ".tar.bz2") ||
+		strings.HasSuffix(lowerFilename, ".tar.xz") {
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,174 @@
+package submit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/alldebrid/mocks"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type recordingQueue struct {
+	ids []int64
+}
+
+func (q *recordingQueue) QueueDownload(downloadID int64) {
+	q.ids = append(q.ids, downloadID)
+}
+
+func newTestService(t *testing.T) (*Service, *mocks.MockAllDebridClient, *recordingQueue, *database.DB) {
+	ctrl := gomock.NewController(t)
+
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	client := mocks.NewMockAllDebridClient(ctrl)
+	queue := &recordingQueue{}
+
+	return NewService(db, client, queue), client, queue, db
+}
+
+func TestService_SubmitSingle(t *testing.T) {
+	service, client, queue, db := newTestService(t)
+
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/file.zip").
+		Return(&alldebrid.UnrestrictResult{
+			UnrestrictedURL: "https://dl.alldebrid.com/file.zip",
+			Filename:        "file.zip",
+			FileSize:        1024,
+		}, nil)
+
+	result, err := service.Submit(context.Background(), Request{
+		URLs:      []string{"https://example.com/file.zip"},
+		Directory: "/downloads",
+	})
+	require.NoError(t, err)
+	require.Empty(t, result.GroupID)
+	require.Len(t, result.Items, 1)
+	require.Equal(t, "file.zip", result.Items[0].SourceFilename)
+	require.True(t, result.Items[0].Download.IsArchive)
+	require.Equal(t, []int64{result.Items[0].Download.ID}, queue.ids)
+
+	stored, err := db.GetDownload(result.Items[0].Download.ID)
+	require.NoError(t, err)
+	require.Equal(t, "/downloads", stored.Directory)
+}
+
+func TestService_SubmitMultipleWithFailure(t *testing.T) {
+	service, client, queue, db := newTestService(t)
+
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/a.rar").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/a.rar", Filename: "a.rar"}, nil)
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/b.rar").
+		Return(nil, errors.New("link dead"))
+
+	result, err := service.Submit(context.Background(), Request{
+		URLs:      []string{"https://example.com/a.rar", "https://example.com/b.rar"},
+		Directory: "/downloads",
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, result.GroupID)
+	require.Len(t, result.Items, 1)
+	require.Len(t, result.Failed, 1)
+	require.Equal(t, "https://example.com/b.rar", result.Failed[0].URL)
+	require.Len(t, queue.ids, 1)
+
+	group, err := db.GetDownloadGroup(result.GroupID)
+	require.NoError(t, err)
+	require.Equal(t, 2, group.TotalDownloads)
+}
+
+func TestService_SubmitErrors(t *testing.T) {
+	service, client, _, _ := newTestService(t)
+
+	_, err := service.Submit(context.Background(), Request{URLs: []string{"https://example.com/a"}})
+	var submitErr *Error
+	require.ErrorAs(t, err, &submitErr)
+	require.Equal(t, "Directory is required", submitErr.Message)
+
+	_, err = service.Submit(context.Background(), Request{Directory: "/downloads"})
+	require.ErrorAs(t, err, &submitErr)
+	require.Equal(t, "URL is required", submitErr.Message)
+
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/a").
+		Return(nil, errors.New("bad link"))
+
+	_, err = service.Submit(context.Background(), Request{URLs: []string{"https://example.com/a"}, Directory: "/downloads"})
+	require.ErrorAs(t, err, &submitErr)
+	require.Equal(t, "Failed to unrestrict URL: bad link", submitErr.Message)
+	require.False(t, submitErr.Internal)
+
+	_, err = service.Submit(context.Background(), Request{URLs: []string{"https://example.com/a"}, Directory: "/downloads", Category: "tv"})
+	require.ErrorAs(t, err, &submitErr)
+	require.Equal(t, "Unknown category: tv", submitErr.Message)
+}
+
+func TestService_SubmitWithCategory(t *testing.T) {
+	service, client, _, db := newTestService(t)
+
+	category := models.NewCategory("tv")
+	category.Priority = 5
+	require.NoError(t, db.CreateCategory(category))
+
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/show.mkv").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/show.mkv", Filename: "show.mkv"}, nil)
+
+	result, err := service.Submit(context.Background(), Request{
+		URLs:      []string{"https://example.com/show.mkv"},
+		Directory: "/downloads/tv",
+		Category:  "tv",
+	})
+	require.NoError(t, err)
+
+	stored, err := db.GetDownload(result.Items[0].Download.ID)
+	require.NoError(t, err)
+	require.Equal(t, "tv", stored.Category)
+	require.Equal(t, 5, stored.Priority)
+}
+
+func TestService_UnrestrictCachesUntilSubmitted(t *testing.T) {
+	service, client, _, _ := newTestService(t)
+
+	// Only one API call for the suggestion lookup and the submission
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/file.mkv").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/file.mkv", Filename: "file.mkv"}, nil).
+		Times(1)
+
+	_, err := service.Unrestrict(context.Background(), "https://example.com/file.mkv")
+	require.NoError(t, err)
+
+	_, err = service.Submit(context.Background(), Request{URLs: []string{"https://example.com/file.mkv"}, Directory: "/downloads"})
+	require.NoError(t, err)
+
+	service.cacheMutex.RLock()
+	defer service.cacheMutex.RUnlock()
+	require.Empty(t, service.urlCache)
+}
+
+func TestUniqueFilename(t *testing.T) {
+	dir := t.TempDir()
+	require.Equal(t, "file.txt", UniqueFilename("file.txt", dir))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), nil, 0o644))
+	require.Equal(t, "file(1).txt", UniqueFilename("file.txt", dir))
+}
+
+func TestParseURLs(t *testing.T) {
+	urls := ParseURLs("https://a.example/1\nftp://nope  http://b.example/2")
+	require.Equal(t, []string{"https://a.example/1", "http://b.example/2"}, urls)
+}
@@ -0,0 +1,282 @@
+// Package torrent adds magnets and .torrent files through the debrid provider and
+// hands their files to the download worker once the provider has fetched them
+package torrent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/submit"
+	"debrid-downloader/pkg/models"
+)
+
+// DefaultPollInterval is how often torrents still being fetched by the provider are checked
+const DefaultPollInterval = 15 * time.Second
+
+// Canceller stops a download the worker is currently running
+type Canceller interface {
+	CancelCurrentDownloadIfMatches(downloadID int64) bool
+}
+
+// AddRequest describes where a torrent's files should be downloaded
+type AddRequest struct {
+	Directory string
+	Category  string
+	OwnerID   int64
+}
+
+// Service tracks torrents at the debrid provider
+type Service struct {
+	db           *database.DB
+	client       alldebrid.AllDebridClient
+	submitter    *submit.Service
+	canceller    Canceller
+	logger       *slog.Logger
+	pollInterval time.Duration
+}
+
+// NewService creates a new torrent service
+func NewService(db *database.DB, client alldebrid.AllDebridClient, submitter *submit.Service, canceller Canceller) *Service {
+	return &Service{
+		db:           db,
+		client:       client,
+		submitter:    submitter,
+		canceller:    canceller,
+		logger:       slog.Default(),
+		pollInterval: DefaultPollInterval,
+	}
+}
+
+// AddMagnet uploads a magnet link to the debrid provider
+func (s *Service) AddMagnet(ctx context.Context, magnet string, req AddRequest) (*models.Torrent, error) {
+	uploaded, err := s.client.UploadMagnet(ctx, magnet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload magnet: %w", err)
+	}
+	return s.track(ctx, uploaded, req)
+}
+
+// AddTorrentFile uploads the contents of a .torrent file to the debrid provider
+func (s *Service) AddTorrentFile(ctx context.Context, filename string, data []byte, req AddRequest) (*models.Torrent, error) {
+	uploaded, err := s.client.UploadTorrentFile(ctx, filename, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload torrent file: %w", err)
+	}
+	return s.track(ctx, uploaded, req)
+}
+
+// track records an uploaded magnet and checks straight away whether the provider
+// already has it cached
+func (s *Service) track(ctx context.Context, uploaded *alldebrid.Magnet, req AddRequest) (*models.Torrent, error) {
+	hash := strings.ToLower(uploaded.Hash)
+
+	// Adding the same torrent twice keeps the original
+	if existing, err := s.db.GetTorrent(hash); err == nil {
+		return existing, nil
+	}
+
+	now := time.Now()
+	torrent := &models.Torrent{
+		Hash:      hash,
+		Name:      uploaded.Name,
+		MagnetID:  uploaded.ID,
+		Category:  req.Category,
+		Directory: req.Directory,
+		Size:      uploaded.Size,
+		Status:    models.TorrentStatusQueued,
+		OwnerID:   req.OwnerID,
+		AddedAt:   now,
+		UpdatedAt: now,
+	}
+	if err := s.db.CreateTorrent(torrent); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Torrent added", "hash", hash, "name", torrent.Name, "magnet_id", torrent.MagnetID, "category", torrent.Category)
+
+	if err := s.check(ctx, torrent); err != nil {
+		s.logger.Warn("Failed to check torrent status", "hash", hash, "error", err)
+	}
+
+	return torrent, nil
+}
+
+// Start polls the debrid provider for queued torrents until the context is cancelled
+func (s *Service) Start(ctx context.Context) {
+	s.logger.Info("Starting torrent monitor", "interval", s.pollInterval)
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info("Torrent monitor stopping")
+			return
+		case <-ticker.C:
+			s.poll(ctx)
+		}
+	}
+}
+
+// poll checks every torrent the provider is still fetching
+func (s *Service) poll(ctx context.Context) {
+	torrents, err := s.db.ListTorrentsByStatus(models.TorrentStatusQueued)
+	if err != nil {
+		s.logger.Error("Failed to list queued torrents", "error", err)
+		return
+	}
+
+	for _, torrent := range torrents {
+		if err := s.check(ctx, torrent); err != nil {
+			s.logger.Warn("Failed to check torrent status", "hash", torrent.Hash, "error", err)
+		}
+	}
+}
+
+// check asks the provider for a torrent's status and submits its files once they are ready
+func (s *Service) check(ctx context.Context, torrent *models.Torrent) error {
+	status, err := s.client.MagnetStatus(ctx, torrent.MagnetID)
+	if err != nil {
+		return err
+	}
+
+	if status.Filename != "" {
+		torrent.Name = status.Filename
+	}
+	if status.Size > 0 {
+		torrent.Size = status.Size
+	}
+
+	switch {
+	case status.Failed():
+		torrent.Status = models.TorrentStatusFailed
+		torrent.ErrorMessage = status.Status
+		s.logger.Warn("Torrent failed at debrid provider", "hash", torrent.Hash, "status", status.Status)
+	case status.Ready():
+		s.submit(ctx, torrent, status)
+	default:
+		// Still being fetched by the provider
+	}
+
+	torrent.UpdatedAt = time.Now()
+	return s.db.UpdateTorrent(torrent)
+}
+
+// submit queues the files of a ready torrent as one download group. Torrents with
+// several files get their own folder, as a torrent client would create.
+func (s *Service) submit(ctx context.Context, torrent *models.Torrent, status *alldebrid.MagnetStatus) {
+	if len(status.Links) == 0 {
+		torrent.Status = models.TorrentStatusFailed
+		torrent.ErrorMessage = "Torrent has no files"
+		return
+	}
+
+	urls := make([]string, 0, len(status.Links))
+	for _, link := range status.Links {
+		urls = append(urls, link.Link)
+	}
+
+	directory := torrent.Directory
+	if len(urls) > 1 {
+		directory = filepath.Join(directory, FolderName(torrent))
+	}
+
+	// Torrents can be labelled with categories that were never set up here; those
+	// are only labels and don't affect how the files are processed
+	var category string
+	if _, err := s.db.GetCategoryByName(torrent.Category); err == nil {
+		category = torrent.Category
+	}
+
+	result, err := s.submitter.Submit(ctx, submit.Request{
+		URLs:      urls,
+		Directory: directory,
+		OwnerID:   torrent.OwnerID,
+		Category:  category,
+		Group:     true,
+	})
+	if err != nil {
+		torrent.Status = models.TorrentStatusFailed
+		torrent.ErrorMessage = err.Error()
+		s.logger.Error("Failed to submit torrent files", "hash", torrent.Hash, "error", err)
+		return
+	}
+
+	torrent.Status = models.TorrentStatusDownloading
+	torrent.ErrorMessage = ""
+	torrent.GroupID = result.GroupID
+
+	s.logger.Info("Torrent ready, files queued", "hash", torrent.Hash, "files", len(result.Items), "group_id", result.GroupID)
+}
+
+// Remove deletes a torrent and removes it from the debrid provider. With deleteFiles
+// its downloads are cancelled and their files removed; otherwise they are left alone.
+func (s *Service) Remove(ctx context.Context, torrent *models.Torrent, deleteFiles bool) error {
+	if err := s.client.DeleteMagnet(ctx, torrent.MagnetID); err != nil {
+		// The provider may already have dropped it, so this doesn't stop the removal
+		s.logger.Warn("Failed to delete magnet at debrid provider", "hash", torrent.Hash, "magnet_id", torrent.MagnetID, "error", err)
+	}
+
+	if deleteFiles && torrent.GroupID != "" {
+		if err := s.deleteDownloads(torrent); err != nil {
+			return err
+		}
+	}
+
+	if err := s.db.DeleteTorrent(torrent.Hash); err != nil {
+		return err
+	}
+
+	s.logger.Info("Torrent removed", "hash", torrent.Hash, "name", torrent.Name, "delete_files", deleteFiles)
+	return nil
+}
+
+// deleteDownloads removes a torrent's downloads along with their files
+func (s *Service) deleteDownloads(torrent *models.Torrent) error {
+	downloads, err := s.db.GetDownloadsByGroupID(torrent.GroupID)
+	if err != nil {
+		return err
+	}
+
+	for _, download := range downloads {
+		if download.Status == models.StatusDownloading && s.canceller != nil {
+			s.canceller.CancelCurrentDownloadIfMatches(download.ID)
+		}
+
+		path := filepath.Join(download.Directory, download.Filename)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			s.logger.Warn("Failed to remove downloaded file", "path", path, "error", err)
+		}
+
+		if err := s.db.DeleteDownload(download.ID); err != nil {
+			return err
+		}
+	}
+
+	// Multi-file torrents have their own folder, removed once it is empty
+	if len(downloads) > 1 {
+		folder := filepath.Join(torrent.Directory, FolderName(torrent))
+		if err := os.Remove(folder); err != nil && !os.IsNotExist(err) {
+			s.logger.Debug("Torrent folder not removed", "path", folder, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// FolderName returns the folder a multi-file torrent is downloaded into
+func FolderName(torrent *models.Torrent) string {
+	name := strings.TrimSpace(strings.NewReplacer("/", "_", `\`, "_").Replace(torrent.Name))
+	if name == "" || name == "." || name == ".." {
+		return torrent.Hash
+	}
+	return name
+}
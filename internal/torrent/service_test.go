@@ -0,0 +1,198 @@
+package torrent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/alldebrid/mocks"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/submit"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type recordingQueue struct {
+	ids []int64
+}
+
+func (q *recordingQueue) QueueDownload(downloadID int64) {
+	q.ids = append(q.ids, downloadID)
+}
+
+func (q *recordingQueue) CancelCurrentDownloadIfMatches(downloadID int64) bool {
+	return false
+}
+
+func newTestService(t *testing.T) (*Service, *mocks.MockAllDebridClient, *recordingQueue, *database.DB) {
+	ctrl := gomock.NewController(t)
+
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	client := mocks.NewMockAllDebridClient(ctrl)
+	queue := &recordingQueue{}
+
+	return NewService(db, client, submit.NewService(db, client, queue), queue), client, queue, db
+}
+
+func TestService_AddMagnetReady(t *testing.T) {
+	service, client, queue, db := newTestService(t)
+	directory := t.TempDir()
+
+	client.EXPECT().
+		UploadMagnet(gomock.Any(), "magnet:?xt=urn:btih:ABC").
+		Return(&alldebrid.Magnet{ID: 7, Hash: "ABC", Name: "Show.S01", Size: 300, Ready: true}, nil)
+	client.EXPECT().
+		MagnetStatus(gomock.Any(), int64(7)).
+		Return(&alldebrid.MagnetStatus{
+			ID:         7,
+			Filename:   "Show.S01",
+			StatusCode: alldebrid.MagnetStatusReady,
+			Links: []alldebrid.MagnetLink{
+				{Link: "https://alldebrid.com/f/1", Filename: "e01.mkv"},
+				{Link: "https://alldebrid.com/f/2", Filename: "e02.mkv"},
+			},
+		}, nil)
+	for link, name := range map[string]string{"https://alldebrid.com/f/1": "e01.mkv", "https://alldebrid.com/f/2": "e02.mkv"} {
+		client.EXPECT().
+			UnrestrictLink(gomock.Any(), link).
+			Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/" + name, Filename: name, FileSize: 150}, nil)
+	}
+
+	torrent, err := service.AddMagnet(context.Background(), "magnet:?xt=urn:btih:ABC", AddRequest{Directory: directory, Category: "tv", OwnerID: 3})
+	require.NoError(t, err)
+	require.Equal(t, "abc", torrent.Hash)
+	require.Equal(t, models.TorrentStatusDownloading, torrent.Status)
+	require.NotEmpty(t, torrent.GroupID)
+	require.Len(t, queue.ids, 2)
+
+	stored, err := db.GetTorrent("abc")
+	require.NoError(t, err)
+	require.Equal(t, torrent.GroupID, stored.GroupID)
+	require.Equal(t, "tv", stored.Category)
+
+	// Multi-file torrents get their own folder
+	downloads, err := db.GetDownloadsByGroupID(torrent.GroupID)
+	require.NoError(t, err)
+	require.Len(t, downloads, 2)
+	require.Equal(t, filepath.Join(directory, "Show.S01"), downloads[0].Directory)
+	require.Equal(t, int64(3), downloads[0].OwnerID)
+
+	// Adding it again keeps the original
+	client.EXPECT().
+		UploadMagnet(gomock.Any(), "magnet:?xt=urn:btih:ABC").
+		Return(&alldebrid.Magnet{ID: 7, Hash: "ABC"}, nil)
+	again, err := service.AddMagnet(context.Background(), "magnet:?xt=urn:btih:ABC", AddRequest{Directory: directory})
+	require.NoError(t, err)
+	require.Equal(t, torrent.GroupID, again.GroupID)
+}
+
+func TestService_PollQueued(t *testing.T) {
+	service, client, queue, db := newTestService(t)
+	directory := t.TempDir()
+
+	client.EXPECT().
+		UploadTorrentFile(gomock.Any(), "movie.torrent", []byte("d4:infoe")).
+		Return(&alldebrid.Magnet{ID: 8, Hash: "def", Name: "Movie"}, nil)
+	client.EXPECT().
+		MagnetStatus(gomock.Any(), int64(8)).
+		Return(&alldebrid.MagnetStatus{ID: 8, Status: "Downloading", StatusCode: 1}, nil)
+
+	torrent, err := service.AddTorrentFile(context.Background(), "movie.torrent", []byte("d4:infoe"), AddRequest{Directory: directory})
+	require.NoError(t, err)
+	require.Equal(t, models.TorrentStatusQueued, torrent.Status)
+	require.Empty(t, queue.ids)
+
+	// Ready on the next poll, with a single file saved straight into the directory
+	client.EXPECT().
+		MagnetStatus(gomock.Any(), int64(8)).
+		Return(&alldebrid.MagnetStatus{
+			ID:         8,
+			StatusCode: alldebrid.MagnetStatusReady,
+			Links:      []alldebrid.MagnetLink{{Link: "https://alldebrid.com/f/3", Filename: "movie.mkv"}},
+		}, nil)
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://alldebrid.com/f/3").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/movie.mkv", Filename: "movie.mkv"}, nil)
+
+	service.poll(context.Background())
+
+	stored, err := db.GetTorrent("def")
+	require.NoError(t, err)
+	require.Equal(t, models.TorrentStatusDownloading, stored.Status)
+	require.Len(t, queue.ids, 1)
+
+	download, err := db.GetDownload(queue.ids[0])
+	require.NoError(t, err)
+	require.Equal(t, directory, download.Directory)
+	require.Equal(t, stored.GroupID, download.GroupID)
+}
+
+func TestService_ProviderFailure(t *testing.T) {
+	service, client, _, db := newTestService(t)
+
+	client.EXPECT().
+		UploadMagnet(gomock.Any(), gomock.Any()).
+		Return(&alldebrid.Magnet{ID: 9, Hash: "bad", Name: "Broken"}, nil)
+	client.EXPECT().
+		MagnetStatus(gomock.Any(), int64(9)).
+		Return(&alldebrid.MagnetStatus{ID: 9, Status: "Upload fail", StatusCode: 5}, nil)
+
+	_, err := service.AddMagnet(context.Background(), "magnet:?xt=urn:btih:bad", AddRequest{Directory: t.TempDir()})
+	require.NoError(t, err)
+
+	stored, err := db.GetTorrent("bad")
+	require.NoError(t, err)
+	require.Equal(t, models.TorrentStatusFailed, stored.Status)
+	require.Equal(t, "Upload fail", stored.ErrorMessage)
+}
+
+func TestService_Remove(t *testing.T) {
+	service, client, _, db := newTestService(t)
+	directory := t.TempDir()
+
+	client.EXPECT().
+		UploadMagnet(gomock.Any(), gomock.Any()).
+		Return(&alldebrid.Magnet{ID: 10, Hash: "gone", Name: "Gone"}, nil)
+	client.EXPECT().
+		MagnetStatus(gomock.Any(), int64(10)).
+		Return(&alldebrid.MagnetStatus{
+			ID:         10,
+			StatusCode: alldebrid.MagnetStatusReady,
+			Links:      []alldebrid.MagnetLink{{Link: "https://alldebrid.com/f/4", Filename: "gone.mkv"}},
+		}, nil)
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://alldebrid.com/f/4").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/gone.mkv", Filename: "gone.mkv"}, nil)
+
+	torrent, err := service.AddMagnet(context.Background(), "magnet:?xt=urn:btih:gone", AddRequest{Directory: directory})
+	require.NoError(t, err)
+
+	path := filepath.Join(directory, "gone.mkv")
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0o644))
+
+	// The provider failing to delete doesn't stop the removal
+	client.EXPECT().DeleteMagnet(gomock.Any(), int64(10)).Return(errors.New("already gone"))
+
+	require.NoError(t, service.Remove(context.Background(), torrent, true))
+
+	_, err = db.GetTorrent("gone")
+	require.Error(t, err)
+	require.NoFileExists(t, path)
+
+	downloads, err := db.GetDownloadsByGroupID(torrent.GroupID)
+	require.NoError(t, err)
+	require.Empty(t, downloads)
+}
+
+func TestFolderName(t *testing.T) {
+	require.Equal(t, "Show_S01", FolderName(&models.Torrent{Name: "Show/S01", Hash: "abc"}))
+	require.Equal(t, "abc", FolderName(&models.Torrent{Name: "..", Hash: "abc"}))
+}
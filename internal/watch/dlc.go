@@ -0,0 +1,94 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DLCDecrypter turns DLC containers into links using a dcrypt.it-compatible service.
+// DLC containers can only be decrypted with keys held by such services.
+type DLCDecrypter struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewDLCDecrypter creates a decrypter that uploads containers to the given URL
+func NewDLCDecrypter(url string) *DLCDecrypter {
+	return &DLCDecrypter{
+		url:        url,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// dcryptResponse is the JSON returned by the decryption service
+type dcryptResponse struct {
+	Success *struct {
+		Message string   `json:"message"`
+		Links   []string `json:"links"`
+	} `json:"success"`
+	FormErrors map[string][]string `json:"form_errors"`
+}
+
+// Decrypt returns the links in a DLC container
+func (d *DLCDecrypter) Decrypt(ctx context.Context, filename string, data []byte) ([]string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("dlcfile", filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create form file: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to write form file: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close multipart writer: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt DLC: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to decrypt DLC: status %d", resp.StatusCode)
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Upload responses are wrapped in a textarea for iframe-based uploaders
+	text := strings.TrimSpace(string(raw))
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "<textarea>"), "</textarea>")
+
+	var decoded dcryptResponse
+	if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if decoded.Success == nil {
+		for _, messages := range decoded.FormErrors {
+			if len(messages) > 0 {
+				return nil, fmt.Errorf("failed to decrypt DLC: %s", messages[0])
+			}
+		}
+		return nil, fmt.Errorf("failed to decrypt DLC")
+	}
+
+	return decoded.Success.Links, nil
+}
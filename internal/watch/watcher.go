@@ -0,0 +1,370 @@
+// Package watch picks up link lists, torrents and DLC containers dropped into a folder
+// and submits them as downloads
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/folder"
+	"debrid-downloader/internal/rules"
+	"debrid-downloader/internal/submit"
+	"debrid-downloader/internal/torrent"
+)
+
+const (
+	// ProcessedFolder is where files are moved once submitted
+	ProcessedFolder = ".processed"
+	// FailedFolder is where files that couldn't be submitted are moved, each with an error report
+	FailedFolder = ".failed"
+
+	// settleTime is how long a file must go unmodified before it is read, so files
+	// still being copied in aren't picked up half-written
+	settleTime = 2 * time.Second
+)
+
+// Options configures a watcher
+type Options struct {
+	Path     string
+	BasePath string
+	Interval time.Duration
+	// DLCDecryptURL is the service DLC containers are decrypted with; empty disables them
+	DLCDecryptURL string
+	// Owner is the username the downloads belong to; empty leaves them unowned
+	Owner string
+}
+
+// Watcher scans a folder for files to submit. A file's subfolder chooses where its
+// downloads go: the directory of the category with that name, otherwise the same
+// path under the downloads folder. Files in the watch folder itself go where the
+// routing rules or learned suggestions send their first link, or to the downloads
+// folder.
+type Watcher struct {
+	db            *database.DB
+	submitter     *submit.Service
+	torrents      *torrent.Service
+	dlc           *DLCDecrypter
+	folderService *folder.Service
+	path          string
+	owner         string
+	interval      time.Duration
+	logger        *slog.Logger
+
+	// stuck remembers files handled but not moved out of the watch folder, by
+	// modification time, so they aren't submitted again on every scan
+	mu    sync.Mutex
+	stuck map[string]time.Time
+}
+
+// NewWatcher creates a new watch folder scanner
+func NewWatcher(db *database.DB, submitter *submit.Service, torrents *torrent.Service, opts Options) *Watcher {
+	w := &Watcher{
+		db:            db,
+		submitter:     submitter,
+		torrents:      torrents,
+		folderService: folder.NewService(opts.BasePath),
+		path:          filepath.Clean(opts.Path),
+		owner:         opts.Owner,
+		interval:      opts.Interval,
+		logger:        slog.Default(),
+		stuck:         make(map[string]time.Time),
+	}
+	if opts.DLCDecryptURL != "" {
+		w.dlc = NewDLCDecrypter(opts.DLCDecryptURL)
+	}
+	return w
+}
+
+// Start scans the watch folder until the context is cancelled
+func (w *Watcher) Start(ctx context.Context) {
+	if err := os.MkdirAll(w.path, 0o755); err != nil {
+		w.logger.Error("Failed to create watch folder", "path", w.path, "error", err)
+		return
+	}
+
+	w.logger.Info("Starting watch folder", "path", w.path, "interval", w.interval)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.Scan(ctx)
+
+		select {
+		case <-ctx.Done():
+			w.logger.Info("Watch folder stopping")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan submits every supported file in the watch folder
+func (w *Watcher) Scan(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	seen := make(map[string]bool)
+	err := filepath.WalkDir(w.path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Skips the processed and failed folders along with other hidden entries
+		if strings.HasPrefix(entry.Name(), ".") && path != w.path {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !supported(path) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) < settleTime {
+			return nil
+		}
+
+		seen[path] = true
+		if modTime, ok := w.stuck[path]; ok && modTime.Equal(info.ModTime()) {
+			return nil
+		}
+		delete(w.stuck, path)
+
+		if !w.handle(ctx, path) {
+			w.stuck[path] = info.ModTime()
+		}
+		return ctx.Err()
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		w.logger.Error("Failed to scan watch folder", "path", w.path, "error", err)
+	}
+
+	// Forget files that have since been removed by hand
+	if err == nil {
+		for path := range w.stuck {
+			if !seen[path] {
+				delete(w.stuck, path)
+			}
+		}
+	}
+}
+
+// supported reports whether a file is one the watcher reads
+func supported(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".txt", ".torrent", ".magnet", ".dlc":
+		return true
+	}
+	return false
+}
+
+// handle processes a file and moves it to the processed or failed folder. It reports
+// whether the file left the watch folder.
+func (w *Watcher) handle(ctx context.Context, path string) bool {
+	relative, err := filepath.Rel(w.path, path)
+	if err != nil {
+		w.logger.Error("Failed to resolve watched file", "path", path, "error", err)
+		return false
+	}
+
+	problems, err := w.process(ctx, path, filepath.Dir(relative))
+	if err != nil {
+		w.logger.Error("Failed to process watched file", "file", relative, "error", err)
+		w.report(relative, err.Error())
+		return w.move(path, relative, FailedFolder)
+	}
+
+	if len(problems) > 0 {
+		w.logger.Warn("Some links in watched file failed", "file", relative, "failed", len(problems))
+		w.report(relative, strings.Join(problems, "\n"))
+	}
+	return w.move(path, relative, ProcessedFolder)
+}
+
+// process submits the contents of a file, returning problems with individual links
+func (w *Watcher) process(ctx context.Context, path, subfolder string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	ownerID, err := w.ownerID()
+	if err != nil {
+		return nil, err
+	}
+
+	directory, category, err := w.directoryFor(subfolder)
+	if err != nil {
+		return nil, err
+	}
+	torrentReq := torrent.AddRequest{Directory: directory, Category: category, OwnerID: ownerID}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".torrent":
+		if subfolder == "." {
+			torrentReq.Directory = w.route(rules.Link{Filename: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}, ownerID)
+		}
+		_, err := w.torrents.AddTorrentFile(ctx, filepath.Base(path), data, torrentReq)
+		return nil, err
+	case ".dlc":
+		if w.dlc == nil {
+			return nil, fmt.Errorf("DLC containers are disabled")
+		}
+		links, err := w.dlc.Decrypt(ctx, filepath.Base(path), data)
+		if err != nil {
+			return nil, err
+		}
+		return w.submitLinks(ctx, links, nil, subfolder == ".", torrentReq)
+	default:
+		links := submit.ParseURLs(string(data))
+		var magnets []string
+		for _, line := range strings.Fields(string(data)) {
+			if strings.HasPrefix(strings.ToLower(line), "magnet:") {
+				magnets = append(magnets, line)
+			}
+		}
+		return w.submitLinks(ctx, links, magnets, subfolder == ".", torrentReq)
+	}
+}
+
+// submitLinks submits hoster links as one submission, grouped when there are several,
+// and adds each magnet as a torrent. With route set, the first link picks the
+// directory of them all.
+func (w *Watcher) submitLinks(ctx context.Context, links, magnets []string, route bool, torrentReq torrent.AddRequest) ([]string, error) {
+	if len(links) == 0 && len(magnets) == 0 {
+		return nil, fmt.Errorf("no links found")
+	}
+
+	if route {
+		if len(links) > 0 {
+			torrentReq.Directory = w.route(w.submitter.LinkDetails(ctx, links[0]), torrentReq.OwnerID)
+		} else {
+			torrentReq.Directory = w.route(rules.Link{URL: magnets[0], Filename: magnetName(magnets[0])}, torrentReq.OwnerID)
+		}
+	}
+
+	var problems []string
+	submitted := 0
+
+	if len(links) > 0 {
+		result, err := w.submitter.Submit(ctx, submit.Request{URLs: links, Directory: torrentReq.Directory, OwnerID: torrentReq.OwnerID, Category: torrentReq.Category})
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			submitted += len(result.Items)
+			for _, failure := range result.Failed {
+				problems = append(problems, failure.URL+": "+failure.Error)
+			}
+		}
+	}
+
+	for _, magnet := range magnets {
+		if _, err := w.torrents.AddMagnet(ctx, magnet, torrentReq); err != nil {
+			problems = append(problems, magnet+": "+err.Error())
+			continue
+		}
+		submitted++
+	}
+
+	if submitted == 0 {
+		return nil, errors.New(strings.Join(problems, "\n"))
+	}
+	return problems, nil
+}
+
+// directoryFor returns the download directory for files in a subfolder of the watch
+// folder, and the category it matched if any. Files in the watch folder itself are
+// routed by their links, so the downloads folder is only the fallback for them.
+func (w *Watcher) directoryFor(subfolder string) (directory, category string, err error) {
+	if subfolder == "." {
+		return w.folderService.BasePath, "", nil
+	}
+
+	subfolder = filepath.ToSlash(subfolder)
+	if match, err := w.db.GetCategoryByName(subfolder); err == nil {
+		directory, err := w.folderService.ValidatePath(match.DirectoryName())
+		return directory, match.Name, err
+	}
+
+	directory, err = w.folderService.ValidatePath(subfolder)
+	return directory, "", err
+}
+
+// route returns the folder the routing rules or learned suggestions pick for a link,
+// or the downloads folder when they don't
+func (w *Watcher) route(link rules.Link, ownerID int64) string {
+	if directory := w.submitter.Route(link, ownerID, w.folderService); directory != "" {
+		return directory
+	}
+	return w.folderService.BasePath
+}
+
+// ownerID looks up the user the watch folder's downloads belong to, 0 when none is set
+func (w *Watcher) ownerID() (int64, error) {
+	if w.owner == "" {
+		return 0, nil
+	}
+	user, err := w.db.GetUserByUsername(w.owner)
+	if err != nil {
+		return 0, fmt.Errorf("watch folder owner %q not found: %w", w.owner, err)
+	}
+	return user.ID, nil
+}
+
+// magnetName returns the display name of a magnet link, empty if it has none
+func magnetName(magnet string) string {
+	query, _, _ := strings.Cut(strings.TrimPrefix(magnet, "magnet:?"), "#")
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return ""
+	}
+	return values.Get("dn")
+}
+
+// move puts a handled file under the processed or failed folder, keeping its
+// subfolder and adding a timestamp if the name is taken. A file that can't be moved
+// is left in place and skipped by later scans until it changes.
+func (w *Watcher) move(path, relative, destination string) bool {
+	target := filepath.Join(w.path, destination, relative)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		w.logger.Error("Failed to create folder for watched file, it won't be submitted again until it changes", "path", filepath.Dir(target), "error", err)
+		return false
+	}
+
+	if _, err := os.Stat(target); err == nil {
+		ext := filepath.Ext(target)
+		target = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(target, ext), time.Now().Unix(), ext)
+	}
+
+	if err := os.Rename(path, target); err != nil {
+		w.logger.Error("Failed to move watched file, it won't be submitted again until it changes", "from", path, "to", target, "error", err)
+		return false
+	}
+	return true
+}
+
+// report writes an error report for a file into the failed folder
+func (w *Watcher) report(relative, message string) {
+	target := filepath.Join(w.path, FailedFolder, relative+".error.txt")
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		w.logger.Error("Failed to create failed folder", "path", filepath.Dir(target), "error", err)
+		return
+	}
+
+	content := fmt.Sprintf("%s\n%s\n", time.Now().Format(time.RFC3339), message)
+	if err := os.WriteFile(target, []byte(content), 0o644); err != nil {
+		w.logger.Error("Failed to write error report", "path", target, "error", err)
+	}
+}
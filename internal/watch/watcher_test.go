@@ -0,0 +1,318 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/alldebrid/mocks"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/submit"
+	"debrid-downloader/internal/torrent"
+	"debrid-downloader/pkg/fuzzy"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type recordingQueue struct {
+	ids []int64
+}
+
+func (q *recordingQueue) QueueDownload(downloadID int64) {
+	q.ids = append(q.ids, downloadID)
+}
+
+func (q *recordingQueue) CancelCurrentDownloadIfMatches(downloadID int64) bool {
+	return false
+}
+
+func newTestWatcher(t *testing.T, dlcURL string) (*Watcher, *mocks.MockAllDebridClient, *database.DB, string, string) {
+	ctrl := gomock.NewController(t)
+
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	client := mocks.NewMockAllDebridClient(ctrl)
+	queue := &recordingQueue{}
+	submitter := submit.NewService(db, client, queue)
+	torrents := torrent.NewService(db, client, submitter, queue)
+
+	watchPath := t.TempDir()
+	basePath := t.TempDir()
+	watcher := NewWatcher(db, submitter, torrents, Options{Path: watchPath, BasePath: basePath, Interval: time.Second, DLCDecryptURL: dlcURL})
+
+	return watcher, client, db, watchPath, basePath
+}
+
+// drop writes a file into the watch folder old enough to be picked up
+func drop(t *testing.T, watchPath, relative, content string) {
+	path := filepath.Join(watchPath, relative)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	old := time.Now().Add(-time.Minute)
+	require.NoError(t, os.Chtimes(path, old, old))
+}
+
+func TestWatcher_LinkList(t *testing.T) {
+	watcher, client, db, watchPath, basePath := newTestWatcher(t, "")
+	require.NoError(t, db.CreateCategory(&models.Category{Name: "movies", Directory: "Films", CreatedAt: time.Now()}))
+
+	for _, name := range []string{"a.rar", "b.rar"} {
+		client.EXPECT().
+			UnrestrictLink(gomock.Any(), "https://example.com/"+name).
+			Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/" + name, Filename: name}, nil)
+	}
+
+	drop(t, watchPath, "movies/list.txt", "https://example.com/a.rar\nhttps://example.com/b.rar\n")
+	watcher.Scan(context.Background())
+
+	downloads, err := db.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 2)
+	require.NotEmpty(t, downloads[0].GroupID)
+	require.Equal(t, downloads[0].GroupID, downloads[1].GroupID)
+	require.Equal(t, filepath.Join(basePath, "Films"), downloads[0].Directory)
+
+	require.NoFileExists(t, filepath.Join(watchPath, "movies", "list.txt"))
+	require.FileExists(t, filepath.Join(watchPath, ProcessedFolder, "movies", "list.txt"))
+
+	// Processed files are not picked up again
+	watcher.Scan(context.Background())
+	downloads, err = db.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 2)
+}
+
+func TestWatcher_SubfolderPath(t *testing.T) {
+	watcher, client, db, watchPath, basePath := newTestWatcher(t, "")
+
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/file.mkv").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/file.mkv", Filename: "file.mkv"}, nil)
+
+	drop(t, watchPath, "tv/show/list.txt", "https://example.com/file.mkv")
+	watcher.Scan(context.Background())
+
+	downloads, err := db.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 1)
+	require.Equal(t, filepath.Join(basePath, "tv", "show"), downloads[0].Directory)
+}
+
+func TestWatcher_RootFolderRouting(t *testing.T) {
+	watcher, client, db, watchPath, basePath := newTestWatcher(t, "")
+	require.NoError(t, db.CreateRule(&models.Rule{Condition: models.RuleFilename, Value: `\.iso$`, Directory: "Software", CreatedAt: time.Now()}))
+	require.NoError(t, db.CreateRule(&models.Rule{Condition: models.RuleFilename, Value: `(?i)^show`, Directory: "TV", CreatedAt: time.Now()}))
+	require.NoError(t, db.LearnDirectoryTokens(0, fuzzy.Tokens("Great.Movie.2023.mkv", ""), filepath.Join(basePath, "Movies")))
+
+	// The unrestricted name is looked up once for the rules and reused by the submission
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/abc").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/distro.iso", Filename: "distro.iso"}, nil)
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/def").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/movie.mkv", Filename: "Other.Movie.2023.mkv"}, nil)
+	client.EXPECT().
+		UploadMagnet(gomock.Any(), "magnet:?xt=urn:btih:abc&dn=Show.S01").
+		Return(&alldebrid.Magnet{ID: 1, Hash: "abc", Name: "Show.S01"}, nil)
+	client.EXPECT().
+		MagnetStatus(gomock.Any(), gomock.Any()).
+		Return(&alldebrid.MagnetStatus{Status: "Downloading", StatusCode: 1}, nil)
+
+	drop(t, watchPath, "software.txt", "https://example.com/abc")
+	drop(t, watchPath, "movie.txt", "https://example.com/def")
+	drop(t, watchPath, "show.magnet", "magnet:?xt=urn:btih:abc&dn=Show.S01")
+	watcher.Scan(context.Background())
+
+	downloads, err := db.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 2)
+	directories := map[string]string{}
+	for _, download := range downloads {
+		directories[download.Filename] = download.Directory
+	}
+	require.Equal(t, filepath.Join(basePath, "Software"), directories["distro.iso"])
+	require.Equal(t, filepath.Join(basePath, "Movies"), directories["Other.Movie.2023.mkv"])
+
+	torrents, err := db.ListTorrents(database.AllOwners, "")
+	require.NoError(t, err)
+	require.Len(t, torrents, 1)
+	require.Equal(t, filepath.Join(basePath, "TV"), torrents[0].Directory)
+}
+
+func TestWatcher_Owner(t *testing.T) {
+	watcher, client, db, watchPath, _ := newTestWatcher(t, "")
+	watcher.owner = "alice"
+
+	// Files wait for the owner to exist
+	drop(t, watchPath, "early.txt", "https://example.com/early.mkv")
+	watcher.Scan(context.Background())
+	report, err := os.ReadFile(filepath.Join(watchPath, FailedFolder, "early.txt.error.txt"))
+	require.NoError(t, err)
+	require.Contains(t, string(report), `watch folder owner "alice" not found`)
+
+	alice := &models.User{Username: "alice", Role: models.RoleUser, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(alice))
+
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/file.mkv").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/file.mkv", Filename: "file.mkv"}, nil)
+	client.EXPECT().
+		UploadMagnet(gomock.Any(), "magnet:?xt=urn:btih:abc").
+		Return(&alldebrid.Magnet{ID: 1, Hash: "abc", Name: "Show"}, nil)
+	client.EXPECT().
+		MagnetStatus(gomock.Any(), gomock.Any()).
+		Return(&alldebrid.MagnetStatus{Status: "Downloading", StatusCode: 1}, nil)
+
+	drop(t, watchPath, "list.txt", "https://example.com/file.mkv")
+	drop(t, watchPath, "show.magnet", "magnet:?xt=urn:btih:abc")
+	watcher.Scan(context.Background())
+
+	downloads, err := db.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 1)
+	require.Equal(t, alice.ID, downloads[0].OwnerID)
+
+	torrents, err := db.ListTorrents(database.AllOwners, "")
+	require.NoError(t, err)
+	require.Len(t, torrents, 1)
+	require.Equal(t, alice.ID, torrents[0].OwnerID)
+}
+
+func TestWatcher_UnmovableFile(t *testing.T) {
+	watcher, client, db, watchPath, _ := newTestWatcher(t, "")
+
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/file.mkv").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/file.mkv", Filename: "file.mkv"}, nil).
+		Times(2)
+
+	// A file in place of the processed folder stops the move
+	require.NoError(t, os.WriteFile(filepath.Join(watchPath, ProcessedFolder), nil, 0o644))
+	drop(t, watchPath, "list.txt", "https://example.com/file.mkv")
+
+	watcher.Scan(context.Background())
+	watcher.Scan(context.Background())
+
+	downloads, err := db.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 1)
+	require.FileExists(t, filepath.Join(watchPath, "list.txt"))
+
+	// Changing the file submits it again
+	drop(t, watchPath, "list.txt", "https://example.com/file.mkv\n")
+	changed := time.Now().Add(-30 * time.Second)
+	require.NoError(t, os.Chtimes(filepath.Join(watchPath, "list.txt"), changed, changed))
+	watcher.Scan(context.Background())
+
+	downloads, err = db.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 2)
+}
+
+func TestWatcher_MagnetAndTorrent(t *testing.T) {
+	watcher, client, db, watchPath, _ := newTestWatcher(t, "")
+
+	client.EXPECT().
+		UploadMagnet(gomock.Any(), "magnet:?xt=urn:btih:abc").
+		Return(&alldebrid.Magnet{ID: 1, Hash: "abc", Name: "Show"}, nil)
+	client.EXPECT().
+		UploadTorrentFile(gomock.Any(), "movie.torrent", []byte("d4:infoe")).
+		Return(&alldebrid.Magnet{ID: 2, Hash: "def", Name: "Movie"}, nil)
+	client.EXPECT().
+		MagnetStatus(gomock.Any(), gomock.Any()).
+		Return(&alldebrid.MagnetStatus{Status: "Downloading", StatusCode: 1}, nil).
+		Times(2)
+
+	drop(t, watchPath, "show.magnet", "magnet:?xt=urn:btih:abc\n")
+	drop(t, watchPath, "movie.torrent", "d4:infoe")
+	watcher.Scan(context.Background())
+
+	torrents, err := db.ListTorrents(database.AllOwners, "")
+	require.NoError(t, err)
+	require.Len(t, torrents, 2)
+	require.FileExists(t, filepath.Join(watchPath, ProcessedFolder, "show.magnet"))
+	require.FileExists(t, filepath.Join(watchPath, ProcessedFolder, "movie.torrent"))
+}
+
+func TestWatcher_Failures(t *testing.T) {
+	watcher, client, _, watchPath, _ := newTestWatcher(t, "")
+
+	// Routing the file tries the link before the submission does
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/dead.zip").
+		Return(nil, errors.New("link dead")).
+		Times(2)
+
+	drop(t, watchPath, "empty.txt", "nothing to see here")
+	drop(t, watchPath, "dead.txt", "https://example.com/dead.zip")
+	drop(t, watchPath, "container.dlc", "encrypted")
+	drop(t, watchPath, "notes.md", "https://example.com/ignored.zip")
+	watcher.Scan(context.Background())
+
+	for _, name := range []string{"empty.txt", "dead.txt", "container.dlc"} {
+		require.FileExists(t, filepath.Join(watchPath, FailedFolder, name))
+		require.FileExists(t, filepath.Join(watchPath, FailedFolder, name+".error.txt"))
+	}
+
+	report, err := os.ReadFile(filepath.Join(watchPath, FailedFolder, "dead.txt.error.txt"))
+	require.NoError(t, err)
+	require.Contains(t, string(report), "link dead")
+
+	// Unsupported files are left alone
+	require.FileExists(t, filepath.Join(watchPath, "notes.md"))
+}
+
+func TestWatcher_RecentFilesWait(t *testing.T) {
+	watcher, _, _, watchPath, _ := newTestWatcher(t, "")
+
+	path := filepath.Join(watchPath, "list.txt")
+	require.NoError(t, os.WriteFile(path, []byte("https://example.com/file.zip"), 0o644))
+	watcher.Scan(context.Background())
+
+	require.FileExists(t, path)
+}
+
+func TestWatcher_DLC(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("dlcfile")
+		require.NoError(t, err)
+		defer file.Close()
+		require.Equal(t, "links.dlc", header.Filename)
+		_, _ = w.Write([]byte(`<textarea>{"success": {"message": "ok", "links": ["https://example.com/part1.rar"]}}</textarea>`))
+	}))
+	defer server.Close()
+
+	watcher, client, db, watchPath, _ := newTestWatcher(t, server.URL)
+
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/part1.rar").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/part1.rar", Filename: "part1.rar"}, nil)
+
+	drop(t, watchPath, "links.dlc", "encrypted")
+	watcher.Scan(context.Background())
+
+	downloads, err := db.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 1)
+	require.Equal(t, "part1.rar", downloads[0].Filename)
+}
+
+func TestDLCDecrypter_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<textarea>{"form_errors": {"dlcfile": ["Invalid DLC file"]}}</textarea>`))
+	}))
+	defer server.Close()
+
+	_, err := NewDLCDecrypter(server.URL).Decrypt(context.Background(), "bad.dlc", []byte("x"))
+	require.ErrorContains(t, err, "Invalid DLC file")
+}
@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"debrid-downloader/internal/submit"
+	"debrid-downloader/pkg/models"
+)
+
+// apiSubmitRequest is the JSON body accepted by APISubmitDownload
+type apiSubmitRequest struct {
+	URL       string   `json:"url"`
+	URLs      []string `json:"urls"`
+	Directory string   `json:"directory"`
+	Category  string   `json:"category"`
+}
+
+// apiSubmitResponse is returned after a successful submission
+type apiSubmitResponse struct {
+	GroupID   string             `json:"group_id,omitempty"`
+	Downloads []*models.Download `json:"downloads"`
+	Failed    []submit.Failure   `json:"failed,omitempty"`
+}
+
+// APIListDownloads returns downloads as JSON, filtered like the history search or by a saved preset
+func (h *Handlers) APIListDownloads(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+
+	limit := 50
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 500 {
+			writeJSONError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+		limit = parsed
+	}
+
+	offset := 0
+	if value := query.Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			writeJSONError(w, http.StatusBadRequest, "offset must be a non-negative number")
+			return
+		}
+		offset = parsed
+	}
+
+	sortOrder := query.Get("sort")
+	if sortOrder == "" {
+		sortOrder = "desc"
+	}
+
+	// A preset supplies the filters the query doesn't set
+	if value := query.Get("preset"); value != "" {
+		preset, ok := h.ownPreset(value)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, "Preset not found")
+			return
+		}
+		merged, err := withPreset(preset, query)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		query = merged
+	}
+
+	filter, err := h.parseDownloadFilter(query)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	downloads, err := h.db.FilterDownloads(h.downloadOwner(), filter, sortOrder, limit, offset)
+	if err != nil {
+		h.logger.Error("Failed to list downloads", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to list downloads")
+		return
+	}
+
+	if downloads == nil {
+		downloads = []*models.Download{}
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{"downloads": downloads})
+}
+
+// APIGetDownload returns a single download as JSON
+func (h *Handlers) APIGetDownload(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	download, ok := h.apiDownload(w, r)
+	if !ok {
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, download)
+}
+
+// APIPauseDownload pauses a download that is in progress
+func (h *Handlers) APIPauseDownload(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	download, ok := h.apiDownload(w, r)
+	if !ok {
+		return
+	}
+
+	if download.Status != models.StatusDownloading {
+		writeJSONError(w, http.StatusBadRequest, "Download is not in progress")
+		return
+	}
+
+	if err := h.downloadWorker.PauseDownload(download.ID); err != nil {
+		h.logger.Error("Failed to pause download", "download_id", download.ID, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to pause download")
+		return
+	}
+
+	h.writeUpdatedDownload(w, download.ID)
+}
+
+// APIResumeDownload queues a paused download again
+func (h *Handlers) APIResumeDownload(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	download, ok := h.apiDownload(w, r)
+	if !ok {
+		return
+	}
+
+	if download.Status != models.StatusPaused {
+		writeJSONError(w, http.StatusBadRequest, "Download is not paused")
+		return
+	}
+
+	if err := h.downloadWorker.ResumeDownload(download.ID); err != nil {
+		h.logger.Error("Failed to resume download", "download_id", download.ID, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to resume download")
+		return
+	}
+
+	h.writeUpdatedDownload(w, download.ID)
+}
+
+// APIRetryDownload queues a failed download again
+func (h *Handlers) APIRetryDownload(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	download, ok := h.apiDownload(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.retryDownload(download); err != nil {
+		status, message := actionErrorStatus(err, "Failed to retry download")
+		h.logger.Warn("Failed to retry download", "download_id", download.ID, "error", err)
+		writeJSONError(w, status, message)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, download)
+}
+
+// APIDeleteDownload removes a download from the history, cancelling it if it is in
+// progress. Finished files are kept.
+func (h *Handlers) APIDeleteDownload(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	download, ok := h.apiDownload(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.deleteDownload(download); err != nil {
+		h.logger.Error("Failed to delete download", "download_id", download.ID, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to delete download")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// APIListGroups returns the most recent download groups as JSON
+func (h *Handlers) APIListGroups(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 500 {
+			writeJSONError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+		limit = parsed
+	}
+
+	groups, err := h.db.ListDownloadGroups(h.downloadOwner(), limit)
+	if err != nil {
+		h.logger.Error("Failed to list download groups", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to list groups")
+		return
+	}
+
+	if groups == nil {
+		groups = []*models.DownloadGroup{}
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{"groups": groups})
+}
+
+// apiDownload looks up the download named by the request path, writing a JSON error
+// if it doesn't exist or belongs to someone else
+func (h *Handlers) apiDownload(w http.ResponseWriter, r *http.Request) (*models.Download, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid download ID")
+		return nil, false
+	}
+
+	download, err := h.db.GetDownload(id)
+	if err != nil || !h.canAccess(download) {
+		writeJSONError(w, http.StatusNotFound, "Download not found")
+		return nil, false
+	}
+
+	return download, true
+}
+
+// writeUpdatedDownload responds with a download as it is after an action changed it
+func (h *Handlers) writeUpdatedDownload(w http.ResponseWriter, id int64) {
+	download, err := h.db.GetDownload(id)
+	if err != nil {
+		h.logger.Error("Failed to get updated download", "download_id", id, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to get download")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, download)
+}
+
+// APISubmitDownload queues one or more links, accepting the same input as the web form as JSON.
+// When no directory is given, the category's folder or else the suggested directory for
+// the first link is used.
+func (h *Handlers) APISubmitDownload(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req apiSubmitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	urls := req.URLs
+	if req.URL != "" {
+		urls = append([]string{req.URL}, urls...)
+	}
+	if len(urls) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "URL is required")
+		return
+	}
+
+	directory := req.Directory
+	if directory == "" && req.Category != "" {
+		categoryDir, err := h.categoryDirectory(req.Category)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		directory = categoryDir
+	}
+	if directory == "" {
+		directory = h.getDirectorySuggestionsForFilename(r.Context(), urls[0])
+	}
+
+	directory, err := h.resolveDownloadDirectory(directory)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.submitService.Submit(r.Context(), submit.Request{URLs: urls, Directory: directory, OwnerID: h.ownerID(), Category: req.Category})
+	if err != nil {
+		status := http.StatusBadRequest
+		message := err.Error()
+		var submitErr *submit.Error
+		if errors.As(err, &submitErr) {
+			message = submitErr.Message
+			if submitErr.Internal {
+				status = http.StatusInternalServerError
+			}
+		}
+		writeJSONError(w, status, message)
+		return
+	}
+
+	response := apiSubmitResponse{
+		GroupID: result.GroupID,
+		Failed:  result.Failed,
+	}
+	for _, item := range result.Items {
+		response.Downloads = append(response.Downloads, item.Download)
+
+		if err := h.learnDirectory(item.SourceFilename, item.Download.OriginalURL, directory); err != nil {
+			h.logger.Warn("Failed to learn directory", "error", err, "filename", item.SourceFilename, "directory", directory)
+		}
+	}
+
+	h.writeJSON(w, http.StatusCreated, response)
+}
+
+// resolveDownloadDirectory accepts an absolute path inside the downloads folder or a path
+// relative to it, and returns the absolute path
+func (h *Handlers) resolveDownloadDirectory(directory string) (string, error) {
+	if h.folderService.Contains(directory) {
+		directory = strings.TrimPrefix(filepath.Clean(directory), h.folderService.BasePath)
+	}
+
+	fullPath, err := h.folderService.ValidatePath(directory)
+	if err != nil {
+		return "", fmt.Errorf("invalid directory: %s", directory)
+	}
+
+	return fullPath, nil
+}
+
+// writeJSON encodes a value as the JSON response body
+func (h *Handlers) writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		h.logger.Error("Failed to encode JSON response", "error", err)
+	}
+}
+
+// writeJSONError writes a JSON error response
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/alldebrid/mocks"
+	"debrid-downloader/internal/auth"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestHandlers_APISubmitDownload(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	mockClient := mocks.NewMockAllDebridClient(ctrl)
+	worker := downloader.NewWorker(db, "/downloads")
+	handlers := NewHandlers(db, mockClient, "/downloads", worker)
+
+	mockClient.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/file.zip").
+		Return(&alldebrid.UnrestrictResult{
+			UnrestrictedURL: "https://dl.alldebrid.com/file.zip",
+			Filename:        "file.zip",
+			FileSize:        1024,
+		}, nil)
+
+	body := `{"url": "https://example.com/file.zip", "directory": "movies"}`
+	req := httptest.NewRequest("POST", "/api/v1/downloads", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	handlers.APISubmitDownload(w, req)
+
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var response struct {
+		Downloads []*models.Download `json:"downloads"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	require.Len(t, response.Downloads, 1)
+	require.Equal(t, "/downloads/movies", response.Downloads[0].Directory)
+	require.Equal(t, models.StatusPending, response.Downloads[0].Status)
+
+	// A category without a directory downloads into the category's folder
+	category := models.NewCategory("tv")
+	category.Directory = "shows"
+	require.NoError(t, db.CreateCategory(category))
+
+	mockClient.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/show.mkv").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl.alldebrid.com/show.mkv", Filename: "show.mkv"}, nil)
+
+	req = httptest.NewRequest("POST", "/api/v1/downloads", strings.NewReader(`{"url": "https://example.com/show.mkv", "category": "tv"}`))
+	w = httptest.NewRecorder()
+	handlers.APISubmitDownload(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	require.Equal(t, "/downloads/shows", response.Downloads[0].Directory)
+	require.Equal(t, "tv", response.Downloads[0].Category)
+}
+
+func TestHandlers_APISubmitDownloadValidation(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "invalid JSON", body: `{`},
+		{name: "missing URL", body: `{"directory": "/downloads"}`},
+		{name: "directory outside downloads", body: `{"url": "https://example.com/a", "directory": "../etc"}`},
+		{name: "unknown category", body: `{"url": "https://example.com/a", "category": "tv"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/downloads", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			handlers.APISubmitDownload(w, req)
+
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			require.Contains(t, w.Header().Get("Content-Type"), "application/json")
+		})
+	}
+}
+
+func TestHandlers_APIListAndGetDownload(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	download := &models.Download{
+		OriginalURL: "https://example.com/a.zip",
+		Filename:    "a.zip",
+		Directory:   "/downloads",
+		Status:      models.StatusCompleted,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(download))
+
+	req := httptest.NewRequest("GET", "/api/v1/downloads?status=completed", nil)
+	w := httptest.NewRecorder()
+	handlers.APIListDownloads(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Downloads []*models.Download `json:"downloads"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	require.Len(t, list.Downloads, 1)
+
+	req = httptest.NewRequest("GET", "/api/v1/downloads?limit=0", nil)
+	w = httptest.NewRecorder()
+	handlers.APIListDownloads(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest("GET", fmt.Sprintf("/api/v1/downloads/%d", download.ID), nil)
+	req.SetPathValue("id", fmt.Sprint(download.ID))
+	w = httptest.NewRecorder()
+	handlers.APIGetDownload(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"filename":"a.zip"`)
+
+	req = httptest.NewRequest("GET", "/api/v1/downloads/999", nil)
+	req.SetPathValue("id", "999")
+	w = httptest.NewRecorder()
+	handlers.APIGetDownload(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandlers_APIDownloadActions(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	download := &models.Download{
+		OriginalURL:  "https://example.com/a.zip",
+		Filename:     "a.zip",
+		Directory:    "/downloads",
+		Status:       models.StatusFailed,
+		ErrorMessage: "connection reset",
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(download))
+
+	call := func(handler http.HandlerFunc, method, action string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, fmt.Sprintf("/api/v1/downloads/%d%s", download.ID, action), nil)
+		req.SetPathValue("id", fmt.Sprint(download.ID))
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	// Only downloads in the right state can be paused or resumed
+	w := call(handlers.APIPauseDownload, "POST", "/pause")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "Download is not in progress")
+
+	w = call(handlers.APIResumeDownload, "POST", "/resume")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "Download is not paused")
+
+	// Retrying a failed download queues it again
+	w = call(handlers.APIRetryDownload, "POST", "/retry")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"status":"pending"`)
+
+	w = call(handlers.APIRetryDownload, "POST", "/retry")
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "Download is not in failed state")
+
+	// Paused downloads can be resumed
+	download.Status = models.StatusPaused
+	require.NoError(t, db.UpdateDownload(download))
+	w = call(handlers.APIResumeDownload, "POST", "/resume")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"status":"pending"`)
+
+	download.Status = models.StatusCompleted
+	require.NoError(t, db.UpdateDownload(download))
+	w = call(handlers.APIDeleteDownload, "DELETE", "")
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	_, err = db.GetDownload(download.ID)
+	require.Error(t, err)
+
+	w = call(handlers.APIDeleteDownload, "DELETE", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandlers_APIListGroups(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	w := httptest.NewRecorder()
+	handlers.APIListGroups(w, httptest.NewRequest("GET", "/api/v1/groups", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"groups": []}`, w.Body.String())
+
+	require.NoError(t, db.CreateDownloadGroup(&models.DownloadGroup{
+		ID:             "group-1",
+		CreatedAt:      time.Now(),
+		TotalDownloads: 2,
+		Status:         models.GroupStatusDownloading,
+	}))
+
+	w = httptest.NewRecorder()
+	handlers.APIListGroups(w, httptest.NewRequest("GET", "/api/v1/groups?limit=5", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"id":"group-1"`)
+
+	w = httptest.NewRecorder()
+	handlers.APIListGroups(w, httptest.NewRequest("GET", "/api/v1/groups?limit=0", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlers_APITokens(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	form := url.Values{"name": {"cron"}, "scope": {"read"}}
+	req := httptest.NewRequest("POST", "/settings/tokens", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	handlers.CreateAPIToken(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "shown again")
+
+	tokens, err := db.ListAPITokens()
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	require.Equal(t, models.ScopeRead, tokens[0].Scope)
+	// Only the hash of the displayed token is stored
+	require.NotContains(t, w.Body.String(), tokens[0].TokenHash)
+	require.Contains(t, w.Body.String(), tokens[0].Prefix)
+
+	form = url.Values{"name": {"bad"}, "scope": {"root"}}
+	req = httptest.NewRequest("POST", "/settings/tokens", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handlers.CreateAPIToken(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	req = httptest.NewRequest("DELETE", fmt.Sprintf("/settings/tokens/%d", tokens[0].ID), nil)
+	req.SetPathValue("id", fmt.Sprint(tokens[0].ID))
+	w = httptest.NewRecorder()
+	handlers.RevokeAPIToken(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "Revoked")
+
+	revoked, err := db.GetAPITokenByHash(tokens[0].TokenHash)
+	require.NoError(t, err)
+	require.True(t, revoked.Revoked())
+
+	// A token value hashes to what is stored
+	value, _, hash, err := auth.GenerateAPIToken()
+	require.NoError(t, err)
+	require.Equal(t, auth.HashAPIToken(value), hash)
+}
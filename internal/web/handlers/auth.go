@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"debrid-downloader/internal/auth"
+	"debrid-downloader/internal/web/templates"
+)
+
+// AuthHandlers contains the login and logout handlers
+type AuthHandlers struct {
+	auth   *auth.Service
+	logger *slog.Logger
+}
+
+// NewAuthHandlers creates a new auth handlers instance
+func NewAuthHandlers(authService *auth.Service) *AuthHandlers {
+	return &AuthHandlers{
+		auth:   authService,
+		logger: slog.Default(),
+	}
+}
+
+// LoginPage renders the sign-in form
+func (h *AuthHandlers) LoginPage(w http.ResponseWriter, r *http.Request) {
+	next := auth.SafeRedirectTarget(r.URL.Query().Get("next"))
+
+	// Nothing to sign in to, or already signed in
+	if !h.auth.PasswordLoginEnabled() || h.auth.SessionFromRequest(r) != nil {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	h.renderLogin(w, r, http.StatusOK, "", next)
+}
+
+// Login verifies the submitted credentials and starts a session
+func (h *AuthHandlers) Login(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	next := auth.SafeRedirectTarget(r.FormValue("next"))
+
+	if !h.auth.PasswordLoginEnabled() {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	username := r.FormValue("username")
+	user, ok := h.auth.Authenticate(username, r.FormValue("password"))
+	if !ok {
+		h.logger.Warn("Failed login attempt", "username", username, "remote_addr", r.RemoteAddr)
+		h.renderLogin(w, r, http.StatusUnauthorized, "Invalid username or password", next)
+		return
+	}
+
+	session, err := h.auth.CreateSession(user)
+	if err != nil {
+		h.logger.Error("Failed to create session", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.auth.SetSessionCookie(w, session)
+	h.logger.Info("User logged in", "username", username, "remote_addr", r.RemoteAddr)
+
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// Logout ends the current session
+func (h *AuthHandlers) Logout(w http.ResponseWriter, r *http.Request) {
+	if session := auth.SessionFromContext(r.Context()); session != nil {
+		h.auth.DeleteSession(session.ID)
+		h.logger.Info("User logged out", "username", session.Username)
+	}
+
+	h.auth.ClearSessionCookie(w)
+
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", "/login")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// renderLogin writes the login page with the given status code
+func (h *AuthHandlers) renderLogin(w http.ResponseWriter, r *http.Request, status int, errorMessage, next string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+
+	if err := templates.Login(errorMessage, next).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render login template", "error", err)
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"debrid-downloader/internal/auth"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+// bcrypt hash of "secret"
+const testPasswordHash = "$2a$10$8qRMFrDLMPTbxRR1MfVWOeUflFtgOa8IzYPB2AlcLV1acDtgCXgWO"
+
+func newTestAuthHandlers() (*AuthHandlers, *auth.Service) {
+	service := auth.NewService(auth.Options{Username: "admin", PasswordHash: testPasswordHash})
+	return NewAuthHandlers(service), service
+}
+
+func postLoginForm(values url.Values) *http.Request {
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestAuthHandlers_LoginPage(t *testing.T) {
+	handlers, _ := newTestAuthHandlers()
+
+	req := httptest.NewRequest("GET", "/login?next=/settings", nil)
+	w := httptest.NewRecorder()
+
+	handlers.LoginPage(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "Sign in")
+	require.Contains(t, w.Body.String(), `value="/settings"`)
+}
+
+func TestAuthHandlers_LoginPage_AuthDisabled(t *testing.T) {
+	handlers := NewAuthHandlers(auth.NewService(auth.Options{}))
+
+	req := httptest.NewRequest("GET", "/login", nil)
+	w := httptest.NewRecorder()
+
+	handlers.LoginPage(w, req)
+
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	require.Equal(t, "/", w.Header().Get("Location"))
+}
+
+func TestAuthHandlers_Login(t *testing.T) {
+	tests := []struct {
+		name         string
+		form         url.Values
+		wantStatus   int
+		wantLocation string
+		wantCookie   bool
+	}{
+		{
+			name:         "valid credentials",
+			form:         url.Values{"username": {"admin"}, "password": {"secret"}, "next": {"/settings"}},
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "/settings",
+			wantCookie:   true,
+		},
+		{
+			name:         "external next is ignored",
+			form:         url.Values{"username": {"admin"}, "password": {"secret"}, "next": {"//evil.example"}},
+			wantStatus:   http.StatusSeeOther,
+			wantLocation: "/",
+			wantCookie:   true,
+		},
+		{
+			name:       "wrong password",
+			form:       url.Values{"username": {"admin"}, "password": {"nope"}},
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "wrong username",
+			form:       url.Values{"username": {"root"}, "password": {"secret"}},
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers, _ := newTestAuthHandlers()
+			w := httptest.NewRecorder()
+
+			handlers.Login(w, postLoginForm(tt.form))
+
+			require.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantLocation != "" {
+				require.Equal(t, tt.wantLocation, w.Header().Get("Location"))
+			}
+			if tt.wantCookie {
+				require.Contains(t, w.Header().Get("Set-Cookie"), auth.SessionCookieName)
+			} else {
+				require.Contains(t, w.Body.String(), "Invalid username or password")
+			}
+		})
+	}
+}
+
+func TestAuthHandlers_Logout(t *testing.T) {
+	handlers, service := newTestAuthHandlers()
+
+	session, err := service.CreateSession(&models.User{Username: "admin", Role: models.RoleAdmin})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("POST", "/logout", nil)
+	req = req.WithContext(auth.WithSession(req.Context(), session))
+	w := httptest.NewRecorder()
+
+	handlers.Logout(w, req)
+
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	require.Equal(t, "/login", w.Header().Get("Location"))
+	require.Nil(t, service.GetSession(session.ID))
+	require.Contains(t, w.Header().Get("Set-Cookie"), "Max-Age=0")
+}
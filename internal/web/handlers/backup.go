@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"debrid-downloader/internal/auth"
+	"debrid-downloader/internal/backup"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/web/templates"
+)
+
+// maxImportSize limits the size of an uploaded export
+const maxImportSize = 256 << 20
+
+// SetBackupPath sets the directory scheduled backups are written to, shown on the
+// settings page. Empty means scheduled backups are off.
+func (h *Handlers) SetBackupPath(path string) {
+	h.backupPath = path
+}
+
+// APIBackup responds with a snapshot of the database, taken while it stays in use
+func (h *Handlers) APIBackup(w http.ResponseWriter, r *http.Request) {
+	dir, err := os.MkdirTemp("", "debrid-backup-")
+	if err != nil {
+		h.logger.Error("Failed to create backup directory", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to back up database")
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	name := backup.FileName(time.Now())
+	path := filepath.Join(dir, name)
+	if err := h.db.Backup(path); err != nil {
+		h.logger.Error("Failed to back up database", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to back up database")
+		return
+	}
+
+	h.logger.Info("Database backup downloaded", "file", name)
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeFile(w, r, path)
+}
+
+// APIExport responds with downloads, groups, directory mappings and settings as JSON
+func (h *Handlers) APIExport(w http.ResponseWriter, r *http.Request) {
+	export, err := h.db.Export()
+	if err != nil {
+		h.logger.Error("Failed to export data", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to export data")
+		return
+	}
+
+	name := fmt.Sprintf("debrid-export-%s.json", export.ExportedAt.UTC().Format("20060102-150405"))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	h.writeJSON(w, http.StatusOK, export)
+}
+
+// APIImport adds the records from an export. The conflict query parameter chooses what
+// happens to records that already exist: skip (the default), replace or fail.
+func (h *Handlers) APIImport(w http.ResponseWriter, r *http.Request) {
+	mode, err := conflictMode(r.URL.Query().Get("conflict"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var export database.Export
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportSize)).Decode(&export); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid export file")
+		return
+	}
+
+	result, err := h.db.Import(&export, mode, importOwner(r))
+	if err != nil {
+		h.logger.Warn("Import failed", "error", err)
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	h.logger.Info("Data imported", "imported", result.Imported, "skipped", result.Skipped)
+	h.reloadSettings()
+	h.writeJSON(w, http.StatusOK, result)
+}
+
+// ImportData imports an export uploaded on the settings page
+func (h *Handlers) ImportData(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(maxImportSize); err != nil {
+		h.renderBackups(w, r, "", "Choose an export file to import")
+		return
+	}
+
+	mode, err := conflictMode(r.FormValue("conflict"))
+	if err != nil {
+		h.renderBackups(w, r, "", err.Error())
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		h.renderBackups(w, r, "", "Choose an export file to import")
+		return
+	}
+	defer file.Close()
+
+	var export database.Export
+	if err := json.NewDecoder(file).Decode(&export); err != nil {
+		h.renderBackups(w, r, "", "The file is not an export")
+		return
+	}
+
+	result, err := h.db.Import(&export, mode, importOwner(r))
+	if err != nil {
+		h.logger.Warn("Import failed", "error", err)
+		h.renderBackups(w, r, "", "Import failed: "+err.Error())
+		return
+	}
+
+	h.logger.Info("Data imported", "imported", result.Imported, "skipped", result.Skipped)
+	h.reloadSettings()
+	h.renderBackups(w, r, importSummary(result), "")
+}
+
+// importOwner returns the user imported records go to when their owner has no user with
+// the same name here: the user importing them, or nobody without users
+func importOwner(r *http.Request) int64 {
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		return user.ID
+	}
+	return 0
+}
+
+// reloadSettings applies settings that an import may have changed
+func (h *Handlers) reloadSettings() {
+	if h.settings == nil {
+		return
+	}
+	if err := h.settings.Reload(); err != nil {
+		h.logger.Error("Failed to reload settings after import", "error", err)
+	}
+}
+
+// conflictMode parses an import conflict mode, defaulting to skip
+func conflictMode(value string) (database.ConflictMode, error) {
+	if value == "" {
+		return database.ConflictSkip, nil
+	}
+	mode := database.ConflictMode(value)
+	if !mode.Valid() {
+		return "", fmt.Errorf("conflict must be skip, replace or fail")
+	}
+	return mode, nil
+}
+
+// importSummary describes how many records an import added and skipped
+func importSummary(result *database.ImportResult) string {
+	imported, skipped := 0, 0
+	for _, count := range result.Imported {
+		imported += count
+	}
+	for _, count := range result.Skipped {
+		skipped += count
+	}
+	return fmt.Sprintf("Imported %d records, skipped %d that already existed", imported, skipped)
+}
+
+// backupSettings lists the scheduled backups, newest first
+func (h *Handlers) backupSettings() templates.BackupSettings {
+	settings := templates.BackupSettings{Path: h.backupPath}
+	if h.backupPath == "" {
+		return settings
+	}
+
+	backups, err := backup.List(h.backupPath)
+	if err != nil {
+		h.logger.Warn("Failed to list backups", "path", h.backupPath, "error", err)
+	}
+	for _, path := range slices.Backward(backups) {
+		settings.Backups = append(settings.Backups, filepath.Base(path))
+	}
+	return settings
+}
+
+// renderBackups renders the backup section of the settings page
+func (h *Handlers) renderBackups(w http.ResponseWriter, r *http.Request, message, errorMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	if err := templates.BackupSection(h.backupSettings(), message, errorMessage).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render backups", "error", err)
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/backup"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlers_APIBackupAndExport(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.New(filepath.Join(dir, "debrid.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+	require.NoError(t, db.CreateCategory(models.NewCategory("movies")))
+
+	// The backup is a SQLite database file
+	w := httptest.NewRecorder()
+	handlers.APIBackup(w, httptest.NewRequest("GET", "/api/v1/backup", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+	require.True(t, bytes.HasPrefix(w.Body.Bytes(), []byte("SQLite format 3")))
+
+	w = httptest.NewRecorder()
+	handlers.APIExport(w, httptest.NewRequest("GET", "/api/v1/export", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	export := w.Body.String()
+	require.Contains(t, export, `"name":"movies"`)
+
+	// Importing into a fresh database adds the records; importing again skips them
+	target, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer target.Close()
+	importer := NewHandlers(target, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(target, "/downloads"))
+
+	w = httptest.NewRecorder()
+	importer.APIImport(w, httptest.NewRequest("POST", "/api/v1/import", strings.NewReader(export)))
+	require.Equal(t, http.StatusOK, w.Code)
+	var result database.ImportResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	require.Equal(t, 1, result.Imported["categories"])
+
+	w = httptest.NewRecorder()
+	importer.APIImport(w, httptest.NewRequest("POST", "/api/v1/import?conflict=fail", strings.NewReader(export)))
+	require.Equal(t, http.StatusConflict, w.Code)
+
+	w = httptest.NewRecorder()
+	importer.APIImport(w, httptest.NewRequest("POST", "/api/v1/import?conflict=merge", strings.NewReader(export)))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	importer.APIImport(w, httptest.NewRequest("POST", "/api/v1/import", strings.NewReader("not json")))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandlers_ImportData(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	backupDir := t.TempDir()
+	handlers.SetBackupPath(backupDir)
+	require.NoError(t, os.WriteFile(filepath.Join(backupDir, backup.FileName(time.Now())), nil, 0o644))
+
+	upload := func(content string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		require.NoError(t, form.WriteField("conflict", "skip"))
+		part, err := form.CreateFormFile("file", "export.json")
+		require.NoError(t, err)
+		_, err = part.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, form.Close())
+
+		req := httptest.NewRequest("POST", "/settings/import", &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		handlers.ImportData(w, req)
+		return w
+	}
+
+	w := upload(`{"version": 1, "categories": [{"id": 5, "name": "tv", "cleanup_profile": "video"}]}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "Imported 1 records, skipped 0")
+	require.Contains(t, w.Body.String(), "debrid-")
+
+	category, err := db.GetCategoryByName("tv")
+	require.NoError(t, err)
+	require.Equal(t, int64(5), category.ID)
+
+	w = upload(`{"version": 7}`)
+	require.Contains(t, w.Body.String(), "Import failed: unsupported export version: 7")
+
+	w = upload(`nonsense`)
+	require.Contains(t, w.Body.String(), "The file is not an export")
+}
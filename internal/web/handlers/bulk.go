@@ -0,0 +1,447 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/web/templates"
+	"debrid-downloader/pkg/models"
+)
+
+// maxBulkDownloads is the most downloads a bulk action can name
+const maxBulkDownloads = 1000
+
+// bulkVerbs maps the bulk actions to how their results are described
+var bulkVerbs = map[string]string{
+	"retry":    "Retried",
+	"pause":    "Paused",
+	"resume":   "Resumed",
+	"delete":   "Deleted",
+	"move":     "Moved",
+	"priority": "Reprioritised",
+}
+
+// bulkRequest is an action on several downloads, from the bulk actions bar or the API
+type bulkRequest struct {
+	Action      string  `json:"action"` // One of bulkVerbs
+	IDs         []int64 `json:"ids"`
+	AllFailed   bool    `json:"all_failed"`   // Retry every failed download instead of IDs
+	DeleteFiles bool    `json:"delete_files"` // Delete also removes the files of completed downloads
+	Directory   string  `json:"directory"`    // Where move puts the downloads
+	Priority    *int    `json:"priority"`     // Priority set by priority
+}
+
+// bulkResult reports which of the downloads named by a bulk action it changed
+type bulkResult struct {
+	Action  string  `json:"action"`
+	Changed []int64 `json:"changed"`
+	Skipped []int64 `json:"skipped"` // Named downloads that didn't exist or weren't in a state the action applies to
+}
+
+// summary describes the result for the bulk actions bar
+func (r *bulkResult) summary() string {
+	noun := "downloads"
+	if len(r.Changed) == 1 {
+		noun = "download"
+	}
+	message := fmt.Sprintf("%s %d %s", bulkVerbs[r.Action], len(r.Changed), noun)
+	if len(r.Skipped) > 0 {
+		message += fmt.Sprintf(" (%d skipped)", len(r.Skipped))
+	}
+	return message
+}
+
+// newBulkResult returns the result of an action on ids that changed some of them
+func newBulkResult(action string, ids, changed []int64) *bulkResult {
+	result := &bulkResult{Action: action, Changed: changed, Skipped: []int64{}}
+	if result.Changed == nil {
+		result.Changed = []int64{}
+	}
+	for _, id := range ids {
+		if !slices.Contains(changed, id) {
+			result.Skipped = append(result.Skipped, id)
+		}
+	}
+	return result
+}
+
+// fileMove is a file moved by a bulk move, kept so the move can be undone
+type fileMove struct {
+	from, to string
+}
+
+// parseBulkForm reads a bulk action from the bulk actions form. IDs can be repeated or
+// comma-separated.
+func parseBulkForm(values url.Values) (bulkRequest, error) {
+	req := bulkRequest{
+		Action:      values.Get("action"),
+		AllFailed:   formBool(values.Get("all_failed")),
+		DeleteFiles: formBool(values.Get("delete_files")),
+		Directory:   strings.TrimSpace(values.Get("directory")),
+	}
+
+	for _, value := range values["ids"] {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			id, err := strconv.ParseInt(field, 10, 64)
+			if err != nil {
+				return req, fmt.Errorf("invalid download ID: %s", field)
+			}
+			req.IDs = append(req.IDs, id)
+		}
+	}
+
+	if value := strings.TrimSpace(values.Get("priority")); value != "" {
+		priority, err := strconv.Atoi(value)
+		if err != nil {
+			return req, fmt.Errorf("priority must be a whole number")
+		}
+		req.Priority = &priority
+	}
+
+	return req, nil
+}
+
+// formBool reads a checkbox or true/false form value
+func formBool(value string) bool {
+	parsed, _ := strconv.ParseBool(value)
+	return parsed || value == "on"
+}
+
+// bulkAction applies a bulk action to the downloads it names that the current user may
+// change. Each action changes the database in a single transaction.
+func (h *Handlers) bulkAction(req bulkRequest) (*bulkResult, error) {
+	if _, ok := bulkVerbs[req.Action]; !ok {
+		return nil, &actionError{message: "Unknown bulk action"}
+	}
+
+	owner := h.downloadOwner()
+	if req.Action == "retry" && req.AllFailed {
+		return h.retryAllFailed(owner)
+	}
+
+	ids := req.IDs
+	if len(ids) == 0 {
+		return nil, &actionError{message: "No downloads selected"}
+	}
+	if len(ids) > maxBulkDownloads {
+		return nil, &actionError{message: fmt.Sprintf("At most %d downloads can be changed at once", maxBulkDownloads)}
+	}
+
+	var changed []int64
+	var err error
+	now := time.Now()
+
+	switch req.Action {
+	case "retry":
+		if changed, err = h.db.RetryDownloads(owner, ids, now); err == nil {
+			for _, id := range changed {
+				h.downloadWorker.QueueDownload(id)
+			}
+		}
+	case "pause":
+		changed, err = h.downloadWorker.PauseDownloads(owner, ids)
+	case "resume":
+		if changed, err = h.db.ResumeDownloads(owner, ids, now); err == nil {
+			for _, id := range changed {
+				h.downloadWorker.QueueDownload(id)
+			}
+		}
+	case "delete":
+		changed, err = h.bulkDelete(owner, ids, req.DeleteFiles)
+	case "move":
+		changed, err = h.bulkMove(owner, ids, req.Directory)
+	case "priority":
+		if req.Priority == nil {
+			return nil, &actionError{message: "Priority is required"}
+		}
+		if changed, err = h.db.SetDownloadsPriority(owner, ids, *req.Priority, now); err == nil {
+			h.downloadWorker.SetPriority(changed, *req.Priority)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := newBulkResult(req.Action, ids, changed)
+	h.logger.Info("Bulk action applied", "action", req.Action, "changed", len(result.Changed), "skipped", len(result.Skipped))
+	return result, nil
+}
+
+// retryAllFailed retries every failed download of the owner. It isn't held to
+// maxBulkDownloads since the downloads aren't named one by one.
+func (h *Handlers) retryAllFailed(ownerID int64) (*bulkResult, error) {
+	changed, err := h.db.RetryAllFailed(ownerID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range changed {
+		h.downloadWorker.QueueDownload(id)
+	}
+
+	result := newBulkResult("retry", changed, changed)
+	h.logger.Info("Bulk action applied", "action", "retry", "all_failed", true, "changed", len(result.Changed), "skipped", len(result.Skipped))
+	return result, nil
+}
+
+// bulkDelete removes downloads from the history in one transaction, see deleteDownloads
+func (h *Handlers) bulkDelete(ownerID int64, ids []int64, deleteFiles bool) ([]int64, error) {
+	downloads, err := h.db.GetDownloadsByIDs(ownerID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.deleteDownloads(downloads, deleteFiles, func(ids []int64) error {
+		_, err := h.db.DeleteDownloads(ids)
+		return err
+	})
+}
+
+// deleteDownloads cancels the downloads in progress, removes the records with remove and
+// then their temporary files. With deleteFiles the files of completed downloads,
+// extracted ones included, are removed too.
+func (h *Handlers) deleteDownloads(downloads []*models.Download, deleteFiles bool, remove func(ids []int64) error) ([]int64, error) {
+	deleted := []int64{}
+	files := make(map[int64][]string)
+	wasActive, canceled := false, false
+	for _, download := range downloads {
+		deleted = append(deleted, download.ID)
+
+		switch download.Status {
+		case models.StatusDownloading:
+			if h.downloadWorker.CancelCurrentDownloadIfMatches(download.ID) {
+				canceled = true
+			}
+			wasActive = true
+		case models.StatusPending, models.StatusPaused:
+			wasActive = true
+		case models.StatusCompleted:
+			if deleteFiles {
+				files[download.ID] = h.downloadFiles(download)
+			}
+		}
+	}
+
+	// Give the worker a moment to process the cancellations
+	if canceled {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if err := remove(deleted); err != nil {
+		return nil, err
+	}
+
+	for _, download := range downloads {
+		tempPath := filepath.Join(download.Directory, fmt.Sprintf("%s.%d.tmp", download.Filename, download.ID))
+		for _, path := range append(files[download.ID], tempPath) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				h.logger.Warn("Failed to remove file of deleted download", "download_id", download.ID, "path", path, "error", err)
+			}
+		}
+	}
+
+	if wasActive {
+		h.queueNextPendingDownload()
+	}
+
+	return deleted, nil
+}
+
+// downloadFiles returns the paths of a download's file and the files extracted from it
+// that haven't been deleted
+func (h *Handlers) downloadFiles(download *models.Download) []string {
+	paths := []string{filepath.Join(download.Directory, download.Filename)}
+
+	extracted, err := h.db.GetExtractedFilesByDownloadID(download.ID)
+	if err != nil {
+		h.logger.Warn("Failed to get extracted files", "download_id", download.ID, "error", err)
+		return paths
+	}
+	for _, file := range extracted {
+		if file.DeletedAt == nil {
+			paths = append(paths, file.FilePath)
+		}
+	}
+	return paths
+}
+
+// bulkMove moves downloads that aren't in progress, with their files, to another folder
+// inside the downloads folder. Downloads whose files can't be moved are skipped; if the
+// database can't be updated the files are moved back.
+func (h *Handlers) bulkMove(ownerID int64, ids []int64, directory string) ([]int64, error) {
+	if directory == "" {
+		return nil, &actionError{message: "Directory is required"}
+	}
+	target, err := h.resolveDownloadDirectory(directory)
+	if err != nil {
+		return nil, &actionError{message: err.Error()}
+	}
+
+	downloads, err := h.db.GetDownloadsByIDs(ownerID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var active []int64
+	for _, download := range h.downloadWorker.ActiveDownloads() {
+		active = append(active, download.ID)
+	}
+
+	var moved []*models.Download
+	var moves []fileMove
+	for _, download := range downloads {
+		if download.Status == models.StatusDownloading || slices.Contains(active, download.ID) ||
+			filepath.Clean(download.Directory) == target {
+			continue
+		}
+
+		downloadMoves, err := h.moveDownloadFiles(download, target)
+		if err != nil {
+			h.logger.Warn("Failed to move download files", "download_id", download.ID, "error", err)
+			continue
+		}
+		moves = append(moves, downloadMoves...)
+		moved = append(moved, download)
+	}
+
+	if len(moved) == 0 {
+		return nil, nil
+	}
+
+	if err := h.db.MoveDownloads(moved, target, time.Now()); err != nil {
+		h.undoMoves(moves)
+		return nil, err
+	}
+
+	changed := make([]int64, len(moved))
+	for i, download := range moved {
+		changed[i] = download.ID
+	}
+	return changed, nil
+}
+
+// moveDownloadFiles moves a download's file, temporary file and the files extracted
+// inside its folder to directory, keeping their paths below the folder. Existing files
+// are never replaced; if one file can't be moved, the ones already moved are put back.
+func (h *Handlers) moveDownloadFiles(download *models.Download, directory string) ([]fileMove, error) {
+	paths := append(h.downloadFiles(download),
+		filepath.Join(download.Directory, fmt.Sprintf("%s.%d.tmp", download.Filename, download.ID)))
+
+	var moves []fileMove
+	for _, path := range paths {
+		relative, err := filepath.Rel(download.Directory, path)
+		if err != nil || relative == "." || strings.HasPrefix(relative, "..") {
+			continue
+		}
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+
+		target := filepath.Join(directory, relative)
+		if _, err := os.Lstat(target); err == nil {
+			h.undoMoves(moves)
+			return nil, fmt.Errorf("%s already exists", target)
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			h.undoMoves(moves)
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := os.Rename(path, target); err != nil {
+			h.undoMoves(moves)
+			return nil, fmt.Errorf("failed to move file: %w", err)
+		}
+		moves = append(moves, fileMove{from: path, to: target})
+	}
+
+	return moves, nil
+}
+
+// undoMoves puts moved files back where they were, last first
+func (h *Handlers) undoMoves(moves []fileMove) {
+	for i := len(moves) - 1; i >= 0; i-- {
+		if err := os.Rename(moves[i].to, moves[i].from); err != nil {
+			h.logger.Error("Failed to move file back", "from", moves[i].to, "to", moves[i].from, "error", err)
+		}
+	}
+}
+
+// BulkDownloads applies an action from the bulk actions bar to the selected downloads
+// and asks the history to refresh
+func (h *Handlers) BulkDownloads(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.renderBulkResult(w, r, false, "Invalid form data")
+		return
+	}
+
+	req, err := parseBulkForm(r.Form)
+	if err != nil {
+		h.renderBulkResult(w, r, false, err.Error())
+		return
+	}
+
+	result, err := h.bulkAction(req)
+	if err != nil {
+		status, message := actionErrorStatus(err, "Failed to update downloads")
+		if status == http.StatusInternalServerError {
+			h.logger.Error("Failed to apply bulk action", "action", req.Action, "error", err)
+			http.Error(w, message, status)
+			return
+		}
+		h.renderBulkResult(w, r, false, message)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", "downloadsChanged")
+	h.renderBulkResult(w, r, true, result.summary())
+}
+
+// renderBulkResult renders the message shown under the bulk actions bar
+func (h *Handlers) renderBulkResult(w http.ResponseWriter, r *http.Request, success bool, message string) {
+	if err := templates.DownloadResult(success, message).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render bulk action result", "error", err)
+	}
+}
+
+// APIBulkDownloads applies a bulk action to downloads, see bulkRequest, and returns
+// which of them it changed
+func (h *Handlers) APIBulkDownloads(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req bulkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	result, err := h.bulkAction(req)
+	if err != nil {
+		status, message := actionErrorStatus(err, "Failed to update downloads")
+		if status == http.StatusInternalServerError {
+			h.logger.Error("Failed to apply bulk action", "action", req.Action, "error", err)
+		}
+		writeJSONError(w, status, message)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBulkForm(t *testing.T) {
+	req, err := parseBulkForm(url.Values{
+		"action":       {"priority"},
+		"ids":          {"1,2", "3"},
+		"priority":     {"-2"},
+		"delete_files": {"on"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "priority", req.Action)
+	require.Equal(t, []int64{1, 2, 3}, req.IDs)
+	require.Equal(t, -2, *req.Priority)
+	require.True(t, req.DeleteFiles)
+
+	_, err = parseBulkForm(url.Values{"ids": {"1,x"}})
+	require.EqualError(t, err, "invalid download ID: x")
+
+	_, err = parseBulkForm(url.Values{"priority": {"high"}})
+	require.Error(t, err)
+}
+
+func TestHandlers_BulkDownloads(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	base := t.TempDir()
+	incoming := filepath.Join(base, "incoming")
+	require.NoError(t, os.MkdirAll(incoming, 0755))
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), base, downloader.NewWorker(db, base))
+
+	create := func(name string, status models.DownloadStatus) *models.Download {
+		download := &models.Download{
+			OriginalURL: "https://example.com/" + name,
+			Filename:    name,
+			Directory:   incoming,
+			Status:      status,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		require.NoError(t, db.CreateDownload(download))
+		return download
+	}
+	completed := create("film.mkv", models.StatusCompleted)
+	other := create("show.mkv", models.StatusCompleted)
+	failed := create("failed.mkv", models.StatusFailed)
+	paused := create("paused.mkv", models.StatusPaused)
+	for _, name := range []string{"film.mkv", "show.mkv"} {
+		require.NoError(t, os.WriteFile(filepath.Join(incoming, name), []byte(name), 0644))
+	}
+
+	api := func(body string) (int, bulkResult) {
+		w := httptest.NewRecorder()
+		handlers.APIBulkDownloads(w, httptest.NewRequest("POST", "/api/v1/downloads/bulk", strings.NewReader(body)))
+		var result bulkResult
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		}
+		return w.Code, result
+	}
+
+	code, _ := api(`{"action": "explode", "ids": [1]}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = api(`{"action": "pause"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// Retrying all failed downloads needs no IDs, goes past the bulk limit in one statement and
+	// starts over for downloads that ran out of automatic retries
+	failed.RetryCount = 6
+	require.NoError(t, db.UpdateDownload(failed))
+	for i := range maxBulkDownloads {
+		create(fmt.Sprintf("failed-%d.mkv", i), models.StatusFailed)
+	}
+	code, result := api(`{"action": "retry", "all_failed": true}`)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, result.Changed, maxBulkDownloads+1)
+	require.Contains(t, result.Changed, failed.ID)
+	retried, err := db.GetDownload(failed.ID)
+	require.NoError(t, err)
+	require.Zero(t, retried.RetryCount)
+
+	code, _ = api(fmt.Sprintf(`{"action": "pause", "ids": [%s]}`, strings.TrimSuffix(strings.Repeat("1,", maxBulkDownloads+1), ",")))
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, result = api(fmt.Sprintf(`{"action": "resume", "ids": [%d, %d]}`, paused.ID, completed.ID))
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []int64{paused.ID}, result.Changed)
+	require.Equal(t, []int64{completed.ID}, result.Skipped)
+
+	code, result = api(fmt.Sprintf(`{"action": "priority", "ids": [%d, %d], "priority": 3}`, paused.ID, failed.ID))
+	require.Equal(t, http.StatusOK, code)
+	require.ElementsMatch(t, []int64{paused.ID, failed.ID}, result.Changed)
+
+	// Moving takes the files along and refuses folders outside the downloads folder
+	code, _ = api(fmt.Sprintf(`{"action": "move", "ids": [%d], "directory": "../outside"}`, completed.ID))
+	require.Equal(t, http.StatusBadRequest, code)
+
+	code, result = api(fmt.Sprintf(`{"action": "move", "ids": [%d, %d], "directory": "movies"}`, completed.ID, other.ID))
+	require.Equal(t, http.StatusOK, code)
+	require.ElementsMatch(t, []int64{completed.ID, other.ID}, result.Changed)
+	require.FileExists(t, filepath.Join(base, "movies", "film.mkv"))
+	require.NoFileExists(t, filepath.Join(incoming, "film.mkv"))
+
+	download, err := db.GetDownload(completed.ID)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(base, "movies"), download.Directory)
+
+	// The bulk actions bar deletes with the files and asks the history to refresh
+	form := url.Values{"action": {"delete"}, "ids": {fmt.Sprintf("%d", completed.ID)}, "delete_files": {"true"}}
+	req := httptest.NewRequest("POST", "/downloads/bulk", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handlers.BulkDownloads(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "downloadsChanged", w.Header().Get("HX-Trigger"))
+	require.Contains(t, w.Body.String(), "Deleted 1 download")
+	require.NoFileExists(t, filepath.Join(base, "movies", "film.mkv"))
+	require.FileExists(t, filepath.Join(base, "movies", "show.mkv"))
+
+	_, err = db.GetDownload(completed.ID)
+	require.Error(t, err)
+
+	// Problems with the request are shown in the bar
+	req = httptest.NewRequest("POST", "/downloads/bulk", strings.NewReader("action=pause"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	handlers.BulkDownloads(w, req)
+	require.Contains(t, w.Body.String(), "No downloads selected")
+	require.Empty(t, w.Header().Get("HX-Trigger"))
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"debrid-downloader/internal/release"
+	"debrid-downloader/internal/web/templates"
+	"debrid-downloader/pkg/models"
+)
+
+// CreateCategory adds a download category
+func (h *Handlers) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	switch {
+	case name == "":
+		h.renderCategories(w, r, "Enter a name")
+		return
+	case strings.ContainsAny(name, `/\`):
+		h.renderCategories(w, r, "Category names can't contain slashes")
+		return
+	}
+
+	if _, err := h.db.GetCategoryByName(name); err == nil {
+		h.renderCategories(w, r, "A category with that name already exists")
+		return
+	}
+
+	category := models.NewCategory(name)
+	if message := h.categoryFromForm(r, category); message != "" {
+		h.renderCategories(w, r, message)
+		return
+	}
+
+	if err := h.db.CreateCategory(category); err != nil {
+		h.logger.Error("Failed to create category", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Category created", "category_id", category.ID, "category", name, "directory", category.DirectoryName())
+	h.renderCategories(w, r, "")
+}
+
+// CreateDefaultCategories adds the starter categories that don't exist yet
+func (h *Handlers) CreateDefaultCategories(w http.ResponseWriter, r *http.Request) {
+	for _, category := range models.DefaultCategories() {
+		if _, err := h.db.GetCategoryByName(category.Name); err == nil {
+			continue
+		}
+		if err := h.db.CreateCategory(category); err != nil {
+			h.logger.Error("Failed to create category", "error", err, "category", category.Name)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.logger.Info("Default categories created")
+	h.renderCategories(w, r, "")
+}
+
+// UpdateCategory changes a category's folder and post-processing settings
+func (h *Handlers) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	category, err := h.db.GetCategory(id)
+	if err != nil {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
+
+	if message := h.categoryFromForm(r, category); message != "" {
+		h.renderCategories(w, r, message)
+		return
+	}
+
+	if err := h.db.UpdateCategory(category); err != nil {
+		h.logger.Error("Failed to update category", "error", err, "category_id", id)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Category updated", "category_id", id, "category", category.Name, "directory", category.DirectoryName())
+	h.renderCategories(w, r, "")
+}
+
+// DeleteCategory removes a category. Its downloads and folder are kept.
+func (h *Handlers) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid category ID", http.StatusBadRequest)
+		return
+	}
+
+	category, err := h.db.GetCategory(id)
+	if err != nil {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.db.DeleteCategory(category.Name); err != nil {
+		h.logger.Error("Failed to delete category", "error", err, "category_id", id)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Category deleted", "category_id", id, "category", category.Name)
+	h.renderCategories(w, r, "")
+}
+
+// categoryFromForm copies the submitted settings onto a category, returning a message
+// describing the first invalid field
+func (h *Handlers) categoryFromForm(r *http.Request, category *models.Category) string {
+	directory := strings.Trim(strings.TrimSpace(r.FormValue("directory")), "/")
+	profile := models.CleanupProfile(r.FormValue("cleanup_profile"))
+
+	priority := 0
+	if value := strings.TrimSpace(r.FormValue("priority")); value != "" {
+		var err error
+		if priority, err = strconv.Atoi(value); err != nil {
+			return "The priority must be a whole number"
+		}
+	}
+
+	// No hook, or an unparseable one, leaves the category on folder matching
+	hookID, _ := strconv.ParseInt(r.FormValue("hook_id"), 10, 64)
+	if hookID != 0 {
+		if _, err := h.db.GetHook(hookID); err != nil {
+			return "Unknown hook"
+		}
+	}
+
+	if !profile.Valid() {
+		return "Choose what to keep after extraction"
+	}
+
+	renameTemplate := strings.TrimSpace(r.FormValue("rename_template"))
+	if renameTemplate != "" {
+		if err := release.Validate(renameTemplate); err != nil {
+			return fmt.Sprintf("Invalid rename template: %s", err)
+		}
+	}
+
+	dirName := directory
+	if dirName == "" {
+		dirName = category.Name
+	}
+	if _, err := h.folderService.ValidatePath(dirName); err != nil {
+		return "The folder must be inside the downloads directory"
+	}
+
+	category.Directory = directory
+	category.Keywords = strings.TrimSpace(r.FormValue("keywords"))
+	category.Extract = r.FormValue("extract") != ""
+	category.CleanupProfile = profile
+	category.Priority = priority
+	category.HookID = hookID
+	category.RenameTemplate = renameTemplate
+	return ""
+}
+
+// categoryDirectory returns the full path of a category's folder
+func (h *Handlers) categoryDirectory(name string) (string, error) {
+	category, err := h.db.GetCategoryByName(name)
+	if err != nil {
+		return "", fmt.Errorf("unknown category: %s", name)
+	}
+	return h.folderService.ValidatePath(category.DirectoryName())
+}
+
+// categoryOptions lists the categories offered on the download form, with their folders
+// inside the user's downloads folder
+func (h *Handlers) categoryOptions() []templates.CategoryOption {
+	categories, err := h.db.ListCategories()
+	if err != nil {
+		h.logger.Error("Failed to list categories", "error", err)
+		return nil
+	}
+
+	options := make([]templates.CategoryOption, 0, len(categories))
+	for _, category := range categories {
+		directory, err := h.folderService.ValidatePath(category.DirectoryName())
+		if err != nil {
+			continue
+		}
+		options = append(options, templates.CategoryOption{Name: category.Name, Directory: directory})
+	}
+	return options
+}
+
+// categorySettings loads the categories and the hooks they can use
+func (h *Handlers) categorySettings() (templates.CategorySettings, error) {
+	var (
+		settings templates.CategorySettings
+		err      error
+	)
+	if settings.Categories, err = h.db.ListCategories(); err != nil {
+		return settings, err
+	}
+	if settings.Hooks, err = h.db.ListHooks(); err != nil {
+		return settings, err
+	}
+	return settings, nil
+}
+
+// renderCategories renders the categories section of the settings page
+func (h *Handlers) renderCategories(w http.ResponseWriter, r *http.Request, errorMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	settings, err := h.categorySettings()
+	if err != nil {
+		h.logger.Error("Failed to list categories", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := templates.CategoriesSection(settings, errorMessage).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render categories", "error", err)
+	}
+}
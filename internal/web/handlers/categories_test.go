@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlers_Categories(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	basePath := t.TempDir()
+	handlers := NewHandlers(db, alldebrid.New("test-key"), basePath, downloader.NewWorker(db, basePath))
+
+	admin := &models.User{Username: "admin", Role: models.RoleAdmin, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(admin))
+	hook := &models.Hook{Name: "Import", Script: "import.sh", CreatedAt: time.Now()}
+	require.NoError(t, db.CreateHook(hook))
+
+	form := url.Values{
+		"name":            {"Anime"},
+		"directory":       {"/tv/anime/"},
+		"keywords":        {"anime, .ass"},
+		"cleanup_profile": {"subtitles"},
+		"priority":        {"3"},
+		"hook_id":         {fmt.Sprint(hook.ID)},
+		"extract":         {"on"},
+		"rename_template": {"{show}/Season {season:02}/{show} - {episode:02}.{ext}"},
+	}
+	w := httptest.NewRecorder()
+	handlers.CreateCategory(w, requestAs(admin, "POST", "/settings/categories", form.Encode()))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "Anime")
+
+	category, err := db.GetCategoryByName("Anime")
+	require.NoError(t, err)
+	require.Equal(t, "tv/anime", category.Directory)
+	require.Equal(t, []string{"anime", ".ass"}, category.KeywordList())
+	require.Equal(t, models.CleanupSubtitles, category.CleanupProfile)
+	require.Equal(t, 3, category.Priority)
+	require.Equal(t, hook.ID, category.HookID)
+	require.True(t, category.Extract)
+	require.Equal(t, "{show}/Season {season:02}/{show} - {episode:02}.{ext}", category.RenameTemplate)
+
+	// Invalid input is reported in the section
+	for message, values := range map[string]url.Values{
+		"Enter a name":                   {"cleanup_profile": {"video"}},
+		"contain slashes":                {"name": {"a/b"}, "cleanup_profile": {"video"}},
+		"already exists":                 {"name": {"Anime"}, "cleanup_profile": {"video"}},
+		"inside the downloads directory": {"name": {"x"}, "directory": {"../etc"}, "cleanup_profile": {"video"}},
+		"Choose what to keep":            {"name": {"x"}, "cleanup_profile": {"everything"}},
+		"whole number":                   {"name": {"x"}, "cleanup_profile": {"video"}, "priority": {"high"}},
+		"Unknown hook":                   {"name": {"x"}, "cleanup_profile": {"video"}, "hook_id": {"99"}},
+		"unknown placeholder":            {"name": {"x"}, "cleanup_profile": {"video"}, "rename_template": {"{name}.{ext}"}},
+	} {
+		w = httptest.NewRecorder()
+		handlers.CreateCategory(w, requestAs(admin, "POST", "/settings/categories", values.Encode()))
+		require.Contains(t, w.Body.String(), message)
+	}
+
+	// Unchecking extraction and clearing the hook is saved
+	update := url.Values{"directory": {"anime"}, "cleanup_profile": {"none"}, "hook_id": {"0"}}
+	req := requestAs(admin, "POST", fmt.Sprintf("/settings/categories/%d", category.ID), update.Encode())
+	req.SetPathValue("id", fmt.Sprint(category.ID))
+	w = httptest.NewRecorder()
+	handlers.UpdateCategory(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	category, err = db.GetCategory(category.ID)
+	require.NoError(t, err)
+	require.Equal(t, "anime", category.Directory)
+	require.False(t, category.Extract)
+	require.Equal(t, models.CleanupNone, category.CleanupProfile)
+	require.Zero(t, category.HookID)
+
+	// The settings page and download form offer the category
+	w = httptest.NewRecorder()
+	handlers.Settings(w, requestAs(admin, "GET", "/settings", ""))
+	require.Contains(t, w.Body.String(), "Add Category")
+
+	w = httptest.NewRecorder()
+	handlers.Home(w, requestAs(admin, "GET", "/", ""))
+	require.Contains(t, w.Body.String(), `data-directory="`+basePath+`/anime"`)
+
+	req = requestAs(admin, "DELETE", fmt.Sprintf("/settings/categories/%d", category.ID), "")
+	req.SetPathValue("id", fmt.Sprint(category.ID))
+	w = httptest.NewRecorder()
+	handlers.DeleteCategory(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "No categories yet")
+
+	// The defaults are only added once
+	for range 2 {
+		w = httptest.NewRecorder()
+		handlers.CreateDefaultCategories(w, requestAs(admin, "POST", "/settings/categories/defaults", ""))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	categories, err := db.ListCategories()
+	require.NoError(t, err)
+	require.Len(t, categories, len(models.DefaultCategories()))
+}
@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/rules"
+	"debrid-downloader/internal/web/templates"
+	"debrid-downloader/pkg/models"
+)
+
+// filterParams lists the form and query parameters that make up a history filter
+var filterParams = []string{
+	"search", "status", "created_from", "created_to", "completed_from", "completed_to",
+	"min_size", "max_size", "host", "dir", "group", "archives",
+}
+
+// parseDownloadFilter reads the history filters from form or query values. Dates are
+// YYYY-MM-DD, with the to dates inclusive, or RFC 3339 times; sizes are like 700MB; the
+// folder is relative to the downloads folder.
+func (h *Handlers) parseDownloadFilter(values url.Values) (database.DownloadFilter, error) {
+	filter := database.DownloadFilter{
+		Search:   values.Get("search"),
+		Statuses: values["status"],
+		Host:     strings.TrimSpace(values.Get("host")),
+		GroupID:  strings.TrimSpace(values.Get("group")),
+	}
+
+	if dir := strings.TrimSpace(values.Get("dir")); dir != "" {
+		directory, err := h.filterDirectory(dir)
+		if err != nil {
+			return filter, fmt.Errorf("dir must be a folder inside the downloads directory")
+		}
+		filter.Directory = directory
+	}
+
+	dates := []struct {
+		name  string
+		value *time.Time
+		end   bool
+	}{
+		{"created_from", &filter.CreatedFrom, false},
+		{"created_to", &filter.CreatedTo, true},
+		{"completed_from", &filter.CompletedFrom, false},
+		{"completed_to", &filter.CompletedTo, true},
+	}
+	for _, date := range dates {
+		value := strings.TrimSpace(values.Get(date.name))
+		if value == "" {
+			continue
+		}
+		parsed, err := parseFilterDate(value, date.end)
+		if err != nil {
+			return filter, fmt.Errorf("%s must be a date such as 2024-01-31", date.name)
+		}
+		*date.value = parsed
+	}
+
+	sizes := []struct {
+		name  string
+		value *int64
+	}{
+		{"min_size", &filter.MinSize},
+		{"max_size", &filter.MaxSize},
+	}
+	for _, size := range sizes {
+		value := strings.TrimSpace(values.Get(size.name))
+		if value == "" {
+			continue
+		}
+		parsed, err := rules.ParseSize(value)
+		if err != nil {
+			return filter, fmt.Errorf("%s must be a size such as 700MB", size.name)
+		}
+		*size.value = parsed
+	}
+
+	if value := strings.TrimSpace(values.Get("archives")); value != "" {
+		archives, err := strconv.ParseBool(value)
+		if value == "on" {
+			archives, err = true, nil
+		}
+		if err != nil {
+			return filter, fmt.Errorf("archives must be true or false")
+		}
+		filter.ArchivesOnly = archives
+	}
+
+	return filter, nil
+}
+
+// filterDirectory resolves the folder filter against the downloads folder. A full path
+// inside it is kept as it is, so filters saved with one still work.
+func (h *Handlers) filterDirectory(dir string) (string, error) {
+	if filepath.IsAbs(dir) && h.folderService.Contains(dir) {
+		return filepath.Clean(dir), nil
+	}
+	return h.folderService.ValidatePath(dir)
+}
+
+// parseFilterDate reads a YYYY-MM-DD date in local time or an RFC 3339 time. A date
+// ending a range returns the start of the next day so the range includes it.
+func parseFilterDate(value string, end bool) (time.Time, error) {
+	if date, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		if end {
+			date = date.AddDate(0, 0, 1)
+		}
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// filterQuery encodes the history filters in values as a preset query, dropping empty
+// values and anything else
+func filterQuery(values url.Values) string {
+	query := url.Values{}
+	for _, name := range filterParams {
+		for _, value := range values[name] {
+			if value = strings.TrimSpace(value); value != "" {
+				query.Add(name, value)
+			}
+		}
+	}
+	return query.Encode()
+}
+
+// withPreset returns values on top of the filters saved in a preset
+func withPreset(preset *models.FilterPreset, values url.Values) (url.Values, error) {
+	merged, err := url.ParseQuery(preset.Query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid preset query: %w", err)
+	}
+	for _, name := range filterParams {
+		if _, ok := values[name]; ok {
+			merged[name] = values[name]
+		}
+	}
+	return merged, nil
+}
+
+// ownPreset returns the signed-in user's filter preset with the given ID
+func (h *Handlers) ownPreset(value string) (*models.FilterPreset, bool) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	preset, err := h.db.GetFilterPreset(id)
+	if err != nil || preset.OwnerID != h.ownerID() {
+		return nil, false
+	}
+	return preset, true
+}
+
+// newPreset checks the filters in values and returns them as a preset of the signed-in user
+func (h *Handlers) newPreset(name string, values url.Values) (*models.FilterPreset, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("preset name is required")
+	}
+	if _, err := h.parseDownloadFilter(values); err != nil {
+		return nil, err
+	}
+
+	return &models.FilterPreset{
+		Name:      name,
+		Query:     filterQuery(values),
+		OwnerID:   h.ownerID(),
+		CreatedAt: time.Now(),
+	}, nil
+}
+
+// FilterPresets renders the signed-in user's filter presets
+func (h *Handlers) FilterPresets(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	h.renderFilterPresets(w, r, "")
+}
+
+// SaveFilterPreset saves the search form's filters as a preset
+func (h *Handlers) SaveFilterPreset(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.renderFilterPresets(w, r, "Invalid form data")
+		return
+	}
+
+	preset, err := h.newPreset(r.FormValue("preset_name"), r.Form)
+	if err != nil {
+		h.renderFilterPresets(w, r, err.Error())
+		return
+	}
+
+	if err := h.db.SaveFilterPreset(preset); err != nil {
+		h.logger.Error("Failed to save filter preset", "error", err)
+		h.renderFilterPresets(w, r, "Failed to save preset")
+		return
+	}
+
+	h.logger.Info("Filter preset saved", "preset_id", preset.ID, "name", preset.Name)
+	h.renderFilterPresets(w, r, "")
+}
+
+// DeleteFilterPreset removes one of the signed-in user's filter presets
+func (h *Handlers) DeleteFilterPreset(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	preset, ok := h.ownPreset(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Preset not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.db.DeleteFilterPreset(preset.ID); err != nil {
+		h.logger.Error("Failed to delete filter preset", "error", err, "preset_id", preset.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Filter preset deleted", "preset_id", preset.ID)
+	h.renderFilterPresets(w, r, "")
+}
+
+// renderFilterPresets renders the filter presets of the history filters
+func (h *Handlers) renderFilterPresets(w http.ResponseWriter, r *http.Request, errorMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	presets, err := h.db.ListFilterPresets(h.ownerID())
+	if err != nil {
+		h.logger.Error("Failed to list filter presets", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := templates.FilterPresets(presets, errorMessage).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render filter presets", "error", err)
+	}
+}
+
+// apiPresetRequest is the JSON body accepted by APICreatePreset
+type apiPresetRequest struct {
+	Name  string `json:"name"`
+	Query string `json:"query"`
+}
+
+// APIListPresets returns the caller's filter presets as JSON
+func (h *Handlers) APIListPresets(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	presets, err := h.db.ListFilterPresets(h.ownerID())
+	if err != nil {
+		h.logger.Error("Failed to list filter presets", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to list presets")
+		return
+	}
+
+	if presets == nil {
+		presets = []*models.FilterPreset{}
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{"presets": presets})
+}
+
+// APICreatePreset saves a filter preset, replacing the caller's preset with the same name
+func (h *Handlers) APICreatePreset(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var req apiPresetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	values, err := url.ParseQuery(strings.TrimPrefix(req.Query, "?"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "query must be URL query parameters")
+		return
+	}
+
+	preset, err := h.newPreset(req.Name, values)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.db.SaveFilterPreset(preset); err != nil {
+		h.logger.Error("Failed to save filter preset", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to save preset")
+		return
+	}
+
+	h.logger.Info("Filter preset saved", "preset_id", preset.ID, "name", preset.Name)
+
+	h.writeJSON(w, http.StatusCreated, preset)
+}
+
+// APIDeletePreset removes one of the caller's filter presets
+func (h *Handlers) APIDeletePreset(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	preset, ok := h.ownPreset(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Preset not found")
+		return
+	}
+
+	if err := h.db.DeleteFilterPreset(preset.ID); err != nil {
+		h.logger.Error("Failed to delete filter preset", "error", err, "preset_id", preset.ID)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to delete preset")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
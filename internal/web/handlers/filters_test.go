@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDownloadFilter(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	filter, err := handlers.parseDownloadFilter(url.Values{
+		"search":         {"film"},
+		"status":         {"completed", "failed"},
+		"created_from":   {"2024-01-01"},
+		"created_to":     {"2024-01-31"},
+		"completed_from": {"2024-01-02T10:00:00Z"},
+		"min_size":       {"700MB"},
+		"max_size":       {"1.5 GB"},
+		"host":           {" example.com "},
+		"dir":            {"movies/"},
+		"group":          {"abc"},
+		"archives":       {"on"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, database.DownloadFilter{
+		Search:        "film",
+		Statuses:      []string{"completed", "failed"},
+		CreatedFrom:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+		CreatedTo:     time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local),
+		CompletedFrom: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC),
+		MinSize:       700 << 20,
+		MaxSize:       3 << 29,
+		Host:          "example.com",
+		Directory:     "/downloads/movies",
+		GroupID:       "abc",
+		ArchivesOnly:  true,
+	}, filter)
+
+	for values, message := range map[string]string{
+		"created_to=yesterday": "created_to must be a date such as 2024-01-31",
+		"min_size=big":         "min_size must be a size such as 700MB",
+		"archives=maybe":       "archives must be true or false",
+		"dir=../etc":           "dir must be a folder inside the downloads directory",
+	} {
+		query, err := url.ParseQuery(values)
+		require.NoError(t, err)
+		_, err = handlers.parseDownloadFilter(query)
+		require.EqualError(t, err, message)
+	}
+
+	// The folder is relative to the downloads folder; a full path inside it is kept
+	for dir, want := range map[string]string{
+		"/":                 "/downloads",
+		"/movies":           "/downloads/movies",
+		"tv/Show":           "/downloads/tv/Show",
+		"/downloads/movies": "/downloads/movies",
+	} {
+		filter, err := handlers.parseDownloadFilter(url.Values{"dir": {dir}})
+		require.NoError(t, err)
+		require.Equal(t, want, filter.Directory, dir)
+	}
+
+	require.Equal(t, "host=example.com&search=film&status=failed&status=paused",
+		filterQuery(url.Values{"search": {"film"}, "status": {"failed", "paused"}, "host": {"example.com"}, "dir": {""}, "sort": {"asc"}}))
+}
+
+func TestHandlers_SearchDownloadsFilters(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	for _, name := range []string{"movie.mkv", "backup.rar"} {
+		require.NoError(t, db.CreateDownload(&models.Download{
+			OriginalURL: "https://example.com/" + name,
+			Filename:    name,
+			Directory:   "/downloads",
+			Status:      models.StatusCompleted,
+			IsArchive:   strings.HasSuffix(name, ".rar"),
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}))
+	}
+
+	search := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/downloads/search", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handlers.SearchDownloads(w, req)
+		return w
+	}
+
+	w := search(url.Values{"status": {"completed"}, "archives": {"true"}})
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "backup.rar")
+	require.NotContains(t, w.Body.String(), "movie.mkv")
+
+	w = search(url.Values{"status": {"completed"}, "min_size": {"lots"}})
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "min_size must be a size")
+}
+
+func TestHandlers_FilterPresets(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	alice := &models.User{Username: "alice", Role: models.RoleUser, CreatedAt: time.Now()}
+	bob := &models.User{Username: "bob", Role: models.RoleUser, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(alice))
+	require.NoError(t, db.CreateUser(bob))
+
+	// Saving keeps only the filters from the search form
+	form := url.Values{"preset_name": {"Failed movies"}, "search": {"dir:movies"}, "status": {"failed"}, "sort": {"asc"}, "host": {""}}
+	w := httptest.NewRecorder()
+	handlers.SaveFilterPreset(w, requestAs(alice, "POST", "/presets", form.Encode()))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "Failed movies")
+
+	presets, err := db.ListFilterPresets(alice.ID)
+	require.NoError(t, err)
+	require.Len(t, presets, 1)
+	require.Equal(t, "search=dir%3Amovies&status=failed", presets[0].Query)
+
+	w = httptest.NewRecorder()
+	handlers.SaveFilterPreset(w, requestAs(alice, "POST", "/presets", url.Values{"preset_name": {" "}}.Encode()))
+	require.Contains(t, w.Body.String(), "preset name is required")
+	w = httptest.NewRecorder()
+	handlers.SaveFilterPreset(w, requestAs(alice, "POST", "/presets", url.Values{"preset_name": {"Bad"}, "created_from": {"soon"}}.Encode()))
+	require.Contains(t, w.Body.String(), "created_from must be a date")
+
+	// Presets are personal
+	w = httptest.NewRecorder()
+	handlers.FilterPresets(w, requestAs(bob, "GET", "/presets", ""))
+	require.Contains(t, w.Body.String(), "No saved presets")
+
+	target := fmt.Sprintf("/presets/%d", presets[0].ID)
+	req := requestAs(bob, "DELETE", target, "")
+	req.SetPathValue("id", fmt.Sprint(presets[0].ID))
+	w = httptest.NewRecorder()
+	handlers.DeleteFilterPreset(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	req = requestAs(alice, "DELETE", target, "")
+	req.SetPathValue("id", fmt.Sprint(presets[0].ID))
+	w = httptest.NewRecorder()
+	handlers.DeleteFilterPreset(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "No saved presets")
+}
+
+func TestHandlers_APIPresets(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	for _, download := range []*models.Download{
+		{OriginalURL: "https://one.example/a", Filename: "a.mkv", Status: models.StatusCompleted},
+		{OriginalURL: "https://two.example/b", Filename: "b.mkv", Status: models.StatusCompleted},
+		{OriginalURL: "https://two.example/c", Filename: "c.mkv", Status: models.StatusFailed},
+	} {
+		download.Directory = "/downloads"
+		download.CreatedAt = time.Now()
+		download.UpdatedAt = time.Now()
+		require.NoError(t, db.CreateDownload(download))
+	}
+
+	list := func(target string) (int, []string) {
+		w := httptest.NewRecorder()
+		handlers.APIListDownloads(w, httptest.NewRequest("GET", target, nil))
+		var response struct {
+			Downloads []*models.Download `json:"downloads"`
+		}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		var names []string
+		for _, d := range response.Downloads {
+			names = append(names, d.Filename)
+		}
+		return w.Code, names
+	}
+
+	// The list takes the same filters as the search form
+	code, names := list("/api/v1/downloads?status=completed&status=failed&host=two.example")
+	require.Equal(t, http.StatusOK, code)
+	require.ElementsMatch(t, []string{"b.mkv", "c.mkv"}, names)
+	code, _ = list("/api/v1/downloads?status=completed&created_from=never")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	w := httptest.NewRecorder()
+	handlers.APICreatePreset(w, httptest.NewRequest("POST", "/api/v1/presets", strings.NewReader(`{"name": "Two", "query": "?status=completed&status=failed&host=two.example"}`)))
+	require.Equal(t, http.StatusCreated, w.Code)
+	var preset models.FilterPreset
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &preset))
+	require.Equal(t, "host=two.example&status=completed&status=failed", preset.Query)
+
+	w = httptest.NewRecorder()
+	handlers.APICreatePreset(w, httptest.NewRequest("POST", "/api/v1/presets", strings.NewReader(`{"name": "Bad", "query": "max_size=huge"}`)))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	handlers.APIListPresets(w, httptest.NewRequest("GET", "/api/v1/presets", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `"name":"Two"`)
+
+	// A preset supplies filters and the query overrides them
+	code, names = list(fmt.Sprintf("/api/v1/downloads?preset=%d", preset.ID))
+	require.Equal(t, http.StatusOK, code)
+	require.ElementsMatch(t, []string{"b.mkv", "c.mkv"}, names)
+	code, names = list(fmt.Sprintf("/api/v1/downloads?preset=%d&status=failed", preset.ID))
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"c.mkv"}, names)
+	code, _ = list("/api/v1/downloads?preset=999")
+	require.Equal(t, http.StatusNotFound, code)
+
+	req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/presets/%d", preset.ID), nil)
+	req.SetPathValue("id", fmt.Sprint(preset.ID))
+	w = httptest.NewRecorder()
+	handlers.APIDeletePreset(w, req)
+	require.Equal(t, http.StatusNoContent, w.Code)
+
+	req = httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/presets/%d", preset.ID), nil)
+	req.SetPathValue("id", fmt.Sprint(preset.ID))
+	w = httptest.NewRecorder()
+	handlers.APIDeletePreset(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
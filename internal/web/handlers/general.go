@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/settings"
+	"debrid-downloader/internal/web/templates"
+)
+
+// megabyte is the unit speed limits are entered in on the settings page
+const megabyte = 1 << 20
+
+// SetSettings sets the runtime settings edited on the settings page. Without them the
+// section is hidden and new downloads default to the downloads folder.
+func (h *Handlers) SetSettings(service *settings.Service) {
+	h.settings = service
+}
+
+// defaultDirectory returns the directory suggested when nothing else matches. A user
+// limited to a folder keeps that folder as their default.
+func (h *Handlers) defaultDirectory() string {
+	if h.settings == nil || h.restricted() {
+		return h.folderService.BasePath
+	}
+	return filepath.Join(h.folderService.BasePath, h.settings.Current().DefaultDirectory)
+}
+
+// UpdateGeneralSettings validates and applies the download settings from the settings page
+func (h *Handlers) UpdateGeneralSettings(w http.ResponseWriter, r *http.Request) {
+	if h.settings == nil {
+		http.Error(w, "Settings are not available", http.StatusNotFound)
+		return
+	}
+
+	next, err := parseGeneralSettings(r, h.settings.Current())
+	if err != nil {
+		h.renderGeneral(w, r, "", err.Error())
+		return
+	}
+
+	if err := h.settings.Update(next); err != nil {
+		h.logger.Warn("Failed to update settings", "error", err)
+		h.renderGeneral(w, r, "", err.Error())
+		return
+	}
+
+	h.logger.Info("Settings updated", "concurrency", next.Concurrency, "speed_limit", next.SpeedLimit)
+	h.renderGeneral(w, r, "Settings saved", "")
+}
+
+// ResetGeneralSettings goes back to the configured download settings
+func (h *Handlers) ResetGeneralSettings(w http.ResponseWriter, r *http.Request) {
+	if h.settings == nil {
+		http.Error(w, "Settings are not available", http.StatusNotFound)
+		return
+	}
+
+	if err := h.settings.Reset(); err != nil {
+		h.logger.Error("Failed to reset settings", "error", err)
+		h.renderGeneral(w, r, "", "Failed to reset settings")
+		return
+	}
+
+	h.logger.Info("Settings reset")
+	h.renderGeneral(w, r, "Settings reset to the configured values", "")
+}
+
+// parseGeneralSettings reads the settings form over the current settings
+func parseGeneralSettings(r *http.Request, current settings.Settings) (settings.Settings, error) {
+	if err := r.ParseForm(); err != nil {
+		return current, fmt.Errorf("invalid form data")
+	}
+
+	next := current
+	var err error
+	if next.Concurrency, err = strconv.Atoi(strings.TrimSpace(r.FormValue("concurrency"))); err != nil {
+		return current, fmt.Errorf("concurrent downloads must be a whole number")
+	}
+	if next.MaxRetries, err = strconv.Atoi(strings.TrimSpace(r.FormValue("max_retries"))); err != nil {
+		return current, fmt.Errorf("retries must be a whole number")
+	}
+
+	speedLimit, err := strconv.ParseFloat(strings.TrimSpace(r.FormValue("speed_limit")), 64)
+	if err != nil || math.IsNaN(speedLimit) || math.IsInf(speedLimit, 0) {
+		return current, fmt.Errorf("speed limit must be a number")
+	}
+	next.SpeedLimit = int64(speedLimit * megabyte)
+
+	backoff, err := strconv.ParseFloat(strings.TrimSpace(r.FormValue("retry_backoff")), 64)
+	if err != nil || math.IsNaN(backoff) || math.IsInf(backoff, 0) {
+		return current, fmt.Errorf("retry backoff must be a number of seconds")
+	}
+	next.RetryBackoff = time.Duration(backoff * float64(time.Second))
+
+	next.VideoExtensions = strings.Split(r.FormValue("video_extensions"), ",")
+	next.CleanupExtensions = strings.Split(r.FormValue("cleanup_extensions"), ",")
+	next.SubtitleExtensions = strings.Split(r.FormValue("subtitle_extensions"), ",")
+	next.DefaultDirectory = r.FormValue("default_directory")
+	return next, nil
+}
+
+// generalSettings returns the runtime settings as the settings form shows them
+func (h *Handlers) generalSettings() templates.GeneralSettings {
+	current := h.settings.Current()
+	return templates.GeneralSettings{
+		Concurrency:        current.Concurrency,
+		MaxConcurrency:     settings.MaxConcurrency,
+		SpeedLimit:         strconv.FormatFloat(float64(current.SpeedLimit)/megabyte, 'f', -1, 64),
+		MaxRetries:         current.MaxRetries,
+		RetryBackoff:       strconv.FormatFloat(current.RetryBackoff.Seconds(), 'f', -1, 64),
+		VideoExtensions:    strings.Join(current.VideoExtensions, ", "),
+		CleanupExtensions:  strings.Join(current.CleanupExtensions, ", "),
+		SubtitleExtensions: strings.Join(current.SubtitleExtensions, ", "),
+		DefaultDirectory:   current.DefaultDirectory,
+		BasePath:           h.folderService.BasePath,
+	}
+}
+
+// renderGeneral renders the download settings section of the settings page
+func (h *Handlers) renderGeneral(w http.ResponseWriter, r *http.Request, message, errorMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	if err := templates.GeneralSection(h.generalSettings(), message, errorMessage).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render settings", "error", err)
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/internal/settings"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlers_GeneralSettings(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+	require.Equal(t, "/downloads", handlers.defaultDirectory())
+
+	defaults := settings.Settings{Concurrency: 1, MaxRetries: 5, RetryBackoff: time.Second, RetentionDays: 60, FailedRetentionDays: 60}
+	require.NoError(t, defaults.Validate())
+	service, err := settings.NewService(db, defaults)
+	require.NoError(t, err)
+	handlers.SetSettings(service)
+
+	// The section is shown on the settings page
+	w := httptest.NewRecorder()
+	handlers.Settings(w, httptest.NewRequest("GET", "/settings", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `id="general"`)
+	require.Contains(t, w.Body.String(), "Concurrent downloads")
+
+	post := func(path string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		if strings.HasSuffix(path, "/reset") {
+			handlers.ResetGeneralSettings(w, req)
+		} else {
+			handlers.UpdateGeneralSettings(w, req)
+		}
+		return w
+	}
+
+	form := url.Values{
+		"concurrency":         {"3"},
+		"speed_limit":         {"1.5"},
+		"max_retries":         {"2"},
+		"retry_backoff":       {"0.5"},
+		"video_extensions":    {"MKV, mp4"},
+		"cleanup_extensions":  {""},
+		"subtitle_extensions": {".srt"},
+		"default_directory":   {"incoming"},
+	}
+	w = post("/settings/general", form)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "Settings saved")
+	require.Contains(t, w.Body.String(), ".mkv, .mp4")
+
+	current := service.Current()
+	require.Equal(t, 3, current.Concurrency)
+	require.Equal(t, int64(1.5*(1<<20)), current.SpeedLimit)
+	require.Equal(t, 500*time.Millisecond, current.RetryBackoff)
+	require.Equal(t, "/downloads/incoming", handlers.defaultDirectory())
+
+	// Invalid values are reported and change nothing
+	form.Set("concurrency", "40")
+	w = post("/settings/general", form)
+	require.Contains(t, w.Body.String(), "concurrent downloads must be between 1 and 10")
+	require.Equal(t, 3, service.Current().Concurrency)
+
+	form.Set("concurrency", "2")
+	form.Set("speed_limit", "fast")
+	w = post("/settings/general", form)
+	require.Contains(t, w.Body.String(), "speed limit must be a number")
+
+	form.Set("speed_limit", "0")
+	form.Set("default_directory", "/etc")
+	w = post("/settings/general", form)
+	require.Contains(t, w.Body.String(), "default directory must be relative")
+	require.Equal(t, "incoming", service.Current().DefaultDirectory)
+
+	// Reset goes back to the defaults
+	w = post("/settings/general/reset", nil)
+	require.Contains(t, w.Body.String(), "Settings reset to the configured values")
+	require.Equal(t, defaults, service.Current())
+	require.Equal(t, "/downloads", handlers.defaultDirectory())
+}
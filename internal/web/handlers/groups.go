@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"debrid-downloader/pkg/models"
+)
+
+// groupActions lists the actions that apply to a whole group besides deleting it
+var groupActions = []string{"pause", "resume", "retry", "process", "move"}
+
+// ownGroup looks up a download group that the current user may change
+func (h *Handlers) ownGroup(id string) (*models.DownloadGroup, bool) {
+	group, err := h.db.GetDownloadGroup(id)
+	if err != nil || !h.ownsRecord(group.OwnerID) {
+		return nil, false
+	}
+	return group, true
+}
+
+// groupAction applies an action to every download of a group. Retrying resets a failed
+// group so it is processed again when its downloads complete; process re-runs the
+// post-processing of a group whose downloads have all completed.
+func (h *Handlers) groupAction(group *models.DownloadGroup, action, directory string) (*bulkResult, error) {
+	downloads, err := h.db.GetDownloadsByGroupID(group.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(downloads) == 0 {
+		return nil, &actionError{message: "The group has no downloads"}
+	}
+
+	ids := make([]int64, len(downloads))
+	for i, download := range downloads {
+		ids[i] = download.ID
+	}
+
+	switch action {
+	case "pause", "resume":
+		return h.bulkAction(bulkRequest{Action: action, IDs: ids})
+
+	case "move":
+		if group.Status == models.GroupStatusProcessing {
+			return nil, &actionError{message: "Wait for the group to finish processing before moving it"}
+		}
+		for _, download := range downloads {
+			if download.Status == models.StatusDownloading {
+				return nil, &actionError{message: "Pause the group before moving it"}
+			}
+		}
+		return h.bulkAction(bulkRequest{Action: action, IDs: ids, Directory: directory})
+
+	case "retry":
+		changed, err := h.db.RetryDownloadGroup(group.ID, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if len(changed) == 0 {
+			return nil, &actionError{message: "The group has no failed downloads"}
+		}
+		for _, id := range changed {
+			h.downloadWorker.QueueDownload(id)
+		}
+		return newBulkResult(action, ids, changed), nil
+
+	case "process":
+		if group.Status == models.GroupStatusProcessing {
+			return nil, &actionError{message: "The group is already being processed"}
+		}
+		for _, download := range downloads {
+			if download.Status != models.StatusCompleted {
+				return nil, &actionError{message: "Every download in the group has to complete first"}
+			}
+		}
+		if err := h.downloadWorker.ReprocessGroup(group.ID); err != nil {
+			return nil, err
+		}
+		return newBulkResult(action, ids, ids), nil
+	}
+
+	return nil, &actionError{message: "Unknown group action, use one of " + strings.Join(groupActions, ", ")}
+}
+
+// deleteGroup removes a group and its downloads in one transaction, cancelling those in
+// progress, and with deleteFiles removes the files of the completed ones. A group being
+// post-processed is left alone until the extraction finishes.
+func (h *Handlers) deleteGroup(group *models.DownloadGroup, deleteFiles bool) (*bulkResult, error) {
+	if group.Status == models.GroupStatusProcessing {
+		return nil, &actionError{message: "Wait for the group to finish processing before deleting it"}
+	}
+
+	downloads, err := h.db.GetDownloadsByGroupID(group.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted, err := h.deleteDownloads(downloads, deleteFiles, func([]int64) error {
+		_, err := h.db.DeleteDownloadGroup(group.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.logger.Info("Download group deleted", "group_id", group.ID, "downloads", len(deleted), "delete_files", deleteFiles)
+	return newBulkResult("delete", deleted, deleted), nil
+}
+
+// groupSummary describes the result of a group action for the history
+func groupSummary(result *bulkResult) string {
+	if result.Action == "process" {
+		return "Post-processing the group again"
+	}
+	return "Group: " + result.summary()
+}
+
+// GroupAction applies an action from a download's group buttons to the whole group and
+// asks the history to refresh. Move takes the folder from the directory field or the
+// prompt.
+func (h *Handlers) GroupAction(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	group, ok := h.ownGroup(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return
+	}
+
+	directory := strings.TrimSpace(r.FormValue("directory"))
+	if directory == "" {
+		directory = strings.TrimSpace(r.Header.Get("HX-Prompt"))
+	}
+
+	result, err := h.groupAction(group, r.PathValue("action"), directory)
+	h.renderGroupResult(w, r, group, result, err)
+}
+
+// DeleteGroup removes a group and its downloads from the history, and with
+// delete_files=true their files
+func (h *Handlers) DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	group, ok := h.ownGroup(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return
+	}
+
+	result, err := h.deleteGroup(group, formBool(r.FormValue("delete_files")))
+	h.renderGroupResult(w, r, group, result, err)
+}
+
+// renderGroupResult renders the outcome of a group action in the bulk actions bar
+func (h *Handlers) renderGroupResult(w http.ResponseWriter, r *http.Request, group *models.DownloadGroup, result *bulkResult, err error) {
+	if err != nil {
+		status, message := actionErrorStatus(err, "Failed to update group")
+		if status == http.StatusInternalServerError {
+			h.logger.Error("Failed to apply group action", "group_id", group.ID, "error", err)
+			http.Error(w, message, status)
+			return
+		}
+		h.renderBulkResult(w, r, false, message)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", "downloadsChanged")
+	h.renderBulkResult(w, r, true, groupSummary(result))
+}
+
+// apiGroupRequest is the optional JSON body of the group actions
+type apiGroupRequest struct {
+	Directory string `json:"directory"`
+}
+
+// APIGetGroup returns a download group with its downloads as JSON
+func (h *Handlers) APIGetGroup(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	group, ok := h.ownGroup(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Group not found")
+		return
+	}
+
+	downloads, err := h.db.GetDownloadsByGroupID(group.ID)
+	if err != nil {
+		h.logger.Error("Failed to get group downloads", "group_id", group.ID, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to get group")
+		return
+	}
+	if downloads == nil {
+		downloads = []*models.Download{}
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{"group": group, "downloads": downloads})
+}
+
+// APIGroupAction applies pause, resume, retry, process or move to a whole group and
+// returns which of its downloads changed
+func (h *Handlers) APIGroupAction(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	group, ok := h.ownGroup(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Group not found")
+		return
+	}
+
+	var req apiGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	result, err := h.groupAction(group, r.PathValue("action"), strings.TrimSpace(req.Directory))
+	h.writeGroupResult(w, group, result, err)
+}
+
+// APIDeleteGroup removes a group and its downloads, and with delete_files=true their files
+func (h *Handlers) APIDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	group, ok := h.ownGroup(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "Group not found")
+		return
+	}
+
+	result, err := h.deleteGroup(group, formBool(r.URL.Query().Get("delete_files")))
+	h.writeGroupResult(w, group, result, err)
+}
+
+// writeGroupResult writes the outcome of a group action as JSON
+func (h *Handlers) writeGroupResult(w http.ResponseWriter, group *models.DownloadGroup, result *bulkResult, err error) {
+	if err != nil {
+		status, message := actionErrorStatus(err, "Failed to update group")
+		if status == http.StatusInternalServerError {
+			h.logger.Error("Failed to apply group action", "group_id", group.ID, "error", err)
+		}
+		writeJSONError(w, status, message)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlers_GroupActions(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	base := t.TempDir()
+	incoming := filepath.Join(base, "incoming")
+	require.NoError(t, os.MkdirAll(incoming, 0755))
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), base, downloader.NewWorker(db, base))
+
+	group := &models.DownloadGroup{ID: "group-1", CreatedAt: time.Now(), TotalDownloads: 2, Status: models.GroupStatusDownloading}
+	require.NoError(t, db.CreateDownloadGroup(group))
+
+	var parts []*models.Download
+	for _, part := range []struct {
+		name   string
+		status models.DownloadStatus
+	}{
+		{"notes.part1.txt", models.StatusCompleted},
+		{"notes.part2.txt", models.StatusFailed},
+	} {
+		download := &models.Download{
+			OriginalURL: "https://example.com/" + part.name,
+			Filename:    part.name,
+			Directory:   incoming,
+			Status:      part.status,
+			RetryCount:  6, // Past the automatic retries
+			GroupID:     group.ID,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+		require.NoError(t, db.CreateDownload(download))
+		require.NoError(t, os.WriteFile(filepath.Join(incoming, part.name), []byte(part.name), 0644))
+		parts = append(parts, download)
+	}
+
+	api := func(action, body string) (int, string) {
+		req := httptest.NewRequest("POST", "/api/v1/groups/"+group.ID+"/"+action, strings.NewReader(body))
+		req.SetPathValue("id", group.ID)
+		req.SetPathValue("action", action)
+		w := httptest.NewRecorder()
+		handlers.APIGroupAction(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	code, body := api("explode", "")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, body, "pause, resume, retry, process, move")
+
+	// Post-processing waits for every download to complete
+	code, body = api("process", "")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, body, "has to complete first")
+
+	// Retrying only touches the failed downloads, and starts their retries over
+	code, body = api("retry", "")
+	require.Equal(t, http.StatusOK, code)
+	var result bulkResult
+	require.NoError(t, json.Unmarshal([]byte(body), &result))
+	require.Equal(t, []int64{parts[1].ID}, result.Changed)
+
+	retried, err := db.GetDownload(parts[1].ID)
+	require.NoError(t, err)
+	require.Zero(t, retried.RetryCount)
+
+	code, body = api("retry", "")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, body, "no failed downloads")
+
+	retried.Status = models.StatusCompleted
+	require.NoError(t, db.UpdateDownload(retried))
+
+	// A group being post-processed can't be moved or deleted under the extraction
+	group.Status = models.GroupStatusProcessing
+	require.NoError(t, db.UpdateDownloadGroup(group))
+
+	code, body = api("move", `{"directory": "archive"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, body, "finish processing")
+
+	req := httptest.NewRequest("DELETE", "/api/v1/groups/"+group.ID, nil)
+	req.SetPathValue("id", group.ID)
+	w := httptest.NewRecorder()
+	handlers.APIDeleteGroup(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "finish processing")
+
+	code, body = api("process", "")
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, body, "already being processed")
+
+	group.Status = models.GroupStatusCompleted
+	require.NoError(t, db.UpdateDownloadGroup(group))
+
+	code, _ = api("process", "")
+	require.Equal(t, http.StatusOK, code)
+	require.Eventually(t, func() bool {
+		found, err := db.GetDownloadGroup(group.ID)
+		return err == nil && found.Status == models.GroupStatusCompleted
+	}, time.Second, 10*time.Millisecond)
+
+	code, _ = api("move", `{"directory": "../outside"}`)
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// The history's move button asks for the folder with a prompt
+	req = httptest.NewRequest("POST", "/groups/"+group.ID+"/move", nil)
+	req.SetPathValue("id", group.ID)
+	req.SetPathValue("action", "move")
+	req.Header.Set("HX-Prompt", "archive")
+	w = httptest.NewRecorder()
+	handlers.GroupAction(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "Group: Moved 2 downloads")
+	require.Equal(t, "downloadsChanged", w.Header().Get("HX-Trigger"))
+	require.FileExists(t, filepath.Join(base, "archive", "notes.part2.txt"))
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/api/v1/groups/"+group.ID, nil)
+	req.SetPathValue("id", group.ID)
+	handlers.APIGetGroup(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var details struct {
+		Group     models.DownloadGroup `json:"group"`
+		Downloads []*models.Download   `json:"downloads"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &details))
+	require.Len(t, details.Downloads, 2)
+	require.Equal(t, filepath.Join(base, "archive"), details.Downloads[0].Directory)
+
+	// Deleting with files removes the group, its downloads and their files
+	req = httptest.NewRequest("DELETE", "/groups/"+group.ID+"?delete_files=true", nil)
+	req.SetPathValue("id", group.ID)
+	w = httptest.NewRecorder()
+	handlers.DeleteGroup(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "Group: Deleted 2 downloads")
+	require.NoFileExists(t, filepath.Join(base, "archive", "notes.part1.txt"))
+
+	_, err = db.GetDownloadGroup(group.ID)
+	require.Error(t, err)
+	_, err = db.GetDownload(parts[0].ID)
+	require.Error(t, err)
+
+	req = httptest.NewRequest("DELETE", "/api/v1/groups/"+group.ID, nil)
+	req.SetPathValue("id", group.ID)
+	w = httptest.NewRecorder()
+	handlers.APIDeleteGroup(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
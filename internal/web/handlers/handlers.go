@@ -4,25 +4,27 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"debrid-downloader/internal/alldebrid"
 	"debrid-downloader/internal/database"
 	"debrid-downloader/internal/downloader"
 	"debrid-downloader/internal/folder"
+	"debrid-downloader/internal/rules"
+	"debrid-downloader/internal/retention"
+	"debrid-downloader/internal/settings"
+	"debrid-downloader/internal/submit"
 	"debrid-downloader/internal/web/templates"
 	"debrid-downloader/pkg/fuzzy"
 	"debrid-downloader/pkg/models"
-	"github.com/google/uuid"
 )
 
 // Handlers contains all HTTP handlers and their dependencies
@@ -31,9 +33,14 @@ type Handlers struct {
 	allDebridClient alldebrid.AllDebridClient
 	folderService   *folder.Service
 	downloadWorker  *downloader.Worker
+	submitService   *submit.Service
+	settings        *settings.Service // Runtime settings, nil when not set
+	retention       *retention.Service // History cleanup, nil when not set
+	hooksPath       string // Directory of post-processing hook scripts, empty when hooks are disabled
+	backupPath      string // Directory of scheduled backups, empty when they are off
 	logger          *slog.Logger
-	urlCache        map[string]*alldebrid.UnrestrictResult // Simple cache for unrestricted URLs
-	cacheMutex      sync.RWMutex                           // Protects urlCache
+	// user is the signed-in user the handlers are scoped to, nil when authentication is disabled
+	user *models.User
 }
 
 // NewHandlers creates a new handlers instance
@@ -43,8 +50,8 @@ func NewHandlers(db *database.DB, client alldebrid.AllDebridClient, basePath str
 		allDebridClient: client,
 		folderService:   folder.NewService(basePath),
 		downloadWorker:  worker,
+		submitService:   submit.NewService(db, client, worker),
 		logger:          slog.Default(),
-		urlCache:        make(map[string]*alldebrid.UnrestrictResult),
 	}
 }
 
@@ -52,6 +59,11 @@ func NewHandlers(db *database.DB, client alldebrid.AllDebridClient, basePath str
 func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
 	// Get filename from query parameter for directory suggestions
 	filename := r.URL.Query().Get("filename")
 
@@ -61,7 +73,7 @@ func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
 	// Start with empty downloads list - user must select statuses to see results
 	var downloads []*models.Download
 
-	component := templates.Base("Debrid Downloader", templates.Home(downloads, suggestedDir))
+	component := templates.Base("Debrid Downloader", templates.Home(downloads, suggestedDir, h.categoryOptions()))
 	if err := component.Render(r.Context(), w); err != nil {
 		h.logger.Error("Failed to render home template", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -73,8 +85,13 @@ func (h *Handlers) Home(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) CurrentDownloads(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
 	// Get current downloads (pending, downloading, paused)
-	downloads, err := h.db.ListDownloads(10, 0)
+	downloads, err := h.db.ListDownloadsByOwner(h.downloadOwner(), 10, 0)
 	if err != nil {
 		h.logger.Error("Failed to get current downloads", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -104,6 +121,11 @@ func (h *Handlers) CurrentDownloads(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) SubmitDownload(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
 	if err := r.ParseForm(); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		component := templates.DownloadResult(false, "Failed to parse form data")
@@ -115,7 +137,13 @@ func (h *Handlers) SubmitDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	category := r.FormValue("category")
 	directory := r.FormValue("directory")
+	if directory == "" && category != "" {
+		if categoryDir, err := h.categoryDirectory(category); err == nil {
+			directory = categoryDir
+		}
+	}
 	if directory == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		component := templates.DownloadResult(false, "Directory is required")
@@ -127,6 +155,18 @@ func (h *Handlers) SubmitDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Restricted users can only download into their own folder
+	if h.restricted() && !h.folderService.Contains(directory) {
+		w.WriteHeader(http.StatusForbidden)
+		component := templates.DownloadResult(false, "Directory is outside your downloads folder")
+		if err := component.Render(r.Context(), w); err != nil {
+			h.logger.Error("Failed to render component", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		return
+	}
+
 	// Parse URLs - check if it's single or multi-URL submission
 	var urls []string
 
@@ -161,143 +201,23 @@ func (h *Handlers) SubmitDownload(w http.ResponseWriter, r *http.Request) {
 		urls = []string{singleURL}
 	}
 
-	var groupID string
-	var downloads []*models.Download
-
-	// If multiple URLs, create a group
-	if len(urls) > 1 {
-		groupID = uuid.New().String()
-
-		// Create download group record
-		group := &models.DownloadGroup{
-			ID:                 groupID,
-			CreatedAt:          time.Now(),
-			TotalDownloads:     len(urls),
-			CompletedDownloads: 0,
-			Status:             models.GroupStatusDownloading,
-		}
-
-		if err := h.db.CreateDownloadGroup(group); err != nil {
-			h.logger.Error("Failed to create download group", "error", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			component := templates.DownloadResult(false, "Failed to create download group")
-			if err := component.Render(r.Context(), w); err != nil {
-				h.logger.Error("Failed to render component", "error", err)
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
-				return
-			}
-			return
-		}
-	}
-
-	// Process each URL
-	for i, url := range urls {
-		var result *alldebrid.UnrestrictResult
-		var err error
-
-		// Check cache first
-		h.cacheMutex.RLock()
-		if cached, exists := h.urlCache[url]; exists {
-			h.cacheMutex.RUnlock()
-			result = cached
-			h.logger.Debug("Using cached unrestrict result", "url", url, "filename", result.Filename)
-		} else {
-			h.cacheMutex.RUnlock()
-			// Not in cache, unrestrict the URL using AllDebrid
-			result, err = h.allDebridClient.UnrestrictLink(r.Context(), url)
-			if err != nil {
-				h.logger.Error("Failed to unrestrict URL", "error", err, "url", url, "group_id", groupID)
-				// For multi-URL, continue with other URLs; for single URL, return error
-				if len(urls) == 1 {
-					w.WriteHeader(http.StatusBadRequest)
-					component := templates.DownloadResult(false, fmt.Sprintf("Failed to unrestrict URL: %s", err.Error()))
-					if err := component.Render(r.Context(), w); err != nil {
-						h.logger.Error("Failed to render component", "error", err)
-						http.Error(w, "Internal server error", http.StatusInternalServerError)
-						return
-					}
-					return
-				}
-				continue // Skip this URL but continue with others
-			}
-
-			// Cache the result
-			h.cacheMutex.Lock()
-			h.urlCache[url] = result
-			h.cacheMutex.Unlock()
-		}
-
-		// Ensure unique filename by checking for existing files
-		uniqueFilename := h.ensureUniqueFilename(result.Filename, directory)
-
-		// Detect if this is an archive file
-		isArchive := h.isArchiveFile(result.Filename)
-
-		// Create download record
-		download := &models.Download{
-			OriginalURL:     url,
-			UnrestrictedURL: result.UnrestrictedURL,
-			Filename:        uniqueFilename,
-			Directory:       directory,
-			Status:          models.StatusPending,
-			Progress:        0.0,
-			FileSize:        result.FileSize,
-			DownloadedBytes: 0,
-			DownloadSpeed:   0.0,
-			RetryCount:      0,
-			CreatedAt:       time.Now(),
-			UpdatedAt:       time.Now(),
-			GroupID:         groupID,
-			IsArchive:       isArchive,
-			ExtractedFiles:  "",
-		}
-
-		if err := h.db.CreateDownload(download); err != nil {
-			h.logger.Error("Failed to create download record", "error", err, "url", url, "group_id", groupID)
-			if len(urls) == 1 {
-				w.WriteHeader(http.StatusInternalServerError)
-				component := templates.DownloadResult(false, "Failed to create download record")
-				if err := component.Render(r.Context(), w); err != nil {
-					h.logger.Error("Failed to render component", "error", err)
-					http.Error(w, "Internal server error", http.StatusInternalServerError)
-					return
-				}
-				return
-			}
-			continue // Skip this URL but continue with others
-		}
-
-		downloads = append(downloads, download)
-
-		// Create or update directory mapping for future suggestions
-		if err := h.createOrUpdateDirectoryMapping(result.Filename, url, directory); err != nil {
-			h.logger.Warn("Failed to update directory mapping", "error", err, "filename", result.Filename, "url", url, "directory", directory)
-		}
-
-		// Queue the download for processing
-		h.downloadWorker.QueueDownload(download.ID)
-
-		h.logger.Info("Download submitted", "url", url, "directory", directory, "filename", result.Filename, "download_id", download.ID, "group_id", groupID, "is_archive", isArchive, "position", i+1, "total", len(urls))
+	result, err := h.submitService.Submit(r.Context(), submit.Request{URLs: urls, Directory: directory, OwnerID: h.ownerID(), Category: category})
+	if err != nil {
+		h.renderSubmitError(w, r, err)
+		return
 	}
 
-	// Clean up cache for processed URLs to prevent memory growth
-	h.cacheMutex.Lock()
-	for _, url := range urls {
-		delete(h.urlCache, url)
-	}
-	h.cacheMutex.Unlock()
+	groupID := result.GroupID
+	downloads := make([]*models.Download, 0, len(result.Items))
+	for _, item := range result.Items {
+		downloads = append(downloads, item.Download)
 
-	// Check if any downloads were created
-	if len(downloads) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		component := templates.DownloadResult(false, "No downloads could be created")
-		if err := component.Render(r.Context(), w); err != nil {
-			h.logger.Error("Failed to render component", "error", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			return
+		// Learn the directory for future suggestions
+		if err := h.learnDirectory(item.SourceFilename, item.Download.OriginalURL, directory); err != nil {
+			h.logger.Warn("Failed to learn directory", "error", err, "filename", item.SourceFilename, "url", item.Download.OriginalURL, "directory", directory)
 		}
-		return
 	}
+	h.recordSuggestionFeedback(r.FormValue("suggested_directory"), directory, result.Items)
 
 	// Create success message
 	var successMessage string
@@ -311,7 +231,7 @@ func (h *Handlers) SubmitDownload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get updated downloads for the list
-	allDownloads, err := h.db.ListDownloads(50, 0)
+	allDownloads, err := h.db.ListDownloadsByOwner(h.downloadOwner(), 50, 0)
 	if err != nil {
 		h.logger.Error("Failed to get downloads for refresh", "error", err)
 		// Still return success, but without the refresh
@@ -410,7 +330,7 @@ func (h *Handlers) SubmitDownload(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update stats modal content with new download count
-	stats, err := h.db.GetDownloadStats()
+	stats, err := h.db.GetDownloadStatsByOwner(h.downloadOwner())
 	if err != nil {
 		h.logger.Error("Failed to get stats for update", "error", err)
 	} else {
@@ -430,175 +350,51 @@ func (h *Handlers) SubmitDownload(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// getDirectorySuggestions returns directory suggestions based on filename fuzzy matching
-func (h *Handlers) getDirectorySuggestions(filename string) string {
-	// Use the configured base path as default
-	basePath := h.folderService.BasePath
-
-	// Get directory mappings from database
-	mappings, err := h.db.GetDirectoryMappings()
-	if err != nil {
-		h.logger.Error("Failed to get directory mappings", "error", err)
-		return basePath
-	}
-
-	// If no mappings exist, just return base path
-	if len(mappings) == 0 {
-		return basePath
-	}
-
-	// Score directories based on filename matching
-	type dirScore struct {
-		directory string
-		score     int
-		useCount  int
-	}
-
-	var scores []dirScore
-	filename = strings.ToLower(filename)
+// renderSubmitError writes the result message for a failed submission
+func (h *Handlers) renderSubmitError(w http.ResponseWriter, r *http.Request, err error) {
+	status := http.StatusBadRequest
+	message := "Failed to submit download"
 
-	// Check against existing mappings
-	for _, mapping := range mappings {
-		score := fuzzyMatch(filename, strings.ToLower(mapping.FilenamePattern))
-		if score > 0 {
-			scores = append(scores, dirScore{
-				directory: mapping.Directory,
-				score:     score,
-				useCount:  mapping.UseCount,
-			})
+	var submitErr *submit.Error
+	if errors.As(err, &submitErr) {
+		message = submitErr.Message
+		if submitErr.Internal {
+			status = http.StatusInternalServerError
 		}
 	}
 
-	// Sort by score (descending) then by use count (descending)
-	sort.Slice(scores, func(i, j int) bool {
-		if scores[i].score == scores[j].score {
-			return scores[i].useCount > scores[j].useCount
-		}
-		return scores[i].score > scores[j].score
-	})
-
-	// Return best match or base path
-	if len(scores) > 0 {
-		return scores[0].directory
+	w.WriteHeader(status)
+	component := templates.DownloadResult(false, message)
+	if err := component.Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render component", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
-
-	return basePath
 }
 
-// getDirectorySuggestionsForURL returns directory suggestions based on URL fuzzy matching
-func (h *Handlers) getDirectorySuggestionsForURL(url string) string {
-	// Use the configured base path as default
-	basePath := h.folderService.BasePath
-
-	// Get directory mappings from database
-	mappings, err := h.db.GetDirectorySuggestionsForURL(url)
-	if err != nil {
-		h.logger.Error("Failed to get directory suggestions for URL", "error", err, "url", url)
-		return basePath
-	}
-
-	// If no mappings exist, try to suggest based on URL analysis
-	if len(mappings) == 0 {
-		return h.getSmartDirectorySuggestion(url, basePath)
-	}
-
-	// Score directories based on URL similarity
-	type dirScore struct {
-		directory string
-		score     int
-		useCount  int
-	}
-
-	var scores []dirScore
-	url = strings.ToLower(url)
-
-	// Check against existing mappings
-	for _, mapping := range mappings {
-		var score int
-
-		// First try URL matching if we have a stored URL
-		if mapping.OriginalURL != "" {
-			urlScore := fuzzyMatchURL(url, strings.ToLower(mapping.OriginalURL))
-			if urlScore > 0 {
-				score = urlScore
-			}
-		}
-
-		// If no URL match, fall back to filename matching
-		if score == 0 && mapping.FilenamePattern != "" {
-			// Extract filename from URL for comparison
-			urlFilename := extractFilenameFromURL(url)
-			if urlFilename != "" {
-				score = fuzzyMatch(urlFilename, strings.ToLower(mapping.FilenamePattern))
-			}
+// getDirectorySuggestions returns the directory a routing rule or the learned tokens pick
+// for a filename, or the default directory
+func (h *Handlers) getDirectorySuggestions(filename string) string {
+	// Routing rules are checked before the learned tokens
+	if filename != "" {
+		if directory, rule := h.ruleDirectory(rules.Link{Filename: filename}); rule != nil {
+			return directory
 		}
-
-		if score > 0 {
-			scores = append(scores, dirScore{
-				directory: mapping.Directory,
-				score:     score,
-				useCount:  mapping.UseCount,
-			})
+		if ranked := h.rankDirectories(filename, "", 1); len(ranked) > 0 {
+			return ranked[0].Directory
 		}
 	}
 
-	// Sort by score (descending) then by use count (descending)
-	sort.Slice(scores, func(i, j int) bool {
-		if scores[i].score == scores[j].score {
-			return scores[i].useCount > scores[j].useCount
-		}
-		return scores[i].score > scores[j].score
-	})
-
-	// Return best match or base path
-	if len(scores) > 0 {
-		return scores[0].directory
-	}
-
-	return basePath
+	return h.defaultDirectory()
 }
 
-// fuzzyMatch returns a score (0-100) for how well filename matches pattern
-func fuzzyMatch(filename, pattern string) int {
-	if pattern == "" {
-		return 0
-	}
-
-	// Exact substring match gets highest score
-	if strings.Contains(filename, pattern) {
-		return 100
-	}
-
-	// Check file extension match
-	filenameExt := strings.ToLower(filepath.Ext(filename))
-	patternExt := strings.ToLower(filepath.Ext(pattern))
-	if filenameExt != "" && filenameExt == patternExt {
-		return 80
-	}
-
-	// Check for partial word matches
-	filenameWords := strings.FieldsFunc(filename, func(r rune) bool {
-		return r == '.' || r == '_' || r == '-' || r == ' '
-	})
-	patternWords := strings.FieldsFunc(pattern, func(r rune) bool {
-		return r == '.' || r == '_' || r == '-' || r == ' '
-	})
-
-	matches := 0
-	for _, fw := range filenameWords {
-		for _, pw := range patternWords {
-			if strings.Contains(fw, pw) || strings.Contains(pw, fw) {
-				matches++
-				break
-			}
-		}
-	}
-
-	if len(patternWords) > 0 {
-		return (matches * 60) / len(patternWords)
+// getDirectorySuggestionsForURL returns the directory the learned tokens pick for a link,
+// otherwise the folder of the category its name suggests
+func (h *Handlers) getDirectorySuggestionsForURL(url string) string {
+	if ranked := h.rankDirectories(extractFilenameFromURL(url), url, 1); len(ranked) > 0 {
+		return ranked[0].Directory
 	}
 
-	return 0
+	return h.getSmartDirectorySuggestion(url, h.folderService.BasePath)
 }
 
 // contentBasedScore returns a score based on content type detection from filename
@@ -676,153 +472,9 @@ func (h *Handlers) contentBasedScore(filename, directory string) int {
 	return 0
 }
 
-// createOrUpdateDirectoryMapping creates or updates a directory mapping based on filename pattern and URL
-func (h *Handlers) createOrUpdateDirectoryMapping(filename, url, directory string) error {
-	// Extract pattern from filename (e.g., extension or keywords)
-	pattern := extractPattern(filename)
-	if pattern == "" {
-		return nil // No meaningful pattern to store
-	}
-
-	// Check if a mapping already exists for this pattern and directory
-	mappings, err := h.db.GetDirectoryMappings()
-	if err != nil {
-		return err
-	}
-
-	for _, mapping := range mappings {
-		if mapping.FilenamePattern == pattern && mapping.Directory == directory {
-			// Update existing mapping
-			return h.db.UpdateDirectoryMappingUsage(mapping.ID)
-		}
-	}
-
-	// Create new mapping
-	mapping := &models.DirectoryMapping{
-		FilenamePattern: pattern,
-		OriginalURL:     url,
-		Directory:       directory,
-		UseCount:        1,
-		LastUsed:        time.Now(),
-		CreatedAt:       time.Now(),
-	}
-
-	return h.db.CreateDirectoryMapping(mapping)
-}
-
-// extractPattern extracts a meaningful pattern from filename for directory mapping
-func extractPattern(filename string) string {
-	filename = strings.ToLower(filename)
-
-	// Get file extension
-	ext := filepath.Ext(filename)
-	if ext != "" {
-		return ext
-	}
-
-	// Extract meaningful keywords
-	keywords := []string{"movie", "film", "season", "episode", "music", "album", "software", "setup", "installer"}
-
-	for _, keyword := range keywords {
-		if strings.Contains(filename, keyword) {
-			return keyword
-		}
-	}
-
-	// Look for season/episode patterns
-	if strings.Contains(filename, "s0") && strings.Contains(filename, "e0") {
-		return "tv_show"
-	}
-
-	return ""
-}
-
-// fuzzyMatchURL returns a score (0-100) for how well two URLs match
-func fuzzyMatchURL(url1, url2 string) int {
-	if url1 == "" || url2 == "" {
-		return 0
-	}
-
-	// Exact match gets highest score
-	if url1 == url2 {
-		return 100
-	}
-
-	// Extract and compare domain names
-	domain1 := extractDomain(url1)
-	domain2 := extractDomain(url2)
-
-	if domain1 != "" && domain2 != "" && domain1 == domain2 {
-		// Same domain gets a good score
-		score := 60
-
-		// Boost score for path similarity
-		path1 := extractPath(url1)
-		path2 := extractPath(url2)
-
-		if path1 != "" && path2 != "" {
-			pathScore := fuzzyMatch(path1, path2)
-			score += (pathScore * 40) / 100 // Add up to 40 more points for path similarity
-		}
-
-		return score
-	}
-
-	// Compare filenames extracted from URLs
-	filename1 := extractFilenameFromURL(url1)
-	filename2 := extractFilenameFromURL(url2)
-
-	if filename1 != "" && filename2 != "" {
-		return fuzzyMatch(filename1, filename2)
-	}
-
-	return 0
-}
-
-// extractDomain extracts the domain from a URL
-func extractDomain(url string) string {
-	// Remove protocol
-	if strings.HasPrefix(url, "http://") {
-		url = url[7:]
-	} else if strings.HasPrefix(url, "https://") {
-		url = url[8:]
-	}
-
-	// Extract domain (everything before first slash)
-	parts := strings.Split(url, "/")
-	if len(parts) > 0 {
-		domain := parts[0]
-		// Remove port if present
-		if colonIndex := strings.Index(domain, ":"); colonIndex != -1 {
-			domain = domain[:colonIndex]
-		}
-		return strings.ToLower(domain)
-	}
-
-	return ""
-}
-
-// extractPath extracts the path from a URL
-func extractPath(url string) string {
-	// Remove protocol
-	if strings.HasPrefix(url, "http://") {
-		url = url[7:]
-	} else if strings.HasPrefix(url, "https://") {
-		url = url[8:]
-	}
-
-	// Find first slash to get path
-	slashIndex := strings.Index(url, "/")
-	if slashIndex != -1 && slashIndex < len(url)-1 {
-		path := url[slashIndex+1:]
-		// Remove query parameters
-		if questionIndex := strings.Index(path, "?"); questionIndex != -1 {
-			path = path[:questionIndex]
-		}
-		return strings.ToLower(path)
-	}
-
-	return ""
+// learnDirectory learns the file's tokens for the directory it was saved to
+func (h *Handlers) learnDirectory(filename, url, directory string) error {
+	return h.db.LearnDirectoryTokens(h.ownerID(), fuzzy.Tokens(filename, url), directory)
 }
 
 // extractFilenameFromURL extracts filename from URL
@@ -848,6 +500,11 @@ func extractFilenameFromURL(url string) string {
 func (h *Handlers) BrowseFolders(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
 	path := r.URL.Query().Get("path")
 	if path == "" {
 		path = "/"
@@ -886,6 +543,11 @@ func (h *Handlers) BrowseFolders(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) CreateFolder(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
 	if r.Method != http.MethodPost {
 		http.Error(w, `{"error": "Method not allowed"}`, http.StatusMethodNotAllowed)
 		return
@@ -941,7 +603,85 @@ func (h *Handlers) CreateFolder(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) Settings(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
-	component := templates.Base("Settings", templates.Settings())
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	tokens, err := h.db.ListAPITokensByOwner(h.downloadOwner())
+	if err != nil {
+		h.logger.Error("Failed to list API tokens", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	webhooks, err := h.listWebhooks()
+	if err != nil {
+		h.logger.Error("Failed to list webhooks", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	data := templates.SettingsData{APITokens: tokens, Webhooks: webhooks}
+
+	if h.isAdmin() {
+		if h.settings != nil {
+			data.ManageGeneral = true
+			data.General = h.generalSettings()
+		}
+		if h.settings != nil && h.retention != nil {
+			data.ManageHistory = true
+			data.History = h.historySettings()
+		}
+
+		data.ManageMediaServers = true
+		data.MediaServers, err = h.db.ListMediaServers()
+		if err != nil {
+			h.logger.Error("Failed to list media servers", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		data.ManageHooks = true
+		data.Hooks, err = h.hookSettings()
+		if err != nil {
+			h.logger.Error("Failed to list hooks", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		data.ManageCategories = true
+		data.Categories, err = h.categorySettings()
+		if err != nil {
+			h.logger.Error("Failed to list categories", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		data.ManageRules = true
+		data.Rules, err = h.db.ListRules()
+		if err != nil {
+			h.logger.Error("Failed to list rules", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		data.ManageBackups = true
+		data.Backups = h.backupSettings()
+	}
+
+	// User management is only available once accounts exist, i.e. with authentication enabled
+	if h.user != nil && h.user.IsAdmin() {
+		data.ManageUsers = true
+		data.Users, err = h.db.ListUsers()
+		if err != nil {
+			h.logger.Error("Failed to list users", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	component := templates.Base("Settings", templates.Settings(data))
 	if err := component.Render(r.Context(), w); err != nil {
 		h.logger.Error("Failed to render settings template", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -953,23 +693,31 @@ func (h *Handlers) Settings(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) SearchDownloads(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
 	if err := r.ParseForm(); err != nil {
 		h.logger.Error("Failed to parse search form", "error", err)
 		http.Error(w, "Bad request", http.StatusBadRequest)
 		return
 	}
 
-	searchTerm := r.FormValue("search")
-	statusFilters := r.Form["status"] // Get array of selected statuses
+	filter, err := h.parseDownloadFilter(r.Form)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	sortOrder := r.FormValue("sort")
 	if sortOrder == "" {
 		sortOrder = "desc"
 	}
 
 	// Get filtered downloads from database
-	downloads, err := h.db.SearchDownloads(searchTerm, statusFilters, sortOrder, 50, 0)
+	downloads, err := h.db.FilterDownloads(h.downloadOwner(), filter, sortOrder, 50, 0)
 	if err != nil {
-		h.logger.Error("Failed to search downloads", "error", err, "search", searchTerm, "status", statusFilters, "sort", sortOrder)
+		h.logger.Error("Failed to search downloads", "error", err, "search", filter.Search, "status", filter.Statuses, "sort", sortOrder)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
@@ -997,6 +745,11 @@ func (h *Handlers) SearchDownloads(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) RetryDownload(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
 	// Extract download ID from URL path parameter
 	idStr := r.PathValue("id")
 	downloadID, err := strconv.ParseInt(idStr, 10, 64)
@@ -1013,36 +766,18 @@ func (h *Handlers) RetryDownload(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Download not found", http.StatusNotFound)
 		return
 	}
-
-	// Check if download can be retried
-	if download.Status != models.StatusFailed {
-		h.logger.Warn("Attempted to retry non-failed download", "download_id", downloadID, "status", download.Status)
-		http.Error(w, "Download is not in failed state", http.StatusBadRequest)
-		return
-	}
-
-	if download.RetryCount >= 5 {
-		h.logger.Warn("Attempted to retry download that has exceeded retry limit", "download_id", downloadID, "retry_count", download.RetryCount)
-		http.Error(w, "Download has exceeded retry limit", http.StatusBadRequest)
+	if !h.canAccess(download) {
+		http.Error(w, "Download not found", http.StatusNotFound)
 		return
 	}
 
-	// Reset download status and queue it
-	download.Status = models.StatusPending
-	download.ErrorMessage = ""
-	download.UpdatedAt = time.Now()
-
-	if err := h.db.UpdateDownload(download); err != nil {
-		h.logger.Error("Failed to update download for retry", "download_id", downloadID, "error", err)
-		http.Error(w, "Failed to update download", http.StatusInternalServerError)
+	if err := h.retryDownload(download); err != nil {
+		status, message := actionErrorStatus(err, "Failed to update download")
+		h.logger.Warn("Failed to retry download", "download_id", downloadID, "status", download.Status, "retry_count", download.RetryCount, "error", err)
+		http.Error(w, message, status)
 		return
 	}
 
-	// Queue the download for processing
-	h.downloadWorker.QueueDownload(downloadID)
-
-	h.logger.Info("Download queued for retry", "download_id", downloadID, "retry_count", download.RetryCount)
-
 	// Render the updated download item
 	component := templates.DownloadItem(download)
 	if err := component.Render(r.Context(), w); err != nil {
@@ -1056,6 +791,11 @@ func (h *Handlers) RetryDownload(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) GetDirectorySuggestion(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/plain")
 
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
 	// Try both query parameter and form data
 	var url string
 	if r.Method == "POST" {
@@ -1082,8 +822,8 @@ func (h *Handlers) GetDirectorySuggestion(w http.ResponseWriter, r *http.Request
 	}
 
 	if url == "" {
-		// Return base path if no URL provided
-		if _, err := w.Write([]byte(h.folderService.BasePath)); err != nil {
+		// Return the default directory if no URL provided
+		if _, err := w.Write([]byte(h.defaultDirectory())); err != nil {
 			h.logger.Error("Failed to write response", "error", err)
 		}
 		return
@@ -1147,6 +887,11 @@ func (h *Handlers) CreateTestFailedDownload(w http.ResponseWriter, r *http.Reque
 func (h *Handlers) PauseDownload(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
 	// Extract download ID from URL path parameter
 	idStr := r.PathValue("id")
 	downloadID, err := strconv.ParseInt(idStr, 10, 64)
@@ -1156,8 +901,13 @@ func (h *Handlers) PauseDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.canAccessID(downloadID) {
+		http.Error(w, "Download not found", http.StatusNotFound)
+		return
+	}
+
 	// Pause the download
-	if err := h.downloadWorker.PauseCurrentDownload(); err != nil {
+	if err := h.downloadWorker.PauseDownload(downloadID); err != nil {
 		h.logger.Error("Failed to pause download", "download_id", downloadID, "error", err)
 		http.Error(w, "Failed to pause download", http.StatusInternalServerError)
 		return
@@ -1186,6 +936,11 @@ func (h *Handlers) PauseDownload(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) ResumeDownload(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
 	// Extract download ID from URL path parameter
 	idStr := r.PathValue("id")
 	downloadID, err := strconv.ParseInt(idStr, 10, 64)
@@ -1195,6 +950,11 @@ func (h *Handlers) ResumeDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !h.canAccessID(downloadID) {
+		http.Error(w, "Download not found", http.StatusNotFound)
+		return
+	}
+
 	// Resume the download
 	if err := h.downloadWorker.ResumeDownload(downloadID); err != nil {
 		h.logger.Error("Failed to resume download", "download_id", downloadID, "error", err)
@@ -1225,6 +985,11 @@ func (h *Handlers) ResumeDownload(w http.ResponseWriter, r *http.Request) {
 func (h *Handlers) DeleteDownload(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
 	// Extract download ID from URL path parameter
 	idStr := r.PathValue("id")
 	downloadID, err := strconv.ParseInt(idStr, 10, 64)
@@ -1241,6 +1006,25 @@ func (h *Handlers) DeleteDownload(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Download not found", http.StatusNotFound)
 		return
 	}
+	if !h.canAccess(download) {
+		http.Error(w, "Download not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.deleteDownload(download); err != nil {
+		h.logger.Error("Failed to delete download", "download_id", downloadID, "error", err)
+		http.Error(w, "Failed to delete download", http.StatusInternalServerError)
+		return
+	}
+
+	// Return empty response to remove the item from DOM
+	w.WriteHeader(http.StatusOK)
+}
+
+// deleteDownload cancels a download if it is in progress and removes its record and
+// temporary file, keeping any finished file
+func (h *Handlers) deleteDownload(download *models.Download) error {
+	downloadID := download.ID
 
 	// Check if this was an active download that needs to trigger queue processing
 	wasActive := download.Status == models.StatusPending ||
@@ -1267,9 +1051,7 @@ func (h *Handlers) DeleteDownload(w http.ResponseWriter, r *http.Request) {
 
 	// Delete from database (this will remove the history record)
 	if err := h.db.DeleteDownload(downloadID); err != nil {
-		h.logger.Error("Failed to delete download", "download_id", downloadID, "error", err)
-		http.Error(w, "Failed to delete download", http.StatusInternalServerError)
-		return
+		return err
 	}
 
 	// Clean up temporary file if it exists (but keep final file)
@@ -1293,151 +1075,96 @@ func (h *Handlers) DeleteDownload(w http.ResponseWriter, r *http.Request) {
 		h.queueNextPendingDownload()
 	}
 
-	// Return empty response to remove the item from DOM
-	w.WriteHeader(http.StatusOK)
+	return nil
 }
 
-// ensureUniqueFilename checks if a file exists and generates a unique filename if needed
-func (h *Handlers) ensureUniqueFilename(filename, directory string) string {
-	originalName := filename
-	counter := 1
-
-	for {
-		// Check if file exists at the target location
-		fullPath := filepath.Join(directory, filename)
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			// File doesn't exist, we can use this name
-			break
-		}
-
-		// File exists, generate a new name
-		ext := filepath.Ext(originalName)
-		nameWithoutExt := strings.TrimSuffix(originalName, ext)
-		filename = fmt.Sprintf("%s(%d)%s", nameWithoutExt, counter, ext)
-		counter++
-
-		// Safety check to prevent infinite loop
-		if counter > 1000 {
-			h.logger.Warn("Too many filename conflicts, using timestamp", "original", originalName, "directory", directory)
-			timestamp := time.Now().Unix()
-			filename = fmt.Sprintf("%s_%d%s", nameWithoutExt, timestamp, ext)
-			break
-		}
+// retryDownload resets a failed download to pending and queues it again. A retry by hand
+// starts over, so its retry count is reset like a bulk retry's.
+func (h *Handlers) retryDownload(download *models.Download) error {
+	if download.Status != models.StatusFailed {
+		return &actionError{message: "Download is not in failed state"}
 	}
 
-	if filename != originalName {
-		h.logger.Info("Generated unique filename", "original", originalName, "unique", filename, "directory", directory)
+	// Reset download status and queue it
+	download.Status = models.StatusPending
+	download.ErrorMessage = ""
+	download.RetryCount = 0
+	download.UpdatedAt = time.Now()
+
+	if err := h.db.UpdateDownload(download); err != nil {
+		return fmt.Errorf("failed to update download for retry: %w", err)
 	}
 
-	return filename
+	// Queue the download for processing
+	h.downloadWorker.QueueDownload(download.ID)
+
+	h.logger.Info("Download queued for retry", "download_id", download.ID)
+	return nil
 }
 
-// parseMultipleURLs parses a string containing multiple URLs separated by whitespace or newlines
-func (h *Handlers) parseMultipleURLs(input string) []string {
-	var urls []string
+// actionError is returned when a download can't be changed the way a request asked.
+// Its message is safe to show to the user.
+type actionError struct {
+	message string
+}
 
-	// Split on both newlines and spaces, then filter for HTTP URLs
-	lines := strings.Fields(strings.ReplaceAll(input, "\n", " "))
+func (e *actionError) Error() string {
+	return e.message
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" && (strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://")) {
-			urls = append(urls, line)
-		}
+// actionErrorStatus returns the response status and message for an error from a
+// download action, using fallback as the message for internal errors
+func actionErrorStatus(err error, fallback string) (int, string) {
+	var actionErr *actionError
+	if errors.As(err, &actionErr) {
+		return http.StatusBadRequest, actionErr.message
 	}
+	return http.StatusInternalServerError, fallback
+}
 
-	return urls
+// ensureUniqueFilename checks if a file exists and generates a unique filename if needed
+func (h *Handlers) ensureUniqueFilename(filename, directory string) string {
+	return submit.UniqueFilename(filename, directory)
+}
+
+// parseMultipleURLs parses a string containing multiple URLs separated by whitespace or newlines
+func (h *Handlers) parseMultipleURLs(input string) []string {
+	return submit.ParseURLs(input)
 }
 
 // isArchiveFile checks if a filename is an archive that should be extracted
 func (h *Handlers) isArchiveFile(filename string) bool {
-	ext := strings.ToLower(filepath.Ext(filename))
-	lowerFilename := strings.ToLower(filename)
-
-	// For RAR files, be more selective about multi-part archives
-	if ext == ".rar" {
-		// If it's a multi-part RAR, only mark the first part as an archive
-		if strings.Contains(lowerFilename, ".part") {
-			return strings.Contains(lowerFilename, ".part1.rar") ||
-				strings.Contains(lowerFilename, ".part01.rar") ||
-				strings.Contains(lowerFilename, ".part001.rar")
-		}
-		// Single RAR files are archives
-		return true
-	}
-
-	// Other archive formats
-	archiveExts := []string{".zip", ".7z", ".tar", ".gz", ".bz2", ".xz"}
-	for _, archiveExt := range archiveExts {
-		if ext == archiveExt {
-			return true
-		}
-	}
-
-	// Check for compound extensions like .tar.gz
-	if strings.HasSuffix(lowerFilename, ".tar.gz") ||
-		strings.HasSuffix(lowerFilename, ".tar.bz2") ||
-		strings.HasSuffix(lowerFilename, ".tar.xz") {
-		return true
-	}
-
-	return false
+	return submit.IsArchiveFile(filename)
 }
 
-// getSmartDirectorySuggestion analyzes URL to suggest appropriate directory
+// getSmartDirectorySuggestion suggests the folder under basePath of the category whose
+// keywords best match the URL, or the default directory when none do. Matching
+// extensions count for more than other keywords.
 func (h *Handlers) getSmartDirectorySuggestion(url, basePath string) string {
 	// Extract filename from URL
 	filename := extractFilenameFromURL(url)
 
 	if filename == "" {
-		return basePath
+		return h.defaultDirectory()
 	}
 
 	// Convert to lowercase for analysis
 	filename = strings.ToLower(filename)
 	url = strings.ToLower(url)
 
-	// Extract domain for additional analysis
-	domain := extractDomain(url)
-
-	// Define category mappings
-	categories := map[string][]string{
-		"Movies": {
-			".mp4", ".mkv", ".avi", ".mov", ".wmv", ".flv", ".webm", ".m4v",
-			"movie", "film", "cinema", "dvdrip", "bluray", "hdtv", "webrip",
-		},
-		"TV Shows": {
-			"season", "episode", "s01", "s02", "s03", "s04", "s05", "e01", "e02",
-			"series", "tv", "show", "hdtv", "webrip",
-		},
-		"Music": {
-			".mp3", ".flac", ".wav", ".aac", ".ogg", ".m4a", ".wma",
-			"album", "music", "song", "track", "artist", "band",
-		},
-		"Software": {
-			".exe", ".msi", ".dmg", ".pkg", ".deb", ".rpm", ".appimage",
-			"software", "program", "app", "installer", "setup", "portable",
-		},
-		"Games": {
-			"game", "steam", "gog", "origin", "epic", "uplay", "crack", "repack",
-			".iso", "setup.exe",
-		},
-		"Books": {
-			".pdf", ".epub", ".mobi", ".azw", ".azw3", ".djvu",
-			"book", "ebook", "novel", "manual", "guide",
-		},
-		"Archives": {
-			".zip", ".rar", ".7z", ".tar", ".gz", ".bz2", ".xz",
-		},
+	categories, err := h.db.ListCategories()
+	if err != nil {
+		h.logger.Error("Failed to list categories for suggestion", "error", err)
+		return h.defaultDirectory()
 	}
 
 	// Score each category
-	bestCategory := ""
+	var bestCategory *models.Category
 	bestScore := 0
 
-	for category, keywords := range categories {
+	for _, category := range categories {
 		score := 0
-		for _, keyword := range keywords {
+		for _, keyword := range category.KeywordList() {
 			if strings.Contains(filename, keyword) || strings.Contains(url, keyword) {
 				if strings.HasPrefix(keyword, ".") {
 					// File extension gets higher score
@@ -1449,20 +1176,6 @@ func (h *Handlers) getSmartDirectorySuggestion(url, basePath string) string {
 			}
 		}
 
-		// Domain-specific scoring
-		if domain != "" {
-			switch {
-			case strings.Contains(domain, "torrent") && category == "Movies":
-				score += 3
-			case strings.Contains(domain, "music") && category == "Music":
-				score += 3
-			case strings.Contains(domain, "software") && category == "Software":
-				score += 3
-			case strings.Contains(domain, "game") && category == "Games":
-				score += 3
-			}
-		}
-
 		if score > bestScore {
 			bestScore = score
 			bestCategory = category
@@ -1470,61 +1183,40 @@ func (h *Handlers) getSmartDirectorySuggestion(url, basePath string) string {
 	}
 
 	// Return suggested directory path
-	if bestCategory != "" && bestScore >= 5 {
-		return filepath.Join(basePath, bestCategory)
+	if bestCategory != nil && bestScore >= 5 {
+		return filepath.Join(basePath, bestCategory.DirectoryName())
 	}
 
-	// Fallback to base path
-	return basePath
+	// Fallback to the default directory
+	return h.defaultDirectory()
 }
 
-// getDirectorySuggestionsFromFilename gets directory suggestions using a pre-fetched filename
-func (h *Handlers) getDirectorySuggestionsFromFilename(filename string) string {
-	// Get directory mappings from database
-	mappings, err := h.db.GetDirectoryMappings()
-	if err != nil {
-		h.logger.Error("Failed to get directory mappings", "error", err)
-		return h.folderService.BasePath
-	}
-
-	// Use fuzzy matcher to suggest directory based on filename
-	matcher := fuzzy.NewMatcher()
-	suggestedDir := matcher.SuggestDirectory(filename, mappings)
-
-	// If no suggestion from fuzzy matcher, return base path
-	if suggestedDir == "" {
-		return h.folderService.BasePath
+// getDirectorySuggestionsFromFilename gets directory suggestions using a pre-fetched
+// filename from the learned tokens, or the default directory
+func (h *Handlers) getDirectorySuggestionsFromFilename(filename, url string) string {
+	if ranked := h.rankDirectories(filename, url, 1); len(ranked) > 0 {
+		return ranked[0].Directory
 	}
-
-	return suggestedDir
+	return h.defaultDirectory()
 }
 
 // getDirectorySuggestionsForFilename gets directory suggestions by first fetching filename from AllDebrid API
 func (h *Handlers) getDirectorySuggestionsForFilename(ctx context.Context, url string) string {
-	// Check cache first
-	h.cacheMutex.RLock()
-	if cached, exists := h.urlCache[url]; exists {
-		h.cacheMutex.RUnlock()
-		// Use cached result
-		if cached.Filename != "" {
-			return h.getDirectorySuggestionsFromFilename(cached.Filename)
-		}
-		return h.getDirectorySuggestionsForURL(url)
-	}
-	h.cacheMutex.RUnlock()
-
-	// Not in cache, unrestrict the URL using AllDebrid API
-	result, err := h.allDebridClient.UnrestrictLink(ctx, url)
+	// Unrestrict the URL using AllDebrid API; the result is cached for the submission
+	result, err := h.submitService.Unrestrict(ctx, url)
 	if err != nil {
 		h.logger.Debug("Failed to unrestrict link for filename suggestion", "error", err, "url", url)
-		// Fall back to URL-based suggestions if API call fails
+		// Routing rules come first, then URL-based suggestions if API call fails
+		if directory, rule := h.ruleDirectory(rules.Link{URL: url, Filename: extractFilenameFromURL(url)}); rule != nil {
+			return directory
+		}
 		return h.getDirectorySuggestionsForURL(url)
 	}
 
-	// Cache the result for later use
-	h.cacheMutex.Lock()
-	h.urlCache[url] = result
-	h.cacheMutex.Unlock()
+	// Routing rules are checked before the learned tokens
+	if directory, rule := h.ruleDirectory(rules.Link{URL: url, Filename: result.Filename, Size: result.FileSize}); rule != nil {
+		return directory
+	}
 
 	// Use the filename from AllDebrid for fuzzy matching
 	filename := result.Filename
@@ -1534,7 +1226,7 @@ func (h *Handlers) getDirectorySuggestionsForFilename(ctx context.Context, url s
 	}
 
 	// Use the new helper function that doesn't require unrestriction
-	return h.getDirectorySuggestionsFromFilename(filename)
+	return h.getDirectorySuggestionsFromFilename(filename, url)
 }
 
 // queueNextPendingDownload checks for pending downloads and queues the next one
@@ -1567,8 +1259,13 @@ func (h *Handlers) queueNextPendingDownload() {
 func (h *Handlers) GetDownloadStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
 	// Get download statistics from database
-	stats, err := h.db.GetDownloadStats()
+	stats, err := h.db.GetDownloadStatsByOwner(h.downloadOwner())
 	if err != nil {
 		h.logger.Error("Failed to get download stats", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -1599,9 +1296,14 @@ func (h *Handlers) GetDownloadStats(w http.ResponseWriter, r *http.Request) {
 // UpdateDownloadProgress handles fast progress updates for active downloads only
 func (h *Handlers) UpdateDownloadProgress(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
 	
 	// Get only actively downloading downloads
-	downloads, err := h.db.ListDownloads(10, 0)
+	downloads, err := h.db.ListDownloadsByOwner(h.downloadOwner(), 10, 0)
 	if err != nil {
 		h.logger.Error("Failed to get downloads for progress update", "error", err)
 		return
@@ -400,29 +400,6 @@ func TestHandlers_CurrentDownloadsDatabaseError(t *testing.T) {
 	require.Contains(t, w.Body.String(), "Internal server error")
 }
 
-func TestFuzzyMatch(t *testing.T) {
-	tests := []struct {
-		name      string
-		filename  string
-		pattern   string
-		wantScore int
-	}{
-		{"exact match", "movie.mp4", "movie.mp4", 100},
-		{"substring match", "action.movie.mp4", "movie", 100},
-		{"extension match", "file.mp4", "test.mp4", 80},
-		{"word match", "action_movie_2023.mp4", "movie_action", 60},
-		{"no match", "document.pdf", "video.mp4", 0},
-		{"empty pattern", "test.mp4", "", 0},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			score := fuzzyMatch(tt.filename, tt.pattern)
-			require.Equal(t, tt.wantScore, score)
-		})
-	}
-}
-
 func TestContentBasedScore(t *testing.T) {
 	db, err := database.New(":memory:")
 	require.NoError(t, err)
@@ -455,28 +432,6 @@ func TestContentBasedScore(t *testing.T) {
 	}
 }
 
-func TestExtractPattern(t *testing.T) {
-	tests := []struct {
-		name     string
-		filename string
-		want     string
-	}{
-		{"video extension", "movie.mp4", ".mp4"},
-		{"audio extension", "song.mp3", ".mp3"},
-		{"movie keyword", "action_movie_2023", "movie"},
-		{"tv show pattern", "show_s01e01", "tv_show"},
-		{"software keyword", "program_setup", "setup"},
-		{"no pattern", "randomfile", ""},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := extractPattern(tt.filename)
-			require.Equal(t, tt.want, result)
-		})
-	}
-}
-
 func TestGetDirectorySuggestions(t *testing.T) {
 	db, err := database.New(":memory:")
 	require.NoError(t, err)
@@ -499,7 +454,7 @@ func TestGetDirectorySuggestions(t *testing.T) {
 	require.NotEmpty(t, suggestedDir)
 }
 
-func TestCreateOrUpdateDirectoryMapping(t *testing.T) {
+func TestLearnDirectory(t *testing.T) {
 	db, err := database.New(":memory:")
 	require.NoError(t, err)
 	defer db.Close()
@@ -508,26 +463,20 @@ func TestCreateOrUpdateDirectoryMapping(t *testing.T) {
 	worker := downloader.NewWorker(db, "/tmp/test")
 	handlers := NewHandlers(db, client, "/tmp/test", worker)
 
-	// Test creating new mapping
-	err = handlers.createOrUpdateDirectoryMapping("movie.mp4", "https://example.com/movie.mp4", "/downloads/movies")
-	require.NoError(t, err)
+	require.NoError(t, handlers.learnDirectory("movie.mp4", "https://example.com/movie.mp4", "/tmp/test/movies"))
+	require.NoError(t, handlers.learnDirectory("another.mp4", "https://example.com/another.mp4", "/tmp/test/movies"))
 
-	// Verify mapping was created
-	mappings, err := db.GetDirectoryMappings()
-	require.NoError(t, err)
-	require.Len(t, mappings, 1)
-	require.Equal(t, ".mp4", mappings[0].FilenamePattern)
-	require.Equal(t, "/downloads/movies", mappings[0].Directory)
-
-	// Test updating existing mapping
-	err = handlers.createOrUpdateDirectoryMapping("another.mp4", "https://example.com/another.mp4", "/downloads/movies")
+	// The tokens are learned; no pattern mapping is recorded
+	learned, err := db.GetDirectoryTokens(database.AllOwners, []string{"ext:mp4", "word:movie", "host:example.com"})
 	require.NoError(t, err)
+	require.Len(t, learned, 3)
+	for _, token := range learned {
+		require.Equal(t, "/tmp/test/movies", token.Directory)
+	}
 
-	// Verify use count was updated
-	mappings, err = db.GetDirectoryMappings()
+	mappings, err := db.GetDirectoryMappings()
 	require.NoError(t, err)
-	require.Len(t, mappings, 1)
-	require.Equal(t, 2, mappings[0].UseCount)
+	require.Empty(t, mappings)
 }
 
 func TestBrowseFolders(t *testing.T) {
@@ -741,27 +690,31 @@ func TestRetryDownload(t *testing.T) {
 
 	require.Equal(t, http.StatusNotFound, w.Code)
 
-	// Create download with too many retries
+	// Create download past its automatic retries
 	download2 := &models.Download{
 		OriginalURL: "https://example.com/file2.zip",
 		Filename:    "file2.zip",
 		Directory:   "/downloads",
 		Status:      models.StatusFailed,
-		RetryCount:  6, // Exceeds limit
+		RetryCount:  6,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 	err = db.CreateDownload(download2)
 	require.NoError(t, err)
 
-	// Test retry with exceeded limit
+	// Test retry starts over however often it was retried
 	req = httptest.NewRequest("POST", "/downloads/2/retry", nil)
 	req.SetPathValue("id", "2")
 	w = httptest.NewRecorder()
 
 	handlers.RetryDownload(w, req)
 
-	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Equal(t, http.StatusOK, w.Code)
+	retried, err := db.GetDownload(download2.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.StatusPending, retried.Status)
+	require.Zero(t, retried.RetryCount)
 
 	// Create non-failed download
 	download3 := &models.Download{
@@ -1061,89 +1014,36 @@ func TestEnsureUniqueFilename(t *testing.T) {
 	require.NotNil(t, handlers.ensureUniqueFilename)
 }
 
-func TestFuzzyMatchURL(t *testing.T) {
-	tests := []struct {
-		name     string
-		url1     string
-		url2     string
-		expected int
-	}{
-		{
-			name:     "exact match",
-			url1:     "https://example.com/file.zip",
-			url2:     "https://example.com/file.zip",
-			expected: 100,
-		},
-		{
-			name:     "same domain",
-			url1:     "https://example.com/file1.zip",
-			url2:     "https://example.com/file2.zip",
-			expected: 92, // Base domain score (60) + path similarity score
-		},
-		{
-			name:     "different domains",
-			url1:     "https://example.com/file.zip",
-			url2:     "https://another.com/file.zip",
-			expected: 100, // Same filename
-		},
-		{
-			name:     "empty URLs",
-			url1:     "",
-			url2:     "",
-			expected: 0,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := fuzzyMatchURL(tt.url1, tt.url2)
-			require.Equal(t, tt.expected, result)
-		})
-	}
-}
-
 func TestExtractFunctions(t *testing.T) {
 	tests := []struct {
 		name     string
 		url      string
-		domain   string
-		path     string
 		filename string
 	}{
 		{
 			name:     "complete URL",
 			url:      "https://example.com/path/to/file.zip?param=value",
-			domain:   "example.com",
-			path:     "path/to/file.zip",
 			filename: "file.zip",
 		},
 		{
 			name:     "URL with port",
 			url:      "https://example.com:8080/file.zip",
-			domain:   "example.com",
-			path:     "file.zip",
 			filename: "file.zip",
 		},
 		{
 			name:     "HTTP URL",
 			url:      "http://example.com/file.zip",
-			domain:   "example.com",
-			path:     "file.zip",
 			filename: "file.zip",
 		},
 		{
 			name:     "root path",
 			url:      "https://example.com/",
-			domain:   "example.com",
-			path:     "",
 			filename: "",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			require.Equal(t, tt.domain, extractDomain(tt.url))
-			require.Equal(t, tt.path, extractPath(tt.url))
 			require.Equal(t, tt.filename, extractFilenameFromURL(tt.url))
 		})
 	}
@@ -1158,11 +1058,24 @@ func TestGetSmartDirectorySuggestion(t *testing.T) {
 	worker := downloader.NewWorker(db, "/tmp/test")
 	handlers := NewHandlers(db, client, "/tmp/test", worker)
 
+	for _, category := range models.DefaultCategories() {
+		require.NoError(t, db.CreateCategory(category))
+	}
+	tv, err := db.GetCategoryByName("TV Shows")
+	require.NoError(t, err)
+	tv.Directory = "tv"
+	require.NoError(t, db.UpdateCategory(tv))
+
 	tests := []struct {
 		name     string
 		url      string
 		expected string
 	}{
+		{
+			name:     "tv episode in category folder",
+			url:      "https://example.com/show.s01e02.webm",
+			expected: "/tmp/test/tv",
+		},
 		{
 			name:     "movie file",
 			url:      "https://example.com/action.movie.2023.mp4",
@@ -1288,40 +1201,12 @@ func TestGetDirectorySuggestionsForURL(t *testing.T) {
 	suggestedDir := handlers.getDirectorySuggestionsForURL("https://example.com/movie.mp4")
 	require.NotEmpty(t, suggestedDir)
 
-	// Create some directory mappings first
-	mapping := &models.DirectoryMapping{
-		FilenamePattern: ".mp4",
-		OriginalURL:     "https://example.com/movie.mp4",
-		Directory:       "/downloads/movies",
-		UseCount:        1,
-		LastUsed:        time.Now(),
-		CreatedAt:       time.Now(),
-	}
-	err = db.CreateDirectoryMapping(mapping)
-	require.NoError(t, err)
+	// Learn a directory for a similar link first
+	require.NoError(t, handlers.learnDirectory("movie.mp4", "https://example.com/movie.mp4", "/tmp/test/movies"))
 
 	// Test with URL matching
 	suggestedDir = handlers.getDirectorySuggestionsForURL("https://example.com/movie2.mp4")
-	require.NotEmpty(t, suggestedDir)
-}
-
-func TestCreateOrUpdateDirectoryMappingWithNoPattern(t *testing.T) {
-	db, err := database.New(":memory:")
-	require.NoError(t, err)
-	defer db.Close()
-
-	client := alldebrid.New("test-key")
-	worker := downloader.NewWorker(db, "/tmp/test")
-	handlers := NewHandlers(db, client, "/tmp/test", worker)
-
-	// Test with filename that produces no pattern
-	err = handlers.createOrUpdateDirectoryMapping("noextension", "https://example.com/noextension", "/downloads")
-	require.NoError(t, err)
-
-	// Verify no mapping was created
-	mappings, err := db.GetDirectoryMappings()
-	require.NoError(t, err)
-	require.Len(t, mappings, 0)
+	require.Equal(t, "/tmp/test/movies", suggestedDir)
 }
 
 func TestBrowseFoldersError(t *testing.T) {
@@ -1443,7 +1328,7 @@ func TestCreateTestFailedDownload(t *testing.T) {
 	require.Equal(t, models.StatusFailed, downloads[0].Status)
 }
 
-func TestGetDirectorySuggestionWithDatabaseMappings(t *testing.T) {
+func TestGetDirectorySuggestionWithLearnedDirectory(t *testing.T) {
 	db, err := database.New(":memory:")
 	require.NoError(t, err)
 	defer db.Close()
@@ -1452,28 +1337,17 @@ func TestGetDirectorySuggestionWithDatabaseMappings(t *testing.T) {
 	worker := downloader.NewWorker(db, "/tmp/test")
 	handlers := NewHandlers(db, client, "/tmp/test", worker)
 
-	// Create directory mapping
-	mapping := &models.DirectoryMapping{
-		FilenamePattern: ".mp4",
-		OriginalURL:     "https://example.com/movie.mp4",
-		Directory:       "/downloads/movies",
-		UseCount:        1,
-		LastUsed:        time.Now(),
-		CreatedAt:       time.Now(),
-	}
-	err = db.CreateDirectoryMapping(mapping)
-	require.NoError(t, err)
+	require.NoError(t, handlers.learnDirectory("movie.mp4", "https://example.com/movie.mp4", "/tmp/test/movies"))
 
-	// Test GET request with matching pattern
+	// Test GET request with a similar link
 	req := httptest.NewRequest("GET", "/api/directory-suggestion?url=https://example.com/action.mp4", nil)
 	w := httptest.NewRecorder()
 
 	handlers.GetDirectorySuggestion(w, req)
 
 	require.Equal(t, http.StatusOK, w.Code)
-	// Should return the mapping directory since it matches the pattern
-	bodyString := w.Body.String()
-	require.NotEmpty(t, bodyString)
+	// Should return the learned directory since the extension and host match
+	require.Equal(t, "/tmp/test/movies", w.Body.String())
 }
 
 func TestSubmitDownloadWithGroupCreationError(t *testing.T) {
@@ -1505,7 +1379,7 @@ func TestSubmitDownloadWithGroupCreationError(t *testing.T) {
 	require.Contains(t, w.Body.String(), "Failed to create download group")
 }
 
-func TestGetDirectorySuggestionsWithMixedMappings(t *testing.T) {
+func TestGetDirectorySuggestionsWithMixedDirectories(t *testing.T) {
 	db, err := database.New(":memory:")
 	require.NoError(t, err)
 	defer db.Close()
@@ -1514,39 +1388,19 @@ func TestGetDirectorySuggestionsWithMixedMappings(t *testing.T) {
 	worker := downloader.NewWorker(db, "/tmp/test")
 	handlers := NewHandlers(db, client, "/tmp/test", worker)
 
-	// Create multiple mappings with different scores
-	mappings := []*models.DirectoryMapping{
-		{
-			FilenamePattern: ".mp4",
-			Directory:       "/downloads/movies",
-			UseCount:        3,
-			LastUsed:        time.Now(),
-			CreatedAt:       time.Now(),
-		},
-		{
-			FilenamePattern: "movie",
-			Directory:       "/downloads/films",
-			UseCount:        1,
-			LastUsed:        time.Now(),
-			CreatedAt:       time.Now(),
-		},
-		{
-			FilenamePattern: ".avi",
-			Directory:       "/downloads/videos",
-			UseCount:        2,
-			LastUsed:        time.Now(),
-			CreatedAt:       time.Now(),
-		},
-	}
-
-	for _, mapping := range mappings {
-		err = db.CreateDirectoryMapping(mapping)
-		require.NoError(t, err)
+	// Learn several directories with different evidence
+	for filename, directory := range map[string]string{
+		"action.movie.2023.mp4": "/tmp/test/movies",
+		"other.movie.2021.mp4":  "/tmp/test/movies",
+		"holiday.clip.mp4":      "/tmp/test/videos",
+		"concert.avi":           "/tmp/test/videos",
+	} {
+		require.NoError(t, handlers.learnDirectory(filename, "", directory))
 	}
 
-	// Test with filename that matches multiple patterns
-	suggestedDir := handlers.getDirectorySuggestions("action.movie.2023.mp4")
-	require.NotEmpty(t, suggestedDir)
+	// Test with filename that matches several directories
+	suggestedDir := handlers.getDirectorySuggestions("another.movie.2024.mp4")
+	require.Equal(t, "/tmp/test/movies", suggestedDir)
 }
 
 func TestDeleteDownloadDatabaseError(t *testing.T) {
@@ -1781,7 +1635,7 @@ func TestCreateTestFailedDownloadError(t *testing.T) {
 	require.Contains(t, w.Body.String(), "error")
 }
 
-func TestAdvancedDirectoryMappingScenarios(t *testing.T) {
+func TestAdvancedDirectorySuggestionScenarios(t *testing.T) {
 	db, err := database.New(":memory:")
 	require.NoError(t, err)
 	defer db.Close()
@@ -1790,23 +1644,17 @@ func TestAdvancedDirectoryMappingScenarios(t *testing.T) {
 	worker := downloader.NewWorker(db, "/tmp/test")
 	handlers := NewHandlers(db, client, "/tmp/test", worker)
 
-	// Test getDirectorySuggestionsForURL with URL patterns
-	mapping := &models.DirectoryMapping{
-		FilenamePattern: "",
-		OriginalURL:     "https://example.com/movies/action.mp4",
-		Directory:       "/downloads/movies",
-		UseCount:        1,
-		LastUsed:        time.Now(),
-		CreatedAt:       time.Now(),
-	}
-	err = db.CreateDirectoryMapping(mapping)
-	require.NoError(t, err)
+	// Test getDirectorySuggestionsForURL with a learned link
+	require.NoError(t, handlers.learnDirectory("action.mp4", "https://example.com/movies/action.mp4", "/tmp/test/movies"))
 
 	// Test URL-based matching
 	suggestedDir := handlers.getDirectorySuggestionsForURL("https://example.com/movies/thriller.mp4")
-	require.NotEmpty(t, suggestedDir)
+	require.Equal(t, "/tmp/test/movies", suggestedDir)
 
 	// Test with smart directory suggestion fallback
+	for _, category := range models.DefaultCategories() {
+		require.NoError(t, db.CreateCategory(category))
+	}
 	suggestedDir2 := handlers.getSmartDirectorySuggestion("https://example.com/music/album.mp3", "/tmp/test")
 	require.Contains(t, suggestedDir2, "Music")
 }
@@ -2363,35 +2211,15 @@ func TestHandlers_AdditionalPathCoverage(t *testing.T) {
 	worker := downloader.NewWorker(db, "/tmp/test")
 	handlers := NewHandlers(db, client, "/tmp/test", worker)
 
-	t.Run("getDirectorySuggestionsForURL with complex patterns", func(t *testing.T) {
-		// Create some complex mappings to test more paths
-		mappings := []*models.DirectoryMapping{
-			{
-				FilenamePattern: ".mp4",
-				OriginalURL:     "https://videos.example.com/movie.mp4",
-				Directory:       "/downloads/videos",
-				UseCount:        10,
-				LastUsed:        time.Now(),
-				CreatedAt:       time.Now(),
-			},
-			{
-				FilenamePattern: "",
-				OriginalURL:     "https://videos.example.com/different.avi",
-				Directory:       "/downloads/different",
-				UseCount:        5,
-				LastUsed:        time.Now(),
-				CreatedAt:       time.Now(),
-			},
-		}
-
-		for _, mapping := range mappings {
-			err = db.CreateDirectoryMapping(mapping)
-			require.NoError(t, err)
+	t.Run("getDirectorySuggestionsForURL with several learned links", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			require.NoError(t, handlers.learnDirectory("movie.mp4", "https://videos.example.com/movie.mp4", "/tmp/test/videos"))
 		}
+		require.NoError(t, handlers.learnDirectory("different.avi", "https://videos.example.com/different.avi", "/tmp/test/different"))
 
-		// Test with a URL that should match the domain pattern
+		// Test with a URL that only shares the host
 		suggestedDir := handlers.getDirectorySuggestionsForURL("https://videos.example.com/new-movie.mkv")
-		require.NotEmpty(t, suggestedDir)
+		require.Equal(t, "/tmp/test/videos", suggestedDir)
 	})
 
 	t.Run("ensureUniqueFilename with many conflicts", func(t *testing.T) {
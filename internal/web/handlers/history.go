@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/retention"
+	"debrid-downloader/internal/web/templates"
+	"debrid-downloader/pkg/models"
+)
+
+// historyPreviewSize is how many of the downloads the next cleanup removes are listed
+const historyPreviewSize = 10
+
+// SetRetention sets the history cleanup shown on the settings page. Its section needs
+// the runtime settings too.
+func (h *Handlers) SetRetention(service *retention.Service) {
+	h.retention = service
+}
+
+// UpdateHistorySettings saves how long finished downloads are kept
+func (h *Handlers) UpdateHistorySettings(w http.ResponseWriter, r *http.Request) {
+	if h.settings == nil || h.retention == nil {
+		http.Error(w, "History cleanup is not available", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		h.renderHistory(w, r, "", "invalid form data")
+		return
+	}
+
+	next := h.settings.Current()
+	fields := []struct {
+		name  string
+		value *int
+		label string
+	}{
+		{"completed_days", &next.RetentionDays, "days to keep completed downloads"},
+		{"failed_days", &next.FailedRetentionDays, "days to keep failed downloads"},
+		{"max_rows", &next.MaxHistory, "history size"},
+	}
+	for _, field := range fields {
+		value, err := strconv.Atoi(strings.TrimSpace(r.FormValue(field.name)))
+		if err != nil {
+			h.renderHistory(w, r, "", field.label+" must be a whole number")
+			return
+		}
+		*field.value = value
+	}
+
+	if err := h.settings.Update(next); err != nil {
+		h.logger.Warn("Failed to update history settings", "error", err)
+		h.renderHistory(w, r, "", err.Error())
+		return
+	}
+
+	h.logger.Info("History settings updated", "completed_days", next.RetentionDays, "failed_days", next.FailedRetentionDays, "max_rows", next.MaxHistory)
+	h.renderHistory(w, r, "History settings saved", "")
+}
+
+// RunHistoryCleanup cleans up the history now instead of waiting for the next run
+func (h *Handlers) RunHistoryCleanup(w http.ResponseWriter, r *http.Request) {
+	if h.settings == nil || h.retention == nil {
+		http.Error(w, "History cleanup is not available", http.StatusNotFound)
+		return
+	}
+
+	result, err := h.retention.Run(time.Now())
+	if err != nil {
+		h.logger.Error("Failed to clean up history", "error", err)
+		h.renderHistory(w, r, "", "Failed to clean up history")
+		return
+	}
+
+	message := fmt.Sprintf("Removed %d downloads from the history", result.Removed)
+	if result.Archive != "" {
+		message += ", archived to " + result.Archive
+	}
+	h.renderHistory(w, r, message, "")
+}
+
+// historySettings returns the history cleanup settings and what its next run removes
+func (h *Handlers) historySettings() templates.HistorySettings {
+	current := h.settings.Current()
+	settings := templates.HistorySettings{
+		CompletedDays: current.RetentionDays,
+		FailedDays:    current.FailedRetentionDays,
+		MaxRows:       current.MaxHistory,
+		ArchivePath:   h.retention.ArchivePath(),
+		NextRun:       h.retention.NextRun(),
+	}
+
+	removals, err := h.retention.Preview(settings.NextRun)
+	if err != nil {
+		h.logger.Warn("Failed to preview history cleanup", "error", err)
+		return settings
+	}
+	for _, download := range removals {
+		if download.Status == models.StatusFailed {
+			settings.NextFailed++
+		} else {
+			settings.NextCompleted++
+		}
+	}
+	settings.NextRemovals = removals[:min(len(removals), historyPreviewSize)]
+	return settings
+}
+
+// renderHistory renders the history cleanup section of the settings page
+func (h *Handlers) renderHistory(w http.ResponseWriter, r *http.Request, message, errorMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	if err := templates.HistorySection(h.historySettings(), message, errorMessage).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render history settings", "error", err)
+	}
+}
+
+// PinDownload keeps a download in the history when old downloads are removed
+func (h *Handlers) PinDownload(w http.ResponseWriter, r *http.Request) {
+	h.pinDownload(w, r, true)
+}
+
+// UnpinDownload lets history cleanup remove a download again
+func (h *Handlers) UnpinDownload(w http.ResponseWriter, r *http.Request) {
+	h.pinDownload(w, r, false)
+}
+
+// pinDownload pins or unpins a download and renders it
+func (h *Handlers) pinDownload(w http.ResponseWriter, r *http.Request, pinned bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	downloadID, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid download ID", http.StatusBadRequest)
+		return
+	}
+
+	download, err := h.db.GetDownload(downloadID)
+	if err != nil || !h.canAccess(download) {
+		http.Error(w, "Download not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.db.SetDownloadPinned(download.ID, pinned); err != nil {
+		h.logger.Error("Failed to pin download", "download_id", download.ID, "error", err)
+		http.Error(w, "Failed to update download", http.StatusInternalServerError)
+		return
+	}
+	download.Pinned = pinned
+
+	if err := templates.DownloadItem(download).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render pinned download", "error", err)
+	}
+}
+
+// APIPinDownload keeps a download in the history when old downloads are removed
+func (h *Handlers) APIPinDownload(w http.ResponseWriter, r *http.Request) {
+	h.apiPinDownload(w, r, true)
+}
+
+// APIUnpinDownload lets history cleanup remove a download again
+func (h *Handlers) APIUnpinDownload(w http.ResponseWriter, r *http.Request) {
+	h.apiPinDownload(w, r, false)
+}
+
+// apiPinDownload pins or unpins a download and responds with it
+func (h *Handlers) apiPinDownload(w http.ResponseWriter, r *http.Request, pinned bool) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	download, ok := h.apiDownload(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.SetDownloadPinned(download.ID, pinned); err != nil {
+		h.logger.Error("Failed to pin download", "download_id", download.ID, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to update download")
+		return
+	}
+
+	h.writeUpdatedDownload(w, download.ID)
+}
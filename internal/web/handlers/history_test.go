@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/internal/retention"
+	"debrid-downloader/internal/settings"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlers_HistorySettings(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	defaults := settings.Settings{Concurrency: 1, MaxRetries: 5, RetryBackoff: time.Second, RetentionDays: 60, FailedRetentionDays: 60}
+	require.NoError(t, defaults.Validate())
+	service, err := settings.NewService(db, defaults)
+	require.NoError(t, err)
+	handlers.SetSettings(service)
+
+	history := retention.NewService(db, "")
+	service.Subscribe(func(s settings.Settings) { history.SetPolicy(s.HistoryPolicy()) })
+	handlers.SetRetention(history)
+
+	finished := time.Now().Add(-20 * 24 * time.Hour)
+	for _, name := range []string{"old.mkv", "pinned.mkv"} {
+		download := &models.Download{
+			OriginalURL: "https://example.com/" + name,
+			Filename:    name,
+			Directory:   "/downloads",
+			Status:      models.StatusCompleted,
+			CreatedAt:   finished,
+			UpdatedAt:   finished,
+			CompletedAt: &finished,
+		}
+		require.NoError(t, db.CreateDownload(download))
+		if name == "pinned.mkv" {
+			require.NoError(t, db.SetDownloadPinned(download.ID, true))
+		}
+	}
+
+	// The section is shown on the settings page with nothing to remove yet
+	w := httptest.NewRecorder()
+	handlers.Settings(w, httptest.NewRequest("GET", "/settings", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `id="history"`)
+	require.Contains(t, w.Body.String(), "nothing to remove")
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/settings/history", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handlers.UpdateHistorySettings(w, req)
+		return w
+	}
+
+	// Shorter retention previews the downloads the next run removes
+	w = post(url.Values{"completed_days": {"10"}, "failed_days": {"5"}, "max_rows": {"0"}})
+	require.Contains(t, w.Body.String(), "History settings saved")
+	require.Contains(t, w.Body.String(), "removes 1 completed and 0 failed downloads")
+	require.Contains(t, w.Body.String(), "old.mkv")
+	require.NotContains(t, w.Body.String(), "pinned.mkv")
+	require.Equal(t, 10, service.Current().RetentionDays)
+	require.Equal(t, 5, service.Current().FailedRetentionDays)
+
+	w = post(url.Values{"completed_days": {"10"}, "failed_days": {"0"}, "max_rows": {"0"}})
+	require.Contains(t, w.Body.String(), "failed downloads must be kept for at least 1 day")
+	w = post(url.Values{"completed_days": {"ten"}, "failed_days": {"5"}, "max_rows": {"0"}})
+	require.Contains(t, w.Body.String(), "days to keep completed downloads must be a whole number")
+	require.Equal(t, 10, service.Current().RetentionDays)
+
+	// Running now removes them, keeping the pinned download
+	w = httptest.NewRecorder()
+	handlers.RunHistoryCleanup(w, httptest.NewRequest("POST", "/settings/history/run", nil))
+	require.Contains(t, w.Body.String(), "Removed 1 downloads from the history")
+
+	downloads, err := db.ListDownloads(10, 0)
+	require.NoError(t, err)
+	require.Len(t, downloads, 1)
+	require.Equal(t, "pinned.mkv", downloads[0].Filename)
+}
+
+func TestHandlers_PinDownload(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	download := &models.Download{
+		OriginalURL: "https://example.com/file.mkv",
+		Filename:    "file.mkv",
+		Directory:   "/downloads",
+		Status:      models.StatusCompleted,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	require.NoError(t, db.CreateDownload(download))
+
+	// Pinning from the page shows the download as pinned
+	req := httptest.NewRequest("POST", fmt.Sprintf("/downloads/%d/pin", download.ID), nil)
+	req.SetPathValue("id", fmt.Sprint(download.ID))
+	w := httptest.NewRecorder()
+	handlers.PinDownload(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "Unpin")
+
+	updated, err := db.GetDownload(download.ID)
+	require.NoError(t, err)
+	require.True(t, updated.Pinned)
+
+	// The API unpins it
+	req = httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/downloads/%d/pin", download.ID), nil)
+	req.SetPathValue("id", fmt.Sprint(download.ID))
+	w = httptest.NewRecorder()
+	handlers.APIUnpinDownload(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response models.Download
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.False(t, response.Pinned)
+
+	req = httptest.NewRequest("POST", "/api/v1/downloads/999/pin", nil)
+	req.SetPathValue("id", "999")
+	w = httptest.NewRecorder()
+	handlers.APIPinDownload(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/hooks"
+	"debrid-downloader/internal/web/templates"
+	"debrid-downloader/pkg/models"
+)
+
+// SetHooksPath sets the directory post-processing hook scripts are chosen from. Hooks
+// can't be added while it is empty.
+func (h *Handlers) SetHooksPath(path string) {
+	h.hooksPath = path
+}
+
+// CreateHook adds a post-processing hook for a folder or everything
+func (h *Handlers) CreateHook(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := h.hookSettings()
+	if err != nil {
+		h.logger.Error("Failed to load hook settings", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	hook := &models.Hook{
+		Name:      strings.TrimSpace(r.FormValue("name")),
+		Script:    r.FormValue("script"),
+		Directory: strings.Trim(strings.TrimSpace(r.FormValue("directory")), "/"),
+		CreatedAt: time.Now(),
+	}
+	if timeout := strings.TrimSpace(r.FormValue("timeout")); timeout != "" {
+		seconds, err := strconv.Atoi(timeout)
+		if err != nil || seconds <= 0 {
+			h.renderHooks(w, r, "The timeout must be a positive number of seconds")
+			return
+		}
+		hook.TimeoutSeconds = seconds
+	}
+
+	switch {
+	case !settings.Enabled:
+		h.renderHooks(w, r, "Set HOOKS_PATH to enable hooks")
+		return
+	case hook.Name == "":
+		h.renderHooks(w, r, "Enter a name")
+		return
+	case !slices.Contains(settings.Scripts, hook.Script):
+		h.renderHooks(w, r, "Choose an executable script from the hooks directory")
+		return
+	}
+
+	if hook.Directory != "" {
+		if _, err := h.folderService.ValidatePath(hook.Directory); err != nil {
+			h.renderHooks(w, r, "The folder must be inside the downloads directory")
+			return
+		}
+	}
+
+	if err := h.db.CreateHook(hook); err != nil {
+		h.logger.Error("Failed to create hook", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Hook created", "hook_id", hook.ID, "script", hook.Script, "directory", hook.Directory)
+	h.renderHooks(w, r, "")
+}
+
+// DeleteHook removes a post-processing hook
+func (h *Handlers) DeleteHook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid hook ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteHook(id); err != nil {
+		http.Error(w, "Hook not found", http.StatusNotFound)
+		return
+	}
+
+	h.logger.Info("Hook deleted", "hook_id", id)
+	h.renderHooks(w, r, "")
+}
+
+// hookSettings loads the hooks along with the scripts they can use and the categories using them
+func (h *Handlers) hookSettings() (templates.HookSettings, error) {
+	settings := templates.HookSettings{Enabled: h.hooksPath != ""}
+
+	var err error
+	if settings.Hooks, err = h.db.ListHooks(); err != nil {
+		return settings, err
+	}
+	if settings.Categories, err = h.db.ListCategories(); err != nil {
+		return settings, err
+	}
+	if settings.Scripts, err = hooks.ListScripts(h.hooksPath); err != nil {
+		// A missing directory is shown as having no scripts rather than breaking the page
+		h.logger.Warn("Failed to list hook scripts", "path", h.hooksPath, "error", err)
+	}
+
+	return settings, nil
+}
+
+// renderHooks renders the post-processing hooks section of the settings page
+func (h *Handlers) renderHooks(w http.ResponseWriter, r *http.Request, errorMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	settings, err := h.hookSettings()
+	if err != nil {
+		h.logger.Error("Failed to list hooks", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := templates.HooksSection(settings, errorMessage).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render hooks", "error", err)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlers_Hooks(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	basePath := t.TempDir()
+	handlers := NewHandlers(db, alldebrid.New("test-key"), basePath, downloader.NewWorker(db, basePath))
+
+	admin := &models.User{Username: "admin", Role: models.RoleAdmin, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(admin))
+
+	// Hooks can't be added until a scripts directory is configured
+	form := url.Values{"name": {"Import"}, "script": {"import.sh"}, "directory": {"tv"}, "timeout": {"120"}}
+	w := httptest.NewRecorder()
+	handlers.CreateHook(w, requestAs(admin, "POST", "/settings/hooks", form.Encode()))
+	require.Contains(t, w.Body.String(), "Set HOOKS_PATH to enable hooks")
+
+	scriptsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsDir, "import.sh"), []byte("#!/bin/sh\n"), 0o755))
+	handlers.SetHooksPath(scriptsDir)
+
+	w = httptest.NewRecorder()
+	handlers.CreateHook(w, requestAs(admin, "POST", "/settings/hooks", form.Encode()))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	hooks, err := db.ListHooks()
+	require.NoError(t, err)
+	require.Len(t, hooks, 1)
+	require.Equal(t, "tv", hooks[0].Directory)
+	require.Equal(t, 120, hooks[0].TimeoutSeconds)
+
+	// Categories using the hook are listed next to it
+	category := models.NewCategory("anime")
+	category.HookID = hooks[0].ID
+	require.NoError(t, db.CreateCategory(category))
+
+	// Invalid input is reported in the section
+	for message, values := range map[string]url.Values{
+		"Choose an executable script":    {"name": {"x"}, "script": {"../../bin/sh"}},
+		"inside the downloads directory": {"name": {"x"}, "script": {"import.sh"}, "directory": {"../etc"}},
+		"positive number of seconds":     {"name": {"x"}, "script": {"import.sh"}, "timeout": {"-1"}},
+		"Enter a name":                   {"script": {"import.sh"}},
+	} {
+		w = httptest.NewRecorder()
+		handlers.CreateHook(w, requestAs(admin, "POST", "/settings/hooks", values.Encode()))
+		require.Contains(t, w.Body.String(), message)
+	}
+
+	w = httptest.NewRecorder()
+	handlers.Settings(w, requestAs(admin, "GET", "/settings", ""))
+	require.Contains(t, w.Body.String(), "Post-Processing Hooks")
+	require.Contains(t, w.Body.String(), "2m0s")
+	require.Contains(t, w.Body.String(), "Categories: anime")
+
+	req := requestAs(admin, "DELETE", fmt.Sprintf("/settings/hooks/%d", hooks[0].ID), "")
+	req.SetPathValue("id", fmt.Sprint(hooks[0].ID))
+	w = httptest.NewRecorder()
+	handlers.DeleteHook(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "No hooks yet")
+}
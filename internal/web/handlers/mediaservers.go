@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/web/templates"
+	"debrid-downloader/pkg/models"
+)
+
+// CreateMediaServer adds a Jellyfin, Emby or Plex server to refresh when downloads finish
+func (h *Handlers) CreateMediaServer(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	server := &models.MediaServer{
+		Name:         strings.TrimSpace(r.FormValue("name")),
+		Kind:         models.MediaServerKind(r.FormValue("kind")),
+		URL:          strings.TrimSpace(r.FormValue("url")),
+		Token:        strings.TrimSpace(r.FormValue("token")),
+		PathPrefix:   strings.TrimSpace(r.FormValue("path_prefix")),
+		RemotePrefix: strings.TrimSpace(r.FormValue("remote_prefix")),
+		CreatedAt:    time.Now(),
+	}
+
+	parsed, err := url.Parse(server.URL)
+	switch {
+	case server.Name == "":
+		h.renderMediaServers(w, r, "Enter a name")
+		return
+	case !server.Kind.Valid():
+		h.renderMediaServers(w, r, "Choose Jellyfin, Emby or Plex")
+		return
+	case err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "":
+		h.renderMediaServers(w, r, "Enter an http:// or https:// URL")
+		return
+	case server.Token == "":
+		h.renderMediaServers(w, r, "Enter the server's API key or token")
+		return
+	case !filepath.IsAbs(server.PathPrefix):
+		h.renderMediaServers(w, r, "The path must be an absolute directory")
+		return
+	}
+	server.PathPrefix = filepath.Clean(server.PathPrefix)
+
+	if err := h.db.CreateMediaServer(server); err != nil {
+		h.logger.Error("Failed to create media server", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Media server added", "media_server_id", server.ID, "kind", server.Kind, "url", parsed.Redacted())
+	h.renderMediaServers(w, r, "")
+}
+
+// DeleteMediaServer stops refreshing a media server
+func (h *Handlers) DeleteMediaServer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid media server ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.db.DeleteMediaServer(id); err != nil {
+		http.Error(w, "Media server not found", http.StatusNotFound)
+		return
+	}
+
+	h.logger.Info("Media server deleted", "media_server_id", id)
+	h.renderMediaServers(w, r, "")
+}
+
+// renderMediaServers renders the media servers section of the settings page
+func (h *Handlers) renderMediaServers(w http.ResponseWriter, r *http.Request, errorMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	servers, err := h.db.ListMediaServers()
+	if err != nil {
+		h.logger.Error("Failed to list media servers", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := templates.MediaServersSection(servers, errorMessage).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render media servers", "error", err)
+	}
+}
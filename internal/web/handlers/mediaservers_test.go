@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlers_MediaServers(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	admin := &models.User{Username: "admin", Role: models.RoleAdmin, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(admin))
+	alice := &models.User{Username: "alice", Role: models.RoleUser, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(alice))
+
+	form := url.Values{
+		"name":          {"Living room"},
+		"kind":          {"jellyfin"},
+		"url":           {"http://jellyfin:8096"},
+		"token":         {"api-key"},
+		"path_prefix":   {"/downloads/movies/"},
+		"remote_prefix": {"/media/movies"},
+	}
+	w := httptest.NewRecorder()
+	handlers.CreateMediaServer(w, requestAs(admin, "POST", "/settings/media-servers", form.Encode()))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "Living room")
+	require.NotContains(t, w.Body.String(), "api-key")
+
+	servers, err := db.ListMediaServers()
+	require.NoError(t, err)
+	require.Len(t, servers, 1)
+	require.Equal(t, "/downloads/movies", servers[0].PathPrefix)
+
+	// Invalid input is reported in the section
+	for field, message := range map[string]string{
+		"kind":        "Choose Jellyfin, Emby or Plex",
+		"url":         "Enter an http:// or https:// URL",
+		"path_prefix": "The path must be an absolute directory",
+	} {
+		invalid := url.Values{}
+		for key, values := range form {
+			invalid[key] = values
+		}
+		invalid.Set(field, "invalid")
+
+		w = httptest.NewRecorder()
+		handlers.CreateMediaServer(w, requestAs(admin, "POST", "/settings/media-servers", invalid.Encode()))
+		require.Contains(t, w.Body.String(), message)
+	}
+
+	// Only admins see the section
+	w = httptest.NewRecorder()
+	handlers.Settings(w, requestAs(admin, "GET", "/settings", ""))
+	require.Contains(t, w.Body.String(), "jellyfin:8096")
+
+	w = httptest.NewRecorder()
+	handlers.Settings(w, requestAs(alice, "GET", "/settings", ""))
+	require.NotContains(t, w.Body.String(), "Media Servers")
+
+	req := requestAs(admin, "DELETE", fmt.Sprintf("/settings/media-servers/%d", servers[0].ID), "")
+	req.SetPathValue("id", fmt.Sprint(servers[0].ID))
+	w = httptest.NewRecorder()
+	handlers.DeleteMediaServer(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "No media servers yet")
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/rules"
+	"debrid-downloader/internal/web/templates"
+	"debrid-downloader/pkg/models"
+)
+
+// CreateRule adds a routing rule after the existing ones
+func (h *Handlers) CreateRule(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	rule := &models.Rule{CreatedAt: time.Now()}
+	if message := h.ruleFromForm(r, rule); message != "" {
+		h.renderRules(w, r, message)
+		return
+	}
+
+	if err := h.db.CreateRule(rule); err != nil {
+		h.logger.Error("Failed to create rule", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Rule created", "rule_id", rule.ID, "condition", rule.Condition, "value", rule.Value, "directory", rule.Directory)
+	h.renderRules(w, r, "")
+}
+
+// UpdateRule changes a routing rule's condition, value and folder
+func (h *Handlers) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.ruleFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	if message := h.ruleFromForm(r, rule); message != "" {
+		h.renderRules(w, r, message)
+		return
+	}
+
+	if err := h.db.UpdateRule(rule); err != nil {
+		h.logger.Error("Failed to update rule", "error", err, "rule_id", rule.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Rule updated", "rule_id", rule.ID, "condition", rule.Condition, "value", rule.Value, "directory", rule.Directory)
+	h.renderRules(w, r, "")
+}
+
+// MoveRule moves a routing rule up or down the order they are checked in
+func (h *Handlers) MoveRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.ruleFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.MoveRule(rule.ID, r.FormValue("direction") == "down"); err != nil {
+		h.logger.Error("Failed to move rule", "error", err, "rule_id", rule.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.renderRules(w, r, "")
+}
+
+// DeleteRule removes a routing rule
+func (h *Handlers) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.ruleFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.DeleteRule(rule.ID); err != nil {
+		h.logger.Error("Failed to delete rule", "error", err, "rule_id", rule.ID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Rule deleted", "rule_id", rule.ID)
+	h.renderRules(w, r, "")
+}
+
+// TestRule previews where a link would be saved: the rule it matches, or the learned
+// suggestion when none does
+func (h *Handlers) TestRule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	url := strings.TrimSpace(r.FormValue("url"))
+	if url == "" {
+		http.Error(w, "Enter a link to test", http.StatusBadRequest)
+		return
+	}
+
+	link := h.linkDetails(r.Context(), url)
+	preview := templates.RulePreview{Filename: link.Filename, Size: link.Size}
+	if directory, rule := h.ruleDirectory(link); rule != nil {
+		preview.Rule = rule
+		preview.Directory = directory
+	} else {
+		preview.Directory = h.getDirectorySuggestionsForFilename(r.Context(), url)
+	}
+
+	if err := templates.RuleTestResult(preview).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render rule test", "error", err)
+	}
+}
+
+// ruleFromPath loads the rule named by the request's id path value, writing an error
+// response when there isn't one
+func (h *Handlers) ruleFromPath(w http.ResponseWriter, r *http.Request) (*models.Rule, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid rule ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	rule, err := h.db.GetRule(id)
+	if err != nil {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return nil, false
+	}
+	return rule, true
+}
+
+// ruleFromForm copies the submitted condition, value and folder onto a rule, returning
+// a message describing the first invalid field
+func (h *Handlers) ruleFromForm(r *http.Request, rule *models.Rule) string {
+	condition := models.RuleCondition(r.FormValue("condition"))
+	value := strings.TrimSpace(r.FormValue("value"))
+	directory := strings.Trim(strings.TrimSpace(r.FormValue("directory")), "/")
+
+	switch {
+	case !condition.Valid():
+		return "Choose a condition"
+	case value == "":
+		return "Enter a value to match"
+	}
+	if err := rules.Validate(condition, value); err != nil {
+		return fmt.Sprintf("Invalid rule: %s", err)
+	}
+	if _, err := h.folderService.ValidatePath(directory); err != nil {
+		return "The folder must be inside the downloads directory"
+	}
+
+	rule.Condition = condition
+	rule.Value = value
+	rule.Directory = directory
+	return ""
+}
+
+// linkDetails looks up a link's file name and size, falling back to the name in the
+// link when it can't be unrestricted
+func (h *Handlers) linkDetails(ctx context.Context, url string) rules.Link {
+	return h.submitService.LinkDetails(ctx, url)
+}
+
+// ruleDirectory returns the folder of the first rule a link matches, or a nil rule
+func (h *Handlers) ruleDirectory(link rules.Link) (string, *models.Rule) {
+	return h.submitService.RuleDirectory(link, h.folderService)
+}
+
+// renderRules renders the routing rules section of the settings page
+func (h *Handlers) renderRules(w http.ResponseWriter, r *http.Request, errorMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	list, err := h.db.ListRules()
+	if err != nil {
+		h.logger.Error("Failed to list rules", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := templates.RulesSection(list, errorMessage).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render rules", "error", err)
+	}
+}
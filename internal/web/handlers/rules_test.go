@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlers_Rules(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	basePath := t.TempDir()
+	handlers := NewHandlers(db, alldebrid.New("test-key"), basePath, downloader.NewWorker(db, basePath))
+
+	admin := &models.User{Username: "admin", Role: models.RoleAdmin, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(admin))
+
+	for _, values := range []url.Values{
+		{"condition": {"host"}, "value": {"example.com"}, "directory": {"/example/"}},
+		{"condition": {"filename"}, "value": {`\.iso$`}, "directory": {"images"}},
+	} {
+		w := httptest.NewRecorder()
+		handlers.CreateRule(w, requestAs(admin, "POST", "/settings/rules", values.Encode()))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	rules, err := db.ListRules()
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	require.Equal(t, "example", rules[0].Directory)
+
+	// Invalid input is reported in the section
+	for message, values := range map[string]url.Values{
+		"Choose a condition":             {"condition": {"colour"}, "value": {"red"}},
+		"Enter a value":                  {"condition": {"host"}},
+		"regular expression":             {"condition": {"filename"}, "value": {"(unclosed"}},
+		"invalid size":                   {"condition": {"larger_than"}, "value": {"huge"}},
+		"inside the downloads directory": {"condition": {"host"}, "value": {"a.com"}, "directory": {"../etc"}},
+	} {
+		w := httptest.NewRecorder()
+		handlers.CreateRule(w, requestAs(admin, "POST", "/settings/rules", values.Encode()))
+		require.Contains(t, w.Body.String(), message)
+	}
+
+	// Links matching a rule are suggested its folder before anything learned
+	w := httptest.NewRecorder()
+	handlers.GetDirectorySuggestion(w, requestAs(admin, "GET", "/api/directory-suggestion?url="+url.QueryEscape("https://files.example.com/a.zip"), ""))
+	require.Equal(t, filepath.Join(basePath, "example"), w.Body.String())
+
+	// The preview names the matching rule
+	test := url.Values{"url": {"https://mirror.org/linux.ISO"}}
+	w = httptest.NewRecorder()
+	handlers.TestRule(w, requestAs(admin, "POST", "/settings/rules/test", test.Encode()))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "Filename matches")
+	require.Contains(t, w.Body.String(), filepath.Join(basePath, "images"))
+
+	test = url.Values{"url": {"https://other.org/file.zip"}}
+	w = httptest.NewRecorder()
+	handlers.TestRule(w, requestAs(admin, "POST", "/settings/rules/test", test.Encode()))
+	require.Contains(t, w.Body.String(), "No rule matches")
+
+	// Moving the second rule up puts it first
+	move := url.Values{"direction": {"up"}}
+	req := requestAs(admin, "POST", fmt.Sprintf("/settings/rules/%d/move", rules[1].ID), move.Encode())
+	req.SetPathValue("id", fmt.Sprint(rules[1].ID))
+	w = httptest.NewRecorder()
+	handlers.MoveRule(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	reordered, err := db.ListRules()
+	require.NoError(t, err)
+	require.Equal(t, rules[1].ID, reordered[0].ID)
+
+	update := url.Values{"condition": {"larger_than"}, "value": {"4GB"}, "directory": {"big"}}
+	req = requestAs(admin, "POST", fmt.Sprintf("/settings/rules/%d", rules[0].ID), update.Encode())
+	req.SetPathValue("id", fmt.Sprint(rules[0].ID))
+	w = httptest.NewRecorder()
+	handlers.UpdateRule(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	found, err := db.GetRule(rules[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, models.RuleLargerThan, found.Condition)
+	require.Equal(t, "big", found.Directory)
+
+	// The settings page lists the rules
+	w = httptest.NewRecorder()
+	handlers.Settings(w, requestAs(admin, "GET", "/settings", ""))
+	require.Contains(t, w.Body.String(), "Routing Rules")
+	require.Contains(t, w.Body.String(), "Larger than")
+
+	for _, rule := range rules {
+		req = requestAs(admin, "DELETE", fmt.Sprintf("/settings/rules/%d", rule.ID), "")
+		req.SetPathValue("id", fmt.Sprint(rule.ID))
+		w = httptest.NewRecorder()
+		handlers.DeleteRule(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+	require.Contains(t, w.Body.String(), "No rules yet")
+}
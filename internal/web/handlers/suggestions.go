@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/submit"
+	"debrid-downloader/pkg/fuzzy"
+	"debrid-downloader/pkg/models"
+)
+
+// overridePenalty is how much an overridden suggestion weakens the tokens that led to it
+const overridePenalty = 0.5
+
+// apiSuggestion is a ranked directory returned by APIDirectorySuggestions
+type apiSuggestion struct {
+	Directory  string  `json:"directory"`
+	Confidence float64 `json:"confidence"`
+	Source     string  `json:"source"` // "rule" or "learned"
+}
+
+// APIDirectorySuggestions returns the directories a link would likely be saved to, best
+// first with confidence scores. A matching routing rule always comes first.
+func (h *Handlers) APIDirectorySuggestions(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	url := strings.TrimSpace(query.Get("url"))
+	if url == "" {
+		writeJSONError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+
+	limit := 5
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 50 {
+			writeJSONError(w, http.StatusBadRequest, "limit must be between 1 and 50")
+			return
+		}
+		limit = parsed
+	}
+
+	link := h.linkDetails(r.Context(), url)
+	suggestions := []apiSuggestion{}
+	if directory, rule := h.ruleDirectory(link); rule != nil {
+		suggestions = append(suggestions, apiSuggestion{Directory: directory, Confidence: 1, Source: "rule"})
+	}
+	for _, ranked := range h.rankDirectories(link.Filename, url, limit) {
+		if len(suggestions) == limit {
+			break
+		}
+		if len(suggestions) > 0 && suggestions[0].Directory == ranked.Directory {
+			continue
+		}
+		suggestions = append(suggestions, apiSuggestion{Directory: ranked.Directory, Confidence: ranked.Confidence, Source: "learned"})
+	}
+
+	accepted, overridden, err := h.db.GetSuggestionAccuracy(h.mappingOwner())
+	if err != nil {
+		h.logger.Error("Failed to get suggestion accuracy", "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to get suggestions")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, map[string]any{
+		"filename":    link.Filename,
+		"suggestions": suggestions,
+		"feedback":    map[string]int{"accepted": accepted, "overridden": overridden},
+	})
+}
+
+// rankDirectories returns up to limit learned directories for a file, best first,
+// leaving out any the current user can't download into
+func (h *Handlers) rankDirectories(filename, url string, limit int) []fuzzy.Suggestion {
+	return h.submitService.RankDirectories(h.mappingOwner(), filename, url, limit, h.folderService)
+}
+
+// recordSuggestionFeedback records whether the submit form's suggested directory was kept.
+// Overriding it weakens the tokens that led to the suggestion.
+func (h *Handlers) recordSuggestionFeedback(suggested, chosen string, items []*submit.Item) {
+	if suggested == "" || len(items) == 0 {
+		return
+	}
+
+	accepted := filepath.Clean(suggested) == filepath.Clean(chosen)
+	feedback := &models.SuggestionFeedback{
+		Filename:  items[0].SourceFilename,
+		Suggested: suggested,
+		Chosen:    chosen,
+		Accepted:  accepted,
+		OwnerID:   h.ownerID(),
+		CreatedAt: time.Now(),
+	}
+	if err := h.db.CreateSuggestionFeedback(feedback); err != nil {
+		h.logger.Warn("Failed to record suggestion feedback", "error", err)
+		return
+	}
+	if accepted {
+		return
+	}
+
+	for _, item := range items {
+		tokens := fuzzy.Tokens(item.SourceFilename, item.Download.OriginalURL)
+		if err := h.db.WeakenDirectoryTokens(h.ownerID(), tokens, suggested, overridePenalty); err != nil {
+			h.logger.Warn("Failed to weaken overridden suggestion", "error", err, "directory", suggested)
+		}
+	}
+}
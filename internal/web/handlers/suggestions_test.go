@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/internal/submit"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlers_LearnedSuggestions(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	basePath := t.TempDir()
+	handlers := NewHandlers(db, alldebrid.New("test-key"), basePath, downloader.NewWorker(db, basePath))
+
+	tv := filepath.Join(basePath, "tv")
+	movies := filepath.Join(basePath, "movies")
+
+	// Files with the same extension are told apart by their titles and release groups
+	for filename, directory := range map[string]string{
+		"The.Office.S01E01.720p.HDTV.x264-NTB.mkv": tv,
+		"The.Office.S01E02.720p.HDTV.x264-NTB.mkv": tv,
+		"Heat.1995.1080p.BluRay.x264-SPARKS.mkv":   movies,
+	} {
+		require.NoError(t, handlers.learnDirectory(filename, "https://host.example/"+filename, directory))
+	}
+
+	require.Equal(t, tv, handlers.getDirectorySuggestionsFromFilename("The.Office.S02E01.720p.HDTV.x264-NTB.mkv", ""))
+	require.Equal(t, movies, handlers.getDirectorySuggestionsFromFilename("Ronin.1998.1080p.BluRay.x264-SPARKS.mkv", ""))
+
+	ranked := handlers.rankDirectories("The.Office.S02E01.mkv", "", 5)
+	require.Len(t, ranked, 2)
+	require.Equal(t, tv, ranked[0].Directory)
+	require.Greater(t, ranked[0].Confidence, ranked[1].Confidence)
+
+	// The JSON API returns the ranked list with confidence scores
+	w := httptest.NewRecorder()
+	handlers.APIDirectorySuggestions(w, httptest.NewRequest("GET", "/api/v1/directory-suggestions?url="+url.QueryEscape("https://host.example/The.Office.S03E01.mkv"), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Suggestions []apiSuggestion `json:"suggestions"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.NotEmpty(t, response.Suggestions)
+	require.Equal(t, tv, response.Suggestions[0].Directory)
+	require.Equal(t, "learned", response.Suggestions[0].Source)
+
+	w = httptest.NewRecorder()
+	handlers.APIDirectorySuggestions(w, httptest.NewRequest("GET", "/api/v1/directory-suggestions", nil))
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Overriding a suggestion is recorded and weakens it
+	before := handlers.rankDirectories("Heat.1995.mkv", "", 1)[0].Confidence
+	items := []*submit.Item{{
+		Download:       &models.Download{OriginalURL: "https://host.example/Heat.1995.mkv"},
+		SourceFilename: "Heat.1995.mkv",
+	}}
+	handlers.recordSuggestionFeedback(movies, tv, items)
+	handlers.recordSuggestionFeedback(tv, tv, items)
+
+	accepted, overridden, err := db.GetSuggestionAccuracy(database.AllOwners)
+	require.NoError(t, err)
+	require.Equal(t, 1, accepted)
+	require.Equal(t, 1, overridden)
+
+	after := handlers.rankDirectories("Heat.1995.mkv", "", 1)
+	require.Equal(t, movies, after[0].Directory)
+	require.Less(t, after[0].Confidence, before)
+}
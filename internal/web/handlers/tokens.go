@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/auth"
+	"debrid-downloader/internal/web/templates"
+	"debrid-downloader/pkg/models"
+)
+
+// CreateAPIToken creates a new API token and shows its value once
+func (h *Handlers) CreateAPIToken(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "Token name is required", http.StatusBadRequest)
+		return
+	}
+
+	scope := models.APITokenScope(r.FormValue("scope"))
+	if !scope.Valid() {
+		http.Error(w, "Invalid token scope", http.StatusBadRequest)
+		return
+	}
+	if scope == models.ScopeAdmin && !h.isAdmin() {
+		http.Error(w, "Only admins can create admin tokens", http.StatusForbidden)
+		return
+	}
+
+	value, prefix, hash, err := auth.GenerateAPIToken()
+	if err != nil {
+		h.logger.Error("Failed to generate API token", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	token := &models.APIToken{
+		Name:      name,
+		Prefix:    prefix,
+		TokenHash: hash,
+		Scope:     scope,
+		CreatedAt: time.Now(),
+		OwnerID:   h.ownerID(),
+	}
+	if err := h.db.CreateAPIToken(token); err != nil {
+		h.logger.Error("Failed to create API token", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("API token created", "token_id", token.ID, "name", name, "scope", scope)
+	h.renderAPITokens(w, r, value)
+}
+
+// RevokeAPIToken revokes an API token
+func (h *Handlers) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.db.GetAPIToken(id)
+	if err != nil || !h.ownsRecord(token.OwnerID) {
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.db.RevokeAPIToken(id, time.Now()); err != nil {
+		h.logger.Error("Failed to revoke API token", "error", err, "token_id", id)
+		http.Error(w, "Token not found", http.StatusNotFound)
+		return
+	}
+
+	h.logger.Info("API token revoked", "token_id", id)
+	h.renderAPITokens(w, r, "")
+}
+
+// renderAPITokens renders the API tokens section of the settings page
+func (h *Handlers) renderAPITokens(w http.ResponseWriter, r *http.Request, newToken string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	tokens, err := h.db.ListAPITokensByOwner(h.downloadOwner())
+	if err != nil {
+		h.logger.Error("Failed to list API tokens", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := templates.APITokensSection(tokens, newToken).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render API tokens", "error", err)
+	}
+}
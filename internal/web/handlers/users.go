@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/auth"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/web/templates"
+	"debrid-downloader/pkg/models"
+)
+
+// forRequest returns the handlers scoped to the signed-in user. Users limited to a folder get a
+// folder service rooted there, so browsing, suggestions and submissions stay inside it.
+func (h *Handlers) forRequest(w http.ResponseWriter, r *http.Request) (*Handlers, bool) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return h, true
+	}
+
+	scoped := *h
+	scoped.user = user
+
+	if !user.IsAdmin() && user.Folder != "" {
+		folderService, err := h.folderService.Subtree(user.Folder)
+		if err != nil {
+			h.logger.Error("Invalid user folder", "error", err, "username", user.Username, "folder", user.Folder)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return nil, false
+		}
+		scoped.folderService = folderService
+	}
+
+	return &scoped, true
+}
+
+// isAdmin reports whether the current user sees everything. Everyone is an admin while
+// authentication is disabled.
+func (h *Handlers) isAdmin() bool {
+	return h.user == nil || h.user.IsAdmin()
+}
+
+// restricted reports whether the current user is limited to their own downloads and folder
+func (h *Handlers) restricted() bool {
+	return !h.isAdmin()
+}
+
+// ownerID returns the owner to record on new downloads, mappings and tokens
+func (h *Handlers) ownerID() int64 {
+	if h.user == nil {
+		return 0
+	}
+	return h.user.ID
+}
+
+// downloadOwner returns the owner filter for listing downloads and tokens
+func (h *Handlers) downloadOwner() int64 {
+	if h.isAdmin() {
+		return database.AllOwners
+	}
+	return h.user.ID
+}
+
+// mappingOwner returns the owner filter for directory suggestions. Suggestions are learned
+// per user, admins included, so one user's habits don't steer another's downloads.
+func (h *Handlers) mappingOwner() int64 {
+	if h.user == nil {
+		return database.AllOwners
+	}
+	return h.user.ID
+}
+
+// ownsRecord reports whether the current user may see or change a record with the given owner
+func (h *Handlers) ownsRecord(ownerID int64) bool {
+	return h.isAdmin() || ownerID == h.user.ID
+}
+
+// canAccess reports whether the current user may see or change a download
+func (h *Handlers) canAccess(download *models.Download) bool {
+	return h.ownsRecord(download.OwnerID)
+}
+
+// canAccessID looks up a download and reports whether the current user may change it
+func (h *Handlers) canAccessID(downloadID int64) bool {
+	if h.isAdmin() {
+		return true
+	}
+
+	download, err := h.db.GetDownload(downloadID)
+	if err != nil {
+		return false
+	}
+	return h.canAccess(download)
+}
+
+// CreateUser adds a user account
+func (h *Handlers) CreateUser(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	username := strings.TrimSpace(r.FormValue("username"))
+	password := r.FormValue("password")
+	if username == "" || password == "" {
+		h.renderUsers(w, r, "Username and password are required")
+		return
+	}
+
+	if _, err := h.db.GetUserByUsername(username); err == nil {
+		h.renderUsers(w, r, "A user with that name already exists")
+		return
+	}
+
+	role := models.UserRole(r.FormValue("role"))
+	if !role.Valid() {
+		h.renderUsers(w, r, "Invalid role")
+		return
+	}
+
+	folder, err := h.userFolder(r.FormValue("folder"))
+	if err != nil {
+		h.renderUsers(w, r, err.Error())
+		return
+	}
+
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		h.logger.Error("Failed to hash password", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	user := &models.User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		Folder:       folder,
+		CreatedAt:    time.Now(),
+	}
+	if err := h.db.CreateUser(user); err != nil {
+		h.logger.Error("Failed to create user", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("User created", "user_id", user.ID, "username", username, "role", role, "folder", folder)
+	h.renderUsers(w, r, "")
+}
+
+// UpdateUser changes a user's role, folder and, when given, password
+func (h *Handlers) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.db.GetUser(id)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	role := models.UserRole(r.FormValue("role"))
+	if !role.Valid() {
+		h.renderUsers(w, r, "Invalid role")
+		return
+	}
+	if user.ID == h.ownerID() && role != models.RoleAdmin {
+		h.renderUsers(w, r, "You can't remove your own admin role")
+		return
+	}
+
+	folder, err := h.userFolder(r.FormValue("folder"))
+	if err != nil {
+		h.renderUsers(w, r, err.Error())
+		return
+	}
+
+	if password := r.FormValue("password"); password != "" {
+		user.PasswordHash, err = auth.HashPassword(password)
+		if err != nil {
+			h.logger.Error("Failed to hash password", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	user.Role = role
+	user.Folder = folder
+
+	if err := h.db.UpdateUser(user); err != nil {
+		h.logger.Error("Failed to update user", "error", err, "user_id", id)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("User updated", "user_id", id, "username", user.Username, "role", role, "folder", folder)
+	h.renderUsers(w, r, "")
+}
+
+// DeleteUser removes a user account and revokes their API tokens. Their downloads are kept.
+func (h *Handlers) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if id == h.ownerID() {
+		h.renderUsers(w, r, "You can't delete your own account")
+		return
+	}
+
+	if err := h.db.DeleteUser(id); err != nil {
+		h.logger.Error("Failed to delete user", "error", err, "user_id", id)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("User deleted", "user_id", id)
+	h.renderUsers(w, r, "")
+}
+
+// userFolder validates a folder entered for a user and creates it, returning the cleaned path
+// relative to the downloads directory. An empty folder leaves the user unrestricted.
+func (h *Handlers) userFolder(folder string) (string, error) {
+	folder = strings.Trim(strings.TrimSpace(folder), "/")
+	if folder == "" {
+		return "", nil
+	}
+
+	fullPath, err := h.folderService.ValidatePath(folder)
+	if err != nil {
+		return "", err
+	}
+
+	if fullPath == h.folderService.BasePath {
+		return "", nil
+	}
+
+	relative := strings.TrimPrefix(fullPath, h.folderService.BasePath+string(filepath.Separator))
+	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		if err := h.folderService.CreateDirectory(relative); err != nil {
+			return "", err
+		}
+	}
+
+	return relative, nil
+}
+
+// renderUsers renders the users section of the settings page
+func (h *Handlers) renderUsers(w http.ResponseWriter, r *http.Request, errorMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	users, err := h.db.ListUsers()
+	if err != nil {
+		h.logger.Error("Failed to list users", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := templates.UsersSection(users, errorMessage).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render users", "error", err)
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/auth"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+// requestAs returns a request made by the given user
+func requestAs(user *models.User, method, target string, body string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	return req.WithContext(auth.WithUser(req.Context(), user))
+}
+
+func TestHandlers_DownloadsScopedToOwner(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	basePath := t.TempDir()
+	handlers := NewHandlers(db, alldebrid.New("test-key"), basePath, downloader.NewWorker(db, basePath))
+
+	admin := &models.User{Username: "admin", Role: models.RoleAdmin, CreatedAt: time.Now()}
+	alice := &models.User{Username: "alice", Role: models.RoleUser, Folder: "alice", CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(admin))
+	require.NoError(t, db.CreateUser(alice))
+	require.NoError(t, os.Mkdir(filepath.Join(basePath, "alice"), 0o755))
+
+	downloads := map[int64]*models.Download{}
+	for _, owner := range []*models.User{admin, alice} {
+		download := &models.Download{
+			OriginalURL: "https://example.com/" + owner.Username + ".zip",
+			Filename:    owner.Username + ".zip",
+			Directory:   basePath,
+			Status:      models.StatusFailed,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+			OwnerID:     owner.ID,
+		}
+		require.NoError(t, db.CreateDownload(download))
+		downloads[owner.ID] = download
+	}
+
+	search := url.Values{"status": {"failed"}}.Encode()
+
+	t.Run("users only see their own downloads", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handlers.SearchDownloads(w, requestAs(alice, "POST", "/downloads/search", search))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), "alice.zip")
+		require.NotContains(t, w.Body.String(), "admin.zip")
+	})
+
+	t.Run("admins see every download", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handlers.SearchDownloads(w, requestAs(admin, "POST", "/downloads/search", search))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), "alice.zip")
+		require.Contains(t, w.Body.String(), "admin.zip")
+	})
+
+	t.Run("users cannot delete other users downloads", func(t *testing.T) {
+		id := downloads[admin.ID].ID
+		req := requestAs(alice, "DELETE", "/downloads/1", "")
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		handlers.DeleteDownload(w, req)
+
+		require.Equal(t, http.StatusNotFound, w.Code)
+		_, err := db.GetDownload(id)
+		require.NoError(t, err)
+	})
+
+	t.Run("users cannot fetch other users downloads", func(t *testing.T) {
+		req := requestAs(alice, "GET", "/api/v1/downloads/1", "")
+		req.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		handlers.APIGetDownload(w, req)
+
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("folder browsing starts at the user's folder", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handlers.BrowseFolders(w, requestAs(alice, "GET", "/api/folders", ""))
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), `"base_path":"`+filepath.Join(basePath, "alice")+`"`)
+	})
+
+	t.Run("submissions outside the user's folder are rejected", func(t *testing.T) {
+		form := url.Values{"url": {"https://example.com/file.zip"}, "directory": {basePath}}
+		w := httptest.NewRecorder()
+		handlers.SubmitDownload(w, requestAs(alice, "POST", "/download", form.Encode()))
+
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Contains(t, w.Body.String(), "outside your downloads folder")
+	})
+}
+
+func TestHandlers_ManageUsers(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	basePath := t.TempDir()
+	handlers := NewHandlers(db, alldebrid.New("test-key"), basePath, downloader.NewWorker(db, basePath))
+
+	admin := &models.User{Username: "admin", Role: models.RoleAdmin, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(admin))
+
+	// Create
+	form := url.Values{"username": {"bob"}, "password": {"secret"}, "role": {"user"}, "folder": {"/bob/"}}
+	w := httptest.NewRecorder()
+	handlers.CreateUser(w, requestAs(admin, "POST", "/settings/users", form.Encode()))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "bob")
+
+	bob, err := db.GetUserByUsername("bob")
+	require.NoError(t, err)
+	require.Equal(t, "bob", bob.Folder)
+	require.NotEqual(t, "secret", bob.PasswordHash)
+	require.DirExists(t, filepath.Join(basePath, "bob"))
+
+	// Folders must stay inside the downloads directory
+	form = url.Values{"username": {"eve"}, "password": {"secret"}, "role": {"user"}, "folder": {"../etc"}}
+	w = httptest.NewRecorder()
+	handlers.CreateUser(w, requestAs(admin, "POST", "/settings/users", form.Encode()))
+	_, err = db.GetUserByUsername("eve")
+	require.Error(t, err)
+
+	// Update
+	form = url.Values{"role": {"admin"}, "folder": {""}}
+	req := requestAs(admin, "POST", "/settings/users/1", form.Encode())
+	req.SetPathValue("id", "2")
+	w = httptest.NewRecorder()
+	handlers.UpdateUser(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	bob, err = db.GetUser(bob.ID)
+	require.NoError(t, err)
+	require.True(t, bob.IsAdmin())
+	require.Empty(t, bob.Folder)
+
+	// Admins cannot demote or delete themselves
+	form = url.Values{"role": {"user"}}
+	req = requestAs(admin, "POST", "/settings/users/1", form.Encode())
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	handlers.UpdateUser(w, req)
+	require.Contains(t, w.Body.String(), "own admin role")
+
+	req = requestAs(admin, "DELETE", "/settings/users/1", "")
+	req.SetPathValue("id", "1")
+	w = httptest.NewRecorder()
+	handlers.DeleteUser(w, req)
+	require.Contains(t, w.Body.String(), "own account")
+
+	// Delete
+	req = requestAs(admin, "DELETE", "/settings/users/2", "")
+	req.SetPathValue("id", "2")
+	w = httptest.NewRecorder()
+	handlers.DeleteUser(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	_, err = db.GetUser(bob.ID)
+	require.Error(t, err)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/events"
+	"debrid-downloader/internal/web/templates"
+	"debrid-downloader/internal/webhook"
+	"debrid-downloader/pkg/models"
+)
+
+// CreateWebhook adds a webhook and shows its signing secret once
+func (h *Handlers) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	target := strings.TrimSpace(r.FormValue("url"))
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		h.renderWebhooks(w, r, "", "Enter an http:// or https:// URL")
+		return
+	}
+
+	var selected []string
+	for _, value := range r.Form["events"] {
+		if !events.Type(value).Valid() {
+			h.renderWebhooks(w, r, "", "Unknown event: "+value)
+			return
+		}
+		selected = append(selected, value)
+	}
+	// Ticking every event is the same as subscribing to all, including any added later
+	if len(selected) == len(events.Types) {
+		selected = nil
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		h.logger.Error("Failed to generate webhook secret", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	hook := &models.Webhook{
+		URL:       target,
+		Secret:    secret,
+		Events:    strings.Join(selected, ","),
+		Enabled:   true,
+		OwnerID:   h.ownerID(),
+		CreatedAt: time.Now(),
+	}
+	if err := h.db.CreateWebhook(hook); err != nil {
+		h.logger.Error("Failed to create webhook", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Webhook created", "webhook_id", hook.ID, "url", parsed.Redacted(), "events", hook.Events)
+	h.renderWebhooks(w, r, secret, "")
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func (h *Handlers) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	h, ok := h.forRequest(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.db.GetWebhook(id)
+	if err != nil || !h.ownsRecord(hook.OwnerID) {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	if err := h.db.DeleteWebhook(id); err != nil {
+		h.logger.Error("Failed to delete webhook", "error", err, "webhook_id", id)
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	h.logger.Info("Webhook deleted", "webhook_id", id)
+	h.renderWebhooks(w, r, "", "")
+}
+
+// listWebhooks returns the current user's webhooks with their most recent delivery
+func (h *Handlers) listWebhooks() ([]templates.WebhookRow, error) {
+	hooks, err := h.db.ListWebhooksByOwner(h.downloadOwner())
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]templates.WebhookRow, 0, len(hooks))
+	for _, hook := range hooks {
+		row := templates.WebhookRow{Webhook: hook}
+		deliveries, err := h.db.ListWebhookDeliveries(hook.ID, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(deliveries) > 0 {
+			row.LastDelivery = deliveries[0]
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// renderWebhooks renders the webhooks section of the settings page
+func (h *Handlers) renderWebhooks(w http.ResponseWriter, r *http.Request, newSecret, errorMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	rows, err := h.listWebhooks()
+	if err != nil {
+		h.logger.Error("Failed to list webhooks", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := templates.WebhooksSection(rows, newSecret, errorMessage).Render(r.Context(), w); err != nil {
+		h.logger.Error("Failed to render webhooks", "error", err)
+	}
+}
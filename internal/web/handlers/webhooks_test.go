@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/downloader"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlers_Webhooks(t *testing.T) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	handlers := NewHandlers(db, alldebrid.New("test-key"), "/downloads", downloader.NewWorker(db, "/downloads"))
+
+	alice := &models.User{Username: "alice", Role: models.RoleUser, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(alice))
+	bob := &models.User{Username: "bob", Role: models.RoleUser, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(bob))
+
+	form := url.Values{
+		"url":    {"https://hooks.example.com/done"},
+		"events": {"download.completed", "group.completed"},
+	}
+	w := httptest.NewRecorder()
+	handlers.CreateWebhook(w, requestAs(alice, "POST", "/settings/webhooks", form.Encode()))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "shown again")
+
+	hooks, err := db.ListWebhooksByOwner(alice.ID)
+	require.NoError(t, err)
+	require.Len(t, hooks, 1)
+	require.Equal(t, "download.completed,group.completed", hooks[0].Events)
+	require.Contains(t, w.Body.String(), hooks[0].Secret)
+
+	// Invalid URLs and events are reported in the section
+	form = url.Values{"url": {"ftp://example.com"}}
+	w = httptest.NewRecorder()
+	handlers.CreateWebhook(w, requestAs(alice, "POST", "/settings/webhooks", form.Encode()))
+	require.Contains(t, w.Body.String(), "Enter an http:// or https:// URL")
+
+	form = url.Values{"url": {"https://example.com"}, "events": {"download.exploded"}}
+	w = httptest.NewRecorder()
+	handlers.CreateWebhook(w, requestAs(alice, "POST", "/settings/webhooks", form.Encode()))
+	require.Contains(t, w.Body.String(), "Unknown event")
+
+	// Other users can neither see nor delete it
+	w = httptest.NewRecorder()
+	handlers.Settings(w, requestAs(bob, "GET", "/settings", ""))
+	require.NotContains(t, w.Body.String(), "hooks.example.com")
+
+	path := fmt.Sprintf("/settings/webhooks/%d", hooks[0].ID)
+	req := requestAs(bob, "DELETE", path, "")
+	req.SetPathValue("id", fmt.Sprint(hooks[0].ID))
+	w = httptest.NewRecorder()
+	handlers.DeleteWebhook(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	req = requestAs(alice, "DELETE", path, "")
+	req.SetPathValue("id", fmt.Sprint(hooks[0].ID))
+	w = httptest.NewRecorder()
+	handlers.DeleteWebhook(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), "No webhooks yet")
+}
@@ -0,0 +1,134 @@
+package qbittorrent
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"debrid-downloader/pkg/models"
+)
+
+// categoryInfo is a category in the format of qBittorrent's torrents/categories
+type categoryInfo struct {
+	Name     string `json:"name"`
+	SavePath string `json:"savePath"`
+}
+
+// Categories lists the categories and where each saves to
+func (h *Handler) Categories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.db.ListCategories()
+	if err != nil {
+		h.logger.Error("Failed to list categories", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	root, ok := h.root(w, r)
+	if !ok {
+		return
+	}
+
+	infos := make(map[string]categoryInfo, len(categories))
+	for _, category := range categories {
+		savePath, err := categoryPath(root, category)
+		if err != nil {
+			continue
+		}
+		infos[category.Name] = categoryInfo{Name: category.Name, SavePath: savePath}
+	}
+
+	writeJSON(w, infos)
+}
+
+// CreateCategory adds a category. Only admins can create categories, since they are
+// shared by every user.
+func (h *Handler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.FormValue("category"))
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		http.Error(w, "Invalid category name", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetCategoryByName(name); err == nil {
+		http.Error(w, "Category already exists", http.StatusConflict)
+		return
+	}
+
+	directory, ok := h.categoryDirectory(w, r)
+	if !ok {
+		return
+	}
+
+	category := models.NewCategory(name)
+	category.Directory = directory
+	if err := h.db.CreateCategory(category); err != nil {
+		h.logger.Error("Failed to create category", "category", name, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("Created category", "category", name, "directory", directory)
+	w.WriteHeader(http.StatusOK)
+}
+
+// EditCategory changes a category's save path
+func (h *Handler) EditCategory(w http.ResponseWriter, r *http.Request) {
+	category, err := h.db.GetCategoryByName(strings.TrimSpace(r.FormValue("category")))
+	if err != nil {
+		http.Error(w, "Category does not exist", http.StatusConflict)
+		return
+	}
+
+	directory, ok := h.categoryDirectory(w, r)
+	if !ok {
+		return
+	}
+
+	category.Directory = directory
+	if err := h.db.UpdateCategory(category); err != nil {
+		h.logger.Error("Failed to update category", "category", category.Name, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// RemoveCategories deletes the newline-separated categories
+func (h *Handler) RemoveCategories(w http.ResponseWriter, r *http.Request) {
+	for _, name := range strings.Split(r.FormValue("categories"), "\n") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if err := h.db.DeleteCategory(name); err != nil {
+			h.logger.Error("Failed to delete category", "category", name, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// categoryDirectory reads the savePath parameter as a directory relative to the
+// downloads folder, writing an error response if it can't be used
+func (h *Handler) categoryDirectory(w http.ResponseWriter, r *http.Request) (string, bool) {
+	savePath := strings.TrimSpace(r.FormValue("savePath"))
+	if savePath == "" {
+		return "", true
+	}
+
+	fullPath, err := resolvePath(h.folderService, savePath)
+	if err != nil {
+		http.Error(w, "Save path must be inside the downloads folder", http.StatusBadRequest)
+		return "", false
+	}
+
+	directory, err := filepath.Rel(h.folderService.BasePath, fullPath)
+	if err != nil {
+		http.Error(w, "Save path must be inside the downloads folder", http.StatusBadRequest)
+		return "", false
+	}
+	return directory, true
+}
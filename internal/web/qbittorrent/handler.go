@@ -0,0 +1,453 @@
+// Package qbittorrent emulates the parts of the qBittorrent WebUI API used by Sonarr
+// and Radarr, so they can send torrents here as if this were a qBittorrent client
+package qbittorrent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"debrid-downloader/internal/auth"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/folder"
+	"debrid-downloader/internal/torrent"
+	"debrid-downloader/pkg/models"
+)
+
+const (
+	// PathPrefix is where the API is mounted, matching qBittorrent
+	PathPrefix = "/api/v2/"
+
+	// SessionCookieName is the cookie qBittorrent clients expect the session in
+	SessionCookieName = "SID"
+
+	// Version is the qBittorrent release reported to clients
+	Version = "v4.6.7"
+	// WebAPIVersion is the WebUI API version reported to clients
+	WebAPIVersion = "2.9.3"
+
+	// maxTorrentFileSize limits .torrent files fetched from URLs or uploaded
+	maxTorrentFileSize = 10 << 20
+)
+
+// Handler serves the qBittorrent-compatible API
+type Handler struct {
+	db            *database.DB
+	torrents      *torrent.Service
+	auth          *auth.Service
+	folderService *folder.Service
+	httpClient    *http.Client
+	mux           *http.ServeMux
+	logger        *slog.Logger
+}
+
+// NewHandler creates a new qBittorrent API handler
+func NewHandler(db *database.DB, torrents *torrent.Service, authService *auth.Service, basePath string) *Handler {
+	h := &Handler{
+		db:            db,
+		torrents:      torrents,
+		auth:          authService,
+		folderService: folder.NewService(basePath),
+		httpClient:    &http.Client{Timeout: 30 * time.Second},
+		mux:           http.NewServeMux(),
+		logger:        slog.Default(),
+	}
+
+	h.mux.HandleFunc("POST /api/v2/auth/login", h.Login)
+	h.mux.HandleFunc("POST /api/v2/auth/logout", h.authenticated(h.Logout))
+
+	h.mux.HandleFunc("GET /api/v2/app/version", h.authenticated(h.AppVersion))
+	h.mux.HandleFunc("GET /api/v2/app/webapiVersion", h.authenticated(h.WebAPIVersion))
+	h.mux.HandleFunc("GET /api/v2/app/preferences", h.authenticated(h.Preferences))
+
+	h.mux.HandleFunc("GET /api/v2/torrents/info", h.authenticated(h.TorrentsInfo))
+	h.mux.HandleFunc("GET /api/v2/torrents/properties", h.authenticated(h.TorrentProperties))
+	h.mux.HandleFunc("GET /api/v2/torrents/files", h.authenticated(h.TorrentFiles))
+	h.mux.HandleFunc("POST /api/v2/torrents/add", h.authenticated(h.AddTorrents))
+	h.mux.HandleFunc("POST /api/v2/torrents/delete", h.authenticated(h.DeleteTorrents))
+	h.mux.HandleFunc("POST /api/v2/torrents/setCategory", h.authenticated(h.SetCategory))
+
+	h.mux.HandleFunc("GET /api/v2/torrents/categories", h.authenticated(h.Categories))
+	h.mux.HandleFunc("POST /api/v2/torrents/createCategory", h.authenticated(adminOnly(h.CreateCategory)))
+	h.mux.HandleFunc("POST /api/v2/torrents/editCategory", h.authenticated(adminOnly(h.EditCategory)))
+	h.mux.HandleFunc("POST /api/v2/torrents/removeCategories", h.authenticated(adminOnly(h.RemoveCategories)))
+
+	// Seeding and queue controls have no meaning for debrid downloads, but clients
+	// call them after adding a torrent and treat errors as failures
+	for _, action := range []string{"topPrio", "bottomPrio", "setShareLimits", "setForceStart"} {
+		h.mux.HandleFunc("POST /api/v2/torrents/"+action, h.authenticated(h.accepted))
+	}
+
+	return h
+}
+
+// ServeHTTP dispatches a request to the matching API endpoint
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// authenticated requires a session from the login endpoint when authentication is
+// enabled, and rejects cross-site requests the same way qBittorrent does
+func (h *Handler) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if crossOrigin(r) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !h.auth.Enabled() {
+			next(w, r)
+			return
+		}
+
+		cookie, err := r.Cookie(SessionCookieName)
+		if err != nil {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		session := h.auth.GetClientSession(cookie.Value)
+		if session == nil {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		user, err := h.auth.SessionUser(session)
+		if err != nil {
+			h.auth.DeleteClientSession(session.ID)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		ctx := auth.WithUser(r.Context(), user)
+		if session.Token != nil {
+			ctx = auth.WithAPIToken(ctx, session.Token)
+		}
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// adminOnly restricts a handler to admins that signed in with their password or an
+// admin-scoped API token
+func adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return auth.RequireScope(auth.RequireAdmin(next))
+}
+
+// canManageCategories reports whether the request may change the shared categories,
+// following the same rules as adminOnly
+func canManageCategories(r *http.Request) bool {
+	if user := auth.UserFromContext(r.Context()); user != nil && !user.IsAdmin() {
+		return false
+	}
+	token := auth.APITokenFromContext(r.Context())
+	return token == nil || token.Scope == models.ScopeAdmin
+}
+
+// crossOrigin reports whether a request was sent by a page on another host.
+// Clients such as Sonarr send neither header.
+func crossOrigin(r *http.Request) bool {
+	for _, header := range []string{"Origin", "Referer"} {
+		value := r.Header.Get(header)
+		if value == "" {
+			continue
+		}
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Host != r.Host {
+			return true
+		}
+	}
+	return false
+}
+
+// Login signs in with an account's password, or with an API token as the password.
+// Tokens need the submit or admin scope.
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+
+	if crossOrigin(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if !h.auth.Enabled() {
+		_, _ = w.Write([]byte("Ok."))
+		return
+	}
+
+	username := r.FormValue("username")
+	password := r.FormValue("password")
+
+	var token *models.APIToken
+	user, ok := h.auth.Authenticate(username, password)
+	if !ok {
+		token, user, ok = h.auth.AuthenticateAPIToken(password)
+		if ok && token.Scope != models.ScopeSubmit && token.Scope != models.ScopeAdmin {
+			ok = false
+		}
+	}
+	if !ok {
+		h.logger.Warn("Failed qBittorrent API login", "username", username, "remote_addr", r.RemoteAddr)
+		_, _ = w.Write([]byte("Fails."))
+		return
+	}
+
+	// The session is only valid on this API, and keeps the token's scope
+	session, err := h.auth.CreateClientSession(user, token)
+	if err != nil {
+		h.logger.Error("Failed to create session", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    session.ID,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	h.logger.Info("qBittorrent API login", "username", user.Username)
+	_, _ = w.Write([]byte("Ok."))
+}
+
+// Logout ends the session
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(SessionCookieName); err == nil {
+		h.auth.DeleteClientSession(cookie.Value)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// AppVersion reports the qBittorrent version
+func (h *Handler) AppVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	_, _ = w.Write([]byte(Version))
+}
+
+// WebAPIVersion reports the WebUI API version
+func (h *Handler) WebAPIVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	_, _ = w.Write([]byte(WebAPIVersion))
+}
+
+// Preferences reports the settings clients check before adding torrents
+func (h *Handler) Preferences(w http.ResponseWriter, r *http.Request) {
+	root, ok := h.root(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"save_path":                root.BasePath,
+		"temp_path_enabled":        false,
+		"queueing_enabled":         false,
+		"max_ratio_enabled":        false,
+		"max_ratio":                -1,
+		"max_seeding_time_enabled": false,
+		"max_seeding_time":         -1,
+		"max_ratio_act":            0,
+		"dht":                      false,
+	})
+}
+
+// accepted acknowledges a request that needs no action
+func (h *Handler) accepted(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// root returns the folder the requesting user's downloads are confined to. It writes
+// an error when the user's folder can't be used rather than fall back to the whole
+// downloads folder.
+func (h *Handler) root(w http.ResponseWriter, r *http.Request) (*folder.Service, bool) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil || user.IsAdmin() || user.Folder == "" {
+		return h.folderService, true
+	}
+	root, err := h.folderService.Subtree(user.Folder)
+	if err != nil {
+		h.logger.Error("Invalid user folder", "username", user.Username, "folder", user.Folder, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return root, true
+}
+
+// owner returns the ID new torrents are recorded against, and the owner filter for
+// listing: every owner for admins
+func owner(r *http.Request) (ownerID, filter int64) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil {
+		return 0, database.AllOwners
+	}
+	if user.IsAdmin() {
+		return user.ID, database.AllOwners
+	}
+	return user.ID, user.ID
+}
+
+// AddTorrents adds magnets, .torrent URLs and uploaded .torrent files
+func (h *Handler) AddTorrents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+
+	if err := r.ParseMultipartForm(maxTorrentFileSize); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	root, ok := h.root(w, r)
+	if !ok {
+		return
+	}
+
+	ownerID, _ := owner(r)
+	directory, category, err := h.addDirectory(r, root)
+	if err != nil {
+		h.logger.Warn("Rejected torrent save path", "error", err)
+		_, _ = w.Write([]byte("Fails."))
+		return
+	}
+	req := torrent.AddRequest{Directory: directory, Category: category, OwnerID: ownerID}
+
+	added := 0
+	for _, link := range strings.Fields(r.FormValue("urls")) {
+		var err error
+		if strings.HasPrefix(strings.ToLower(link), "magnet:") {
+			_, err = h.torrents.AddMagnet(r.Context(), link, req)
+		} else {
+			err = h.addTorrentURL(r.Context(), link, req)
+		}
+		if err != nil {
+			h.logger.Error("Failed to add torrent", "url", link, "error", err)
+			continue
+		}
+		added++
+	}
+
+	if r.MultipartForm != nil {
+		for _, header := range r.MultipartForm.File["torrents"] {
+			if err := h.addTorrentUpload(r.Context(), header, req); err != nil {
+				h.logger.Error("Failed to add torrent file", "filename", header.Filename, "error", err)
+				continue
+			}
+			added++
+		}
+	}
+
+	if added == 0 {
+		_, _ = w.Write([]byte("Fails."))
+		return
+	}
+	_, _ = w.Write([]byte("Ok."))
+}
+
+// addDirectory works out where an added torrent is saved: the savepath if given,
+// otherwise its category's directory. Unknown categories are created for admins and
+// rejected for everyone else.
+func (h *Handler) addDirectory(r *http.Request, root *folder.Service) (directory, categoryName string, err error) {
+	categoryName = strings.TrimSpace(r.FormValue("category"))
+
+	if savePath := strings.TrimSpace(r.FormValue("savepath")); savePath != "" {
+		directory, err = resolvePath(root, savePath)
+		return directory, categoryName, err
+	}
+
+	if categoryName == "" {
+		return root.BasePath, "", nil
+	}
+
+	category, err := h.db.GetCategoryByName(categoryName)
+	if err != nil {
+		if !canManageCategories(r) {
+			return "", "", fmt.Errorf("unknown category: %s", categoryName)
+		}
+		category = models.NewCategory(categoryName)
+		if err := h.db.CreateCategory(category); err != nil {
+			return "", "", err
+		}
+		h.logger.Info("Created category", "category", categoryName)
+	}
+
+	directory, err = categoryPath(root, category)
+	return directory, categoryName, err
+}
+
+// resolvePath accepts an absolute path inside the root, or a path relative to it
+func resolvePath(root *folder.Service, path string) (string, error) {
+	if filepath.IsAbs(path) {
+		if !root.Contains(path) {
+			return "", fmt.Errorf("path outside of downloads folder: %s", path)
+		}
+		return filepath.Clean(path), nil
+	}
+	return root.ValidatePath(path)
+}
+
+// categoryPath returns a category's directory within the root
+func categoryPath(root *folder.Service, category *models.Category) (string, error) {
+	return root.ValidatePath(category.DirectoryName())
+}
+
+// addTorrentURL fetches a .torrent file and adds it
+func (h *Handler) addTorrentURL(ctx context.Context, link string, req torrent.AddRequest) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := h.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to fetch torrent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch torrent: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxTorrentFileSize))
+	if err != nil {
+		return fmt.Errorf("failed to read torrent: %w", err)
+	}
+
+	filename := filepath.Base(httpReq.URL.Path)
+	if !strings.HasSuffix(strings.ToLower(filename), ".torrent") {
+		filename = "download.torrent"
+	}
+
+	_, err = h.torrents.AddTorrentFile(ctx, filename, data, req)
+	return err
+}
+
+// addTorrentUpload adds an uploaded .torrent file
+func (h *Handler) addTorrentUpload(ctx context.Context, header *multipart.FileHeader, req torrent.AddRequest) error {
+	file, err := header.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open upload: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxTorrentFileSize))
+	if err != nil {
+		return fmt.Errorf("failed to read upload: %w", err)
+	}
+
+	_, err = h.torrents.AddTorrentFile(ctx, header.Filename, data, req)
+	return err
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to encode JSON response", "error", err)
+	}
+}
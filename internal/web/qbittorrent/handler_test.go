@@ -0,0 +1,293 @@
+package qbittorrent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/alldebrid/mocks"
+	"debrid-downloader/internal/auth"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/submit"
+	"debrid-downloader/internal/torrent"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type nopQueue struct{}
+
+func (nopQueue) QueueDownload(downloadID int64)                       {}
+func (nopQueue) CancelCurrentDownloadIfMatches(downloadID int64) bool { return false }
+
+func newTestHandler(t *testing.T, opts auth.Options) (*Handler, *mocks.MockAllDebridClient, *database.DB, string) {
+	ctrl := gomock.NewController(t)
+
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	client := mocks.NewMockAllDebridClient(ctrl)
+	torrents := torrent.NewService(db, client, submit.NewService(db, client, nopQueue{}), nopQueue{})
+
+	opts.Tokens = db
+	opts.Users = db
+	basePath := t.TempDir()
+
+	return NewHandler(db, torrents, auth.NewService(opts), basePath), client, db, basePath
+}
+
+func form(method, target string, values url.Values) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestHandler_Login(t *testing.T) {
+	hash, err := auth.HashPassword("secret")
+	require.NoError(t, err)
+
+	handler, _, db, _ := newTestHandler(t, auth.Options{Username: "admin", PasswordHash: hash})
+
+	admin := &models.User{Username: "admin", PasswordHash: hash, Role: models.RoleAdmin, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(admin))
+
+	version := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v2/app/version", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	login := func(username, password string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, form("POST", "/api/v2/auth/login", url.Values{"username": {username}, "password": {password}}))
+		return w
+	}
+
+	t.Run("requests without a session are forbidden", func(t *testing.T) {
+		require.Equal(t, http.StatusForbidden, version(nil).Code)
+	})
+
+	t.Run("wrong password fails", func(t *testing.T) {
+		w := login("admin", "wrong")
+		require.Equal(t, "Fails.", w.Body.String())
+		require.Empty(t, w.Result().Cookies())
+	})
+
+	t.Run("password login returns a session cookie", func(t *testing.T) {
+		w := login("admin", "secret")
+		require.Equal(t, "Ok.", w.Body.String())
+
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		require.Equal(t, SessionCookieName, cookies[0].Name)
+
+		w = version(cookies[0])
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, Version, w.Body.String())
+	})
+
+	t.Run("API tokens with the submit scope can log in", func(t *testing.T) {
+		for scope, expected := range map[models.APITokenScope]string{models.ScopeSubmit: "Ok.", models.ScopeRead: "Fails."} {
+			token, prefix, tokenHash, err := auth.GenerateAPIToken()
+			require.NoError(t, err)
+			require.NoError(t, db.CreateAPIToken(&models.APIToken{
+				Name: "sonarr", Prefix: prefix, TokenHash: tokenHash, Scope: scope, CreatedAt: time.Now(), OwnerID: admin.ID,
+			}))
+
+			require.Equal(t, expected, login("sonarr", token).Body.String(), scope)
+		}
+	})
+
+	t.Run("token sessions keep the token's scope and aren't web sessions", func(t *testing.T) {
+		token, prefix, tokenHash, err := auth.GenerateAPIToken()
+		require.NoError(t, err)
+		require.NoError(t, db.CreateAPIToken(&models.APIToken{
+			Name: "radarr", Prefix: prefix, TokenHash: tokenHash, Scope: models.ScopeSubmit, CreatedAt: time.Now(), OwnerID: admin.ID,
+		}))
+
+		cookies := login("radarr", token).Result().Cookies()
+		require.Len(t, cookies, 1)
+		require.Nil(t, handler.auth.GetSession(cookies[0].Value))
+
+		// The token's owner is an admin, but the token only has the submit scope
+		for _, target := range []string{"/api/v2/torrents/createCategory", "/api/v2/torrents/editCategory", "/api/v2/torrents/removeCategories"} {
+			req := form("POST", target, url.Values{"category": {"tv"}, "categories": {"tv"}})
+			req.AddCookie(cookies[0])
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			require.Equal(t, http.StatusForbidden, w.Code, target)
+		}
+
+		// The UI middleware doesn't accept the SID as a session cookie
+		ui := handler.auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		req := httptest.NewRequest("GET", "/api/v1/export", nil)
+		req.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: cookies[0].Value})
+		w := httptest.NewRecorder()
+		ui.ServeHTTP(w, req)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("cross-site requests are rejected", func(t *testing.T) {
+		req := form("POST", "/api/v2/auth/login", url.Values{"username": {"admin"}, "password": {"secret"}})
+		req.Header.Set("Origin", "https://evil.example.com")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestHandler_Torrents(t *testing.T) {
+	handler, client, db, basePath := newTestHandler(t, auth.Options{})
+
+	client.EXPECT().
+		UploadMagnet(gomock.Any(), "magnet:?xt=urn:btih:ABC").
+		Return(&alldebrid.Magnet{ID: 1, Hash: "ABC", Name: "Show.S01E01", Size: 1000}, nil)
+	client.EXPECT().
+		MagnetStatus(gomock.Any(), int64(1)).
+		Return(&alldebrid.MagnetStatus{ID: 1, Status: "Downloading", StatusCode: 1}, nil)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, form("POST", "/api/v2/torrents/add", url.Values{"urls": {"magnet:?xt=urn:btih:ABC"}, "category": {"tv"}}))
+	require.Equal(t, "Ok.", w.Body.String())
+
+	info := func() []torrentInfo {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v2/torrents/info?category=tv", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var infos []torrentInfo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &infos))
+		return infos
+	}
+
+	t.Run("added torrents are fetched by the provider first", func(t *testing.T) {
+		infos := info()
+		require.Len(t, infos, 1)
+		require.Equal(t, "abc", infos[0].Hash)
+		require.Equal(t, "metaDL", infos[0].State)
+		require.Equal(t, "tv", infos[0].Category)
+		require.Equal(t, filepath.Join(basePath, "tv"), infos[0].SavePath)
+	})
+
+	t.Run("unknown categories are created", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/api/v2/torrents/categories", nil))
+
+		var categories map[string]categoryInfo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &categories))
+		require.Equal(t, filepath.Join(basePath, "tv"), categories["tv"].SavePath)
+	})
+
+	t.Run("completed groups are reported as finished", func(t *testing.T) {
+		stored, err := db.GetTorrent("abc")
+		require.NoError(t, err)
+
+		group := &models.DownloadGroup{ID: "group-1", CreatedAt: time.Now(), TotalDownloads: 1, Status: models.GroupStatusProcessing}
+		require.NoError(t, db.CreateDownloadGroup(group))
+		require.NoError(t, db.CreateDownload(&models.Download{
+			OriginalURL: "https://alldebrid.com/f/1",
+			Filename:    "Show.S01E01.mkv",
+			Directory:   stored.Directory,
+			Status:      models.StatusCompleted,
+			FileSize:    1000,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+			GroupID:     group.ID,
+		}))
+		stored.Status = models.TorrentStatusDownloading
+		stored.GroupID = group.ID
+		require.NoError(t, db.UpdateTorrent(stored))
+
+		// Still extracting
+		infos := info()
+		require.Equal(t, "checkingUP", infos[0].State)
+
+		group.Status = models.GroupStatusCompleted
+		require.NoError(t, db.UpdateDownloadGroup(group))
+
+		infos = info()
+		require.Equal(t, "pausedUP", infos[0].State)
+		require.Equal(t, 1.0, infos[0].Progress)
+		require.Equal(t, filepath.Join(basePath, "tv", "Show.S01E01.mkv"), infos[0].ContentPath)
+	})
+
+	t.Run("save paths outside the downloads folder are rejected", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, form("POST", "/api/v2/torrents/add", url.Values{"urls": {"magnet:?xt=urn:btih:DEF"}, "savepath": {"/etc"}}))
+		require.Equal(t, "Fails.", w.Body.String())
+	})
+
+	t.Run("deleting removes the torrent", func(t *testing.T) {
+		client.EXPECT().DeleteMagnet(gomock.Any(), int64(1)).Return(nil)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, form("POST", "/api/v2/torrents/delete", url.Values{"hashes": {"ABC"}, "deleteFiles": {"false"}}))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, info())
+	})
+}
+
+func TestHandler_ScopedToOwner(t *testing.T) {
+	handler, _, db, basePath := newTestHandler(t, auth.Options{})
+
+	alice := &models.User{ID: 2, Username: "alice", Role: models.RoleUser, Folder: "alice"}
+	for _, ownerID := range []int64{1, alice.ID} {
+		require.NoError(t, db.CreateTorrent(&models.Torrent{
+			Hash:      fmt.Sprintf("hash%d", ownerID),
+			Directory: basePath,
+			Status:    models.TorrentStatusQueued,
+			OwnerID:   ownerID,
+			AddedAt:   time.Now(),
+			UpdatedAt: time.Now(),
+		}))
+	}
+
+	req := httptest.NewRequest("GET", "/api/v2/torrents/info", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), alice))
+	w := httptest.NewRecorder()
+	handler.TorrentsInfo(w, req)
+
+	var infos []torrentInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &infos))
+	require.Len(t, infos, 1)
+	require.Equal(t, "hash2", infos[0].Hash)
+
+	// Restricted users save below their own folder
+	req = httptest.NewRequest("GET", "/api/v2/app/preferences", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), alice))
+	root, ok := handler.root(httptest.NewRecorder(), req)
+	require.True(t, ok)
+	require.Equal(t, filepath.Join(basePath, "alice"), root.BasePath)
+
+	// Only admins create categories by adding a torrent with one
+	req = form("POST", "/api/v2/torrents/add", url.Values{"urls": {"magnet:?xt=urn:btih:ABC"}, "category": {"anime"}})
+	req = req.WithContext(auth.WithUser(req.Context(), alice))
+	w = httptest.NewRecorder()
+	handler.AddTorrents(w, req)
+	require.Equal(t, "Fails.", w.Body.String())
+	_, err := db.GetCategoryByName("anime")
+	require.Error(t, err)
+
+	// An unusable folder fails rather than opening up the whole downloads folder
+	alice.Folder = "../outside"
+	req = httptest.NewRequest("GET", "/api/v2/app/preferences", nil)
+	req = req.WithContext(auth.WithUser(req.Context(), alice))
+	w = httptest.NewRecorder()
+	handler.Preferences(w, req)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,331 @@
+package qbittorrent
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"debrid-downloader/internal/torrent"
+	"debrid-downloader/pkg/models"
+)
+
+// infiniteETA is what qBittorrent reports when a torrent has no estimate
+const infiniteETA = 8640000
+
+// torrentInfo is a torrent in the format of qBittorrent's torrents/info
+type torrentInfo struct {
+	Hash             string  `json:"hash"`
+	Name             string  `json:"name"`
+	Size             int64   `json:"size"`
+	TotalSize        int64   `json:"total_size"`
+	Progress         float64 `json:"progress"`
+	Downloaded       int64   `json:"downloaded"`
+	Completed        int64   `json:"completed"`
+	AmountLeft       int64   `json:"amount_left"`
+	DLSpeed          int64   `json:"dlspeed"`
+	UPSpeed          int64   `json:"upspeed"`
+	ETA              int64   `json:"eta"`
+	State            string  `json:"state"`
+	Category         string  `json:"category"`
+	Tags             string  `json:"tags"`
+	SavePath         string  `json:"save_path"`
+	ContentPath      string  `json:"content_path"`
+	Ratio            float64 `json:"ratio"`
+	RatioLimit       float64 `json:"ratio_limit"`
+	SeedingTime      int64   `json:"seeding_time"`
+	SeedingTimeLimit int64   `json:"seeding_time_limit"`
+	AddedOn          int64   `json:"added_on"`
+	CompletionOn     int64   `json:"completion_on"`
+	LastActivity     int64   `json:"last_activity"`
+}
+
+// torrentFile is a file in the format of qBittorrent's torrents/files
+type torrentFile struct {
+	Index    int     `json:"index"`
+	Name     string  `json:"name"`
+	Size     int64   `json:"size"`
+	Progress float64 `json:"progress"`
+	Priority int     `json:"priority"`
+	IsSeed   bool    `json:"is_seed"`
+}
+
+// TorrentsInfo lists torrents, optionally filtered by category and hashes
+func (h *Handler) TorrentsInfo(w http.ResponseWriter, r *http.Request) {
+	_, filter := owner(r)
+
+	torrents, err := h.db.ListTorrents(filter, r.URL.Query().Get("category"))
+	if err != nil {
+		h.logger.Error("Failed to list torrents", "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	hashes := parseHashes(r.URL.Query().Get("hashes"))
+
+	infos := []torrentInfo{}
+	for _, t := range torrents {
+		if hashes != nil && !hashes[t.Hash] {
+			continue
+		}
+		infos = append(infos, h.info(t))
+	}
+
+	writeJSON(w, infos)
+}
+
+// TorrentProperties reports a torrent's general properties
+func (h *Handler) TorrentProperties(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.ownedTorrent(r, r.URL.Query().Get("hash"))
+	if !ok {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	info := h.info(t)
+	writeJSON(w, map[string]any{
+		"save_path":            info.SavePath,
+		"total_size":           info.TotalSize,
+		"total_downloaded":     info.Downloaded,
+		"dl_speed":             info.DLSpeed,
+		"eta":                  info.ETA,
+		"share_ratio":          0,
+		"seeding_time":         0,
+		"addition_date":        info.AddedOn,
+		"completion_date":      info.CompletionOn,
+		"last_seen":            info.LastActivity,
+		"pieces_have":          0,
+		"pieces_num":           0,
+		"piece_size":           0,
+		"is_private":           false,
+		"time_elapsed":         0,
+		"nb_connections":       0,
+		"nb_connections_limit": 0,
+	})
+}
+
+// TorrentFiles lists a torrent's files, relative to its save path
+func (h *Handler) TorrentFiles(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.ownedTorrent(r, r.URL.Query().Get("hash"))
+	if !ok {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	downloads := h.downloads(t)
+	files := []torrentFile{}
+	for i, download := range downloads {
+		name := download.Filename
+		if len(downloads) > 1 {
+			name = filepath.Join(torrent.FolderName(t), name)
+		}
+		files = append(files, torrentFile{
+			Index:    i,
+			Name:     name,
+			Size:     download.FileSize,
+			Progress: download.Progress / 100,
+			Priority: 1,
+			IsSeed:   download.Status == models.StatusCompleted,
+		})
+	}
+
+	writeJSON(w, files)
+}
+
+// DeleteTorrents removes torrents, and their files when deleteFiles is true
+func (h *Handler) DeleteTorrents(w http.ResponseWriter, r *http.Request) {
+	deleteFiles := r.FormValue("deleteFiles") == "true"
+
+	for _, t := range h.selectTorrents(r, r.FormValue("hashes")) {
+		if err := h.torrents.Remove(r.Context(), t, deleteFiles); err != nil {
+			h.logger.Error("Failed to remove torrent", "hash", t.Hash, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// SetCategory changes the category of torrents. Files already downloaded are not moved.
+func (h *Handler) SetCategory(w http.ResponseWriter, r *http.Request) {
+	category := strings.TrimSpace(r.FormValue("category"))
+	if category != "" {
+		if _, err := h.db.GetCategoryByName(category); err != nil {
+			http.Error(w, "Category does not exist", http.StatusConflict)
+			return
+		}
+	}
+
+	for _, t := range h.selectTorrents(r, r.FormValue("hashes")) {
+		t.Category = category
+		if err := h.db.UpdateTorrent(t); err != nil {
+			h.logger.Error("Failed to set torrent category", "hash", t.Hash, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// selectTorrents returns the requesting user's torrents matching a hashes parameter,
+// which is either "all" or hashes separated by "|"
+func (h *Handler) selectTorrents(r *http.Request, param string) []*models.Torrent {
+	_, filter := owner(r)
+
+	torrents, err := h.db.ListTorrents(filter, "")
+	if err != nil {
+		h.logger.Error("Failed to list torrents", "error", err)
+		return nil
+	}
+
+	if param == "all" {
+		return torrents
+	}
+
+	hashes := parseHashes(param)
+
+	var selected []*models.Torrent
+	for _, t := range torrents {
+		if hashes[t.Hash] {
+			selected = append(selected, t)
+		}
+	}
+	return selected
+}
+
+// ownedTorrent looks up a torrent the requesting user can see
+func (h *Handler) ownedTorrent(r *http.Request, hash string) (*models.Torrent, bool) {
+	t, err := h.db.GetTorrent(strings.ToLower(hash))
+	if err != nil {
+		return nil, false
+	}
+	_, filter := owner(r)
+	if filter != 0 && t.OwnerID != filter {
+		return nil, false
+	}
+	return t, true
+}
+
+// parseHashes splits a "|"-separated list of hashes, returning nil when empty or "all"
+func parseHashes(param string) map[string]bool {
+	if param == "" || param == "all" {
+		return nil
+	}
+	hashes := make(map[string]bool)
+	for _, hash := range strings.Split(param, "|") {
+		hashes[strings.ToLower(strings.TrimSpace(hash))] = true
+	}
+	return hashes
+}
+
+// downloads returns the downloads a torrent's files were queued as
+func (h *Handler) downloads(t *models.Torrent) []*models.Download {
+	if t.GroupID == "" {
+		return nil
+	}
+	downloads, err := h.db.GetDownloadsByGroupID(t.GroupID)
+	if err != nil {
+		h.logger.Warn("Failed to get torrent downloads", "hash", t.Hash, "error", err)
+		return nil
+	}
+	return downloads
+}
+
+// info reports a torrent the way qBittorrent would, combining the provider's state
+// with the progress of its downloads
+func (h *Handler) info(t *models.Torrent) torrentInfo {
+	downloads := h.downloads(t)
+
+	info := torrentInfo{
+		Hash:             t.Hash,
+		Name:             t.Name,
+		Category:         t.Category,
+		SavePath:         t.Directory,
+		ContentPath:      filepath.Join(t.Directory, t.Name),
+		RatioLimit:       -2,
+		SeedingTimeLimit: -2,
+		ETA:              infiniteETA,
+		AddedOn:          t.AddedAt.Unix(),
+		LastActivity:     t.UpdatedAt.Unix(),
+	}
+
+	var size, downloaded int64
+	var speed float64
+	var completed, active, paused, failed int
+	var completedAt int64
+	for _, download := range downloads {
+		size += download.FileSize
+		speed += download.DownloadSpeed
+		switch download.Status {
+		case models.StatusCompleted:
+			completed++
+			downloaded += download.FileSize
+			if download.CompletedAt != nil && download.CompletedAt.Unix() > completedAt {
+				completedAt = download.CompletedAt.Unix()
+			}
+		case models.StatusDownloading:
+			active++
+			downloaded += download.DownloadedBytes
+		case models.StatusPaused:
+			paused++
+			downloaded += download.DownloadedBytes
+		case models.StatusFailed:
+			failed++
+		}
+	}
+	if size == 0 {
+		size = t.Size
+	}
+
+	switch len(downloads) {
+	case 0:
+	case 1:
+		info.ContentPath = filepath.Join(downloads[0].Directory, downloads[0].Filename)
+	default:
+		info.ContentPath = filepath.Join(t.Directory, torrent.FolderName(t))
+	}
+
+	var group *models.DownloadGroup
+	if t.GroupID != "" {
+		group, _ = h.db.GetDownloadGroup(t.GroupID)
+	}
+
+	switch {
+	case t.Status == models.TorrentStatusQueued:
+		info.State = "metaDL"
+	case t.Status == models.TorrentStatusFailed, failed > 0,
+		group != nil && group.Status == models.GroupStatusFailed:
+		info.State = "error"
+	case group != nil && group.Status == models.GroupStatusCompleted:
+		// Paused and seeded means done to Sonarr and Radarr, so they import and remove it
+		info.State = "pausedUP"
+		downloaded = size
+		info.CompletionOn = completedAt
+	case len(downloads) > 0 && completed == len(downloads):
+		// Downloaded, but archives are still being extracted
+		info.State = "checkingUP"
+		downloaded = size
+	case active > 0:
+		info.State = "downloading"
+	case paused > 0:
+		info.State = "pausedDL"
+	default:
+		info.State = "queuedDL"
+	}
+
+	info.Size = size
+	info.TotalSize = size
+	info.Downloaded = downloaded
+	info.Completed = downloaded
+	info.AmountLeft = max(size-downloaded, 0)
+	info.DLSpeed = int64(speed)
+	if size > 0 {
+		info.Progress = float64(downloaded) / float64(size)
+	}
+	if info.DLSpeed > 0 {
+		info.ETA = info.AmountLeft / info.DLSpeed
+	}
+
+	return info
+}
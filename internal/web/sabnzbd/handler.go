@@ -0,0 +1,388 @@
+// Package sabnzbd emulates the parts of the SABnzbd API that automation tools use to
+// send links to a downloader and follow their progress
+package sabnzbd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"debrid-downloader/internal/auth"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/folder"
+	"debrid-downloader/internal/submit"
+	"debrid-downloader/pkg/models"
+)
+
+const (
+	// Version is the SABnzbd release reported to clients
+	Version = "4.3.3"
+
+	// nzoPrefix is prepended to download IDs to form SABnzbd job IDs
+	nzoPrefix = "SABnzbd_nzo_"
+
+	// maxSlots caps how many downloads are read for one queue or history request
+	maxSlots = 1000
+
+	// defaultCategory is SABnzbd's name for "no category"
+	defaultCategory = "*"
+)
+
+// Paths are where the API is mounted; SABnzbd serves it at both
+var Paths = []string{"/api", "/sabnzbd/api"}
+
+// Canceller stops a download the worker is currently running
+type Canceller interface {
+	CancelCurrentDownloadIfMatches(downloadID int64) bool
+}
+
+// Handler serves the SABnzbd-compatible API
+type Handler struct {
+	db            *database.DB
+	submitter     *submit.Service
+	canceller     Canceller
+	auth          *auth.Service
+	folderService *folder.Service
+	logger        *slog.Logger
+}
+
+// NewHandler creates a new SABnzbd API handler
+func NewHandler(db *database.DB, submitter *submit.Service, canceller Canceller, authService *auth.Service, basePath string) *Handler {
+	return &Handler{
+		db:            db,
+		submitter:     submitter,
+		canceller:     canceller,
+		auth:          authService,
+		folderService: folder.NewService(basePath),
+		logger:        slog.Default(),
+	}
+}
+
+// ServeHTTP dispatches a request on its mode parameter. Responses are always JSON.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	mode := r.FormValue("mode")
+
+	// SABnzbd answers these without a key so clients can detect it
+	switch mode {
+	case "version":
+		writeJSON(w, map[string]string{"version": Version})
+		return
+	case "auth":
+		writeJSON(w, map[string]string{"auth": "apikey"})
+		return
+	}
+
+	user, ok := h.authenticate(w, r, mode == "addurl")
+	if !ok {
+		return
+	}
+	r = r.WithContext(auth.WithUser(r.Context(), user))
+
+	switch mode {
+	case "addurl":
+		h.AddURL(w, r)
+	case "queue":
+		h.Queue(w, r)
+	case "history":
+		h.History(w, r)
+	case "get_cats":
+		h.Categories(w, r)
+	case "get_config":
+		h.Config(w, r)
+	default:
+		writeError(w, "not implemented")
+	}
+}
+
+// authenticate checks the apikey parameter against the API tokens. Adding links needs
+// a token with the submit or admin scope; any token can read the queue and history.
+// As elsewhere, no key is needed while authentication is disabled.
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request, submits bool) (*models.User, bool) {
+	if !h.auth.Enabled() {
+		return nil, true
+	}
+
+	key := r.FormValue("apikey")
+	if key == "" {
+		writeError(w, "API Key Required")
+		return nil, false
+	}
+
+	token, user, ok := h.auth.AuthenticateAPIToken(key)
+	if !ok {
+		h.logger.Warn("Rejected SABnzbd API request with invalid key", "remote_addr", r.RemoteAddr)
+		writeError(w, "API Key Incorrect")
+		return nil, false
+	}
+
+	if submits && token.Scope != models.ScopeSubmit && token.Scope != models.ScopeAdmin {
+		writeError(w, "API Key Incorrect")
+		return nil, false
+	}
+
+	return user, true
+}
+
+// AddURL submits the link in the name parameter, saving it to the cat category's directory
+func (h *Handler) AddURL(w http.ResponseWriter, r *http.Request) {
+	link := strings.TrimSpace(r.FormValue("name"))
+	if link == "" {
+		writeError(w, "expects one parameter")
+		return
+	}
+
+	root, ok := h.root(w, r)
+	if !ok {
+		return
+	}
+
+	directory, err := h.categoryDirectory(root, r.FormValue("cat"))
+	if err != nil {
+		h.logger.Warn("Rejected SABnzbd category", "category", r.FormValue("cat"), "error", err)
+		writeError(w, "Invalid category")
+		return
+	}
+
+	user := auth.UserFromContext(r.Context())
+	var ownerID int64
+	if user != nil {
+		ownerID = user.ID
+	}
+
+	result, err := h.submitter.Submit(r.Context(), submit.Request{
+		URLs:      []string{link},
+		Directory: directory,
+		OwnerID:   ownerID,
+		Category:  categoryName(r.FormValue("cat")),
+	})
+	if err != nil {
+		h.logger.Error("Failed to add SABnzbd URL", "url", link, "error", err)
+		var submitErr *submit.Error
+		if errors.As(err, &submitErr) {
+			writeError(w, submitErr.Message)
+			return
+		}
+		writeError(w, "Failed to add URL")
+		return
+	}
+
+	ids := make([]string, 0, len(result.Items))
+	for _, item := range result.Items {
+		ids = append(ids, nzoID(item.Download.ID))
+	}
+
+	writeJSON(w, map[string]any{"status": true, "nzo_ids": ids})
+}
+
+// root returns the folder the requesting user's downloads are confined to. It writes
+// an error when the user's folder can't be used rather than fall back to the whole
+// downloads folder.
+func (h *Handler) root(w http.ResponseWriter, r *http.Request) (*folder.Service, bool) {
+	user := auth.UserFromContext(r.Context())
+	if user == nil || user.IsAdmin() || user.Folder == "" {
+		return h.folderService, true
+	}
+	root, err := h.folderService.Subtree(user.Folder)
+	if err != nil {
+		h.logger.Error("Invalid user folder", "username", user.Username, "folder", user.Folder, "error", err)
+		writeError(w, "Invalid user folder")
+		return nil, false
+	}
+	return root, true
+}
+
+// ownerFilter returns the owner whose downloads a request can see, every owner for admins
+func ownerFilter(r *http.Request) int64 {
+	user := auth.UserFromContext(r.Context())
+	if user == nil || user.IsAdmin() {
+		return database.AllOwners
+	}
+	return user.ID
+}
+
+// categoryDirectory returns the directory for an existing category within the root.
+// Unknown categories are rejected, since only admins manage categories. No category
+// saves to the root itself.
+func (h *Handler) categoryDirectory(root *folder.Service, name string) (string, error) {
+	name = categoryName(name)
+	if name == "" {
+		return root.BasePath, nil
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid category name: %s", name)
+	}
+
+	category, err := h.db.GetCategoryByName(name)
+	if err != nil {
+		return "", fmt.Errorf("unknown category: %s", name)
+	}
+
+	return root.ValidatePath(category.DirectoryName())
+}
+
+// categoryName returns the name of a requested category, empty for the default category
+func categoryName(name string) string {
+	name = strings.TrimSpace(name)
+	if name == defaultCategory || strings.EqualFold(name, "default") {
+		return ""
+	}
+	return name
+}
+
+// categoryPath is a category and its directory
+type categoryPath struct {
+	Name string
+	Path string
+}
+
+// categoryPaths returns each category's directory within the root
+func (h *Handler) categoryPaths(root *folder.Service) ([]categoryPath, error) {
+	categories, err := h.db.ListCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []categoryPath
+	for _, category := range categories {
+		if path, err := root.ValidatePath(category.DirectoryName()); err == nil {
+			paths = append(paths, categoryPath{Name: category.Name, Path: path})
+		}
+	}
+	return paths, nil
+}
+
+// categoryFor returns the category whose directory holds a download. Downloads don't
+// record a category, so the most specific matching directory wins.
+func categoryFor(download *models.Download, paths []categoryPath) string {
+	best, bestLength := defaultCategory, -1
+	for _, category := range paths {
+		if !folder.NewService(category.Path).Contains(download.Directory) {
+			continue
+		}
+		if len(category.Path) > bestLength {
+			best, bestLength = category.Name, len(category.Path)
+		}
+	}
+	return best
+}
+
+// Categories lists the category names, SABnzbd's default category first
+func (h *Handler) Categories(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.db.ListCategories()
+	if err != nil {
+		h.logger.Error("Failed to list categories", "error", err)
+		writeError(w, "Failed to list categories")
+		return
+	}
+
+	names := []string{defaultCategory}
+	for _, category := range categories {
+		names = append(names, category.Name)
+	}
+
+	writeJSON(w, map[string]any{"categories": names})
+}
+
+// Config reports the completed downloads folder and categories, which clients read to
+// find downloaded files
+func (h *Handler) Config(w http.ResponseWriter, r *http.Request) {
+	root, ok := h.root(w, r)
+	if !ok {
+		return
+	}
+
+	paths, err := h.categoryPaths(root)
+	if err != nil {
+		h.logger.Error("Failed to list categories", "error", err)
+		writeError(w, "Failed to list categories")
+		return
+	}
+
+	categories := []map[string]any{{"name": defaultCategory, "dir": "", "order": 0, "pp": "3", "script": "None", "priority": 0}}
+	for _, category := range paths {
+		categories = append(categories, map[string]any{
+			"name": category.Name, "dir": category.Path, "order": len(categories), "pp": "3", "script": "None", "priority": 0,
+		})
+	}
+
+	writeJSON(w, map[string]any{
+		"config": map[string]any{
+			"misc": map[string]any{
+				"complete_dir":             root.BasePath,
+				"download_dir":             root.BasePath,
+				"pre_check":                false,
+				"enable_tv_sorting":        false,
+				"enable_movie_sorting":     false,
+				"enable_date_sorting":      false,
+				"history_retention":        "",
+				"history_retention_option": "all",
+			},
+			"categories": categories,
+			"sorters":    []any{},
+		},
+	})
+}
+
+// nzoID returns the SABnzbd job ID for a download
+func nzoID(downloadID int64) string {
+	return nzoPrefix + strconv.FormatInt(downloadID, 10)
+}
+
+// parseNzoID returns the download ID from a SABnzbd job ID
+func parseNzoID(id string) (int64, bool) {
+	downloadID, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(id), nzoPrefix), 10, 64)
+	return downloadID, err == nil
+}
+
+// deleteDownloads removes the comma-separated jobs the user can access, cancelling any
+// that are running and removing their files when deleteFiles is set
+func (h *Handler) deleteDownloads(r *http.Request, ids string, deleteFiles bool) {
+	filter := ownerFilter(r)
+
+	for _, id := range strings.Split(ids, ",") {
+		downloadID, ok := parseNzoID(id)
+		if !ok {
+			continue
+		}
+		download, err := h.db.GetDownload(downloadID)
+		if err != nil || (filter != database.AllOwners && download.OwnerID != filter) {
+			continue
+		}
+
+		if download.Status == models.StatusDownloading {
+			h.canceller.CancelCurrentDownloadIfMatches(download.ID)
+		}
+
+		if deleteFiles {
+			path := filepath.Join(download.Directory, download.Filename)
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				h.logger.Warn("Failed to remove downloaded file", "path", path, "error", err)
+			}
+		}
+
+		if err := h.db.DeleteDownload(download.ID); err != nil {
+			h.logger.Error("Failed to delete download", "download_id", download.ID, "error", err)
+			continue
+		}
+		h.logger.Info("Download deleted through SABnzbd API", "download_id", download.ID, "delete_files", deleteFiles)
+	}
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Failed to encode JSON response", "error", err)
+	}
+}
+
+// writeError writes an error the way SABnzbd does, with a 200 status
+func writeError(w http.ResponseWriter, message string) {
+	writeJSON(w, map[string]any{"status": false, "error": message})
+}
@@ -0,0 +1,205 @@
+package sabnzbd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/alldebrid/mocks"
+	"debrid-downloader/internal/auth"
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/submit"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+type nopQueue struct{}
+
+func (nopQueue) QueueDownload(downloadID int64)                       {}
+func (nopQueue) CancelCurrentDownloadIfMatches(downloadID int64) bool { return false }
+
+func newTestHandler(t *testing.T, opts auth.Options) (*Handler, *mocks.MockAllDebridClient, *database.DB, string) {
+	ctrl := gomock.NewController(t)
+
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	client := mocks.NewMockAllDebridClient(ctrl)
+
+	opts.Tokens = db
+	opts.Users = db
+	basePath := t.TempDir()
+
+	return NewHandler(db, submit.NewService(db, client, nopQueue{}), nopQueue{}, auth.NewService(opts), basePath), client, db, basePath
+}
+
+// call makes an API request and decodes the JSON response
+func call(t *testing.T, handler http.Handler, params url.Values) map[string]any {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/api?"+params.Encode(), nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestHandler_APIKey(t *testing.T) {
+	handler, _, db, _ := newTestHandler(t, auth.Options{Username: "admin", PasswordHash: "hash"})
+
+	admin := &models.User{Username: "admin", Role: models.RoleAdmin, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(admin))
+
+	keys := map[models.APITokenScope]string{}
+	for _, scope := range []models.APITokenScope{models.ScopeRead, models.ScopeSubmit} {
+		token, prefix, tokenHash, err := auth.GenerateAPIToken()
+		require.NoError(t, err)
+		require.NoError(t, db.CreateAPIToken(&models.APIToken{
+			Name: string(scope), Prefix: prefix, TokenHash: tokenHash, Scope: scope, CreatedAt: time.Now(), OwnerID: admin.ID,
+		}))
+		keys[scope] = token
+	}
+
+	// The version is public so clients can detect the API
+	require.Equal(t, Version, call(t, handler, url.Values{"mode": {"version"}})["version"])
+
+	response := call(t, handler, url.Values{"mode": {"queue"}})
+	require.Equal(t, "API Key Required", response["error"])
+
+	response = call(t, handler, url.Values{"mode": {"queue"}, "apikey": {"wrong"}})
+	require.Equal(t, "API Key Incorrect", response["error"])
+
+	response = call(t, handler, url.Values{"mode": {"queue"}, "apikey": {keys[models.ScopeRead]}})
+	require.Contains(t, response, "queue")
+
+	// Adding links needs the submit scope
+	response = call(t, handler, url.Values{"mode": {"addurl"}, "name": {"https://example.com/file.zip"}, "apikey": {keys[models.ScopeRead]}})
+	require.Equal(t, "API Key Incorrect", response["error"])
+}
+
+func TestHandler_AddURLAndQueue(t *testing.T) {
+	handler, client, db, basePath := newTestHandler(t, auth.Options{})
+	require.NoError(t, db.CreateCategory(models.NewCategory("tv")))
+
+	client.EXPECT().
+		UnrestrictLink(gomock.Any(), "https://example.com/show.mkv").
+		Return(&alldebrid.UnrestrictResult{UnrestrictedURL: "https://dl/show.mkv", Filename: "show.mkv", FileSize: 2 << 20}, nil)
+
+	response := call(t, handler, url.Values{"mode": {"addurl"}, "name": {"https://example.com/show.mkv"}, "cat": {"tv"}})
+	require.Equal(t, true, response["status"])
+	ids := response["nzo_ids"].([]any)
+	require.Len(t, ids, 1)
+
+	downloadID, ok := parseNzoID(ids[0].(string))
+	require.True(t, ok)
+	download, err := db.GetDownload(downloadID)
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(basePath, "tv"), download.Directory)
+
+	t.Run("categories are listed", func(t *testing.T) {
+		response := call(t, handler, url.Values{"mode": {"get_cats"}})
+		require.Equal(t, []any{"*", "tv"}, response["categories"])
+	})
+
+	t.Run("queue reports the download under its category", func(t *testing.T) {
+		queue := call(t, handler, url.Values{"mode": {"queue"}, "cat": {"tv"}})["queue"].(map[string]any)
+		slots := queue["slots"].([]any)
+		require.Len(t, slots, 1)
+
+		slot := slots[0].(map[string]any)
+		require.Equal(t, ids[0], slot["nzo_id"])
+		require.Equal(t, "tv", slot["cat"])
+		require.Equal(t, "Queued", slot["status"])
+		require.Equal(t, "2.00", slot["mb"])
+
+		queue = call(t, handler, url.Values{"mode": {"queue"}, "cat": {"movies"}})["queue"].(map[string]any)
+		require.Empty(t, queue["slots"])
+	})
+
+	t.Run("history reports where completed files are", func(t *testing.T) {
+		now := time.Now()
+		download.Status = models.StatusCompleted
+		download.StartedAt = &now
+		download.CompletedAt = &now
+		require.NoError(t, db.UpdateDownload(download))
+
+		history := call(t, handler, url.Values{"mode": {"history"}, "category": {"tv"}})["history"].(map[string]any)
+		slots := history["slots"].([]any)
+		require.Len(t, slots, 1)
+
+		slot := slots[0].(map[string]any)
+		require.Equal(t, "Completed", slot["status"])
+		require.Equal(t, filepath.Join(basePath, "tv", "show.mkv"), slot["storage"])
+	})
+
+	t.Run("history entries can be deleted", func(t *testing.T) {
+		response := call(t, handler, url.Values{"mode": {"history"}, "name": {"delete"}, "value": {ids[0].(string)}})
+		require.Equal(t, true, response["status"])
+
+		_, err := db.GetDownload(downloadID)
+		require.Error(t, err)
+	})
+
+	t.Run("config reports category directories", func(t *testing.T) {
+		config := call(t, handler, url.Values{"mode": {"get_config"}})["config"].(map[string]any)
+		require.Equal(t, basePath, config["misc"].(map[string]any)["complete_dir"])
+
+		categories := config["categories"].([]any)
+		require.Len(t, categories, 2)
+		require.Equal(t, filepath.Join(basePath, "tv"), categories[1].(map[string]any)["dir"])
+	})
+
+	t.Run("categories cannot leave the downloads folder", func(t *testing.T) {
+		response := call(t, handler, url.Values{"mode": {"addurl"}, "name": {"https://example.com/x.zip"}, "cat": {"../etc"}})
+		require.Equal(t, "Invalid category", response["error"])
+	})
+
+	t.Run("unknown categories are rejected", func(t *testing.T) {
+		response := call(t, handler, url.Values{"mode": {"addurl"}, "name": {"https://example.com/x.zip"}, "cat": {"anime"}})
+		require.Equal(t, "Invalid category", response["error"])
+		_, err := db.GetCategoryByName("anime")
+		require.Error(t, err)
+	})
+}
+
+func TestHandler_InvalidUserFolder(t *testing.T) {
+	handler, _, _, _ := newTestHandler(t, auth.Options{})
+
+	// A restricted user whose folder can't be used must not see the whole downloads folder
+	alice := &models.User{ID: 2, Username: "alice", Role: models.RoleUser, Folder: "../outside"}
+	for _, mode := range []string{"get_config", "queue", "addurl"} {
+		req := httptest.NewRequest("GET", "/api?mode="+mode+"&name=https://example.com/x.zip", nil)
+		req = req.WithContext(auth.WithUser(req.Context(), alice))
+		w := httptest.NewRecorder()
+		switch mode {
+		case "get_config":
+			handler.Config(w, req)
+		case "queue":
+			handler.Queue(w, req)
+		case "addurl":
+			handler.AddURL(w, req)
+		}
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Equal(t, "Invalid user folder", response["error"], mode)
+	}
+}
+
+func TestPage(t *testing.T) {
+	downloads := []*models.Download{{ID: 1}, {ID: 2}, {ID: 3}}
+	req := httptest.NewRequest("GET", "/api?start=1&limit=1", strings.NewReader(""))
+	require.Equal(t, []*models.Download{{ID: 2}}, page(downloads, req))
+
+	req = httptest.NewRequest("GET", "/api?start=0&limit=0", nil)
+	require.Len(t, page(downloads, req), 3)
+}
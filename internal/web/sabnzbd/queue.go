@@ -0,0 +1,221 @@
+package sabnzbd
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"debrid-downloader/pkg/models"
+)
+
+// queueSlot is a download in the format of SABnzbd's queue
+type queueSlot struct {
+	NzoID      string `json:"nzo_id"`
+	Index      int    `json:"index"`
+	Filename   string `json:"filename"`
+	Category   string `json:"cat"`
+	Status     string `json:"status"`
+	Priority   string `json:"priority"`
+	Percentage string `json:"percentage"`
+	MB         string `json:"mb"`
+	MBLeft     string `json:"mbleft"`
+	Size       string `json:"size"`
+	SizeLeft   string `json:"sizeleft"`
+	TimeLeft   string `json:"timeleft"`
+}
+
+// historySlot is a download in the format of SABnzbd's history
+type historySlot struct {
+	NzoID        string `json:"nzo_id"`
+	Name         string `json:"name"`
+	NzbName      string `json:"nzb_name"`
+	Category     string `json:"category"`
+	Status       string `json:"status"`
+	FailMessage  string `json:"fail_message"`
+	Bytes        int64  `json:"bytes"`
+	Size         string `json:"size"`
+	Storage      string `json:"storage"`
+	Path         string `json:"path"`
+	DownloadTime int64  `json:"download_time"`
+	Completed    int64  `json:"completed"`
+}
+
+// Queue lists downloads that haven't finished, or deletes them with name=delete
+func (h *Handler) Queue(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("name") == "delete" {
+		h.deleteDownloads(r, r.FormValue("value"), r.FormValue("del_files") == "1")
+		writeJSON(w, map[string]any{"status": true})
+		return
+	}
+
+	statuses := []string{string(models.StatusDownloading), string(models.StatusPending), string(models.StatusPaused)}
+	downloads, paths, ok := h.search(w, r, statuses, "asc", r.FormValue("cat"))
+	if !ok {
+		return
+	}
+
+	var speed float64
+	status := "Idle"
+	slots := []queueSlot{}
+	for i, download := range page(downloads, r) {
+		left := max(download.FileSize-download.DownloadedBytes, 0)
+		slot := queueSlot{
+			NzoID:      nzoID(download.ID),
+			Index:      i,
+			Filename:   download.Filename,
+			Category:   categoryFor(download, paths),
+			Status:     "Queued",
+			Priority:   "Normal",
+			Percentage: strconv.Itoa(int(download.Progress)),
+			MB:         megabytes(download.FileSize),
+			MBLeft:     megabytes(left),
+			Size:       megabytes(download.FileSize) + " MB",
+			SizeLeft:   megabytes(left) + " MB",
+			TimeLeft:   "0:00:00",
+		}
+
+		switch download.Status {
+		case models.StatusDownloading:
+			slot.Status = "Downloading"
+			status = "Downloading"
+			speed += download.DownloadSpeed
+			if download.DownloadSpeed > 0 {
+				slot.TimeLeft = formatDuration(time.Duration(float64(left)/download.DownloadSpeed) * time.Second)
+			}
+		case models.StatusPaused:
+			slot.Status = "Paused"
+		}
+
+		slots = append(slots, slot)
+	}
+
+	writeJSON(w, map[string]any{
+		"queue": map[string]any{
+			"status":    status,
+			"paused":    false,
+			"kbpersec":  fmt.Sprintf("%.2f", speed/1024),
+			"noofslots": len(downloads),
+			"slots":     slots,
+		},
+	})
+}
+
+// History lists finished downloads, or deletes them with name=delete
+func (h *Handler) History(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("name") == "delete" {
+		h.deleteDownloads(r, r.FormValue("value"), r.FormValue("del_files") == "1")
+		writeJSON(w, map[string]any{"status": true})
+		return
+	}
+
+	statuses := []string{string(models.StatusCompleted), string(models.StatusFailed)}
+	downloads, paths, ok := h.search(w, r, statuses, "desc", r.FormValue("category"))
+	if !ok {
+		return
+	}
+
+	slots := []historySlot{}
+	for _, download := range page(downloads, r) {
+		slot := historySlot{
+			NzoID:       nzoID(download.ID),
+			Name:        download.Filename,
+			NzbName:     download.Filename,
+			Category:    categoryFor(download, paths),
+			Status:      "Completed",
+			FailMessage: download.ErrorMessage,
+			Bytes:       download.FileSize,
+			Size:        megabytes(download.FileSize) + " MB",
+			Storage:     storagePath(download),
+			Path:        download.Directory,
+		}
+		if download.Status == models.StatusFailed {
+			slot.Status = "Failed"
+		}
+		if download.CompletedAt != nil {
+			slot.Completed = download.CompletedAt.Unix()
+			if download.StartedAt != nil {
+				slot.DownloadTime = int64(download.CompletedAt.Sub(*download.StartedAt).Seconds()) - download.TotalPausedTime
+			}
+		}
+		slots = append(slots, slot)
+	}
+
+	writeJSON(w, map[string]any{
+		"history": map[string]any{
+			"noofslots": len(downloads),
+			"slots":     slots,
+		},
+	})
+}
+
+// search returns the user's downloads in the given statuses, limited to a category when
+// one is given, along with the category directories used to match them
+func (h *Handler) search(w http.ResponseWriter, r *http.Request, statuses []string, sortOrder, category string) ([]*models.Download, []categoryPath, bool) {
+	root, ok := h.root(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	paths, err := h.categoryPaths(root)
+	if err != nil {
+		h.logger.Error("Failed to list categories", "error", err)
+		writeError(w, "Failed to list categories")
+		return nil, nil, false
+	}
+
+	downloads, err := h.db.SearchDownloadsByOwner(ownerFilter(r), "", statuses, sortOrder, maxSlots, 0)
+	if err != nil {
+		h.logger.Error("Failed to search downloads", "error", err)
+		writeError(w, "Failed to list downloads")
+		return nil, nil, false
+	}
+
+	if category == "" {
+		return downloads, paths, true
+	}
+
+	var filtered []*models.Download
+	for _, download := range downloads {
+		if categoryFor(download, paths) == category {
+			filtered = append(filtered, download)
+		}
+	}
+	return filtered, paths, true
+}
+
+// page applies the start and limit parameters; a limit of 0 means no limit
+func page(downloads []*models.Download, r *http.Request) []*models.Download {
+	start, _ := strconv.Atoi(r.FormValue("start"))
+	limit, _ := strconv.Atoi(r.FormValue("limit"))
+
+	if start < 0 || start >= len(downloads) {
+		return nil
+	}
+	downloads = downloads[start:]
+	if limit > 0 && limit < len(downloads) {
+		downloads = downloads[:limit]
+	}
+	return downloads
+}
+
+// storagePath is where a finished download's files are. Archives are extracted
+// next to the archive, so their directory is reported instead.
+func storagePath(download *models.Download) string {
+	if download.IsArchive {
+		return download.Directory
+	}
+	return filepath.Join(download.Directory, download.Filename)
+}
+
+// megabytes formats a byte count in MB as SABnzbd does
+func megabytes(bytes int64) string {
+	return fmt.Sprintf("%.2f", float64(bytes)/(1024*1024))
+}
+
+// formatDuration formats a duration as H:MM:SS
+func formatDuration(d time.Duration) string {
+	seconds := int64(d.Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
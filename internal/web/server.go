@@ -11,62 +11,258 @@ import (
 	"time"
 
 	"debrid-downloader/internal/alldebrid"
+	"debrid-downloader/internal/auth"
+	"debrid-downloader/internal/backup"
 	"debrid-downloader/internal/config"
 	"debrid-downloader/internal/database"
 	"debrid-downloader/internal/downloader"
+	"debrid-downloader/internal/hooks"
+	"debrid-downloader/internal/mediaserver"
+	"debrid-downloader/internal/metrics"
+	"debrid-downloader/internal/notify"
+	"debrid-downloader/internal/retention"
+	"debrid-downloader/internal/settings"
+	"debrid-downloader/internal/submit"
+	"debrid-downloader/internal/torrent"
+	"debrid-downloader/internal/watch"
 	"debrid-downloader/internal/web/handlers"
+	"debrid-downloader/internal/web/qbittorrent"
+	"debrid-downloader/internal/web/sabnzbd"
+	"debrid-downloader/internal/webhook"
+	"debrid-downloader/pkg/models"
 )
 
 // Server represents the HTTP server
 type Server struct {
 	server   *http.Server
 	handlers *handlers.Handlers
+	torrents *torrent.Service
+	webhooks *webhook.Dispatcher
+	notifier *notify.Service
+	media    *mediaserver.Refresher
+	hooks    *hooks.Runner
+	watcher  *watch.Watcher     // nil unless a watch folder is configured
+	backups  *backup.Scheduler  // nil unless scheduled backups are configured
+	history  *retention.Service // nil until SetRetention is called
 	logger   *slog.Logger
 }
 
 // NewServer creates a new HTTP server
 func NewServer(db *database.DB, client alldebrid.AllDebridClient, cfg *config.Config, worker *downloader.Worker) *Server {
+	client = metrics.InstrumentClient(client)
+
+	authService := auth.NewService(auth.Options{
+		Username:       cfg.AuthUsername,
+		PasswordHash:   cfg.AuthPasswordHash,
+		ProxyHeader:    cfg.AuthProxyHeader,
+		TrustedProxies: cfg.AuthTrustedProxies,
+		SessionTTL:     cfg.SessionTTL,
+		SecureCookies:  cfg.SecureCookies,
+		Tokens:         db,
+		Users:          db,
+	})
+	authHandlers := handlers.NewAuthHandlers(authService)
 	handlers := handlers.NewHandlers(db, client, cfg.BaseDownloadsPath, worker)
+	handlers.SetHooksPath(cfg.HooksPath)
+	handlers.SetBackupPath(cfg.BackupPath)
+	submitter := submit.NewService(db, client, worker)
+	torrents := torrent.NewService(db, client, submitter, worker)
+	webhooks := webhook.NewDispatcher(db)
+	worker.Events().Subscribe(webhooks.Handle)
+
+	channels, err := notify.ChannelsFromConfig(cfg)
+	if err != nil {
+		slog.Error("Notifications disabled", "error", err)
+	}
+	notifier := notify.NewService(db, channels)
+	worker.Events().Subscribe(notifier.Handle)
+	media := mediaserver.NewRefresher(db)
+	worker.Events().Subscribe(media.Handle)
+	hookRunner := hooks.NewRunner(db, cfg.HooksPath, cfg.BaseDownloadsPath)
+	worker.Events().Subscribe(hookRunner.Handle)
 
 	mux := http.NewServeMux()
 
+	// Authentication
+	mux.HandleFunc("GET /login", authHandlers.LoginPage)
+	mux.HandleFunc("POST /login", authHandlers.Login)
+	mux.HandleFunc("POST /logout", authHandlers.Logout)
+
+	// Routes are listed with the API token scopes allowed to call them.
+	// Browser sessions and admin tokens can call every route.
+	read := []models.APITokenScope{models.ScopeRead}
+	submit := []models.APITokenScope{models.ScopeSubmit}
+	readOrSubmit := []models.APITokenScope{models.ScopeRead, models.ScopeSubmit}
+	route := func(pattern string, handler http.HandlerFunc, scopes ...models.APITokenScope) {
+		mux.HandleFunc(pattern, auth.RequireScope(handler, scopes...))
+	}
+	// adminRoute additionally requires the signed-in user to have the admin role
+	adminRoute := func(pattern string, handler http.HandlerFunc) {
+		route(pattern, auth.RequireAdmin(handler))
+	}
+
 	// Routes
-	mux.HandleFunc("GET /", handlers.Home)
-	mux.HandleFunc("GET /settings", handlers.Settings)
+	route("GET /", handlers.Home, read...)
+	route("GET /settings", handlers.Settings)
+	route("POST /settings/tokens", handlers.CreateAPIToken)
+	route("DELETE /settings/tokens/{id}", handlers.RevokeAPIToken)
+	route("POST /settings/webhooks", handlers.CreateWebhook)
+	route("DELETE /settings/webhooks/{id}", handlers.DeleteWebhook)
+	adminRoute("POST /settings/general", handlers.UpdateGeneralSettings)
+	adminRoute("POST /settings/general/reset", handlers.ResetGeneralSettings)
+	adminRoute("POST /settings/history", handlers.UpdateHistorySettings)
+	adminRoute("POST /settings/history/run", handlers.RunHistoryCleanup)
+	adminRoute("POST /settings/media-servers", handlers.CreateMediaServer)
+	adminRoute("DELETE /settings/media-servers/{id}", handlers.DeleteMediaServer)
+	adminRoute("POST /settings/hooks", handlers.CreateHook)
+	adminRoute("DELETE /settings/hooks/{id}", handlers.DeleteHook)
+	adminRoute("POST /settings/categories", handlers.CreateCategory)
+	adminRoute("POST /settings/categories/defaults", handlers.CreateDefaultCategories)
+	adminRoute("POST /settings/categories/{id}", handlers.UpdateCategory)
+	adminRoute("DELETE /settings/categories/{id}", handlers.DeleteCategory)
+	adminRoute("POST /settings/rules", handlers.CreateRule)
+	adminRoute("POST /settings/rules/test", handlers.TestRule)
+	adminRoute("POST /settings/rules/{id}", handlers.UpdateRule)
+	adminRoute("POST /settings/rules/{id}/move", handlers.MoveRule)
+	adminRoute("DELETE /settings/rules/{id}", handlers.DeleteRule)
+	adminRoute("POST /settings/import", handlers.ImportData)
+	adminRoute("POST /settings/users", handlers.CreateUser)
+	adminRoute("POST /settings/users/{id}", handlers.UpdateUser)
+	adminRoute("DELETE /settings/users/{id}", handlers.DeleteUser)
 
 	// HTMX partial endpoints
-	mux.HandleFunc("GET /downloads/current", handlers.CurrentDownloads)
-	mux.HandleFunc("POST /download", handlers.SubmitDownload)
-	mux.HandleFunc("POST /downloads/search", handlers.SearchDownloads)
-	mux.HandleFunc("POST /downloads/progress", handlers.UpdateDownloadProgress)
-	mux.HandleFunc("POST /downloads/{id}/retry", handlers.RetryDownload)
-	mux.HandleFunc("POST /downloads/{id}/pause", handlers.PauseDownload)
-	mux.HandleFunc("POST /downloads/{id}/resume", handlers.ResumeDownload)
-	mux.HandleFunc("DELETE /downloads/{id}", handlers.DeleteDownload)
-	mux.HandleFunc("GET /api/stats", handlers.GetDownloadStats)
-	mux.HandleFunc("GET /api/directory-suggestion", handlers.GetDirectorySuggestion)
-	mux.HandleFunc("POST /api/directory-suggestion", handlers.GetDirectorySuggestion)
-	mux.HandleFunc("POST /api/test/failed-download", handlers.CreateTestFailedDownload)
+	route("GET /downloads/current", handlers.CurrentDownloads, read...)
+	route("POST /download", handlers.SubmitDownload, submit...)
+	route("POST /downloads/search", handlers.SearchDownloads, read...)
+	route("GET /presets", handlers.FilterPresets, read...)
+	route("POST /presets", handlers.SaveFilterPreset)
+	route("DELETE /presets/{id}", handlers.DeleteFilterPreset)
+	route("POST /downloads/progress", handlers.UpdateDownloadProgress, read...)
+	route("POST /downloads/bulk", handlers.BulkDownloads)
+	route("POST /groups/{id}/{action}", handlers.GroupAction)
+	route("DELETE /groups/{id}", handlers.DeleteGroup)
+	route("POST /downloads/{id}/retry", handlers.RetryDownload)
+	route("POST /downloads/{id}/pause", handlers.PauseDownload)
+	route("POST /downloads/{id}/resume", handlers.ResumeDownload)
+	route("DELETE /downloads/{id}", handlers.DeleteDownload)
+	route("POST /downloads/{id}/pin", handlers.PinDownload)
+	route("DELETE /downloads/{id}/pin", handlers.UnpinDownload)
+	route("GET /api/stats", handlers.GetDownloadStats, read...)
+	route("GET /api/directory-suggestion", handlers.GetDirectorySuggestion, readOrSubmit...)
+	route("POST /api/directory-suggestion", handlers.GetDirectorySuggestion, readOrSubmit...)
+	adminRoute("POST /api/test/failed-download", handlers.CreateTestFailedDownload)
 
 	// Folder browsing API endpoints
-	mux.HandleFunc("GET /api/folders", handlers.BrowseFolders)
-	mux.HandleFunc("POST /api/folders", handlers.CreateFolder)
+	route("GET /api/folders", handlers.BrowseFolders, readOrSubmit...)
+	route("POST /api/folders", handlers.CreateFolder)
+
+	// JSON API for scripts and integrations
+	route("GET /api/v1/downloads", handlers.APIListDownloads, read...)
+	route("GET /api/v1/downloads/{id}", handlers.APIGetDownload, read...)
+	route("POST /api/v1/downloads", handlers.APISubmitDownload, submit...)
+	route("POST /api/v1/downloads/bulk", handlers.APIBulkDownloads, submit...)
+	route("POST /api/v1/downloads/{id}/pause", handlers.APIPauseDownload, submit...)
+	route("POST /api/v1/downloads/{id}/resume", handlers.APIResumeDownload, submit...)
+	route("POST /api/v1/downloads/{id}/retry", handlers.APIRetryDownload, submit...)
+	route("DELETE /api/v1/downloads/{id}", handlers.APIDeleteDownload, submit...)
+	route("POST /api/v1/downloads/{id}/pin", handlers.APIPinDownload, submit...)
+	route("DELETE /api/v1/downloads/{id}/pin", handlers.APIUnpinDownload, submit...)
+	route("GET /api/v1/groups", handlers.APIListGroups, read...)
+	route("GET /api/v1/groups/{id}", handlers.APIGetGroup, read...)
+	route("POST /api/v1/groups/{id}/{action}", handlers.APIGroupAction, submit...)
+	route("DELETE /api/v1/groups/{id}", handlers.APIDeleteGroup, submit...)
+	route("GET /api/v1/presets", handlers.APIListPresets, read...)
+	route("POST /api/v1/presets", handlers.APICreatePreset, submit...)
+	route("DELETE /api/v1/presets/{id}", handlers.APIDeletePreset, submit...)
+	adminRoute("GET /api/v1/backup", handlers.APIBackup)
+	adminRoute("GET /api/v1/export", handlers.APIExport)
+	adminRoute("POST /api/v1/import", handlers.APIImport)
+	route("GET /api/v1/directory-suggestions", handlers.APIDirectorySuggestions, readOrSubmit...)
+
+	// Prometheus metrics; scrapers authenticate with a read token when authentication is enabled
+	route("GET /metrics", metrics.Handler(db, worker).ServeHTTP, read...)
+
+	// The qBittorrent and SABnzbd-compatible APIs authenticate the way their clients expect
+	root := http.NewServeMux()
+	root.Handle(qbittorrent.PathPrefix, qbittorrent.NewHandler(db, torrents, authService, cfg.BaseDownloadsPath))
+	sabHandler := sabnzbd.NewHandler(db, submitter, worker, authService, cfg.BaseDownloadsPath)
+	for _, path := range sabnzbd.Paths {
+		root.Handle(path, sabHandler)
+	}
+	root.Handle("/", authService.Middleware(mux))
 
 	server := &http.Server{
 		Addr:         ":" + cfg.ServerPort,
-		Handler:      mux,
+		Handler:      root,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
+	var watcher *watch.Watcher
+	if cfg.WatchFolderPath != "" {
+		watcher = watch.NewWatcher(db, submitter, torrents, watch.Options{
+			Path:          cfg.WatchFolderPath,
+			BasePath:      cfg.BaseDownloadsPath,
+			Interval:      cfg.WatchInterval,
+			DLCDecryptURL: cfg.DLCDecryptURL,
+			Owner:         cfg.WatchFolderOwner,
+		})
+	}
+
+	var backups *backup.Scheduler
+	if cfg.BackupPath != "" {
+		backups = backup.NewScheduler(db, backup.Options{
+			Path:     cfg.BackupPath,
+			Interval: cfg.BackupInterval,
+			Keep:     cfg.BackupKeep,
+		})
+	}
+
 	return &Server{
 		server:   server,
 		handlers: handlers,
+		torrents: torrents,
+		webhooks: webhooks,
+		notifier: notifier,
+		media:    media,
+		hooks:    hookRunner,
+		watcher:  watcher,
+		backups:  backups,
 		logger:   slog.Default(),
 	}
 }
 
+// SetSettings sets the runtime settings edited on the settings page
+func (s *Server) SetSettings(service *settings.Service) {
+	s.handlers.SetSettings(service)
+}
+
+// SetRetention sets the history cleanup, which runs with the background services and
+// from the settings page
+func (s *Server) SetRetention(service *retention.Service) {
+	s.history = service
+	s.handlers.SetRetention(service)
+}
+
+// StartBackground starts the server's background services, which run until the context is cancelled
+func (s *Server) StartBackground(ctx context.Context) {
+	go s.torrents.Start(ctx)
+	go s.webhooks.Start(ctx)
+	go s.notifier.Start(ctx)
+	go s.media.Start(ctx)
+	go s.hooks.Start(ctx)
+	if s.watcher != nil {
+		go s.watcher.Start(ctx)
+	}
+	if s.backups != nil {
+		go s.backups.Start(ctx)
+	}
+	if s.history != nil {
+		go s.history.Start(ctx)
+	}
+}
+
 // Start starts the HTTP server
 func (s *Server) Start() error {
 	localIP := getLocalIP()
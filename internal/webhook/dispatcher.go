@@ -0,0 +1,282 @@
+// Package webhook delivers signed JSON notifications of download events to
+// user-configured URLs, retrying failed deliveries with backoff
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/events"
+	"debrid-downloader/pkg/models"
+)
+
+const (
+	// DefaultPollInterval is how often the dispatcher looks for deliveries that are due
+	DefaultPollInterval = 5 * time.Second
+
+	// MaxAttempts is how many times a delivery is tried before it is marked failed
+	MaxAttempts = 8
+
+	// SignatureHeader carries the hex HMAC-SHA256 of the body, prefixed with "sha256="
+	SignatureHeader = "X-Webhook-Signature-256"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+
+	requestTimeout = 10 * time.Second
+	baseBackoff    = 30 * time.Second
+	maxBackoff     = time.Hour
+	batchSize      = 50
+	maxErrorLength = 500
+)
+
+// Payload is the JSON body POSTed to webhooks
+type Payload struct {
+	Event     events.Type           `json:"event"`
+	Timestamp time.Time             `json:"timestamp"`
+	Download  *models.Download      `json:"download,omitempty"`
+	Group     *models.DownloadGroup `json:"group,omitempty"`
+}
+
+// Dispatcher records events for each matching webhook and delivers them in the background
+type Dispatcher struct {
+	db           *database.DB
+	client       *http.Client
+	logger       *slog.Logger
+	pollInterval time.Duration
+	wake         chan struct{}
+	now          func() time.Time
+}
+
+// NewDispatcher creates a new webhook dispatcher
+func NewDispatcher(db *database.DB) *Dispatcher {
+	return &Dispatcher{
+		db:           db,
+		client:       &http.Client{Timeout: requestTimeout},
+		logger:       slog.Default(),
+		pollInterval: DefaultPollInterval,
+		wake:         make(chan struct{}, 1),
+		now:          time.Now,
+	}
+}
+
+// Handle queues a delivery of the event for every enabled webhook that wants it.
+// It is meant to be subscribed to the worker's event bus.
+func (d *Dispatcher) Handle(event events.Event) {
+	webhooks, err := d.db.ListWebhooks()
+	if err != nil {
+		d.logger.Error("Failed to list webhooks", "error", err)
+		return
+	}
+
+	var body []byte
+	queued := false
+	for _, webhook := range webhooks {
+		if !webhook.Enabled || !webhook.Wants(string(event.Type)) || !d.canSee(webhook, event) {
+			continue
+		}
+
+		if body == nil {
+			body, err = json.Marshal(Payload{
+				Event:     event.Type,
+				Timestamp: event.Time.UTC(),
+				Download:  event.Download,
+				Group:     event.Group,
+			})
+			if err != nil {
+				d.logger.Error("Failed to encode webhook payload", "event", event.Type, "error", err)
+				return
+			}
+		}
+
+		now := d.now().UTC()
+		delivery := &models.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			Event:         string(event.Type),
+			Payload:       string(body),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+			CreatedAt:     now,
+		}
+		if err := d.db.CreateWebhookDelivery(delivery); err != nil {
+			d.logger.Error("Failed to queue webhook delivery", "webhook_id", webhook.ID, "error", err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// canSee reports whether the webhook's owner may receive the event. Webhooks
+// created before accounts existed, or by admins, receive every event.
+func (d *Dispatcher) canSee(webhook *models.Webhook, event events.Event) bool {
+	if webhook.OwnerID == database.AllOwners || webhook.OwnerID == event.OwnerID() {
+		return true
+	}
+
+	owner, err := d.db.GetUser(webhook.OwnerID)
+	if err != nil {
+		return false
+	}
+	return owner.IsAdmin()
+}
+
+// Start delivers queued events until the context is cancelled
+func (d *Dispatcher) Start(ctx context.Context) {
+	d.logger.Info("Starting webhook dispatcher", "interval", d.pollInterval)
+
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.DeliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			d.logger.Info("Webhook dispatcher stopping")
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// DeliverDue attempts every pending delivery whose next attempt is due
+func (d *Dispatcher) DeliverDue(ctx context.Context) {
+	deliveries, err := d.db.ListDueWebhookDeliveries(d.now().UTC(), batchSize)
+	if err != nil {
+		d.logger.Error("Failed to list due webhook deliveries", "error", err)
+		return
+	}
+
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			return
+		}
+		d.attempt(ctx, delivery)
+	}
+}
+
+// attempt sends one delivery and records the outcome
+func (d *Dispatcher) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	webhook, err := d.db.GetWebhook(delivery.WebhookID)
+	if err != nil {
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = "webhook no longer exists"
+		d.save(delivery)
+		return
+	}
+
+	delivery.Attempts++
+	code, err := d.send(ctx, webhook, delivery)
+	delivery.ResponseCode = code
+	now := d.now().UTC()
+
+	if err == nil {
+		delivery.Status = models.DeliveryDelivered
+		delivery.Error = ""
+		delivery.DeliveredAt = &now
+		d.save(delivery)
+		return
+	}
+
+	delivery.Error = truncate(err.Error(), maxErrorLength)
+	if delivery.Attempts >= MaxAttempts {
+		delivery.Status = models.DeliveryFailed
+		d.logger.Warn("Webhook delivery failed permanently",
+			"webhook_id", webhook.ID, "delivery_id", delivery.ID, "attempts", delivery.Attempts, "error", err)
+	} else {
+		delivery.NextAttemptAt = now.Add(Backoff(delivery.Attempts))
+		d.logger.Info("Webhook delivery failed, will retry",
+			"webhook_id", webhook.ID, "delivery_id", delivery.ID, "attempts", delivery.Attempts,
+			"next_attempt_at", delivery.NextAttemptAt, "error", err)
+	}
+	d.save(delivery)
+}
+
+// send POSTs the signed payload and returns the response status code
+func (d *Dispatcher) send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "debrid-downloader-webhook")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) save(delivery *models.WebhookDelivery) {
+	if err := d.db.UpdateWebhookDelivery(delivery); err != nil {
+		d.logger.Error("Failed to record webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// Sign returns the signature header value for a payload: "sha256=" followed by
+// the hex HMAC-SHA256 of the body keyed with the webhook secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff returns how long to wait before retrying after the given number of
+// attempts: 30s, 1m, 2m, 4m and so on, capped at an hour
+func Backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return delay
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
+
+// GenerateSecret returns a random secret for signing a new webhook's payloads
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
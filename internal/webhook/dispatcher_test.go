@@ -0,0 +1,192 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"debrid-downloader/internal/database"
+	"debrid-downloader/internal/events"
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+type receivedRequest struct {
+	headers http.Header
+	body    []byte
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	requests []receivedRequest
+	statuses []int
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.requests = append(rec.requests, receivedRequest{headers: r.Header.Clone(), body: body})
+
+	status := http.StatusNoContent
+	if len(rec.statuses) > 0 {
+		status = rec.statuses[0]
+		rec.statuses = rec.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newTestDispatcher(t *testing.T) (*Dispatcher, *database.DB) {
+	db, err := database.New(":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return NewDispatcher(db), db
+}
+
+func createWebhook(t *testing.T, db *database.DB, url, events string, ownerID int64) *models.Webhook {
+	webhook := &models.Webhook{
+		URL:       url,
+		Secret:    "top-secret",
+		Events:    events,
+		Enabled:   true,
+		OwnerID:   ownerID,
+		CreatedAt: time.Now(),
+	}
+	require.NoError(t, db.CreateWebhook(webhook))
+	return webhook
+}
+
+func TestDispatcher_DeliversSignedPayload(t *testing.T) {
+	dispatcher, db := newTestDispatcher(t)
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	webhook := createWebhook(t, db, server.URL, "", 0)
+	createWebhook(t, db, server.URL, string(events.GroupCompleted), 0)
+
+	dispatcher.Handle(events.Event{
+		Type:     events.DownloadCompleted,
+		Time:     time.Now(),
+		Download: &models.Download{ID: 7, Filename: "movie.mkv", Status: models.StatusCompleted},
+	})
+	dispatcher.DeliverDue(context.Background())
+
+	require.Len(t, rec.requests, 1)
+	got := rec.requests[0]
+	require.Equal(t, "download.completed", got.headers.Get(EventHeader))
+	require.Equal(t, "application/json", got.headers.Get("Content-Type"))
+	require.Equal(t, Sign("top-secret", got.body), got.headers.Get(SignatureHeader))
+
+	var payload Payload
+	require.NoError(t, json.Unmarshal(got.body, &payload))
+	require.Equal(t, events.DownloadCompleted, payload.Event)
+	require.Equal(t, "movie.mkv", payload.Download.Filename)
+	require.Nil(t, payload.Group)
+
+	log, err := db.ListWebhookDeliveries(webhook.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, log, 1)
+	require.Equal(t, models.DeliveryDelivered, log[0].Status)
+	require.Equal(t, http.StatusNoContent, log[0].ResponseCode)
+	require.NotNil(t, log[0].DeliveredAt)
+}
+
+func TestDispatcher_RetriesWithBackoff(t *testing.T) {
+	dispatcher, db := newTestDispatcher(t)
+	rec := &recorder{statuses: []int{http.StatusInternalServerError}}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	now := time.Now()
+	dispatcher.now = func() time.Time { return now }
+
+	webhook := createWebhook(t, db, server.URL, "", 0)
+	dispatcher.Handle(events.Event{Type: events.GroupFailed, Group: &models.DownloadGroup{ID: "group-1"}})
+	dispatcher.DeliverDue(context.Background())
+
+	log, err := db.ListWebhookDeliveries(webhook.ID, 10)
+	require.NoError(t, err)
+	require.Equal(t, models.DeliveryPending, log[0].Status)
+	require.Equal(t, 1, log[0].Attempts)
+	require.Equal(t, http.StatusInternalServerError, log[0].ResponseCode)
+	require.Contains(t, log[0].Error, "500")
+
+	// Not due until the backoff has passed
+	dispatcher.DeliverDue(context.Background())
+	require.Len(t, rec.requests, 1)
+
+	now = now.Add(Backoff(1) + time.Second)
+	dispatcher.DeliverDue(context.Background())
+	require.Len(t, rec.requests, 2)
+
+	log, err = db.ListWebhookDeliveries(webhook.ID, 10)
+	require.NoError(t, err)
+	require.Equal(t, models.DeliveryDelivered, log[0].Status)
+	require.Equal(t, 2, log[0].Attempts)
+	require.Empty(t, log[0].Error)
+}
+
+func TestDispatcher_GivesUpAfterMaxAttempts(t *testing.T) {
+	dispatcher, db := newTestDispatcher(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	dispatcher.now = func() time.Time { return now }
+
+	webhook := createWebhook(t, db, server.URL, "", 0)
+	dispatcher.Handle(events.Event{Type: events.DownloadFailed, Download: &models.Download{ID: 1}})
+
+	for i := 0; i < MaxAttempts+2; i++ {
+		dispatcher.DeliverDue(context.Background())
+		now = now.Add(maxBackoff)
+	}
+
+	log, err := db.ListWebhookDeliveries(webhook.ID, 10)
+	require.NoError(t, err)
+	require.Equal(t, models.DeliveryFailed, log[0].Status)
+	require.Equal(t, MaxAttempts, log[0].Attempts)
+}
+
+func TestDispatcher_OwnerScoping(t *testing.T) {
+	dispatcher, db := newTestDispatcher(t)
+
+	admin := &models.User{Username: "admin", Role: models.RoleAdmin, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(admin))
+	alice := &models.User{Username: "alice", Role: models.RoleUser, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(alice))
+	bob := &models.User{Username: "bob", Role: models.RoleUser, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateUser(bob))
+
+	adminHook := createWebhook(t, db, "http://127.0.0.1/admin", "", admin.ID)
+	aliceHook := createWebhook(t, db, "http://127.0.0.1/alice", "", alice.ID)
+	bobHook := createWebhook(t, db, "http://127.0.0.1/bob", "", bob.ID)
+	disabled := &models.Webhook{URL: "http://127.0.0.1/disabled", Secret: "s", Enabled: false, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateWebhook(disabled))
+
+	dispatcher.Handle(events.Event{Type: events.DownloadQueued, Download: &models.Download{ID: 1, OwnerID: alice.ID}})
+
+	for hook, want := range map[int64]int{adminHook.ID: 1, aliceHook.ID: 1, bobHook.ID: 0, disabled.ID: 0} {
+		log, err := db.ListWebhookDeliveries(hook, 10)
+		require.NoError(t, err)
+		require.Len(t, log, want, "webhook %d", hook)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	require.Equal(t, 30*time.Second, Backoff(1))
+	require.Equal(t, time.Minute, Backoff(2))
+	require.Equal(t, 4*time.Minute, Backoff(4))
+	require.Equal(t, time.Hour, Backoff(10))
+}
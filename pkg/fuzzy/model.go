@@ -0,0 +1,197 @@
+package fuzzy
+
+import (
+	"math"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"debrid-downloader/pkg/models"
+)
+
+// Token prefixes name the kind of feature a token is
+const (
+	TokenWord  = "word:"
+	TokenGroup = "group:"
+	TokenHost  = "host:"
+	TokenExt   = "ext:"
+)
+
+// RecencyHalfLife is how long it takes a learned choice to count for half as much
+const RecencyHalfLife = 90 * 24 * time.Hour
+
+// tokenWeights is how much each kind of token says about a directory. Release groups
+// and title words are specific; hosts and extensions are shared by many directories.
+var tokenWeights = map[string]float64{
+	TokenWord:  1.0,
+	TokenGroup: 1.5,
+	TokenHost:  0.5,
+	TokenExt:   0.25,
+}
+
+// noiseWords are quality tags and filler words that say nothing about where a file goes
+var noiseWords = map[string]bool{
+	"480p": true, "576p": true, "720p": true, "1080p": true, "2160p": true, "4k": true, "uhd": true,
+	"x264": true, "x265": true, "h264": true, "h265": true, "hevc": true, "avc": true, "av1": true, "xvid": true,
+	"web": true, "dl": true, "webdl": true, "webrip": true, "bluray": true, "bdrip": true, "brrip": true,
+	"hdtv": true, "dvdrip": true, "remux": true, "hdr": true, "10bit": true, "proper": true, "repack": true,
+	"internal": true, "aac": true, "ac3": true, "dts": true, "ddp": true, "ddp5": true, "atmos": true,
+	"the": true, "a": true, "an": true, "and": true, "of": true,
+}
+
+// episodeWord matches S01E02, S01 and E02 style words
+var episodeWord = regexp.MustCompile(`^(s\d{1,2}(e\d{1,3})?|e\d{1,3}|\d+x\d+)$`)
+
+// Suggestion is a directory ranked by the learned model
+type Suggestion struct {
+	Directory  string  `json:"directory"`
+	Confidence float64 `json:"confidence"` // From 0 to 1
+}
+
+// Tokens returns the features a file is learned by: its normalised title words, release
+// group and extension, and the host of the link it came from
+func Tokens(filename, link string) []string {
+	var tokens []string
+	seen := make(map[string]bool)
+	add := func(token string) {
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	name := strings.ToLower(path.Base(strings.ReplaceAll(filename, `\`, "/")))
+	if ext := path.Ext(name); len(ext) > 1 && len(ext) <= 5 {
+		add(TokenExt + ext[1:])
+		name = strings.TrimSuffix(name, ext)
+	}
+
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	// Scene releases end with -GROUP after their quality tags
+	if i := strings.LastIndex(name, "-"); i > 0 && len(words) > 1 {
+		group := name[i+1:]
+		if isWord(group) && hasNoise(words[:len(words)-1]) {
+			add(TokenGroup + group)
+			words = words[:len(words)-1]
+		}
+	}
+
+	for _, word := range words {
+		if len(word) < 2 || noiseWords[word] || isNumber(word) || episodeWord.MatchString(word) {
+			continue
+		}
+		add(TokenWord + word)
+	}
+
+	if parsed, err := url.Parse(link); err == nil && parsed.Hostname() != "" {
+		add(TokenHost + strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www."))
+	}
+
+	return tokens
+}
+
+// Rank scores the directories a file's tokens were learned for and returns up to limit
+// of them, best first. Each shared token counts for more the rarer it is among the
+// candidates, the more often it was chosen and the more recently.
+func (m *Matcher) Rank(tokens []string, learned []*models.DirectoryToken, now time.Time, limit int) []Suggestion {
+	wanted := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		wanted[token] = true
+	}
+
+	directories := make(map[string]bool)
+	tokenDirectories := make(map[string]int)
+	for _, entry := range learned {
+		if wanted[entry.Token] && entry.Weight > 0 {
+			directories[entry.Directory] = true
+			tokenDirectories[entry.Token]++
+		}
+	}
+
+	scores := make(map[string]float64)
+	for _, entry := range learned {
+		if !wanted[entry.Token] || entry.Weight <= 0 {
+			continue
+		}
+		idf := math.Log(1 + float64(len(directories))/float64(tokenDirectories[entry.Token]))
+		decay := math.Pow(0.5, now.Sub(entry.LastUsed).Hours()/RecencyHalfLife.Hours())
+		scores[entry.Directory] += tokenWeight(entry.Token) * idf * math.Log1p(entry.Weight) * decay
+	}
+
+	total := 0.0
+	for _, score := range scores {
+		total += score
+	}
+
+	suggestions := make([]Suggestion, 0, len(scores))
+	for directory, score := range scores {
+		if score <= 0 {
+			continue
+		}
+		// A directory's share of the score, discounted when there is little evidence
+		confidence := score / total * (1 - math.Exp(-score))
+		suggestions = append(suggestions, Suggestion{Directory: directory, Confidence: confidence})
+	}
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Confidence == suggestions[j].Confidence {
+			return suggestions[i].Directory < suggestions[j].Directory
+		}
+		return suggestions[i].Confidence > suggestions[j].Confidence
+	})
+
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// tokenWeight returns the weight of a token's kind
+func tokenWeight(token string) float64 {
+	for prefix, weight := range tokenWeights {
+		if strings.HasPrefix(token, prefix) {
+			return weight
+		}
+	}
+	return 1.0
+}
+
+// hasNoise reports whether any of the words is a quality tag
+func hasNoise(words []string) bool {
+	for _, word := range words {
+		if noiseWords[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// isWord reports whether s is a single run of letters and digits
+func isWord(s string) bool {
+	if len(s) < 2 {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isNumber reports whether s is all digits, such as a year or part number
+func isNumber(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,81 @@
+package fuzzy
+
+import (
+	"testing"
+	"time"
+
+	"debrid-downloader/pkg/models"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		link     string
+		expected []string
+	}{
+		{
+			name:     "scene episode",
+			filename: "The.Office.US.S03E07.720p.HDTV.x264-NTB.mkv",
+			link:     "https://www.rapidgator.net/file/abc",
+			expected: []string{"ext:mkv", "group:ntb", "word:office", "word:us", "host:rapidgator.net"},
+		},
+		{
+			name:     "movie with year",
+			filename: "Blade_Runner_2049 (2017) [1080p].mp4",
+			expected: []string{"ext:mp4", "word:blade", "word:runner"},
+		},
+		{
+			name:     "dash without quality tags is not a group",
+			filename: "family-holiday.zip",
+			expected: []string{"ext:zip", "word:family", "word:holiday"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, Tokens(tt.filename, tt.link))
+		})
+	}
+}
+
+func TestMatcher_Rank(t *testing.T) {
+	now := time.Now()
+	learned := []*models.DirectoryToken{
+		// Every directory has seen .mkv files, so the extension barely counts
+		{Token: "ext:mkv", Directory: "/downloads/tv", Weight: 20, LastUsed: now},
+		{Token: "ext:mkv", Directory: "/downloads/movies", Weight: 30, LastUsed: now},
+		{Token: "word:office", Directory: "/downloads/tv", Weight: 5, LastUsed: now},
+		{Token: "group:ntb", Directory: "/downloads/tv", Weight: 3, LastUsed: now},
+		{Token: "word:office", Directory: "/downloads/old", Weight: 5, LastUsed: now.Add(-2 * 365 * 24 * time.Hour)},
+		{Token: "word:runner", Directory: "/downloads/movies", Weight: 1, LastUsed: now},
+	}
+
+	matcher := NewMatcher()
+	ranked := matcher.Rank(Tokens("The.Office.S04E01.720p.x264-NTB.mkv", ""), learned, now, 5)
+	require.Len(t, ranked, 3)
+	require.Equal(t, "/downloads/tv", ranked[0].Directory)
+	require.Greater(t, ranked[0].Confidence, 0.7)
+	require.LessOrEqual(t, ranked[0].Confidence, 1.0)
+
+	// Older choices count for less than recent ones with the same weight
+	var old, movies float64
+	for _, suggestion := range ranked {
+		switch suggestion.Directory {
+		case "/downloads/old":
+			old = suggestion.Confidence
+		case "/downloads/movies":
+			movies = suggestion.Confidence
+		}
+	}
+	require.Less(t, old, ranked[0].Confidence)
+	require.Greater(t, movies, 0.0)
+
+	// The limit keeps the best suggestions
+	require.Len(t, matcher.Rank(Tokens("The.Office.S04E01.720p.x264-NTB.mkv", ""), learned, now, 1), 1)
+
+	// Nothing learned means no suggestions
+	require.Empty(t, matcher.Rank(Tokens("unknown.iso", ""), learned, now, 5))
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// APITokenScope limits what an API token is allowed to do
+type APITokenScope string
+
+const (
+	ScopeRead   APITokenScope = "read"
+	ScopeSubmit APITokenScope = "submit"
+	ScopeAdmin  APITokenScope = "admin"
+)
+
+// Valid reports whether the scope is one of the known scopes
+func (s APITokenScope) Valid() bool {
+	switch s {
+	case ScopeRead, ScopeSubmit, ScopeAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// APIToken represents a long-lived token used by scripts and integrations
+type APIToken struct {
+	ID         int64         `json:"id" db:"id"`
+	Name       string        `json:"name" db:"name"`
+	Prefix     string        `json:"prefix" db:"prefix"` // First characters of the token, shown to identify it
+	TokenHash  string        `json:"-" db:"token_hash"`  // SHA-256 of the full token; the token itself is never stored
+	Scope      APITokenScope `json:"scope" db:"scope"`
+	CreatedAt  time.Time     `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time    `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time    `json:"revoked_at" db:"revoked_at"`
+	OwnerID    int64         `json:"owner_id" db:"owner_id"` // User the token acts as
+}
+
+// Revoked reports whether the token has been revoked
+func (t *APIToken) Revoked() bool {
+	return t.RevokedAt != nil
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// CleanupProfile decides which files extracted from archives are deleted afterwards
+type CleanupProfile string
+
+const (
+	// CleanupVideo keeps video files and deletes known extras such as NFOs, images and subtitles
+	CleanupVideo CleanupProfile = "video"
+	// CleanupSubtitles is CleanupVideo but keeps subtitles
+	CleanupSubtitles CleanupProfile = "subtitles"
+	// CleanupNone keeps every extracted file
+	CleanupNone CleanupProfile = "none"
+)
+
+// CleanupProfiles lists every cleanup profile, the default first
+var CleanupProfiles = []CleanupProfile{CleanupVideo, CleanupSubtitles, CleanupNone}
+
+// Valid reports whether the profile is one of CleanupProfiles
+func (p CleanupProfile) Valid() bool {
+	for _, profile := range CleanupProfiles {
+		if p == profile {
+			return true
+		}
+	}
+	return false
+}
+
+// Category groups downloads that share a directory and post-processing settings
+type Category struct {
+	ID             int64          `json:"id" db:"id"`
+	Name           string         `json:"name" db:"name"`
+	Directory      string         `json:"directory" db:"directory"` // Relative to the downloads path, empty for a folder named after the category
+	Keywords       string         `json:"keywords" db:"keywords"`   // Comma-separated words and .extensions that suggest the category for a link
+	Extract        bool           `json:"extract" db:"extract"`     // Whether archives are extracted
+	CleanupProfile CleanupProfile `json:"cleanup_profile" db:"cleanup_profile"`
+	Priority       int            `json:"priority" db:"priority"`               // Higher priorities are downloaded first
+	HookID         int64          `json:"hook_id" db:"hook_id"`                 // Post-processing hook, 0 for the usual folder matching
+	RenameTemplate string         `json:"rename_template" db:"rename_template"` // How video files are renamed once complete, empty to keep their names
+	CreatedAt      time.Time      `json:"created_at" db:"created_at"`
+}
+
+// NewCategory returns a category with the default post-processing settings
+func NewCategory(name string) *Category {
+	return &Category{
+		Name:           name,
+		Extract:        true,
+		CleanupProfile: CleanupVideo,
+		CreatedAt:      time.Now(),
+	}
+}
+
+// DirectoryName returns the category's directory relative to the downloads path
+func (c *Category) DirectoryName() string {
+	if c.Directory == "" {
+		return c.Name
+	}
+	return c.Directory
+}
+
+// KeywordList returns the category's keywords, lowercased
+func (c *Category) KeywordList() []string {
+	var keywords []string
+	for _, keyword := range strings.Split(c.Keywords, ",") {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	return keywords
+}
+
+// DefaultCategories returns a starter set of categories with keywords for suggesting them
+func DefaultCategories() []*Category {
+	defaults := []struct {
+		name     string
+		keywords string
+		extract  bool
+		cleanup  CleanupProfile
+	}{
+		{"Movies", ".mp4,.mkv,.avi,.mov,.wmv,.flv,.webm,.m4v,movie,film,cinema,dvdrip,bluray,hdtv,webrip", true, CleanupVideo},
+		{"TV Shows", "season,episode,s01,s02,s03,s04,s05,e01,e02,series,tv,show,hdtv,webrip", true, CleanupSubtitles},
+		{"Music", ".mp3,.flac,.wav,.aac,.ogg,.m4a,.wma,album,music,song,track,artist,band", true, CleanupNone},
+		{"Software", ".exe,.msi,.dmg,.pkg,.deb,.rpm,.appimage,software,program,app,installer,setup,portable", false, CleanupNone},
+		{"Games", "game,steam,gog,origin,epic,uplay,crack,repack,.iso,setup.exe", false, CleanupNone},
+		{"Books", ".pdf,.epub,.mobi,.azw,.azw3,.djvu,book,ebook,novel,manual,guide", true, CleanupNone},
+		{"Archives", ".zip,.rar,.7z,.tar,.gz,.bz2,.xz", false, CleanupNone},
+	}
+
+	categories := make([]*Category, 0, len(defaults))
+	for _, d := range defaults {
+		category := NewCategory(d.name)
+		category.Keywords = d.keywords
+		category.Extract = d.extract
+		category.CleanupProfile = d.cleanup
+		categories = append(categories, category)
+	}
+	return categories
+}
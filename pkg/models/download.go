@@ -39,6 +39,13 @@ type Download struct {
 	GroupID         string         `json:"group_id" db:"group_id"`                   // Group ID for multi-file downloads
 	IsArchive       bool           `json:"is_archive" db:"is_archive"`               // Whether this is an archive file
 	ExtractedFiles  string         `json:"extracted_files" db:"extracted_files"`     // JSON array of extracted file paths
+	OwnerID         int64          `json:"owner_id" db:"owner_id"`                   // User who submitted the download, 0 if unowned
+	Category        string         `json:"category" db:"category"`                   // Category name, empty if none
+	Priority        int            `json:"priority" db:"priority"`                   // Queue priority from the category, higher first
+	HookExitCode    *int           `json:"hook_exit_code" db:"hook_exit_code"`       // Post-processing hook exit code, nil if no hook ran
+	HookOutput      string         `json:"hook_output" db:"hook_output"`             // Post-processing hook output, or its group's
+	Pinned          bool           `json:"pinned" db:"pinned"`                       // Kept in the history when old downloads are removed
+	NextAttemptAt   *time.Time     `json:"next_attempt_at" db:"next_attempt_at"`     // When a download waiting out a rate limit is tried again
 }
 
 // DirectoryMapping represents a learned directory suggestion
@@ -50,6 +57,7 @@ type DirectoryMapping struct {
 	UseCount        int       `json:"use_count" db:"use_count"`
 	LastUsed        time.Time `json:"last_used" db:"last_used"`
 	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	OwnerID         int64     `json:"owner_id" db:"owner_id"`
 }
 
 // DownloadGroupStatus represents the status of a download group
@@ -70,6 +78,9 @@ type DownloadGroup struct {
 	CompletedDownloads int                 `json:"completed_downloads" db:"completed_downloads"`
 	Status             DownloadGroupStatus `json:"status" db:"status"`
 	ProcessingError    string              `json:"processing_error" db:"processing_error"`
+	OwnerID            int64               `json:"owner_id" db:"owner_id"`
+	HookExitCode       *int                `json:"hook_exit_code" db:"hook_exit_code"` // Post-processing hook exit code, nil if no hook ran
+	HookOutput         string              `json:"hook_output" db:"hook_output"`
 }
 
 // ExtractedFile represents a file that was extracted from an archive
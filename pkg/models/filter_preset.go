@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// FilterPreset is a saved set of history filters
+type FilterPreset struct {
+	ID        int64     `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Query     string    `json:"query" db:"query"` // Filters as URL query parameters, as the downloads API takes them
+	OwnerID   int64     `json:"owner_id" db:"owner_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// DefaultHookTimeout is how long a hook may run when no timeout is set
+const DefaultHookTimeout = 5 * time.Minute
+
+// Hook is a post-processing script run when downloads in its directory or category finish.
+// Categories choose their hook; other downloads use the hook for the closest directory.
+type Hook struct {
+	ID             int64     `json:"id" db:"id"`
+	Name           string    `json:"name" db:"name"`
+	Script         string    `json:"script" db:"script"`       // Executable file name inside the hooks directory
+	Directory      string    `json:"directory" db:"directory"` // Relative to the downloads path, empty for every directory
+	TimeoutSeconds int       `json:"timeout_seconds" db:"timeout_seconds"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
+
+// Timeout returns how long the hook may run before it is killed
+func (h *Hook) Timeout() time.Duration {
+	if h.TimeoutSeconds <= 0 {
+		return DefaultHookTimeout
+	}
+	return time.Duration(h.TimeoutSeconds) * time.Second
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MediaServerKind identifies the media server software
+type MediaServerKind string
+
+const (
+	MediaServerJellyfin MediaServerKind = "jellyfin"
+	MediaServerEmby     MediaServerKind = "emby"
+	MediaServerPlex     MediaServerKind = "plex"
+)
+
+// Valid reports whether the kind is one of the supported media servers
+func (k MediaServerKind) Valid() bool {
+	return k == MediaServerJellyfin || k == MediaServerEmby || k == MediaServerPlex
+}
+
+// MediaServer is a Jellyfin, Emby or Plex server whose library is refreshed when
+// downloads under its path finish
+type MediaServer struct {
+	ID           int64           `json:"id" db:"id"`
+	Name         string          `json:"name" db:"name"`
+	Kind         MediaServerKind `json:"kind" db:"kind"`
+	URL          string          `json:"url" db:"url"`
+	Token        string          `json:"-" db:"token"`                     // API key, or X-Plex-Token for Plex
+	PathPrefix   string          `json:"path_prefix" db:"path_prefix"`     // Directory as this app sees it
+	RemotePrefix string          `json:"remote_prefix" db:"remote_prefix"` // The same directory as the media server sees it, empty if identical
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+}
+
+// TranslatePath maps a local directory under PathPrefix to the media server's view of it.
+// It reports false when the directory is outside PathPrefix.
+func (s *MediaServer) TranslatePath(dir string) (string, bool) {
+	rel, err := filepath.Rel(s.PathPrefix, filepath.Clean(dir))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	remote := s.RemotePrefix
+	if remote == "" {
+		remote = s.PathPrefix
+	}
+	if rel == "." {
+		return remote, true
+	}
+	// Media servers may run on another OS, so join with the separator the remote prefix uses
+	separator := "/"
+	if strings.Contains(remote, `\`) && !strings.Contains(remote, "/") {
+		separator = `\`
+	}
+	return strings.TrimRight(remote, `/\`) + separator + strings.ReplaceAll(rel, string(filepath.Separator), separator), true
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMediaServer_TranslatePath(t *testing.T) {
+	server := &MediaServer{PathPrefix: "/downloads/movies", RemotePrefix: "/data/media/movies"}
+
+	path, ok := server.TranslatePath("/downloads/movies/Heat (1995)")
+	require.True(t, ok)
+	require.Equal(t, "/data/media/movies/Heat (1995)", path)
+
+	path, ok = server.TranslatePath("/downloads/movies")
+	require.True(t, ok)
+	require.Equal(t, "/data/media/movies", path)
+
+	_, ok = server.TranslatePath("/downloads/movies-old/file")
+	require.False(t, ok)
+	_, ok = server.TranslatePath("/downloads/tv")
+	require.False(t, ok)
+
+	// Without a remote prefix, paths are passed through
+	server.RemotePrefix = ""
+	path, ok = server.TranslatePath("/downloads/movies/a/b")
+	require.True(t, ok)
+	require.Equal(t, "/downloads/movies/a/b", path)
+
+	// Windows media servers get backslashes
+	server.RemotePrefix = `D:\Media\Movies\`
+	path, ok = server.TranslatePath("/downloads/movies/a/b")
+	require.True(t, ok)
+	require.Equal(t, `D:\Media\Movies\a\b`, path)
+}
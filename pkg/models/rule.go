@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// RuleCondition is what a routing rule checks about a link
+type RuleCondition string
+
+const (
+	// RuleHost matches the link's host or its subdomains; * wildcards are allowed
+	RuleHost RuleCondition = "host"
+	// RuleFilename matches the file name against a regular expression
+	RuleFilename RuleCondition = "filename"
+	// RuleURL matches the whole link against a regular expression
+	RuleURL RuleCondition = "url"
+	// RuleLargerThan matches files bigger than a size such as 2GB
+	RuleLargerThan RuleCondition = "larger_than"
+	// RuleSmallerThan matches files smaller than a size such as 500MB
+	RuleSmallerThan RuleCondition = "smaller_than"
+)
+
+// RuleConditions lists every rule condition in the order they are offered
+var RuleConditions = []RuleCondition{RuleHost, RuleFilename, RuleURL, RuleLargerThan, RuleSmallerThan}
+
+// Valid reports whether the condition is one of RuleConditions
+func (c RuleCondition) Valid() bool {
+	for _, condition := range RuleConditions {
+		if c == condition {
+			return true
+		}
+	}
+	return false
+}
+
+// Label describes the condition for the settings page
+func (c RuleCondition) Label() string {
+	switch c {
+	case RuleHost:
+		return "Host is"
+	case RuleFilename:
+		return "Filename matches"
+	case RuleURL:
+		return "Link matches"
+	case RuleLargerThan:
+		return "Larger than"
+	case RuleSmallerThan:
+		return "Smaller than"
+	}
+	return string(c)
+}
+
+// Rule sends links that meet its condition to a directory. Rules are checked in
+// position order and the first match wins.
+type Rule struct {
+	ID        int64         `json:"id" db:"id"`
+	Condition RuleCondition `json:"condition" db:"condition"`
+	Value     string        `json:"value" db:"value"`
+	Directory string        `json:"directory" db:"directory"` // Relative to the downloads path
+	Position  int           `json:"position" db:"position"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+}
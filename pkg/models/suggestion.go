@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// DirectoryToken is how strongly a learned token, such as a title word or host, points
+// to a directory
+type DirectoryToken struct {
+	ID        int64     `json:"id" db:"id"`
+	Token     string    `json:"token" db:"token"`
+	Directory string    `json:"directory" db:"directory"`
+	Weight    float64   `json:"weight" db:"weight"` // Grows each time the directory is chosen, shrinks when its suggestion is overridden
+	LastUsed  time.Time `json:"last_used" db:"last_used"`
+	OwnerID   int64     `json:"owner_id" db:"owner_id"`
+}
+
+// SuggestionFeedback records whether a suggested directory was kept or overridden
+type SuggestionFeedback struct {
+	ID        int64     `json:"id" db:"id"`
+	Filename  string    `json:"filename" db:"filename"`
+	Suggested string    `json:"suggested" db:"suggested"`
+	Chosen    string    `json:"chosen" db:"chosen"`
+	Accepted  bool      `json:"accepted" db:"accepted"`
+	OwnerID   int64     `json:"owner_id" db:"owner_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
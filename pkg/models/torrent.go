@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// TorrentStatus represents how far a magnet or torrent file has progressed
+type TorrentStatus string
+
+const (
+	// TorrentStatusQueued means the debrid provider is still fetching the torrent
+	TorrentStatusQueued TorrentStatus = "queued"
+	// TorrentStatusDownloading means the torrent's files have been handed to the download worker
+	TorrentStatusDownloading TorrentStatus = "downloading"
+	// TorrentStatusFailed means the debrid provider or the submission failed
+	TorrentStatusFailed TorrentStatus = "failed"
+)
+
+// Torrent is a magnet or .torrent file added through the debrid provider. Once ready,
+// its files become downloads in the group identified by GroupID.
+type Torrent struct {
+	Hash         string        `json:"hash" db:"hash"` // Lowercase info hash
+	Name         string        `json:"name" db:"name"`
+	MagnetID     int64         `json:"magnet_id" db:"magnet_id"` // ID at the debrid provider
+	Category     string        `json:"category" db:"category"`
+	Directory    string        `json:"directory" db:"directory"`
+	Size         int64         `json:"size" db:"size"`
+	Status       TorrentStatus `json:"status" db:"status"`
+	ErrorMessage string        `json:"error_message" db:"error_message"`
+	GroupID      string        `json:"group_id" db:"group_id"`
+	OwnerID      int64         `json:"owner_id" db:"owner_id"`
+	AddedAt      time.Time     `json:"added_at" db:"added_at"`
+	UpdatedAt    time.Time     `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// UserRole determines what a user can see and change
+type UserRole string
+
+const (
+	RoleAdmin UserRole = "admin"
+	RoleUser  UserRole = "user"
+)
+
+// Valid reports whether the role is one of the known roles
+func (r UserRole) Valid() bool {
+	return r == RoleAdmin || r == RoleUser
+}
+
+// User represents an account that can sign in and own downloads
+type User struct {
+	ID           int64     `json:"id" db:"id"`
+	Username     string    `json:"username" db:"username"`
+	PasswordHash string    `json:"-" db:"password_hash"`
+	Role         UserRole  `json:"role" db:"role"`
+	Folder       string    `json:"folder" db:"folder"` // Subtree of the downloads path the user is limited to, empty for all
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// IsAdmin reports whether the user can see and manage everything
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
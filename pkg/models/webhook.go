@@ -0,0 +1,63 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// WebhookDeliveryStatus represents the state of a webhook delivery
+type WebhookDeliveryStatus string
+
+const (
+	DeliveryPending   WebhookDeliveryStatus = "pending"
+	DeliveryDelivered WebhookDeliveryStatus = "delivered"
+	DeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// Webhook is a URL that receives a signed POST when download events happen
+type Webhook struct {
+	ID        int64     `json:"id" db:"id"`
+	URL       string    `json:"url" db:"url"`
+	Secret    string    `json:"-" db:"secret"`      // HMAC key used to sign each payload
+	Events    string    `json:"events" db:"events"` // Comma separated event types, empty for all events
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	OwnerID   int64     `json:"owner_id" db:"owner_id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// EventList returns the event types the webhook subscribes to
+func (w *Webhook) EventList() []string {
+	if w.Events == "" {
+		return nil
+	}
+	return strings.Split(w.Events, ",")
+}
+
+// Wants reports whether the webhook subscribes to the given event type
+func (w *Webhook) Wants(eventType string) bool {
+	events := w.EventList()
+	if len(events) == 0 {
+		return true
+	}
+	for _, event := range events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery records one attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	ID            int64                 `json:"id" db:"id"`
+	WebhookID     int64                 `json:"webhook_id" db:"webhook_id"`
+	Event         string                `json:"event" db:"event"`
+	Payload       string                `json:"payload" db:"payload"`
+	Status        WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts      int                   `json:"attempts" db:"attempts"`
+	ResponseCode  int                   `json:"response_code" db:"response_code"`
+	Error         string                `json:"error" db:"error"`
+	NextAttemptAt time.Time             `json:"next_attempt_at" db:"next_attempt_at"`
+	CreatedAt     time.Time             `json:"created_at" db:"created_at"`
+	DeliveredAt   *time.Time            `json:"delivered_at" db:"delivered_at"`
+}